@@ -0,0 +1,198 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package audit implements structured
+// audit logging of queries.
+//
+// Each query produces exactly one Event,
+// which is written to one or more Sinks
+// as a single line of JSON.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SnellerInc/sneller/db"
+)
+
+// Event is the audit record for one query.
+type Event struct {
+	// Time is the time at which
+	// the query was received.
+	Time time.Time `json:"time"`
+	// QueryID is the unique ID of the query.
+	QueryID string `json:"query_id,omitempty"`
+	// TenantID is the ID of the tenant
+	// that ran the query, if the request
+	// was successfully authorized.
+	TenantID string `json:"tenant_id,omitempty"`
+	// RemoteAddr is the address of the client.
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	// Database is the default database
+	// provided with the query.
+	Database string `json:"database,omitempty"`
+	// Query is the redacted query text.
+	// (See expr.Query.Redacted.)
+	Query string `json:"query,omitempty"`
+	// Tables is the list of db.table
+	// names referenced by the query.
+	Tables []string `json:"tables,omitempty"`
+	// Status is the final status of the query.
+	Status string `json:"status"`
+	// Error is the error encountered, if any.
+	Error string `json:"error,omitempty"`
+	// Duration is the time it took
+	// to respond to the query, in seconds.
+	Duration float64 `json:"duration"`
+	// BytesScanned is the number of bytes
+	// scanned while executing the query.
+	BytesScanned int64 `json:"bytes_scanned"`
+
+	// Tenant is the tenant that ran the
+	// query; it is not part of the record,
+	// but it is used by TableSink to determine
+	// the storage into which the event is written.
+	Tenant db.Tenant `json:"-"`
+}
+
+// Sink is a destination for audit events.
+type Sink interface {
+	// Write writes one event.
+	// Write must be safe to call
+	// from multiple goroutines.
+	Write(ev *Event) error
+	// Close flushes any buffered events
+	// and releases the resources
+	// associated with the Sink.
+	Close() error
+}
+
+// Writer is a Sink that writes
+// events to an io.Writer.
+type Writer struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+// NewWriter constructs a Writer that
+// writes to w. If w implements io.Closer,
+// it is closed when the Writer is closed.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write implements Sink.Write
+func (w *Writer) Write(ev *Event) error {
+	buf, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err = w.w.Write(buf)
+	return err
+}
+
+// Close implements Sink.Close
+func (w *Writer) Close() error {
+	if c, ok := w.w.(io.Closer); ok && w.w != os.Stdout && w.w != os.Stderr {
+		return c.Close()
+	}
+	return nil
+}
+
+// Multi is a Sink that writes
+// events to each of its Sinks.
+type Multi []Sink
+
+// Write implements Sink.Write.
+// Every sink is written to, even
+// if one of them returns an error.
+func (m Multi) Write(ev *Event) error {
+	var first error
+	for i := range m {
+		if err := m[i].Write(ev); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Close implements Sink.Close
+func (m Multi) Close() error {
+	var first error
+	for i := range m {
+		if err := m[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Parse creates a Sink from a specification.
+// The following specifications are accepted:
+//
+//	stdout
+//	file:///path/to/audit.log[?maxsize=<bytes>&backups=<n>]
+//	table://<db>/<table>
+//
+// The "file" sink writes to a local file
+// that is rotated once it reaches maxsize bytes
+// (see RotatingFile), and the "table" sink appends
+// events to a table in the storage of the tenant
+// that ran each query (see TableSink).
+// The builder b is used for table sinks.
+func Parse(spec string, b *db.Builder) (Sink, error) {
+	if spec == "stdout" {
+		return NewWriter(os.Stdout), nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		f := &RotatingFile{Path: u.Path}
+		q := u.Query()
+		if s := q.Get("maxsize"); s != "" {
+			if _, err := fmt.Sscan(s, &f.MaxSize); err != nil {
+				return nil, fmt.Errorf("audit: bad maxsize %q", s)
+			}
+		}
+		if s := q.Get("backups"); s != "" {
+			if _, err := fmt.Sscan(s, &f.MaxBackups); err != nil {
+				return nil, fmt.Errorf("audit: bad backups %q", s)
+			}
+		}
+		if err := f.open(); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case "table":
+		table := strings.Trim(u.Path, "/")
+		if u.Host == "" || table == "" || strings.Contains(table, "/") {
+			return nil, fmt.Errorf("audit: table spec %q should be table://<db>/<table>", spec)
+		}
+		return NewTableSink(b, u.Host, table), nil
+	}
+	return nil, fmt.Errorf("audit: unrecognized sink %q", spec)
+}
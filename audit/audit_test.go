@@ -0,0 +1,258 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/db"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+func event(i int) *Event {
+	return &Event{
+		Time:         time.Date(2022, 6, 1, 0, 0, i, 0, time.UTC),
+		QueryID:      fmt.Sprintf("query-%d", i),
+		TenantID:     "test-tenant",
+		Query:        "SELECT COUNT(*) FROM foo",
+		Tables:       []string{"default.foo"},
+		Status:       "ok",
+		BytesScanned: int64(i * 100),
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 0; i < 3; i++ {
+		if err := w.Write(event(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines", len(lines))
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(lines[2]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.QueryID != "query-2" || ev.BytesScanned != 200 || ev.Tables[0] != "default.foo" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "audit.log")
+	s, err := Parse("file://"+name+"?maxsize=1000&backups=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := s.(*RotatingFile)
+	for i := 0; i < 50; i++ {
+		if err := f.Write(event(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lst, err := filepath.Glob(name + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lst) != 3 {
+		t.Fatalf("expected 3 files; got %v", lst)
+	}
+	for _, p := range lst {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 1000 {
+			t.Errorf("%s has size %d", p, info.Size())
+		}
+	}
+	// the most recent event should be
+	// at the end of the current file
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf, []byte(`"query_id":"query-49"`)) {
+		t.Errorf("current file missing most recent event:\n%s", buf)
+	}
+}
+
+type testTenant struct {
+	root *db.DirFS
+	key  *blockfmt.Key
+}
+
+func (t *testTenant) ID() string                { return "test-tenant" }
+func (t *testTenant) Root() (db.InputFS, error) { return t.root, nil }
+func (t *testTenant) Key() *blockfmt.Key        { return t.key }
+
+func (t *testTenant) Split(pat string) (db.InputFS, string, error) {
+	return nil, "", fmt.Errorf("cannot split %q", pat)
+}
+
+func TestTableSink(t *testing.T) {
+	dfs := db.NewDirFS(t.TempDir())
+	defer dfs.Close()
+	tenant := &testTenant{root: dfs, key: new(blockfmt.Key)}
+	rand.Read(tenant.key[:])
+
+	s, err := Parse("table://audit/queries", &db.Builder{Align: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := s.(*TableSink)
+	ts.MaxEvents = 5
+	ts.Logf = t.Logf
+	for i := 0; i < 7; i++ {
+		ev := event(i)
+		ev.Tenant = tenant
+		if err := ts.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// should be discarded
+	if err := ts.Write(event(100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Close(); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := db.OpenIndex(dfs, "audit", "queries", tenant.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Inputs.Backing = dfs
+	inputs := 0
+	err = idx.Inputs.Walk("", func(name, etag string, id int) bool {
+		inputs++
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inputs != 2 {
+		t.Errorf("expected 2 inputs; got %d", inputs)
+	}
+	var out bytes.Buffer
+	for i := range idx.Inline {
+		desc := &idx.Inline[i]
+		f, err := dfs.Open(desc.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dec blockfmt.Decoder
+		dec.Set(desc.Trailer, len(desc.Trailer.Blocks))
+		var raw bytes.Buffer
+		_, err = dec.Copy(&raw, io.LimitReader(f, desc.Trailer.Offset))
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ion.ToJSON(&out, bufio.NewReader(&raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 7; i++ {
+		if !strings.Contains(out.String(), fmt.Sprintf(`"query-%d"`, i)) {
+			t.Errorf("missing query-%d", i)
+		}
+	}
+	if strings.Contains(out.String(), "query-100") {
+		t.Error("event without a tenant was written")
+	}
+}
+
+func TestTableSinkBuildAgain(t *testing.T) {
+	tenant := &testTenant{root: db.NewDirFS(t.TempDir()), key: new(blockfmt.Key)}
+	ts := NewTableSink(&db.Builder{}, "audit", "queries")
+	ts.MaxEvents = 2
+	ts.Logf = t.Logf
+	attempts := 0
+	ts.append = func(db.Tenant, string, string, []blockfmt.Input) error {
+		attempts++
+		return db.ErrBuildAgain
+	}
+	for i := 0; i < 3; i++ {
+		ev := event(i)
+		ev.Tenant = tenant
+		if err := ts.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts.Close()
+	// both batches are attempted
+	// a bounded number of times
+	if attempts != 2*flushAttempts {
+		t.Errorf("got %d attempts; expected %d", attempts, 2*flushAttempts)
+	}
+	if n := ts.Dropped(); n != 3 {
+		t.Errorf("got %d dropped events; expected 3", n)
+	}
+}
+
+func TestTableSinkBackground(t *testing.T) {
+	tenant := &testTenant{root: db.NewDirFS(t.TempDir()), key: new(blockfmt.Key)}
+	ts := NewTableSink(&db.Builder{}, "audit", "queries")
+	ts.MaxEvents = 1
+	ts.Logf = t.Logf
+	unblock := make(chan struct{})
+	flushed := 0
+	ts.append = func(db.Tenant, string, string, []blockfmt.Input) error {
+		<-unblock
+		flushed++
+		return nil
+	}
+	// with appends blocked, Write must not
+	// block, and events beyond the queue are dropped
+	total := maxQueuedBatches + 5
+	for i := 0; i < total; i++ {
+		ev := event(i)
+		ev.Tenant = tenant
+		if err := ts.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(unblock)
+	if err := ts.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dropped := int(ts.Dropped())
+	if dropped == 0 {
+		t.Error("expected some events to be dropped")
+	}
+	if flushed+dropped != total {
+		t.Errorf("flushed %d + dropped %d != %d", flushed, dropped, total)
+	}
+}
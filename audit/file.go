@@ -0,0 +1,145 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// DefaultMaxSize is the default
+// maximum size of a RotatingFile.
+const DefaultMaxSize = 100 * 1024 * 1024
+
+// DefaultMaxBackups is the default
+// number of rotated files kept by
+// a RotatingFile.
+const DefaultMaxBackups = 5
+
+// RotatingFile is a Sink that writes to
+// a local file. Once the file reaches MaxSize
+// bytes, it is renamed to Path+".1"
+// (and any existing Path+".N" is renamed
+// to Path+".N+1") and a new file is created.
+type RotatingFile struct {
+	// Path is the path of the file.
+	Path string
+	// MaxSize is the size at which the file
+	// is rotated. If MaxSize is zero,
+	// DefaultMaxSize is used.
+	MaxSize int64
+	// MaxBackups is the number of rotated
+	// files to keep. If MaxBackups is zero,
+	// DefaultMaxBackups is used.
+	MaxBackups int
+
+	lock sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func (r *RotatingFile) maxSize() int64 {
+	if r.MaxSize > 0 {
+		return r.MaxSize
+	}
+	return DefaultMaxSize
+}
+
+func (r *RotatingFile) maxBackups() int {
+	if r.MaxBackups > 0 {
+		return r.MaxBackups
+	}
+	return DefaultMaxBackups
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.Path, n)
+}
+
+// rotate performs the file rotation;
+// the caller must hold r.lock
+func (r *RotatingFile) rotate() error {
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+	max := r.maxBackups()
+	os.Remove(r.backup(max))
+	for n := max - 1; n >= 1; n-- {
+		err := os.Rename(r.backup(n), r.backup(n+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(r.Path, r.backup(1)); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// Write implements Sink.Write
+func (r *RotatingFile) Write(ev *Event) error {
+	buf, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	if r.size > 0 && r.size+int64(len(buf)) > r.maxSize() {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.Write(buf)
+	r.size += int64(n)
+	return err
+}
+
+// Close implements Sink.Close
+func (r *RotatingFile) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,293 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SnellerInc/sneller/db"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+const (
+	// DefaultFlushInterval is the default
+	// interval at which a TableSink flushes events.
+	DefaultFlushInterval = 10 * time.Second
+	// DefaultMaxEvents is the default number
+	// of buffered events for one tenant that
+	// causes a TableSink to flush immediately.
+	DefaultMaxEvents = 1000
+
+	// maxQueuedBatches is the number of full
+	// batches that may wait to be flushed;
+	// batches beyond this limit are dropped
+	maxQueuedBatches = 8
+	// flushAttempts is the number of times
+	// that appending a batch is attempted
+	// before the batch is dropped
+	flushAttempts = 5
+	// flushBackoff is the initial delay
+	// between attempts to append a batch
+	flushBackoff = 100 * time.Millisecond
+)
+
+// TableSink is a Sink that appends events
+// to a table using db.Builder.Append, so
+// that the audit log can be queried with Sneller.
+//
+// Events are written into the storage of the
+// tenant that ran each query (see Event.Tenant);
+// events without a Tenant are discarded.
+// Events are buffered in memory and flushed
+// as one JSON object per batch.
+// Batches are always flushed in the background;
+// if flushing falls behind or keeps failing,
+// batches are dropped (see Dropped) rather
+// than delaying the caller of Write.
+type TableSink struct {
+	// Builder is the builder used to
+	// append events to the table.
+	Builder *db.Builder
+	// DB and Table are the database
+	// and table into which events are written.
+	DB, Table string
+	// FlushInterval is the maximum amount
+	// of time that events are buffered.
+	// If FlushInterval is zero, DefaultFlushInterval is used.
+	FlushInterval time.Duration
+	// MaxEvents is the maximum number of
+	// buffered events per tenant.
+	// If MaxEvents is zero, DefaultMaxEvents is used.
+	MaxEvents int
+	// Logf, if non-nil, is used to
+	// log errors encountered while flushing.
+	Logf func(f string, args ...interface{})
+
+	lock    sync.Mutex
+	pending map[string]*batch
+	once    sync.Once
+	full    chan *batch
+	done    chan struct{}
+	stopped chan struct{}
+	dropped int64
+
+	// append is Builder.Append; it is
+	// replaced in testing
+	append func(t db.Tenant, db, table string, lst []blockfmt.Input) error
+}
+
+type batch struct {
+	tenant db.Tenant
+	buf    bytes.Buffer
+	count  int
+}
+
+// NewTableSink constructs a TableSink
+// that appends to dbname.table using b.
+func NewTableSink(b *db.Builder, dbname, table string) *TableSink {
+	return &TableSink{
+		Builder: b,
+		DB:      dbname,
+		Table:   table,
+	}
+}
+
+func (t *TableSink) logf(f string, args ...interface{}) {
+	if t.Logf != nil {
+		t.Logf(f, args...)
+	}
+}
+
+func (t *TableSink) start() {
+	t.once.Do(func() {
+		t.full = make(chan *batch, maxQueuedBatches)
+		t.done = make(chan struct{})
+		t.stopped = make(chan struct{})
+		if t.append == nil {
+			t.append = t.Builder.Append
+		}
+		go t.run()
+	})
+}
+
+func (t *TableSink) interval() time.Duration {
+	if t.FlushInterval > 0 {
+		return t.FlushInterval
+	}
+	return DefaultFlushInterval
+}
+
+func (t *TableSink) maxEvents() int {
+	if t.MaxEvents > 0 {
+		return t.MaxEvents
+	}
+	return DefaultMaxEvents
+}
+
+func (t *TableSink) run() {
+	defer close(t.stopped)
+	tick := time.NewTicker(t.interval())
+	defer tick.Stop()
+	for {
+		select {
+		case b := <-t.full:
+			t.flushOne(b)
+		case <-tick.C:
+			t.flushAll()
+		case <-t.done:
+			for {
+				select {
+				case b := <-t.full:
+					t.flushOne(b)
+				default:
+					t.flushAll()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Dropped returns the number of events
+// that have been dropped because they
+// could not be flushed.
+func (t *TableSink) Dropped() int64 {
+	return atomic.LoadInt64(&t.dropped)
+}
+
+func (t *TableSink) drop(b *batch) {
+	atomic.AddInt64(&t.dropped, int64(b.count))
+}
+
+func (t *TableSink) flushOne(b *batch) {
+	if err := t.flush(b); err != nil {
+		t.logf("audit: dropping %d events for %s: %s", b.count, b.tenant.ID(), err)
+		t.drop(b)
+	}
+}
+
+// take removes and returns all of the pending batches
+func (t *TableSink) take() []*batch {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	out := make([]*batch, 0, len(t.pending))
+	for id, b := range t.pending {
+		out = append(out, b)
+		delete(t.pending, id)
+	}
+	return out
+}
+
+func (t *TableSink) flushAll() error {
+	var first error
+	for _, b := range t.take() {
+		if err := t.flush(b); err != nil {
+			t.logf("audit: dropping %d events for %s: %s", b.count, b.tenant.ID(), err)
+			t.drop(b)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func (t *TableSink) flush(b *batch) error {
+	var rnd [8]byte
+	rand.Read(rnd[:])
+	sum := sha256.Sum256(b.buf.Bytes())
+	now := time.Now().UTC()
+	in := blockfmt.Input{
+		// the path and ETag are only used
+		// for de-duplicating inputs to the table
+		Path: path.Join("audit", t.DB, t.Table, now.Format("20060102T150405Z")+"-"+hex.EncodeToString(rnd[:])+".json"),
+		ETag: `"` + hex.EncodeToString(sum[:]) + `"`,
+		Size: int64(b.buf.Len()),
+		R:    io.NopCloser(bytes.NewReader(b.buf.Bytes())),
+		F:    blockfmt.SuffixToFormat[".json"](),
+	}
+	delay := flushBackoff
+	for i := 1; ; i++ {
+		err := t.append(b.tenant, t.DB, t.Table, []blockfmt.Input{in})
+		if !errors.Is(err, db.ErrBuildAgain) || i == flushAttempts {
+			return err
+		}
+		// the table is being scanned or
+		// written concurrently; try again later
+		time.Sleep(delay)
+		delay *= 2
+		in.R = io.NopCloser(bytes.NewReader(b.buf.Bytes()))
+	}
+}
+
+// Write implements Sink.Write
+func (t *TableSink) Write(ev *Event) error {
+	if ev.Tenant == nil {
+		return nil
+	}
+	buf, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	t.start()
+	id := ev.Tenant.ID()
+	t.lock.Lock()
+	if t.pending == nil {
+		t.pending = make(map[string]*batch)
+	}
+	b := t.pending[id]
+	if b == nil {
+		b = &batch{}
+		t.pending[id] = b
+	}
+	// use the most recent tenant credentials
+	b.tenant = ev.Tenant
+	b.buf.Write(buf)
+	b.buf.WriteByte('\n')
+	b.count++
+	if b.count < t.maxEvents() {
+		t.lock.Unlock()
+		return nil
+	}
+	delete(t.pending, id)
+	t.lock.Unlock()
+	select {
+	case t.full <- b:
+	default:
+		t.logf("audit: flush queue full; dropping %d events for %s", b.count, id)
+		t.drop(b)
+	}
+	return nil
+}
+
+// Close implements Sink.Close.
+// Close flushes all pending events.
+func (t *TableSink) Close() error {
+	t.start()
+	close(t.done)
+	<-t.stopped
+	// flush anything that raced with run() exiting
+	return t.flushAll()
+}
@@ -46,7 +46,9 @@ type Provider interface {
 // given specification.
 //
 // It uses an authorization endpoint when a
-// http(s):// prefix is detected and otherwise
+// http(s):// prefix is detected, validates
+// JSON Web Tokens using the configuration file
+// following a jwt:// prefix, and otherwise
 // the specification is considered to be a
 // file-name. If no specification is used,
 // then it will use environment variables.
@@ -54,6 +56,9 @@ func Parse(spec string) (Provider, error) {
 	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
 		return FromEndPoint(spec)
 	}
+	if strings.HasPrefix(spec, "jwt://") {
+		return FromJWT(strings.TrimPrefix(spec, "jwt://"))
+	}
 
 	if spec != "" {
 		return FromFile(spec)
@@ -64,6 +69,12 @@ func Parse(spec string) (Provider, error) {
 
 // Create an authorization provider based
 // on environment variables.
+// If SNELLER_ROOT is set, then the tenant
+// is rooted in that local directory rather
+// than in SNELLER_BUCKET.
+// If SNELLER_BUCKET is a gs:// or az:// URI,
+// then the GCS_* or AZURE_STORAGE_* variables
+// are used in place of the AWS credentials.
 func FromEnvironment() (Provider, error) {
 	mustGetenv := func(env string) (string, error) {
 		val := os.Getenv(env)
@@ -93,6 +104,19 @@ func FromEnvironment() (Provider, error) {
 		return nil, errors.New("invalid 'SNELLER_INDEX_KEY'")
 	}
 
+	// SNELLER_ROOT selects a local directory
+	// rather than an S3 bucket for storage
+	if root := os.Getenv("SNELLER_ROOT"); root != "" {
+		return &DirStatic{
+			Tenants: []DirIdentity{{
+				ID:       "default",
+				IndexKey: indexKey,
+				Root:     root,
+				Tokens:   []string{token},
+			}},
+		}, nil
+	}
+
 	creds := S3Static{
 		CheckToken: func(t string) error {
 			if t != token {
@@ -108,14 +132,40 @@ func FromEnvironment() (Provider, error) {
 		},
 	}
 
-	specs := []struct {
+	creds.Bucket, err = mustGetenv("SNELLER_BUCKET")
+	if err != nil {
+		return nil, err
+	}
+
+	type spec struct {
 		env    string
 		target *string
-	}{
-		{"SNELLER_BUCKET", &creds.Bucket},
-		{"AWS_ACCESS_KEY_ID", &creds.Credentials.AccessKeyID},
-		{"AWS_SECRET_ACCESS_KEY", &creds.Credentials.SecretAccessKey},
-		{"S3_ENDPOINT", &creds.Credentials.BaseURI},
+	}
+	var specs []spec
+	switch {
+	case strings.HasPrefix(creds.Bucket, "gs://"):
+		creds.GCS = &GCSCredentials{
+			BaseURI: os.Getenv("GCS_ENDPOINT"),
+		}
+		specs = []spec{
+			{"GCS_ACCESS_KEY_ID", &creds.GCS.AccessKeyID},
+			{"GCS_SECRET_ACCESS_KEY", &creds.GCS.SecretAccessKey},
+		}
+	case strings.HasPrefix(creds.Bucket, "az://"):
+		creds.Azure = &AzureCredentials{
+			BaseURI:   os.Getenv("AZURE_STORAGE_ENDPOINT"),
+			SharedKey: os.Getenv("AZURE_STORAGE_KEY"),
+			SAS:       os.Getenv("AZURE_STORAGE_SAS"),
+		}
+		specs = []spec{
+			{"AZURE_STORAGE_ACCOUNT", &creds.Azure.Account},
+		}
+	default:
+		specs = []spec{
+			{"AWS_ACCESS_KEY_ID", &creds.Credentials.AccessKeyID},
+			{"AWS_SECRET_ACCESS_KEY", &creds.Credentials.SecretAccessKey},
+			{"S3_ENDPOINT", &creds.Credentials.BaseURI},
+		}
 	}
 
 	for _, spec := range specs {
@@ -142,7 +192,11 @@ func FromEndPoint(uri string) (Provider, error) {
 // Create an authorization provider that reads
 // the credential information from the given
 // file-name.
-// See alse S3Static.
+// If the file has a "tenants" list, then
+// each entry maps a set of tokens to a tenant
+// rooted in a local directory (see DirStatic);
+// otherwise, the file describes a single
+// S3 identity (see S3Static).
 func FromFile(fileName string) (Provider, error) {
 	// for clarity, allow file:// in the spec
 	fileName = strings.TrimPrefix(fileName, "file://")
@@ -150,11 +204,15 @@ func FromFile(fileName string) (Provider, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 	var static fileCreds
 	err = json.NewDecoder(f).Decode(&static)
 	if err != nil {
 		return nil, err
 	}
+	if static.Tenants != nil {
+		return &DirStatic{Tenants: static.Tenants}, nil
+	}
 	if static.Allowed != nil {
 		allowed := make(map[string]struct{}, len(static.Allowed))
 		notAllowed := errors.New("token not allowed")
@@ -171,5 +229,6 @@ func FromFile(fileName string) (Provider, error) {
 
 type fileCreds struct {
 	S3Static
-	Allowed []string `json:"allowed_tokens"`
+	Allowed []string      `json:"allowed_tokens"`
+	Tenants []DirIdentity `json:"tenants"`
 }
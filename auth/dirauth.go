@@ -0,0 +1,137 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/SnellerInc/sneller/db"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+var (
+	_ Provider         = &DirStatic{}
+	_ LimitedTenant    = &dirTenant{}
+	_ RestrictedTenant = &dirTenant{}
+)
+
+// DirIdentity describes a tenant whose
+// storage is rooted in a local directory
+// (or a network filesystem mounted locally).
+//
+// Every process that executes queries on behalf
+// of the tenant must be able to read Root at
+// the same path.
+type DirIdentity struct {
+	ID       string `json:"TenantID"`
+	IndexKey []byte `json:"IndexKey"`
+	// Root is the absolute path of the
+	// directory in which the tenant's
+	// databases and input objects are stored.
+	// Input patterns using the file:// scheme
+	// are interpreted relative to Root.
+	Root string `json:"Root"`
+	// Limits, if present, describes
+	// the resource limits of the tenant.
+	Limits *Limits `json:"Limits,omitempty"`
+	// Databases, if non-nil, is the list
+	// of databases that the tenant may access.
+	// The special name "*" matches every database.
+	Databases []string `json:"Databases,omitempty"`
+	// Tokens is the list of tokens that
+	// are accepted for this tenant by DirStatic.
+	Tokens []string `json:"Tokens,omitempty"`
+}
+
+// Tenant converts the DirIdentity into a db.Tenant.
+// Tenant will perform some validation of the
+// fields in d to confirm that it describes
+// a valid configuration.
+func (d *DirIdentity) Tenant() (db.Tenant, error) {
+	if d.ID == "" {
+		return nil, fmt.Errorf("DirIdentity missing TenantID")
+	}
+	if !filepath.IsAbs(d.Root) {
+		return nil, fmt.Errorf("DirIdentity root %q is not an absolute path", d.Root)
+	}
+	info, err := os.Stat(d.Root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("DirIdentity root %q is not a directory", d.Root)
+	}
+	k := new(blockfmt.Key)
+	if copy(k[:], d.IndexKey) != len(k[:]) {
+		return nil, fmt.Errorf("invalid len(IndexKey)=%d", len(d.IndexKey))
+	}
+	root := db.NewDirFS(d.Root)
+	root.Local = true
+	return &dirTenant{
+		id:     d.ID,
+		root:   root,
+		ikey:   k,
+		limits: d.Limits,
+		dbs:    d.Databases,
+	}, nil
+}
+
+// dirTenant implements db.Tenant
+type dirTenant struct {
+	id     string
+	root   *db.DirFS
+	ikey   *blockfmt.Key
+	limits *Limits
+	dbs    []string
+}
+
+func (d *dirTenant) ID() string                { return d.id }
+func (d *dirTenant) Key() *blockfmt.Key        { return d.ikey }
+func (d *dirTenant) Root() (db.InputFS, error) { return d.root, nil }
+func (d *dirTenant) Limits() *Limits           { return d.limits }
+
+func (d *dirTenant) Split(pattern string) (db.InputFS, string, error) {
+	return d.root.Split(pattern)
+}
+
+func (d *dirTenant) AllowDatabase(name string) bool {
+	return allowDatabase(d.dbs, name)
+}
+
+// DirStatic is a Provider that maps
+// tokens to a static set of tenants
+// whose storage is rooted in local directories.
+type DirStatic struct {
+	Tenants []DirIdentity
+}
+
+var errUnknownToken = errors.New("unknown token")
+
+// Authorize implements Provider.Authorize
+func (d *DirStatic) Authorize(ctx context.Context, token string) (db.Tenant, error) {
+	for i := range d.Tenants {
+		for _, tok := range d.Tenants[i].Tokens {
+			if subtle.ConstantTimeCompare([]byte(tok), []byte(token)) == 1 {
+				return d.Tenants[i].Tenant()
+			}
+		}
+	}
+	return nil, errUnknownToken
+}
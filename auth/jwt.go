@@ -0,0 +1,649 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // for crypto.SHA256
+	_ "crypto/sha512" // for crypto.SHA384, crypto.SHA512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SnellerInc/sneller/aws"
+	"github.com/SnellerInc/sneller/db"
+)
+
+var _ Provider = &JWT{}
+
+const (
+	// DefaultJWKSRefresh is the default interval
+	// at which a JSON Web Key Set is reloaded.
+	DefaultJWKSRefresh = time.Hour
+
+	// a JWKS is never reloaded more often
+	// than this due to an unknown key ID,
+	// so that bogus tokens can't be used
+	// to hammer the identity provider
+	minJWKSRefresh = time.Minute
+
+	// after a JWKS fails to load, it is
+	// not loaded again for this long
+	jwksFailureBackoff = 10 * time.Second
+
+	// assumed role credentials are
+	// refreshed when they are within this
+	// interval of expiring
+	roleExpiryMargin = 5 * time.Minute
+)
+
+// JWT is a Provider that accepts bearer tokens
+// that are JSON Web Tokens (for example, OpenID Connect
+// ID tokens issued by an identity provider) and validates
+// them locally against the keys in a JSON Web Key Set.
+//
+// Tokens must carry a valid signature,
+// an "exp" claim that has not passed, and an "aud"
+// claim that includes Audience. The tenant identity
+// is built from the embedded S3BearerIdentity, with
+// the tenant ID, the list of accessible databases,
+// and the IAM role used to access S3 taken from the
+// claims named by TenantClaim, DatabasesClaim and RoleClaim,
+// respectively.
+type JWT struct {
+	// JWKS is the location of the JSON Web Key Set
+	// used to verify token signatures. It may be
+	// an http:// or https:// URL or the path
+	// of a local file (optionally prefixed with file://).
+	JWKS string `json:"JWKS"`
+	// Issuer, if non-empty, is the required
+	// value of the "iss" claim.
+	Issuer string `json:"Issuer,omitempty"`
+	// Audience is the value that must be
+	// present in the "aud" claim.
+	Audience string `json:"Audience"`
+	// TenantClaim is the name of the claim
+	// that holds the tenant ID.
+	// The default is "sub".
+	TenantClaim string `json:"TenantClaim,omitempty"`
+	// DatabasesClaim, if non-empty, is the name of
+	// the claim that holds the list of databases that
+	// the tenant may access (either as a list of strings
+	// or as a single space-separated string).
+	// Tokens without the claim cannot access any database.
+	// If DatabasesClaim is empty, every database is accessible.
+	DatabasesClaim string `json:"DatabasesClaim,omitempty"`
+	// RoleClaim, if non-empty, is the name of the claim
+	// that holds the ARN of the IAM role that is assumed
+	// in order to access S3 on behalf of the tenant.
+	// The role is assumed using Credentials, or the ambient
+	// AWS credentials if Credentials are not provided.
+	// Tokens without the claim are rejected.
+	// If RoleClaim is empty, Credentials are used directly.
+	RoleClaim string `json:"RoleClaim,omitempty"`
+	// STSEndpoint, if non-empty, overrides
+	// the endpoint used to assume roles.
+	STSEndpoint string `json:"STSEndpoint,omitempty"`
+	// Leeway is the number of seconds of clock skew
+	// that is tolerated when checking "exp" and "nbf".
+	Leeway int `json:"Leeway,omitempty"`
+	// RefreshSeconds is the interval at which
+	// the JWKS is reloaded. If it is zero,
+	// DefaultJWKSRefresh is used.
+	RefreshSeconds int `json:"RefreshSeconds,omitempty"`
+	// S3BearerIdentity is the template for the
+	// identity of each tenant. The ID (and, when RoleClaim
+	// is used, the Credentials) are replaced with values
+	// derived from each token.
+	S3BearerIdentity
+
+	// Client is the client used to fetch
+	// the JWKS and assume roles.
+	// If Client is nil, http.DefaultClient is used.
+	Client *http.Client `json:"-"`
+
+	lock    sync.Mutex
+	keys    []jwk
+	fetched time.Time
+	// failed is the time of the last
+	// failure to load the JWKS, and
+	// lasterr is the error
+	failed  time.Time
+	lasterr error
+	// loading is non-nil while the
+	// JWKS is being loaded; it is closed
+	// when loading is done
+	loading chan struct{}
+
+	rlock sync.Mutex
+	roles map[string]*aws.Credentials
+}
+
+// FromJWT creates a JWT authorization provider
+// from the JSON configuration in the given file.
+func FromJWT(fileName string) (Provider, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	j := new(JWT)
+	err = json.NewDecoder(f).Decode(j)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	if j.JWKS == "" {
+		return nil, fmt.Errorf("%s: missing JWKS", fileName)
+	}
+	if j.Audience == "" {
+		return nil, fmt.Errorf("%s: missing Audience", fileName)
+	}
+	return j, nil
+}
+
+func (j *JWT) client() *http.Client {
+	if j.Client == nil {
+		return http.DefaultClient
+	}
+	return j.Client
+}
+
+// Authorize implements Provider.Authorize
+func (j *JWT) Authorize(ctx context.Context, token string) (db.Tenant, error) {
+	claims, err := j.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	id := j.S3BearerIdentity
+	tc := j.TenantClaim
+	if tc == "" {
+		tc = "sub"
+	}
+	id.ID, _ = claims[tc].(string)
+	if id.ID == "" {
+		return nil, fmt.Errorf("jwt: missing %q claim", tc)
+	}
+	if j.DatabasesClaim != "" {
+		id.Databases, err = stringList(claims[j.DatabasesClaim])
+		if err != nil {
+			return nil, fmt.Errorf("jwt: claim %q: %w", j.DatabasesClaim, err)
+		}
+	}
+	if j.RoleClaim != "" {
+		role, _ := claims[j.RoleClaim].(string)
+		if role == "" {
+			return nil, fmt.Errorf("jwt: missing %q claim", j.RoleClaim)
+		}
+		c, err := j.assume(role, id.ID)
+		if err != nil {
+			return nil, err
+		}
+		id.Credentials = S3BearerCredentials{
+			BaseURI:         j.Credentials.BaseURI,
+			AccessKeyID:     c.AccessKeyID,
+			SecretAccessKey: c.SecretAccessKey,
+			SessionToken:    c.SessionToken,
+			Source:          role,
+			Expires:         c.Expiration,
+			CanExpire:       true,
+		}
+	}
+	return id.Tenant()
+}
+
+// stringList interprets a claim as a list of strings
+func stringList(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return strings.Fields(v), nil
+	case []interface{}:
+		out := make([]string, len(v))
+		for i := range v {
+			s, ok := v[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected list item %v", v[i])
+			}
+			out[i] = s
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unexpected value %v", v)
+	}
+}
+
+// assume returns credentials for the given role,
+// re-using previously assumed credentials when
+// they are not close to expiring
+func (j *JWT) assume(role, tenant string) (*aws.Credentials, error) {
+	// the session name is recorded in CloudTrail;
+	// it must match [\w+=,.@-]{2,64}
+	session := []byte("sneller-" + tenant)
+	for i, c := range session {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("_+=,.@-", c) >= 0) {
+			session[i] = '_'
+		}
+	}
+	if len(session) > 64 {
+		session = session[:64]
+	}
+	ckey := role + "\x00" + string(session)
+
+	j.rlock.Lock()
+	defer j.rlock.Unlock()
+	if c := j.roles[ckey]; c != nil && time.Until(c.Expiration) > roleExpiryMargin {
+		return c, nil
+	}
+	var k *aws.SigningKey
+	var err error
+	if c := &j.Credentials; c.AccessKeyID != "" {
+		k = aws.DeriveKey(j.STSEndpoint, c.AccessKeyID, c.SecretAccessKey, j.Region, "sts")
+		k.Token = c.SessionToken
+	} else {
+		k, err = aws.AmbientKey("sts", nil)
+		if err != nil {
+			return nil, err
+		}
+		k.BaseURI = j.STSEndpoint
+	}
+	c, err := aws.AssumeRole(j.client(), k, role, string(session), 0)
+	if err != nil {
+		return nil, err
+	}
+	if j.roles == nil {
+		j.roles = make(map[string]*aws.Credentials)
+	}
+	j.roles[ckey] = c
+	return c, nil
+}
+
+// Verify verifies the signature and the
+// standard claims (exp, nbf, iss and aud) of token
+// and returns the token claims.
+func (j *JWT) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("jwt: malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("jwt: signature: %w", err)
+	}
+	keys, err := j.keySet(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(token[:len(parts[0])+1+len(parts[1])])
+	ok := false
+	for i := range keys {
+		k := &keys[i]
+		if (header.Kid != "" && k.Kid != header.Kid) || (k.Alg != "" && k.Alg != header.Alg) {
+			continue
+		}
+		err = verify(header.Alg, k.key, signed, sig)
+		if err == nil {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		if err == nil {
+			err = errors.New("no matching key")
+		}
+		return nil, fmt.Errorf("jwt: invalid signature: %w", err)
+	}
+	var claims map[string]interface{}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: claims: %w", err)
+	}
+	err = j.check(claims, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(s string, into interface{}) error {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, into)
+}
+
+// check checks the registered claims
+func (j *JWT) check(claims map[string]interface{}, now time.Time) error {
+	leeway := time.Duration(j.Leeway) * time.Second
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("jwt: missing exp claim")
+	}
+	if now.Add(-leeway).After(time.Unix(int64(exp), 0)) {
+		return errors.New("jwt: token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("jwt: token not yet valid")
+	}
+	if j.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != j.Issuer {
+			return fmt.Errorf("jwt: unexpected issuer %q", iss)
+		}
+	}
+	aud, err := stringList(claims["aud"])
+	if err != nil {
+		return fmt.Errorf("jwt: aud: %w", err)
+	}
+	for i := range aud {
+		if aud[i] == j.Audience {
+			return nil
+		}
+	}
+	return errors.New("jwt: token not issued for this audience")
+}
+
+// verify verifies sig over signed
+// using the algorithm alg and key k
+func verify(alg string, k crypto.PublicKey, signed, sig []byte) error {
+	var h crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		h = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		h = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		h = crypto.SHA512
+	case "EdDSA":
+		pub, ok := k.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("key type does not match algorithm")
+		}
+		if !ed25519.Verify(pub, signed, sig) {
+			return errors.New("verification failed")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	hh := h.New()
+	hh.Write(signed)
+	digest := hh.Sum(nil)
+	switch alg[0] {
+	case 'R':
+		pub, ok := k.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match algorithm")
+		}
+		return rsa.VerifyPKCS1v15(pub, h, digest, sig)
+	case 'P':
+		pub, ok := k.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match algorithm")
+		}
+		return rsa.VerifyPSS(pub, h, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	default:
+		pub, ok := k.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match algorithm")
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size || size != h.Size() && !(size == 66 && h == crypto.SHA512) {
+			return errors.New("bad signature size")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("verification failed")
+		}
+		return nil
+	}
+}
+
+// jwk is a JSON Web Key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	key crypto.PublicKey
+}
+
+func b64int(s string) (*big.Int, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
+
+func (k *jwk) parse() error {
+	switch k.Kty {
+	case "RSA":
+		n, err := b64int(k.N)
+		if err != nil {
+			return err
+		}
+		e, err := b64int(k.E)
+		if err != nil {
+			return err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return errors.New("bad RSA exponent")
+		}
+		k.key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64int(k.X)
+		if err != nil {
+			return err
+		}
+		y, err := b64int(k.Y)
+		if err != nil {
+			return err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return errors.New("point not on curve")
+		}
+		k.key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return errors.New("bad Ed25519 key size")
+		}
+		k.key = ed25519.PublicKey(x)
+	default:
+		return fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	return nil
+}
+
+// keySet returns the current set of keys,
+// reloading it if it is stale or if it does
+// not contain the key ID kid
+//
+// At most one load is in progress at a time,
+// and it is performed without holding j.lock
+// so that requests that don't need to wait
+// for the JWKS are not delayed by it.
+func (j *JWT) keySet(ctx context.Context, kid string) ([]jwk, error) {
+	refresh := DefaultJWKSRefresh
+	if j.RefreshSeconds > 0 {
+		refresh = time.Duration(j.RefreshSeconds) * time.Second
+	}
+	j.lock.Lock()
+	now := time.Now()
+	stale := j.keys == nil || now.Sub(j.fetched) >= refresh
+	if !stale && kid != "" && now.Sub(j.fetched) >= minJWKSRefresh {
+		stale = true
+		for i := range j.keys {
+			if j.keys[i].Kid == kid {
+				stale = false
+				break
+			}
+		}
+	}
+	// rate-limit loads after a failure
+	if stale && !j.failed.IsZero() && now.Sub(j.failed) < jwksFailureBackoff {
+		stale = false
+	}
+	if !stale {
+		keys, err := j.keys, j.lasterr
+		j.lock.Unlock()
+		if keys == nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+	if wait := j.loading; wait != nil {
+		// someone else is loading the keys
+		j.lock.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		j.lock.Lock()
+		keys, err := j.keys, j.lasterr
+		j.lock.Unlock()
+		if keys == nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+	done := make(chan struct{})
+	j.loading = done
+	j.lock.Unlock()
+
+	keys, err := j.load(ctx)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.loading = nil
+	close(done)
+	if err == nil {
+		j.keys = keys
+		j.fetched = time.Now()
+		j.failed = time.Time{}
+		j.lasterr = nil
+		return keys, nil
+	}
+	// a canceled request is not
+	// a failure of the JWKS
+	if ctx.Err() == nil {
+		j.failed = time.Now()
+		j.lasterr = err
+	}
+	// on error, keep using the old keys
+	if j.keys == nil {
+		return nil, err
+	}
+	return j.keys, nil
+}
+
+// load loads the key set from j.JWKS
+func (j *JWT) load(ctx context.Context) ([]jwk, error) {
+	var r io.ReadCloser
+	if strings.HasPrefix(j.JWKS, "http://") || strings.HasPrefix(j.JWKS, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.JWKS, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		res, err := j.client().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: fetching JWKS: %w", err)
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("jwt: fetching JWKS: %s", res.Status)
+		}
+		r = res.Body
+	} else {
+		f, err := os.Open(strings.TrimPrefix(j.JWKS, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("jwt: loading JWKS: %w", err)
+		}
+		r = f
+	}
+	defer r.Close()
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	err := json.NewDecoder(io.LimitReader(r, 1<<20)).Decode(&set)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: decoding JWKS: %w", err)
+	}
+	keys := set.Keys[:0]
+	for i := range set.Keys {
+		k := set.Keys[i]
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// ignore keys we don't understand
+		// rather than rejecting the whole set
+		if k.parse() != nil {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwt: JWKS contains no usable keys")
+	}
+	return keys, nil
+}
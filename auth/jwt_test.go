@@ -0,0 +1,417 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/db"
+)
+
+type testSigner struct {
+	kid, alg string
+	key      crypto.Signer
+}
+
+func b64(buf []byte) string {
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func (s *testSigner) jwk() map[string]string {
+	m := map[string]string{"kid": s.kid, "use": "sig"}
+	switch k := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		m["kty"] = "RSA"
+		m["n"] = b64(k.N.Bytes())
+		m["e"] = b64(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		m["kty"] = "EC"
+		m["crv"] = k.Curve.Params().Name
+		size := (k.Curve.Params().BitSize + 7) / 8
+		m["x"] = b64(k.X.FillBytes(make([]byte, size)))
+		m["y"] = b64(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		m["kty"] = "OKP"
+		m["crv"] = "Ed25519"
+		m["x"] = b64(k)
+	}
+	return m
+}
+
+func (s *testSigner) sign(t *testing.T, claims map[string]interface{}) string {
+	hdr, _ := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	signed := b64(hdr) + "." + b64(body)
+	var sig []byte
+	var err error
+	switch k := s.key.(type) {
+	case *rsa.PrivateKey:
+		h := sha256.Sum256([]byte(signed))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, h[:])
+	case *ecdsa.PrivateKey:
+		h := sha256.Sum256([]byte(signed))
+		r, s, err2 := ecdsa.Sign(rand.Reader, k, h[:])
+		err = err2
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func writeJWKS(t *testing.T, name string, signers ...*testSigner) {
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for _, s := range signers {
+		set.Keys = append(set.Keys, s.jwk())
+	}
+	buf, _ := json.Marshal(&set)
+	err := os.WriteFile(name, buf, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testSigners(t *testing.T) []*testSigner {
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edk, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []*testSigner{
+		{kid: "rsa", alg: "RS256", key: rk},
+		{kid: "ec", alg: "ES256", key: ek},
+		{kid: "ed", alg: "EdDSA", key: edk},
+	}
+}
+
+func testIdentity() S3BearerIdentity {
+	return S3BearerIdentity{
+		Region:   "us-east-1",
+		IndexKey: make([]byte, 32),
+		Bucket:   "s3://sneller-test",
+		Credentials: S3BearerCredentials{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "secret",
+		},
+	}
+}
+
+func TestJWT(t *testing.T) {
+	signers := testSigners(t)
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwks, signers...)
+
+	j := &JWT{
+		JWKS:             "file://" + jwks,
+		Issuer:           "https://idp.example.com/",
+		Audience:         "sneller",
+		TenantClaim:      "tenant",
+		DatabasesClaim:   "dbs",
+		S3BearerIdentity: testIdentity(),
+	}
+	now := time.Now().Unix()
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":    "https://idp.example.com/",
+			"aud":    []string{"other", "sneller"},
+			"exp":    now + 60,
+			"tenant": "tenant-0",
+			"dbs":    []string{"db0", "db1"},
+		}
+	}
+	for _, s := range signers {
+		tn, err := j.Authorize(context.Background(), s.sign(t, valid()))
+		if err != nil {
+			t.Fatalf("%s: %s", s.alg, err)
+		}
+		if tn.ID() != "tenant-0" {
+			t.Errorf("tenant ID %q", tn.ID())
+		}
+		if !AllowDatabase(tn, "db0") || !AllowDatabase(tn, "db1") || AllowDatabase(tn, "db2") {
+			t.Errorf("%s: unexpected database access", s.alg)
+		}
+	}
+
+	bad := []struct {
+		name   string
+		edit   func(m map[string]interface{})
+		errstr string
+	}{
+		{"expired", func(m map[string]interface{}) { m["exp"] = now - 60 }, "expired"},
+		{"no exp", func(m map[string]interface{}) { delete(m, "exp") }, "missing exp"},
+		{"not yet valid", func(m map[string]interface{}) { m["nbf"] = now + 60 }, "not yet valid"},
+		{"audience", func(m map[string]interface{}) { m["aud"] = "other" }, "audience"},
+		{"issuer", func(m map[string]interface{}) { m["iss"] = "https://evil.example.com/" }, "issuer"},
+		{"tenant", func(m map[string]interface{}) { delete(m, "tenant") }, "tenant"},
+	}
+	for _, b := range bad {
+		claims := valid()
+		b.edit(claims)
+		_, err := j.Authorize(context.Background(), signers[0].sign(t, claims))
+		if err == nil || !strings.Contains(err.Error(), b.errstr) {
+			t.Errorf("%s: got error %v", b.name, err)
+		}
+	}
+
+	// missing databases claim means no access
+	claims := valid()
+	delete(claims, "dbs")
+	tn, err := j.Authorize(context.Background(), signers[1].sign(t, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if AllowDatabase(tn, "db0") {
+		t.Error("expected no database access without claim")
+	}
+
+	// tamper with the claims
+	tok := signers[0].sign(t, valid())
+	parts := strings.Split(tok, ".")
+	claims = valid()
+	claims["tenant"] = "tenant-1"
+	body, _ := json.Marshal(claims)
+	parts[1] = b64(body)
+	_, err = j.Authorize(context.Background(), strings.Join(parts, "."))
+	if err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Errorf("tampered token: got error %v", err)
+	}
+
+	// a key that is not in the set
+	other := testSigners(t)[0]
+	_, err = j.Authorize(context.Background(), other.sign(t, valid()))
+	if err == nil {
+		t.Error("expected error for unknown key")
+	}
+	// the "none" algorithm must never be accepted
+	none := &testSigner{kid: "rsa", alg: "none", key: signers[0].key}
+	tok = none.sign(t, valid())
+	tok = tok[:strings.LastIndexByte(tok, '.')+1]
+	_, err = j.Authorize(context.Background(), tok)
+	if err == nil {
+		t.Error("accepted alg=none")
+	}
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	signers := testSigners(t)
+	var fetches int32
+	var current atomic.Value
+	current.Store(signers[:1])
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		name := filepath.Join(t.TempDir(), "jwks.json")
+		writeJWKS(t, name, current.Load().([]*testSigner)...)
+		http.ServeFile(w, r, name)
+	}))
+	defer srv.Close()
+
+	j := &JWT{
+		JWKS:             srv.URL,
+		Audience:         "sneller",
+		S3BearerIdentity: testIdentity(),
+	}
+	claims := map[string]interface{}{
+		"aud": "sneller",
+		"exp": time.Now().Unix() + 60,
+		"sub": "tenant-0",
+	}
+	_, err := j.Authorize(context.Background(), signers[0].sign(t, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rotate keys; tokens with the new kid
+	// are rejected until the minimum refresh
+	// interval has passed
+	current.Store(signers[1:])
+	_, err = j.Authorize(context.Background(), signers[1].sign(t, claims))
+	if err == nil {
+		t.Fatal("expected error before refresh")
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("%d fetches", n)
+	}
+	j.lock.Lock()
+	j.fetched = j.fetched.Add(-minJWKSRefresh)
+	j.lock.Unlock()
+	_, err = j.Authorize(context.Background(), signers[1].sign(t, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Fatalf("%d fetches", n)
+	}
+}
+
+func TestJWKSFailure(t *testing.T) {
+	signers := testSigners(t)
+	var fetches int32
+	var ok int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if atomic.LoadInt32(&ok) == 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		<-release
+		name := filepath.Join(t.TempDir(), "jwks.json")
+		writeJWKS(t, name, signers[0])
+		http.ServeFile(w, r, name)
+	}))
+	defer srv.Close()
+
+	j := &JWT{
+		JWKS:             srv.URL,
+		Audience:         "sneller",
+		S3BearerIdentity: testIdentity(),
+	}
+	claims := map[string]interface{}{
+		"aud": "sneller",
+		"exp": time.Now().Unix() + 60,
+		"sub": "tenant-0",
+	}
+	tok := signers[0].sign(t, claims)
+	// a failing JWKS is not fetched again
+	// until the backoff interval has passed
+	for i := 0; i < 5; i++ {
+		_, err := j.Authorize(context.Background(), tok)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("%d fetches", n)
+	}
+
+	// once the JWKS is available again,
+	// concurrent requests share one fetch
+	atomic.StoreInt32(&ok, 1)
+	j.lock.Lock()
+	j.failed = j.failed.Add(-jwksFailureBackoff)
+	j.lock.Unlock()
+	const parallel = 8
+	errs := make(chan error, parallel)
+	for i := 0; i < parallel; i++ {
+		go func() {
+			_, err := j.Authorize(context.Background(), tok)
+			errs <- err
+		}()
+	}
+	// wait for the first request to
+	// reach the server before releasing it
+	for atomic.LoadInt32(&fetches) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < parallel; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Fatalf("%d fetches", n)
+	}
+}
+
+func TestJWTAssumeRole(t *testing.T) {
+	signers := testSigners(t)
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwks, signers...)
+
+	var calls int32
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		q := r.URL.Query()
+		if q.Get("Action") != "AssumeRole" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials>
+<AccessKeyId>ASIA-%s</AccessKeyId>
+<SecretAccessKey>role-secret</SecretAccessKey>
+<SessionToken>role-token</SessionToken>
+<Expiration>%s</Expiration>
+</Credentials></AssumeRoleResult></AssumeRoleResponse>`,
+			q.Get("RoleSessionName"), time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer sts.Close()
+
+	j := &JWT{
+		JWKS:             jwks,
+		Audience:         "sneller",
+		RoleClaim:        "role",
+		STSEndpoint:      sts.URL,
+		S3BearerIdentity: testIdentity(),
+	}
+	claims := map[string]interface{}{
+		"aud":  "sneller",
+		"exp":  time.Now().Unix() + 60,
+		"sub":  "tenant/0",
+		"role": "arn:aws:iam::123456789012:role/tenant-0",
+	}
+	for i := 0; i < 2; i++ {
+		tn, err := j.Authorize(context.Background(), signers[2].sign(t, claims))
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := tn.(*s3Tenant).root.(*db.S3FS)
+		if root.Key.AccessKey != "ASIA-sneller-tenant_0" || root.Key.Token != "role-token" {
+			t.Errorf("unexpected key %+v", root.Key)
+		}
+		if !AllowDatabase(tn, "anything") {
+			t.Error("expected access to all databases")
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("%d calls to sts:AssumeRole", n)
+	}
+	delete(claims, "role")
+	_, err := j.Authorize(context.Background(), signers[2].sign(t, claims))
+	if err == nil {
+		t.Error("expected error without role claim")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"github.com/SnellerInc/sneller/db"
+)
+
+// Limits describes the resource limits
+// that apply to queries run on behalf of
+// a tenant. A zero value for any of the
+// fields means that there is no limit.
+type Limits struct {
+	// MaxConcurrentQueries is the maximum
+	// number of queries that a tenant may
+	// have executing at once.
+	MaxConcurrentQueries int `json:"MaxConcurrentQueries,omitempty"`
+	// MaxQueueSeconds is the amount of time
+	// that a query may wait for one of the
+	// MaxConcurrentQueries to complete before
+	// it is rejected. If MaxQueueSeconds is zero,
+	// queries above the concurrency limit are
+	// rejected immediately.
+	MaxQueueSeconds int `json:"MaxQueueSeconds,omitempty"`
+	// MaxScanBytes is the maximum number
+	// of bytes that a single query may scan,
+	// as determined during query planning.
+	MaxScanBytes int64 `json:"MaxScanBytes,omitempty"`
+	// MaxQueriesPerMinute is the maximum
+	// number of queries that a tenant may
+	// begin executing in any one-minute window.
+	MaxQueriesPerMinute int `json:"MaxQueriesPerMinute,omitempty"`
+}
+
+// LimitedTenant is a db.Tenant
+// that is subject to resource limits.
+//
+// A db.Tenant returned from Provider.Authorize
+// may implement LimitedTenant in order to
+// indicate the limits that apply to it.
+type LimitedTenant interface {
+	db.Tenant
+	// Limits returns the resource
+	// limits for the tenant, or nil
+	// if the tenant is not limited.
+	Limits() *Limits
+}
+
+// TenantLimits returns the Limits associated
+// with t, or nil if t is not a LimitedTenant.
+func TenantLimits(t db.Tenant) *Limits {
+	if lt, ok := t.(LimitedTenant); ok {
+		return lt.Limits()
+	}
+	return nil
+}
+
+// RestrictedTenant is a db.Tenant
+// that may only access some databases.
+type RestrictedTenant interface {
+	db.Tenant
+	// AllowDatabase returns whether or not
+	// the tenant may access the named database.
+	AllowDatabase(name string) bool
+}
+
+// AllowDatabase returns whether t may access
+// the database name. Tenants that do not implement
+// RestrictedTenant may access every database.
+func AllowDatabase(t db.Tenant, name string) bool {
+	if rt, ok := t.(RestrictedTenant); ok {
+		return rt.AllowDatabase(name)
+	}
+	return true
+}
+
+// allowDatabase returns whether name
+// is in the list of databases dbs,
+// where a nil list matches every database
+func allowDatabase(dbs []string, name string) bool {
+	if dbs == nil {
+		return true
+	}
+	for i := range dbs {
+		if dbs[i] == name || dbs[i] == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -25,11 +25,16 @@ import (
 
 	"github.com/SnellerInc/sneller/aws"
 	"github.com/SnellerInc/sneller/aws/s3"
+	"github.com/SnellerInc/sneller/azure"
 	"github.com/SnellerInc/sneller/db"
 	"github.com/SnellerInc/sneller/ion/blockfmt"
 )
 
-var _ Provider = &S3Bearer{}
+var (
+	_ Provider         = &S3Bearer{}
+	_ LimitedTenant    = &s3Tenant{}
+	_ RestrictedTenant = &s3Tenant{}
+)
 
 // S3Bearer is a tenant authorization strategy
 // that produces a db.Tenant from a remote HTTP(s) endpoint
@@ -53,6 +58,19 @@ type S3BearerIdentity struct {
 	// Credentials is a JSON-compatible
 	// representation of the AWS SDK "Credentials" structure
 	Credentials S3BearerCredentials `json:"Credentials"`
+	// Limits, if present, describes
+	// the resource limits of the tenant.
+	Limits *Limits `json:"Limits,omitempty"`
+	// Databases, if non-nil, is the list
+	// of databases that the tenant may access.
+	// The special name "*" matches every database.
+	Databases []string `json:"Databases,omitempty"`
+	// GCS, if present, holds the credentials
+	// used when Bucket is a gs:// URI.
+	GCS *GCSCredentials `json:"GCS,omitempty"`
+	// Azure, if present, holds the credentials
+	// used when Bucket is an az:// URI.
+	Azure *AzureCredentials `json:"Azure,omitempty"`
 }
 
 type S3BearerCredentials struct {
@@ -71,43 +89,135 @@ func (s *S3BearerIdentity) Expired() bool {
 	return s.Credentials.CanExpire && s.Credentials.Expires.Before(time.Now())
 }
 
+// GCSCredentials describes an HMAC key
+// used to access Google Cloud Storage
+// through its S3-compatible XML API.
+type GCSCredentials struct {
+	// BaseURI, if non-empty, overrides
+	// the default endpoint (db.GCSEndpoint).
+	BaseURI         string `json:"BaseURI,omitempty"`
+	AccessKeyID     string `json:"AccessKeyID"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+}
+
+// AzureCredentials describes the credentials
+// used to access an Azure storage account.
+// Exactly one of SharedKey or SAS should be set.
+type AzureCredentials struct {
+	// BaseURI, if non-empty, overrides the default
+	// endpoint (https://<account>.blob.core.windows.net).
+	BaseURI string `json:"BaseURI,omitempty"`
+	Account string `json:"Account"`
+	// SharedKey is the base64-encoded
+	// shared key of the storage account.
+	SharedKey string `json:"SharedKey,omitempty"`
+	// SAS is a shared access signature
+	// for the storage account.
+	SAS string `json:"SAS,omitempty"`
+}
+
+func (a *AzureCredentials) key() (*azure.Key, error) {
+	if a.Account == "" {
+		return nil, fmt.Errorf("AzureCredentials missing Account")
+	}
+	if a.SAS != "" {
+		return &azure.Key{
+			Account: a.Account,
+			BaseURI: a.BaseURI,
+			SAS:     a.SAS,
+		}, nil
+	}
+	if a.SharedKey == "" {
+		return nil, fmt.Errorf("AzureCredentials missing SharedKey or SAS")
+	}
+	return azure.SharedKey(a.BaseURI, a.Account, a.SharedKey)
+}
+
 // Tenant converts the S3BearerIdentity
 // into a db.Tenant. Tenant will perform some
 // validation of the fields in s to confirm
 // that it describes a valid configuration.
+//
+// The scheme of s.Bucket determines which
+// storage service is used for the tenant's
+// root: s3:// uses s.Credentials, gs:// uses s.GCS,
+// and az:// uses s.Azure. If s.Credentials are present
+// for a tenant rooted in GCS or Azure, then the tenant
+// may also ingest objects from S3.
 func (s *S3BearerIdentity) Tenant() (db.Tenant, error) {
 	u, err := url.Parse(s.Bucket)
 	if err != nil {
 		return nil, err
 	}
-	if u.Scheme != "s3" {
-		return nil, fmt.Errorf("bad scheme %q in S3BearerIdentity.Bucket", u.Scheme)
-	}
-	if !s3.ValidBucket(u.Host) {
-		return nil, fmt.Errorf("bucket %q is invalid", s.Bucket)
-	}
 	k := new(blockfmt.Key)
 	if copy(k[:], s.IndexKey) != len(k[:]) {
 		return nil, fmt.Errorf("invalid len(IndexKey)=%d", len(s.IndexKey))
 	}
-	c := &s.Credentials
-	if s.Expired() {
-		return nil, fmt.Errorf("credentials already expired at %s", c.Expires)
-	}
-	if c.AccessKeyID == "" || c.SecretAccessKey == "" || s.Region == "" {
-		return nil, fmt.Errorf("S3BearerIdentity missing proper credentials")
-	}
-	root := &db.S3FS{}
-	root.Bucket = u.Host
-	root.Key = aws.DeriveKey(c.BaseURI, c.AccessKeyID, c.SecretAccessKey, s.Region, "s3")
-	root.Key.Token = c.SessionToken
 	ret := &s3Tenant{
-		id:   s.ID,
-		root: root,
-		ikey: k,
+		id:     s.ID,
+		ikey:   k,
+		limits: s.Limits,
+		dbs:    s.Databases,
+		res:    make(db.SchemeResolver),
+	}
+	c := &s.Credentials
+	var s3key *aws.SigningKey
+	if u.Scheme == "s3" || c.AccessKeyID != "" {
+		if s.Expired() {
+			return nil, fmt.Errorf("credentials already expired at %s", c.Expires)
+		}
+		if c.AccessKeyID == "" || c.SecretAccessKey == "" || s.Region == "" {
+			return nil, fmt.Errorf("S3BearerIdentity missing proper credentials")
+		}
+		s3key = aws.DeriveKey(c.BaseURI, c.AccessKeyID, c.SecretAccessKey, s.Region, "s3")
+		s3key.Token = c.SessionToken
+		ret.res["s3"] = &db.S3Resolver{
+			DeriveKey: func(_ string) (*aws.SigningKey, error) {
+				return s3key, nil
+			},
+		}
 	}
-	ret.DeriveKey = func(_ string) (*aws.SigningKey, error) {
-		return ret.root.Key, nil
+	switch u.Scheme {
+	case "s3":
+		if !s3.ValidBucket(u.Host) {
+			return nil, fmt.Errorf("bucket %q is invalid", s.Bucket)
+		}
+		root := &db.S3FS{}
+		root.Bucket = u.Host
+		root.Key = s3key
+		ret.root = root
+	case "gs":
+		if !s3.ValidBucket(u.Host) {
+			return nil, fmt.Errorf("bucket %q is invalid", s.Bucket)
+		}
+		g := s.GCS
+		if g == nil || g.AccessKeyID == "" || g.SecretAccessKey == "" {
+			return nil, fmt.Errorf("S3BearerIdentity missing GCS credentials")
+		}
+		key := db.GCSKey(g.BaseURI, g.AccessKeyID, g.SecretAccessKey)
+		root := &db.GCSFS{}
+		root.Bucket = u.Host
+		root.Key = key
+		ret.root = root
+		ret.res["gs"] = &db.GCSResolver{Key: key}
+	case "az":
+		if !azure.ValidContainer(u.Host) {
+			return nil, fmt.Errorf("container %q is invalid", s.Bucket)
+		}
+		if s.Azure == nil {
+			return nil, fmt.Errorf("S3BearerIdentity missing Azure credentials")
+		}
+		key, err := s.Azure.key()
+		if err != nil {
+			return nil, err
+		}
+		root := &db.AzureFS{}
+		root.Container = u.Host
+		root.Key = key
+		ret.root = root
+		ret.res["az"] = &db.AzureResolver{Key: key}
+	default:
+		return nil, fmt.Errorf("bad scheme %q in S3BearerIdentity.Bucket", u.Scheme)
 	}
 	return ret, nil
 }
@@ -151,16 +261,31 @@ func (s *S3Bearer) Authorize(ctx context.Context, token string) (db.Tenant, erro
 }
 
 // s3Tenant implements db.Tenant
+//
+// Despite its name, s3Tenant may be rooted
+// in any of the object storage services
+// supported by S3BearerIdentity.
 type s3Tenant struct {
-	db.S3Resolver
-	id   string
-	root *db.S3FS
-	ikey *blockfmt.Key
+	res    db.SchemeResolver
+	id     string
+	root   db.FS
+	ikey   *blockfmt.Key
+	limits *Limits
+	dbs    []string
 }
 
 func (s *s3Tenant) ID() string                { return s.id }
 func (s *s3Tenant) Key() *blockfmt.Key        { return s.ikey }
 func (s *s3Tenant) Root() (db.InputFS, error) { return s.root, nil }
+func (s *s3Tenant) Limits() *Limits           { return s.limits }
+
+func (s *s3Tenant) Split(pattern string) (db.InputFS, string, error) {
+	return s.res.Split(pattern)
+}
+
+func (s *s3Tenant) AllowDatabase(name string) bool {
+	return allowDatabase(s.dbs, name)
+}
 
 // S3Static is a Provider that is backed
 // by a single static S3 identity.
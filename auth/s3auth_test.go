@@ -0,0 +1,85 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/SnellerInc/sneller/db"
+)
+
+func TestIdentitySchemes(t *testing.T) {
+	azkey := base64.StdEncoding.EncodeToString([]byte("secret"))
+
+	gcs := testIdentity()
+	gcs.Bucket = "gs://sneller-test"
+	gcs.Credentials = S3BearerCredentials{}
+	gcs.GCS = &GCSCredentials{AccessKeyID: "GOOG1EXAMPLE", SecretAccessKey: "secret"}
+
+	az := testIdentity()
+	az.Bucket = "az://sneller-test"
+	az.Azure = &AzureCredentials{Account: "account", SharedKey: azkey}
+
+	run := []struct {
+		id     S3BearerIdentity
+		prefix string
+		split  []string
+	}{
+		{testIdentity(), "s3://sneller-test/", []string{"s3://other/x"}},
+		{gcs, "gs://sneller-test/", []string{"gs://other/x"}},
+		// Azure tenant with S3 credentials can ingest from S3
+		{az, "az://sneller-test/", []string{"az://other/x", "s3://bucket/x"}},
+	}
+	for i := range run {
+		tn, err := run[i].id.Tenant()
+		if err != nil {
+			t.Fatalf("%s: %s", run[i].id.Bucket, err)
+		}
+		root, err := tn.Root()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := root.(db.FS); !ok {
+			t.Errorf("%s: root %T is not a db.FS", run[i].id.Bucket, root)
+		}
+		if root.Prefix() != run[i].prefix {
+			t.Errorf("%s: prefix %q", run[i].id.Bucket, root.Prefix())
+		}
+		for _, pat := range run[i].split {
+			if _, _, err := tn.Split(pat); err != nil {
+				t.Errorf("%s: split %s: %s", run[i].id.Bucket, pat, err)
+			}
+		}
+	}
+	// GCS tenants without S3 credentials
+	// cannot reference S3 inputs
+	tn, err := gcs.Tenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tn.Split("s3://bucket/x"); err == nil {
+		t.Error("expected error splitting s3:// pattern")
+	}
+
+	bad := []S3BearerIdentity{gcs, az}
+	bad[0].GCS = nil
+	bad[1].Azure = &AzureCredentials{Account: "account"}
+	for i := range bad {
+		if _, err := bad[i].Tenant(); err == nil {
+			t.Errorf("%s: expected error", bad[i].Bucket)
+		}
+	}
+}
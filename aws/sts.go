@@ -0,0 +1,93 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package aws
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Credentials are temporary security
+// credentials returned from STS.
+type Credentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+// AssumeRole calls the STS AssumeRole API
+// using the signing key k in order to produce
+// temporary credentials for the role with the
+// ARN roleARN. The key k should have been derived
+// for the "sts" service.
+//
+// If k.BaseURI is empty, the regional
+// STS endpoint for k.Region is used.
+// If client is nil, http.DefaultClient is used.
+func AssumeRole(client *http.Client, k *SigningKey, roleARN, session string, duration time.Duration) (*Credentials, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base := k.BaseURI
+	if base == "" {
+		base = "https://sts." + k.Region + ".amazonaws.com"
+	}
+	// note: url.Values.Encode produces
+	// the sorted query string that SignV4 expects
+	v := url.Values{}
+	v.Set("Action", "AssumeRole")
+	v.Set("RoleArn", roleARN)
+	v.Set("RoleSessionName", session)
+	v.Set("Version", "2011-06-15")
+	if duration > 0 {
+		v.Set("DurationSeconds", strconv.Itoa(int(duration/time.Second)))
+	}
+	req, err := http.NewRequest(http.MethodGet, base+"/?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	k.SignV4(req, nil)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		// don't read an arbitrarily large response
+		text := make([]byte, 1024)
+		n, _ := io.ReadFull(res.Body, text)
+		return nil, fmt.Errorf("sts:AssumeRole %s: %s (%q)", roleARN, res.Status, text[:n])
+	}
+	var out struct {
+		Result struct {
+			Credentials Credentials `xml:"Credentials"`
+		} `xml:"AssumeRoleResult"`
+	}
+	err = xml.NewDecoder(res.Body).Decode(&out)
+	if err != nil {
+		return nil, fmt.Errorf("sts:AssumeRole: decoding response: %w", err)
+	}
+	c := &out.Result.Credentials
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return nil, fmt.Errorf("sts:AssumeRole %s: response missing credentials", roleARN)
+	}
+	return c, nil
+}
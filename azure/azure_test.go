@@ -0,0 +1,469 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package azure
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/fsutil"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// fakeBlobs is a minimal in-memory
+// implementation of the Blob service
+type fakeBlobs struct {
+	t      *testing.T
+	key    *Key
+	lock   sync.Mutex
+	blobs  map[string][]byte
+	blocks map[string][]byte
+}
+
+func etag(b []byte) string {
+	sum := md5.Sum(b)
+	return fmt.Sprintf("0x%X", sum[:8])
+}
+
+func (f *fakeBlobs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(r) {
+		w.WriteHeader(403)
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	// path is /<account>/<container>[/<blob>]
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] != f.key.Account {
+		w.WriteHeader(400)
+		return
+	}
+	q := r.URL.Query()
+	if len(parts) == 2 {
+		if r.Method != http.MethodGet || q.Get("comp") != "list" {
+			w.WriteHeader(400)
+			return
+		}
+		f.list(w, q.Get("prefix"), q.Get("delimiter"), q.Get("marker"), q.Get("maxresults"))
+		return
+	}
+	name := parts[1] + "/" + parts[2]
+	body, _ := io.ReadAll(r.Body)
+	switch r.Method {
+	case http.MethodPut:
+		switch q.Get("comp") {
+		case "":
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				w.WriteHeader(400)
+				return
+			}
+			f.blobs[name] = body
+			w.Header().Set("ETag", quoteETag(etag(body)))
+		case "block":
+			f.blocks[name+"#"+q.Get("blockid")] = body
+		case "blocklist":
+			var lst struct {
+				Latest []string `xml:"Latest"`
+			}
+			if err := xml.Unmarshal(body, &lst); err != nil {
+				w.WriteHeader(400)
+				return
+			}
+			var out []byte
+			for _, id := range lst.Latest {
+				b, ok := f.blocks[name+"#"+id]
+				if !ok {
+					w.WriteHeader(400)
+					return
+				}
+				out = append(out, b...)
+			}
+			f.blobs[name] = out
+			w.Header().Set("ETag", quoteETag(etag(out)))
+		}
+		w.WriteHeader(201)
+	case http.MethodDelete:
+		if _, ok := f.blobs[name]; !ok {
+			w.WriteHeader(404)
+			return
+		}
+		delete(f.blobs, name)
+		w.WriteHeader(202)
+	case http.MethodHead, http.MethodGet:
+		b, ok := f.blobs[name]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		tag := quoteETag(etag(b))
+		if m := r.Header.Get("If-Match"); m != "" && m != tag {
+			w.WriteHeader(412)
+			return
+		}
+		w.Header().Set("ETag", tag)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if rng := r.Header.Get("Range"); rng != "" {
+			var start, end int
+			fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			if end >= len(b) {
+				end = len(b) - 1
+			}
+			b = b[start : end+1]
+			w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+			w.WriteHeader(206)
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+			w.WriteHeader(200)
+		}
+		if r.Method == http.MethodGet {
+			w.Write(b)
+		}
+	}
+}
+
+func (f *fakeBlobs) authorized(r *http.Request) bool {
+	if r.Header.Get("x-ms-version") != Version {
+		f.t.Errorf("%s %s: missing x-ms-version", r.Method, r.URL)
+		return false
+	}
+	if sig := r.URL.Query().Get("sig"); sig != "" {
+		// we don't bother validating SAS signatures
+		return true
+	}
+	want := "SharedKey " + f.key.Account + ":" + f.key.mac(f.key.stringToSign(r))
+	if got := r.Header.Get("Authorization"); got != want {
+		f.t.Errorf("%s %s: authorization %q != %q", r.Method, r.URL, got, want)
+		return false
+	}
+	return true
+}
+
+func (f *fakeBlobs) list(w http.ResponseWriter, prefix, delim, marker, max string) {
+	var names []string
+	for name := range f.blobs {
+		// strip container
+		name = name[strings.IndexByte(name, '/')+1:]
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	type xmlblob struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ETag          string `xml:"Etag"`
+			ContentLength int    `xml:"Content-Length"`
+		} `xml:"Properties"`
+	}
+	type xmlprefix struct {
+		Name string `xml:"Name"`
+	}
+	var out struct {
+		XMLName xml.Name `xml:"EnumerationResults"`
+		Blobs   struct {
+			Blob       []xmlblob   `xml:"Blob"`
+			BlobPrefix []xmlprefix `xml:"BlobPrefix"`
+		} `xml:"Blobs"`
+		NextMarker string `xml:"NextMarker"`
+	}
+	n, _ := strconv.Atoi(max)
+	if n <= 0 {
+		// use a small page size so that
+		// pagination is always exercised
+		n = 2
+	}
+	count := 0
+	last := ""
+	for _, name := range names {
+		if name <= marker {
+			continue
+		}
+		if count == n {
+			out.NextMarker = last
+			break
+		}
+		if delim != "" {
+			if i := strings.Index(name[len(prefix):], delim); i >= 0 {
+				dir := name[:len(prefix)+i+1]
+				if dir != last {
+					out.Blobs.BlobPrefix = append(out.Blobs.BlobPrefix, xmlprefix{Name: dir})
+					count++
+				}
+				// skip past everything in this prefix
+				last = dir + "\xff"
+				marker = last
+				continue
+			}
+		}
+		var b xmlblob
+		b.Name = name
+		body := f.blobs["test/"+name]
+		b.Properties.ETag = etag(body)
+		b.Properties.ContentLength = len(body)
+		b.Properties.LastModified = time.Now().UTC().Format(time.RFC1123)
+		out.Blobs.Blob = append(out.Blobs.Blob, b)
+		count++
+		last = name
+	}
+	buf, _ := xml.Marshal(&out)
+	w.WriteHeader(200)
+	w.Write(buf)
+}
+
+func testContainer(t *testing.T) *ContainerFS {
+	secret := base64.StdEncoding.EncodeToString([]byte("not a very secret key"))
+	k, err := SharedKey("", "testaccount", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeBlobs{
+		t:      t,
+		key:    k,
+		blobs:  make(map[string][]byte),
+		blocks: make(map[string][]byte),
+	}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	k.BaseURI = srv.URL + "/" + k.Account
+	return &ContainerFS{
+		Key:       k,
+		Container: "test",
+		Client:    srv.Client(),
+	}
+}
+
+func TestContainerFS(t *testing.T) {
+	c := testContainer(t)
+	files := map[string]string{
+		"a/b/x.json":   "x",
+		"a/b/y.json":   "yy",
+		"a/c/z.json":   "zzz",
+		"a/top.json":   "top",
+		"b/other.json": "other",
+	}
+	for name, body := range files {
+		etag, err := c.Put(name, []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if etag == "" {
+			t.Fatal("no etag")
+		}
+	}
+	for name, body := range files {
+		buf, err := fs.ReadFile(c, name)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if string(buf) != body {
+			t.Fatalf("%s: got %q", name, buf)
+		}
+	}
+	_, err := c.Open("a/nope.json")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	ents, err := fs.ReadDir(c, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for i := range ents {
+		names = append(names, ents[i].Name())
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "b,c,top.json" {
+		t.Fatalf("unexpected entries %v", names)
+	}
+	var walked []string
+	err = fs.WalkDir(c, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			walked = append(walked, p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walked) != len(files) {
+		t.Fatalf("walked %v", walked)
+	}
+	var globbed []string
+	err = fsutil.WalkGlob(c, "a/b/x.json", "a/*/*.json", func(p string, f fs.File, err error) error {
+		if err != nil {
+			return err
+		}
+		f.Close()
+		globbed = append(globbed, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(globbed, ",") != "a/b/y.json,a/c/z.json" {
+		t.Fatalf("globbed %v", globbed)
+	}
+	err = c.Remove("a/top.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fs.Stat(c, "a/top.json")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("after remove: %v", err)
+	}
+}
+
+func TestUploader(t *testing.T) {
+	c := testContainer(t)
+	up := &Uploader{
+		Key:       c.Key,
+		Client:    c.Client,
+		Container: c.Container,
+		Blob:      "big/object",
+	}
+	if err := up.Start(); err != nil {
+		t.Fatal(err)
+	}
+	part := bytes.Repeat([]byte("x"), MinPartSize)
+	// upload out-of-order
+	if err := up.Upload(2, part); err != nil {
+		t.Fatal(err)
+	}
+	first := bytes.Repeat([]byte("y"), MinPartSize)
+	if err := up.Upload(1, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := up.Upload(3, []byte("short")); err == nil {
+		t.Fatal("expected error for short part")
+	}
+	if err := up.Close([]byte("tail")); err != nil {
+		t.Fatal(err)
+	}
+	want := append(append(append([]byte{}, first...), part...), "tail"...)
+	if up.Size() != int64(len(want)) {
+		t.Fatalf("size %d != %d", up.Size(), len(want))
+	}
+	f, err := c.Open("big/object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	file := f.(*File)
+	if file.ETag != up.ETag() {
+		t.Fatalf("etag %s != %s", file.ETag, up.ETag())
+	}
+	got := make([]byte, 10)
+	_, err = file.ReadAt(got, int64(MinPartSize)-5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "yyyyyxxxxx" {
+		t.Fatalf("ReadAt got %q", got)
+	}
+}
+
+func TestEncodeKey(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte("secret"))
+	k, err := SharedKey("http://127.0.0.1:10000/acct", "acct", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st ion.Symtab
+	var buf ion.Buffer
+	if k.Encode(&st, &buf) == nil {
+		t.Fatal("encoded a shared key")
+	}
+	ck, err := k.ContainerKey("test", "rl", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ck.Encode(&st, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte(secret)) {
+		t.Fatal("encoded key contains secret")
+	}
+	out, err := DecodeKey(&st, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Account != ck.Account || out.BaseURI != ck.BaseURI || out.SAS != ck.SAS {
+		t.Fatalf("%+v != %+v", out, ck)
+	}
+}
+
+// TestAzurite runs against a real Blob service
+// (for example, the Azurite emulator) when
+// AZURE_TEST_ENDPOINT, AZURE_TEST_ACCOUNT,
+// AZURE_TEST_KEY, and AZURE_TEST_CONTAINER are set
+// and the container already exists.
+func TestAzurite(t *testing.T) {
+	endpoint := os.Getenv("AZURE_TEST_ENDPOINT")
+	account := os.Getenv("AZURE_TEST_ACCOUNT")
+	secret := os.Getenv("AZURE_TEST_KEY")
+	container := os.Getenv("AZURE_TEST_CONTAINER")
+	if account == "" || secret == "" || container == "" {
+		t.Skip("AZURE_TEST_* not set")
+	}
+	k, err := SharedKey(endpoint, account, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ContainerFS{Key: k, Container: container}
+	name := fmt.Sprintf("sneller-test/%d", time.Now().UnixNano())
+	_, err = c.Put(name, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Remove(name)
+	buf, err := fs.ReadFile(c, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("got %q", buf)
+	}
+	u, err := URL(k, container, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("GET with SAS: %s", res.Status)
+	}
+}
@@ -0,0 +1,400 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package azure
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ContainerFS implements fs.FS,
+// fs.ReadDirFS, and fs.SubFS
+// for the blobs in a container.
+type ContainerFS struct {
+	Key       *Key
+	Container string
+	Client    *http.Client
+}
+
+func (c *ContainerFS) client() *http.Client {
+	if c.Client == nil {
+		return &DefaultClient
+	}
+	return c.Client
+}
+
+func badpath(op, name string) error {
+	return &fs.PathError{
+		Op:   op,
+		Path: name,
+		Err:  fs.ErrInvalid,
+	}
+}
+
+// Put performs a Put Blob operation at the blob 'where'
+// and returns the ETag of the newly-created blob.
+func (c *ContainerFS) Put(where string, contents []byte) (string, error) {
+	where = path.Clean(where)
+	if !fs.ValidPath(where) || where == "." {
+		return "", badpath("azure PUT", where)
+	}
+	if !ValidContainer(c.Container) {
+		return "", badContainer(c.Container)
+	}
+	req, err := http.NewRequest(http.MethodPut, uri(c.Key, c.Container, where), bytes.NewReader(contents))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	c.Key.Sign(req)
+	res, err := flakyDo(c.client(), req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 201 {
+		return "", fmt.Errorf("azure PUT: %s %s", res.Status, extractMessage(res.Body))
+	}
+	return res.Header.Get("ETag"), nil
+}
+
+// Remove removes the blob at fullpath.
+func (c *ContainerFS) Remove(fullpath string) error {
+	fullpath = path.Clean(fullpath)
+	if !fs.ValidPath(fullpath) || fullpath == "." {
+		return badpath("azure DELETE", fullpath)
+	}
+	if !ValidContainer(c.Container) {
+		return badContainer(c.Container)
+	}
+	req, err := http.NewRequest(http.MethodDelete, uri(c.Key, c.Container, fullpath), nil)
+	if err != nil {
+		return err
+	}
+	c.Key.Sign(req)
+	res, err := flakyDo(c.client(), req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return &fs.PathError{Op: "remove", Path: fullpath, Err: fs.ErrNotExist}
+	}
+	if res.StatusCode != 202 {
+		return fmt.Errorf("azure DELETE: %s %s", res.Status, extractMessage(res.Body))
+	}
+	return nil
+}
+
+// Sub implements fs.SubFS.Sub.
+func (c *ContainerFS) Sub(dir string) (fs.FS, error) {
+	dir = path.Clean(dir)
+	if !fs.ValidPath(dir) {
+		return nil, badpath("sub", dir)
+	}
+	if dir == "." {
+		return c, nil
+	}
+	return fs.Sub(c, dir)
+}
+
+// Open implements fs.FS.Open
+//
+// The returned fs.File will be either a *File
+// or a *Prefix depending on whether name refers
+// to a blob or a common path prefix that
+// leads to multiple blobs.
+// If name does not refer to a blob or a path prefix,
+// then Open returns an error matching fs.ErrNotExist.
+func (c *ContainerFS) Open(name string) (fs.File, error) {
+	isDir := strings.HasSuffix(name, "/")
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return nil, badpath("open", name)
+	}
+	if name == "." {
+		return &Prefix{fs: c, Path: "."}, nil
+	}
+	if !isDir {
+		rd, err := Stat(c.Key, c.client(), c.Container, name)
+		if err == nil {
+			return &File{Reader: rd}, nil
+		}
+	}
+	p := &Prefix{fs: c, Path: name + "/"}
+	lst, _, err := c.list(p.Path, "/", "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(lst) == 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return p, nil
+}
+
+// ReadDir implements fs.ReadDirFS
+func (c *ContainerFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return nil, badpath("readdir", name)
+	}
+	p := &Prefix{fs: c, Path: "."}
+	if name != "." {
+		p.Path = name + "/"
+	}
+	return p.ReadDir(-1)
+}
+
+// listing is the response to a List Blobs operation
+type listing struct {
+	Blobs struct {
+		Blob []struct {
+			Name       string `xml:"Name"`
+			Properties struct {
+				LastModified  string `xml:"Last-Modified"`
+				ETag          string `xml:"Etag"`
+				ContentLength int64  `xml:"Content-Length"`
+			} `xml:"Properties"`
+		} `xml:"Blob"`
+		BlobPrefix []struct {
+			Name string `xml:"Name"`
+		} `xml:"BlobPrefix"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// list performs one List Blobs operation and returns
+// the entries in the listing along with the marker
+// for the next page of results, if any
+func (c *ContainerFS) list(prefix, delim, marker string, max int) ([]fs.DirEntry, string, error) {
+	if !ValidContainer(c.Container) {
+		return nil, "", badContainer(c.Container)
+	}
+	q := url.Values{}
+	q.Set("restype", "container")
+	q.Set("comp", "list")
+	if prefix != "" && prefix != "." {
+		q.Set("prefix", prefix)
+	}
+	if delim != "" {
+		q.Set("delimiter", delim)
+	}
+	if marker != "" {
+		q.Set("marker", marker)
+	}
+	if max > 0 {
+		q.Set("maxresults", fmt.Sprint(max))
+	}
+	req, err := http.NewRequest(http.MethodGet, uri(c.Key, c.Container, "")+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("creating http request: %w", err)
+	}
+	c.Key.Sign(req)
+	res, err := flakyDo(c.client(), req)
+	if err != nil {
+		return nil, "", fmt.Errorf("executing request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, "", fmt.Errorf("azure list blobs: %s %s", res.Status, extractMessage(res.Body))
+	}
+	var ret listing
+	err = xml.NewDecoder(res.Body).Decode(&ret)
+	if err != nil {
+		return nil, "", fmt.Errorf("xml decoding response: %w", err)
+	}
+	out := make([]fs.DirEntry, 0, len(ret.Blobs.Blob)+len(ret.Blobs.BlobPrefix))
+	for i := range ret.Blobs.Blob {
+		b := &ret.Blobs.Blob[i]
+		lm, _ := time.Parse(time.RFC1123, b.Properties.LastModified)
+		out = append(out, &File{
+			Reader: &Reader{
+				Key:          c.Key,
+				Client:       c.client(),
+				ETag:         quoteETag(b.Properties.ETag),
+				LastModified: lm,
+				size:         b.Properties.ContentLength,
+				container:    c.Container,
+				blob:         b.Name,
+			},
+		})
+	}
+	for i := range ret.Blobs.BlobPrefix {
+		out = append(out, &Prefix{
+			fs:   c,
+			Path: ret.Blobs.BlobPrefix[i].Name,
+		})
+	}
+	return out, ret.NextMarker, nil
+}
+
+// Prefix implements fs.File, fs.ReadDirFile,
+// fs.DirEntry, and fs.FileInfo for a
+// pseudo-directory of blobs.
+type Prefix struct {
+	fs *ContainerFS
+	// Path is the path of this prefix,
+	// including a trailing forward slash,
+	// or "." for the root of the container.
+	Path string
+
+	// listing state for ReadDir
+	marker string
+	done   bool
+	buf    []fs.DirEntry
+}
+
+// Name implements fs.DirEntry.Name
+func (p *Prefix) Name() string { return path.Base(p.Path) }
+
+// Type implements fs.DirEntry.Type
+func (p *Prefix) Type() fs.FileMode { return fs.ModeDir }
+
+// Info implements fs.DirEntry.Info
+func (p *Prefix) Info() (fs.FileInfo, error) { return p, nil }
+
+// IsDir implements fs.FileInfo.IsDir
+func (p *Prefix) IsDir() bool { return true }
+
+// ModTime implements fs.FileInfo.ModTime
+//
+// Prefixes don't have a meaningful modification time,
+// so ModTime returns the zero time.Time.
+func (p *Prefix) ModTime() time.Time { return time.Time{} }
+
+// Mode implements fs.FileInfo.Mode
+func (p *Prefix) Mode() fs.FileMode { return fs.ModeDir | 0755 }
+
+// Sys implements fs.FileInfo.Sys
+func (p *Prefix) Sys() interface{} { return nil }
+
+// Size implements fs.FileInfo.Size
+func (p *Prefix) Size() int64 { return 0 }
+
+// Stat implements fs.File.Stat
+func (p *Prefix) Stat() (fs.FileInfo, error) { return p, nil }
+
+// Read implements fs.File.Read.
+//
+// Read always returns an error.
+func (p *Prefix) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: p.Path, Err: fs.ErrInvalid}
+}
+
+// Close implements fs.File.Close
+func (p *Prefix) Close() error { return nil }
+
+// ReadDir implements fs.ReadDirFile
+//
+// Every returned fs.DirEntry will be either
+// a *Prefix or a *File.
+func (p *Prefix) ReadDir(n int) ([]fs.DirEntry, error) {
+	for !p.done && (n <= 0 || len(p.buf) < n) {
+		lst, next, err := p.fs.list(p.Path, "/", p.marker, 0)
+		if err != nil {
+			return nil, err
+		}
+		p.buf = append(p.buf, lst...)
+		p.marker = next
+		p.done = next == ""
+	}
+	if n <= 0 || n >= len(p.buf) {
+		out := p.buf
+		p.buf = nil
+		if n > 0 && len(out) == 0 {
+			return nil, io.EOF
+		}
+		return out, nil
+	}
+	out := p.buf[:n:n]
+	p.buf = p.buf[n:]
+	return out, nil
+}
+
+// File implements fs.File
+type File struct {
+	// Reader is a reader that points to
+	// the associated blob.
+	*Reader
+	body io.ReadCloser // populated lazily
+}
+
+// Name implements fs.FileInfo.Name
+func (f *File) Name() string { return path.Base(f.Reader.blob) }
+
+// Path returns the full path to the
+// blob within its container.
+// See also blockfmt.NamedFile
+func (f *File) Path() string { return f.Reader.blob }
+
+// Mode implements fs.FileInfo.Mode
+func (f *File) Mode() fs.FileMode { return 0644 }
+
+// Read implements fs.File.Read
+//
+// Note: Read is not safe to call from
+// multiple goroutines simultaneously.
+// Use ReadAt for parallel reads.
+func (f *File) Read(p []byte) (int, error) {
+	if f.Reader.Size() == 0 {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		var err error
+		f.body, err = f.Reader.RangeReader(0, f.Reader.Size())
+		if err != nil {
+			return 0, err
+		}
+	}
+	return f.body.Read(p)
+}
+
+// Info implements fs.DirEntry.Info
+func (f *File) Info() (fs.FileInfo, error) { return f, nil }
+
+// Type implements fs.DirEntry.Type
+func (f *File) Type() fs.FileMode { return f.Mode() }
+
+// Close implements fs.File.Close
+func (f *File) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
+
+// IsDir implements fs.FileInfo.IsDir.
+// IsDir always returns false.
+func (f *File) IsDir() bool { return false }
+
+// ModTime implements fs.FileInfo.ModTime.
+// This returns the same value as f.Reader.LastModified.
+func (f *File) ModTime() time.Time { return f.Reader.LastModified }
+
+// Sys implements fs.FileInfo.Sys.
+func (f *File) Sys() interface{} { return nil }
+
+// Stat implements fs.File.Stat
+func (f *File) Stat() (fs.FileInfo, error) { return f, nil }
@@ -0,0 +1,113 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package azure
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/SnellerInc/sneller/fsutil"
+)
+
+var (
+	_ fsutil.WalkGlobFS = &ContainerFS{}
+)
+
+// split a glob pattern on the first meta-character
+// so that we can list from the most specific prefix
+func splitMeta(pattern string) (string, string) {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '\\', '[':
+			return pattern[:i], pattern[i:]
+		default:
+		}
+	}
+	return pattern, ""
+}
+
+// WalkGlob implements fsutil.WalkGlobFS
+//
+// Globbing is accelerated by listing
+// all the blobs that begin with the
+// leading non-meta-character characters
+// of pattern, followed by filtering each
+// of the listed blobs by pattern.
+//
+// The List Blobs operation has no equivalent
+// of the S3 start-after parameter, so blobs
+// that sort before seek are listed and skipped.
+func (c *ContainerFS) WalkGlob(seek, pattern string, walk fsutil.WalkGlobFn) error {
+	if !ValidContainer(c.Container) {
+		return badContainer(c.Container)
+	}
+	// check pattern is sane
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	before, after := splitMeta(pattern)
+	if after == "" {
+		// no meta-characters; we are
+		// just opening a file
+		rd, err := Stat(c.Key, c.client(), c.Container, before)
+		if err != nil {
+			return nil
+		}
+		if seek != "" && before <= seek {
+			return nil
+		}
+		return walk(before, &File{Reader: rd}, nil)
+	}
+	if seek == "." {
+		seek = ""
+	}
+	// see equivalent check in fsutil.WalkGlob
+	if seek != "" && (seek < before || !strings.HasPrefix(seek, before)) {
+		return fmt.Errorf("seek %q not compatible with prefix %q", seek, before)
+	}
+	marker := ""
+	for {
+		lst, next, err := c.list(before, "", marker, 0)
+		if err != nil {
+			return err
+		}
+		for i := range lst {
+			f, ok := lst[i].(*File)
+			if !ok {
+				continue
+			}
+			name := f.Path()
+			if seek != "" && name <= seek {
+				continue
+			}
+			match, err := path.Match(pattern, name)
+			if err != nil {
+				return err
+			}
+			if !match {
+				continue
+			}
+			err = walk(name, f, nil)
+			if err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		marker = next
+	}
+}
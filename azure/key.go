@@ -0,0 +1,291 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package azure implements a lightweight
+// client of the Azure Blob Storage API.
+//
+// The ContainerFS type can be used to view
+// the blobs in a container as an fs.FS.
+package azure
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// Version is the version of the
+// Blob service REST API used for requests.
+const Version = "2020-10-02"
+
+// Key is used to authorize requests
+// to a storage account using either the
+// shared key of the account or a shared
+// access signature (SAS).
+type Key struct {
+	// Account is the name of the storage account.
+	Account string
+	// BaseURI, if non-empty, is the endpoint
+	// of the Blob service, including the account
+	// name if the service uses path-style addressing
+	// (for example, http://127.0.0.1:10000/devstoreaccount1
+	// for a local emulator). If BaseURI is empty,
+	// then https://<account>.blob.core.windows.net is used.
+	BaseURI string
+	// SAS, if non-empty, is a shared access
+	// signature (as a URL query string) that
+	// is used to authorize requests instead
+	// of the shared key.
+	SAS string
+
+	secret []byte
+}
+
+// SharedKey returns a Key that authorizes
+// requests using the base64-encoded shared key
+// of the storage account.
+func SharedKey(baseURI, account, key string) (*Key, error) {
+	secret, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("azure: decoding shared key: %w", err)
+	}
+	return &Key{
+		Account: account,
+		BaseURI: baseURI,
+		secret:  secret,
+	}, nil
+}
+
+// Endpoint returns the base URI
+// of the Blob service for the account.
+func (k *Key) Endpoint() string {
+	if k.BaseURI != "" {
+		return strings.TrimSuffix(k.BaseURI, "/")
+	}
+	return "https://" + k.Account + ".blob.core.windows.net"
+}
+
+func (k *Key) mac(str string) string {
+	h := hmac.New(sha256.New, k.secret)
+	h.Write([]byte(str))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// canonical writes the canonicalized headers
+// and resource of req into dst
+func (k *Key) canonical(dst *strings.Builder, req *http.Request) {
+	var hdrs []string
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-ms-") {
+			hdrs = append(hdrs, lower)
+		}
+	}
+	sort.Strings(hdrs)
+	for _, h := range hdrs {
+		dst.WriteString(h)
+		dst.WriteByte(':')
+		dst.WriteString(strings.TrimSpace(req.Header.Get(h)))
+		dst.WriteByte('\n')
+	}
+	dst.WriteByte('/')
+	dst.WriteString(k.Account)
+	dst.WriteString(req.URL.EscapedPath())
+	query := make(url.Values)
+	for name, vals := range req.URL.Query() {
+		lower := strings.ToLower(name)
+		query[lower] = append(query[lower], vals...)
+	}
+	params := make([]string, 0, len(query))
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, p := range params {
+		vals := query[p]
+		sort.Strings(vals)
+		dst.WriteByte('\n')
+		dst.WriteString(p)
+		dst.WriteByte(':')
+		dst.WriteString(strings.Join(vals, ","))
+	}
+}
+
+// stringToSign returns the string that
+// is signed to authorize req with a shared key
+func (k *Key) stringToSign(req *http.Request) string {
+	var dst strings.Builder
+	dst.WriteString(req.Method)
+	dst.WriteByte('\n')
+	length := ""
+	if req.ContentLength > 0 {
+		length = fmt.Sprint(req.ContentLength)
+	}
+	for _, h := range []string{
+		"Content-Encoding",
+		"Content-Language",
+		"Content-Length",
+		"Content-MD5",
+		"Content-Type",
+		"Date",
+		"If-Modified-Since",
+		"If-Match",
+		"If-None-Match",
+		"If-Unmodified-Since",
+		"Range",
+	} {
+		if h == "Content-Length" {
+			dst.WriteString(length)
+		} else {
+			dst.WriteString(req.Header.Get(h))
+		}
+		dst.WriteByte('\n')
+	}
+	k.canonical(&dst, req)
+	return dst.String()
+}
+
+// Sign authorizes req.
+// Sign must be called after all of
+// the other headers of req have been set.
+func (k *Key) Sign(req *http.Request) {
+	req.Header.Set("x-ms-version", Version)
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	if k.SAS != "" {
+		sas := strings.TrimPrefix(k.SAS, "?")
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = sas
+		} else {
+			req.URL.RawQuery += "&" + sas
+		}
+		return
+	}
+	req.Header.Set("Authorization", "SharedKey "+k.Account+":"+k.mac(k.stringToSign(req)))
+}
+
+const sasTime = "2006-01-02T15:04:05Z"
+
+// sas produces a service SAS for resource
+// (either a container or a blob within a container)
+func (k *Key) sas(container, blob, perms string, validfor time.Duration) (string, error) {
+	if k.SAS != "" {
+		return strings.TrimPrefix(k.SAS, "?"), nil
+	}
+	if k.secret == nil {
+		return "", fmt.Errorf("azure: key for %s has no secret", k.Account)
+	}
+	resource := "/blob/" + k.Account + "/" + container
+	sr := "c"
+	if blob != "" {
+		resource += "/" + blob
+		sr = "b"
+	}
+	expiry := time.Now().Add(validfor).UTC().Format(sasTime)
+	fields := []string{
+		perms,    // signedPermissions
+		"",       // signedStart
+		expiry,   // signedExpiry
+		resource, // canonicalizedResource
+		"",       // signedIdentifier
+		"",       // signedIP
+		"",       // signedProtocol
+		Version,  // signedVersion
+		sr,       // signedResource
+		"",       // signedSnapshotTime
+		"",       // rscc
+		"",       // rscd
+		"",       // rsce
+		"",       // rscl
+		"",       // rsct
+	}
+	v := url.Values{}
+	v.Set("sv", Version)
+	v.Set("sr", sr)
+	v.Set("sp", perms)
+	v.Set("se", expiry)
+	v.Set("sig", k.mac(strings.Join(fields, "\n")))
+	return v.Encode(), nil
+}
+
+// ContainerKey returns a Key that may only
+// be used to access the given container,
+// with the given permissions (for example "rwdlc"),
+// for the given duration.
+// If k already uses a SAS, then ContainerKey returns k.
+func (k *Key) ContainerKey(container, perms string, validfor time.Duration) (*Key, error) {
+	if k.SAS != "" {
+		return k, nil
+	}
+	sas, err := k.sas(container, "", perms, validfor)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		Account: k.Account,
+		BaseURI: k.BaseURI,
+		SAS:     sas,
+	}, nil
+}
+
+// Encode encodes the key into dst.
+// Keys with a shared key secret cannot be
+// encoded; use ContainerKey to produce a
+// key that can be encoded.
+func (k *Key) Encode(st *ion.Symtab, dst *ion.Buffer) error {
+	if k.SAS == "" {
+		return fmt.Errorf("azure: cannot encode shared key for %s", k.Account)
+	}
+	dst.BeginStruct(-1)
+	dst.BeginField(st.Intern("account"))
+	dst.WriteString(k.Account)
+	dst.BeginField(st.Intern("base_uri"))
+	dst.WriteString(k.BaseURI)
+	dst.BeginField(st.Intern("sas"))
+	dst.WriteString(k.SAS)
+	dst.EndStruct()
+	return nil
+}
+
+// DecodeKey decodes a Key encoded
+// using (*Key).Encode.
+func DecodeKey(st *ion.Symtab, buf []byte) (*Key, error) {
+	k := &Key{}
+	_, err := ion.UnpackStruct(st, buf, func(field string, buf []byte) error {
+		var err error
+		switch field {
+		case "account":
+			k.Account, _, err = ion.ReadString(buf)
+		case "base_uri":
+			k.BaseURI, _, err = ion.ReadString(buf)
+		case "sas":
+			k.SAS, _, err = ion.ReadString(buf)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if k.Account == "" || k.SAS == "" {
+		return nil, fmt.Errorf("azure: incomplete key")
+	}
+	return k, nil
+}
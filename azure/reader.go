@@ -0,0 +1,265 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package azure
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultClient is the default HTTP client
+// used for requests made from this package.
+var DefaultClient = http.Client{
+	Transport: &http.Transport{
+		ResponseHeaderTimeout: 5 * time.Second,
+	},
+}
+
+var ErrInvalidContainer = errors.New("invalid container name")
+
+func badContainer(name string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidContainer, name)
+}
+
+// ValidContainer returns whether or not
+// container is a valid container name.
+//
+// See https://learn.microsoft.com/en-us/rest/api/storageservices/naming-and-referencing-containers--blobs--and-metadata
+func ValidContainer(container string) bool {
+	if len(container) < 3 || len(container) > 63 {
+		return false
+	}
+	if container[0] == '-' || strings.Contains(container, "--") {
+		return false
+	}
+	for i := 0; i < len(container); i++ {
+		c := container[i]
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// escape path-escapes a blob name
+// while leaving the / separators intact
+func escape(s string) string {
+	full := url.PathEscape(s)
+	return strings.Replace(full, "%2F", "/", -1)
+}
+
+// uri produces the URI of a blob,
+// or of the container if blob is empty
+func uri(k *Key, container, blob string) string {
+	u := k.Endpoint() + "/" + container
+	if blob != "" {
+		u += "/" + escape(blob)
+	}
+	return u
+}
+
+// URL returns a URL for a blob that
+// can be used directly with http.Get
+// for the given duration.
+func URL(k *Key, container, blob string) (string, error) {
+	if !ValidContainer(container) {
+		return "", badContainer(container)
+	}
+	sas, err := k.sas(container, blob, "r", time.Hour)
+	if err != nil {
+		return "", err
+	}
+	return uri(k, container, blob) + "?" + sas, nil
+}
+
+// quoteETag produces the quoted form of an ETag,
+// since blob listings produce unquoted ETags but
+// response headers contain quoted ETags
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, "\"") {
+		return etag
+	}
+	return "\"" + etag + "\""
+}
+
+// extractMessage tries to extract the <Message/>
+// field of an XML response to improve error messages
+func extractMessage(r io.Reader) string {
+	rt := struct {
+		Message string `xml:"Message"`
+	}{}
+	if xml.NewDecoder(r).Decode(&rt) == nil {
+		return rt.Message
+	}
+	return "(no message)"
+}
+
+func flakyDo(cl *http.Client, req *http.Request) (*http.Response, error) {
+	hasBody := req.Body != nil
+	res, err := cl.Do(req)
+	if err == nil && (res.StatusCode != 500 && res.StatusCode != 503) {
+		return res, err
+	}
+	if hasBody && req.GetBody == nil {
+		// can't re-do this request because
+		// we can't rewind the Body reader
+		return res, err
+	}
+	if res != nil {
+		res.Body.Close()
+	}
+	if hasBody {
+		req.Body, err = req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("req.GetBody: %w", err)
+		}
+	}
+	return cl.Do(req)
+}
+
+// Reader presents a read-only view of a blob
+type Reader struct {
+	// Key is the key that Reader
+	// uses to authorize HTTP requests.
+	Key *Key
+
+	// Client is the HTTP client used to
+	// make HTTP requests.
+	Client *http.Client
+
+	// ETag is the ETag of the blob
+	// as returned by listing or a HEAD operation.
+	ETag string
+	// LastModified is the blob's LastModified time
+	// as returned by listing or a HEAD operation.
+	LastModified time.Time
+
+	size int64
+
+	container, blob string
+}
+
+// Size returns the size of the blob in bytes.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// Name returns the name of the blob
+func (r *Reader) Name() string {
+	return r.blob
+}
+
+// Container returns the container of the blob
+func (r *Reader) Container() string {
+	return r.container
+}
+
+func (r *Reader) client() *http.Client {
+	if r.Client == nil {
+		return &DefaultClient
+	}
+	return r.Client
+}
+
+// Stat performs a HEAD on a blob
+// and returns an associated Reader.
+func Stat(k *Key, cl *http.Client, container, blob string) (*Reader, error) {
+	if !ValidContainer(container) {
+		return nil, badContainer(container)
+	}
+	if cl == nil {
+		cl = &DefaultClient
+	}
+	req, err := http.NewRequest(http.MethodHead, uri(k, container, blob), nil)
+	if err != nil {
+		return nil, err
+	}
+	k.Sign(req)
+	res, err := flakyDo(cl, req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, &fs.PathError{
+			Op:   "open",
+			Path: "az://" + container + "/" + blob,
+			Err:  fs.ErrNotExist,
+		}
+	}
+	if res.StatusCode != 200 {
+		// HEAD responses don't have a body
+		return nil, fmt.Errorf("azure.Stat: HEAD returned %s", res.Status)
+	}
+	if res.ContentLength < 0 {
+		return nil, fmt.Errorf("azure.Stat: content length %d invalid", res.ContentLength)
+	}
+	lm, _ := time.Parse(time.RFC1123, res.Header.Get("Last-Modified"))
+	return &Reader{
+		Key:          k,
+		Client:       cl,
+		ETag:         res.Header.Get("ETag"),
+		LastModified: lm,
+		size:         res.ContentLength,
+		container:    container,
+		blob:         blob,
+	}, nil
+}
+
+// RangeReader produces an io.ReadCloser that reads
+// bytes in the range from [off, off+width)
+//
+// It is the caller's responsibility to call Close()
+// on the returned io.ReadCloser.
+func (r *Reader) RangeReader(off, width int64) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, uri(r.Key, r.container, r.blob), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+width-1))
+	if r.ETag != "" {
+		req.Header.Set("If-Match", r.ETag)
+	}
+	r.Key.Sign(req)
+	res, err := flakyDo(r.client(), req)
+	if err != nil {
+		return nil, err
+	}
+	// if we ask for the whole blob we may get a 200
+	// instead of a 206; that's fine
+	if res.StatusCode != 206 && res.StatusCode != 200 {
+		defer res.Body.Close()
+		return nil, fmt.Errorf("azure.Reader.RangeReader: status %s %q", res.Status, extractMessage(res.Body))
+	}
+	return res.Body, nil
+}
+
+// ReadAt implements io.ReaderAt
+func (r *Reader) ReadAt(dst []byte, off int64) (int, error) {
+	rd, err := r.RangeReader(off, int64(len(dst)))
+	if err != nil {
+		return 0, err
+	}
+	defer rd.Close()
+	return io.ReadFull(rd, dst)
+}
@@ -0,0 +1,244 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package azure
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// MinPartSize is the minimum size for
+// all of the parts of an upload except
+// for the final part.
+//
+// (Azure itself permits smaller blocks,
+// but we use the same minimum as S3 so that
+// callers produce similarly-sized parts.)
+const MinPartSize = 5 * 1024 * 1024
+
+// Uploader wraps the state of a block blob
+// that is uploaded in parts.
+//
+// Each part is uploaded with a Put Block operation,
+// and Close commits the list of blocks
+// with a Put Block List operation.
+// Uncommitted blocks are discarded by the
+// service automatically, so an upload that
+// is never closed does not leave a blob behind.
+type Uploader struct {
+	// Key is the key used to sign requests.
+	// It cannot be nil.
+	Key *Key
+	// Client is the http client used to
+	// make requests. If it is nil, then
+	// DefaultClient will be used.
+	Client *http.Client
+
+	// ContentType, if not an empty string,
+	// will be the Content-Type of the new blob.
+	ContentType string
+
+	Container, Blob string
+
+	finalETag string
+	finished  bool
+
+	lock   sync.Mutex
+	blocks []block
+}
+
+type block struct {
+	num  int64
+	size int64
+}
+
+// blockID returns the block ID for a part number;
+// all of the block IDs in a blob must have
+// the same length before they are base64-encoded
+func blockID(num int64) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%010d", num)))
+}
+
+// MinPartSize returns the minimum part size
+// for the Uploader.
+//
+// (The return value of MinPartSize is always azure.MinPartSize.)
+func (u *Uploader) MinPartSize() int { return MinPartSize }
+
+func (u *Uploader) client() *http.Client {
+	if u.Client == nil {
+		return &DefaultClient
+	}
+	return u.Client
+}
+
+// Start validates the Uploader.
+// Unlike S3 multi-part uploads, there is
+// no state to create before uploading blocks.
+func (u *Uploader) Start() error {
+	if !ValidContainer(u.Container) {
+		return badContainer(u.Container)
+	}
+	if u.Blob == "" {
+		return fmt.Errorf("azure.Uploader.Blob must be present")
+	}
+	return nil
+}
+
+// Upload uploads the part number num.
+// Every part except for the final part
+// must be at least MinPartSize bytes.
+//
+// It is safe to call Upload from multiple goroutines
+// simultaneously. However, calls to Upload must
+// occur strictly before a call to Close.
+func (u *Uploader) Upload(num int64, contents []byte) error {
+	if len(contents) < MinPartSize {
+		return fmt.Errorf("azure.Uploader.Upload: size %d below min part size %d", len(contents), MinPartSize)
+	}
+	return u.upload(num, contents)
+}
+
+func (u *Uploader) upload(num int64, contents []byte) error {
+	req, err := http.NewRequest(http.MethodPut,
+		uri(u.Key, u.Container, u.Blob)+"?comp=block&blockid="+url.QueryEscape(blockID(num)),
+		bytes.NewReader(contents))
+	if err != nil {
+		return err
+	}
+	u.Key.Sign(req)
+	res, err := flakyDo(u.client(), req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 201 {
+		return fmt.Errorf("azure.Uploader.Upload: %s %q", res.Status, extractMessage(res.Body))
+	}
+	u.lock.Lock()
+	u.blocks = append(u.blocks, block{num: num, size: int64(len(contents))})
+	u.lock.Unlock()
+	return nil
+}
+
+func (u *Uploader) maxpart() int64 {
+	max := int64(0)
+	for i := range u.blocks {
+		if u.blocks[i].num > max {
+			max = u.blocks[i].num
+		}
+	}
+	return max
+}
+
+// Size returns the final size of the blob.
+// The return value of Size is only valid
+// after Close has been called.
+func (u *Uploader) Size() int64 {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if !u.finished {
+		return 0
+	}
+	out := int64(0)
+	for i := range u.blocks {
+		out += u.blocks[i].size
+	}
+	return out
+}
+
+// Close uploads the final part of the blob
+// (if final is non-empty) and commits the
+// blob from its constituent blocks.
+//
+// Close will panic if it has already
+// been called and returned successfully.
+func (u *Uploader) Close(final []byte) error {
+	if u.finished {
+		panic("multiple calls to azure.Uploader.Close")
+	}
+	if len(final) > 0 {
+		err := u.upload(u.maxpart()+1, final)
+		if err != nil {
+			return err
+		}
+	}
+	sort.Slice(u.blocks, func(i, j int) bool {
+		return u.blocks[i].num < u.blocks[j].num
+	})
+	ids := make([]string, len(u.blocks))
+	for i := range u.blocks {
+		ids[i] = blockID(u.blocks[i].num)
+	}
+	buf, err := xml.Marshal(&struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{Latest: ids})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut,
+		uri(u.Key, u.Container, u.Blob)+"?comp=blocklist", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	if u.ContentType != "" {
+		req.Header.Set("x-ms-blob-content-type", u.ContentType)
+	}
+	u.Key.Sign(req)
+	res, err := flakyDo(u.client(), req)
+	if err != nil {
+		return fmt.Errorf("azure.Uploader.Close: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 201 {
+		return fmt.Errorf("azure.Uploader.Close: %s %q", res.Status, extractMessage(res.Body))
+	}
+	u.finalETag = res.Header.Get("ETag")
+	u.finished = true
+	return nil
+}
+
+// ETag returns the ETag of the final blob.
+// The return value of ETag is only valid after
+// Close has been called.
+func (u *Uploader) ETag() string { return u.finalETag }
+
+// Closed returns whether or not Close
+// has been called on u.
+func (u *Uploader) Closed() bool { return u.finished }
+
+// Abort abandons the upload.
+//
+// Uncommitted blocks are garbage-collected
+// by the service, so Abort only resets the
+// state of the Uploader so that the upload
+// can be re-tried.
+func (u *Uploader) Abort() error {
+	if u.finished {
+		return nil
+	}
+	u.lock.Lock()
+	u.blocks = nil
+	u.lock.Unlock()
+	return nil
+}
@@ -0,0 +1,205 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/SnellerInc/sneller/db"
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/expr/partiql"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+	"github.com/SnellerInc/sneller/vm"
+)
+
+// deleteFilter implements db.RowFilter
+// by evaluating a WHERE clause with the vm
+type deleteFilter struct {
+	match blockfmt.Filter
+	keep  expr.Node // rows to keep
+
+	// output of the filter; Filter
+	// is not called concurrently
+	out chunks
+}
+
+// chunks is an io.Writer that keeps
+// the ion chunks written to it
+type chunks struct {
+	buf  []byte
+	ends []int // end of each chunk in buf
+}
+
+func (c *chunks) Write(p []byte) (int, error) {
+	c.buf = append(c.buf, p...)
+	c.ends = append(c.ends, len(c.buf))
+	return len(p), nil
+}
+
+func (c *chunks) reset() {
+	c.buf = c.buf[:0]
+	c.ends = c.ends[:0]
+}
+
+// copy writes each chunk to dst
+// with a separate call to Write
+func (c *chunks) copy(dst io.Writer) error {
+	start := 0
+	for _, end := range c.ends {
+		if _, err := dst.Write(c.buf[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+func parsePredicate(text string) (expr.Node, error) {
+	q, err := partiql.Parse([]byte("SELECT * FROM x WHERE " + text))
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := q.Body.(*expr.Select)
+	if !ok || sel.Where == nil {
+		return nil, fmt.Errorf("unexpected predicate %q", text)
+	}
+	if err := expr.Check(sel.Where); err != nil {
+		return nil, err
+	}
+	return sel.Where, nil
+}
+
+func newDeleteFilter(pred expr.Node) (*deleteFilter, error) {
+	df := &deleteFilter{
+		keep: &expr.IsKey{Expr: pred, Key: expr.IsNotTrue},
+	}
+	// compile the filter once up front so that
+	// a predicate that cannot be compiled is
+	// rejected before any objects are rewritten
+	if _, err := vm.NewFilter(df.keep, &vm.Count{}); err != nil {
+		return nil, fmt.Errorf("compiling predicate: %w", err)
+	}
+	if f, ok := blockfmt.CompileFilter(pred); ok {
+		df.match = f
+	}
+	return df, nil
+}
+
+func (d *deleteFilter) Match(s *blockfmt.SparseIndex, n int) blockfmt.Ternary {
+	if d.match == nil {
+		return blockfmt.Maybe
+	}
+	return d.match(s, n)
+}
+
+// rows returns the number of rows in
+// one or more ion chunks
+func rows(buf []byte) (int, error) {
+	var st ion.Symtab
+	n := 0
+	for len(buf) > 0 {
+		if ion.IsBVM(buf) || ion.TypeOf(buf) == ion.AnnotationType {
+			var err error
+			buf, err = st.Unmarshal(buf)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		size := ion.SizeOf(buf)
+		if size <= 0 || size > len(buf) {
+			return 0, fmt.Errorf("rows: invalid ion value")
+		}
+		if ion.TypeOf(buf) == ion.StructType {
+			n++
+		}
+		buf = buf[size:]
+	}
+	return n, nil
+}
+
+func write(dst vm.QuerySink, src []byte) error {
+	w, err := dst.Open()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	err2 := w.Close()
+	if err == nil {
+		err = err2
+	}
+	err2 = dst.Close()
+	if err == nil {
+		err = err2
+	}
+	return err
+}
+
+func (d *deleteFilter) Filter(dst io.Writer, src []byte) (kept, removed int, err error) {
+	total, err := rows(src)
+	if err != nil {
+		return 0, 0, err
+	}
+	d.out.reset()
+	f, err := vm.NewFilter(d.keep, vm.LockedSink(&d.out))
+	if err != nil {
+		return 0, 0, err
+	}
+	err = write(f, src)
+	if err != nil {
+		return 0, 0, err
+	}
+	kept, err = rows(d.out.buf)
+	if err != nil {
+		return 0, 0, err
+	}
+	removed = total - kept
+	switch {
+	case removed == 0:
+		_, err = dst.Write(src)
+	case kept > 0:
+		err = d.out.copy(dst)
+	}
+	return kept, removed, err
+}
+
+// entry point for 'sdb delete ...'
+func deleteRows(creds db.Tenant, dbname, table, predicate string) {
+	pred, err := parsePredicate(predicate)
+	if err != nil {
+		exitf("parsing predicate: %s\n", err)
+	}
+	b := db.Builder{
+		Align:         1024 * 1024,
+		RangeMultiple: 100,
+		GCMinimumAge:  5 * time.Minute,
+		MaxSnapshots:  dashn,
+	}
+	if dashv {
+		b.Logf = logf
+	}
+	df, err := newDeleteFilter(pred)
+	if err != nil {
+		exitf("%s\n", err)
+	}
+	n, err := b.Delete(creds, dbname, table, df)
+	if err != nil {
+		exitf("delete: %s\n", err)
+	}
+	fmt.Printf("%d rows deleted\n", n)
+}
@@ -0,0 +1,212 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/SnellerInc/sneller/db"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+type testTenant struct {
+	root *db.DirFS
+	key  *blockfmt.Key
+}
+
+func (t *testTenant) ID() string                { return "test" }
+func (t *testTenant) Key() *blockfmt.Key        { return t.key }
+func (t *testTenant) Root() (db.InputFS, error) { return t.root, nil }
+func (t *testTenant) Split(pattern string) (db.InputFS, string, error) {
+	return t.root, pattern, nil
+}
+
+// tableIDs returns the id field
+// of each row in the table
+func tableIDs(t *testing.T, dfs *db.DirFS, key *blockfmt.Key) []int64 {
+	idx, err := db.OpenIndex(dfs, "default", "rows", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	descs, err := idx.Indirect.Search(dfs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	descs = append(descs, idx.Inline...)
+	var ids []int64
+	for i := range descs {
+		f, err := dfs.Open(descs[i].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		var d blockfmt.Decoder
+		d.Set(descs[i].Trailer, len(descs[i].Trailer.Blocks))
+		_, err = d.Copy(&buf, io.LimitReader(f, descs[i].Trailer.Offset))
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var st ion.Symtab
+		src := buf.Bytes()
+		for len(src) > 0 {
+			if ion.TypeOf(src) != ion.StructType && !ion.IsBVM(src) && ion.TypeOf(src) != ion.AnnotationType {
+				src = src[ion.SizeOf(src):]
+				continue
+			}
+			var d ion.Datum
+			d, src, err = ion.ReadDatum(&st, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, ok := d.(*ion.Struct)
+			if !ok {
+				continue
+			}
+			switch id := s.FieldByName("id").Value.(type) {
+			case ion.Int:
+				ids = append(ids, int64(id))
+			case ion.Uint:
+				ids = append(ids, int64(id))
+			default:
+				t.Fatalf("unexpected id %v", id)
+			}
+		}
+	}
+	return ids
+}
+
+func TestDeleteFilter(t *testing.T) {
+	tmpdir := t.TempDir()
+	dfs := db.NewDirFS(tmpdir)
+	defer dfs.Close()
+	tenant := &testTenant{root: dfs, key: new(blockfmt.Key)}
+	rand.Read(tenant.key[:])
+
+	var buf bytes.Buffer
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&buf, "{\"id\": %d, \"name\": \"row-%d\"}\n", i, i)
+	}
+	err := os.WriteFile(filepath.Join(tmpdir, "input.json"), buf.Bytes(), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json := blockfmt.SuffixToFormat[".json"]
+	lst, err := blockfmt.CollectGlob(dfs, func(string) blockfmt.RowFormat { return json() }, "input.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := db.Builder{Align: 1024, RangeMultiple: 1, Logf: t.Logf}
+	err = b.Append(tenant, "default", "rows", lst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the sparse index cannot decide this
+	// predicate, so every row is evaluated
+	pred, err := parsePredicate("id % 3 = 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	df, err := newDeleteFilter(pred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if df.match != nil {
+		t.Fatal("expected the predicate to be undecidable from the sparse index")
+	}
+	n, err := b.Delete(tenant, "default", "rows", df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 333 {
+		t.Errorf("deleted %d rows; expected 333", n)
+	}
+	ids := tableIDs(t, dfs, tenant.key)
+	if len(ids) != 1000-333 {
+		t.Errorf("%d rows remain; expected %d", len(ids), 1000-333)
+	}
+	for _, id := range ids {
+		if id%3 == 0 {
+			t.Errorf("row %d was not deleted", id)
+		}
+	}
+
+	// rows that are not TRUE are kept,
+	// and nothing is rewritten when
+	// no rows match
+	before := tableFiles(t, tmpdir)
+	pred, err = parsePredicate("missing_field = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	df, err = newDeleteFilter(pred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = b.Delete(tenant, "default", "rows", df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("deleted %d rows; expected none", n)
+	}
+	if got := len(tableIDs(t, dfs, tenant.key)); got != len(ids) {
+		t.Errorf("%d rows remain; expected %d", got, len(ids))
+	}
+	if after := tableFiles(t, tmpdir); !reflect.DeepEqual(before, after) {
+		t.Errorf("table files changed from %v to %v", before, after)
+	}
+}
+
+// tableFiles returns the names of
+// the files in the table directory
+func tableFiles(t *testing.T, root string) []string {
+	ents, err := os.ReadDir(filepath.Join(root, "db", "default", "rows"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for i := range ents {
+		out = append(out, ents[i].Name())
+	}
+	return out
+}
+
+func TestDeletePredicate(t *testing.T) {
+	for _, text := range []string{
+		"id +",
+		"'abc' + 1 = 2",
+		"SUM(id) > 1",
+	} {
+		pred, err := parsePredicate(text)
+		if err == nil {
+			_, err = newDeleteFilter(pred)
+		}
+		if err == nil {
+			t.Errorf("predicate %q: expected an error", text)
+		} else {
+			t.Logf("predicate %q: %s", text, err)
+		}
+	}
+}
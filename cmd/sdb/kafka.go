@@ -0,0 +1,65 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/SnellerInc/sneller/db"
+)
+
+// entry point for 'sdb kafka ...'
+func kafka(creds db.Tenant, proxy, group, topic string) {
+	c, err := db.NewKafkaRESTConsumer(nil, proxy, group, topic)
+	if err != nil {
+		exitf("kafka: %s\n", err)
+	}
+	// closing the consumer causes the
+	// runner to return once the batch
+	// in progress has been finalized
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		<-sigs
+		signal.Stop(sigs)
+		c.Close()
+	}()
+	r := &db.QueueRunner{
+		Owner: creds,
+		Conf: db.Builder{
+			Align:         1024 * 1024,
+			RangeMultiple: 100,
+			GCMinimumAge:  5 * time.Minute,
+			MaxSnapshots:  dashn,
+		},
+		Logf:          logf,
+		BatchInterval: 5 * time.Second,
+		IOErrDelay:    time.Second,
+	}
+	if dashv {
+		r.Conf.Logf = logf
+	}
+	q := &db.KafkaQueue{
+		Consumer: c,
+		Logf:     logf,
+	}
+	err = r.Run(q)
+	c.Close()
+	if err != nil {
+		exitf("kafka: %s\n", err)
+	}
+}
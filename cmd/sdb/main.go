@@ -20,6 +20,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
@@ -29,7 +30,10 @@ import (
 	"github.com/SnellerInc/sneller/auth"
 	"github.com/SnellerInc/sneller/aws"
 	"github.com/SnellerInc/sneller/aws/s3"
+	"github.com/SnellerInc/sneller/date"
 	"github.com/SnellerInc/sneller/db"
+	"github.com/SnellerInc/sneller/expr/partiql"
+	"github.com/SnellerInc/sneller/ion"
 	"github.com/SnellerInc/sneller/ion/blockfmt"
 )
 
@@ -38,8 +42,10 @@ var (
 	dashh        bool
 	dashf        bool
 	dashm        int64
+	dashn        int
 	dashk        string
 	dasho        string
+	dashcompact  bool
 	token        string
 	authEndPoint string
 )
@@ -54,8 +60,10 @@ func init() {
 	flag.BoolVar(&dashh, "h", false, "show usage help")
 	flag.BoolVar(&dashf, "f", false, "force rebuild")
 	flag.Int64Var(&dashm, "m", 100*giga, "maximum input bytes read per index update")
+	flag.IntVar(&dashn, "n", 0, "number of index snapshots to retain per table")
 	flag.StringVar(&dashk, "k", "", "key file to use for signing+authenticating indexes")
 	flag.StringVar(&dasho, "o", "-", "output file (or - for stdin) for unpack")
+	flag.BoolVar(&dashcompact, "compact", false, "compact tables after sync")
 	flag.StringVar(&token, "token", "", "JWT token or custom bearer token (default: fetch from SNELLER_TOKEN environment variable)")
 	flag.StringVar(&authEndPoint, "a", "", "authorization specification (file://, http://, https://, empty uses environment)")
 }
@@ -108,6 +116,24 @@ func create(creds db.Tenant, dbname, defpath string) {
 	}
 }
 
+func createView(creds db.Tenant, dbname, text string) {
+	ofs := outfs(creds)
+	v, err := partiql.ParseView([]byte(text))
+	if err != nil {
+		exitf("parsing view: %s\n", err)
+	}
+	if dashv {
+		logf("creating view %q in db %q", v.Name, dbname)
+	}
+	err = db.WriteView(ofs, dbname, &db.ViewDef{
+		Name:       v.Name,
+		Definition: text,
+	})
+	if err != nil {
+		exitf("writing view: %s\n", err)
+	}
+}
+
 func gc(creds db.Tenant, dbname, tblpat string) {
 	ofs := root(creds)
 	rmfs, ok := ofs.(db.RemoveFS)
@@ -161,6 +187,10 @@ func sync(dbname, tblpat string) {
 			Force:         dashf,
 			MaxScanBytes:  dashm,
 			GCMinimumAge:  5 * time.Minute,
+			MaxSnapshots:  dashn,
+		}
+		if dashcompact {
+			b.CompactLikelihood = 100
 		}
 		if dashv {
 			b.Logf = logf
@@ -175,6 +205,60 @@ func sync(dbname, tblpat string) {
 	}
 }
 
+// entry point for 'sdb compact ...'
+func compact(creds db.Tenant, dbname, table string) {
+	b := db.Builder{
+		Align:         1024 * 1024,
+		RangeMultiple: 100,
+		GCMinimumAge:  5 * time.Minute,
+		MaxSnapshots:  dashn,
+	}
+	if dashv {
+		b.Logf = logf
+	}
+	err := b.Compact(creds, dbname, table)
+	if err != nil {
+		exitf("compact: %s\n", err)
+	}
+}
+
+// entry point for 'sdb snapshots ...'
+func snapshots(creds db.Tenant, dbname, table string) {
+	ofs := root(creds)
+	lst, err := db.Snapshots(ofs, dbname, table)
+	if err != nil {
+		exitf("listing snapshots: %s\n", err)
+	}
+	for i := range lst {
+		idx, err := db.OpenPartialSnapshot(ofs, dbname, table, creds.Key(), lst[i].Created)
+		if err != nil {
+			exitf("opening snapshot %s: %s\n", lst[i].Path, err)
+		}
+		fmt.Printf("%s %d objects %s\n", lst[i].Created.Time().Format(time.RFC3339Nano), idx.Objects(), lst[i].Path)
+	}
+}
+
+// entry point for 'sdb rollback ...'
+func rollback(creds db.Tenant, dbname, table, when string) {
+	t, ok := date.Parse([]byte(when))
+	if !ok {
+		exitf("cannot parse timestamp %q\n", when)
+	}
+	b := db.Builder{
+		Align:         1024 * 1024,
+		RangeMultiple: 100,
+		GCMinimumAge:  5 * time.Minute,
+		MaxSnapshots:  dashn,
+	}
+	if dashv {
+		b.Logf = logf
+	}
+	err := b.Rollback(creds, dbname, table, t)
+	if err != nil {
+		exitf("rollback: %s\n", err)
+	}
+}
+
 var hsizes = []byte{'K', 'M', 'G', 'T', 'P'}
 
 func human(size int64) string {
@@ -199,6 +283,23 @@ func describeTrailer(t *blockfmt.Trailer, compsize int64) {
 	fmt.Printf("\tfields: %v\n", t.Sparse.FieldNames())
 }
 
+func describeSchema(s *blockfmt.Schema) {
+	fmt.Printf("schema: %d rows, %d fields\n", s.Rows, len(s.Fields))
+	if s.Truncated {
+		fmt.Printf("\t(truncated after %d fields)\n", blockfmt.MaxSchemaFields)
+	}
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		var types []string
+		for t := range f.Types {
+			if f.Types[t] != 0 {
+				types = append(types, fmt.Sprintf("%s:%d", ion.Type(t), f.Types[t]))
+			}
+		}
+		fmt.Printf("\t%s %s\n", strings.Join(f.Path, "."), strings.Join(types, " "))
+	}
+}
+
 func describe(creds db.Tenant, dbname, table string) {
 	ofs := root(creds)
 	idx, err := db.OpenIndex(ofs, dbname, table, creds.Key())
@@ -227,6 +328,12 @@ func describe(creds db.Tenant, dbname, table string) {
 	fmt.Printf("total blocks:       %d\n", blocks)
 	fmt.Printf("total compressed:   %s\n", human(totalComp))
 	fmt.Printf("total decompressed: %s (%.2fx)\n", human(totalDecomp), float64(totalDecomp)/float64(totalComp))
+	sc, err := db.OpenSchema(ofs, dbname, table)
+	if err == nil {
+		describeSchema(sc)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		exitf("opening schema: %s\n", err)
+	}
 }
 
 func fetch(creds db.Tenant, files ...string) {
@@ -448,6 +555,29 @@ document with the following structure:
 			return true
 		},
 	},
+	{
+		name: "create-view",
+		help: "<db> <statement>",
+		desc: `create a new view
+The command
+  $ sdb create-view <db> "CREATE VIEW <name> AS SELECT ..."
+uploads the view definition to
+the tenant root file system at
+  /db/<db>/<name>/view.json
+
+Queries may reference the view in place of a table;
+each reference is replaced with the query that
+defines the view. Unqualified tables in the query
+refer to tables in <db>.
+`,
+		run: func(args []string) bool {
+			if len(args) != 3 {
+				return false
+			}
+			createView(creds(), args[1], args[2])
+			return true
+		},
+	},
 	{
 		name: "sync",
 		help: "<db> <table-pattern?>",
@@ -469,6 +599,30 @@ in the associated definition.json files (see also "create")
 			return true
 		},
 	},
+	{
+		name: "kafka",
+		help: "<proxy-url> <group> <topic>",
+		desc: `ingest records from a Kafka topic
+The command
+  $ sdb kafka http://localhost:8082 sneller events
+joins the consumer group <group> through the Kafka REST
+proxy at <proxy-url> and continuously ingests the records
+of <topic> into every table with an input pattern like
+  kafka://<topic>/*/*
+(see "create") until it is interrupted.
+Consumer offsets are only committed once the
+records have been written to the table index.
+Record values are newline-separated when the input
+format is "json" and concatenated when it is "ion".
+`,
+		run: func(args []string) bool {
+			if len(args) != 4 {
+				return false
+			}
+			kafka(creds(), args[1], args[2], args[3])
+			return true
+		},
+	},
 	{
 		name: "gc",
 		help: "<db> <table-pattern?>",
@@ -493,6 +647,101 @@ and it was created more than 15 minutes ago.
 			return true
 		},
 	},
+	{
+		name: "compact",
+		help: "<db> <table>",
+		desc: `compact the packed-*.ion.zst files in a table
+The command
+  $ sdb compact <db> <table>
+merges time-adjacent packed-*.ion.zst files of
+similar size in the given table into larger files
+with rows sorted by the primary timestamp of the table.
+Files smaller than the minimum merge size are merged
+together, and groups of larger files are merged
+into files of the next size tier.
+
+(Compaction also runs after "sync" when
+the -compact flag is given.)
+`,
+		run: func(args []string) bool {
+			if len(args) != 3 {
+				return false
+			}
+			compact(creds(), args[1], args[2])
+			return true
+		},
+	},
+	{
+		name: "delete",
+		help: "<db> <table> <predicate>",
+		desc: `delete the rows matching a predicate from a table
+The command
+  $ sdb delete <db> <table> "user_id = 'abc123'"
+rewrites the packed-*.ion.zst files in the given table
+that may contain rows for which <predicate> is TRUE
+(using the same syntax as a SQL WHERE clause)
+and replaces them in the index with copies that
+do not contain those rows. The old files are
+removed by garbage collection after a grace period.
+
+NOTE: the input files from which the table was built
+are not modified, so deleted rows will re-appear if
+the table is rebuilt from its inputs (see sync -f).
+`,
+		run: func(args []string) bool {
+			if len(args) != 4 {
+				return false
+			}
+			deleteRows(creds(), args[1], args[2], args[3])
+			return true
+		},
+	},
+	{
+		name: "snapshots",
+		help: "<db> <table>",
+		desc: `list the retained snapshots of a table index
+The command
+  $ sdb snapshots <db> <table>
+lists the creation time, number of objects, and path
+of each retained snapshot of the index of the given
+table from oldest to newest.
+
+Snapshots are only written when the -n <count> flag
+is given to "sync", "compact", "delete", or "rollback",
+in which case the <count> most recent versions of
+the index are retained. Files referenced by retained
+snapshots are never removed by garbage collection.
+Snapshots can be queried with
+  SELECT ... FROM <db>.<table> AT TIMESTAMP '<time>'
+`,
+		run: func(args []string) bool {
+			if len(args) != 3 {
+				return false
+			}
+			snapshots(creds(), args[1], args[2])
+			return true
+		},
+	},
+	{
+		name: "rollback",
+		help: "<db> <table> <timestamp>",
+		desc: `restore a table to a retained snapshot
+The command
+  $ sdb rollback <db> <table> 2022-10-01T00:00:00Z
+replaces the index of the given table with the most
+recent retained snapshot created at or before the given
+time (see "snapshots"). The restored index is written
+as a new version of the index, so the rollback itself
+can be undone by rolling back to a later time.
+`,
+		run: func(args []string) bool {
+			if len(args) != 4 {
+				return false
+			}
+			rollback(creds(), args[1], args[2], args[3])
+			return true
+		},
+	},
 	{
 		name: "describe",
 		help: "<db> <table>",
@@ -501,7 +750,10 @@ The command
   $ sdb describe <db> <table>
 will output a textual description
 of the index file associated with
-the given database+table.
+the given database+table, including
+the field paths that occur in the table
+and the number of values of each type
+at each path.
 `,
 		run: func(args []string) bool {
 			if len(args) != 3 {
@@ -32,7 +32,6 @@ import (
 	"github.com/SnellerInc/sneller/ion"
 	"github.com/SnellerInc/sneller/plan"
 	"github.com/SnellerInc/sneller/vm"
-	"golang.org/x/sys/cpu"
 )
 
 var (
@@ -185,9 +184,8 @@ func do(arg string) {
 		return
 	}
 
-	if !cpu.X86.HasAVX512 {
-		fmt.Fprintln(os.Stderr, "CPU doesn't support AVX-512")
-		os.Exit(1)
+	if vm.Portable() {
+		fmt.Fprintln(os.Stderr, "warning: CPU doesn't support AVX-512; using the (slow, partial) portable interpreter")
 	}
 
 	var stat plan.ExecStats
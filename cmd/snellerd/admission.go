@@ -0,0 +1,144 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SnellerInc/sneller/auth"
+)
+
+// admissionError is returned from
+// admission.acquire when a query
+// is rejected due to a tenant limit
+type admissionError struct {
+	msg string
+	// retry is the suggested interval
+	// after which the query may be retried
+	retry time.Duration
+}
+
+func (a *admissionError) Error() string { return a.msg }
+
+// admission performs admission control
+// for queries based on per-tenant limits
+// (see auth.Limits)
+type admission struct {
+	lock    sync.Mutex
+	tenants map[string]*usage
+}
+
+type usage struct {
+	running int
+	// recent is the list of start times
+	// of queries within the last minute
+	recent []time.Time
+	// changed is closed and replaced
+	// each time a query completes
+	changed chan struct{}
+}
+
+// expire drops start times older than a minute
+func (u *usage) expire(now time.Time) {
+	i := 0
+	for i < len(u.recent) && now.Sub(u.recent[i]) >= time.Minute {
+		i++
+	}
+	u.recent = u.recent[:copy(u.recent, u.recent[i:])]
+}
+
+func (a *admission) get(id string) *usage {
+	if a.tenants == nil {
+		a.tenants = make(map[string]*usage)
+	}
+	u := a.tenants[id]
+	if u == nil {
+		u = &usage{changed: make(chan struct{})}
+		a.tenants[id] = u
+	}
+	return u
+}
+
+// acquire blocks until the tenant with the given id
+// is permitted to run another query under lim,
+// or returns an error if the query should be rejected.
+// If acquire returns a nil error, the caller must call
+// the returned function once the query has completed.
+func (a *admission) acquire(ctx context.Context, id string, lim *auth.Limits) (func(), error) {
+	if lim == nil {
+		return func() {}, nil
+	}
+	var timeout <-chan time.Time
+	if lim.MaxQueueSeconds > 0 {
+		t := time.NewTimer(time.Duration(lim.MaxQueueSeconds) * time.Second)
+		defer t.Stop()
+		timeout = t.C
+	}
+	a.lock.Lock()
+	u := a.get(id)
+	for lim.MaxConcurrentQueries > 0 && u.running >= lim.MaxConcurrentQueries {
+		if timeout == nil {
+			a.lock.Unlock()
+			return nil, &admissionError{
+				msg:   fmt.Sprintf("too many concurrent queries (limit %d)", lim.MaxConcurrentQueries),
+				retry: time.Second,
+			}
+		}
+		wait := u.changed
+		a.lock.Unlock()
+		select {
+		case <-wait:
+		case <-timeout:
+			return nil, &admissionError{
+				msg:   fmt.Sprintf("timed out waiting for one of %d concurrent queries to complete", lim.MaxConcurrentQueries),
+				retry: time.Second,
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		a.lock.Lock()
+		u = a.get(id)
+	}
+	now := time.Now()
+	u.expire(now)
+	if lim.MaxQueriesPerMinute > 0 && len(u.recent) >= lim.MaxQueriesPerMinute {
+		retry := time.Minute - now.Sub(u.recent[0])
+		a.lock.Unlock()
+		return nil, &admissionError{
+			msg:   fmt.Sprintf("too many queries (limit %d per minute)", lim.MaxQueriesPerMinute),
+			retry: retry,
+		}
+	}
+	u.running++
+	u.recent = append(u.recent, now)
+	a.lock.Unlock()
+	return func() { a.release(id) }, nil
+}
+
+func (a *admission) release(id string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	u := a.tenants[id]
+	u.running--
+	close(u.changed)
+	u.changed = make(chan struct{})
+	u.expire(time.Now())
+	if u.running == 0 && len(u.recent) == 0 {
+		delete(a.tenants, id)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/auth"
+)
+
+func TestAdmission(t *testing.T) {
+	var a admission
+	ctx := context.Background()
+	isRejected := func(err error) bool {
+		var ae *admissionError
+		return errors.As(err, &ae)
+	}
+
+	// no limits: always admitted
+	done, err := a.acquire(ctx, "x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done()
+
+	lim := &auth.Limits{MaxConcurrentQueries: 2}
+	d0, err := a.acquire(ctx, "x", lim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d1, err := a.acquire(ctx, "x", lim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = a.acquire(ctx, "x", lim)
+	if !isRejected(err) {
+		t.Fatalf("expected rejection; got %v", err)
+	}
+	// other tenants are unaffected
+	d2, err := a.acquire(ctx, "y", lim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2()
+
+	// queued queries are admitted
+	// once a slot is released
+	lim.MaxQueueSeconds = 10
+	admitted := make(chan error, 1)
+	go func() {
+		done, err := a.acquire(ctx, "x", lim)
+		if err == nil {
+			done()
+		}
+		admitted <- err
+	}()
+	select {
+	case err := <-admitted:
+		t.Fatalf("admitted early (err=%v)", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	d0()
+	if err := <-admitted; err != nil {
+		t.Fatal(err)
+	}
+	d1()
+
+	// rate limiting
+	lim = &auth.Limits{MaxQueriesPerMinute: 3}
+	for i := 0; i < 3; i++ {
+		done, err := a.acquire(ctx, "z", lim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done()
+	}
+	_, err = a.acquire(ctx, "z", lim)
+	if !isRejected(err) {
+		t.Fatalf("expected rejection; got %v", err)
+	}
+	if r := err.(*admissionError).retry; r <= 0 || r > time.Minute {
+		t.Errorf("unexpected retry interval %s", r)
+	}
+}
@@ -132,12 +132,24 @@ func (r *requester) getTables(db string) *http.Request {
 	return req
 }
 
+func (r *requester) getFunctions(db string) *http.Request {
+	req := r.get(fmt.Sprintf("/functions?database=%s", url.QueryEscape(db)))
+	req.Header.Set("Authorization", "Bearer snellerd-test")
+	return req
+}
+
 func (r *requester) getInputs(db, table string) *http.Request {
 	req := r.get(fmt.Sprintf("/inputs?database=%s&table=%s", url.QueryEscape(db), url.QueryEscape(table)))
 	req.Header.Set("Authorization", "Bearer snellerd-test")
 	return req
 }
 
+func (r *requester) getSchema(db, table string) *http.Request {
+	req := r.get(fmt.Sprintf("/schema?database=%s&table=%s", url.QueryEscape(db), url.QueryEscape(table)))
+	req.Header.Set("Authorization", "Bearer snellerd-test")
+	return req
+}
+
 type testAuth struct {
 	self db.Tenant
 }
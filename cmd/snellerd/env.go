@@ -20,6 +20,7 @@ import (
 	"github.com/SnellerInc/sneller/expr"
 	"github.com/SnellerInc/sneller/expr/blob"
 	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
 	"github.com/SnellerInc/sneller/plan"
 	"github.com/SnellerInc/sneller/vm"
 )
@@ -85,6 +86,6 @@ type filterHandle struct {
 	filter expr.Node
 	blobs  *blob.List
 
-	// cached result of compileFilter(filter)
-	compiled filter
+	// cached result of blockfmt.CompileFilter(filter)
+	compiled blockfmt.Filter
 }
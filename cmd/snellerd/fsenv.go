@@ -15,12 +15,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"hash"
 	"io"
+	"io/fs"
 	"path"
+	"strings"
 	"time"
 
+	"github.com/SnellerInc/sneller/auth"
 	"github.com/SnellerInc/sneller/date"
 	"github.com/SnellerInc/sneller/db"
 	"github.com/SnellerInc/sneller/expr"
@@ -32,6 +36,7 @@ import (
 
 type savedIndex struct {
 	db, table string
+	at        date.Time // for TABLE_AT
 	index     *blockfmt.Index
 }
 
@@ -48,6 +53,16 @@ type fsEnv struct {
 
 	recent []savedIndex
 	lists  []savedList
+	// functions are the user-defined functions
+	// by database and upper-case name; loaded lazily
+	functions map[string]map[string]*expr.Function
+	// views are the views that have been
+	// resolved by db/view name; nil if the
+	// name does not refer to a view
+	views map[string]*expr.View
+	// handles are the table handles
+	// returned from Stat
+	handles []*filterHandle
 
 	// FIXME: change cachedEnv and don't
 	// keep the accumulated state here:
@@ -95,6 +110,24 @@ func (f *fsEnv) CacheValues() ([]byte, time.Time) {
 	return f.hash.Sum(nil), f.modtime.Time()
 }
 
+// Tables implements cachedEnv.Tables
+func (f *fsEnv) Tables() []string {
+	out := make([]string, len(f.recent))
+	for i := range f.recent {
+		out[i] = f.recent[i].db + "." + f.recent[i].table
+	}
+	return out
+}
+
+// allow returns an error wrapping fs.ErrPermission
+// if the tenant may not access the database dbname
+func (f *fsEnv) allow(dbname string) error {
+	if !auth.AllowDatabase(f.tenant, dbname) {
+		return fmt.Errorf("database %q: %w", dbname, fs.ErrPermission)
+	}
+	return nil
+}
+
 var _ plan.Indexer = (*fsEnv)(nil)
 
 func (f *fsEnv) Index(p expr.Node) (plan.Index, error) {
@@ -103,7 +136,17 @@ func (f *fsEnv) Index(p expr.Node) (plan.Index, error) {
 
 func (f *fsEnv) index(e expr.Node) (*blockfmt.Index, error) {
 	var dbname, table string
+	var at date.Time
 	var err error
+	// TABLE_AT(path, ts) references a snapshot
+	if b, ok := e.(*expr.Builtin); ok && b.Func == expr.TableAt && len(b.Args) == 2 {
+		ts, ok := b.Args[1].(*expr.Timestamp)
+		if !ok {
+			return nil, syntax("unexpected snapshot time %q", expr.ToString(b.Args[1]))
+		}
+		at = ts.Value
+		e = b.Args[0]
+	}
 	p, ok := e.(*expr.Path)
 	if !ok {
 		return nil, syntax("unexpected table expression %q", expr.ToString(e))
@@ -123,22 +166,32 @@ func (f *fsEnv) index(e expr.Node) (*blockfmt.Index, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = f.allow(dbname)
+	if err != nil {
+		return nil, err
+	}
 
 	// if a query references the same table
 	// more than once (common with CTEs, nested SELECTs, etc.),
 	// then don't load the index more than once; it is expensive
 	for i := range f.recent {
-		if f.recent[i].db == dbname && f.recent[i].table == table {
+		if f.recent[i].db == dbname && f.recent[i].table == table && f.recent[i].at.Equal(at) {
 			return f.recent[i].index, nil
 		}
 	}
-	index, err := db.OpenPartialIndex(f.root, dbname, table, f.tenant.Key())
+	var index *blockfmt.Index
+	if at.IsZero() {
+		index, err = db.OpenPartialIndex(f.root, dbname, table, f.tenant.Key())
+	} else {
+		index, err = db.OpenPartialSnapshot(f.root, dbname, table, f.tenant.Key(), at)
+	}
 	if err != nil {
 		return nil, err
 	}
 	f.recent = append(f.recent, savedIndex{
 		db:    dbname,
 		table: table,
+		at:    at,
 		index: index,
 	})
 	if f.modtime.IsZero() || f.modtime.Before(index.Created) {
@@ -160,12 +213,12 @@ func (f *fsEnv) Stat(e, where expr.Node) (plan.TableHandle, error) {
 		return nil, err
 	}
 	var keep func(*blockfmt.SparseIndex, int) bool
-	var match filter
+	var match blockfmt.Filter
 	if where != nil {
-		if m, ok := compileFilter(where); ok {
+		if m, ok := blockfmt.CompileFilter(where); ok {
 			match = m
 			keep = func(s *blockfmt.SparseIndex, n int) bool {
-				return match(s, n) != never
+				return match(s, n) != blockfmt.Never
 			}
 		}
 	}
@@ -173,7 +226,22 @@ func (f *fsEnv) Stat(e, where expr.Node) (plan.TableHandle, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &filterHandle{filter: where, compiled: match, blobs: blobs}, nil
+	fh := &filterHandle{filter: where, compiled: match, blobs: blobs}
+	f.handles = append(f.handles, fh)
+	return fh, nil
+}
+
+// ScanSize implements cachedEnv.ScanSize
+func (f *fsEnv) ScanSize() (total, maxscan int64, err error) {
+	for _, fh := range f.handles {
+		t, m, err := fh.scanSize()
+		if err != nil {
+			return 0, 0, err
+		}
+		total += t
+		maxscan += m
+	}
+	return total, maxscan, nil
 }
 
 var _ plan.TableLister = (*fsEnv)(nil)
@@ -183,6 +251,10 @@ func (f *fsEnv) ListTables(dbname string) ([]string, error) {
 	if dbname == "" {
 		dbname = f.db
 	}
+	err := f.allow(dbname)
+	if err != nil {
+		return nil, err
+	}
 	for i := range f.lists {
 		if f.lists[i].db == dbname {
 			return f.lists[i].list, nil
@@ -199,6 +271,144 @@ func (f *fsEnv) ListTables(dbname string) ([]string, error) {
 	return li, nil
 }
 
+var _ plan.FunctionResolver = (*fsEnv)(nil)
+
+// Function implements plan.FunctionResolver.Function
+//
+// Functions are resolved from the database
+// provided with the query; if no database
+// was provided, there are no functions.
+// Functions called within a view are resolved
+// from the database of the view (see View).
+func (f *fsEnv) Function(name string) (*expr.Function, error) {
+	if f.db == "" {
+		return nil, nil
+	}
+	return f.function(f.db, name)
+}
+
+func (f *fsEnv) function(dbname, name string) (*expr.Function, error) {
+	fns, ok := f.functions[dbname]
+	if !ok {
+		err := f.allow(dbname)
+		if err != nil {
+			return nil, err
+		}
+		defs, err := db.OpenFunctions(f.root, dbname)
+		if err != nil {
+			return nil, err
+		}
+		fns = make(map[string]*expr.Function, len(defs))
+		for i := range defs {
+			fn, err := defs[i].Parse()
+			if err != nil {
+				return nil, err
+			}
+			fns[strings.ToUpper(fn.Name)] = fn
+			// the function definitions change
+			// the meaning of the query text
+			io.WriteString(f.hash, dbname)
+			io.WriteString(f.hash, defs[i].Definition)
+		}
+		if f.functions == nil {
+			f.functions = make(map[string]map[string]*expr.Function)
+		}
+		f.functions[dbname] = fns
+	}
+	return fns[strings.ToUpper(name)], nil
+}
+
+var _ plan.ViewResolver = (*fsEnv)(nil)
+
+// View implements plan.ViewResolver.View
+//
+// Views are resolved like tables: if a database
+// was provided with the query, then views are
+// referenced by name, and otherwise as db.view.
+func (f *fsEnv) View(e expr.Node) (*expr.View, error) {
+	p, ok := e.(*expr.Path)
+	if !ok {
+		return nil, nil
+	}
+	var dbname, name string
+	if f.db == "" {
+		d, ok := p.Rest.(*expr.Dot)
+		if !ok || d.Rest != nil {
+			// let Index produce the error
+			return nil, nil
+		}
+		dbname, name = p.First, d.Field
+	} else {
+		if p.Rest != nil {
+			return nil, nil
+		}
+		dbname, name = f.db, p.First
+	}
+	key := path.Join(dbname, name)
+	if v, ok := f.views[key]; ok {
+		return v, nil
+	}
+	err := f.allow(dbname)
+	if err != nil {
+		return nil, err
+	}
+	var view *expr.View
+	def, err := db.OpenView(f.root, dbname, name)
+	if err == nil {
+		view, err = def.Parse()
+		if err != nil {
+			return nil, err
+		}
+		// the view definition changes
+		// the meaning of the query text
+		io.WriteString(f.hash, key)
+		io.WriteString(f.hash, def.Definition)
+		if f.db == "" {
+			// tables referenced in the view
+			// belong to the view's database
+			// unless they are qualified
+			view.Query = expr.Rewrite(&qualifier{db: dbname}, view.Query).(*expr.Select)
+		}
+		// and so do the functions called in the view;
+		// they are inlined here since the query that
+		// references the view may not have a database
+		body, err := expr.Inline(view.Query, func(name string) (*expr.Function, error) {
+			return f.function(dbname, name)
+		})
+		if err != nil {
+			return nil, err
+		}
+		view.Query = body.(*expr.Select)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if f.views == nil {
+		f.views = make(map[string]*expr.View)
+	}
+	f.views[key] = view
+	return view, nil
+}
+
+// qualifier rewrites unqualified table
+// references as references to tables in db
+type qualifier struct {
+	db string
+}
+
+func (q *qualifier) Walk(e expr.Node) expr.Rewriter { return q }
+
+func (q *qualifier) Rewrite(e expr.Node) expr.Node {
+	tbl, ok := e.(*expr.Table)
+	if !ok {
+		return e
+	}
+	p, ok := tbl.Expr.(*expr.Path)
+	if ok && p.Rest == nil {
+		tbl.Expr = &expr.Path{First: q.db, Rest: &expr.Dot{Field: p.First}}
+	}
+	return e
+}
+
 var _ plan.UploadEnv = (*fsEnv)(nil)
 
 func (f *fsEnv) Uploader() plan.UploadFS {
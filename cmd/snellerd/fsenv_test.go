@@ -17,16 +17,19 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math/rand"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
@@ -35,9 +38,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/SnellerInc/sneller/audit"
+	"github.com/SnellerInc/sneller/auth"
 	"github.com/SnellerInc/sneller/db"
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/expr/partiql"
 	"github.com/SnellerInc/sneller/ion"
 	"github.com/SnellerInc/sneller/ion/blockfmt"
+	"github.com/SnellerInc/sneller/plan"
 	"github.com/SnellerInc/sneller/tenant"
 )
 
@@ -151,6 +159,16 @@ func testdirEnviron(t *testing.T) db.Tenant {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = db.WriteFunctions(dfs, "default", testFunctions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range testViews {
+		err = db.WriteView(dfs, "default", v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
 
 	b := db.Builder{
 		Align:         2048,
@@ -195,13 +213,177 @@ func testFiles(t *testing.T) {
 	}
 }
 
+type restrictedTenant struct {
+	testTenant
+	allowed string
+}
+
+func (r *restrictedTenant) AllowDatabase(name string) bool { return name == r.allowed }
+
+var testFunctions = []db.FunctionDef{{
+	Name:       "first_half",
+	Definition: "CREATE FUNCTION first_half(t) AS t < `2009-01-15T00:00:00Z`",
+}}
+
+var testViews = []*db.ViewDef{{
+	Name:       "early_trips",
+	Definition: "CREATE VIEW early_trips AS SELECT * FROM taxi WHERE tpep_pickup_datetime < `2009-01-15T00:00:00Z`",
+}, {
+	Name:       "first_half_trips",
+	Definition: "CREATE VIEW first_half_trips AS SELECT * FROM taxi WHERE first_half(tpep_pickup_datetime)",
+}}
+
+func TestRestrictedDatabase(t *testing.T) {
+	tt := &restrictedTenant{
+		testTenant: testTenant{root: db.NewDirFS(t.TempDir())},
+		allowed:    "default",
+	}
+	env, err := environ(tt, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fe := env.(*fsEnv)
+	_, err = fe.Index(&expr.Path{First: "other", Rest: &expr.Dot{Field: "table"}})
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Index: got error %v", err)
+	}
+	_, err = fe.ListTables("other")
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("ListTables: got error %v", err)
+	}
+	_, err = fe.View(&expr.Path{First: "other", Rest: &expr.Dot{Field: "view"}})
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("View: got error %v", err)
+	}
+	// a table in an accessible database
+	// is not a view
+	v, err := fe.View(&expr.Path{First: "default", Rest: &expr.Dot{Field: "table"}})
+	if v != nil || err != nil {
+		t.Errorf("View: got %v, %v", v, err)
+	}
+	// an accessible database still yields
+	// the usual error for a missing table
+	_, err = fe.Index(&expr.Path{First: "default", Rest: &expr.Dot{Field: "table"}})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Index: got error %v", err)
+	}
+}
+
+// test a tenant backed by a local directory
+// that is provided by the auth package
+func TestLocalTenant(t *testing.T) {
+	root := t.TempDir()
+	err := os.MkdirAll(filepath.Join(root, "in"), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldname, err := filepath.Abs("../../testdata/parking.10n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(oldname, filepath.Join(root, "in", "parking.10n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &auth.DirStatic{
+		Tenants: []auth.DirIdentity{{
+			ID:       "local",
+			IndexKey: randomKey()[:],
+			Root:     root,
+			Tokens:   []string{"snellerd-test"},
+		}},
+	}
+	tt, err := provider.Authorize(context.Background(), "snellerd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Authorize(context.Background(), "bogus"); err == nil {
+		t.Fatal("expected an error for an unknown token")
+	}
+	// patterns may not escape the root
+	if _, _, err := tt.Split("file://../in/*.10n"); !errors.Is(err, db.ErrBadPattern) {
+		t.Fatalf("Split: got error %v", err)
+	}
+	dfs := db.NewDirFS(root)
+	err = db.WriteDefinition(dfs, "default", &db.Definition{
+		Name:   "parking",
+		Inputs: []db.Input{{Pattern: "file://in/*.10n"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := db.Builder{
+		Align: 2048,
+		Fallback: func(_ string) blockfmt.RowFormat {
+			return blockfmt.UnsafeION()
+		},
+	}
+	err = b.Sync(tt, "default", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peersock := listen(t)
+	s := server{
+		logger:    testlogger(t),
+		cachedir:  t.TempDir(),
+		tenantcmd: []string{"./snellerd-test-binary", "worker"},
+		splitSize: 16 * 1024,
+		peers:     makePeers(t, peersock.Addr().(*net.TCPAddr)),
+		auth:      provider,
+	}
+	err = s.peers.Start(time.Second, t.Logf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpsock := listen(t)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	s.aboutToServe = (&wg).Done
+	go s.Serve(httpsock, peersock)
+	wg.Wait()
+	defer s.Close()
+
+	rq := &requester{
+		t:    t,
+		host: "http://" + httpsock.Addr().String(),
+	}
+	res, err := http.DefaultClient.Do(rq.getQuery("default", "SELECT COUNT(*) FROM parking"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status %s", res.Status)
+	}
+	var buf bytes.Buffer
+	_, err = ion.ToJSON(&buf, bufio.NewReader(res.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != `{"count": 1023}` {
+		t.Errorf("got result %s", got)
+	}
+}
+
 // test the server running on a tmpfs that
 // has been populated with some test tables
 func TestSimpleFS(t *testing.T) {
 	tt := testdirEnviron(t)
 	peersock0, peersock1 := listen(t), listen(t)
 	_ = peersock1
+	results, err := newResultCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditlog := filepath.Join(t.TempDir(), "audit.log")
+	auditor, err := audit.Parse("file://"+auditlog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 	s := server{
+		audit:     auditor,
+		results:   results,
 		logger:    testlogger(t),
 		sandbox:   tenant.CanSandbox(),
 		cachedir:  t.TempDir(),
@@ -210,7 +392,7 @@ func TestSimpleFS(t *testing.T) {
 		peers:     makePeers(t, peersock0.Addr().(*net.TCPAddr), peersock1.Addr().(*net.TCPAddr)),
 		auth:      testAuth{tt},
 	}
-	err := s.peers.Start(time.Second, t.Logf)
+	err = s.peers.Start(time.Second, t.Logf)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -295,6 +477,26 @@ func TestSimpleFS(t *testing.T) {
 			t.Fatalf("got tables: %v", tables)
 		}
 	}
+	{
+		// test that listing functions works
+		req := rq.getFunctions("default")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("get functions: %s", res.Status)
+		}
+		var fns []db.FunctionDef
+		err = json.NewDecoder(res.Body).Decode(&fns)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fns, testFunctions) {
+			t.Fatalf("got functions %v", fns)
+		}
+	}
 	{
 		// test that listing index contents work
 		req := rq.getInputs("default", "parking")
@@ -323,6 +525,48 @@ func TestSimpleFS(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
+	{
+		// test that the inferred schema is available
+		req := rq.getSchema("default", "parking")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("get schema: %s", res.Status)
+		}
+		var ret tableSchema
+		err = json.NewDecoder(res.Body).Decode(&ret)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ret.Rows != 1023 {
+			t.Errorf("schema has %d rows", ret.Rows)
+		}
+		found := false
+		for i := range ret.Fields {
+			if len(ret.Fields[i].Path) == 1 && ret.Fields[i].Path[0] == "Location" {
+				found = true
+				if n := ret.Fields[i].Types["string"]; n == 0 || n > 1023 {
+					t.Errorf("Location has %d strings", n)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Location not in schema %v", ret.Fields)
+		}
+
+		// a table that does not exist is not found
+		res, err = http.DefaultClient.Do(rq.getSchema("default", "no-such-table"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("get schema of missing table: %s", res.Status)
+		}
+	}
 
 	checkTiming := func(res *http.Response) {
 		t.Helper()
@@ -339,7 +583,7 @@ func TestSimpleFS(t *testing.T) {
 				t.Error("query encountered an error")
 			}
 			switch keyvalues[0] {
-			case "exec", "miss", "hit", "scanned":
+			case "exec", "miss", "hit", "scanned", "mem", "compile":
 			default:
 				t.Errorf("unrecognized Server-Timing response %v", keyvalues)
 			}
@@ -388,9 +632,25 @@ SELECT ROUND(SUM(total_amount)) AS "sum" FROM default.taxi WHERE VendorID = (SEL
 		},
 		{`SELECT COUNT(*) FROM TABLE_GLOB("[pt]a*")`, "default", `{"count": 9583}`, false},
 		{`SELECT COUNT(*) FROM TABLE_GLOB("ta*") ++ TABLE_GLOB("pa*")`, "default", `{"count": 9583}`, false},
+		// the current index is the most recent snapshot
+		{`SELECT COUNT(*) FROM default.parking AT TIMESTAMP '2100-01-01T00:00:00Z'`, "", `{"count": 1023}`, false},
+		{"SELECT COUNT(*) FROM taxi AT TIMESTAMP '2100-01-01T00:00:00Z' WHERE tpep_pickup_datetime < `2009-01-15T00:00:00Z`", "default", `{"count": 3707}`, true},
+		// user-defined functions are resolved from the default database
+		{"SELECT COUNT(*) FROM taxi WHERE first_half(tpep_pickup_datetime)", "default", `{"count": 3707}`, true},
+		// views are resolved like tables, and the tables
+		// in a view belong to the database of the view
+		{"SELECT COUNT(*) FROM early_trips", "default", `{"count": 3707}`, true},
+		{"SELECT COUNT(*) FROM default.early_trips", "", `{"count": 3707}`, true},
+		// functions called in a view belong to
+		// the database of the view, too
+		{"SELECT COUNT(*) FROM first_half_trips", "default", `{"count": 3707}`, true},
+		{"SELECT COUNT(*) FROM default.first_half_trips", "", `{"count": 3707}`, true},
 		{`SELECT * INTO foo.bar FROM default.taxi`, "", `{"table": "foo\.bar-.*"}`, false},
 	}
-	for i := range queries {
+	// run each query twice so that the
+	// second run is served from the result cache
+	for i := 0; i < 2*len(queries); i++ {
+		i, cached := i/2, i%2 == 1
 		r := rq.getQuery(queries[i].db, queries[i].input)
 		res, err := http.DefaultClient.Do(r)
 		if err != nil {
@@ -399,6 +659,15 @@ SELECT ROUND(SUM(total_amount)) AS "sum" FROM default.taxi WHERE VendorID = (SEL
 		if res.StatusCode != http.StatusOK {
 			t.Fatalf("status %s", res.Status)
 		}
+		want := "miss"
+		if strings.Contains(queries[i].input, "INTO") {
+			want = ""
+		} else if cached {
+			want = "hit"
+		}
+		if got := res.Header.Get("X-Sneller-Result-Cache"); got != want {
+			t.Errorf("query %d: result cache %q, want %q", i, got, want)
+		}
 		var buf bytes.Buffer
 		_, err = ion.ToJSON(&buf, bufio.NewReader(res.Body))
 		res.Body.Close()
@@ -429,6 +698,28 @@ SELECT ROUND(SUM(total_amount)) AS "sum" FROM default.taxi WHERE VendorID = (SEL
 		if (tablesize == 0 || scannedsize < tablesize) != queries[i].partial {
 			t.Errorf("partial=%v, scanned=%d, all=%d", queries[i].partial, scannedsize, tablesize)
 		}
+		// the estimate computed without splitting
+		// should agree with the splitter
+		if !cached && want != "" {
+			env, err := environ(tt, queries[i].db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q, err := partiql.Parse([]byte(queries[i].input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := plan.New(q, env); err != nil {
+				t.Fatal(err)
+			}
+			total, scan, err := env.ScanSize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tablesize || scan != scannedsize {
+				t.Errorf("ScanSize: got %d of %d, want %d of %d", scan, total, scannedsize, tablesize)
+			}
+		}
 		checkTiming(res)
 	}
 
@@ -464,4 +755,90 @@ SELECT ROUND(SUM(total_amount)) AS "sum" FROM default.taxi WHERE VendorID = (SEL
 		}
 		checkTiming(res)
 	}
+
+	// check that the queries above
+	// were reflected in the metrics
+	res, err := http.Get(rq.host + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		// every query is executed once, plus the
+		// second execution of the SELECT INTO query
+		fmt.Sprintf(`sneller_query_duration_seconds_count{status="ok"} %d`, len(queries)+1+len(jsqueries)),
+		fmt.Sprintf(`sneller_query_duration_seconds_count{status="cached"} %d`, len(queries)-1),
+		fmt.Sprintf(`sneller_node_executions_total{source="local"} %d`, len(queries)+1+len(jsqueries)),
+		"sneller_tenant_processes 1",
+		"sneller_peers 2",
+	} {
+		if !bytes.Contains(metrics, []byte(want+"\n")) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+	if !regexp.MustCompile(`(?m)^sneller_tenant_cache_(hits|misses|failures|live_hits) \d+$`).Match(metrics) {
+		t.Error("metrics missing tenant cache statistics")
+	}
+	// the peer only executes the parts
+	// of split queries sent to it
+	res, err = http.Get("http://" + httpsock2.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peermetrics, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`(?m)^sneller_node_executions_total{source="peer"} (\d+)$`).FindSubmatch(peermetrics)
+	if m == nil || string(m[1]) == "0" {
+		t.Errorf("peer metrics: no executions on behalf of peers:\n%s", peermetrics)
+	}
+	if t.Failed() {
+		t.Logf("metrics:\n%s", metrics)
+	}
+
+	// check that every query produced an audit event
+	f, err := os.Open(auditlog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []audit.Event
+	d := json.NewDecoder(f)
+	for {
+		var ev audit.Event
+		err := d.Decode(&ev)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 2*len(queries)+len(jsqueries) {
+		t.Fatalf("got %d audit events", len(events))
+	}
+	ev := &events[0]
+	if ev.TenantID != "test-tenant" || ev.Status != statusOK || ev.QueryID == "" ||
+		len(ev.Tables) != 1 || ev.Tables[0] != "default.parking" {
+		t.Errorf("unexpected audit event %+v", ev)
+	}
+	if ev.BytesScanned == 0 {
+		t.Error("audit event has BytesScanned == 0")
+	}
+	if ev := &events[1]; ev.Status != statusCached {
+		t.Errorf("second event has status %q", ev.Status)
+	}
+	// literals in the query text must be redacted
+	for i := range events {
+		if strings.Contains(events[i].Query, "2A75") {
+			t.Errorf("query text %q not redacted", events[i].Query)
+		}
+	}
 }
@@ -17,6 +17,7 @@ package main
 import (
 	"net/http"
 
+	"github.com/SnellerInc/sneller/auth"
 	"github.com/SnellerInc/sneller/db"
 )
 
@@ -45,6 +46,9 @@ func (s *server) databasesHandler(w http.ResponseWriter, r *http.Request) {
 
 	out := make([]database, 0)
 	for i := range res {
+		if !auth.AllowDatabase(tenant, res[i]) {
+			continue
+		}
 		if pattern == "" || matchPattern(res[i], pattern) {
 			out = append(out, database{
 				Name: res[i],
@@ -27,6 +27,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/SnellerInc/sneller/audit"
+	"github.com/SnellerInc/sneller/auth"
 	"github.com/SnellerInc/sneller/expr"
 	"github.com/SnellerInc/sneller/expr/partiql"
 	"github.com/SnellerInc/sneller/ion"
@@ -61,8 +63,9 @@ func setError(w http.ResponseWriter) {
 }
 
 func setTiming(w http.ResponseWriter, elapsed time.Duration, stats *plan.ExecStats) {
-	w.Header().Add("Server-Timing", fmt.Sprintf("exec;dur=%g, miss;desc=\"Cache Misses\";count=%d, hit;desc=\"Cache Hits\";count=%d, scanned;desc=\"Bytes Scanned\";count=%d",
-		float64(elapsed)/float64(time.Millisecond), stats.CacheMisses, stats.CacheHits, stats.BytesScanned))
+	w.Header().Add("Server-Timing", fmt.Sprintf("exec;dur=%g, miss;desc=\"Cache Misses\";count=%d, hit;desc=\"Cache Hits\";count=%d, scanned;desc=\"Bytes Scanned\";count=%d, mem;desc=\"Peak Memory\";count=%d, compile;dur=%g",
+		float64(elapsed)/float64(time.Millisecond), stats.CacheMisses, stats.CacheHits, stats.BytesScanned, stats.PeakMemory,
+		float64(stats.CompileTime)/float64(time.Millisecond)))
 }
 
 // after 15 minutes, stop waiting for a result
@@ -75,10 +78,28 @@ const queryKillTimeout = 15 * time.Minute
 func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	start := time.Now()
+	status := statusInvalid
+	ev := audit.Event{Time: start}
+	ev.RemoteAddr, _ = remoteAddr(r)
+	defer func(begin time.Time) {
+		elapsed := time.Since(begin)
+		s.metrics.query(status, elapsed)
+		if s.audit != nil {
+			ev.Status = status
+			ev.Duration = elapsed.Seconds()
+			if err := s.audit.Write(&ev); err != nil {
+				s.logger.Printf("writing audit event: %s", err)
+			}
+		}
+	}(start)
 	tenantCreds, err := s.getTenant(ctx, w, r)
 	if err != nil {
+		status = statusUnauthorized
+		ev.Error = err.Error()
 		return
 	}
+	ev.TenantID = tenantCreds.ID()
+	ev.Tenant = tenantCreds
 	authElapsed := time.Since(start)
 
 	var query []byte
@@ -131,14 +152,18 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	defaultDatabase := r.URL.Query().Get("database")
+	ev.Database = defaultDatabase
 	parsedQuery, err := partiql.Parse(query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
+		ev.Error = err.Error()
 		return
 	}
 	normalized := parsedQuery.Text()
-	redacted := parsedQuery.Text()
+	redacted := parsedQuery.Redacted()
 	queryID := uuid.New()
+	ev.Query = redacted
+	ev.QueryID = queryID.String()
 
 	var workerID tnproto.ID
 	hash := sha256.Sum256([]byte(tenantCreds.ID()))
@@ -147,25 +172,58 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 	planEnv, err := environ(tenantCreds, defaultDatabase)
 	if err != nil {
 		http.Error(w, "tenant ID disallowed", http.StatusForbidden)
+		status = statusUnauthorized
+		ev.Error = err.Error()
 		s.logger.Printf("refusing query: %s", err)
 		return
 	}
+	if p, ok := parsedQuery.Into.(*expr.Path); ok && !auth.AllowDatabase(tenantCreds, p.First) {
+		http.Error(w, "access denied", http.StatusForbidden)
+		status = statusUnauthorized
+		ev.Error = fmt.Sprintf("INTO database %q not permitted", p.First)
+		return
+	}
+	limits := auth.TenantLimits(tenantCreds)
 	endPoints := s.peers.Get()
 
 	var tree *plan.Tree
+	maxscan := int64(-1)
 	start = time.Now()
 	if len(endPoints) == 0 {
 		tree, err = plan.New(parsedQuery, planEnv)
+		if err == nil && limits != nil && limits.MaxScanBytes > 0 {
+			// the splitter isn't involved, so
+			// estimate the scan size from the
+			// tables that the planner used
+			var total int64
+			total, maxscan, err = planEnv.ScanSize()
+			if err == nil {
+				w.Header().Set("X-Sneller-Max-Scanned-Bytes", itoa(maxscan))
+				w.Header().Set("X-Sneller-Total-Table-Bytes", itoa(total))
+			}
+		}
 	} else {
 		planSplitter := s.newSplitter(workerID, endPoints)
 		tree, err = plan.NewSplit(parsedQuery, planEnv, planSplitter)
 		if err == nil {
+			maxscan = planSplitter.maxscan
 			w.Header().Set("X-Sneller-Max-Scanned-Bytes", itoa(planSplitter.maxscan))
 			w.Header().Set("X-Sneller-Total-Table-Bytes", itoa(planSplitter.total))
 		}
 	}
+	ev.Tables = planEnv.Tables()
 	if err != nil {
-		s.planError(w, err)
+		if !s.planError(w, err) {
+			status = statusError
+		}
+		ev.Error = err.Error()
+		return
+	}
+	if limits != nil && limits.MaxScanBytes > 0 && maxscan > limits.MaxScanBytes {
+		status = statusRejected
+		ev.Error = fmt.Sprintf("query would scan %d bytes (limit %d)", maxscan, limits.MaxScanBytes)
+		http.Error(w, ev.Error, http.StatusTooManyRequests)
+		s.logger.Printf("query id %s rejected: scan size %d exceeds limit %d", queryID, maxscan, limits.MaxScanBytes)
 		return
 	}
 	s.logger.Printf("query id %s auth %s planning %s", queryID, authElapsed, time.Since(start))
@@ -193,6 +251,7 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 			for _, matchEtag := range strings.Split(ifNoneMatch, ",") {
 				matchEtag = strings.TrimSpace(matchEtag)
 				if eTag == matchEtag {
+					status = statusNotModified
 					w.WriteHeader(http.StatusNotModified)
 					return
 				}
@@ -208,6 +267,7 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 				}
 
 				if !newestBlobTime.After(ifModifiedSinceTime) {
+					status = statusNotModified
 					w.WriteHeader(http.StatusNotModified)
 					return
 				}
@@ -218,6 +278,7 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", acceptHeader)
 	w.Header().Add("X-Sneller-Query-ID", queryID.String())
 	if r.Method == http.MethodHead {
+		status = statusOK
 		w.WriteHeader(http.StatusOK)
 		return
 	}
@@ -231,8 +292,54 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 		req:   r,
 		res:   w,
 	}
+
+	// SELECT INTO has side-effects,
+	// so it can never be served from the cache
+	var tee *resultTee
+	if s.results != nil && parsedQuery.Into == nil {
+		if f := s.results.get(eTag); f != nil {
+			defer f.Close()
+			w.Header().Set("X-Sneller-Result-Cache", "hit")
+			status = statusCached
+			s.serveCached(w, conn, f, queryID, encodingFormat, sendTrailer)
+			return
+		}
+		w.Header().Set("X-Sneller-Result-Cache", "miss")
+		tee, err = s.results.tee(eTag)
+		if err != nil {
+			s.logger.Printf("query ID %s cannot cache result: %s", queryID, err)
+			tee = nil
+		}
+	}
+
+	done, err := s.admit.acquire(ctx, tenantCreds.ID(), limits)
+	if err != nil {
+		if tee != nil {
+			tee.abort()
+		}
+		status = statusRejected
+		ev.Error = err.Error()
+		s.admissionError(w, err)
+		s.logger.Printf("query id %s rejected: %s", queryID, err)
+		return
+	}
+	defer done()
+
+	status = statusError
 	startrun := time.Now()
-	rc, err := s.manager.Do(workerID, tree, encodingFormat, conn)
+	var into net.Conn = conn
+	if tee != nil {
+		into = tee.remote
+	}
+	rc, err := s.manager.Do(workerID, tree, encodingFormat, into)
+	if tee != nil {
+		// the tenant has its own copy of the
+		// socket now (or it never will)
+		tee.remote.Close()
+		if err != nil {
+			tee.abort()
+		}
+	}
 	if err != nil {
 		if !conn.hijacked {
 			// didn't call w.WriteHeader() yet;
@@ -240,6 +347,7 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 			w.Header().Del("Trailer")
 			w.Header().Set("Content-Type", "text/plain")
 			if errors.Is(err, tenant.ErrOverloaded) {
+				status = statusRejected
 				w.WriteHeader(http.StatusTooManyRequests)
 			} else {
 				w.WriteHeader(http.StatusInternalServerError)
@@ -252,17 +360,46 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 				writeError(w, "error dispatching query")
 			}
 		}
+		ev.Error = err.Error()
 		s.logger.Printf("query ID %s %q execution failed (do): %v", queryID, redacted, err)
 		return
 	}
 	s.logger.Printf("query ID %s plan transfer took %s", queryID, time.Since(startrun))
+	var copied chan error
+	if tee != nil {
+		copied = make(chan error, 1)
+		go func() {
+			dst, err := conn.hijack()
+			if err == nil {
+				err = tee.copy(dst)
+			}
+			copied <- err
+		}()
+	}
 	var stats plan.ExecStats
 	deadlined := setDeadline(rc, queryKillTimeout)
 	err = tenant.Check(rc, &stats)
+	if copied != nil {
+		// the tenant closes its end of the
+		// output once it has exited or finished
+		// writing, so this shouldn't block for long
+		cerr := <-copied
+		if err == nil && cerr != nil {
+			err = fmt.Errorf("copying output: %w", cerr)
+		}
+		if err == nil {
+			if err := tee.commit(); err != nil {
+				s.logger.Printf("query ID %s not cached: %s", queryID, err)
+			}
+		} else {
+			tee.abort()
+		}
+	}
 	if err != nil {
 		if sendTrailer {
 			setError(w)
 		}
+		ev.Error = err.Error()
 		s.logger.Printf("query ID %s %q execution failed (check): %v", queryID, redacted, err)
 		if deadlined && isTimeout(err) {
 			s.logger.Printf("query ID %s killing tenant ID %s due to timeout", queryID, workerID)
@@ -270,6 +407,9 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	status = statusOK
+	ev.BytesScanned = stats.BytesScanned
+	s.metrics.exec(&stats)
 	elapsed := time.Since(startrun)
 	if sendTrailer {
 		setTiming(w, elapsed, &stats)
@@ -281,6 +421,47 @@ func (s *server) executeQueryHandler(w http.ResponseWriter, r *http.Request) {
 		queryID, elapsed, stats.BytesScanned, stats.CacheHits, stats.CacheMisses)
 }
 
+// admissionError writes the response for
+// a query rejected by admission control
+func (s *server) admissionError(w http.ResponseWriter, err error) {
+	var ae *admissionError
+	if !errors.As(err, &ae) {
+		// the request was canceled
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Del("Trailer")
+	secs := int64((ae.retry + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", itoa(secs))
+	http.Error(w, ae.Error(), http.StatusTooManyRequests)
+}
+
+// serveCached writes a result from the result cache
+func (s *server) serveCached(w http.ResponseWriter, conn *delayedHijack, src io.Reader, queryID uuid.UUID, format tnproto.OutputFormat, sendTrailer bool) {
+	start := time.Now()
+	dst, err := conn.hijack()
+	if err == nil {
+		_, err = io.Copy(dst, src)
+	}
+	if err != nil {
+		if sendTrailer {
+			setError(w)
+		}
+		s.logger.Printf("query ID %s writing cached result: %s", queryID, err)
+		return
+	}
+	// nothing was scanned to produce this result
+	var stats plan.ExecStats
+	elapsed := time.Since(start)
+	if sendTrailer {
+		setTiming(w, elapsed, &stats)
+	}
+	if format == tnproto.OutputChunkedIon {
+		writeStatus(w, &stats)
+	}
+	s.logger.Printf("query id %s duration %s (cached)", queryID, elapsed)
+}
+
 // satisfied by net.Conn and friends
 type readDeadliner interface {
 	SetReadDeadline(time.Time) error
@@ -333,21 +514,31 @@ func isBadQuery(err error, w http.ResponseWriter) bool {
 // to the user (and the status code ought to be 4xx)
 //
 // type and syntax errors are returned as 400,
+// fs.ErrPermission errors are returned as 403,
 // fs.ErrNotExist errors are returned as 404,
 // and others are returned as 500
-func (s *server) planError(w http.ResponseWriter, err error) {
+//
+// planError returns true if the error was
+// a user error, or false otherwise
+func (s *server) planError(w http.ResponseWriter, err error) bool {
 	w.Header().Set("Content-Type", "text/plain")
+	if errors.Is(err, fs.ErrPermission) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "access denied\n")
+		return true
+	}
 	if errors.Is(err, fs.ErrNotExist) {
 		w.WriteHeader(http.StatusNotFound)
 		io.WriteString(w, "table does not exist\n")
-		return
+		return true
 	}
 	if isBadQuery(err, w) {
-		return
+		return true
 	}
 	w.WriteHeader(http.StatusInternalServerError)
 	io.WriteString(w, "couldn't create query plan\n")
 	s.logger.Printf("query planning failed: %s", err)
+	return false
 }
 
 func writeError(w http.ResponseWriter, errtext string) {
@@ -0,0 +1,63 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SnellerInc/sneller/auth"
+	"github.com/SnellerInc/sneller/db"
+)
+
+func (s *server) functionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	tenant, err := s.getTenant(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	databaseName := r.URL.Query().Get("database")
+	if databaseName == "" {
+		http.Error(w, "no database", http.StatusBadRequest)
+		return
+	}
+	if !auth.AllowDatabase(tenant, databaseName) {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
+
+	pattern := r.URL.Query().Get("pattern")
+	e, err := environ(tenant, databaseName)
+	if err != nil {
+		s.logger.Printf("refusing tenant: newEnv: %s", err)
+		http.Error(w, "bad tenant ID", http.StatusForbidden)
+		return
+	}
+	defs, err := db.OpenFunctions(e.(*fsEnv).root, databaseName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot list functions: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]db.FunctionDef, 0, len(defs))
+	for i := range defs {
+		if pattern == "" || matchPattern(defs[i].Name, pattern) {
+			out = append(out, defs[i])
+		}
+	}
+	writeResultResponse(w, http.StatusOK, out)
+}
@@ -19,6 +19,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/SnellerInc/sneller/auth"
 	"github.com/SnellerInc/sneller/db"
 	"github.com/SnellerInc/sneller/ion/blockfmt"
 )
@@ -53,6 +54,10 @@ func (s *server) inputsHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "no table", http.StatusBadRequest)
 		return
 	}
+	if !auth.AllowDatabase(tenant, databaseName) {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
 	start := r.URL.Query().Get("start")
 	max := -1
 	maxtext := r.URL.Query().Get("max")
@@ -0,0 +1,66 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// metricsHandler serves metrics in the
+// Prometheus text exposition format
+//
+// example invocation:
+// curl http://localhost:8000/metrics
+func (s *server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	s.metrics.writeTo(&buf)
+	if s.manager != nil {
+		st := s.manager.Stats()
+		writeMetric(&buf, "sneller_tenant_processes", "gauge",
+			"Number of running tenant processes.", strconv.Itoa(st.Live))
+		// executions on this node, including
+		// work done on behalf of peers
+		const name = "sneller_node_executions_total"
+		fmt.Fprintf(&buf, "# HELP %s Queries executed by tenant processes on this node by source.\n# TYPE %s counter\n", name, name)
+		fmt.Fprintf(&buf, "%s{source=\"local\"} %d\n", name, st.DirectExecs)
+		fmt.Fprintf(&buf, "%s{source=\"peer\"} %d\n", name, st.RemoteExecs)
+		writeMetric(&buf, "sneller_tenant_cache_hits", "gauge",
+			"Cache hits reported by running tenant processes.", itoa(st.Cache.Hits))
+		writeMetric(&buf, "sneller_tenant_cache_misses", "gauge",
+			"Cache misses reported by running tenant processes.", itoa(st.Cache.Misses))
+		writeMetric(&buf, "sneller_tenant_cache_failures", "gauge",
+			"Failed cache fills reported by running tenant processes.", itoa(st.Cache.Failures))
+		writeMetric(&buf, "sneller_tenant_cache_live_hits", "gauge",
+			"Cache entries currently mapped for reading by running tenant processes.", itoa(st.Cache.LiveHits))
+	}
+	if s.peers != nil {
+		writeMetric(&buf, "sneller_peers", "gauge",
+			"Number of peers available for split queries.", strconv.Itoa(len(s.peers.Get())))
+	}
+	if s.results != nil {
+		entries, size := s.results.stats()
+		writeMetric(&buf, "sneller_result_cache_entries", "gauge",
+			"Number of entries in the query result cache.", strconv.Itoa(entries))
+		writeMetric(&buf, "sneller_result_cache_bytes", "gauge",
+			"Size of the query result cache in bytes.", itoa(size))
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
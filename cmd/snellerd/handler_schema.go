@@ -0,0 +1,108 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/SnellerInc/sneller/auth"
+	"github.com/SnellerInc/sneller/db"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+type fieldSchema struct {
+	Path  []string         `json:"path"`
+	Types map[string]int64 `json:"types"`
+}
+
+type tableSchema struct {
+	Rows      int64         `json:"rows"`
+	Truncated bool          `json:"truncated,omitempty"`
+	Fields    []fieldSchema `json:"fields"`
+}
+
+func (s *server) schemaHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	tenant, err := s.getTenant(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	databaseName := r.URL.Query().Get("database")
+	if databaseName == "" {
+		http.Error(w, "no database", http.StatusBadRequest)
+		return
+	}
+	tableName := r.URL.Query().Get("table")
+	if tableName == "" {
+		http.Error(w, "no table", http.StatusBadRequest)
+		return
+	}
+	if !auth.AllowDatabase(tenant, databaseName) {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
+
+	root, err := tenant.Root()
+	if err != nil {
+		http.Error(w, "couldn't open db+table", http.StatusInternalServerError)
+		return
+	}
+	_, err = db.OpenPartialIndex(root, databaseName, tableName, tenant.Key())
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "table not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Printf("handling /schema: OpenPartialIndex: %s", err)
+		http.Error(w, "couldn't open index file", http.StatusInternalServerError)
+		return
+	}
+	sc, err := db.OpenSchema(root, databaseName, tableName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		s.logger.Printf("handling /schema: OpenSchema: %s", err)
+		http.Error(w, "couldn't open schema", http.StatusInternalServerError)
+		return
+	}
+
+	ret := tableSchema{Fields: []fieldSchema{}}
+	if sc != nil {
+		ret.Rows = sc.Rows
+		ret.Truncated = sc.Truncated
+		for i := range sc.Fields {
+			f := &sc.Fields[i]
+			types := make(map[string]int64)
+			for t := range f.Types {
+				if f.Types[t] != 0 {
+					types[ion.Type(t).String()] = f.Types[t]
+				}
+			}
+			ret.Fields = append(ret.Fields, fieldSchema{
+				Path:  f.Path,
+				Types: types,
+			})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(&ret)
+	if err != nil {
+		s.logger.Printf("writing schema: %s", err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"io/fs"
 	"net/http"
 
+	"github.com/SnellerInc/sneller/auth"
 	"github.com/SnellerInc/sneller/db"
 )
 
@@ -36,6 +37,10 @@ func (s *server) tablesHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "no database", http.StatusBadRequest)
 		return
 	}
+	if !auth.AllowDatabase(tenant, databaseName) {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
 
 	pattern := r.URL.Query().Get("pattern")
 	e, err := environ(tenant, databaseName)
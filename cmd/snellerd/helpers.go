@@ -30,18 +30,12 @@ import (
 func (s *server) handle(handler func(http.ResponseWriter, *http.Request), methods ...string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
-		// obtain the real address
-		remoteAddress := r.RemoteAddr
-		forwarded := false
-		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
-			parts := strings.Split(forwardedFor, ",")
-			remoteAddress = strings.TrimSpace(parts[len(parts)-1])
-			forwarded = true
-		}
+		remoteAddress, forwarded := remoteAddr(r)
 		// unforwarded requests to "/"
-		// are just ELB heartbeats;
+		// are just ELB heartbeats, and requests
+		// to "/metrics" are periodic scrapes;
 		// don't log these, as they spam the logs
-		if r.URL.Path != "/" || forwarded {
+		if (r.URL.Path != "/" && r.URL.Path != "/metrics") || forwarded {
 			s.logger.Printf("Request %s %s from %s", r.Method, r.URL.Path, remoteAddress)
 		}
 		if version != "" {
@@ -57,6 +51,17 @@ func (s *server) handle(handler func(http.ResponseWriter, *http.Request), method
 	}
 }
 
+// remoteAddr obtains the real address
+// of the client that made the request r,
+// and whether or not the request was forwarded
+func remoteAddr(r *http.Request) (string, bool) {
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		parts := strings.Split(forwardedFor, ",")
+		return strings.TrimSpace(parts[len(parts)-1]), true
+	}
+	return r.RemoteAddr, false
+}
+
 func (s *server) getTenant(ctx context.Context, w http.ResponseWriter, r *http.Request) (db.Tenant, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -34,7 +34,11 @@ type sysconn interface {
 	SyscallConn() (syscall.RawConn, error)
 }
 
-func (d *delayedHijack) SyscallConn() (syscall.RawConn, error) {
+// hijack writes the response header and
+// returns the underlying connection, to which
+// the response body can be written directly
+// using HTTP chunked encoding
+func (d *delayedHijack) hijack() (net.Conn, error) {
 	d.hijacked = true
 	d.res.Header().Add("Transfer-Encoding", "chunked")
 	d.res.WriteHeader(http.StatusOK)
@@ -43,6 +47,14 @@ func (d *delayedHijack) SyscallConn() (syscall.RawConn, error) {
 	if !ok {
 		return nil, fmt.Errorf("no rawConn value?")
 	}
+	return conn, nil
+}
+
+func (d *delayedHijack) SyscallConn() (syscall.RawConn, error) {
+	conn, err := d.hijack()
+	if err != nil {
+		return nil, err
+	}
 	sc, ok := conn.(sysconn)
 	if !ok {
 		return nil, fmt.Errorf("can't use %T as sysconn", conn)
@@ -19,7 +19,7 @@ import (
 	"os"
 	"strings"
 
-	"golang.org/x/sys/cpu"
+	"github.com/SnellerInc/sneller/vm"
 )
 
 var version = "development"
@@ -29,9 +29,8 @@ var version = "development"
 var testmode = false
 
 func main() {
-	if !cpu.X86.HasAVX512 {
-		fmt.Fprintln(os.Stderr, "CPU doesn't support AVX-512")
-		os.Exit(1)
+	if vm.Portable() {
+		fmt.Fprintln(os.Stderr, "warning: CPU doesn't support AVX-512; using the (slow, partial) portable interpreter")
 	}
 
 	args := os.Args[1:]
@@ -0,0 +1,135 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SnellerInc/sneller/plan"
+)
+
+// query status labels used for metrics
+const (
+	statusOK           = "ok"
+	statusCached       = "cached"
+	statusNotModified  = "not_modified"
+	statusUnauthorized = "unauthorized"
+	statusInvalid      = "invalid"
+	statusRejected     = "rejected"
+	statusError        = "error"
+)
+
+// latencyBuckets are the upper bounds (in seconds)
+// of the query latency histogram buckets
+var latencyBuckets = []float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300,
+}
+
+type histogram struct {
+	counts []uint64 // one per latencyBuckets entry
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(secs float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	for i := range latencyBuckets {
+		if secs <= latencyBuckets[i] {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += secs
+}
+
+// metrics collects the statistics exposed
+// by the /metrics endpoint
+type metrics struct {
+	lock    sync.Mutex
+	queries map[string]*histogram // by status
+
+	// accumulated plan.ExecStats
+	// reported by tenant processes
+	stats plan.ExecStats
+}
+
+// query records the completion of a query
+func (m *metrics) query(status string, elapsed time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.queries == nil {
+		m.queries = make(map[string]*histogram)
+	}
+	h := m.queries[status]
+	if h == nil {
+		h = new(histogram)
+		m.queries[status] = h
+	}
+	h.observe(elapsed.Seconds())
+}
+
+// exec records the execution statistics of a query
+func (m *metrics) exec(stats *plan.ExecStats) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.stats.CacheHits += stats.CacheHits
+	m.stats.CacheMisses += stats.CacheMisses
+	m.stats.BytesScanned += stats.BytesScanned
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func writeMetric(w io.Writer, name, typ, help string, value string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, typ, name, value)
+}
+
+// writeTo writes the metrics in the
+// Prometheus text exposition format
+func (m *metrics) writeTo(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	const name = "sneller_query_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Query latency by status.\n# TYPE %s histogram\n", name, name)
+	statuses := make([]string, 0, len(m.queries))
+	for status := range m.queries {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		h := m.queries[status]
+		for i := range latencyBuckets {
+			fmt.Fprintf(w, "%s_bucket{status=%q,le=%q} %d\n", name, status, ftoa(latencyBuckets[i]), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{status=%q,le=\"+Inf\"} %d\n", name, status, h.count)
+		fmt.Fprintf(w, "%s_sum{status=%q} %s\n", name, status, ftoa(h.sum))
+		fmt.Fprintf(w, "%s_count{status=%q} %d\n", name, status, h.count)
+	}
+	writeMetric(w, "sneller_bytes_scanned_total", "counter",
+		"Total number of bytes scanned by queries.", itoa(m.stats.BytesScanned))
+	writeMetric(w, "sneller_cache_hits_total", "counter",
+		"Total number of tenant cache hits.", itoa(m.stats.CacheHits))
+	writeMetric(w, "sneller_cache_misses_total", "counter",
+		"Total number of tenant cache misses.", itoa(m.stats.CacheMisses))
+}
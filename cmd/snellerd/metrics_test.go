@@ -0,0 +1,56 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/plan"
+)
+
+func TestMetricsText(t *testing.T) {
+	var m metrics
+	m.query(statusOK, 20*time.Millisecond)
+	m.query(statusOK, 2*time.Second)
+	m.query(statusError, time.Millisecond)
+	m.exec(&plan.ExecStats{CacheHits: 3, CacheMisses: 1, BytesScanned: 1000})
+	m.exec(&plan.ExecStats{CacheHits: 1, BytesScanned: 24})
+
+	var out strings.Builder
+	m.writeTo(&out)
+	text := out.String()
+	for _, want := range []string{
+		"# TYPE sneller_query_duration_seconds histogram",
+		`sneller_query_duration_seconds_bucket{status="error",le="0.005"} 1`,
+		`sneller_query_duration_seconds_bucket{status="ok",le="0.01"} 0`,
+		`sneller_query_duration_seconds_bucket{status="ok",le="0.025"} 1`,
+		`sneller_query_duration_seconds_bucket{status="ok",le="2.5"} 2`,
+		`sneller_query_duration_seconds_bucket{status="ok",le="+Inf"} 2`,
+		`sneller_query_duration_seconds_sum{status="ok"} 2.02`,
+		`sneller_query_duration_seconds_count{status="ok"} 2`,
+		"sneller_bytes_scanned_total 1024",
+		"sneller_cache_hits_total 4",
+		"sneller_cache_misses_total 1",
+	} {
+		if !strings.Contains(text, want+"\n") {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if t.Failed() {
+		t.Log(text)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/SnellerInc/sneller/usock"
+)
+
+// resultCache is an on-disk cache of query
+// results keyed by the query ETag.
+//
+// Since the ETag already incorporates the tenant ID,
+// the normalized query text, the plan hash (which
+// changes whenever the newest blob in an index changes)
+// and the output format, entries never need to be
+// explicitly invalidated; stale entries simply stop
+// being referenced and age out of the LRU list.
+//
+// Cached results are stored exactly as they were
+// written by the tenant process (i.e. HTTP chunked encoding
+// without the final zero-length chunk), so they can
+// be copied directly into the client connection.
+type resultCache struct {
+	dir     string
+	maxSize int64
+
+	lock  sync.Mutex
+	size  int64
+	lru   list.List // front is most recently used
+	items map[string]*list.Element
+}
+
+type resultEntry struct {
+	name string
+	size int64
+}
+
+// resultSubdir is the directory inside the
+// -result-cache directory that the cache owns
+const resultSubdir = "snellerd-results"
+
+// newResultCache creates a result cache in a
+// subdirectory of root that holds at most
+// maxSize bytes of results.
+// Entries left behind by a previous process
+// are removed; nothing else in root is touched.
+func newResultCache(root string, maxSize int64) (*resultCache, error) {
+	dir := filepath.Join(root, resultSubdir)
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return nil, err
+	}
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, ent := range ents {
+		if ent.Type().IsRegular() && isResultFile(ent.Name()) {
+			os.Remove(filepath.Join(dir, ent.Name()))
+		}
+	}
+	return &resultCache{
+		dir:     dir,
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element),
+	}, nil
+}
+
+// isResultFile returns whether name is the
+// name of an entry (see resultCache.name)
+// or of a partial fill (see resultCache.tee)
+func isResultFile(name string) bool {
+	if strings.HasPrefix(name, ".fill-") {
+		return true
+	}
+	if len(name) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+func (c *resultCache) name(etag string) string {
+	h := sha256.Sum256([]byte(etag))
+	return hex.EncodeToString(h[:])
+}
+
+// get opens the cached result for etag,
+// or returns nil if there is no such result.
+func (c *resultCache) get(etag string) *os.File {
+	name := c.name(etag)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.items[name]
+	if !ok {
+		return nil
+	}
+	// note: an eviction may race with
+	// reading the file, but an unlinked file
+	// remains readable as long as it is open
+	f, err := os.Open(filepath.Join(c.dir, name))
+	if err != nil {
+		c.remove(e)
+		return nil
+	}
+	c.lru.MoveToFront(e)
+	return f
+}
+
+// stats returns the number of entries
+// and the total size of the cache
+func (c *resultCache) stats() (int, int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len(), c.size
+}
+
+// remove removes an entry; the caller must hold c.lock
+func (c *resultCache) remove(e *list.Element) {
+	ent := c.lru.Remove(e).(*resultEntry)
+	delete(c.items, ent.name)
+	c.size -= ent.size
+	os.Remove(filepath.Join(c.dir, ent.name))
+}
+
+func (c *resultCache) insert(name string, size int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[name]; ok {
+		// we raced with another fill of
+		// the same entry; the file has been
+		// replaced, so just update the size
+		ent := e.Value.(*resultEntry)
+		c.size += size - ent.size
+		ent.size = size
+		c.lru.MoveToFront(e)
+	} else {
+		c.items[name] = c.lru.PushFront(&resultEntry{name: name, size: size})
+		c.size += size
+	}
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// resultTee sits between a tenant process
+// and a client connection and copies the
+// query output into a new cache entry
+type resultTee struct {
+	parent *resultCache
+	name   string
+	// local is read by the daemon;
+	// remote is passed to the tenant
+	local, remote *net.UnixConn
+	tmp           *os.File
+	size          int64
+	err           error
+}
+
+// tee prepares a cache fill for etag.
+func (c *resultCache) tee(etag string) (*resultTee, error) {
+	local, remote, err := usock.SocketPair()
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(c.dir, ".fill-*")
+	if err != nil {
+		local.Close()
+		remote.Close()
+		return nil, err
+	}
+	return &resultTee{
+		parent: c,
+		name:   c.name(etag),
+		local:  local,
+		remote: remote,
+		tmp:    tmp,
+	}, nil
+}
+
+// Write implements io.Writer.
+// Errors writing to the cache entry are
+// recorded but not returned, since they
+// should not interrupt the client response.
+func (t *resultTee) Write(p []byte) (int, error) {
+	if t.err != nil {
+		return len(p), nil
+	}
+	t.size += int64(len(p))
+	if t.size > t.parent.maxSize {
+		t.err = errEntryTooLarge
+		return len(p), nil
+	}
+	_, t.err = t.tmp.Write(p)
+	return len(p), nil
+}
+
+var errEntryTooLarge = errors.New("result too large to cache")
+
+// copy copies the tenant output into dst
+// and the cache entry until the tenant
+// closes its end of the connection
+func (t *resultTee) copy(dst io.Writer) error {
+	_, err := io.Copy(io.MultiWriter(dst, t), t.local)
+	if err != nil {
+		// make sure the tenant doesn't
+		// block writing to a full socket
+		t.local.Close()
+	}
+	return err
+}
+
+// commit inserts the cache entry
+// if it was written successfully.
+func (t *resultTee) commit() error {
+	t.local.Close()
+	if t.err != nil {
+		t.abort()
+		return t.err
+	}
+	err := t.tmp.Close()
+	if err == nil {
+		err = os.Rename(t.tmp.Name(), filepath.Join(t.parent.dir, t.name))
+	}
+	if err != nil {
+		os.Remove(t.tmp.Name())
+		return err
+	}
+	t.parent.insert(t.name, t.size)
+	return nil
+}
+
+// abort discards the cache entry
+func (t *resultTee) abort() {
+	t.local.Close()
+	t.remote.Close()
+	t.tmp.Close()
+	os.Remove(t.tmp.Name())
+}
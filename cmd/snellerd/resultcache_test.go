@@ -0,0 +1,128 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResultCacheEvict(t *testing.T) {
+	c, err := newResultCache(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill := func(etag string, size int) {
+		tee, err := c.tee(etag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			tee.remote.Write(bytes.Repeat([]byte{'x'}, size))
+			tee.remote.Close()
+		}()
+		var out bytes.Buffer
+		if err := tee.copy(&out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Len() != size {
+			t.Fatalf("copied %d bytes, wanted %d", out.Len(), size)
+		}
+		err = tee.commit()
+		if size > int(c.maxSize) {
+			if err != errEntryTooLarge {
+				t.Fatalf("expected errEntryTooLarge; got %v", err)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(etag string, size int) {
+		t.Helper()
+		f := c.get(etag)
+		if size < 0 {
+			if f != nil {
+				f.Close()
+				t.Fatalf("%s: unexpected entry", etag)
+			}
+			return
+		}
+		if f == nil {
+			t.Fatalf("%s: missing entry", etag)
+		}
+		buf, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != strings.Repeat("x", size) {
+			t.Fatalf("%s: got %q", etag, buf)
+		}
+	}
+	fill(`"a"`, 40)
+	fill(`"b"`, 40)
+	check(`"a"`, 40) // a is now more recent than b
+	fill(`"c"`, 40)  // evicts b
+	check(`"a"`, 40)
+	check(`"b"`, -1)
+	check(`"c"`, 40)
+	fill(`"d"`, 101) // too large
+	check(`"d"`, -1)
+	check(`"a"`, 40)
+	if c.size != 80 {
+		t.Fatalf("size = %d, want 80", c.size)
+	}
+}
+
+func TestResultCacheDir(t *testing.T) {
+	root := t.TempDir()
+	c, err := newResultCache(root, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := c.name(`"a"`)
+	// files that the cache didn't write
+	// must survive a restart
+	keep := []string{
+		filepath.Join(root, name),
+		filepath.Join(root, "other"),
+		filepath.Join(c.dir, "other"),
+	}
+	for _, p := range append(keep, filepath.Join(c.dir, name), filepath.Join(c.dir, ".fill-123")) {
+		if err := os.WriteFile(p, []byte("x"), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err = newResultCache(root, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range keep {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s: %v", p, err)
+		}
+	}
+	for _, p := range []string{name, ".fill-123"} {
+		if _, err := os.Stat(filepath.Join(c.dir, p)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s not removed: %v", p, err)
+		}
+	}
+}
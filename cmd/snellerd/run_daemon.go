@@ -21,19 +21,26 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/SnellerInc/sneller/audit"
 	"github.com/SnellerInc/sneller/auth"
+	"github.com/SnellerInc/sneller/db"
 	"github.com/SnellerInc/sneller/tenant"
 )
 
 func runDaemon(args []string) {
 	daemonCmd := flag.NewFlagSet("daemon", flag.ExitOnError)
-	authEndpoint := daemonCmd.String("a", "", "authorization specification (file://, http://, https://, empty uses environment)")
+	authEndpoint := daemonCmd.String("a", "", "authorization specification (file://, http://, https://, jwt://, empty uses environment)")
 	daemonEndpoint := daemonCmd.String("e", "127.0.0.1:8000", "endpoint to listen on (REST API)")
 	remoteEndpoint := daemonCmd.String("r", "127.0.0.1:9000", "endpoint to listen on for remote requests (inter-node)")
 	peerExec := daemonCmd.String("x", "", "command to exec for fetching peers")
+	auditSpec := daemonCmd.String("audit", "", "comma-separated list of audit log sinks (stdout, file:///path, table://db/table)")
+	resultDir := daemonCmd.String("result-cache", "", "directory for caching query results (empty disables result caching)")
+	resultSize := daemonCmd.Int64("result-cache-size", 1<<30, "maximum size of the query result cache in bytes")
+	memLimit := daemonCmd.Int64("query-memory-limit", 0, "maximum memory in bytes used by a single query on each node (0 means unlimited)")
 	if daemonCmd.Parse(args) != nil {
 		os.Exit(1)
 	}
@@ -49,6 +56,9 @@ func runDaemon(args []string) {
 		tenantcmd: []string{exe, "worker"},
 		peers:     noPeers{},
 	}
+	if *memLimit > 0 {
+		server.tenantcmd = append(server.tenantcmd, "-m", strconv.FormatInt(*memLimit, 10))
+	}
 	if *peerExec != "" {
 		server.peers = &peerCmd{
 			cmd: strings.Fields(*peerExec),
@@ -87,6 +97,18 @@ func runDaemon(args []string) {
 	} else {
 		server.cachedir = "/tmp"
 	}
+	if *auditSpec != "" {
+		server.audit, err = parseAudit(*auditSpec, server.logger)
+		if err != nil {
+			server.logger.Fatal(err)
+		}
+	}
+	if *resultDir != "" {
+		server.results, err = newResultCache(*resultDir, *resultSize)
+		if err != nil {
+			server.logger.Fatal(err)
+		}
+	}
 	if server.sandbox {
 		server.logger.Println("sandboxing enabled")
 	}
@@ -115,3 +137,31 @@ func runDaemon(args []string) {
 	// Doesn't block if no connections, but will otherwise wait until the timeout deadline
 	server.Shutdown(ctx)
 }
+
+// parseAudit parses a comma-separated
+// list of audit sink specifications
+// (see audit.Parse)
+func parseAudit(spec string, logger *log.Logger) (audit.Sink, error) {
+	b := &db.Builder{
+		Align:         1024 * 1024,
+		RangeMultiple: 100,
+		GCMinimumAge:  5 * time.Minute,
+		Logf:          logger.Printf,
+	}
+	var sinks audit.Multi
+	for _, s := range strings.Split(spec, ",") {
+		sink, err := audit.Parse(strings.TrimSpace(s), b)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		if ts, ok := sink.(*audit.TableSink); ok {
+			ts.Logf = logger.Printf
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return sinks, nil
+}
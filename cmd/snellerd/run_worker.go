@@ -23,6 +23,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	"github.com/SnellerInc/sneller/db"
 	"github.com/SnellerInc/sneller/expr"
@@ -51,6 +52,7 @@ func runWorker(args []string) {
 	workerTenant := workerCmd.String("t", "", "tenant identifier")
 	workerControlSocket := workerCmd.Int("c", -1, "control socket")
 	eventfd := workerCmd.Int("e", -1, "eventfd")
+	memLimit := workerCmd.Int64("m", 0, "per-query memory limit in bytes")
 	if workerCmd.Parse(args) != nil {
 		os.Exit(1)
 	}
@@ -65,6 +67,7 @@ func runWorker(args []string) {
 		panic("no eventfd passed")
 	}
 	logger := log.New(os.Stderr, "tid:"+*workerTenant+" ", 0)
+	plan.MemoryLimit = *memLimit
 
 	// capture vm errors associated with this tenant
 	vm.Errorf = logger.Printf
@@ -103,6 +106,14 @@ func runWorker(args []string) {
 		} else {
 			env.cache = dcache.New(cachedir, env.post)
 			env.cache.Logger = logger
+			// large sorts and aggregates spill
+			// into the tenant's cache directory
+			spilldir := filepath.Join(cachedir, "spill")
+			if err := os.MkdirAll(spilldir, 0750); err != nil {
+				logger.Printf("cannot create spill dir: %s", err)
+			} else {
+				vm.SpillDir = spilldir
+			}
 		}
 	}
 	err = tnproto.Serve(uc, &env)
@@ -157,12 +168,45 @@ var _ plan.UploaderDecoder = (*tenantEnv)(nil)
 
 // DecodeUploader implements plan.UploaderDecoder.
 func (t *tenantEnv) DecodeUploader(st *ion.Symtab, buf []byte) (plan.UploadFS, error) {
-	if testmode {
+	fields := encodedFields(st, buf)
+	switch {
+	case testmode || fields["root"]:
 		return db.DecodeDirFS(st, buf)
+	case fields["container"]:
+		return db.DecodeAzureFS(st, buf)
+	case fields["gcs"]:
+		return db.DecodeGCSFS(st, buf)
 	}
 	return db.DecodeS3FS(st, buf)
 }
 
+// encodedFields returns the set of fields
+// in the encoding of an UploadFS so that
+// we can determine which type was encoded
+func encodedFields(st *ion.Symtab, buf []byte) map[string]bool {
+	found := make(map[string]bool)
+	ion.UnpackStruct(st, buf, func(field string, _ []byte) error {
+		found[field] = true
+		return nil
+	})
+	return found
+}
+
+var _ tnproto.CacheStatter = (*tenantEnv)(nil)
+
+// CacheStats implements tnproto.CacheStatter.
+func (t *tenantEnv) CacheStats() tnproto.CacheStats {
+	if t.cache == nil {
+		return tnproto.CacheStats{}
+	}
+	return tnproto.CacheStats{
+		Hits:     t.cache.Hits(),
+		Misses:   t.cache.Misses(),
+		Failures: t.cache.Failures(),
+		LiveHits: int64(t.cache.LiveHits()),
+	}
+}
+
 func (e *tenantEnv) post() {
 	e.evfd.Write(e.onebuf[:])
 }
@@ -178,9 +222,9 @@ func (h *tenantHandle) Open() (vm.Table, error) {
 		panic("shouldn't have called filterHandle.Open()")
 	}
 	segs := make([]dcache.Segment, 0, len(lst.Contents))
-	var flt filter
+	var flt blockfmt.Filter
 	if fh.filter != nil {
-		if m, ok := compileFilter(fh.filter); ok {
+		if m, ok := blockfmt.CompileFilter(fh.filter); ok {
 			flt = m
 		}
 	}
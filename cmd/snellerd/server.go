@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/SnellerInc/sneller/audit"
 	"github.com/SnellerInc/sneller/auth"
 	"github.com/SnellerInc/sneller/plan"
 	"github.com/SnellerInc/sneller/tenant"
@@ -29,6 +30,13 @@ import (
 type cachedEnv interface {
 	plan.Env
 	CacheValues() ([]byte, time.Time)
+	// Tables returns the db.table names
+	// of the tables referenced during planning
+	Tables() []string
+	// ScanSize returns the total size of the tables
+	// returned from Stat during planning and the maximum
+	// number of bytes scanned after sparse indexing
+	ScanSize() (total, maxscan int64, err error)
 }
 
 type contextKey struct {
@@ -52,6 +60,22 @@ type server struct {
 	// listing peers, we fall back to
 	// this list (assuming it is non-nil)
 
+	// audit, if non-nil, receives
+	// an audit event for each query
+	audit audit.Sink
+
+	// metrics are the statistics
+	// exposed via /metrics
+	metrics metrics
+
+	// admit performs per-tenant
+	// admission control for queries
+	admit admission
+
+	// results, if non-nil, caches query
+	// results by their ETag
+	results *resultCache
+
 	// split size used to configure the splitter,
 	// can be left 0 to use the default
 	splitSize int64
@@ -70,6 +94,10 @@ func (s *server) Close() error {
 	s.manager.Stop()
 	s.peers.Stop()
 	s.srv.Close()
+	if s.audit != nil {
+		s.audit.Close()
+		s.audit = nil
+	}
 	return nil
 }
 
@@ -78,7 +106,12 @@ func (s *server) Shutdown(ctx context.Context) error {
 		s.manager.Stop()
 		s.manager = nil
 	}
-	return s.srv.Shutdown(ctx)
+	err := s.srv.Shutdown(ctx)
+	if s.audit != nil {
+		s.audit.Close()
+		s.audit = nil
+	}
+	return err
 }
 
 func (s *server) handler() *http.ServeMux {
@@ -88,7 +121,10 @@ func (s *server) handler() *http.ServeMux {
 	r.HandleFunc("/executeQuery", s.handle(s.executeQueryHandler, http.MethodHead, http.MethodGet, http.MethodPost))
 	r.HandleFunc("/databases", s.handle(s.databasesHandler, http.MethodGet))
 	r.HandleFunc("/tables", s.handle(s.tablesHandler, http.MethodGet))
+	r.HandleFunc("/functions", s.handle(s.functionsHandler, http.MethodGet))
 	r.HandleFunc("/inputs", s.handle(s.inputsHandler, http.MethodGet))
+	r.HandleFunc("/schema", s.handle(s.schemaHandler, http.MethodGet))
+	r.HandleFunc("/metrics", s.handle(s.metricsHandler, http.MethodGet))
 	return r
 }
 
@@ -21,6 +21,7 @@ import (
 	"github.com/SnellerInc/sneller/expr"
 	"github.com/SnellerInc/sneller/expr/blob"
 	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
 	"github.com/SnellerInc/sneller/plan"
 	"github.com/SnellerInc/sneller/tenant/tnproto"
 	"github.com/dchest/siphash"
@@ -64,7 +65,7 @@ func (s *splitter) Split(table expr.Node, handle plan.TableHandle) (plan.Subtabl
 	}
 	flt := fh.compiled
 	if flt == nil && fh.filter != nil {
-		flt, _ = compileFilter(fh.filter)
+		flt, _ = blockfmt.CompileFilter(fh.filter)
 	}
 	splits := make([]split, len(s.peers))
 	for i := range splits {
@@ -143,17 +144,44 @@ func compact(splits []split) []split {
 // maxscan calculates the max scan size of a blob,
 // optionally with filter f applied. If this returns 0,
 // the entire blob is excluded by the filter.
-func maxscan(pc *blob.CompressedPart, f filter) (scan int64) {
+func maxscan(pc *blob.CompressedPart, f blockfmt.Filter) (scan int64) {
 	t := pc.Parent.Trailer
 	blocks := t.Blocks[pc.StartBlock:pc.EndBlock]
 	for i := range blocks {
-		if f == nil || f(&t.Sparse, pc.StartBlock+i) != never {
+		if f == nil || f(&t.Sparse, pc.StartBlock+i) != blockfmt.Never {
 			scan += int64(blocks[i].Chunks) << t.BlockShift
 		}
 	}
 	return scan
 }
 
+// scanSize returns the total size of the blobs in fh
+// and the max scan size after the filter is applied
+func (fh *filterHandle) scanSize() (total, scan int64, err error) {
+	flt := fh.compiled
+	if flt == nil && fh.filter != nil {
+		flt, _ = blockfmt.CompileFilter(fh.filter)
+	}
+	for _, b := range fh.blobs.Contents {
+		c, ok := b.(*blob.Compressed)
+		if !ok {
+			stat, err := b.Stat()
+			if err != nil {
+				return 0, 0, err
+			}
+			total += stat.Size
+			scan += stat.Size
+			continue
+		}
+		total += c.Trailer.Decompressed()
+		scan += maxscan(&blob.CompressedPart{
+			Parent:   c,
+			EndBlock: len(c.Trailer.Blocks),
+		}, flt)
+	}
+	return total, scan, nil
+}
+
 // partition returns the index of the peer which should
 // handle the specified blob.
 func (s *splitter) partition(b blob.Interface) (int, error) {
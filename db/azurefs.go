@@ -0,0 +1,127 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SnellerInc/sneller/azure"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+var _ FS = &AzureFS{}
+
+// AzureFS is an FS implementation
+// that is backed by an Azure storage container.
+type AzureFS struct {
+	blockfmt.AzureFS
+}
+
+// encodedKeyLifetime is the lifetime of the
+// shared access signature produced by (*AzureFS).Encode;
+// it only needs to outlive a single query
+const encodedKeyLifetime = 24 * time.Hour
+
+// URL implements db.URL
+func (a *AzureFS) URL(name string, info fs.FileInfo, etag string) (string, error) {
+	return azure.URL(a.Key, a.Container, name)
+}
+
+// Encode implements plan.UploadFS
+//
+// The shared key of the storage account is
+// never encoded; instead, the encoded key is a
+// shared access signature that is scoped to the container.
+func (a *AzureFS) Encode(dst *ion.Buffer, st *ion.Symtab) error {
+	k, err := a.Key.ContainerKey(a.Container, "racwdl", encodedKeyLifetime)
+	if err != nil {
+		return err
+	}
+	dst.BeginStruct(-1)
+	dst.BeginField(st.Intern("key"))
+	if err := k.Encode(st, dst); err != nil {
+		return err
+	}
+	dst.BeginField(st.Intern("container"))
+	dst.WriteString(a.Container)
+	dst.EndStruct()
+	return nil
+}
+
+// DecodeAzureFS decodes the output of (*AzureFS).Encode.
+func DecodeAzureFS(st *ion.Symtab, buf []byte) (*AzureFS, error) {
+	a := &AzureFS{}
+	_, err := ion.UnpackStruct(st, buf, func(field string, buf []byte) error {
+		var err error
+		switch field {
+		case "key":
+			a.Key, err = azure.DecodeKey(st, buf)
+		case "container":
+			a.Container, _, err = ion.ReadString(buf)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if a.Key == nil {
+		return nil, fmt.Errorf("missing key")
+	}
+	if a.Container == "" {
+		return nil, fmt.Errorf("missing container")
+	}
+	return a, nil
+}
+
+// AzureResolver is a resolver that expects only az:// schemes.
+type AzureResolver struct {
+	// Key is the key used to access
+	// every container in the storage account.
+	Key *azure.Key
+	// Client, if non-nil, sets the default
+	// client used by returned AzureFS objects.
+	Client *http.Client
+}
+
+// Split implements Resolver.Split
+func (a *AzureResolver) Split(pattern string) (InputFS, string, error) {
+	if !strings.HasPrefix(pattern, "az://") {
+		return nil, "", badPattern(pattern)
+	}
+	pattern = strings.TrimPrefix(pattern, "az://")
+	i := strings.IndexByte(pattern, '/')
+	if i == len(pattern)-1 || i <= 0 {
+		return nil, "", badPattern(pattern)
+	}
+	container := pattern[:i]
+	rest := pattern[i+1:]
+	if !azure.ValidContainer(container) {
+		return nil, "", badPattern(pattern)
+	}
+	return &AzureFS{
+		AzureFS: blockfmt.AzureFS{
+			ContainerFS: azure.ContainerFS{
+				Key:       a.Key,
+				Container: container,
+				Client:    a.Client,
+			},
+		},
+	}, rest, nil
+}
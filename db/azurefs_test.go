@@ -0,0 +1,93 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/SnellerInc/sneller/azure"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func TestSchemeResolver(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte("secret"))
+	akey, err := azure.SharedKey("", "account", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := SchemeResolver{
+		"az": &AzureResolver{Key: akey},
+	}
+	good := []struct {
+		pattern, prefix, rest string
+	}{
+		{"az://container/path/*.json", "az://container/", "path/*.json"},
+	}
+	for i := range good {
+		ifs, rest, err := r.Split(good[i].pattern)
+		if err != nil {
+			t.Fatalf("%s: %s", good[i].pattern, err)
+		}
+		if ifs.Prefix() != good[i].prefix {
+			t.Errorf("%s: prefix %q", good[i].pattern, ifs.Prefix())
+		}
+		if rest != good[i].rest {
+			t.Errorf("%s: rest %q", good[i].pattern, rest)
+		}
+	}
+	bad := []string{
+		"s3://bucket/path/*.json",
+		"file://path/*.json",
+		"path/*.json",
+		"az://Bad_Container/x",
+	}
+	for _, pat := range bad {
+		_, _, err := r.Split(pat)
+		if !errors.Is(err, ErrBadPattern) {
+			t.Errorf("%s: unexpected error %v", pat, err)
+		}
+	}
+}
+
+func TestEncodeAzureFS(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte("azure secret"))
+	akey, err := azure.SharedKey("http://127.0.0.1:10000/account", "account", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	afs, _, err := (&AzureResolver{Key: akey}).Split("az://container/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st ion.Symtab
+	var buf ion.Buffer
+	err = afs.(*AzureFS).Encode(&buf, &st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte(secret)) {
+		t.Fatal("encoded AzureFS contains the shared key")
+	}
+	out, err := DecodeAzureFS(&st, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Container != "container" || out.Key.SAS == "" || out.Key.BaseURI != akey.BaseURI {
+		t.Fatalf("unexpected result %+v", out)
+	}
+}
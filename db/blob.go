@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io/fs"
 	"path"
+	"path/filepath"
 	"time"
 
 	"github.com/SnellerInc/sneller/date"
@@ -71,6 +72,20 @@ func noIfMatch(fs FS) bool {
 	return false
 }
 
+// localRoot returns the absolute root directory
+// of src if it produces file:// URLs, or "" otherwise
+func localRoot(src FS) string {
+	d, ok := src.(*DirFS)
+	if !ok || !d.Local {
+		return ""
+	}
+	root, err := filepath.Abs(d.Root)
+	if err != nil {
+		return ""
+	}
+	return root
+}
+
 func keepAny(t *blockfmt.Trailer, keep func(*blockfmt.SparseIndex, int) bool) bool {
 	for i := range t.Blocks {
 		if keep(&t.Sparse, i) {
@@ -131,6 +146,9 @@ func descToBlob(src FS, b *blockfmt.Descriptor) (blob.Interface, error) {
 			// don't send the If-Match header,
 			// since http.FileServer doesn't handle it
 			UnsafeNoIfMatch: noIfMatch(src),
+			// file:// URLs are only readable
+			// under the root of a local DirFS
+			Root: localRoot(src),
 			Info: blob.Info{
 				// Note: blob.URL.ReadAt automatically
 				// inserts the If-Match header to ensure
@@ -0,0 +1,281 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"fmt"
+	"io"
+	prand "math/rand"
+	"path"
+	"time"
+
+	"github.com/SnellerInc/sneller/compr"
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+// Compact merges groups of adjacent objects
+// in a table into larger objects according to
+// a tiered, time-aware compaction policy.
+// The rows in each new object are sorted by the
+// sort key in the table definition (see Definition.SortKey),
+// or otherwise by the primary timestamp of the table
+// (the first field in the sparse index of the most recent object).
+//
+// Compaction does not change the contents of the table,
+// and the old objects are queued for deletion in
+// blockfmt.Index.ToDelete.
+// Compact should not be run concurrently with
+// other operations that update the same table.
+func (b *Builder) Compact(who Tenant, db, table string) error {
+	st, err := b.open(db, table, who)
+	if err != nil {
+		return err
+	}
+	return st.compactIndex()
+}
+
+// compactIndex opens, compacts, and
+// writes out the index for st
+func (st *tableState) compactIndex() error {
+	idx, err := st.index()
+	if err != nil {
+		return err
+	}
+	if idx.Scanning {
+		return fmt.Errorf("cannot compact table %s/%s: still scanning", st.db, st.table)
+	}
+	st.preciseGC(idx)
+	changed, err := st.compact(idx)
+	if err != nil || !changed {
+		return err
+	}
+	err = st.flush(idx)
+	if err == nil {
+		err = st.runGC(idx)
+	}
+	return err
+}
+
+// maybeCompact compacts the table
+// with probability CompactLikelihood
+func (st *tableState) maybeCompact() error {
+	if prand.Intn(100) >= st.conf.CompactLikelihood {
+		return nil
+	}
+	return st.compactIndex()
+}
+
+// compact compacts the objects in idx
+// and returns true if idx was modified
+func (st *tableState) compact(idx *blockfmt.Index) (bool, error) {
+	idx.Inputs.Backing = st.ofs
+	key := st.sortKey()
+	changed := false
+	merge := func(lst []blockfmt.Descriptor) ([]blockfmt.Descriptor, bool, error) {
+		keep, groups := st.conf.decideMerge(lst)
+		if len(groups) == 0 {
+			return lst, false, nil
+		}
+		for i := range groups {
+			d, err := st.compactGroup(groups[i], key)
+			if err != nil {
+				return nil, false, err
+			}
+			keep = append(keep, *d)
+			st.addSchema(d)
+			for j := range groups[i] {
+				st.subSchema(&groups[i][j])
+				idx.ToDelete = append(idx.ToDelete, blockfmt.Quarantined{
+					Path:   groups[i][j].Path,
+					Expiry: date.Now().Add(st.conf.GCMinimumAge),
+				})
+			}
+		}
+		timeSort(keep, primaryTimestamp(keep))
+		changed = true
+		return keep, true, nil
+	}
+	dir := path.Join("db", st.db, st.table)
+	err := idx.RewriteIndirect(st.ofs, dir, nil, merge, st.conf.GCMinimumAge)
+	if err != nil {
+		return false, err
+	}
+	idx.Inline, _, err = merge(idx.Inline)
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		idx.Created = date.Now().Truncate(time.Microsecond)
+	}
+	return changed, nil
+}
+
+// sortKey returns the sort key for the table,
+// or nil if the table doesn't have one
+func (st *tableState) sortKey() [][]string {
+	def, err := st.def()
+	if err != nil {
+		// tables populated with Append
+		// don't necessarily have a definition
+		return nil
+	}
+	return def.sortPaths()
+}
+
+// fieldOf returns the value at
+// the given path inside d
+func fieldOf(d ion.Datum, p []string) (ion.Datum, bool) {
+	for i := range p {
+		s, ok := d.(*ion.Struct)
+		if !ok {
+			return nil, false
+		}
+		f := s.FieldByName(p[i])
+		if f == nil {
+			return nil, false
+		}
+		d = f.Value
+	}
+	return d, true
+}
+
+// compactGroup writes a new object containing the
+// rows in lst sorted by key, or by their primary
+// timestamp if key is empty
+//
+// The rows are streamed from each object in lst
+// one block at a time into a rowSorter, so at most
+// Builder.MaxSortBytes of rows are held in memory.
+func (st *tableState) compactGroup(lst []blockfmt.Descriptor, key [][]string) (*blockfmt.Descriptor, error) {
+	if key == nil {
+		if ts := primaryTimestamp(lst); ts != nil {
+			key = [][]string{ts}
+		}
+	}
+	var walk [][]string
+	for i := range lst {
+		walk = appendPaths(walk, lst[i].Trailer.Sparse.FieldPaths())
+	}
+
+	fp := path.Join("db", st.db, st.table, "packed-"+uuid()+".ion.zst")
+	out, err := st.ofs.Create(fp)
+	if err != nil {
+		return nil, err
+	}
+	w := &blockfmt.CompressionWriter{
+		Output:            out,
+		Comp:              compr.Compression("zstd"),
+		InputAlign:        st.conf.align(),
+		MinChunksPerBlock: st.conf.flushMeta() / (st.conf.align() * 2),
+	}
+	sw := &blockfmt.SchemaWriter{W: w}
+	cn := ion.Chunker{
+		W:              sw,
+		Align:          w.InputAlign,
+		RangeAlign:     st.conf.flushMeta(),
+		WalkTimeRanges: walk,
+	}
+	var dst io.Writer = &cn
+	var rs *rowSorter
+	if key != nil {
+		rs = st.rowSorter(key)
+		defer rs.Close()
+		dst = rs
+	}
+	for i := range lst {
+		err = st.collectRows(&lst[i], dst)
+		if err != nil {
+			err = fmt.Errorf("reading %s: %w", lst[i].Path, err)
+			break
+		}
+	}
+	if err == nil && rs != nil {
+		err = rs.Emit(&cn)
+	}
+	if err == nil {
+		err = cn.Flush()
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		abort(out)
+		return nil, err
+	}
+	st.conf.logf("table %s: wrote %d object(s) as %s", st.table, len(lst), fp)
+	return st.descriptor(fp, out, &w.Trailer, sw.Schema())
+}
+
+// collectRows writes the decompressed
+// rows in d to dst one block at a time
+func (st *tableState) collectRows(d *blockfmt.Descriptor, dst io.Writer) error {
+	f, err := st.openPacked(d)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var dec blockfmt.Decoder
+	dec.Set(d.Trailer, len(d.Trailer.Blocks))
+	_, err = dec.Copy(dst, io.LimitReader(f, d.Trailer.Offset))
+	return err
+}
+
+func appendPaths(dst, src [][]string) [][]string {
+outer:
+	for i := range src {
+		for j := range dst {
+			if samePath(dst[j], src[i]) {
+				continue outer
+			}
+		}
+		dst = append(dst, src[i])
+	}
+	return dst
+}
+
+func samePath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// descriptor returns the descriptor for
+// a newly-written packed object whose
+// rows are described by s
+func (st *tableState) descriptor(fp string, out blockfmt.Uploader, t *blockfmt.Trailer, s *blockfmt.Schema) (*blockfmt.Descriptor, error) {
+	etag, lastmod, err := getInfo(st.ofs, fp, out)
+	if err != nil {
+		return nil, err
+	}
+	st.setSchema(fp, s)
+	return &blockfmt.Descriptor{
+		ObjectInfo: blockfmt.ObjectInfo{
+			Path:         fp,
+			LastModified: date.FromTime(lastmod),
+			ETag:         etag,
+			Format:       blockfmt.Version,
+			Size:         out.Size(),
+		},
+		Trailer: t,
+	}, nil
+}
@@ -0,0 +1,337 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+type timedRow struct {
+	when date.Time
+	ok   bool
+	key  [][]byte
+	row  ion.Datum
+}
+
+// rowCollector is an io.Writer that
+// accepts decompressed chunks and collects
+// the rows within them
+type rowCollector struct {
+	st   ion.Symtab
+	ts   []string
+	key  [][]string
+	rows []timedRow
+
+	keyst  ion.Symtab
+	keybuf ion.Buffer
+}
+
+func (r *rowCollector) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if ion.TypeOf(p) == ion.NullType {
+			// skip nop pad
+			p = p[ion.SizeOf(p):]
+			continue
+		}
+		d, rest, err := ion.ReadDatum(&r.st, p)
+		if err != nil {
+			return 0, err
+		}
+		p = rest
+		if d == nil {
+			continue
+		}
+		when, ok := timestampOf(d, r.ts)
+		r.rows = append(r.rows, timedRow{
+			when: when,
+			ok:   ok,
+			key:  keyOf(r.key, d, &r.keyst, &r.keybuf),
+			row:  d,
+		})
+	}
+	return n, nil
+}
+
+// timestampOf returns the value of the
+// timestamp at the given path inside d
+func timestampOf(d ion.Datum, p []string) (date.Time, bool) {
+	if p == nil {
+		return date.Time{}, false
+	}
+	v, _ := fieldOf(d, p)
+	if ts, ok := v.(ion.Timestamp); ok {
+		return date.Time(ts), true
+	}
+	return date.Time{}, false
+}
+
+// readTimes returns the values of the "when"
+// field in each object in idx in order
+func readTimes(t *testing.T, dfs *DirFS, idx *blockfmt.Index) [][]date.Time {
+	descs, err := idx.Indirect.Search(dfs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	descs = append(descs, idx.Inline...)
+	out := make([][]date.Time, len(descs))
+	for i := range descs {
+		f, err := dfs.Open(descs[i].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc := rowCollector{ts: []string{"when"}}
+		var d blockfmt.Decoder
+		d.Set(descs[i].Trailer, len(descs[i].Trailer.Blocks))
+		_, err = d.Copy(&rc, io.LimitReader(f, descs[i].Trailer.Offset))
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range rc.rows {
+			if !rc.rows[j].ok {
+				t.Fatalf("row %v has no timestamp", rc.rows[j].row)
+			}
+			out[i] = append(out[i], rc.rows[j].when)
+		}
+	}
+	return out
+}
+
+func TestCompact(t *testing.T) {
+	checkFiles(t)
+	tmpdir := t.TempDir()
+	dfs := NewDirFS(tmpdir)
+	defer dfs.Close()
+	dfs.Log = t.Logf
+	owner := newTenant(dfs)
+	b := Builder{
+		Align: 1024,
+		Logf:  t.Logf,
+		// don't merge while appending
+		MinMergeSize: 1,
+		GCMinimumAge: 0,
+	}
+	var buf bytes.Buffer
+	json := blockfmt.SuffixToFormat[".json"]
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	const objects, rows = 6, 100
+	for i := 0; i < objects; i++ {
+		buf.Reset()
+		for j := 0; j < rows; j++ {
+			// interleave the time ranges of each
+			// object and write rows out of order
+			when := start.Add(time.Duration(j*objects+i) * time.Minute)
+			if j%2 == 0 {
+				when = when.Add(time.Duration(rows*objects) * time.Minute)
+			}
+			fmt.Fprintf(&buf, "{\"when\": %q, \"obj\": %d, \"row\": %d}\n", when.Format(time.RFC3339), i, j)
+		}
+		name := fmt.Sprintf("input%d.json", i)
+		err := os.WriteFile(filepath.Join(tmpdir, name), buf.Bytes(), 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lst, err := blockfmt.CollectGlob(dfs, func(string) blockfmt.RowFormat { return json() }, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = b.Append(owner, "default", "events", lst)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx, err := OpenIndex(dfs, "default", "events", owner.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Objects() != objects {
+		t.Fatalf("%d objects before compaction", idx.Objects())
+	}
+	quarantined := len(idx.ToDelete)
+
+	// now everything is in tier 0;
+	// force the rows to be sorted in
+	// several runs that have to be merged
+	b.MinMergeSize = 0
+	b.MaxSortBytes = 4096
+	err = b.Compact(owner, "default", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err = OpenIndex(dfs, "default", "events", owner.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Objects() != 1 {
+		t.Fatalf("%d objects after compaction", idx.Objects())
+	}
+	if len(idx.ToDelete) < quarantined+objects {
+		t.Errorf("%d objects quarantined; expected at least %d", len(idx.ToDelete), quarantined+objects)
+	}
+	times := readTimes(t, dfs, idx)
+	if len(times[0]) != objects*rows {
+		t.Fatalf("%d rows after compaction", len(times[0]))
+	}
+	for i := 1; i < len(times[0]); i++ {
+		if times[0][i].Before(times[0][i-1]) {
+			t.Fatalf("row %d: %s before %s", i, times[0][i], times[0][i-1])
+		}
+	}
+	// the time index should still be present
+	min, max, ok := idx.TimeRange(&expr.Path{First: "when"})
+	if !ok {
+		t.Fatal("no time range for 'when' after compaction")
+	}
+	if !min.Equal(times[0][0]) || !max.Equal(times[0][len(times[0])-1]) {
+		t.Errorf("time range %s to %s", min, max)
+	}
+
+	// a second compaction does nothing
+	owner.ro = true
+	err = b.Compact(owner, "default", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSortKey(t *testing.T) {
+	checkFiles(t)
+	tmpdir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(tmpdir, "in"), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dfs := NewDirFS(tmpdir)
+	defer dfs.Close()
+	dfs.Log = t.Logf
+	owner := newTenant(dfs)
+	err = WriteDefinition(dfs, "default", &Definition{
+		Name:    "orders",
+		Inputs:  []Input{{Pattern: "file://in/*.json"}},
+		SortKey: []string{"customer", "when"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := Builder{
+		Align: 1024,
+		Logf:  t.Logf,
+		// don't merge while syncing
+		MinMergeSize: 1,
+		// sort in several runs
+		MaxSortBytes: 4096,
+	}
+	key := [][]string{{"customer"}, {"when"}}
+	st, err := b.open("default", "orders", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	checkSorted := func(want, rows int) {
+		t.Helper()
+		idx, err := OpenIndex(dfs, "default", "orders", owner.Key())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if idx.Objects() != want {
+			t.Fatalf("have %d objects; wanted %d", idx.Objects(), want)
+		}
+		total := 0
+		for i := range idx.Inline {
+			rc := rowCollector{key: key}
+			err = st.collectRows(&idx.Inline[i], &rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j := 1; j < len(rc.rows); j++ {
+				if keyLess(rc.rows[j].key, rc.rows[j-1].key) {
+					t.Fatalf("object %d row %d out of order", i, j)
+				}
+			}
+			total += len(rc.rows)
+
+			// the time ranges are collected
+			// from the sorted rows
+			sparse := &idx.Inline[i].Trailer.Sparse
+			if fp := sparse.FieldPaths(); !slices.ContainsFunc(fp, func(p []string) bool {
+				return slices.Equal(p, []string{"when"})
+			}) {
+				t.Fatalf("object %d: field paths %v", i, fp)
+			}
+			min, max, ok := sparse.MinMax(&expr.Path{First: "when"})
+			if !ok {
+				t.Fatalf("object %d: no range for when", i)
+			}
+			if !min.Equal(date.FromTime(start)) || !max.Equal(date.FromTime(start.Add(199*time.Hour))) {
+				t.Errorf("object %d: range [%s, %s]", i, min, max)
+			}
+		}
+		if total != rows {
+			t.Errorf("%d rows total", total)
+		}
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < 2; i++ {
+		buf.Reset()
+		for j := 0; j < 200; j++ {
+			when := start.Add(time.Duration((j*37)%200) * time.Hour)
+			fmt.Fprintf(&buf, "{\"customer\": \"c%d\", \"when\": %q, \"n\": %d}\n", (j*7)%10, when.Format(time.RFC3339), j)
+		}
+		name := filepath.Join(tmpdir, "in", fmt.Sprintf("%d.json", i))
+		err = os.WriteFile(name, buf.Bytes(), 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = b.Sync(owner, "default", "*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSorted(i+1, 200*(i+1))
+		// the rows are sorted before they are
+		// written, so nothing is written twice
+		idx, err := OpenIndex(dfs, "default", "orders", owner.Key())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range idx.ToDelete {
+			if strings.HasPrefix(path.Base(q.Path), "packed-") {
+				t.Errorf("unexpected packed object to delete: %s", q.Path)
+			}
+		}
+	}
+	// compaction preserves the sort order
+	b.MinMergeSize = 0
+	err = b.Compact(owner, "default", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSorted(1, 400)
+}
@@ -21,6 +21,7 @@ import (
 	"io"
 	"io/fs"
 	"path"
+	"strings"
 
 	"github.com/SnellerInc/sneller/fsutil"
 
@@ -58,6 +59,35 @@ type Definition struct {
 	Name string `json:"name"`
 	// Inputs is the list of inputs that comprise the table.
 	Inputs []Input `json:"input"`
+	// SortKey, if non-empty, is the list of paths
+	// (with components separated by '.') by which
+	// rows are sorted within each object that is
+	// produced by ingest or compaction.
+	// Rows are compared using PartiQL ordering semantics
+	// with NULL and MISSING values sorted last.
+	SortKey []string `json:"sort_key,omitempty"`
+	// Schema, if non-nil, is enforced on
+	// each row that is ingested into the table.
+	// Rows that violate the schema are diverted
+	// to a dead-letter table. (See RowSchema.)
+	Schema *RowSchema `json:"schema,omitempty"`
+	// Rollups is the list of aggregations
+	// that are maintained in companion tables
+	// as rows are ingested. (See Rollup.)
+	Rollups []Rollup `json:"rollups,omitempty"`
+}
+
+// sortPaths returns the components
+// of each path in d.SortKey
+func (d *Definition) sortPaths() [][]string {
+	if len(d.SortKey) == 0 {
+		return nil
+	}
+	o := make([][]string, len(d.SortKey))
+	for i := range d.SortKey {
+		o[i] = strings.Split(d.SortKey[i], ".")
+	}
+	return o
 }
 
 func drop(lst []fsutil.NamedFile) {
@@ -171,6 +201,25 @@ type Resolver interface {
 	Split(pattern string) (InputFS, string, error)
 }
 
+// SchemeResolver is a Resolver that
+// dispatches on the scheme of a pattern
+// (the text preceding "://") to one of
+// several other Resolvers.
+type SchemeResolver map[string]Resolver
+
+// Split implements Resolver.Split
+func (s SchemeResolver) Split(pattern string) (InputFS, string, error) {
+	i := strings.Index(pattern, "://")
+	if i <= 0 {
+		return nil, "", badPattern(pattern)
+	}
+	r, ok := s[pattern[:i]]
+	if !ok {
+		return nil, "", badPattern(pattern)
+	}
+	return r.Split(pattern)
+}
+
 var (
 	// ErrBadPattern should be returned by Resolver.Split
 	// when it encounters an invalid pattern.
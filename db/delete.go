@@ -0,0 +1,268 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+// RowFilter selects the rows that are
+// removed from a table by Builder.Delete.
+type RowFilter interface {
+	// Match indicates whether the rows in
+	// block n of an object with the sparse index s
+	// always, maybe, or never match the filter.
+	// Objects in which no block may match
+	// are not rewritten.
+	Match(s *blockfmt.SparseIndex, n int) blockfmt.Ternary
+	// Filter writes the rows in the ion chunk
+	// src that do not match the filter to dst
+	// and returns the number of rows that
+	// were kept and removed, respectively.
+	Filter(dst io.Writer, src []byte) (kept, removed int, err error)
+}
+
+// Delete removes the rows selected by rf
+// from a table and returns the number of
+// rows that were removed.
+//
+// Only the packed objects that contain rows
+// selected by rf are rewritten; objects whose
+// blocks may match according to the sparse index
+// are scanned for a matching row first. The new objects
+// replace the old ones in the index with a single
+// write of the index once every object has been
+// rewritten, so queries observe either all or none
+// of the deletions. The old objects are queued for
+// deletion in blockfmt.Index.ToDelete.
+//
+// Delete does not modify the input objects
+// from which the table was built, so the deleted
+// rows re-appear if the table is rebuilt from its
+// inputs. Delete should not be run concurrently with
+// other operations that update the same table.
+func (b *Builder) Delete(who Tenant, db, table string, rf RowFilter) (int64, error) {
+	st, err := b.open(db, table, who)
+	if err != nil {
+		return 0, err
+	}
+	idx, err := st.index()
+	if err != nil {
+		return 0, err
+	}
+	if idx.Scanning {
+		return 0, fmt.Errorf("db.Builder.Delete: table %s/%s is still being scanned", db, table)
+	}
+	idx.Inputs.Backing = st.ofs
+
+	removed := int64(0)
+	quarantined := len(idx.ToDelete)
+	quarantine := func(p string, age time.Duration) {
+		idx.ToDelete = append(idx.ToDelete, blockfmt.Quarantined{
+			Path:   p,
+			Expiry: date.Now().Add(age),
+		})
+	}
+	rewrite := func(lst []blockfmt.Descriptor) ([]blockfmt.Descriptor, bool, error) {
+		// note: out never gets ahead of lst[i]
+		out := lst[:0]
+		changed := false
+		for i := range lst {
+			nd, kept, n, err := st.deleteFrom(&lst[i], rf)
+			if err != nil {
+				return nil, false, err
+			}
+			if nd == nil {
+				out = append(out, lst[i])
+				continue
+			}
+			if n == 0 {
+				// nothing actually matched;
+				// discard the copy we just made
+				quarantine(nd.Path, 0)
+				out = append(out, lst[i])
+				continue
+			}
+			changed = true
+			removed += n
+			quarantine(lst[i].Path, b.GCMinimumAge)
+			st.subSchema(&lst[i])
+			if kept == 0 {
+				quarantine(nd.Path, 0)
+				continue
+			}
+			st.addSchema(nd)
+			out = append(out, *nd)
+		}
+		return out, changed, nil
+	}
+	overlap := func(s *blockfmt.SparseIndex, n int) bool {
+		return rf.Match(s, n) != blockfmt.Never
+	}
+	dir := path.Join("db", db, table)
+	err = idx.RewriteIndirect(st.ofs, dir, overlap, rewrite, b.GCMinimumAge)
+	if err != nil {
+		return 0, err
+	}
+	idx.Inline, _, err = rewrite(idx.Inline)
+	if err != nil {
+		return 0, err
+	}
+	if removed == 0 && len(idx.ToDelete) == quarantined {
+		return 0, nil
+	}
+	b.logf("table %s/%s: deleted %d rows", db, table, removed)
+	idx.Created = date.Now().Truncate(time.Microsecond)
+	return removed, st.flush(idx)
+}
+
+// deleteFrom writes a copy of the object described
+// by d without the rows selected by rf.
+// If no blocks in d can match rf, deleteFrom returns
+// a nil descriptor. Otherwise, it returns the descriptor
+// of the new object and the number of rows kept and removed.
+func (st *tableState) deleteFrom(d *blockfmt.Descriptor, rf RowFilter) (*blockfmt.Descriptor, int64, int64, error) {
+	t := d.Trailer
+	match := make([]blockfmt.Ternary, len(t.Blocks))
+	any, always := false, false
+	for i := range t.Blocks {
+		match[i] = rf.Match(&t.Sparse, i)
+		if match[i] != blockfmt.Never {
+			any = true
+		}
+		if match[i] == blockfmt.Always {
+			always = true
+		}
+	}
+	if !any {
+		return nil, 0, 0, nil
+	}
+	if !always {
+		// the sparse index cannot tell us whether
+		// any rows match, so look for one before
+		// writing a new object
+		found, err := st.anyMatch(d, match, rf)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("scanning %s: %w", d.Path, err)
+		}
+		if !found {
+			return nil, 0, 0, nil
+		}
+	}
+	f, err := st.openPacked(d)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("opening %s: %w", d.Path, err)
+	}
+	defer f.Close()
+
+	fp := path.Join("db", st.db, st.table, "packed-"+uuid()+".ion.zst")
+	out, err := st.ofs.Create(fp)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	rw := blockfmt.Rewriter{
+		Output:    out,
+		Comp:      "zstd",
+		Align:     st.conf.align(),
+		FlushMeta: st.conf.flushMeta(),
+	}
+	kept, removed := int64(0), int64(0)
+	err = rw.Run(io.LimitReader(f, t.Offset), t, func(dst io.Writer, block int, chunk []byte) error {
+		if match[block] == blockfmt.Never {
+			n, err := chunkRows(chunk)
+			if err != nil {
+				return err
+			}
+			kept += int64(n)
+			_, err = dst.Write(chunk)
+			return err
+		}
+		k, r, err := rf.Filter(dst, chunk)
+		kept += int64(k)
+		removed += int64(r)
+		return err
+	})
+	if err != nil {
+		abort(out)
+		return nil, 0, 0, fmt.Errorf("rewriting %s: %w", d.Path, err)
+	}
+	st.conf.logf("table %s: rewrote %s as %s (%d rows removed)", st.table, d.Path, fp, removed)
+	nd, err := st.descriptor(fp, out, rw.Trailer(), rw.Schema())
+	return nd, kept, removed, err
+}
+
+var errFound = errors.New("found a matching row")
+
+// anyMatch returns whether any of the rows in the
+// blocks of d that may match rf are selected by rf
+func (st *tableState) anyMatch(d *blockfmt.Descriptor, match []blockfmt.Ternary, rf RowFilter) (bool, error) {
+	f, err := st.openPacked(d)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	t := d.Trailer
+	err = blockfmt.Chunks(io.LimitReader(f, t.Offset), t, func(block int, chunk []byte) error {
+		if match[block] == blockfmt.Never {
+			return nil
+		}
+		_, removed, err := rf.Filter(io.Discard, chunk)
+		if err != nil {
+			return err
+		}
+		if removed > 0 {
+			return errFound
+		}
+		return nil
+	})
+	if errors.Is(err, errFound) {
+		return true, nil
+	}
+	return false, err
+}
+
+// chunkRows returns the number of rows
+// in an ion chunk
+func chunkRows(chunk []byte) (int, error) {
+	var st ion.Symtab
+	rest := chunk
+	if ion.IsBVM(rest) || ion.TypeOf(rest) == ion.AnnotationType {
+		var err error
+		rest, err = st.Unmarshal(rest)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := 0
+	for len(rest) > 0 {
+		size := ion.SizeOf(rest)
+		if size <= 0 || size > len(rest) {
+			return 0, fmt.Errorf("chunkRows: invalid ion value")
+		}
+		if ion.TypeOf(rest) == ion.StructType {
+			n++
+		}
+		rest = rest[size:]
+	}
+	return n, nil
+}
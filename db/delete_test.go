@@ -0,0 +1,317 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+// idFilter deletes rows where id%mod == 0
+type idFilter struct {
+	mod int64
+}
+
+func rowID(s *ion.Struct) int64 {
+	switch v := s.FieldByName("id").Value.(type) {
+	case ion.Int:
+		return int64(v)
+	case ion.Uint:
+		return int64(v)
+	}
+	return -1
+}
+
+func (f *idFilter) Match(*blockfmt.SparseIndex, int) blockfmt.Ternary {
+	return blockfmt.Maybe
+}
+
+func (f *idFilter) Filter(dst io.Writer, src []byte) (kept, removed int, err error) {
+	var st ion.Symtab
+	var rows ion.Buffer
+	for len(src) > 0 {
+		if ion.TypeOf(src) == ion.NullType {
+			// skip nop pad
+			src = src[ion.SizeOf(src):]
+			continue
+		}
+		var d ion.Datum
+		d, src, err = ion.ReadDatum(&st, src)
+		if err != nil {
+			return 0, 0, err
+		}
+		s, ok := d.(*ion.Struct)
+		if !ok {
+			continue
+		}
+		if rowID(s)%f.mod == 0 {
+			removed++
+			continue
+		}
+		kept++
+		s.Encode(&rows, &st)
+	}
+	if kept == 0 {
+		return kept, removed, nil
+	}
+	var out ion.Buffer
+	st.Marshal(&out, true)
+	_, err = dst.Write(append(out.Bytes(), rows.Bytes()...))
+	return kept, removed, err
+}
+
+func countRows(t *testing.T, dfs *DirFS, idx *blockfmt.Index) (int, []int64) {
+	descs, err := idx.Indirect.Search(dfs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	descs = append(descs, idx.Inline...)
+	var ids []int64
+	for i := range descs {
+		f, err := dfs.Open(descs[i].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		var d blockfmt.Decoder
+		d.Set(descs[i].Trailer, len(descs[i].Trailer.Blocks))
+		_, err = d.Copy(&buf, io.LimitReader(f, descs[i].Trailer.Offset))
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var st ion.Symtab
+		src := buf.Bytes()
+		for len(src) > 0 {
+			if ion.TypeOf(src) == ion.NullType {
+				src = src[ion.SizeOf(src):]
+				continue
+			}
+			var d ion.Datum
+			d, src, err = ion.ReadDatum(&st, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s, ok := d.(*ion.Struct); ok {
+				ids = append(ids, rowID(s))
+			}
+		}
+	}
+	return len(descs), ids
+}
+
+func TestDelete(t *testing.T) {
+	checkFiles(t)
+	tmpdir := t.TempDir()
+	dfs := NewDirFS(tmpdir)
+	defer dfs.Close()
+	dfs.Log = t.Logf
+	owner := newTenant(dfs)
+	b := Builder{
+		Align: 1024,
+		Logf:  t.Logf,
+		// force the first objects
+		// into an indirect ref
+		MaxInlineBytes: 1,
+		GCMinimumAge:   0,
+	}
+	var buf bytes.Buffer
+	json := blockfmt.SuffixToFormat[".json"]
+	id := 0
+	for i := 0; i < 4; i++ {
+		buf.Reset()
+		for j := 0; j < 100; j++ {
+			fmt.Fprintf(&buf, "{\"id\": %d, \"name\": \"row-%d\"}\n", id, id)
+			id++
+		}
+		name := fmt.Sprintf("input%d.json", i)
+		err := os.WriteFile(filepath.Join(tmpdir, name), buf.Bytes(), 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lst, err := blockfmt.CollectGlob(dfs, func(string) blockfmt.RowFormat { return json() }, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = b.Append(owner, "default", "users", lst)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx, err := OpenIndex(dfs, "default", "users", owner.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, ids := countRows(t, dfs, idx)
+	if len(ids) != 400 {
+		t.Fatalf("got %d rows before delete?", len(ids))
+	}
+
+	// delete every third row
+	n, err := b.Delete(owner, "default", "users", &idFilter{mod: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 134 {
+		t.Errorf("deleted %d rows; expected 134", n)
+	}
+	idx, err = OpenIndex(dfs, "default", "users", owner.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.ToDelete) == 0 {
+		t.Error("no objects quarantined")
+	}
+	after, ids := countRows(t, dfs, idx)
+	if after != objects {
+		t.Errorf("%d objects before, %d after", objects, after)
+	}
+	if len(ids) != 400-134 {
+		t.Errorf("%d rows after delete", len(ids))
+	}
+	for _, id := range ids {
+		if id%3 == 0 {
+			t.Errorf("id %d not deleted", id)
+		}
+	}
+
+	// deleting the same rows again is a no-op
+	n, err = b.Delete(owner, "default", "users", &idFilter{mod: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("deleted %d rows the second time", n)
+	}
+
+	// delete everything; all of the
+	// objects should be removed from the index
+	n, err = b.Delete(owner, "default", "users", &idFilter{mod: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 400-134 {
+		t.Errorf("deleted %d rows; expected %d", n, 400-134)
+	}
+	idx, err = OpenIndex(dfs, "default", "users", owner.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Objects() != 0 {
+		t.Errorf("%d objects remain", idx.Objects())
+	}
+	// the old objects should be removable by GC
+	conf := GCConfig{Logf: t.Logf, MinimumAge: 1, Precise: true}
+	err = conf.Run(dfs, "default", idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.ToDelete) != 0 {
+		t.Errorf("%d quarantined objects remain", len(idx.ToDelete))
+	}
+	refs := make(map[string]bool)
+	for i := range idx.Indirect.Refs {
+		refs[path.Base(idx.Indirect.Refs[i].Path)] = true
+	}
+	entries, err := fs.ReadDir(dfs, "db/default/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ent := range entries {
+		name := ent.Name()
+		if strings.HasPrefix(name, "packed-") ||
+			(strings.HasPrefix(name, "indirect-") && !refs[name]) {
+			t.Errorf("unexpected file %s", name)
+		}
+	}
+}
+
+// evenBlocks is an idFilter whose sparse
+// filter rules out the even-numbered blocks
+type evenBlocks struct {
+	idFilter
+}
+
+func (f *evenBlocks) Match(_ *blockfmt.SparseIndex, n int) blockfmt.Ternary {
+	if n%2 == 0 {
+		return blockfmt.Never
+	}
+	return blockfmt.Maybe
+}
+
+func TestDeleteKeptRows(t *testing.T) {
+	tmpdir := t.TempDir()
+	dfs := NewDirFS(tmpdir)
+	defer dfs.Close()
+	owner := newTenant(dfs)
+	b := Builder{
+		Align:         1024,
+		RangeMultiple: 1,
+		Logf:          t.Logf,
+	}
+	var buf bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&buf, "{\"id\": %d, \"name\": \"row-%d\"}\n", i, i)
+	}
+	err := os.WriteFile(filepath.Join(tmpdir, "input.json"), buf.Bytes(), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json := blockfmt.SuffixToFormat[".json"]
+	lst, err := blockfmt.CollectGlob(dfs, func(string) blockfmt.RowFormat { return json() }, "input.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Append(owner, "default", "users", lst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := b.open("default", "users", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := st.index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Inline) != 1 || len(idx.Inline[0].Trailer.Blocks) < 4 {
+		t.Fatalf("expected one object with several blocks")
+	}
+	d := &idx.Inline[0]
+	nd, kept, removed, err := st.deleteFrom(d, &evenBlocks{idFilter{mod: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nd == nil {
+		t.Fatal("nothing rewritten")
+	}
+	if kept+removed != 1000 {
+		t.Errorf("kept %d + removed %d != 1000", kept, removed)
+	}
+	_, ids := countRows(t, dfs, &blockfmt.Index{Inline: []blockfmt.Descriptor{*nd}})
+	if int64(len(ids)) != kept {
+		t.Errorf("%d rows in the new object; kept %d", len(ids), kept)
+	}
+}
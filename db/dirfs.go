@@ -19,6 +19,8 @@ import (
 	"io/fs"
 	"net"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/SnellerInc/sneller/ion"
@@ -28,13 +30,21 @@ import (
 var _ FS = &DirFS{}
 
 // DirFS is an FS implementation
-// that can be used for local testing.
-// It includes a local HTTP server
+// that is rooted in a local directory.
+// Unless Local is set, it includes a local HTTP server
 // bound to the loopback interface
 // that will serve the directory contents.
 type DirFS struct {
 	*blockfmt.DirFS
 
+	// Local, if set, causes URL to return
+	// file:// URLs that are read directly from
+	// the filesystem rather than URLs served over
+	// the loopback interface. Every process that
+	// reads the URLs must see the directory at
+	// the same path (for example, an NFS mount).
+	Local bool
+
 	start    sync.Once
 	server   *http.Server
 	listener net.Listener
@@ -52,18 +62,23 @@ func NewDirFS(dir string) *DirFS {
 // DecodeDirFS decodes the output of (*DirFS).Encode.
 func DecodeDirFS(st *ion.Symtab, mem []byte) (*DirFS, error) {
 	var root string
+	var local bool
 	_, err := ion.UnpackStruct(st, mem, func(field string, mem []byte) error {
 		var err error
 		switch field {
 		case "root":
 			root, _, err = ion.ReadString(mem)
+		case "local":
+			local, _, err = ion.ReadBool(mem)
 		}
 		return err
 	})
 	if err != nil {
 		return nil, err
 	}
-	return NewDirFS(root), nil
+	d := NewDirFS(root)
+	d.Local = local
+	return d, nil
 }
 
 // Close closes the http server
@@ -101,17 +116,40 @@ func (d *DirFS) Prefix() string {
 	return "file://"
 }
 
+// Split implements Resolver.Split.
+// Patterns must have the file:// prefix
+// and are interpreted relative to the
+// root of the DirFS; patterns that would
+// escape the root are rejected.
+func (d *DirFS) Split(pattern string) (InputFS, string, error) {
+	if !strings.HasPrefix(pattern, "file://") {
+		return nil, "", badPattern(pattern)
+	}
+	rest := strings.TrimPrefix(pattern, "file://")
+	if !fs.ValidPath(rest) || rest == "." {
+		return nil, "", badPattern(pattern)
+	}
+	return d, rest, nil
+}
+
 // URL implements FS.URL
 func (d *DirFS) URL(fp string, info fs.FileInfo, etag string) (string, error) {
-	if err := d.startOnce(); err != nil {
-		return "", err
-	}
 	if !fs.ValidPath(fp) {
 		return "", fmt.Errorf("getting URL for %s: %w", fp, fs.ErrInvalid)
 	}
 	if info.Mode().IsDir() {
 		return "", fmt.Errorf("path %s is a directory; can't provide it as a URL", fp)
 	}
+	if d.Local {
+		abs, err := filepath.Abs(filepath.Join(d.Root, filepath.FromSlash(fp)))
+		if err != nil {
+			return "", err
+		}
+		return "file://" + filepath.ToSlash(abs), nil
+	}
+	if err := d.startOnce(); err != nil {
+		return "", err
+	}
 	uri := "http://" + d.addr.String() + "/" + fp
 	return uri, nil
 }
@@ -121,6 +159,10 @@ func (d *DirFS) Encode(dst *ion.Buffer, st *ion.Symtab) error {
 	dst.BeginStruct(-1)
 	dst.BeginField(st.Intern("root"))
 	dst.WriteString(d.Root)
+	if d.Local {
+		dst.BeginField(st.Intern("local"))
+		dst.WriteBool(true)
+	}
 	dst.EndStruct()
 	return nil
 }
@@ -0,0 +1,114 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/expr/partiql"
+)
+
+// FunctionDef is the stored definition
+// of a user-defined SQL function.
+//
+// The functions belonging to a database
+// are stored together in db/{db}/functions.json.
+type FunctionDef struct {
+	// Name is the name of the function.
+	// Name should match the name of the
+	// function in Definition.
+	Name string `json:"name"`
+	// Definition is the CREATE FUNCTION
+	// statement that defines the function, i.e.
+	//
+	//	CREATE FUNCTION name(params...) AS expr
+	Definition string `json:"definition"`
+}
+
+// Parse parses f.Definition.
+func (f *FunctionDef) Parse() (*expr.Function, error) {
+	fn, err := partiql.ParseFunction([]byte(f.Definition))
+	if err != nil {
+		return nil, fmt.Errorf("function %q: %w", f.Name, err)
+	}
+	if !strings.EqualFold(fn.Name, f.Name) {
+		return nil, fmt.Errorf("function name %q doesn't match %q", fn.Name, f.Name)
+	}
+	return fn, nil
+}
+
+func functionsPath(db string) string {
+	return path.Join("db", db, "functions.json")
+}
+
+// OpenFunctions reads the user-defined functions
+// that belong to the given database.
+// If the database does not have any functions,
+// OpenFunctions returns (nil, nil).
+//
+// The returned definitions are not parsed;
+// use FunctionDef.Parse to check them.
+func OpenFunctions(s fs.FS, db string) ([]FunctionDef, error) {
+	f, err := s.Open(functionsPath(db))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	if err := checkDef(f); err != nil {
+		return nil, err
+	}
+	var lst []FunctionDef
+	err = json.NewDecoder(f).Decode(&lst)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", functionsPath(db), err)
+	}
+	return lst, nil
+}
+
+// WriteFunctions writes the list of user-defined
+// functions for the given database, replacing
+// any existing functions. Each of the definitions
+// must be valid, and function names must be unique
+// (ignoring case).
+func WriteFunctions(dst OutputFS, db string, lst []FunctionDef) error {
+	for i := range lst {
+		if _, err := lst[i].Parse(); err != nil {
+			return err
+		}
+		for j := range lst[:i] {
+			if strings.EqualFold(lst[i].Name, lst[j].Name) {
+				return fmt.Errorf("duplicate function %q", lst[i].Name)
+			}
+		}
+	}
+	if lst == nil {
+		lst = []FunctionDef{}
+	}
+	buf, err := json.Marshal(lst)
+	if err != nil {
+		return err
+	}
+	_, err = dst.WriteFile(functionsPath(db), buf)
+	return err
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFunctions(t *testing.T) {
+	dfs := NewDirFS(t.TempDir())
+	defer dfs.Close()
+
+	lst, err := OpenFunctions(dfs, "db0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lst) != 0 {
+		t.Fatalf("got functions %v before writing any", lst)
+	}
+
+	want := []FunctionDef{{
+		Name:       "status_class",
+		Definition: "CREATE FUNCTION status_class(code) AS CASE WHEN code >= 500 THEN 'server' ELSE 'ok' END",
+	}, {
+		Name:       "Double",
+		Definition: "CREATE FUNCTION double(x) AS x * 2",
+	}}
+	err = WriteFunctions(dfs, "db0", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lst, err = OpenFunctions(dfs, "db0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lst, want) {
+		t.Fatalf("got %v, want %v", lst, want)
+	}
+	for i := range lst {
+		if _, err := lst[i].Parse(); err != nil {
+			t.Error(err)
+		}
+	}
+	// functions are not tables
+	tables, err := ListTables(dfs, "db0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 0 {
+		t.Fatalf("got tables %v", tables)
+	}
+
+	bad := [][]FunctionDef{
+		{{Name: "f", Definition: "CREATE FUNCTION g(x) AS x"}},
+		{{Name: "f", Definition: "CREATE FUNCTION f(x) AS y"}},
+		{
+			{Name: "f", Definition: "CREATE FUNCTION f(x) AS x"},
+			{Name: "F", Definition: "CREATE FUNCTION F(x) AS x + 1"},
+		},
+	}
+	for i := range bad {
+		err := WriteFunctions(dfs, "db0", bad[i])
+		if err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+	// the invalid definitions
+	// must not have been written
+	lst, err = OpenFunctions(dfs, "db0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lst, want) {
+		t.Fatalf("got %v, want %v", lst, want)
+	}
+}
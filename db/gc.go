@@ -36,6 +36,8 @@ type RemoveFS interface {
 var (
 	_ RemoveFS = &S3FS{}
 	_ RemoveFS = &DirFS{}
+	_ RemoveFS = &GCSFS{}
+	_ RemoveFS = &AzureFS{}
 )
 
 // DefaultMinimumAge is the default minimum
@@ -84,10 +86,11 @@ const (
 // within the provided database name and table
 // that a) has a filename pattern that indicates
 // it was packed by Sync, at b) is not pointed to
-// by idx.
+// by idx or by any retained snapshot of idx
+// (see Builder.MaxSnapshots).
 func (c *GCConfig) Run(rfs RemoveFS, dbname string, idx *blockfmt.Index) error {
 	if c.Precise {
-		c.preciseGC(rfs, idx)
+		c.preciseGC(rfs, dbname, idx)
 	}
 
 	// pin relative time to start time,
@@ -95,6 +98,10 @@ func (c *GCConfig) Run(rfs RemoveFS, dbname string, idx *blockfmt.Index) error {
 	// anything that has been written since
 	// the GC operation actually started
 	start := time.Now()
+	ret, err := retained(rfs, dbname, idx.Name)
+	if err != nil {
+		return err
+	}
 	used := make(map[string]struct{})
 	for i := range idx.Inline {
 		used[idx.Inline[i].Path] = struct{}{}
@@ -149,6 +156,10 @@ func (c *GCConfig) Run(rfs RemoveFS, dbname string, idx *blockfmt.Index) error {
 				}
 				return err
 			}
+			if ret.retains(p, info.ModTime()) {
+				c.logf("%s may be referenced by a snapshot", p)
+				return nil
+			}
 			if info.ModTime().After(idx.Created.Time()) {
 				// if, due to some kind of synchronization failure,
 				// we are running an ingest at the same time that
@@ -180,7 +191,10 @@ func (c *GCConfig) Run(rfs RemoveFS, dbname string, idx *blockfmt.Index) error {
 
 // preciseGC removes expired elements from idx.ToDelete
 // and returns true if any items were removed, or otherwise false
-func (c *GCConfig) preciseGC(rfs RemoveFS, idx *blockfmt.Index) bool {
+//
+// Elements that are still referenced by a retained
+// snapshot of idx are left in idx.ToDelete.
+func (c *GCConfig) preciseGC(rfs RemoveFS, dbname string, idx *blockfmt.Index) bool {
 	if len(idx.ToDelete) == 0 {
 		return false
 	}
@@ -188,17 +202,43 @@ func (c *GCConfig) preciseGC(rfs RemoveFS, idx *blockfmt.Index) bool {
 	sort.Slice(idx.ToDelete, func(i, j int) bool {
 		return idx.ToDelete[i].Expiry.Before(idx.ToDelete[j].Expiry)
 	})
-	any := false
 	now := date.Now()
-	for len(idx.ToDelete) > 0 && idx.ToDelete[0].Expiry.Before(now) {
-		err := rfs.Remove(idx.ToDelete[0].Path)
+	if !idx.ToDelete[0].Expiry.Before(now) {
+		return false
+	}
+	ret, err := retained(rfs, dbname, idx.Name)
+	if err != nil {
+		c.logf("reading snapshots of %s/%s: %s", dbname, idx.Name, err)
+		return false
+	}
+	any := false
+	keep := idx.ToDelete[:0]
+	for i, q := range idx.ToDelete {
+		if !q.Expiry.Before(now) {
+			keep = append(keep, idx.ToDelete[i:]...)
+			break
+		}
+		if ret != nil {
+			info, err := fs.Stat(rfs, q.Path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				c.logf("stat %q: %s", q.Path, err)
+				keep = append(keep, q)
+				continue
+			}
+			if err == nil && ret.retains(q.Path, info.ModTime()) {
+				c.logf("%s may be referenced by a snapshot", q.Path)
+				keep = append(keep, q)
+				continue
+			}
+		}
+		err := rfs.Remove(q.Path)
 		if err == nil || errors.Is(err, fs.ErrNotExist) {
 			any = true
-			idx.ToDelete = idx.ToDelete[1:]
 		} else {
-			c.logf("deleting ToDelete %q: %s", idx.ToDelete[0].Path, err)
-			return any
+			c.logf("deleting ToDelete %q: %s", q.Path, err)
+			keep = append(keep, q)
 		}
 	}
+	idx.ToDelete = keep
 	return any
 }
@@ -0,0 +1,101 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/SnellerInc/sneller/aws"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+var _ FS = &GCSFS{}
+
+// GCSEndpoint is the default endpoint of
+// the Google Cloud Storage XML API.
+const GCSEndpoint = "https://storage.googleapis.com"
+
+// GCSKey produces a signing key for the
+// Google Cloud Storage XML API from an HMAC key.
+// If baseURI is empty, GCSEndpoint is used.
+func GCSKey(baseURI, id, secret string) *aws.SigningKey {
+	if baseURI == "" {
+		baseURI = GCSEndpoint
+	}
+	return aws.DeriveKey(baseURI, id, secret, "auto", "s3")
+}
+
+// GCSFS is an FS implementation that is backed
+// by a Google Cloud Storage bucket.
+//
+// Requests are made using the S3-compatible
+// XML API, so the Key of a GCSFS should be
+// produced by GCSKey.
+type GCSFS struct {
+	S3FS
+}
+
+// Prefix implements InputFS.Prefix
+func (g *GCSFS) Prefix() string {
+	return "gs://" + g.Bucket + "/"
+}
+
+// Encode implements plan.UploadFS
+func (g *GCSFS) Encode(dst *ion.Buffer, st *ion.Symtab) error {
+	dst.BeginStruct(-1)
+	dst.BeginField(st.Intern("key"))
+	g.Key.Encode(st, dst)
+	dst.BeginField(st.Intern("bucket"))
+	dst.WriteString(g.Bucket)
+	dst.BeginField(st.Intern("gcs"))
+	dst.WriteBool(true)
+	dst.EndStruct()
+	return nil
+}
+
+// DecodeGCSFS decodes the output of (*GCSFS).Encode.
+func DecodeGCSFS(st *ion.Symtab, buf []byte) (*GCSFS, error) {
+	s, err := DecodeS3FS(st, buf)
+	if err != nil {
+		return nil, err
+	}
+	return &GCSFS{S3FS: *s}, nil
+}
+
+// GCSResolver is a resolver that expects only gs:// schemes.
+type GCSResolver struct {
+	// Key is the key used to access every bucket.
+	Key *aws.SigningKey
+	// Client, if non-nil, sets the default
+	// client used by returned GCSFS objects.
+	Client *http.Client
+}
+
+// Split implements Resolver.Split
+func (g *GCSResolver) Split(pattern string) (InputFS, string, error) {
+	if !strings.HasPrefix(pattern, "gs://") {
+		return nil, "", badPattern(pattern)
+	}
+	s3r := &S3Resolver{
+		DeriveKey: func(string) (*aws.SigningKey, error) { return g.Key, nil },
+		Client:    g.Client,
+	}
+	ifs, rest, err := s3r.Split("s3://" + strings.TrimPrefix(pattern, "gs://"))
+	if err != nil {
+		return nil, "", badPattern(pattern)
+	}
+	return &GCSFS{S3FS: *ifs.(*S3FS)}, rest, nil
+}
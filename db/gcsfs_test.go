@@ -0,0 +1,313 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SnellerInc/sneller/aws/s3"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// fakeGCS is a minimal in-memory implementation
+// of the path-style Cloud Storage XML API
+type fakeGCS struct {
+	t       *testing.T
+	bucket  string
+	lock    sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	nextID  int
+}
+
+func gcsETag(b []byte) string {
+	sum := md5.Sum(b)
+	return fmt.Sprintf("%q", fmt.Sprintf("%x", sum))
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// requests must be signed with an HMAC key
+	// for the "auto" region of the "s3" service
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=id/") || !strings.Contains(auth, "/auto/s3/aws4_request") {
+		f.t.Errorf("%s %s: unexpected Authorization %q", r.Method, r.URL, auth)
+		w.WriteHeader(403)
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	// path is /<bucket>/<object>
+	bucket, object, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !ok || bucket != f.bucket {
+		w.WriteHeader(400)
+		return
+	}
+	q := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		f.reply(w, &struct {
+			XMLName xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket  string   `xml:"Bucket"`
+			Key     string   `xml:"Key"`
+			ID      string   `xml:"UploadId"`
+		}{Bucket: bucket, Key: object, ID: id})
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		parts := f.uploads[q.Get("uploadId")]
+		num, err := strconv.Atoi(q.Get("partNumber"))
+		if parts == nil || err != nil {
+			w.WriteHeader(404)
+			return
+		}
+		parts[num] = body
+		w.Header().Set("ETag", gcsETag(body))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts := f.uploads[q.Get("uploadId")]
+		if parts == nil {
+			w.WriteHeader(404)
+			return
+		}
+		delete(f.uploads, q.Get("uploadId"))
+		var nums []int
+		for num := range parts {
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+		var obj []byte
+		for _, num := range nums {
+			obj = append(obj, parts[num]...)
+		}
+		f.objects[object] = obj
+		f.reply(w, &struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string   `xml:"Bucket"`
+			Key     string   `xml:"Key"`
+			ETag    string   `xml:"ETag"`
+		}{Bucket: bucket, Key: object, ETag: gcsETag(obj)})
+	case r.Method == http.MethodPut:
+		f.objects[object] = body
+		w.Header().Set("ETag", gcsETag(body))
+	case r.Method == http.MethodGet && object == "" && q.Get("list-type") == "2":
+		f.list(w, q.Get("prefix"))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := f.objects[object]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("ETag", gcsETag(obj))
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj)))
+		if r.Method == http.MethodGet {
+			w.Write(obj)
+		}
+	default:
+		w.WriteHeader(400)
+	}
+}
+
+// list lists the objects and prefixes
+// below prefix, delimited by "/"
+func (f *fakeGCS) list(w http.ResponseWriter, prefix string) {
+	type object struct {
+		Key  string `xml:"Key"`
+		ETag string `xml:"ETag"`
+		Size int    `xml:"Size"`
+	}
+	type commonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+	ret := struct {
+		XMLName        xml.Name       `xml:"ListBucketResult"`
+		Contents       []object       `xml:"Contents"`
+		CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+	}{}
+	var names []string
+	for name := range f.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if i := strings.IndexByte(name[len(prefix):], '/'); i >= 0 {
+			dir := name[:len(prefix)+i+1]
+			if !seen[dir] {
+				seen[dir] = true
+				ret.CommonPrefixes = append(ret.CommonPrefixes, commonPrefix{dir})
+			}
+			continue
+		}
+		obj := f.objects[name]
+		ret.Contents = append(ret.Contents, object{Key: name, ETag: gcsETag(obj), Size: len(obj)})
+	}
+	f.reply(w, &ret)
+}
+
+func (f *fakeGCS) reply(w http.ResponseWriter, v any) {
+	buf, err := xml.Marshal(v)
+	if err != nil {
+		f.t.Error(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(buf)
+}
+
+func TestGCSFS(t *testing.T) {
+	fake := &fakeGCS{
+		t:       t,
+		bucket:  "bucket",
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ifs, _, err := (&GCSResolver{Key: GCSKey(srv.URL, "id", "secret")}).Split("gs://bucket/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gfs := ifs.(*GCSFS)
+	check := func(name string, want []byte, etag string) {
+		t.Helper()
+		f, err := gfs.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		got, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: got %d bytes; expected %d", name, len(got), len(want))
+		}
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(len(want)) {
+			t.Errorf("%s: size %d", name, info.Size())
+		}
+		if etag == "" {
+			return
+		}
+		got2, err := gfs.ETag(name, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got2 != etag {
+			t.Errorf("%s: ETag %s; expected %s", name, got2, etag)
+		}
+	}
+
+	contents := []byte(`{"x": 1}`)
+	etag, err := gfs.WriteFile("db/a/b.json", contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if etag != gcsETag(contents) {
+		t.Errorf("WriteFile returned ETag %s", etag)
+	}
+	check("db/a/b.json", contents, etag)
+
+	// multi-part uploads
+	up, err := gfs.Create("db/a/packed.ion.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := bytes.Repeat([]byte{'a'}, up.MinPartSize())
+	err = up.Upload(1, part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	final := []byte("final part")
+	err = up.Close(final)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(part, final...)
+	if up.Size() != int64(len(want)) {
+		t.Errorf("uploaded %d bytes; expected %d", up.Size(), len(want))
+	}
+	check("db/a/packed.ion.zst", want, "")
+	if up.(*s3.Uploader).ETag() != gcsETag(want) {
+		t.Errorf("upload ETag %s", up.(*s3.Uploader).ETag())
+	}
+
+	_, err = gfs.Open("db/a/missing.json")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("opening a missing object: %v", err)
+	}
+}
+
+func TestGCSResolver(t *testing.T) {
+	r := SchemeResolver{"gs": &GCSResolver{Key: GCSKey("", "id", "secret")}}
+	ifs, rest, err := r.Split("gs://bucket/path/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifs.Prefix() != "gs://bucket/" || rest != "path/*.json" {
+		t.Errorf("prefix %q rest %q", ifs.Prefix(), rest)
+	}
+	for _, pat := range []string{
+		"s3://bucket/path/*.json",
+		"gs://bucket/",
+	} {
+		_, _, err := r.Split(pat)
+		if !errors.Is(err, ErrBadPattern) {
+			t.Errorf("%s: unexpected error %v", pat, err)
+		}
+	}
+}
+
+func TestEncodeGCSFS(t *testing.T) {
+	gfs, _, err := (&GCSResolver{Key: GCSKey("", "id", "secret")}).Split("gs://bucket/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st ion.Symtab
+	var buf ion.Buffer
+	err = gfs.(*GCSFS).Encode(&buf, &st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gout, err := DecodeGCSFS(&st, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gout.Prefix() != "gs://bucket/" || gout.Key.BaseURI != GCSEndpoint {
+		t.Fatalf("unexpected result %+v", gout)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+func TestIndexSchema(t *testing.T) {
+	checkFiles(t)
+	tmpdir := t.TempDir()
+	dfs := NewDirFS(tmpdir)
+	defer dfs.Close()
+	dfs.Log = t.Logf
+	owner := newTenant(dfs)
+	b := Builder{
+		Align: 1024,
+		Logf:  t.Logf,
+	}
+	var buf bytes.Buffer
+	json := blockfmt.SuffixToFormat[".json"]
+	id := 0
+	for i := 0; i < 3; i++ {
+		buf.Reset()
+		for j := 0; j < 100; j++ {
+			if id%3 == 0 {
+				fmt.Fprintf(&buf, "{\"id\": %d, \"name\": \"row%d\"}\n", id, id)
+			} else {
+				fmt.Fprintf(&buf, "{\"id\": %d}\n", id)
+			}
+			id++
+		}
+		name := fmt.Sprintf("input%d.json", i)
+		err := os.WriteFile(filepath.Join(tmpdir, name), buf.Bytes(), 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lst, err := blockfmt.CollectGlob(dfs, func(string) blockfmt.RowFormat { return json() }, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = b.Append(owner, "default", "events", lst)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(rows, names int64) {
+		t.Helper()
+		s, err := OpenSchema(dfs, "default", "events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Rows != rows {
+			t.Errorf("schema has %d rows; expected %d", s.Rows, rows)
+		}
+		if f := s.Field([]string{"id"}); f == nil || f.Types[ion.UintType] != rows {
+			t.Errorf("schema of id: %+v", f)
+		}
+		f := s.Field([]string{"name"})
+		if names == 0 {
+			if f != nil {
+				t.Errorf("unexpected schema of name: %+v", f)
+			}
+		} else if f == nil || f.Types[ion.StringType] != names {
+			t.Errorf("schema of name: %+v", f)
+		}
+	}
+	check(300, 100)
+
+	// remove even rows: 150 rows remain,
+	// and 50 of those have a name
+	n, err := b.Delete(owner, "default", "events", &idFilter{mod: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 150 {
+		t.Fatalf("deleted %d rows", n)
+	}
+	check(150, 50)
+
+	err = b.Compact(owner, "default", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(150, 50)
+
+	// remove the rows with names
+	_, err = b.Delete(owner, "default", "events", &idFilter{mod: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(100, 0)
+}
@@ -0,0 +1,396 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/SnellerInc/sneller/compr"
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+// ingestFormat is a blockfmt.RowFormat that
+// passes each row produced by another RowFormat
+// through the RowSchema and Rollups of a table
+type ingestFormat struct {
+	blockfmt.RowFormat
+	source  string
+	check   *rowChecker // nil if no schema
+	dl      *deadLetter
+	rollups []*rollupRun
+}
+
+func (f *ingestFormat) Convert(r io.Reader, dst *ion.Chunker) error {
+	w := &rowFilter{
+		source: f.source,
+		check:  f.check,
+		dl:     f.dl,
+		dst:    dst,
+	}
+	for i := range f.rollups {
+		t, err := f.rollups[i].open()
+		if err != nil {
+			return err
+		}
+		w.tees = append(w.tees, t)
+	}
+	// rows are staged in a separate Chunker
+	// so that we see each row exactly once
+	// regardless of how the format produces them
+	cn := ion.Chunker{
+		W:     w,
+		Align: dst.Align,
+	}
+	err := f.RowFormat.Convert(r, &cn)
+	if err == nil {
+		err = cn.Flush()
+	}
+	for i := range w.tees {
+		err2 := w.tees[i].Close()
+		if err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+// rowFilter accepts chunks of rows and writes the
+// rows that satisfy the schema to dst and to each
+// of the tees and the other rows to the dead-letter output
+//
+// Rows that satisfy the schema as they are
+// are copied without being decoded; only the
+// rows that need to be coerced or rejected
+// are decoded and re-encoded.
+type rowFilter struct {
+	source string
+	check  *rowChecker
+	dl     *deadLetter
+	dst    *ion.Chunker
+	tees   []*rollupTee
+	st     ion.Symtab
+	hdr    ion.Buffer
+}
+
+func (w *rowFilter) Write(p []byte) (int, error) {
+	rest := p
+	if ion.IsBVM(p) || ion.TypeOf(p) == ion.AnnotationType {
+		var err error
+		rest, err = w.st.Unmarshal(p)
+		if err != nil {
+			return 0, err
+		}
+	}
+	// switch dst and the tees to the symbol table
+	// of the input so that rows can be copied as-is
+	w.hdr.Reset()
+	w.st.Marshal(&w.hdr, true)
+	if _, err := w.dst.Write(w.hdr.Bytes()); err != nil {
+		return 0, err
+	}
+	for i := range w.tees {
+		w.tees[i].use(&w.st)
+	}
+	for len(rest) > 0 {
+		size := ion.SizeOf(rest)
+		if size <= 0 || size > len(rest) {
+			return 0, fmt.Errorf("rowFilter: invalid ion value")
+		}
+		if ion.TypeOf(rest) != ion.StructType {
+			// skip nop pad
+			rest = rest[size:]
+			continue
+		}
+		rec := rest[:size]
+		rest = rest[size:]
+		var err error
+		if w.check == nil || w.check.valid(&w.st, rec) {
+			err = w.copy(rec)
+		} else {
+			err = w.slow(rec)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	for i := range w.tees {
+		err := w.tees[i].flush()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// copy writes an encoded row that
+// satisfies the schema to the outputs
+func (w *rowFilter) copy(rec []byte) error {
+	for i := range w.tees {
+		err := w.tees[i].addRaw(rec)
+		if err != nil {
+			return err
+		}
+	}
+	w.dst.Buffer.UnsafeAppend(rec)
+	body, _ := ion.Contents(rec)
+	noteRawTimes(w.dst, body, nil)
+	return w.dst.CommitStable()
+}
+
+// slow decodes a row so that it can be
+// coerced to the schema or rejected
+func (w *rowFilter) slow(rec []byte) error {
+	d, _, err := ion.ReadDatum(&w.st, rec)
+	if err != nil {
+		return err
+	}
+	row := resolve(d, &w.st).(*ion.Struct)
+	if cerr := w.check.check(row); cerr != nil {
+		// dl is nil if the rejected rows from
+		// this source were already written
+		if w.dl != nil {
+			return w.dl.add(row, w.source, cerr)
+		}
+		return nil
+	}
+	for i := range w.tees {
+		err = w.tees[i].add(row)
+		if err != nil {
+			return err
+		}
+	}
+	// note: noteTimes depends on the symbols
+	// assigned by the final call to Encode
+	row.Encode(&w.dst.Buffer, &w.dst.Symbols)
+	noteTimes(w.dst, row, nil)
+	// rows copied by copy use the same symbol
+	// table, so it must not be resymbolized
+	return w.dst.CommitStable()
+}
+
+// sideTable writes a new object to a table
+// other than the one being ingested and appends
+// it to the index of that table
+//
+// Each source object whose rows are written
+// to the side table is recorded in the Inputs
+// of its index, so that rows are not appended
+// again when a batch is ingested a second time
+// (because the index of the source table could
+// not be updated, or because it is being rebuilt).
+type sideTable struct {
+	st     *tableState
+	source string // the table being ingested
+	inputs []blockfmt.Input
+	fp     string
+	out    blockfmt.Uploader
+	w      *blockfmt.CompressionWriter
+	sw     *blockfmt.SchemaWriter
+	cn     ion.Chunker
+}
+
+// sideLocks serializes updates to the
+// index of each side table in this process;
+// entries are removed once they are unused
+var sideLocks struct {
+	sync.Mutex
+	tables map[string]*sideLock
+}
+
+type sideLock struct {
+	sync.Mutex
+	refs int // guarded by sideLocks
+}
+
+// lock acquires the lock for the index of
+// the table and returns the function that
+// releases it
+func (s *sideTable) lock() (unlock func()) {
+	key := path.Join(s.st.owner.ID(), s.st.db, s.st.table)
+	sideLocks.Lock()
+	if sideLocks.tables == nil {
+		sideLocks.tables = make(map[string]*sideLock)
+	}
+	l := sideLocks.tables[key]
+	if l == nil {
+		l = new(sideLock)
+		sideLocks.tables[key] = l
+	}
+	l.refs++
+	sideLocks.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		sideLocks.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(sideLocks.tables, key)
+		}
+		sideLocks.Unlock()
+	}
+}
+
+// sibling returns the state of another
+// table in the same database
+func (st *tableState) sibling(table string) *tableState {
+	return &tableState{
+		conf:  st.conf,
+		owner: st.owner,
+		ofs:   st.ofs,
+		db:    st.db,
+		table: table,
+	}
+}
+
+// inputKey is the path under which a source
+// object is recorded in the Inputs of a side table
+func (s *sideTable) inputKey(p string) string {
+	return s.source + ":" + p
+}
+
+// sideIndex reads the index of the table;
+// a table without an index has an empty one
+func (s *sideTable) sideIndex() (*blockfmt.Index, error) {
+	idx, err := s.st.index()
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		idx = &blockfmt.Index{Name: s.st.table}
+	}
+	idx.Inputs.Backing = s.st.ofs
+	return idx, nil
+}
+
+// accept determines which of the source objects
+// in lst have not yet been written to the table;
+// those objects are remembered so that they can
+// be recorded by commit, and the returned slice
+// indicates which of lst they are
+func (s *sideTable) accept(lst []blockfmt.Input) ([]bool, error) {
+	idx, err := s.sideIndex()
+	if err != nil {
+		return nil, fmt.Errorf("table %s/%s: %w", s.st.db, s.st.table, err)
+	}
+	ok := make([]bool, len(lst))
+	for i := range lst {
+		done, err := idx.Inputs.Contains(s.inputKey(lst[i].Path))
+		if err != nil {
+			return nil, err
+		}
+		if !done {
+			ok[i] = true
+			s.inputs = append(s.inputs, lst[i])
+		}
+	}
+	return ok, nil
+}
+
+// open creates the output object
+// if it has not been created yet
+func (s *sideTable) open() error {
+	if s.out != nil {
+		return nil
+	}
+	fp := path.Join("db", s.st.db, s.st.table, "packed-"+uuid()+".ion.zst")
+	out, err := s.st.ofs.Create(fp)
+	if err != nil {
+		return err
+	}
+	s.fp = fp
+	s.out = out
+	s.w = &blockfmt.CompressionWriter{
+		Output:            out,
+		Comp:              compr.Compression("zstd"),
+		InputAlign:        s.st.conf.align(),
+		MinChunksPerBlock: s.st.conf.flushMeta() / (s.st.conf.align() * 2),
+	}
+	s.sw = &blockfmt.SchemaWriter{W: s.w}
+	s.cn = ion.Chunker{
+		W:          s.sw,
+		Align:      s.w.InputAlign,
+		RangeAlign: s.st.conf.flushMeta(),
+	}
+	return nil
+}
+
+// abort discards the output object
+func (s *sideTable) abort() {
+	if s.out != nil {
+		abort(s.out)
+		s.out = nil
+	}
+}
+
+// commit finishes the output object, if any,
+// and appends it to the index of the table
+// along with the source objects it was produced from
+//
+// If another batch has written rows from any of
+// the same source objects in the meantime, then
+// the object is discarded and ErrBuildAgain is
+// returned; the rows from the remaining source
+// objects are written when the batch is retried.
+func (s *sideTable) commit() error {
+	if s.out == nil {
+		return nil
+	}
+	unlock := s.lock()
+	defer unlock()
+	idx, err := s.sideIndex()
+	if err != nil {
+		s.abort()
+		return err
+	}
+	id := nextID(idx)
+	for i := range s.inputs {
+		ok, err := idx.Inputs.Append(s.inputKey(s.inputs[i].Path), s.inputs[i].ETag, id)
+		if err == nil && !ok || errors.Is(err, blockfmt.ErrETagChanged) {
+			s.st.conf.logf("table %s: rows from %s already written", s.st.table, s.inputs[i].Path)
+			err = ErrBuildAgain
+		}
+		if err != nil {
+			s.abort()
+			return err
+		}
+	}
+	err = s.cn.Flush()
+	if err == nil {
+		err = s.w.Close()
+	}
+	if err != nil {
+		s.abort()
+		return err
+	}
+	desc, err := s.st.descriptor(s.fp, s.out, &s.w.Trailer, s.sw.Schema())
+	if err != nil {
+		return err
+	}
+	s.out = nil
+	s.st.conf.logf("table %s: wrote object %s", s.st.table, s.fp)
+	idx.Inline = append(idx.Inline, *desc)
+	s.st.addSchema(desc)
+	idx.Algo = "zstd"
+	idx.Created = date.Now().Truncate(time.Microsecond)
+	return s.st.flush(idx)
+}
@@ -0,0 +1,379 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// KafkaScheme is the scheme of the paths of
+// the items produced by a KafkaQueue.
+//
+// Table definitions select records from a topic
+// with a pattern like
+//
+//	kafka://topic/*/*
+//
+// and should specify the format of the record values
+// explicitly, since item paths have no file extension.
+const KafkaScheme = "kafka://"
+
+// isStream returns whether an input
+// pattern refers to a stream rather than
+// to a filesystem
+func isStream(pattern string) bool {
+	return strings.HasPrefix(pattern, KafkaScheme)
+}
+
+// KafkaRecord is a single record
+// read from a partition of a topic.
+type KafkaRecord struct {
+	Partition int32
+	Offset    int64
+	Value     []byte
+}
+
+// KafkaConsumer is the interface through which
+// a KafkaQueue reads records from a Kafka-compatible
+// broker. It is typically implemented by a thin
+// adapter around a Kafka client library that is
+// a member of a consumer group.
+type KafkaConsumer interface {
+	// Topic should return the name
+	// of the topic being consumed.
+	Topic() string
+	// Fetch should return the next records
+	// from the topic. Records from the same
+	// partition must be returned in offset order.
+	// If pause is non-negative, then Fetch should
+	// block for up to pause and return (nil, nil)
+	// if no records arrive. If pause is negative,
+	// Fetch should block until it can return
+	// at least one record or an error.
+	// Fetch should return io.EOF once the consumer
+	// has been closed.
+	Fetch(pause time.Duration) ([]KafkaRecord, error)
+	// Commit should commit offset as the offset
+	// of the next record to be consumed from
+	// the given partition.
+	Commit(partition int32, offset int64) error
+	// Seek should arrange for the next records
+	// fetched from the given partition to
+	// begin at offset.
+	Seek(partition int32, offset int64) error
+}
+
+// DefaultKafkaItemSize is the default
+// value of KafkaQueue.MaxItemSize.
+const DefaultKafkaItemSize = 16 * 1024 * 1024
+
+// KafkaQueue is a Queue that produces
+// items from records read from a KafkaConsumer.
+//
+// Each item holds a contiguous range of records
+// from one partition and has a path of the form
+//
+//	kafka://topic/partition/first-last
+//
+// The record values of an item are framed according
+// to the format of the table input that selects it:
+// values in the "json" format are separated by newlines,
+// and values in the "ion" format are concatenated
+// (so each value must be a complete ion stream).
+// Items cannot be ingested in other formats.
+//
+// Offsets are committed only once an item and
+// every preceding item from the same partition
+// have been finalized with StatusOK, so records
+// are delivered at least once. When an item is
+// not ingested successfully, the partition is
+// rewound to the first record of the item, and
+// the redelivered records are split into items
+// with the same boundaries as before, so that
+// items that were ingested already are recognized
+// as duplicates by the table index.
+type KafkaQueue struct {
+	// Consumer is the source of records.
+	Consumer KafkaConsumer
+	// MaxItemSize is the maximum size of
+	// an item. (An item always contains at
+	// least one record.) If MaxItemSize is
+	// zero, DefaultKafkaItemSize is used.
+	MaxItemSize int
+	// Logf, if non-nil, is used to log errors
+	// from committing offsets.
+	Logf func(f string, args ...interface{})
+
+	ready    []*kafkaItem
+	inflight map[int32][]*kafkaItem
+	// boundaries of items that have been
+	// produced for each partition but not
+	// committed, so that items are reproduced
+	// identically after a rewind
+	replay map[int32][]int64
+	// partitions that have been rewound and
+	// are waiting for records at the given offset
+	rewound map[int32]int64
+	// items that are still accumulating records
+	partial map[int32]*kafkaItem
+}
+
+type kafkaItem struct {
+	path        string
+	etag        string
+	partition   int32
+	first, last int64
+	values      [][]byte
+	size        int
+	done        bool
+	status      QueueStatus
+}
+
+func (k *kafkaItem) Path() string { return k.path }
+func (k *kafkaItem) ETag() string { return k.etag }
+func (k *kafkaItem) Size() int64  { return int64(k.size) }
+
+// Open implements QueueDataItem.Open
+func (k *kafkaItem) Open(format string) (io.ReadCloser, error) {
+	var sep []byte
+	switch format {
+	case "json":
+		sep = []byte{'\n'}
+	case "ion":
+		// ion streams can be concatenated
+	default:
+		return nil, fmt.Errorf("%s: cannot ingest records in format %q", k.path, format)
+	}
+	return io.NopCloser(bytes.NewReader(bytes.Join(append(k.values, nil), sep))), nil
+}
+
+var _ QueueDataItem = &kafkaItem{}
+
+func (q *KafkaQueue) logf(f string, args ...interface{}) {
+	if q.Logf != nil {
+		q.Logf(f, args...)
+	}
+}
+
+func (q *KafkaQueue) maxSize() int {
+	if q.MaxItemSize > 0 {
+		return q.MaxItemSize
+	}
+	return DefaultKafkaItemSize
+}
+
+func (q *KafkaQueue) init() {
+	if q.inflight == nil {
+		q.inflight = make(map[int32][]*kafkaItem)
+		q.replay = make(map[int32][]int64)
+		q.rewound = make(map[int32]int64)
+		q.partial = make(map[int32]*kafkaItem)
+	}
+}
+
+// Next implements Queue.Next
+func (q *KafkaQueue) Next(pause time.Duration) (QueueItem, error) {
+	q.init()
+	for len(q.ready) == 0 {
+		recs, err := q.Consumer.Fetch(pause)
+		if err != nil {
+			return nil, err
+		}
+		q.add(recs)
+		if len(recs) == 0 && pause >= 0 {
+			return nil, nil
+		}
+	}
+	item := q.ready[0]
+	q.ready = q.ready[1:]
+	q.inflight[item.partition] = append(q.inflight[item.partition], item)
+	return item, nil
+}
+
+// add splits records into items and
+// appends them to q.ready
+func (q *KafkaQueue) add(recs []KafkaRecord) {
+	for i := range recs {
+		r := &recs[i]
+		if want, ok := q.rewound[r.Partition]; ok {
+			if r.Offset != want {
+				// records fetched before the rewind
+				// took effect; they will be redelivered
+				continue
+			}
+			delete(q.rewound, r.Partition)
+		}
+		cur := q.partial[r.Partition]
+		if cur != nil && !q.replaying(cur) && cur.size+len(r.Value)+1 > q.maxSize() {
+			q.flush(cur)
+			cur = nil
+		}
+		if cur == nil {
+			cur = &kafkaItem{
+				partition: r.Partition,
+				first:     r.Offset,
+			}
+			q.partial[r.Partition] = cur
+		}
+		cur.last = r.Offset
+		cur.values = append(cur.values, r.Value)
+		cur.size += len(r.Value) + 1
+		if !q.replaying(cur) && len(q.replay[cur.partition]) > 0 {
+			// reached the end of a previous item
+			q.flush(cur)
+		}
+	}
+	// items that must be completed to match
+	// a previous item wait for more records
+	for _, cur := range q.partial {
+		if !q.replaying(cur) {
+			q.flush(cur)
+		}
+	}
+}
+
+func (q *KafkaQueue) flush(cur *kafkaItem) {
+	// the ETag covers the record values
+	// but not the framing chosen in Open
+	h := sha256.New()
+	var lenbuf [binary.MaxVarintLen64]byte
+	for _, v := range cur.values {
+		h.Write(lenbuf[:binary.PutUvarint(lenbuf[:], uint64(len(v)))])
+		h.Write(v)
+	}
+	sum := h.Sum(nil)
+	cur.etag = "\"" + hex.EncodeToString(sum[:16]) + "\""
+	cur.path = fmt.Sprintf("%s%s/%d/%020d-%020d",
+		KafkaScheme, q.Consumer.Topic(), cur.partition, cur.first, cur.last)
+	q.ready = append(q.ready, cur)
+	delete(q.partial, cur.partition)
+}
+
+// replaying returns whether the item must be
+// extended with more records because a
+// previously-produced item ended at a later offset
+func (q *KafkaQueue) replaying(cur *kafkaItem) bool {
+	lst := q.replay[cur.partition]
+	for len(lst) > 0 && lst[0] < cur.last {
+		lst = lst[1:]
+	}
+	q.replay[cur.partition] = lst
+	return len(lst) > 0 && lst[0] > cur.last
+}
+
+// Finalize implements Queue.Finalize
+func (q *KafkaQueue) Finalize(item QueueItem, status QueueStatus) {
+	ki, ok := item.(*kafkaItem)
+	if !ok {
+		return
+	}
+	lst := q.inflight[ki.partition]
+	idx := -1
+	for i := range lst {
+		if lst[i] == ki {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		// already discarded by a rewind
+		return
+	}
+	ki.done = true
+	ki.status = status
+	if status != StatusOK {
+		q.rewind(ki.partition, idx)
+		return
+	}
+	// commit the longest finished prefix
+	n := 0
+	for n < len(lst) && lst[n].done {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	next := lst[n-1].last + 1
+	err := q.Consumer.Commit(ki.partition, next)
+	if err != nil {
+		q.logf("committing offset %d of partition %d: %s", next, ki.partition, err)
+		// leave the items in-flight; they will be
+		// committed along with the next item
+		return
+	}
+	q.inflight[ki.partition] = lst[n:]
+	rp := q.replay[ki.partition]
+	for len(rp) > 0 && rp[0] < next {
+		rp = rp[1:]
+	}
+	q.replay[ki.partition] = rp
+}
+
+// rewind arranges for the records in the
+// in-flight items of partition starting at
+// index idx to be delivered again
+func (q *KafkaQueue) rewind(partition int32, idx int) {
+	lst := q.inflight[partition]
+	from := lst[idx].first
+	err := q.Consumer.Seek(partition, from)
+	if err != nil {
+		q.logf("seeking partition %d to offset %d: %s", partition, from, err)
+	}
+	var bounds []int64
+	for _, it := range lst[idx:] {
+		bounds = append(bounds, it.last)
+	}
+	// items that have not been returned
+	// from Next yet will be re-fetched as well
+	kept := q.ready[:0]
+	for _, it := range q.ready {
+		if it.partition == partition {
+			bounds = append(bounds, it.last)
+		} else {
+			kept = append(kept, it)
+		}
+	}
+	q.ready = kept
+	delete(q.partial, partition)
+	q.replay[partition] = mergeBounds(q.replay[partition], bounds)
+	q.inflight[partition] = lst[:idx]
+	q.rewound[partition] = from
+}
+
+// mergeBounds merges two sorted lists of offsets
+func mergeBounds(a, b []int64) []int64 {
+	out := make([]int64, 0, len(a)+len(b))
+	for len(a) > 0 || len(b) > 0 {
+		switch {
+		case len(b) == 0 || (len(a) > 0 && a[0] < b[0]):
+			out = append(out, a[0])
+			a = a[1:]
+		case len(a) == 0 || b[0] < a[0]:
+			out = append(out, b[0])
+			b = b[1:]
+		default:
+			out = append(out, a[0])
+			a, b = a[1:], b[1:]
+		}
+	}
+	return out
+}
@@ -0,0 +1,377 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// memBroker is an in-process stand-in
+// for a Kafka broker with a single topic
+// and a single consumer
+type memBroker struct {
+	lock      sync.Mutex
+	cond      *sync.Cond
+	topic     string
+	parts     [][]KafkaRecord
+	pos       []int64
+	committed []int64
+	closed    bool
+	// maximum number of records
+	// returned per partition per Fetch
+	fetchMax int
+	// called with each commit, if non-nil
+	onCommit func()
+}
+
+func newBroker(topic string, partitions int) *memBroker {
+	b := &memBroker{
+		topic:     topic,
+		parts:     make([][]KafkaRecord, partitions),
+		pos:       make([]int64, partitions),
+		committed: make([]int64, partitions),
+		fetchMax:  3,
+	}
+	b.cond = sync.NewCond(&b.lock)
+	return b
+}
+
+func (b *memBroker) produce(part int32, values ...string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, v := range values {
+		b.parts[part] = append(b.parts[part], KafkaRecord{
+			Partition: part,
+			Offset:    int64(len(b.parts[part])),
+			Value:     []byte(v),
+		})
+	}
+	b.cond.Broadcast()
+}
+
+func (b *memBroker) close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+}
+
+func (b *memBroker) Topic() string { return b.topic }
+
+func (b *memBroker) available() []KafkaRecord {
+	var out []KafkaRecord
+	for i := range b.parts {
+		end := b.pos[i] + int64(b.fetchMax)
+		if max := int64(len(b.parts[i])); end > max {
+			end = max
+		}
+		out = append(out, b.parts[i][b.pos[i]:end]...)
+		b.pos[i] = end
+	}
+	return out
+}
+
+func (b *memBroker) Fetch(pause time.Duration) ([]KafkaRecord, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var deadline time.Time
+	if pause >= 0 {
+		deadline = time.Now().Add(pause)
+		t := time.AfterFunc(pause, func() {
+			b.lock.Lock()
+			b.cond.Broadcast()
+			b.lock.Unlock()
+		})
+		defer t.Stop()
+	}
+	for {
+		if b.closed {
+			return nil, io.EOF
+		}
+		if out := b.available(); len(out) > 0 {
+			return out, nil
+		}
+		if pause >= 0 && !time.Now().Before(deadline) {
+			return nil, nil
+		}
+		b.cond.Wait()
+	}
+}
+
+func (b *memBroker) Commit(part int32, offset int64) error {
+	b.lock.Lock()
+	if offset < b.committed[part] {
+		b.lock.Unlock()
+		return fmt.Errorf("commit of partition %d moved backwards from %d to %d", part, b.committed[part], offset)
+	}
+	b.committed[part] = offset
+	fn := b.onCommit
+	b.lock.Unlock()
+	if fn != nil {
+		fn()
+	}
+	return nil
+}
+
+func (b *memBroker) Seek(part int32, offset int64) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.pos[part] = offset
+	return nil
+}
+
+func (b *memBroker) commits() []int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]int64(nil), b.committed...)
+}
+
+func TestKafkaQueueRewind(t *testing.T) {
+	b := newBroker("events", 2)
+	for i := 0; i < 8; i++ {
+		b.produce(int32(i%2), fmt.Sprintf(`{"x": %d}`, i))
+	}
+	q := &KafkaQueue{Consumer: b, Logf: t.Logf}
+	next := func() *kafkaItem {
+		t.Helper()
+		item, err := q.Next(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item == nil {
+			return nil
+		}
+		return item.(*kafkaItem)
+	}
+	// first fetch produces items for
+	// partition 0 [0, 2] and partition 1 [0, 2]
+	p0 := next()
+	p1 := next()
+	if p0.partition != 0 {
+		p0, p1 = p1, p0
+	}
+	if p0.path != "kafka://events/0/00000000000000000000-00000000000000000002" {
+		t.Fatalf("unexpected path %s", p0.path)
+	}
+	// the second fetch produces [3, 3] for each partition
+	p0b := next()
+	p1b := next()
+	if p0b.partition != 0 {
+		p0b, p1b = p1b, p0b
+	}
+	if next() != nil {
+		t.Fatal("expected no more items")
+	}
+	// out-of-order success doesn't commit
+	q.Finalize(p0b, StatusOK)
+	if c := b.commits(); c[0] != 0 {
+		t.Fatalf("committed %v", c)
+	}
+	q.Finalize(p0, StatusOK)
+	if c := b.commits(); c[0] != 4 {
+		t.Fatalf("committed %v", c)
+	}
+	// failure rewinds partition 1
+	q.Finalize(p1, StatusWriteError)
+	q.Finalize(p1b, StatusOK) // ignored
+	if c := b.commits(); c[1] != 0 {
+		t.Fatalf("committed %v", c)
+	}
+	// the records should be redelivered one
+	// at a time, but the items must have
+	// the same boundaries as before
+	b.fetchMax = 1
+	again := next()
+	if again.path != p1.path || again.etag != p1.etag {
+		t.Fatalf("redelivered %s %s; wanted %s %s", again.path, again.etag, p1.path, p1.etag)
+	}
+	againb := next()
+	if againb.path != p1b.path || againb.etag != p1b.etag {
+		t.Fatalf("redelivered %s %s; wanted %s %s", againb.path, againb.etag, p1b.path, p1b.etag)
+	}
+	q.Finalize(again, StatusOK)
+	q.Finalize(againb, StatusOK)
+	if c := b.commits(); c[0] != 4 || c[1] != 4 {
+		t.Fatalf("committed %v", c)
+	}
+}
+
+func TestKafkaQueueRunner(t *testing.T) {
+	checkFiles(t)
+	tmpdir := t.TempDir()
+	dfs := NewDirFS(tmpdir)
+	defer dfs.Close()
+	dfs.Log = t.Logf
+	owner := newTenant(dfs)
+	err := WriteDefinition(dfs, "default", &Definition{
+		Name: "events",
+		Inputs: []Input{
+			{Pattern: "kafka://events/*/*", Format: "json"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const partitions = 3
+	const total = 40
+	b := newBroker("events", partitions)
+	var done sync.WaitGroup
+	done.Add(1)
+	var once sync.Once
+	b.onCommit = func() {
+		sum := int64(0)
+		for _, c := range b.commits() {
+			sum += c
+		}
+		if sum == total {
+			once.Do(done.Done)
+		}
+	}
+	r := &QueueRunner{
+		Owner: owner,
+		Conf: Builder{
+			Align: 1024,
+			Logf:  t.Logf,
+			// scanning should skip stream inputs
+			NewIndexScan: true,
+		},
+		Logf:          t.Logf,
+		BatchSize:     5,
+		BatchInterval: time.Millisecond,
+	}
+	q := &KafkaQueue{Consumer: b, Logf: t.Logf}
+	final := make(chan error, 1)
+	go func() {
+		final <- r.Run(q)
+	}()
+	for i := 0; i < total; i++ {
+		b.produce(int32(i%partitions), fmt.Sprintf(`{"n": %d}`, i))
+	}
+	done.Wait()
+	b.close()
+	if err := <-final; err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := r.Conf.open("default", "events", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := st.index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := rowCollector{}
+	for i := range idx.Inline {
+		err := st.collectRows(&idx.Inline[i], &rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	seen := make(map[int64]bool)
+	for i := range rc.rows {
+		f := rc.rows[i].row.(*ion.Struct).FieldByName("n")
+		if f == nil {
+			t.Fatalf("unexpected row %v", rc.rows[i].row)
+		}
+		n := int64(f.Value.(ion.Uint))
+		if seen[n] {
+			t.Errorf("duplicate row %d", n)
+		}
+		seen[n] = true
+	}
+	if len(seen) != total {
+		t.Fatalf("got %d rows; wanted %d", len(seen), total)
+	}
+}
+
+func TestKafkaItemFraming(t *testing.T) {
+	b := newBroker("events", 1)
+	var st ion.Symtab
+	var buf ion.Buffer
+	var values []string
+	for i := 0; i < 3; i++ {
+		// each value is a complete
+		// binary ion stream
+		buf.Reset()
+		st.Reset()
+		st.Intern("n")
+		st.Marshal(&buf, true)
+		buf.BeginStruct(-1)
+		buf.BeginField(st.Intern("n"))
+		buf.WriteInt(int64(i))
+		buf.EndStruct()
+		values = append(values, string(buf.Bytes()))
+	}
+	b.produce(0, values...)
+	q := &KafkaQueue{Consumer: b, Logf: t.Logf}
+	item, err := q.Next(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ki := item.(*kafkaItem)
+	read := func(format string) ([]byte, error) {
+		rc, err := ki.Open(format)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	got, err := read("ion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(values, ""); string(got) != want {
+		t.Errorf("ion framing: got %x, want %x", got, want)
+	}
+	// every row can be read back
+	var rst ion.Symtab
+	for n, rest := 0, got; len(rest) > 0; n++ {
+		if ion.IsBVM(rest) {
+			rest, err = rst.Unmarshal(rest)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		var d ion.Datum
+		d, rest, err = ion.ReadDatum(&rst, rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := d.(*ion.Struct).FieldByName("n")
+		if f == nil || f.Value != ion.Uint(n) {
+			t.Fatalf("row %d is %v", n, d)
+		}
+	}
+	got, err = read("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(values, "\n") + "\n"; string(got) != want {
+		t.Errorf("json framing: got %q, want %q", got, want)
+	}
+	if _, err := read("json.gz"); err == nil {
+		t.Error("expected an error for json.gz")
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	kafkaRESTType    = "application/vnd.kafka.v2+json"
+	kafkaRESTRecords = "application/vnd.kafka.binary.v2+json"
+	// longest single poll for records
+	kafkaRESTPoll = 5 * time.Second
+)
+
+var _ KafkaConsumer = &KafkaRESTConsumer{}
+
+// KafkaRESTConsumer is a KafkaConsumer that
+// reads records through the consumer API of
+// a Kafka REST proxy (version 2 of the API,
+// as implemented by the Confluent REST Proxy
+// and the Redpanda HTTP Proxy).
+//
+// The consumer is a member of a consumer group
+// with automatic offset commits disabled, so offsets
+// are only committed by KafkaQueue once records
+// have been ingested.
+type KafkaRESTConsumer struct {
+	// Client is the HTTP client used
+	// for requests to the proxy.
+	Client *http.Client
+
+	topic string
+	base  string // base URI of the consumer instance
+
+	lock   sync.Mutex
+	closed bool
+}
+
+// NewKafkaRESTConsumer creates a new consumer instance
+// in the given group using the REST proxy at proxy
+// and subscribes it to topic. If client is nil,
+// http.DefaultClient is used.
+//
+// The consumer instance should be removed
+// with Close once it is no longer used.
+func NewKafkaRESTConsumer(client *http.Client, proxy, group, topic string) (*KafkaRESTConsumer, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := &KafkaRESTConsumer{
+		Client: client,
+		topic:  topic,
+	}
+	var inst struct {
+		ID   string `json:"instance_id"`
+		Base string `json:"base_uri"`
+	}
+	uri := strings.TrimSuffix(proxy, "/") + "/consumers/" + url.PathEscape(group)
+	err := c.do(http.MethodPost, uri, map[string]string{
+		"format":             "binary",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}, &inst)
+	if err != nil {
+		return nil, fmt.Errorf("creating consumer in group %q: %w", group, err)
+	}
+	c.base = inst.Base
+	err = c.do(http.MethodPost, c.base+"/subscription", map[string][]string{
+		"topics": {topic},
+	}, nil)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("subscribing to %q: %w", topic, err)
+	}
+	return c, nil
+}
+
+// do performs a request with a JSON body
+// and decodes the JSON response into ret
+func (c *KafkaRESTConsumer) do(method, uri string, body, ret interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequest(method, uri, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaRESTType)
+	req.Header.Set("Accept", kafkaRESTType)
+	if method == http.MethodGet {
+		req.Header.Set("Accept", kafkaRESTRecords)
+	}
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, uri, res.Status, bytes.TrimSpace(msg))
+	}
+	if ret == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(ret)
+}
+
+func (c *KafkaRESTConsumer) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closed
+}
+
+// Topic implements KafkaConsumer.Topic
+func (c *KafkaRESTConsumer) Topic() string { return c.topic }
+
+type kafkaRESTRecord struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Value     []byte `json:"value"`
+}
+
+// Fetch implements KafkaConsumer.Fetch
+func (c *KafkaRESTConsumer) Fetch(pause time.Duration) ([]KafkaRecord, error) {
+	for {
+		if c.isClosed() {
+			return nil, io.EOF
+		}
+		poll := pause
+		if poll < 0 || poll > kafkaRESTPoll {
+			poll = kafkaRESTPoll
+		}
+		var lst []kafkaRESTRecord
+		uri := c.base + "/records?timeout=" + strconv.FormatInt(poll.Milliseconds(), 10)
+		err := c.do(http.MethodGet, uri, nil, &lst)
+		if err != nil {
+			if c.isClosed() {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		var out []KafkaRecord
+		for i := range lst {
+			if lst[i].Topic != "" && lst[i].Topic != c.topic {
+				continue
+			}
+			out = append(out, KafkaRecord{
+				Partition: lst[i].Partition,
+				Offset:    lst[i].Offset,
+				Value:     lst[i].Value,
+			})
+		}
+		if len(out) > 0 || pause >= 0 {
+			return out, nil
+		}
+	}
+}
+
+type kafkaRESTOffset struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// Commit implements KafkaConsumer.Commit
+func (c *KafkaRESTConsumer) Commit(partition int32, offset int64) error {
+	// the proxy expects the offset of the last
+	// record consumed and commits the offset after it
+	return c.do(http.MethodPost, c.base+"/offsets", map[string][]kafkaRESTOffset{
+		"offsets": {{Topic: c.topic, Partition: partition, Offset: offset - 1}},
+	}, nil)
+}
+
+// Seek implements KafkaConsumer.Seek
+func (c *KafkaRESTConsumer) Seek(partition int32, offset int64) error {
+	return c.do(http.MethodPost, c.base+"/positions", map[string][]kafkaRESTOffset{
+		"offsets": {{Topic: c.topic, Partition: partition, Offset: offset}},
+	}, nil)
+}
+
+// Close removes the consumer instance from the
+// proxy. Subsequent calls to Fetch return io.EOF.
+func (c *KafkaRESTConsumer) Close() error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.lock.Unlock()
+	return c.do(http.MethodDelete, c.base, nil, nil)
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// restProxy is a minimal implementation of
+// the consumer API of a Kafka REST proxy
+// serving a single partition
+type restProxy struct {
+	t       *testing.T
+	lock    sync.Mutex
+	base    string
+	records []kafkaRESTRecord
+	pos     int64
+	commits []int64
+	deleted bool
+}
+
+func (p *restProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if ct := r.Header.Get("Content-Type"); ct != kafkaRESTType {
+		p.t.Errorf("%s %s: Content-Type %q", r.Method, r.URL.Path, ct)
+	}
+	reply := func(v interface{}) {
+		w.Header().Set("Content-Type", kafkaRESTType)
+		json.NewEncoder(w).Encode(v)
+	}
+	var offsets struct {
+		Offsets []kafkaRESTOffset `json:"offsets"`
+	}
+	inst := "/consumers/group/instances/x"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/consumers/group":
+		var conf map[string]string
+		json.NewDecoder(r.Body).Decode(&conf)
+		if conf["format"] != "binary" || conf["auto.commit.enable"] != "false" {
+			p.t.Errorf("unexpected consumer configuration %v", conf)
+		}
+		reply(map[string]string{"instance_id": "x", "base_uri": p.base + inst})
+	case r.Method == http.MethodPost && r.URL.Path == inst+"/subscription":
+		var sub struct {
+			Topics []string `json:"topics"`
+		}
+		json.NewDecoder(r.Body).Decode(&sub)
+		if !reflect.DeepEqual(sub.Topics, []string{"events"}) {
+			p.t.Errorf("subscribed to %v", sub.Topics)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == inst+"/records":
+		if acc := r.Header.Get("Accept"); acc != kafkaRESTRecords {
+			p.t.Errorf("records: Accept %q", acc)
+		}
+		out := []kafkaRESTRecord{}
+		for int(p.pos) < len(p.records) {
+			out = append(out, p.records[p.pos])
+			p.pos++
+		}
+		reply(out)
+	case r.Method == http.MethodPost && r.URL.Path == inst+"/offsets":
+		json.NewDecoder(r.Body).Decode(&offsets)
+		for _, o := range offsets.Offsets {
+			p.commits = append(p.commits, o.Offset)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && r.URL.Path == inst+"/positions":
+		json.NewDecoder(r.Body).Decode(&offsets)
+		p.pos = offsets.Offsets[0].Offset
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete && r.URL.Path == inst:
+		p.deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func TestKafkaRESTConsumer(t *testing.T) {
+	p := &restProxy{t: t}
+	for i, v := range []string{"a", "\x00\xe0\x01\x00\xea", "c"} {
+		p.records = append(p.records, kafkaRESTRecord{
+			Topic:  "events",
+			Offset: int64(i),
+			Value:  []byte(v),
+		})
+	}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+	p.base = srv.URL
+
+	c, err := NewKafkaRESTConsumer(srv.Client(), srv.URL+"/", "group", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs, err := c.Fetch(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 || string(recs[1].Value) != "\x00\xe0\x01\x00\xea" || recs[2].Offset != 2 {
+		t.Fatalf("fetched %+v", recs)
+	}
+	recs, err = c.Fetch(0)
+	if err != nil || len(recs) != 0 {
+		t.Fatalf("Fetch(0) = %v, %v", recs, err)
+	}
+	// committing the next offset commits
+	// the offset of the last record consumed
+	err = c.Commit(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.commits, []int64{1}) {
+		t.Errorf("committed %v", p.commits)
+	}
+	err = c.Seek(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs, err = c.Fetch(0)
+	if err != nil || len(recs) != 1 || string(recs[0].Value) != "c" {
+		t.Fatalf("after Seek: %+v, %v", recs, err)
+	}
+	err = c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.deleted {
+		t.Error("consumer instance not deleted")
+	}
+	if _, err := c.Fetch(-1); err != io.EOF {
+		t.Errorf("Fetch after Close: %v", err)
+	}
+}
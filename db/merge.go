@@ -17,12 +17,62 @@ package db
 import (
 	"sort"
 
+	"github.com/SnellerInc/sneller/date"
 	"github.com/SnellerInc/sneller/ion/blockfmt"
 )
 
-func timeSort(lst []blockfmt.Descriptor) {
-	sort.Slice(lst, func(i, j int) bool {
-		return lst[i].LastModified.Before(lst[j].LastModified)
+// DefaultCompactFactor is the default
+// ratio between the sizes of adjacent
+// compaction tiers.
+const DefaultCompactFactor = 4
+
+// DefaultMaxCompact is the default maximum
+// number of decompressed bytes in an object
+// produced by compaction.
+const DefaultMaxCompact = 1 * giga
+
+// primaryTimestamp picks the path of the timestamp
+// used to order the objects in lst and the rows
+// within compacted objects, or nil if the
+// objects do not have any indexed timestamps.
+//
+// The primary timestamp is the first indexed field
+// of the most recently-written object, since that
+// reflects the current shape of the data.
+func primaryTimestamp(lst []blockfmt.Descriptor) []string {
+	var ret []string
+	var when date.Time
+	for i := range lst {
+		paths := lst[i].Trailer.Sparse.FieldPaths()
+		if len(paths) == 0 {
+			continue
+		}
+		if ret == nil || lst[i].LastModified.After(when) {
+			ret = paths[0]
+			when = lst[i].LastModified
+		}
+	}
+	return ret
+}
+
+// timeKey returns the earliest value of the
+// primary timestamp in d, or otherwise the
+// last-modified time of d
+func timeKey(d *blockfmt.Descriptor, ts []string) date.Time {
+	if ts != nil {
+		if ti := d.Trailer.Sparse.Get(ts); ti != nil {
+			if min, ok := ti.Min(); ok {
+				return min
+			}
+		}
+	}
+	return d.LastModified
+}
+
+// timeSort sorts lst by the primary timestamp
+func timeSort(lst []blockfmt.Descriptor, ts []string) {
+	sort.SliceStable(lst, func(i, j int) bool {
+		return timeKey(&lst[i], ts).Before(timeKey(&lst[j], ts))
 	})
 }
 
@@ -33,10 +83,54 @@ func (b *Builder) minMergeSize() int64 {
 	return DefaultMinMerge
 }
 
+func (b *Builder) compactFactor() int {
+	if b.CompactFactor > 1 {
+		return b.CompactFactor
+	}
+	return DefaultCompactFactor
+}
+
+func (b *Builder) maxCompactBytes() int64 {
+	if b.MaxCompactBytes > 0 {
+		return b.MaxCompactBytes
+	}
+	return DefaultMaxCompact
+}
+
+func (b *Builder) maxSortBytes() int64 {
+	if b.MaxSortBytes > 0 {
+		return b.MaxSortBytes
+	}
+	return DefaultMaxSortBytes
+}
+
+// tier returns the compaction tier of an object:
+// objects below the minimum merge size are in tier 0,
+// and each subsequent tier holds objects that are
+// compactFactor() times larger than the previous one
+func (b *Builder) tier(size int64) int {
+	lim := b.minMergeSize()
+	t := 0
+	for size >= lim {
+		t++
+		lim *= int64(b.compactFactor())
+	}
+	return t
+}
+
 // decideMerge takes the list of existing objects
 // in an index and decides which ones should be kept as-is
-// and which ones should be merged into the current object being written
-func (b *Builder) decideMerge(existing []blockfmt.Descriptor) (prepend, merge []blockfmt.Descriptor) {
+// and which groups of objects should be merged together.
+//
+// Objects are ordered by their primary timestamp,
+// and runs of adjacent objects in the same size tier
+// are grouped together: every object in tier 0 (below
+// the minimum merge size) is merged into one group,
+// and objects in higher tiers are merged in groups of
+// compactFactor() objects, which produces an object in
+// the next tier. No group is larger than maxCompactBytes().
+// Both of the returned lists are in time order.
+func (b *Builder) decideMerge(existing []blockfmt.Descriptor) (keep []blockfmt.Descriptor, merge [][]blockfmt.Descriptor) {
 	// if we haven't picked up sizes yet (?),
 	// then don't do merging
 	for i := range existing {
@@ -45,18 +139,41 @@ func (b *Builder) decideMerge(existing []blockfmt.Descriptor) (prepend, merge []
 			return existing, nil
 		}
 	}
-	// super-simple heuristic for now:
-	// group all of the objects below the minimum
-	// merge size, and otherwise do nothing
-	for i := range existing {
-		if existing[i].Size < b.minMergeSize() {
-			b.logf("merging %s into new output", existing[i].Path)
-			merge = append(merge, existing[i])
-		} else {
-			prepend = append(prepend, existing[i])
+	lst := make([]blockfmt.Descriptor, len(existing))
+	copy(lst, existing)
+	timeSort(lst, primaryTimestamp(lst))
+
+	factor := b.compactFactor()
+	max := b.maxCompactBytes()
+	for len(lst) > 0 {
+		t := b.tier(lst[0].Size)
+		j := 1
+		for j < len(lst) && b.tier(lst[j].Size) == t {
+			j++
+		}
+		run := lst[:j]
+		lst = lst[j:]
+		want := factor
+		if t == 0 {
+			want = len(run)
+		}
+		for len(run) > 0 {
+			n, size := 0, int64(0)
+			for n < len(run) && n < want && size+run[n].Trailer.Decompressed() <= max {
+				size += run[n].Trailer.Decompressed()
+				n++
+			}
+			if n < 2 || (t > 0 && n < factor) {
+				keep = append(keep, run[0])
+				run = run[1:]
+				continue
+			}
+			for i := range run[:n] {
+				b.logf("merging %s (tier %d)", run[i].Path, t)
+			}
+			merge = append(merge, run[:n:n])
+			run = run[n:]
 		}
 	}
-	timeSort(prepend)
-	timeSort(merge)
-	return prepend, merge
+	return keep, merge
 }
@@ -27,6 +27,9 @@ func TestMerge(t *testing.T) {
 	const minMerge = 1024
 	b := Builder{
 		MinMergeSize: minMerge,
+		// each descriptor below decompresses
+		// to 4x its compressed size
+		MaxCompactBytes: 4 * 4200,
 	}
 
 	sizes := func(lst []blockfmt.Descriptor) []int64 {
@@ -41,13 +44,24 @@ func TestMerge(t *testing.T) {
 	}
 
 	cases := []struct {
-		in, prepend, merge []int64
+		in, keep []int64
+		merge    [][]int64
 	}{
-		{in: []int64{minMerge}, prepend: []int64{minMerge}},
-		{in: []int64{minMerge - 1}, merge: []int64{minMerge - 1}},
-		{in: []int64{minMerge, minMerge - 1}, prepend: []int64{minMerge}, merge: []int64{minMerge - 1}},
-		{in: []int64{minMerge, minMerge - 1, minMerge - 2}, prepend: []int64{minMerge}, merge: []int64{minMerge - 1, minMerge - 2}},
-		{in: []int64{minMerge * 2, minMerge, minMerge - 1, minMerge - 2}, prepend: []int64{minMerge * 2, minMerge}, merge: []int64{minMerge - 1, minMerge - 2}},
+		{in: []int64{minMerge}, keep: []int64{minMerge}},
+		// a single small object isn't worth re-writing
+		{in: []int64{minMerge - 1}, keep: []int64{minMerge - 1}},
+		{in: []int64{minMerge, minMerge - 1, minMerge - 2}, keep: []int64{minMerge}, merge: [][]int64{{minMerge - 1, minMerge - 2}}},
+		// fewer than DefaultCompactFactor objects in tier 1
+		{in: []int64{minMerge * 2, minMerge, minMerge - 1, minMerge - 2}, keep: []int64{minMerge * 2, minMerge}, merge: [][]int64{{minMerge - 1, minMerge - 2}}},
+		// DefaultCompactFactor objects in tier 1 are merged into tier 2,
+		// but the first one is too large to fit under the limit
+		{in: []int64{3000, 2000, 1024, 1024, 1024, 1024, 1024}, keep: []int64{3000, 2000, 1024}, merge: [][]int64{{1024, 1024, 1024, 1024}}},
+		{in: []int64{4096}, keep: []int64{4096}},
+		// objects are only merged with time-adjacent
+		// objects in the same tier
+		{in: []int64{1024, 10, 1024, 1024, 1024}, keep: []int64{1024, 10, 1024, 1024, 1024}},
+		// small objects are merged up to the limit
+		{in: []int64{1000, 1000, 1000, 1000, 1000}, keep: []int64{1000}, merge: [][]int64{{1000, 1000, 1000, 1000}}},
 	}
 
 	t0 := date.Now()
@@ -56,20 +70,28 @@ func TestMerge(t *testing.T) {
 		descs := make([]blockfmt.Descriptor, len(in))
 		for i := range descs {
 			descs[i].Size = in[i]
-			// guarantee that time-sorted results
-			// are also size-sorted results
+			descs[i].Trailer = &blockfmt.Trailer{
+				Blocks: []blockfmt.Blockdesc{{Chunks: int(4 * in[i])}},
+			}
+			// without any sparse indexes, objects
+			// are ordered by modification time
 			descs[i].LastModified = t0.Add(time.Second * time.Duration(i))
 		}
-		gotp, gotm := b.decideMerge(descs)
-		if len(gotp)+len(gotm) != len(descs) {
-			t.Errorf("case %d: got %d entries back; expected %d", i, len(gotp)+len(gotm), len(descs))
+		gotk, gotm := b.decideMerge(descs)
+		total := len(gotk)
+		var msizes [][]int64
+		for j := range gotm {
+			total += len(gotm[j])
+			msizes = append(msizes, sizes(gotm[j]))
+		}
+		if total != len(descs) {
+			t.Errorf("case %d: got %d entries back; expected %d", i, total, len(descs))
 			continue
 		}
-		psizes := sizes(gotp)
-		if !reflect.DeepEqual(psizes, cases[i].prepend) {
-			t.Errorf("case %d: got prepend %v; expected %v", i, psizes, cases[i].prepend)
+		ksizes := sizes(gotk)
+		if !reflect.DeepEqual(ksizes, cases[i].keep) {
+			t.Errorf("case %d: got keep %v; expected %v", i, ksizes, cases[i].keep)
 		}
-		msizes := sizes(gotm)
 		if !reflect.DeepEqual(msizes, cases[i].merge) {
 			t.Errorf("case %d: got merge %v; expected %v", i, msizes, cases[i].merge)
 		}
@@ -55,6 +55,22 @@ type QueueItem interface {
 	ETag() string
 }
 
+// QueueDataItem is a QueueItem that holds
+// its own contents rather than referring
+// to a file (for example, a batch of records
+// read from a stream).
+type QueueDataItem interface {
+	QueueItem
+	// Open should return the contents of the item
+	// framed for the RowFormat with the given name
+	// (see blockfmt.RowFormat.Name), or an error if
+	// the item cannot be represented in that format.
+	Open(format string) (io.ReadCloser, error)
+	// Size should return the approximate size
+	// of the contents of the item.
+	Size() int64
+}
+
 type Queue interface {
 	// Next should return the next item
 	// in the queue. If the provided pause
@@ -144,7 +160,7 @@ func (s QueueStatus) Merge(other QueueStatus) QueueStatus {
 func errResult(err error) QueueStatus {
 	if err == nil {
 		return StatusOK
-	} else if err == ErrBuildAgain {
+	} else if errors.Is(err, ErrBuildAgain) {
 		return StatusTryAgain
 	}
 	return StatusWriteError
@@ -170,6 +186,33 @@ outer:
 			if err != nil || !match {
 				continue
 			}
+			if di, ok := q.inputs[i].(QueueDataItem); ok {
+				fm := bld.Format(def.Inputs[j].Format, p)
+				if fm == nil {
+					return fmt.Errorf("cannot determine format of %q", p)
+				}
+				err = fm.UseHints(def.Inputs[j].Hints)
+				if err != nil {
+					return err
+				}
+				r, err := di.Open(fm.Name())
+				if err != nil {
+					// the item must not be committed
+					// without being ingested, so it is
+					// retried until the definition is fixed
+					q.indirect = append(q.indirect, i)
+					return err
+				}
+				q.indirect = append(q.indirect, i)
+				q.filtered = append(q.filtered, blockfmt.Input{
+					Path: p,
+					ETag: etag,
+					Size: di.Size(),
+					R:    r,
+					F:    fm,
+				})
+				break
+			}
 			infs, name, err := q.Owner.Split(p)
 			if err != nil {
 				return err
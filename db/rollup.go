@@ -0,0 +1,368 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/expr/partiql"
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/vm"
+)
+
+// Rollup is a materialized aggregation over
+// the rows of a table. The aggregation is evaluated
+// over each batch of rows as it is ingested, and
+// the partial aggregates for the batch are appended
+// to the rollup table.
+//
+// The query must have the form
+//
+//	SELECT <key> AS k, ..., <agg>(<expr>) AS a, ...
+//	FROM <table>
+//	[WHERE <predicate>]
+//	[GROUP BY <key>, ...]
+//
+// where each aggregate is one of COUNT, SUM, MIN or MAX,
+// and each non-aggregate column appears in the GROUP BY clause.
+// Field references must not be qualified by the table name.
+//
+// Since each batch produces its own partial aggregates,
+// the rollup table may contain multiple rows for each
+// group. Queries should merge the partial aggregates
+// by grouping on the same keys and using SUM for
+// COUNT and SUM columns, MIN for MIN columns and
+// MAX for MAX columns.
+type Rollup struct {
+	// Name is the name of the table in
+	// the same database to which the
+	// partial aggregates are appended.
+	// The table must not have a definition
+	// of its own, and it must not be the
+	// dead-letter table of any table.
+	Name string `json:"name"`
+	// Query is the text of the aggregation query.
+	Query string `json:"query"`
+}
+
+// rollupPlan is a compiled Rollup
+type rollupPlan struct {
+	name  string
+	where expr.Node
+	agg   vm.Aggregation
+	by    vm.Selection
+}
+
+func mergeable(op expr.AggregateOp) bool {
+	switch op {
+	case expr.OpCount, expr.OpSum, expr.OpMin, expr.OpMax:
+		return true
+	}
+	return false
+}
+
+// compile parses and validates r.Query
+func (r *Rollup) compile() (*rollupPlan, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("rollup: missing name")
+	}
+	q, err := partiql.Parse([]byte(r.Query))
+	if err != nil {
+		return nil, fmt.Errorf("rollup %s: %w", r.Name, err)
+	}
+	sel, ok := q.Body.(*expr.Select)
+	if !ok || len(q.With) > 0 {
+		return nil, fmt.Errorf("rollup %s: query must be a single SELECT", r.Name)
+	}
+	if sel.Distinct || sel.Having != nil || sel.OrderBy != nil || sel.Limit != nil || sel.Offset != nil {
+		return nil, fmt.Errorf("rollup %s: DISTINCT, HAVING, ORDER BY, LIMIT and OFFSET are not supported", r.Name)
+	}
+	if t, ok := sel.From.(*expr.Table); !ok {
+		return nil, fmt.Errorf("rollup %s: FROM must name a table", r.Name)
+	} else if _, ok := t.Expr.(*expr.Path); !ok {
+		return nil, fmt.Errorf("rollup %s: FROM must name a table", r.Name)
+	}
+	if sel.Where != nil {
+		// reject predicates that the vm cannot
+		// evaluate here rather than when the
+		// first batch of rows is ingested
+		if err := expr.Check(sel.Where); err != nil {
+			return nil, fmt.Errorf("rollup %s: WHERE: %w", r.Name, err)
+		}
+		if _, err := vm.NewFilter(sel.Where, &vm.Count{}); err != nil {
+			return nil, fmt.Errorf("rollup %s: WHERE: %w", r.Name, err)
+		}
+	}
+	p := &rollupPlan{name: r.Name, where: sel.Where}
+	for i := range sel.Columns {
+		b := &sel.Columns[i]
+		if a, ok := b.Expr.(*expr.Aggregate); ok {
+			if !mergeable(a.Op) {
+				return nil, fmt.Errorf("rollup %s: aggregate %s cannot be merged", r.Name, expr.ToString(a))
+			}
+			p.agg = append(p.agg, vm.AggBinding{Expr: a, Result: b.Result()})
+			continue
+		}
+		grouped := false
+		for j := range sel.GroupBy {
+			g := sel.GroupBy[j].Expr
+			// GROUP BY may refer to
+			// the column by its alias
+			if p, ok := g.(*expr.Path); ok && p.Rest == nil && p.First == b.Result() {
+				g = b.Expr
+			}
+			if g.Equals(b.Expr) {
+				grouped = true
+				break
+			}
+		}
+		if !grouped {
+			return nil, fmt.Errorf("rollup %s: column %s is neither aggregated nor grouped", r.Name, expr.ToString(b.Expr))
+		}
+		p.by = append(p.by, expr.Bind(b.Expr, b.Result()))
+	}
+	if len(p.agg) == 0 {
+		return nil, fmt.Errorf("rollup %s: no aggregates", r.Name)
+	}
+	if len(p.by) != len(sel.GroupBy) {
+		return nil, fmt.Errorf("rollup %s: each GROUP BY expression must be selected", r.Name)
+	}
+	return p, nil
+}
+
+// rollupRun is the evaluation of a
+// rollupPlan over a batch of rows
+type rollupRun struct {
+	plan *rollupPlan
+	out  sideTable
+	head vm.QuerySink
+
+	lock   sync.Mutex
+	blocks [][]byte
+	rows   int64
+}
+
+// start builds the query pipeline
+func (p *rollupPlan) start(st *tableState) (*rollupRun, error) {
+	var err error
+	run := &rollupRun{
+		plan: p,
+		out:  sideTable{st: st.sibling(p.name), source: st.table},
+	}
+	dst := vm.LockedSink(run)
+	if len(p.by) > 0 {
+		run.head, err = vm.NewHashAggregate(p.agg, p.by, dst)
+	} else {
+		run.head, err = vm.NewAggregate(p.agg, dst)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rollup %s: %w", p.name, err)
+	}
+	if p.where != nil {
+		run.head, err = vm.NewFilter(p.where, run.head)
+		if err != nil {
+			return nil, fmt.Errorf("rollup %s: compiling query: %w", p.name, err)
+		}
+	}
+	return run, nil
+}
+
+// Write accepts the output of the query
+func (r *rollupRun) Write(p []byte) (int, error) {
+	r.blocks = append(r.blocks, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+// open opens an input stream for the query
+func (r *rollupRun) open() (*rollupTee, error) {
+	w, err := r.head.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &rollupTee{run: r, w: w}, nil
+}
+
+// abort discards the query results
+func (r *rollupRun) abort() {
+	r.head.Close()
+	r.blocks = nil
+}
+
+// commit finishes the query and appends
+// the partial aggregates to the rollup table
+func (r *rollupRun) commit() error {
+	if r.rows == 0 {
+		// don't produce partial aggregates for
+		// empty batches (or for batches whose rows
+		// have all been aggregated already)
+		r.abort()
+		return nil
+	}
+	err := r.head.Close()
+	if err != nil {
+		return fmt.Errorf("rollup %s: %w", r.plan.name, err)
+	}
+	err = r.out.open()
+	if err != nil {
+		return err
+	}
+	for i := range r.blocks {
+		_, err = r.out.cn.Write(r.blocks[i])
+		if err != nil {
+			r.out.abort()
+			return err
+		}
+	}
+	return r.out.commit()
+}
+
+// teeFlushSize is the size at which rows
+// are passed on to the rollup query
+const teeFlushSize = vm.PageSize / 4
+
+// rollupTee buffers rows for
+// one input stream of a rollupRun
+type rollupTee struct {
+	run  *rollupRun
+	w    io.WriteCloser
+	st   ion.Symtab
+	hdr  ion.Buffer
+	body ion.Buffer
+	rows int64
+}
+
+// use sets the symbol table for the rows
+// that follow; the tee must have been flushed
+func (t *rollupTee) use(st *ion.Symtab) {
+	st.CloneInto(&t.st)
+}
+
+func (t *rollupTee) add(row *ion.Struct) error {
+	row.Encode(&t.body, &t.st)
+	return t.commit()
+}
+
+// addRaw adds an encoded row whose symbols
+// refer to the symbol table passed to use
+func (t *rollupTee) addRaw(rec []byte) error {
+	t.body.UnsafeAppend(rec)
+	return t.commit()
+}
+
+func (t *rollupTee) commit() error {
+	t.rows++
+	if t.body.Size() >= teeFlushSize {
+		return t.flush()
+	}
+	return nil
+}
+
+func (t *rollupTee) flush() error {
+	if t.body.Size() == 0 {
+		return nil
+	}
+	t.hdr.Reset()
+	t.st.Marshal(&t.hdr, true)
+	t.hdr.UnsafeAppend(t.body.Bytes())
+	t.body.Reset()
+	_, err := t.w.Write(t.hdr.Bytes())
+	return err
+}
+
+func (t *rollupTee) Close() error {
+	err := t.flush()
+	err2 := t.w.Close()
+	if err == nil {
+		err = err2
+	}
+	t.run.lock.Lock()
+	t.run.rows += t.rows
+	t.run.lock.Unlock()
+	return err
+}
+
+// rollups returns the rollup queries
+// for the given definition, ready to be
+// evaluated over a new batch of rows
+func (st *tableState) rollups(def *Definition) ([]*rollupRun, error) {
+	var out []*rollupRun
+	var deadLetter map[string]string
+	for i := range def.Rollups {
+		p, err := def.Rollups[i].compile()
+		if err != nil {
+			return nil, fmt.Errorf("table %s/%s: %w", st.db, st.table, err)
+		}
+		if p.name == st.table {
+			return nil, fmt.Errorf("table %s/%s: rollup table cannot be the table itself", st.db, st.table)
+		}
+		for j := range out {
+			if out[j].plan.name == p.name {
+				return nil, fmt.Errorf("table %s/%s: more than one rollup into table %s", st.db, st.table, p.name)
+			}
+		}
+		// the rollup table must only be populated
+		// by rollups, since the Inputs of its index
+		// record the objects that have been aggregated
+		if _, err := st.sibling(p.name).def(); err == nil {
+			return nil, fmt.Errorf("table %s/%s: rollup table %s has its own definition", st.db, st.table, p.name)
+		}
+		if deadLetter == nil {
+			deadLetter, err = st.deadLetterTables()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if src, ok := deadLetter[p.name]; ok {
+			return nil, fmt.Errorf("table %s/%s: rollup table %s is the dead-letter table of %s", st.db, st.table, p.name, src)
+		}
+		run, err := p.start(st)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+	return out, nil
+}
+
+// deadLetterTables returns the dead-letter tables
+// of the tables in the database, mapped to the
+// table whose rejected rows they hold
+func (st *tableState) deadLetterTables() (map[string]string, error) {
+	defs, err := fs.Glob(st.ofs, path.Join("db", st.db, "*", "definition.[yj][sa][om][nl]"))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	for i := range defs {
+		table := path.Base(path.Dir(defs[i]))
+		def, err := OpenDefinition(st.ofs, st.db, table)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if def.Schema != nil {
+			out[def.Schema.deadLetterTable(table)] = table
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,237 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func TestRollup(t *testing.T) {
+	checkFiles(t)
+	tmpdir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(tmpdir, "in"), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dfs := NewDirFS(tmpdir)
+	defer dfs.Close()
+	dfs.Log = t.Logf
+	// index updates succeed until
+	// root.failed is reset below
+	owner := &failOnceTenant{
+		testTenant: newTenant(dfs),
+		root:       &failOnceFS{OutputFS: dfs, path: IndexPath("default", "requests"), failed: true},
+	}
+	err = WriteDefinition(dfs, "default", &Definition{
+		Name:   "requests",
+		Inputs: []Input{{Pattern: "file://in/*.json"}},
+		Rollups: []Rollup{{
+			Name:  "requests_by_service",
+			Query: "SELECT service, COUNT(*) AS n, SUM(bytes) AS bytes FROM requests WHERE status = 200 GROUP BY service",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := Builder{
+		Align: 1024,
+		Logf:  t.Logf,
+	}
+	write := func(name string, rows ...string) {
+		err := os.WriteFile(filepath.Join(tmpdir, "in", name), []byte(strings.Join(rows, "\n")), 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = b.Sync(owner, "default", "*")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// totals merges the partial aggregates
+	// in the rollup table
+	totals := func() (map[string][2]int64, int) {
+		st, err := b.open("default", "requests_by_service", owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx, err := st.index()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc := rowCollector{}
+		for i := range idx.Inline {
+			err := st.collectRows(&idx.Inline[i], &rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		out := make(map[string][2]int64)
+		for i := range rc.rows {
+			s := rc.rows[i].row.(*ion.Struct)
+			svc := s.FieldByName("service")
+			n := s.FieldByName("n")
+			bytes := s.FieldByName("bytes")
+			if svc == nil || n == nil || bytes == nil {
+				t.Fatalf("unexpected rollup row %v", s)
+			}
+			key := string(svc.Value.(ion.String))
+			v := out[key]
+			v[0] += int64(n.Value.(ion.Uint))
+			v[1] += int64(bytes.Value.(ion.Uint))
+			out[key] = v
+		}
+		return out, len(rc.rows)
+	}
+
+	write("a.json",
+		`{"service": "api", "status": 200, "bytes": 100}`,
+		`{"service": "api", "status": 200, "bytes": 50}`,
+		`{"service": "web", "status": 200, "bytes": 10}`,
+		`{"service": "web", "status": 500, "bytes": 1000}`,
+	)
+	got, n := totals()
+	if n != 2 || got["api"] != [2]int64{2, 150} || got["web"] != [2]int64{1, 10} {
+		t.Fatalf("after first sync: %v (%d rows)", got, n)
+	}
+	// the second batch is aggregated on its own,
+	// even though it may be merged with the
+	// first object in the table itself
+	write("b.json",
+		`{"service": "web", "status": 200, "bytes": 5}`,
+		`{"service": "db", "status": 200, "bytes": 1}`,
+	)
+	got, n = totals()
+	if n != 4 || got["api"] != [2]int64{2, 150} || got["web"] != [2]int64{2, 15} || got["db"] != [2]int64{1, 1} {
+		t.Fatalf("after second sync: %v (%d rows)", got, n)
+	}
+	// syncing with no new inputs
+	// doesn't produce any partial aggregates
+	err = b.Sync(owner, "default", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, n = totals(); n != 4 {
+		t.Fatalf("after empty sync: %d rows", n)
+	}
+
+	// if the index of the table cannot be
+	// updated, then the batch is aggregated
+	// again when it is retried, but the partial
+	// aggregates are only appended once
+	owner.root.failed = false
+	err = os.WriteFile(filepath.Join(tmpdir, "in", "c.json"), []byte(`{"service": "db", "status": 200, "bytes": 2}`), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Sync(owner, "default", "*")
+	if err == nil || !owner.root.failed {
+		t.Fatalf("expected injected failure; got %v", err)
+	}
+	err = b.Sync(owner, "default", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, n = totals()
+	if n != 5 || got["api"] != [2]int64{2, 150} || got["web"] != [2]int64{2, 15} || got["db"] != [2]int64{2, 3} {
+		t.Fatalf("after retried sync: %v (%d rows)", got, n)
+	}
+	// ... and rebuilding the table
+	// doesn't append them again either
+	b.Force = true
+	err = b.Sync(owner, "default", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got2, n2 := totals(); n2 != n || len(got2) != len(got) || got2["db"] != got["db"] {
+		t.Fatalf("after rebuild: %v (%d rows)", got2, n2)
+	}
+}
+
+func TestRollupTarget(t *testing.T) {
+	dfs := NewDirFS(t.TempDir())
+	defer dfs.Close()
+	owner := newTenant(dfs)
+	for _, def := range []*Definition{
+		{Name: "events", Schema: &RowSchema{}},
+		{Name: "other"},
+	} {
+		err := WriteDefinition(dfs, "default", def)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := Builder{Align: 1024, Logf: t.Logf}
+	st, err := b.open("default", "requests", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollup := func(names ...string) *Definition {
+		def := &Definition{Name: "requests"}
+		for _, name := range names {
+			def.Rollups = append(def.Rollups, Rollup{Name: name, Query: "SELECT COUNT(*) AS n FROM requests"})
+		}
+		return def
+	}
+	if _, err := st.rollups(rollup("counts")); err != nil {
+		t.Fatal(err)
+	}
+	for _, names := range [][]string{
+		{"requests"},
+		{"other"},
+		{"events_dead_letter"},
+		{"counts", "counts"},
+	} {
+		if _, err := st.rollups(rollup(names...)); err == nil {
+			t.Errorf("rollups into %v: expected an error", names)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+func TestRollupCompile(t *testing.T) {
+	ok := []string{
+		"SELECT COUNT(*) AS n FROM t",
+		"SELECT x, MIN(y) AS lo, MAX(y) AS hi FROM t GROUP BY x",
+		"SELECT x AS k, SUM(y) AS s FROM t WHERE y > 0 GROUP BY k",
+	}
+	for i := range ok {
+		r := Rollup{Name: "r", Query: ok[i]}
+		if _, err := r.compile(); err != nil {
+			t.Errorf("%s: %s", ok[i], err)
+		}
+	}
+	bad := []string{
+		"SELECT AVG(y) AS a FROM t",
+		"SELECT x, COUNT(*) AS n FROM t",
+		"SELECT x FROM t GROUP BY x",
+		"SELECT COUNT(*) AS n FROM t GROUP BY x",
+		"SELECT COUNT(*) AS n FROM t ORDER BY n",
+		"SELECT x, COUNT(*) AS n FROM (SELECT * FROM t) GROUP BY x",
+		"SELECT COUNT(*) AS n FROM t WHERE 'abc' + 1 = 2",
+		"SELECT COUNT(*) AS n FROM t WHERE SUM(y) > 0",
+	}
+	for i := range bad {
+		r := Rollup{Name: "r", Query: bad[i]}
+		if _, err := r.compile(); err == nil {
+			t.Errorf("%s: expected an error", bad[i])
+		}
+	}
+}
@@ -0,0 +1,556 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// RowSchema is a declarative schema
+// that is enforced on each row as it is ingested.
+//
+// Rows that satisfy the schema (possibly after
+// coercing some values) are written to the table.
+// Rows that violate the schema are written to
+// the dead-letter table instead, as a structure
+// containing the fields "error" (a description
+// of the violation), "source" (the path of the
+// input object) and "row" (the original row).
+type RowSchema struct {
+	// Fields is the list of rules
+	// applied to the fields of each row.
+	Fields []FieldRule `json:"fields"`
+	// DeadLetter is the name of the table
+	// in the same database to which rows that
+	// violate the schema are written.
+	// If DeadLetter is empty, the name of the
+	// table with the suffix "_dead_letter" is used.
+	DeadLetter string `json:"dead_letter,omitempty"`
+}
+
+// FieldRule is a rule for a single
+// field in a RowSchema.
+type FieldRule struct {
+	// Path is the path of the field
+	// with components separated by '.'
+	Path string `json:"path"`
+	// Type, if non-empty, is the required type
+	// of the field. Valid types are "bool", "int",
+	// "float", "decimal", "timestamp", "string",
+	// "symbol", "blob", "list" and "struct".
+	// NULL values always satisfy the type.
+	Type string `json:"type,omitempty"`
+	// Required indicates that the field
+	// must be present and must not be NULL.
+	Required bool `json:"required,omitempty"`
+	// Coerce is the list of types from which
+	// values may be converted to Type.
+	// The supported conversions are:
+	//
+	//   int:       from float (if integral), bool,
+	//              string, timestamp (Unix seconds)
+	//   float:     from int, string
+	//   string:    from int, float, bool, timestamp, symbol
+	//   timestamp: from string, int (Unix seconds)
+	//   bool:      from int (0 or 1), string
+	//
+	// Values that cannot be converted
+	// violate the schema.
+	Coerce []string `json:"coerce,omitempty"`
+}
+
+var schemaTypes = []string{
+	"bool", "int", "float", "decimal", "timestamp",
+	"string", "symbol", "blob", "list", "struct",
+}
+
+func validType(name string) bool {
+	for i := range schemaTypes {
+		if schemaTypes[i] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// deadLetterTable returns the name of
+// the dead-letter table for table
+func (s *RowSchema) deadLetterTable(table string) string {
+	if s.DeadLetter != "" {
+		return s.DeadLetter
+	}
+	return table + "_dead_letter"
+}
+
+// fieldCheck is a compiled FieldRule
+type fieldCheck struct {
+	name     string
+	path     []string
+	typ      string
+	required bool
+	coerce   []string
+}
+
+// rowChecker is a compiled RowSchema
+type rowChecker struct {
+	fields []fieldCheck
+}
+
+// compile checks that s is valid
+// and returns the compiled schema
+func (s *RowSchema) compile() (*rowChecker, error) {
+	rc := &rowChecker{}
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if f.Path == "" {
+			return nil, fmt.Errorf("schema field %d: missing path", i)
+		}
+		if f.Type != "" && !validType(f.Type) {
+			return nil, fmt.Errorf("schema field %q: unknown type %q", f.Path, f.Type)
+		}
+		for _, from := range f.Coerce {
+			if !validType(from) {
+				return nil, fmt.Errorf("schema field %q: unknown type %q", f.Path, from)
+			}
+			if f.Type == "" {
+				return nil, fmt.Errorf("schema field %q: coercion without a type", f.Path)
+			}
+		}
+		rc.fields = append(rc.fields, fieldCheck{
+			name:     f.Path,
+			path:     strings.Split(f.Path, "."),
+			typ:      f.Type,
+			required: f.Required,
+			coerce:   f.Coerce,
+		})
+	}
+	return rc, nil
+}
+
+// symbolValue is a symbol datum that
+// is re-interned when it is encoded
+type symbolValue string
+
+func (s symbolValue) Type() ion.Type { return ion.SymbolType }
+
+func (s symbolValue) Encode(dst *ion.Buffer, st *ion.Symtab) {
+	dst.WriteSymbol(st.Intern(string(s)))
+}
+
+// resolve replaces the symbol values in d,
+// which refer to st, with symbolValues
+func resolve(d ion.Datum, st *ion.Symtab) ion.Datum {
+	switch d := d.(type) {
+	case ion.Symbol:
+		return symbolValue(st.Get(d))
+	case *ion.Struct:
+		for i := range d.Fields {
+			d.Fields[i].Value = resolve(d.Fields[i].Value, st)
+		}
+	case ion.List:
+		for i := range d {
+			d[i] = resolve(d[i], st)
+		}
+	}
+	return d
+}
+
+func typeName(d ion.Datum) string {
+	switch d.(type) {
+	case ion.Bool:
+		return "bool"
+	case ion.Int, ion.Uint, *ion.BigInt:
+		return "int"
+	case ion.Float:
+		return "float"
+	case *ion.BigNum:
+		return "decimal"
+	case ion.Timestamp:
+		return "timestamp"
+	case ion.String:
+		return "string"
+	case symbolValue:
+		return "symbol"
+	case ion.Blob:
+		return "blob"
+	case ion.List:
+		return "list"
+	case *ion.Struct:
+		return "struct"
+	case ion.UntypedNull:
+		return "null"
+	}
+	return d.Type().String()
+}
+
+// coerce converts v to the type named by to
+func coerce(v ion.Datum, to string) (ion.Datum, bool) {
+	switch to {
+	case "int":
+		switch v := v.(type) {
+		case ion.Float:
+			f := float64(v)
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+				return nil, false
+			}
+			return ion.Int(int64(f)), true
+		case ion.Bool:
+			if v {
+				return ion.Int(1), true
+			}
+			return ion.Int(0), true
+		case ion.String:
+			i, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+			return ion.Int(i), err == nil
+		case ion.Timestamp:
+			return ion.Int(date.Time(v).Unix()), true
+		}
+	case "float":
+		switch v := v.(type) {
+		case ion.Int:
+			return ion.Float(float64(v)), true
+		case ion.Uint:
+			return ion.Float(float64(v)), true
+		case ion.String:
+			f, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+			return ion.Float(f), err == nil
+		}
+	case "string":
+		switch v := v.(type) {
+		case ion.Int:
+			return ion.String(strconv.FormatInt(int64(v), 10)), true
+		case ion.Uint:
+			return ion.String(strconv.FormatUint(uint64(v), 10)), true
+		case ion.Float:
+			return ion.String(strconv.FormatFloat(float64(v), 'g', -1, 64)), true
+		case ion.Bool:
+			return ion.String(strconv.FormatBool(bool(v))), true
+		case ion.Timestamp:
+			return ion.String(date.Time(v).Time().Format(time.RFC3339Nano)), true
+		case symbolValue:
+			return ion.String(v), true
+		}
+	case "timestamp":
+		switch v := v.(type) {
+		case ion.String:
+			t, ok := date.Parse([]byte(strings.TrimSpace(string(v))))
+			return ion.Timestamp(t), ok
+		case ion.Int:
+			return ion.Timestamp(date.Unix(int64(v), 0)), true
+		case ion.Uint:
+			if v > math.MaxInt64 {
+				return nil, false
+			}
+			return ion.Timestamp(date.Unix(int64(v), 0)), true
+		}
+	case "bool":
+		switch v := v.(type) {
+		case ion.Int:
+			return ion.Bool(v == 1), v == 0 || v == 1
+		case ion.Uint:
+			return ion.Bool(v == 1), v == 0 || v == 1
+		case ion.String:
+			b, err := strconv.ParseBool(strings.TrimSpace(string(v)))
+			return ion.Bool(b), err == nil
+		}
+	}
+	return nil, false
+}
+
+// lookup returns the field at path within s
+func lookup(s *ion.Struct, path []string) *ion.Field {
+	for {
+		f := s.FieldByName(path[0])
+		if f == nil || len(path) == 1 {
+			return f
+		}
+		inner, ok := f.Value.(*ion.Struct)
+		if !ok {
+			return nil
+		}
+		s, path = inner, path[1:]
+	}
+}
+
+// rawField returns the encoded value at path
+// within the encoded struct body, or nil
+func rawField(st *ion.Symtab, body []byte, path []string) []byte {
+	for {
+		sym, ok := st.Symbolize(path[0])
+		if !ok {
+			return nil
+		}
+		var val []byte
+		for len(body) > 0 {
+			field, rest, err := ion.ReadLabel(body)
+			if err != nil {
+				return nil
+			}
+			size := ion.SizeOf(rest)
+			if size <= 0 || size > len(rest) {
+				return nil
+			}
+			if field == sym {
+				val = rest[:size]
+				break
+			}
+			body = rest[size:]
+		}
+		if val == nil || len(path) == 1 {
+			return val
+		}
+		if ion.TypeOf(val) != ion.StructType {
+			return nil
+		}
+		body, _ = ion.Contents(val)
+		path = path[1:]
+	}
+}
+
+// rawTypeName is typeName for an encoded value;
+// it returns "" for values that typeName may
+// describe differently once they are decoded
+// (typed nulls and annotated values)
+func rawTypeName(val []byte) string {
+	if len(val) == 0 {
+		return "null"
+	}
+	t := ion.TypeOf(val)
+	if t != ion.NullType && t != ion.BoolType && val[0]&0x0f == 0x0f {
+		return ""
+	}
+	switch t {
+	case ion.NullType:
+		return "null"
+	case ion.BoolType:
+		if val[0] == 0x1f {
+			return ""
+		}
+		return "bool"
+	case ion.UintType, ion.IntType:
+		return "int"
+	case ion.FloatType:
+		return "float"
+	case ion.DecimalType:
+		return "decimal"
+	case ion.TimestampType:
+		return "timestamp"
+	case ion.StringType:
+		return "string"
+	case ion.SymbolType:
+		return "symbol"
+	case ion.BlobType, ion.ClobType:
+		return "blob"
+	case ion.ListType, ion.SexpType:
+		return "list"
+	case ion.StructType:
+		return "struct"
+	}
+	return ""
+}
+
+// valid reports whether the encoded row rec,
+// whose symbols refer to st, satisfies the
+// schema without any coercion; rows for which
+// valid returns false must be decoded and
+// passed to check
+func (rc *rowChecker) valid(st *ion.Symtab, rec []byte) bool {
+	body, _ := ion.Contents(rec)
+	for i := range rc.fields {
+		fc := &rc.fields[i]
+		typ := rawTypeName(rawField(st, body, fc.path))
+		switch {
+		case typ == "":
+			return false
+		case typ == "null":
+			if fc.required {
+				return false
+			}
+		case fc.typ != "" && typ != fc.typ:
+			return false
+		}
+	}
+	return true
+}
+
+// check checks row against the schema,
+// coercing fields in place if necessary
+func (rc *rowChecker) check(row *ion.Struct) error {
+	for i := range rc.fields {
+		fc := &rc.fields[i]
+		f := lookup(row, fc.path)
+		if f == nil || typeName(f.Value) == "null" {
+			if fc.required {
+				return fmt.Errorf("missing required field %q", fc.name)
+			}
+			continue
+		}
+		if fc.typ == "" {
+			continue
+		}
+		from := typeName(f.Value)
+		if from == fc.typ {
+			continue
+		}
+		allowed := false
+		for j := range fc.coerce {
+			if fc.coerce[j] == from {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("field %q: expected %s, found %s", fc.name, fc.typ, from)
+		}
+		v, ok := coerce(f.Value, fc.typ)
+		if !ok {
+			return fmt.Errorf("field %q: cannot convert %s to %s", fc.name, from, fc.typ)
+		}
+		f.Value = v
+	}
+	return nil
+}
+
+// noteTimes records the time ranges of the
+// timestamps in s, which has just been encoded
+// using the symbol table of c
+func noteTimes(c *ion.Chunker, s *ion.Struct, prefix []ion.Symbol) {
+	var buf ion.Symbuf
+	for i := range s.Fields {
+		switch v := s.Fields[i].Value.(type) {
+		case ion.Timestamp:
+			buf.Prepare(len(prefix) + 1)
+			for _, sym := range prefix {
+				buf.Push(sym)
+			}
+			buf.Push(s.Fields[i].Sym)
+			c.Ranges.AddTime(buf, date.Time(v))
+		case *ion.Struct:
+			noteTimes(c, v, append(prefix, s.Fields[i].Sym))
+		}
+	}
+}
+
+// noteRawTimes is noteTimes for an encoded
+// struct body whose symbols refer to c.Symbols
+func noteRawTimes(c *ion.Chunker, body []byte, prefix []ion.Symbol) {
+	var buf ion.Symbuf
+	for len(body) > 0 {
+		sym, rest, err := ion.ReadLabel(body)
+		if err != nil {
+			return
+		}
+		size := ion.SizeOf(rest)
+		if size <= 0 || size > len(rest) {
+			return
+		}
+		val := rest[:size]
+		body = rest[size:]
+		switch ion.TypeOf(val) {
+		case ion.TimestampType:
+			t, _, err := ion.ReadTime(val)
+			if err != nil {
+				continue
+			}
+			buf.Prepare(len(prefix) + 1)
+			for _, s := range prefix {
+				buf.Push(s)
+			}
+			buf.Push(sym)
+			c.Ranges.AddTime(buf, t)
+		case ion.StructType:
+			inner, _ := ion.Contents(val)
+			noteRawTimes(c, inner, append(prefix, sym))
+		}
+	}
+}
+
+// deadLetter collects rows that violate a RowSchema
+// and appends them to the dead-letter table
+type deadLetter struct {
+	sideTable
+
+	lock sync.Mutex
+	rows int64
+}
+
+// add writes a row to the dead-letter output
+func (d *deadLetter) add(row *ion.Struct, source string, cause error) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := d.open(); err != nil {
+		return err
+	}
+	rec := ion.Struct{Fields: []ion.Field{
+		{Label: "error", Value: ion.String(cause.Error())},
+		{Label: "source", Value: ion.String(source)},
+		{Label: "row", Value: row},
+	}}
+	var snap ion.Snapshot
+	d.cn.Save(&snap)
+	rec.Encode(&d.cn.Buffer, &d.cn.Symbols)
+	if d.cn.CheckSize() != nil {
+		// the original row doesn't fit
+		// alongside the error; keep the error
+		d.cn.Load(&snap)
+		rec.Fields = rec.Fields[:2]
+		rec.Encode(&d.cn.Buffer, &d.cn.Symbols)
+	}
+	d.rows++
+	return d.cn.Commit()
+}
+
+// commit appends the rejected rows, if any,
+// to the dead-letter table
+func (d *deadLetter) commit() error {
+	if d.out == nil {
+		return nil
+	}
+	d.st.conf.logf("table %s: writing %d rejected rows", d.st.table, d.rows)
+	return d.sideTable.commit()
+}
+
+// rowSchema returns the compiled schema
+// of the table and the dead-letter output
+// for rows that violate it, or nil if the
+// table does not have a schema
+func (st *tableState) rowSchema(def *Definition) (*rowChecker, *deadLetter, error) {
+	if def.Schema == nil {
+		return nil, nil, nil
+	}
+	rc, err := def.Schema.compile()
+	if err != nil {
+		return nil, nil, fmt.Errorf("table %s/%s: %w", st.db, st.table, err)
+	}
+	name := def.Schema.deadLetterTable(st.table)
+	if name == st.table {
+		return nil, nil, fmt.Errorf("table %s/%s: dead-letter table cannot be the table itself", st.db, st.table)
+	}
+	dl := &deadLetter{sideTable: sideTable{st: st.sibling(name), source: st.table}}
+	if _, err := dl.st.def(); err == nil {
+		// the Inputs of the index record the
+		// source objects of the rejected rows
+		return nil, nil, fmt.Errorf("table %s/%s: dead-letter table %s has its own definition", st.db, st.table, name)
+	}
+	return rc, dl, nil
+}
@@ -0,0 +1,355 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+func TestRowSchema(t *testing.T) {
+	checkFiles(t)
+	tmpdir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(tmpdir, "in"), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dfs := NewDirFS(tmpdir)
+	defer dfs.Close()
+	dfs.Log = t.Logf
+	owner := newTenant(dfs)
+	err = WriteDefinition(dfs, "default", &Definition{
+		Name:   "events",
+		Inputs: []Input{{Pattern: "file://in/*.json"}},
+		Schema: &RowSchema{
+			Fields: []FieldRule{
+				{Path: "id", Type: "int", Required: true},
+				{Path: "amount", Type: "float", Coerce: []string{"int", "string"}},
+				{Path: "meta.tag", Type: "string", Coerce: []string{"int"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []string{
+		`{"id": 1, "amount": 2, "meta": {"tag": 7}}`,
+		`{"id": "x", "amount": 1.5}`,
+		`{"amount": 1.5}`,
+		`{"id": 4, "amount": "abc"}`,
+		`{"id": 5, "amount": null}`,
+		`{"id": 6, "amount": "3.25", "meta": {"tag": "t"}}`,
+		`{"id": 7, "meta": {"tag": true}}`,
+	}
+	err = os.WriteFile(filepath.Join(tmpdir, "in", "rows.json"), []byte(strings.Join(rows, "\n")), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := Builder{
+		Align: 1024,
+		Logf:  t.Logf,
+	}
+	err = b.Sync(owner, "default", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := OpenIndex(dfs, "default", "events", owner.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ids := countRows(t, dfs, idx)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 5 || ids[2] != 6 {
+		t.Errorf("ingested ids %v", ids)
+	}
+	sc, err := OpenSchema(dfs, "default", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount := sc.Field([]string{"amount"})
+	if amount == nil || amount.Types[ion.FloatType] != 2 || amount.Types[ion.NullType] != 1 || amount.Types.Total() != 3 {
+		t.Errorf("schema of amount: %+v", amount)
+	}
+	tag := sc.Field([]string{"meta", "tag"})
+	if tag == nil || tag.Types[ion.StringType] != 2 || tag.Types.Total() != 2 {
+		t.Errorf("schema of meta.tag: %+v", tag)
+	}
+
+	// the rejected rows are in the dead-letter table
+	dl, err := b.open("default", "events_dead_letter", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dlidx, err := dl.index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := rowCollector{}
+	for i := range dlidx.Inline {
+		err := dl.collectRows(&dlidx.Inline[i], &rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var errs []string
+	for i := range rc.rows {
+		s := rc.rows[i].row.(*ion.Struct)
+		if src := s.FieldByName("source"); src == nil || src.Value != ion.String("file://in/rows.json") {
+			t.Errorf("row %d: source %v", i, src)
+		}
+		if s.FieldByName("row") == nil {
+			t.Errorf("row %d: missing original row", i)
+		}
+		errs = append(errs, string(s.FieldByName("error").Value.(ion.String)))
+	}
+	sort.Strings(errs)
+	want := []string{
+		`field "amount": cannot convert string to float`,
+		`field "id": expected int, found string`,
+		`field "meta.tag": expected string, found bool`,
+		`missing required field "id"`,
+	}
+	if strings.Join(errs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got errors:\n%s", strings.Join(errs, "\n"))
+	}
+
+	// invalid schemas are rejected
+	bad := RowSchema{Fields: []FieldRule{{Path: "x", Type: "integer"}}}
+	if _, err := bad.compile(); err == nil {
+		t.Error("expected an error for unknown type")
+	}
+	// the dead-letter table lock is released
+	if n := len(sideLocks.tables); n != 0 {
+		t.Errorf("%d side table locks remain", n)
+	}
+}
+
+func TestRowCheckerValid(t *testing.T) {
+	schema := RowSchema{
+		Fields: []FieldRule{
+			{Path: "id", Type: "int", Required: true},
+			{Path: "amount", Type: "float", Coerce: []string{"int"}},
+			{Path: "meta.tag", Type: "string"},
+		},
+	}
+	rc, err := schema.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []struct {
+		text  string
+		valid bool
+	}{
+		{`{"id": 1}`, true},
+		{`{"id": -1, "amount": 1.5, "meta": {"tag": "x"}}`, true},
+		{`{"id": 1, "amount": null, "meta": "x"}`, true},
+		{`{"id": 1, "amount": 2}`, false}, // needs coercion
+		{`{"amount": 1.5}`, false},        // missing id
+		{`{"id": null}`, false},           // null id
+		{`{"id": 1, "meta": {"tag": 3}}`, false},
+	}
+	for i := range rows {
+		var st ion.Symtab
+		d, err := ion.FromJSON(&st, json.NewDecoder(strings.NewReader(rows[i].text)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf ion.Buffer
+		d.Encode(&buf, &st)
+		if got := rc.valid(&st, buf.Bytes()); got != rows[i].valid {
+			t.Errorf("%s: valid = %v", rows[i].text, got)
+		}
+		// a valid row must also pass check unchanged
+		if rows[i].valid {
+			row := resolve(d, &st).(*ion.Struct)
+			if err := rc.check(row); err != nil {
+				t.Errorf("%s: check: %s", rows[i].text, err)
+			}
+		}
+	}
+}
+
+// failOnceFS is an OutputFS that
+// fails the first write of one file
+type failOnceFS struct {
+	OutputFS
+	path   string
+	failed bool
+}
+
+func (f *failOnceFS) WriteFile(path string, buf []byte) (string, error) {
+	if path == f.path && !f.failed {
+		f.failed = true
+		return "", fmt.Errorf("injected failure writing %q", path)
+	}
+	return f.OutputFS.WriteFile(path, buf)
+}
+
+// failOnceTenant is a testTenant
+// whose root is a failOnceFS
+type failOnceTenant struct {
+	*testTenant
+	root *failOnceFS
+}
+
+func (t *failOnceTenant) Root() (InputFS, error) { return t.root, nil }
+
+func TestDeadLetterRetry(t *testing.T) {
+	checkFiles(t)
+	tmpdir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(tmpdir, "in"), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dfs := NewDirFS(tmpdir)
+	defer dfs.Close()
+	dfs.Log = t.Logf
+	// the first attempt to update the index
+	// of the table fails after the dead-letter
+	// rows have been written
+	owner := &failOnceTenant{
+		testTenant: newTenant(dfs),
+		root:       &failOnceFS{OutputFS: dfs, path: IndexPath("default", "events")},
+	}
+	err = WriteDefinition(dfs, "default", &Definition{
+		Name:   "events",
+		Inputs: []Input{{Pattern: "file://in/*.json"}},
+		Schema: &RowSchema{
+			Fields: []FieldRule{{Path: "id", Type: "int", Required: true}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []string{
+		`{"id": 1}`,
+		`{"id": "x"}`,
+		`{"id": 3}`,
+	}
+	err = os.WriteFile(filepath.Join(tmpdir, "in", "rows.json"), []byte(strings.Join(rows, "\n")), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := Builder{
+		Align: 1024,
+		Logf:  t.Logf,
+	}
+	err = b.Sync(owner, "default", "*")
+	if err == nil || !owner.root.failed {
+		t.Fatalf("expected injected failure; got %v", err)
+	}
+	err = b.Sync(owner, "default", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadRows := func() int {
+		dl, err := b.open("default", "events_dead_letter", owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx, err := dl.index()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc := rowCollector{}
+		for i := range idx.Inline {
+			err := dl.collectRows(&idx.Inline[i], &rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return len(rc.rows)
+	}
+	if n := deadRows(); n != 1 {
+		t.Fatalf("%d dead-letter rows after retry; expected 1", n)
+	}
+	idx, err := OpenIndex(dfs, "default", "events", owner.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ids := countRows(t, dfs, idx); len(ids) != 2 {
+		t.Fatalf("ingested ids %v", ids)
+	}
+
+	// rebuilding the table doesn't
+	// write the rejected rows again
+	b.Force = true
+	err = b.Sync(owner, "default", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := deadRows(); n != 1 {
+		t.Fatalf("%d dead-letter rows after rebuild; expected 1", n)
+	}
+}
+
+func TestSideTableConflict(t *testing.T) {
+	dfs := NewDirFS(t.TempDir())
+	defer dfs.Close()
+	b := Builder{Align: 1024, Logf: t.Logf}
+	st, err := b.open("default", "events", newTenant(dfs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lst := []blockfmt.Input{{Path: "in/a.json", ETag: "etag-a"}}
+	// two batches write rows from
+	// the same source concurrently
+	var side [2]deadLetter
+	for i := range side {
+		side[i].sideTable = sideTable{st: st.sibling("events_dead_letter"), source: st.table}
+		ok, err := side[i].accept(lst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok[0] {
+			t.Fatalf("batch %d: source already written", i)
+		}
+		row := ion.Struct{Fields: []ion.Field{{Label: "id", Value: ion.String("x")}}}
+		err = side[i].add(&row, lst[0].Path, fmt.Errorf("bad id"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := side[0].commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := side[1].commit(); !errors.Is(err, ErrBuildAgain) {
+		t.Fatalf("second commit: expected ErrBuildAgain; got %v", err)
+	}
+	ok, err := (&sideTable{st: st.sibling("events_dead_letter"), source: st.table}).accept(lst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok[0] {
+		t.Fatal("source not recorded")
+	}
+	idx, err := st.sibling("events_dead_letter").index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Inline) != 1 {
+		t.Fatalf("%d objects in dead-letter table", len(idx.Inline))
+	}
+}
@@ -0,0 +1,417 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/SnellerInc/sneller/internal/sort"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// DefaultMaxSortBytes is the default number
+// of bytes of rows that are buffered in memory
+// while sorting rows during ingest or compaction
+// before they are written to a temporary file.
+const DefaultMaxSortBytes = 256 * mega
+
+// sortSlab is the size of the buffers
+// from which rowSorter allocates rows
+const sortSlab = 1 * mega
+
+// rowSorter is an io.Writer that accepts
+// chunks of rows (see ion.Chunker.W) and
+// writes them out again ordered by a sort key.
+// It implements blockfmt.RowSorter.
+//
+// Rows are buffered in memory until limit bytes
+// have been buffered, at which point they are sorted
+// and written to a temporary file as a sorted run.
+// If any runs have been written, then the output
+// is produced by merging all of the runs.
+type rowSorter struct {
+	key   [][]string
+	limit int
+	align int
+
+	st    ion.Symtab // symbols for the current input chunk
+	runst ion.Symtab // symbols for the buffered rows
+	tmp   ion.Buffer
+
+	keyst  ion.Symtab // for encoding key values
+	keybuf ion.Buffer
+
+	slab    []byte
+	records []sort.IonRecord
+	size    int
+	runs    []*os.File
+}
+
+func (st *tableState) rowSorter(key [][]string) *rowSorter {
+	return &rowSorter{
+		key:   key,
+		limit: int(st.conf.maxSortBytes()),
+		align: st.conf.align(),
+	}
+}
+
+// null is the encoding of an untyped null,
+// which is used for MISSING sort key values
+var null = []byte{0x0f}
+
+// keyOf returns the encoded values of
+// each of the paths in key inside d
+func keyOf(key [][]string, d ion.Datum, st *ion.Symtab, buf *ion.Buffer) [][]byte {
+	if len(key) == 0 {
+		return nil
+	}
+	o := make([][]byte, len(key))
+	for i := range key {
+		v, ok := fieldOf(d, key[i])
+		if !ok || !sortable(v) {
+			o[i] = null
+			continue
+		}
+		buf.Reset()
+		v.Encode(buf, st)
+		o[i] = append([]byte(nil), buf.Bytes()...)
+	}
+	return o
+}
+
+// sortable returns whether v has a type
+// that sort.Ordering can compare; other
+// values are ordered like NULL
+func sortable(v ion.Datum) bool {
+	switch v.Type() {
+	case ion.BoolType, ion.IntType, ion.UintType,
+		ion.FloatType, ion.TimestampType, ion.StringType:
+		return true
+	}
+	return false
+}
+
+var keyOrder = sort.Ordering{
+	Direction: sort.Ascending,
+	Nulls:     sort.NullsLast,
+}
+
+func keyLess(a, b [][]byte) bool {
+	for i := range a {
+		if c := keyOrder.Compare(a[i], b[i]); c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+func (r *rowSorter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if ion.TypeOf(p) == ion.NullType {
+			// skip nop pad
+			p = p[ion.SizeOf(p):]
+			continue
+		}
+		d, rest, err := ion.ReadDatum(&r.st, p)
+		if err != nil {
+			return 0, err
+		}
+		p = rest
+		if d == nil {
+			continue
+		}
+		if err := r.add(d); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// alloc returns n bytes of memory for a row
+func (r *rowSorter) alloc(n int) []byte {
+	if len(r.slab)+n > cap(r.slab) {
+		size := sortSlab
+		if n > size {
+			size = n
+		}
+		r.slab = make([]byte, 0, size)
+	}
+	start := len(r.slab)
+	r.slab = r.slab[:start+n]
+	return r.slab[start : start+n : start+n]
+}
+
+// add buffers one row; the encoded key values
+// are stored ahead of the row contents so that
+// the row can be sorted with sort.ByColumns
+func (r *rowSorter) add(d ion.Datum) error {
+	if d.Type() != ion.StructType {
+		return fmt.Errorf("cannot sort row of type %s", d.Type())
+	}
+	key := keyOf(r.key, d, &r.keyst, &r.keybuf)
+	r.tmp.Reset()
+	d.Encode(&r.tmp, &r.runst)
+	body, _ := ion.Contents(r.tmp.Bytes())
+	size := len(body)
+	for i := range key {
+		size += len(key[i])
+	}
+	rec := sort.IonRecord{
+		FieldDelims: make([][2]uint32, len(key)),
+		Raw:         r.alloc(size),
+	}
+	off := 0
+	for i := range key {
+		rec.FieldDelims[i] = [2]uint32{uint32(off), uint32(len(key[i]))}
+		off += copy(rec.Raw[off:], key[i])
+	}
+	copy(rec.Raw[off:], body)
+	rec.Boxed = uint32(off)
+	r.records = append(r.records, rec)
+	r.size += size
+	if r.size >= r.limit {
+		return r.spill()
+	}
+	return nil
+}
+
+// sortTo sorts the buffered rows
+// and writes them to dst as chunks
+func (r *rowSorter) sortTo(dst io.Writer) error {
+	if len(r.records) == 0 {
+		return nil
+	}
+	// leave room for the symbol table
+	// in addition to the rows themselves
+	r.tmp.Reset()
+	r.runst.Marshal(&r.tmp, true)
+	rw, err := sort.NewRowsWriter(dst, &r.runst, r.tmp.Size()+r.align)
+	if err != nil {
+		return err
+	}
+	dirs := make([]sort.Direction, len(r.key))
+	nulls := make([]sort.NullsOrder, len(r.key))
+	for i := range r.key {
+		dirs[i] = keyOrder.Direction
+		nulls[i] = keyOrder.Nulls
+	}
+	rp := sort.NewRuntimeParameters(runtime.GOMAXPROCS(0))
+	err = sort.ByColumns(r.records, dirs, nulls, nil, rw, &rp)
+	err2 := rw.Close()
+	if err != nil {
+		return err
+	}
+	return err2
+}
+
+// frameWriter writes each call to Write
+// as a length-prefixed frame
+type frameWriter struct {
+	w   *bufio.Writer
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	_, err := f.w.Write(f.tmp[:binary.PutUvarint(f.tmp[:], uint64(len(p)))])
+	if err != nil {
+		return 0, err
+	}
+	return f.w.Write(p)
+}
+
+// spill writes the buffered rows
+// to a new sorted run
+func (r *rowSorter) spill() error {
+	f, err := os.CreateTemp("", "sort-run-")
+	if err != nil {
+		return fmt.Errorf("creating sorted run: %w", err)
+	}
+	// unlink the file immediately so that
+	// its space is reclaimed as soon as it is closed
+	os.Remove(f.Name())
+	r.runs = append(r.runs, f)
+	w := bufio.NewWriter(f)
+	err = r.sortTo(&frameWriter{w: w})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("writing sorted run: %w", err)
+	}
+	r.records = nil
+	r.slab = nil
+	r.size = 0
+	r.runst.Reset()
+	return nil
+}
+
+// Emit writes all of the rows that have
+// been written to r to dst in sorted order.
+// It does not flush dst.
+func (r *rowSorter) Emit(dst *ion.Chunker) error {
+	if len(r.runs) == 0 {
+		return r.sortTo(dst)
+	}
+	if len(r.records) > 0 {
+		if err := r.spill(); err != nil {
+			return err
+		}
+	}
+	return r.merge(dst)
+}
+
+// Close releases the sorted runs
+func (r *rowSorter) Close() error {
+	for i := range r.runs {
+		r.runs[i].Close()
+	}
+	r.runs = nil
+	r.records = nil
+	r.slab = nil
+	return nil
+}
+
+// runReader reads rows from a sorted run
+type runReader struct {
+	src  *bufio.Reader
+	id   int
+	st   ion.Symtab
+	buf  []byte
+	rest []byte
+
+	row ion.Datum
+	key [][]byte
+}
+
+// next reads the next row into rr.row
+// and returns false at the end of the run
+func (rr *runReader) next(r *rowSorter) (bool, error) {
+	for {
+		for len(rr.rest) > 0 {
+			if ion.TypeOf(rr.rest) == ion.NullType {
+				rr.rest = rr.rest[ion.SizeOf(rr.rest):]
+				continue
+			}
+			d, rest, err := ion.ReadDatum(&rr.st, rr.rest)
+			if err != nil {
+				return false, err
+			}
+			rr.rest = rest
+			if d == nil {
+				continue
+			}
+			rr.row = d
+			rr.key = keyOf(r.key, d, &r.keyst, &r.keybuf)
+			return true, nil
+		}
+		size, err := binary.ReadUvarint(rr.src)
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if uint64(cap(rr.buf)) < size {
+			rr.buf = make([]byte, size)
+		}
+		rr.buf = rr.buf[:size]
+		_, err = io.ReadFull(rr.src, rr.buf)
+		if err != nil {
+			return false, fmt.Errorf("reading sorted run: %w", err)
+		}
+		rr.rest = rr.buf
+	}
+}
+
+// runHeap orders runs by their current row;
+// ties are broken by the order of the runs
+type runHeap []*runReader
+
+func (h runHeap) Len() int { return len(h) }
+func (h runHeap) Less(i, j int) bool {
+	if keyLess(h[i].key, h[j].key) {
+		return true
+	}
+	return !keyLess(h[j].key, h[i].key) && h[i].id < h[j].id
+}
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// merge writes the k-way merge of
+// the sorted runs to dst using a
+// common symbol table
+func (r *rowSorter) merge(dst *ion.Chunker) error {
+	h := make(runHeap, 0, len(r.runs))
+	for i := range r.runs {
+		_, err := r.runs[i].Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		rr := &runReader{src: bufio.NewReader(r.runs[i]), id: i}
+		ok, err := rr.next(r)
+		if err != nil {
+			return err
+		}
+		if ok {
+			h = append(h, rr)
+		}
+	}
+	heap.Init(&h)
+
+	var st ion.Symtab
+	var hdr, body ion.Buffer
+	flush := func() error {
+		if body.Size() == 0 {
+			return nil
+		}
+		hdr.Reset()
+		st.Marshal(&hdr, true)
+		_, err := dst.Write(append(hdr.Bytes(), body.Bytes()...))
+		body.Reset()
+		return err
+	}
+	for len(h) > 0 {
+		rr := h[0]
+		rr.row.Encode(&body, &st)
+		if body.Size() >= dst.Align {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		ok, err := rr.next(r)
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return flush()
+}
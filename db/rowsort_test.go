@@ -0,0 +1,91 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func TestRowSorter(t *testing.T) {
+	const rows = 1000
+	key := [][]string{{"k"}, {"n"}}
+	for _, limit := range []int{DefaultMaxSortBytes, 2048} {
+		t.Run(fmt.Sprintf("limit=%d", limit), func(t *testing.T) {
+			rs := &rowSorter{key: key, limit: limit, align: 1024}
+			defer rs.Close()
+
+			// write the rows in chunks with
+			// a different symbol table each time
+			var st ion.Symtab
+			var hdr, body ion.Buffer
+			flush := func() {
+				if body.Size() == 0 {
+					return
+				}
+				hdr.Reset()
+				st.Marshal(&hdr, true)
+				_, err := rs.Write(append(hdr.Bytes(), body.Bytes()...))
+				if err != nil {
+					t.Fatal(err)
+				}
+				body.Reset()
+				st.Reset()
+			}
+			for i := 0; i < rows; i++ {
+				fields := []ion.Field{
+					{Label: "n", Value: ion.Int((i * 7919) % rows)},
+					{Label: fmt.Sprintf("extra%d", i%5), Value: ion.String("x")},
+				}
+				if i%10 != 0 {
+					// every tenth row is missing the key
+					fields = append(fields, ion.Field{Label: "k", Value: ion.String(fmt.Sprintf("k%d", i%3))})
+				}
+				(&ion.Struct{Fields: fields}).Encode(&body, &st)
+				if body.Size() >= 512 {
+					flush()
+				}
+			}
+			flush()
+			if limit < DefaultMaxSortBytes && len(rs.runs) < 2 {
+				t.Fatalf("expected rows to be spilled; have %d runs", len(rs.runs))
+			}
+
+			rc := rowCollector{key: key}
+			cn := ion.Chunker{W: &rc, Align: 1024}
+			err := rs.Emit(&cn)
+			if err == nil {
+				err = cn.Flush()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rc.rows) != rows {
+				t.Fatalf("got %d rows; expected %d", len(rc.rows), rows)
+			}
+			for i := 1; i < len(rc.rows); i++ {
+				if keyLess(rc.rows[i].key, rc.rows[i-1].key) {
+					t.Fatalf("row %d out of order: %v after %v", i, rc.rows[i].row, rc.rows[i-1].row)
+				}
+			}
+			// rows without the key go last
+			if last := rc.rows[rows-1].row.(*ion.Struct); last.FieldByName("k") != nil {
+				t.Errorf("unexpected last row %v", last)
+			}
+		})
+	}
+}
@@ -93,6 +93,11 @@ func (st *tableState) scan(def *Definition, idx *blockfmt.Index, flushOnComplete
 			complete = false
 			break
 		}
+		if isStream(def.Inputs[i].Pattern) {
+			// streams are only ingested
+			// through a QueueRunner
+			continue
+		}
 		infs, pat, err := st.owner.Split(def.Inputs[i].Pattern)
 		if err != nil {
 			// invalid definition?
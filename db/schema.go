@@ -0,0 +1,260 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+
+	"github.com/SnellerInc/sneller/ion"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+// SchemaPath returns the path
+// at which the schema for the given
+// db and table would live relative
+// to the root of the FS.
+//
+// The schema is stored alongside the index
+// rather than inside it so that the index
+// stays small and remains readable by
+// versions that predate schemas.
+func SchemaPath(db, table string) string {
+	return path.Join("db", db, table, "schema")
+}
+
+// OpenSchema reads the schema of the rows
+// in the given db and table. Objects that were
+// written before schemas were collected are not
+// included in the returned schema.
+//
+// If the table has no schema, the returned error
+// satisfies errors.Is(err, fs.ErrNotExist).
+func OpenSchema(src fs.FS, db, table string) (*blockfmt.Schema, error) {
+	ts, err := readSchema(src, db, table)
+	if err != nil {
+		return nil, err
+	}
+	return &ts.merged, nil
+}
+
+// tableSchema is the decoded form
+// of the object at SchemaPath
+type tableSchema struct {
+	// merged is the sum of the
+	// schemas of the live objects
+	merged blockfmt.Schema
+	// objects is the schema of each object
+	// keyed by path; objects that have been
+	// removed from the index are kept while they
+	// are quarantined so that Builder.Rollback
+	// can restore their schemas
+	objects map[string]*objectSchema
+}
+
+type objectSchema struct {
+	schema *blockfmt.Schema
+	live   bool
+}
+
+func readSchema(src fs.FS, db, table string) (*tableSchema, error) {
+	buf, err := fs.ReadFile(src, SchemaPath(db, table))
+	if err != nil {
+		return nil, err
+	}
+	ts := &tableSchema{objects: make(map[string]*objectSchema)}
+	err = ts.decode(buf)
+	if err != nil {
+		return nil, fmt.Errorf("decoding schema of %s/%s: %w", db, table, err)
+	}
+	return ts, nil
+}
+
+func (ts *tableSchema) encode() []byte {
+	var st ion.Symtab
+	var buf ion.Buffer
+	paths := make([]string, 0, len(ts.objects))
+	for p := range ts.objects {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	buf.BeginStruct(-1)
+	buf.BeginField(st.Intern("schema"))
+	ts.merged.Encode(&buf, &st)
+	buf.BeginField(st.Intern("objects"))
+	buf.BeginList(-1)
+	for _, p := range paths {
+		o := ts.objects[p]
+		buf.BeginStruct(-1)
+		buf.BeginField(st.Intern("path"))
+		buf.WriteString(p)
+		if !o.live {
+			buf.BeginField(st.Intern("removed"))
+			buf.WriteBool(true)
+		}
+		buf.BeginField(st.Intern("schema"))
+		o.schema.Encode(&buf, &st)
+		buf.EndStruct()
+	}
+	buf.EndList()
+	buf.EndStruct()
+
+	var out ion.Buffer
+	st.Marshal(&out, true)
+	return append(out.Bytes(), buf.Bytes()...)
+}
+
+func (ts *tableSchema) decode(buf []byte) error {
+	var st ion.Symtab
+	body, err := st.Unmarshal(buf)
+	if err != nil {
+		return err
+	}
+	_, err = ion.UnpackStruct(&st, body, func(name string, field []byte) error {
+		switch name {
+		case "schema":
+			return ts.merged.Decode(&st, field)
+		case "objects":
+			_, err := ion.UnpackList(field, func(field []byte) error {
+				var p string
+				o := &objectSchema{schema: new(blockfmt.Schema), live: true}
+				_, err := ion.UnpackStruct(&st, field, func(name string, field []byte) error {
+					var err error
+					switch name {
+					case "path":
+						p, _, err = ion.ReadString(field)
+					case "removed":
+						var removed bool
+						removed, _, err = ion.ReadBool(field)
+						o.live = !removed
+					case "schema":
+						err = o.schema.Decode(&st, field)
+					default:
+						err = fmt.Errorf("unexpected field %q", name)
+					}
+					return err
+				})
+				if err != nil {
+					return err
+				}
+				ts.objects[p] = o
+				return nil
+			})
+			return err
+		default:
+			return fmt.Errorf("unexpected field %q", name)
+		}
+	})
+	return err
+}
+
+// schemaOp is a pending change to the
+// schema of a table (see tableState.addSchema)
+type schemaOp struct {
+	path string
+	add  bool
+}
+
+// setSchema records s as the schema
+// of the rows in the object at p, which
+// has just been written by st
+func (st *tableState) setSchema(p string, s *blockfmt.Schema) {
+	if s == nil {
+		return
+	}
+	if st.schemas == nil {
+		st.schemas = make(map[string]*blockfmt.Schema)
+	}
+	st.schemas[p] = s
+}
+
+// addSchema adds the schema of d
+// to the schema of the table
+// when the index is next written
+func (st *tableState) addSchema(d *blockfmt.Descriptor) {
+	st.schemaOps = append(st.schemaOps, schemaOp{path: d.Path, add: true})
+}
+
+// subSchema removes the schema of d
+// from the schema of the table
+// when the index is next written
+func (st *tableState) subSchema(d *blockfmt.Descriptor) {
+	st.schemaOps = append(st.schemaOps, schemaOp{path: d.Path})
+}
+
+// restoreSchema causes the schema of the
+// table to be rebuilt from the schemas of
+// the objects in live when the index is next
+// written (see Builder.Rollback)
+func (st *tableState) restoreSchema(live map[string]struct{}) {
+	st.schemaLive = live
+}
+
+// writeSchema applies the pending changes to
+// the schema of the table after idx has been written
+func (st *tableState) writeSchema(idx *blockfmt.Index) error {
+	if len(st.schemaOps) == 0 && st.schemaLive == nil {
+		return nil
+	}
+	ts, err := readSchema(st.ofs, st.db, st.table)
+	if errors.Is(err, fs.ErrNotExist) {
+		ts = &tableSchema{objects: make(map[string]*objectSchema)}
+	} else if err != nil {
+		return err
+	}
+	for _, op := range st.schemaOps {
+		o := ts.objects[op.path]
+		if op.add {
+			s := st.schemas[op.path]
+			if s == nil || (o != nil && o.live) {
+				continue
+			}
+			ts.objects[op.path] = &objectSchema{schema: s, live: true}
+			ts.merged.Add(s)
+		} else if o != nil && o.live {
+			o.live = false
+			ts.merged.Sub(o.schema)
+		}
+	}
+	if st.schemaLive != nil {
+		ts.merged = blockfmt.Schema{}
+		for p, o := range ts.objects {
+			_, o.live = st.schemaLive[p]
+			if o.live {
+				ts.merged.Add(o.schema)
+			}
+		}
+	}
+	quarantined := make(map[string]struct{}, len(idx.ToDelete))
+	for i := range idx.ToDelete {
+		quarantined[idx.ToDelete[i].Path] = struct{}{}
+	}
+	for p, o := range ts.objects {
+		if _, ok := quarantined[p]; !o.live && !ok {
+			delete(ts.objects, p)
+		}
+	}
+	_, err = st.ofs.WriteFile(SchemaPath(st.db, st.table), ts.encode())
+	if err != nil {
+		return err
+	}
+	st.schemaOps = st.schemaOps[:0]
+	st.schemaLive = nil
+	return nil
+}
@@ -0,0 +1,375 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+// snapshotPrefix is the prefix of the names
+// of index snapshots within a table directory;
+// the remainder of the name is the creation
+// time of the index formatted with snapshotLayout
+const snapshotPrefix = "index."
+
+// snapshotLayout sorts lexicographically
+// in chronological order
+const snapshotLayout = "20060102T150405.000000Z"
+
+// rollbackSuffix is appended to the names of
+// snapshots of indexes written by Builder.Rollback
+const rollbackSuffix = ".rollback"
+
+// snapshotSkew is the tolerance for differences
+// between the clock used for index creation times
+// and the modification times of objects
+var snapshotSkew = time.Minute
+
+// A Snapshot is a retained version
+// of the index of a table.
+type Snapshot struct {
+	// Path is the path of the signed index.
+	Path string
+	// Created is the value of
+	// blockfmt.Index.Created in the index.
+	Created date.Time
+	// Rollback is true if the index was
+	// written by Builder.Rollback, in which case
+	// it may reference objects that were not
+	// referenced by the preceding snapshot.
+	Rollback bool
+}
+
+// SnapshotPath returns the path of the snapshot
+// of the index for db and table that was
+// created at the given time.
+func SnapshotPath(db, table string, created date.Time) string {
+	return snapshotPath(db, table, created, false)
+}
+
+func snapshotPath(db, table string, created date.Time, rollback bool) string {
+	name := snapshotPrefix + created.Time().UTC().Format(snapshotLayout)
+	if rollback {
+		name += rollbackSuffix
+	}
+	return path.Join("db", db, table, name)
+}
+
+// Snapshots returns the list of retained snapshots
+// of the index for the given db and table
+// from oldest to newest.
+//
+// See also: Builder.MaxSnapshots
+func Snapshots(s fs.FS, db, table string) ([]Snapshot, error) {
+	dir := path.Join("db", db, table)
+	ents, err := fs.ReadDir(s, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []Snapshot
+	for i := range ents {
+		name := ents[i].Name()
+		if ents[i].IsDir() || !strings.HasPrefix(name, snapshotPrefix) {
+			continue
+		}
+		ts := strings.TrimPrefix(name, snapshotPrefix)
+		rollback := strings.HasSuffix(ts, rollbackSuffix)
+		t, err := time.Parse(snapshotLayout, strings.TrimSuffix(ts, rollbackSuffix))
+		if err != nil {
+			continue
+		}
+		out = append(out, Snapshot{
+			Path:     path.Join(dir, name),
+			Created:  date.FromTime(t),
+			Rollback: rollback,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Created.Before(out[j].Created)
+	})
+	return out, nil
+}
+
+// OpenSnapshot opens the most recent snapshot
+// of the index for the given db and table that
+// was created at or before the given time.
+// If there is no such snapshot, the returned
+// error wraps fs.ErrNotExist.
+func OpenSnapshot(s fs.FS, db, table string, key *blockfmt.Key, when date.Time) (*blockfmt.Index, error) {
+	return openSnapshot(s, db, table, key, when, 0)
+}
+
+// OpenPartialSnapshot is equivalent to
+// OpenSnapshot, but skips decoding Index.Inputs.
+func OpenPartialSnapshot(s fs.FS, db, table string, key *blockfmt.Key, when date.Time) (*blockfmt.Index, error) {
+	return openSnapshot(s, db, table, key, when, blockfmt.FlagSkipInputs)
+}
+
+func openSnapshot(s fs.FS, db, table string, key *blockfmt.Key, when date.Time, opts blockfmt.Flag) (*blockfmt.Index, error) {
+	lst, err := Snapshots(s, db, table)
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(lst), func(i int) bool {
+		return lst[i].Created.After(when)
+	})
+	if i == 0 {
+		// the current index may have been written
+		// without a snapshot (if snapshots are disabled)
+		idx, err := openIndex(s, db, table, key, opts)
+		if err == nil && !idx.Created.After(when) {
+			return idx, nil
+		}
+		return nil, fmt.Errorf("no snapshot of %s/%s at or before %s: %w", db, table, when, fs.ErrNotExist)
+	}
+	return readIndex(s, lst[i-1].Path, key, opts)
+}
+
+// readIndex reads and decodes the signed index at p
+func readIndex(s fs.FS, p string, key *blockfmt.Key, opts blockfmt.Flag) (*blockfmt.Index, error) {
+	f, err := s.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// prevent DoS: make sure index
+	// is reasonably sized
+	if info.Size() >= MaxIndexSize {
+		return nil, fmt.Errorf("index %q is %d bytes; too big", p, info.Size())
+	}
+	buf := make([]byte, info.Size())
+	n, err := io.ReadFull(f, buf)
+	if err != nil {
+		return nil, err
+	}
+	return blockfmt.DecodeIndex(key, buf[:n], opts)
+}
+
+// snapshot writes buf, which is the signed
+// encoding of idx, as a snapshot of the table
+// and removes the oldest snapshots in excess
+// of Builder.MaxSnapshots
+func (st *tableState) snapshot(idx *blockfmt.Index, buf []byte, rollback bool) error {
+	if st.conf.MaxSnapshots <= 0 || idx.Scanning {
+		return nil
+	}
+	_, err := st.ofs.WriteFile(snapshotPath(st.db, st.table, idx.Created, rollback), buf)
+	if err != nil {
+		return err
+	}
+	rmfs, ok := st.ofs.(RemoveFS)
+	if !ok {
+		return nil
+	}
+	lst, err := Snapshots(st.ofs, st.db, st.table)
+	if err != nil {
+		return err
+	}
+	for len(lst) > st.conf.MaxSnapshots {
+		err := rmfs.Remove(lst[0].Path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		st.conf.logf("table %s: removed snapshot %s", st.table, lst[0].Path)
+		lst = lst[1:]
+	}
+	return nil
+}
+
+// retention describes the objects that
+// are referenced by the retained snapshots
+// of a table (see retained)
+type retention struct {
+	// oldest is the creation time
+	// of the oldest snapshot
+	oldest time.Time
+	// used is the set of objects referenced
+	// by the oldest snapshot and by the
+	// snapshots written by Builder.Rollback
+	used map[string]struct{}
+}
+
+// retains returns whether the object at p,
+// which was last modified at modtime, may be
+// referenced by a retained snapshot
+func (r *retention) retains(p string, modtime time.Time) bool {
+	if r == nil {
+		return false
+	}
+	if _, ok := r.used[p]; ok {
+		return true
+	}
+	return modtime.After(r.oldest.Add(-snapshotSkew))
+}
+
+// retained returns the retention of the
+// objects referenced by the retained snapshots
+// of the given table, or nil if there are none.
+//
+// Each index that is written is retained as a snapshot,
+// and snapshots are removed oldest first, so an object
+// that is referenced by a retained snapshot was
+// either created after the oldest snapshot, or it is
+// referenced by the oldest snapshot, or it was referenced
+// again by a rollback after the oldest snapshot was created.
+// Consequently only those snapshots have to be read
+// rather than every retained snapshot.
+//
+// Snapshots are not authenticated, since they
+// are only used to prevent objects from being removed.
+func retained(s fs.FS, dbname, table string) (*retention, error) {
+	lst, err := Snapshots(s, dbname, table)
+	if err != nil || len(lst) == 0 {
+		return nil, err
+	}
+	r := &retention{
+		oldest: lst[0].Created.Time(),
+		used:   make(map[string]struct{}),
+	}
+	first := true
+	for i := range lst {
+		if !first && !lst[i].Rollback {
+			continue
+		}
+		idx, err := readIndex(s, lst[i].Path, nil, 0)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// removed concurrently; the next
+				// snapshot is now the oldest one
+				continue
+			}
+			return nil, fmt.Errorf("reading snapshot %s: %w", lst[i].Path, err)
+		}
+		first = false
+		err = references(s, idx, r.used)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// references adds the paths of each
+// object referenced by idx to used
+func references(s fs.FS, idx *blockfmt.Index, used map[string]struct{}) error {
+	for i := range idx.Inline {
+		used[idx.Inline[i].Path] = struct{}{}
+	}
+	for i := range idx.Indirect.Refs {
+		used[idx.Indirect.Refs[i].Path] = struct{}{}
+	}
+	if len(idx.Indirect.Refs) > 0 {
+		ifs, ok := s.(blockfmt.InputFS)
+		if !ok {
+			return fmt.Errorf("cannot scan indirect inputs using %T", s)
+		}
+		descs, err := idx.Indirect.Search(ifs, nil)
+		if err != nil {
+			return err
+		}
+		for i := range descs {
+			used[descs[i].Path] = struct{}{}
+		}
+	}
+	idx.Inputs.EachFile(func(f string) {
+		used[f] = struct{}{}
+	})
+	return nil
+}
+
+// Rollback replaces the index of a table with
+// the most recent retained snapshot that was created
+// at or before the given time. The restored index
+// is written with a new creation time (and is itself
+// retained as a new snapshot), so the history of the
+// table before the rollback remains available.
+//
+// Objects that are referenced by the current index
+// but not by the restored index are queued for deletion
+// in blockfmt.Index.ToDelete. Rollback should not be
+// run concurrently with other operations that update
+// the same table.
+func (b *Builder) Rollback(who Tenant, db, table string, when date.Time) error {
+	st, err := b.open(db, table, who)
+	if err != nil {
+		return err
+	}
+	cur, err := st.index()
+	if err != nil {
+		return err
+	}
+	idx, err := OpenSnapshot(st.ofs, db, table, who.Key(), when)
+	if err != nil {
+		return err
+	}
+	cur.Inputs.Backing = st.ofs
+	idx.Inputs.Backing = st.ofs
+	keep := make(map[string]struct{})
+	if err := references(st.ofs, idx, keep); err != nil {
+		return err
+	}
+	old := make(map[string]struct{})
+	if err := references(st.ofs, cur, old); err != nil {
+		return err
+	}
+	// objects that were queued for deletion in
+	// either index can still be deleted unless
+	// the restored index references them again
+	var todelete []blockfmt.Quarantined
+	seen := make(map[string]struct{})
+	add := func(q blockfmt.Quarantined) {
+		if _, ok := keep[q.Path]; ok {
+			return
+		}
+		if _, ok := seen[q.Path]; ok {
+			return
+		}
+		seen[q.Path] = struct{}{}
+		todelete = append(todelete, q)
+	}
+	for i := range cur.ToDelete {
+		add(cur.ToDelete[i])
+	}
+	for i := range idx.ToDelete {
+		add(idx.ToDelete[i])
+	}
+	expiry := date.Now().Add(b.GCMinimumAge)
+	for p := range old {
+		add(blockfmt.Quarantined{Path: p, Expiry: expiry})
+	}
+	b.logf("table %s/%s: rolling back to snapshot created %s", db, table, idx.Created)
+	idx.ToDelete = todelete
+	idx.Scanning = false
+	idx.Created = date.Now().Truncate(time.Microsecond)
+	st.restoreSchema(keep)
+	return st.write(idx, true)
+}
@@ -0,0 +1,205 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion/blockfmt"
+)
+
+func TestSnapshots(t *testing.T) {
+	checkFiles(t)
+	tmpdir := t.TempDir()
+	dfs := NewDirFS(tmpdir)
+	defer dfs.Close()
+	dfs.Log = t.Logf
+	owner := newTenant(dfs)
+	b := Builder{
+		Align:        1024,
+		Logf:         t.Logf,
+		GCLikelihood: 100,
+		GCMinimumAge: 0,
+		MaxSnapshots: 3,
+	}
+	// the objects in this test are created
+	// within milliseconds of the snapshots
+	skew := snapshotSkew
+	snapshotSkew = 0
+	t.Cleanup(func() { snapshotSkew = skew })
+	var buf bytes.Buffer
+	json := blockfmt.SuffixToFormat[".json"]
+	var created []date.Time
+	id := 0
+	for i := 0; i < 3; i++ {
+		buf.Reset()
+		for j := 0; j < 100; j++ {
+			fmt.Fprintf(&buf, "{\"id\": %d}\n", id)
+			id++
+		}
+		name := fmt.Sprintf("input%d.json", i)
+		err := os.WriteFile(filepath.Join(tmpdir, name), buf.Bytes(), 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lst, err := blockfmt.CollectGlob(dfs, func(string) blockfmt.RowFormat { return json() }, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = b.Append(owner, "default", "events", lst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx, err := OpenIndex(dfs, "default", "events", owner.Key())
+		if err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, idx.Created)
+	}
+	checkSnapshots := func(want int) []Snapshot {
+		t.Helper()
+		lst, err := Snapshots(dfs, "default", "events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lst) != want {
+			t.Fatalf("%d snapshots; expected %d", len(lst), want)
+		}
+		return lst
+	}
+	rowsAt := func(when date.Time) int {
+		t.Helper()
+		idx, err := OpenSnapshot(dfs, "default", "events", owner.Key(), when)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, ids := countRows(t, dfs, idx)
+		return len(ids)
+	}
+	lst := checkSnapshots(3)
+	for i := range created {
+		if !lst[i].Created.Equal(created[i]) {
+			t.Errorf("snapshot %d created %s, not %s", i, lst[i].Created, created[i])
+		}
+		if n := rowsAt(created[i]); n != 100*(i+1) {
+			t.Errorf("snapshot %d has %d rows", i, n)
+		}
+	}
+	_, err := OpenSnapshot(dfs, "default", "events", owner.Key(), created[0].Add(-time.Second))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("opening snapshot before first index: %v", err)
+	}
+	first, err := OpenSnapshot(dfs, "default", "events", owner.Key(), created[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// remove every row; the snapshots still
+	// reference the old objects, so GC must not
+	// remove them
+	n, err := b.Delete(owner, "default", "events", &idFilter{mod: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 300 {
+		t.Fatalf("deleted %d rows", n)
+	}
+	idx, err := OpenIndex(dfs, "default", "events", owner.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSnapshots(3)
+	if sc, err := OpenSchema(dfs, "default", "events"); err != nil {
+		t.Fatal(err)
+	} else if sc.Rows != 0 {
+		t.Errorf("schema has %d rows after delete", sc.Rows)
+	}
+	conf := GCConfig{Logf: t.Logf, MinimumAge: 1, Precise: true}
+	err = conf.Run(dfs, "default", idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.ToDelete) == 0 {
+		t.Error("referenced objects removed from ToDelete")
+	}
+	for i := 1; i < len(created); i++ {
+		if n := rowsAt(created[i]); n != 100*(i+1) {
+			t.Errorf("snapshot %d has %d rows after delete+gc", i, n)
+		}
+	}
+
+	// roll back to before the deletion
+	err = b.Rollback(owner, "default", "events", created[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err = OpenIndex(dfs, "default", "events", owner.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !idx.Created.After(created[2]) {
+		t.Errorf("rolled back index created at %s", idx.Created)
+	}
+	if _, ids := countRows(t, dfs, idx); len(ids) != 300 {
+		t.Errorf("%d rows after rollback", len(ids))
+	}
+	// the schema is rolled back as well
+	if sc, err := OpenSchema(dfs, "default", "events"); err != nil {
+		t.Fatal(err)
+	} else if sc.Rows != 300 {
+		t.Errorf("schema has %d rows after rollback", sc.Rows)
+	}
+	used := make(map[string]struct{})
+	err = references(dfs, idx, used)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range idx.ToDelete {
+		if _, ok := used[idx.ToDelete[i].Path]; ok {
+			t.Errorf("%s is referenced and quarantined", idx.ToDelete[i].Path)
+		}
+	}
+	lst = checkSnapshots(3)
+	if !lst[0].Created.Equal(created[2]) {
+		t.Errorf("oldest snapshot created %s", lst[0].Created)
+	}
+	if lst[0].Rollback || lst[1].Rollback || !lst[2].Rollback {
+		t.Errorf("only the last snapshot should be a rollback: %+v", lst)
+	}
+
+	// the objects only referenced by pruned
+	// snapshots can now be removed
+	err = conf.Run(dfs, "default", idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range first.Inline {
+		_, err := fs.Stat(dfs, first.Inline[i].Path)
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s not removed: %v", first.Inline[i].Path, err)
+		}
+	}
+	if _, ids := countRows(t, dfs, idx); len(ids) != 300 {
+		t.Errorf("%d rows after rollback+gc", len(ids))
+	}
+}
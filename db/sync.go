@@ -81,6 +81,31 @@ type Builder struct {
 	// size of objects. If MinMergeSize is zero,
 	// then DefaultMinMerge is used.
 	MinMergeSize int
+	// CompactFactor is the ratio between the
+	// sizes of objects in adjacent compaction tiers,
+	// and also the number of objects in one tier
+	// that are merged into an object in the next tier.
+	// If CompactFactor is less than two, then
+	// DefaultCompactFactor is used.
+	CompactFactor int
+	// MaxCompactBytes is the maximum number
+	// of decompressed bytes in an object produced
+	// by compaction.
+	// If MaxCompactBytes is zero, then DefaultMaxCompact is used.
+	MaxCompactBytes int64
+	// MaxSortBytes is the maximum number of bytes
+	// of rows that are held in memory while the rows
+	// of an object are sorted during ingest or compaction.
+	// Beyond that, sorted runs of rows are written to
+	// temporary files (see os.TempDir) and merged.
+	// If MaxSortBytes is zero, then DefaultMaxSortBytes is used.
+	MaxSortBytes int64
+	// CompactLikelihood is the likelihood that
+	// a Sync operation is followed by compaction
+	// of each table that was synchronized.
+	// This value is interpreted as a percentage
+	// in the same way as GCLikelihood.
+	CompactLikelihood int
 	// Force forces a full index rebuild
 	// even when the input appears to be up-to-date.
 	Force bool
@@ -125,6 +150,14 @@ type Builder struct {
 	// See blockfmt.Index.ToDelete.Expiry for
 	// how this value is used.
 	GCMinimumAge time.Duration
+	// MaxSnapshots is the number of versions
+	// of the index of each table that are retained
+	// as snapshots (see Snapshots and OpenSnapshot).
+	// The objects referenced by retained snapshots
+	// are never removed by garbage collection.
+	// If MaxSnapshots is zero, no new snapshots are written,
+	// but existing snapshots are still respected.
+	MaxSnapshots int
 
 	// Logf, if non-nil, will be where
 	// the builder will log build actions
@@ -176,6 +209,13 @@ type tableState struct {
 	owner     Tenant
 	ofs       OutputFS
 	db, table string
+
+	// schemas of the objects written by st
+	// and pending changes to the table schema;
+	// see setSchema, addSchema and subSchema
+	schemas    map[string]*blockfmt.Schema
+	schemaOps  []schemaOp
+	schemaLive map[string]struct{}
 }
 
 func (b *Builder) open(db, table string, owner Tenant) (*tableState, error) {
@@ -433,7 +473,7 @@ func (b *Builder) Sync(who Tenant, db, tblpat string) error {
 		if idx.Scanning {
 			return ErrBuildAgain
 		}
-		return nil
+		return st.maybeCompact()
 	}
 	errlist := make([]error, len(tables))
 	var wg sync.WaitGroup
@@ -576,7 +616,7 @@ type readCloser struct {
 func (st *tableState) preciseGC(idx *blockfmt.Index) {
 	if rmfs, ok := st.ofs.(RemoveFS); ok && st.conf.GCLikelihood > 0 {
 		gcconf := GCConfig{Precise: true, Logf: st.conf.Logf}
-		gcconf.preciseGC(rmfs, idx)
+		gcconf.preciseGC(rmfs, st.db, idx)
 	}
 }
 
@@ -588,6 +628,13 @@ func (b *Builder) maxInlineBytes() int64 {
 }
 
 func (st *tableState) flush(idx *blockfmt.Index) error {
+	return st.write(idx, false)
+}
+
+// write writes out idx as the index of the table;
+// rollback indicates whether idx was restored by
+// Builder.Rollback (see Snapshot.Rollback)
+func (st *tableState) write(idx *blockfmt.Index, rollback bool) error {
 	idx.Name = st.table
 	idx.Inputs.Backing = st.ofs
 	dir := path.Join("db", st.db, st.table)
@@ -608,7 +655,39 @@ func (st *tableState) flush(idx *blockfmt.Index) error {
 	}
 	idp := IndexPath(st.db, st.table)
 	_, err = st.ofs.WriteFile(idp, buf)
-	return err
+	if err != nil {
+		return err
+	}
+	err = st.writeSchema(idx)
+	if err != nil {
+		return err
+	}
+	return st.snapshot(idx, buf, rollback)
+}
+
+// openPacked opens the packed object
+// described by d and confirms that
+// its ETag has not changed
+func (st *tableState) openPacked(d *blockfmt.Descriptor) (fs.File, error) {
+	f, err := st.ofs.Open(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("stat-ing descriptor: %w", err)
+	}
+	etag, err := st.ofs.ETag(d.Path, info)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("getting ETag: %w", err)
+	}
+	if etag != d.ETag {
+		f.Close()
+		return nil, fmt.Errorf("ETag has changed: %s -> %s", d.ETag, etag)
+	}
+	return f, nil
 }
 
 func (st *tableState) force(idx *blockfmt.Index, prepend *blockfmt.Descriptor, lst []blockfmt.Input) error {
@@ -618,28 +697,77 @@ func (st *tableState) force(idx *blockfmt.Index, prepend *blockfmt.Descriptor, l
 		FlushMeta: st.conf.flushMeta(),
 		Comp:      "zstd",
 	}
-
-	if prepend != nil {
-		f, err := st.ofs.Open(prepend.Path)
+	var check *rowChecker
+	var dl *deadLetter
+	var rollups []*rollupRun
+	// tables populated with Append
+	// don't necessarily have a definition
+	if def, err := st.def(); err == nil {
+		check, dl, err = st.rowSchema(def)
 		if err != nil {
-			return fmt.Errorf("opening %s for re-ingest: %w", prepend.Path, err)
+			return err
 		}
-		info, err := f.Stat()
+		rollups, err = st.rollups(def)
 		if err != nil {
-			return fmt.Errorf("stat-ing re-ingest descriptor: %w", err)
+			return err
 		}
-		etag, err := st.ofs.ETag(prepend.Path, info)
-		if err != nil {
-			return fmt.Errorf("getting ETag: %w", err)
+	}
+	if check != nil || len(rollups) > 0 {
+		// rejected rows and partial aggregates
+		// are only written for objects that haven't
+		// been ingested into those tables already
+		var dlok []bool
+		if dl != nil {
+			var err error
+			dlok, err = dl.accept(lst)
+			if err != nil {
+				return err
+			}
 		}
-		if etag != prepend.ETag {
-			return fmt.Errorf("ETag has changed: %s -> %s", prepend.ETag, etag)
+		rollupok := make([][]bool, len(rollups))
+		for i := range rollups {
+			var err error
+			rollupok[i], err = rollups[i].out.accept(lst)
+			if err != nil {
+				return err
+			}
+		}
+		c.Inputs = make([]blockfmt.Input, len(lst))
+		for i := range lst {
+			f := &ingestFormat{
+				RowFormat: lst[i].F,
+				source:    lst[i].Path,
+				check:     check,
+			}
+			if dl != nil && dlok[i] {
+				f.dl = dl
+			}
+			for j := range rollups {
+				if rollupok[j][i] {
+					f.rollups = append(f.rollups, rollups[j])
+				}
+			}
+			c.Inputs[i] = lst[i]
+			c.Inputs[i].F = f
+		}
+	}
+
+	if prepend != nil {
+		f, err := st.openPacked(prepend)
+		if err != nil {
+			return fmt.Errorf("opening %s for re-ingest: %w", prepend.Path, err)
 		}
 		tr := prepend.Trailer
 		c.Prepend.R = &readCloser{Reader: io.LimitReader(f, tr.Offset), Closer: f}
 		c.Prepend.Trailer = tr
 	}
 
+	if key := st.sortKey(); key != nil {
+		rs := st.rowSorter(key)
+		defer rs.Close()
+		c.Sort = rs
+	}
+
 	name := "packed-" + uuid() + ".ion.zst"
 	fp := path.Join("db", st.db, st.table, name)
 	out, err := st.ofs.Create(fp)
@@ -650,9 +778,35 @@ func (st *tableState) force(idx *blockfmt.Index, prepend *blockfmt.Descriptor, l
 	err = c.Run()
 	if err != nil {
 		abort(out)
+		if dl != nil {
+			dl.abort()
+		}
+		for i := range rollups {
+			rollups[i].abort()
+		}
 		st.updateFailed(idx == nil, c.Inputs)
 		return fmt.Errorf("db.Builder: running blockfmt.Converter: %w", err)
 	}
+	if dl != nil {
+		// rejected rows are committed first so that
+		// they are not lost if the index update fails
+		err = dl.commit()
+		if err != nil {
+			for i := range rollups {
+				rollups[i].abort()
+			}
+			return fmt.Errorf("writing dead-letter rows: %w", err)
+		}
+	}
+	for i := range rollups {
+		err = rollups[i].commit()
+		if err != nil {
+			for _, r := range rollups[i+1:] {
+				r.abort()
+			}
+			return fmt.Errorf("writing rollup %s: %w", rollups[i].plan.name, err)
+		}
+	}
 	etag, lastmod, err := getInfo(st.ofs, fp, out)
 	if err != nil {
 		return err
@@ -667,7 +821,7 @@ func (st *tableState) force(idx *blockfmt.Index, prepend *blockfmt.Descriptor, l
 	}
 	idx.Algo = "zstd"
 	idx.Created = buildtime
-	idx.Inline = append(idx.Inline, blockfmt.Descriptor{
+	desc := blockfmt.Descriptor{
 		ObjectInfo: blockfmt.ObjectInfo{
 			Path:         fp,
 			LastModified: date.FromTime(lastmod),
@@ -676,7 +830,15 @@ func (st *tableState) force(idx *blockfmt.Index, prepend *blockfmt.Descriptor, l
 			Size:         out.Size(),
 		},
 		Trailer: c.Trailer(),
-	})
+	}
+	st.setSchema(fp, c.Schema())
+	if prepend != nil {
+		// the rows in prepend have been
+		// copied into the new object
+		st.subSchema(prepend)
+	}
+	st.addSchema(&desc)
+	idx.Inline = append(idx.Inline, desc)
 	err = st.flush(idx)
 	if err == nil {
 		err = st.runGC(idx)
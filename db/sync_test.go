@@ -189,7 +189,7 @@ func checkNoGarbage(t *testing.T, root *DirFS, dir string, idx *blockfmt.Index)
 			continue
 		}
 		name := entries[i].Name()
-		if name == "definition.json" || name == "index" {
+		if name == "definition.json" || name == "index" || name == "schema" {
 			continue
 		}
 		if _, ok := okfile[name]; !ok {
@@ -21,8 +21,6 @@ package db
 
 import (
 	"errors"
-	"fmt"
-	"io"
 	"io/fs"
 	"path"
 	"strings"
@@ -121,27 +119,7 @@ func OpenPartialIndex(s fs.FS, db, table string, key *blockfmt.Key) (*blockfmt.I
 }
 
 func openIndex(s fs.FS, db, table string, key *blockfmt.Key, opts blockfmt.Flag) (*blockfmt.Index, error) {
-	// prevent DoS: make sure index
-	// is reasonably sized
-	fp := IndexPath(db, table)
-	f, err := s.Open(fp)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	info, err := f.Stat()
-	if err != nil {
-		return nil, err
-	}
-	if info.Size() >= MaxIndexSize {
-		return nil, fmt.Errorf("index %q is %d bytes; too big", fp, info.Size())
-	}
-	buf := make([]byte, info.Size())
-	n, err := io.ReadFull(f, buf)
-	if err != nil {
-		return nil, err
-	}
-	return blockfmt.DecodeIndex(key, buf[:n], opts)
+	return readIndex(s, IndexPath(db, table), key, opts)
 }
 
 // ListTables list the names of all tables in the given
@@ -0,0 +1,114 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/expr/partiql"
+)
+
+// ViewDef is the stored definition of a view,
+// which is a saved query that can be referenced
+// in place of a table.
+//
+// A view is stored in db/{db}/{view}/view.json,
+// i.e. in the place of the definition.json of a table.
+type ViewDef struct {
+	// Name is the name of the view.
+	// Name should match the name of the
+	// view in Definition.
+	Name string `json:"name"`
+	// Definition is the CREATE VIEW
+	// statement that defines the view, i.e.
+	//
+	//	CREATE VIEW name AS SELECT ...
+	Definition string `json:"definition"`
+}
+
+// Parse parses v.Definition.
+func (v *ViewDef) Parse() (*expr.View, error) {
+	view, err := partiql.ParseView([]byte(v.Definition))
+	if err != nil {
+		return nil, fmt.Errorf("view %q: %w", v.Name, err)
+	}
+	if view.Name != v.Name {
+		return nil, fmt.Errorf("view name %q doesn't match %q", view.Name, v.Name)
+	}
+	return view, nil
+}
+
+func viewPath(db, view string) string {
+	return path.Join("db", db, view, "view.json")
+}
+
+// OpenView reads the definition of a view.
+// If there is no view with the given name,
+// the returned error satisfies
+// errors.Is(err, fs.ErrNotExist).
+func OpenView(s fs.FS, db, view string) (*ViewDef, error) {
+	f, err := s.Open(viewPath(db, view))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := checkDef(f); err != nil {
+		return nil, err
+	}
+	v := new(ViewDef)
+	err = json.NewDecoder(f).Decode(v)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", viewPath(db, view), err)
+	}
+	if v.Name != view {
+		return nil, fmt.Errorf("view name %q doesn't match %q", v.Name, view)
+	}
+	return v, nil
+}
+
+// WriteView writes a view definition to the given database.
+// The definition must be valid, and if the name of the view
+// is qualified with a database, it must be db.
+// WriteView refuses to write a view with the same
+// name as an existing table.
+func WriteView(dst OutputFS, db string, v *ViewDef) error {
+	if v.Name == "" {
+		return fmt.Errorf("cannot write view with no Name")
+	}
+	view, err := v.Parse()
+	if err != nil {
+		return err
+	}
+	if view.Database != "" && view.Database != db {
+		return fmt.Errorf("view %s.%s cannot be written to database %q", view.Database, view.Name, db)
+	}
+	_, err = OpenDefinition(dst, db, v.Name)
+	if err == nil {
+		return fmt.Errorf("view %q: a table with the same name already exists", v.Name)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = dst.WriteFile(viewPath(db, v.Name), buf)
+	return err
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestViews(t *testing.T) {
+	dfs := NewDirFS(t.TempDir())
+	defer dfs.Close()
+
+	_, err := OpenView(dfs, "db0", "prod")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist; got %v", err)
+	}
+
+	want := &ViewDef{
+		Name:       "prod",
+		Definition: "CREATE VIEW prod AS SELECT ts, status FROM events WHERE env = 'production'",
+	}
+	err = WriteView(dfs, "db0", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := OpenView(dfs, "db0", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if _, err := got.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	// views are listed along with tables
+	tables, err := ListTables(dfs, "db0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tables, []string{"prod"}) {
+		t.Fatalf("got tables %v", tables)
+	}
+
+	err = WriteDefinition(dfs, "db0", &Definition{Name: "events"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := []*ViewDef{
+		{Name: "v", Definition: "CREATE VIEW w AS SELECT * FROM events"},
+		{Name: "v", Definition: "CREATE VIEW db1.v AS SELECT * FROM events"},
+		{Name: "v", Definition: "CREATE FUNCTION v(x) AS x"},
+		{Name: "v", Definition: "CREATE VIEW v AS SELECT 1"},
+		// a table already has this name
+		{Name: "events", Definition: "CREATE VIEW events AS SELECT * FROM events"},
+	}
+	for i := range bad {
+		err := WriteView(dfs, "db0", bad[i])
+		if err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+	// a qualified name is fine
+	// as long as the database matches
+	err = WriteView(dfs, "db0", &ViewDef{
+		Name:       "v",
+		Definition: "CREATE VIEW db0.v AS SELECT * FROM prod",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -18,6 +18,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 	"unsafe"
 
@@ -86,6 +89,8 @@ func Use(i Interface, client *http.Client) {
 
 // URL is a blob that is fetched
 // using ranged reads of an HTTP(S) URL
+// or read directly from the local filesystem
+// if the URL has the file:// scheme
 type URL struct {
 	// Value is the base URL from which
 	// data will be fetched.
@@ -104,6 +109,12 @@ type URL struct {
 	// You should only unset this in testing.
 	UnsafeNoIfMatch bool
 
+	// Root, if non-empty, is the local directory
+	// under which a file:// URL must be located.
+	// URLs with the file:// scheme are only
+	// readable when Root is set.
+	Root string
+
 	// Client, if non-nil, will
 	// be used for HTTP fetches
 	// in URL.Reader
@@ -137,6 +148,10 @@ func (u *URL) encode(be *blobEncoder, dst *ion.Buffer, st *ion.Symtab) {
 		dst.BeginField(st.Intern("no-if-match"))
 		dst.WriteBool(true)
 	}
+	if u.Root != "" {
+		dst.BeginField(st.Intern("root"))
+		dst.WriteString(u.Root)
+	}
 	dst.EndStruct()
 }
 
@@ -168,6 +183,9 @@ func (d *blobDecoder) decodeURL(fields []byte) (*URL, error) {
 			u.Info.LastModified, fields, err = ion.ReadTime(fields)
 		case "no-if-match":
 			u.UnsafeNoIfMatch, fields, err = ion.ReadBool(fields)
+		case "root":
+			b, fields, err = ion.ReadStringShared(fields)
+			u.Root = d.string(b)
 		default:
 			err = fmt.Errorf("unrecognized field %q", st.Get(sym))
 		}
@@ -191,6 +209,9 @@ func (u *URL) String() string {
 
 // Reader implements blob.Interface.Reader
 func (u *URL) Reader(start, size int64) (io.ReadCloser, error) {
+	if strings.HasPrefix(u.Value, "file://") {
+		return u.fileReader(start, size)
+	}
 	req, err := http.NewRequest(http.MethodGet, u.Value, nil)
 	if err != nil {
 		return nil, err
@@ -241,6 +262,72 @@ func (u *URL) Reader(start, size int64) (io.ReadCloser, error) {
 	return res.Body, nil
 }
 
+// fileSection is an io.ReadCloser
+// for part of a local file
+type fileSection struct {
+	*io.SectionReader
+	f *os.File
+}
+
+func (f *fileSection) Close() error { return f.f.Close() }
+
+// localPath returns the path of a file:// URL
+// after checking that it is located under u.Root
+func (u *URL) localPath() (string, error) {
+	if u.Root == "" {
+		return "", fmt.Errorf("%s: file:// URL without a local root", u.Value)
+	}
+	fp := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(u.Value, "file://")))
+	rel, err := filepath.Rel(filepath.Clean(u.Root), fp)
+	if err != nil || !filepath.IsAbs(fp) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: path is not under %s", u.Value, u.Root)
+	}
+	return fp, nil
+}
+
+// fileReader implements Reader for file:// URLs
+func (u *URL) fileReader(start, size int64) (io.ReadCloser, error) {
+	fp, err := u.localPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// there is no cheap way to check the ETag,
+	// but a change in size or modification time
+	// means the file has been overwritten
+	if info.Size() != u.Info.Size {
+		f.Close()
+		return nil, fmt.Errorf("%s: unexpected size %d (expected %d)", u.Value, info.Size(), u.Info.Size)
+	}
+	if !u.Info.LastModified.IsZero() {
+		// LastModified may have been rounded up
+		// to a whole second (as S3 does), so the
+		// times are compared at that granularity
+		mt := info.ModTime().Add(time.Second - 1).Truncate(time.Second)
+		lm := u.Info.LastModified.Time().Add(time.Second - 1).Truncate(time.Second)
+		if !mt.Equal(lm) {
+			f.Close()
+			return nil, fmt.Errorf("%s: unexpected modification time %s (expected %s)", u.Value, info.ModTime().UTC(), u.Info.LastModified)
+		}
+	}
+	end := start + size
+	if end > u.Info.Size {
+		end = u.Info.Size
+	}
+	return &fileSection{
+		SectionReader: io.NewSectionReader(f, start, end-start),
+		f:             f,
+	}, nil
+}
+
 // List implements expr.Opaque
 type List struct {
 	Contents []Interface
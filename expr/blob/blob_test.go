@@ -23,6 +23,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -158,6 +159,59 @@ func TestBlobs(t *testing.T) {
 	s.Close()
 }
 
+func TestFileBlob(t *testing.T) {
+	backing := make([]byte, 2*1024*1024)
+	rand.Read(backing)
+	dir := t.TempDir()
+	fp := filepath.Join(dir, "backing")
+	err := os.WriteFile(fp, backing, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &URL{
+		Value: "file://" + fp,
+		Root:  dir,
+		Info: Info{
+			Size:         int64(len(backing)),
+			Align:        1024 * 1024,
+			LastModified: date.FromTime(fi.ModTime()),
+		},
+	}
+	testRead(t, b, backing)
+
+	mustFail := func(what string, u URL) {
+		t.Helper()
+		rd, err := u.Reader(0, u.Info.Size)
+		if err == nil {
+			rd.Close()
+			t.Fatalf("%s: expected an error", what)
+		}
+	}
+	// a size mismatch should be detected
+	bad := *b
+	bad.Info.Size--
+	mustFail("size", bad)
+	// ... as should a modification time mismatch
+	bad = *b
+	bad.Info.LastModified = bad.Info.LastModified.Add(-time.Second)
+	mustFail("mtime", bad)
+	// file:// URLs are not readable without a root
+	bad = *b
+	bad.Root = ""
+	mustFail("no root", bad)
+	// ... and must be located under the root
+	bad = *b
+	bad.Root = filepath.Join(dir, "sub")
+	mustFail("outside root", bad)
+	bad = *b
+	bad.Value = "file://" + filepath.Join(dir, "..", filepath.Base(dir)+"x", "backing")
+	mustFail("escaping root", bad)
+}
+
 func TestSerialization(t *testing.T) {
 	now := date.Now().Truncate(time.Microsecond)
 	lst := &List{
@@ -174,7 +228,8 @@ func TestSerialization(t *testing.T) {
 				Trailer: &blockfmt.Trailer{Version: 1, Algo: "zstd"},
 			},
 			&URL{
-				Value: "http://foo.bar/baz",
+				Value: "file:///srv/foo/baz",
+				Root:  "/srv/foo",
 				Info: Info{
 					Size:         rand.Int63(),
 					Align:        1000,
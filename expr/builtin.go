@@ -201,8 +201,12 @@ const (
 
 	ObjectSize // SIZE(x)
 
+	MakeStruct // {'a': x, ...}; produced by struct constructors
+	MakeList   // [x, ...]; produced by list constructors
+
 	TableGlob
 	TablePattern
+	TableAt // TABLE_AT(db.table, ts); produced by 'db.table AT TIMESTAMP ...'
 
 	// used by query planner:
 	InSubquery        // matches IN (SELECT ...)
@@ -316,8 +320,11 @@ var name2Builtin = map[string]BuiltinOp{
 	"TO_UNIX_EPOCH":            DateToUnixEpoch,
 	"TO_UNIX_MICRO":            DateToUnixMicro,
 	"SIZE":                     ObjectSize,
+	"MAKE_STRUCT":              MakeStruct,
+	"MAKE_LIST":                MakeList,
 	"TABLE_GLOB":               TableGlob,
 	"TABLE_PATTERN":            TablePattern,
+	"TABLE_AT":                 TableAt,
 }
 
 var builtin2Name [maxBuiltin]string
@@ -823,6 +830,76 @@ func simplifyObjectSize(h Hint, args []Node) (result Node) {
 	return nil
 }
 
+// checkMakeStruct checks that the arguments
+// to MAKE_STRUCT are pairs of distinct constant
+// field names and values
+func checkMakeStruct(h Hint, args []Node) error {
+	if len(args)%2 != 0 {
+		return errsyntaxf("MAKE_STRUCT expects an even number of arguments, but found %d", len(args))
+	}
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(String)
+		if !ok {
+			return errsyntaxf("struct field name %s is not a string", ToString(args[i]))
+		}
+		for j := 0; j < i; j += 2 {
+			if args[j].(String) == key {
+				return errsyntaxf("duplicate struct field %q", string(key))
+			}
+		}
+	}
+	return nil
+}
+
+func checkMakeList(h Hint, args []Node) error {
+	for i := range args {
+		if _, ok := args[i].(Star); ok {
+			return errsyntaxf("cannot use * in a list constructor")
+		}
+	}
+	return nil
+}
+
+// simplifyMakeStruct folds a struct constructor
+// with constant fields into a Struct;
+// fields that are MISSING are omitted
+func simplifyMakeStruct(h Hint, args []Node) Node {
+	if checkMakeStruct(h, args) != nil {
+		return nil
+	}
+	s := &Struct{}
+	for i := 0; i < len(args); i += 2 {
+		if _, ok := args[i+1].(Missing); ok {
+			continue
+		}
+		c, ok := args[i+1].(Constant)
+		if !ok {
+			return nil
+		}
+		s.Fields = append(s.Fields, Field{Label: string(args[i].(String)), Value: c})
+	}
+	return s
+}
+
+// simplifyMakeList folds a list constructor
+// with constant items into a List;
+// items that are MISSING become NULL
+func simplifyMakeList(h Hint, args []Node) Node {
+	l := &List{Values: make([]Constant, len(args))}
+	for i := range args {
+		if _, ok := args[i].(Missing); ok {
+			l.Values[i] = Null{}
+			continue
+		}
+		c, ok := args[i].(Constant)
+		if !ok {
+			return nil
+		}
+		l.Values[i] = c
+	}
+	return l
+}
+
 func simplifyConcat(h Hint, args []Node) Node {
 	if len(args) != 2 {
 		return nil
@@ -858,6 +935,19 @@ func checkTablePattern(h Hint, args []Node) error {
 	return nil
 }
 
+func checkTableAt(h Hint, args []Node) error {
+	if len(args) != 2 {
+		return mismatch(2, len(args))
+	}
+	if _, ok := args[0].(*Path); !ok {
+		return errsyntaxf("first argument to TABLE_AT is %q", ToString(args[0]))
+	}
+	if _, ok := args[1].(*Timestamp); !ok {
+		return errsyntaxf("second argument to TABLE_AT is %q; expected a timestamp", ToString(args[1]))
+	}
+	return nil
+}
+
 var builtinInfo = [maxBuiltin]binfo{
 	Concat:     {check: fixedArgs(StringType, StringType), private: true, ret: StringType | MissingType, simplify: simplifyConcat},
 	Trim:       {check: checkTrim, ret: StringType | MissingType, simplify: simplifyTrim},
@@ -948,6 +1038,8 @@ var builtinInfo = [maxBuiltin]binfo{
 	GeoDistance: {check: fixedArgs(NumericType, NumericType, NumericType, NumericType), ret: FloatType | MissingType},
 
 	ObjectSize: {check: checkObjectSize, ret: NumericType | MissingType, simplify: simplifyObjectSize},
+	MakeStruct: {check: checkMakeStruct, private: true, ret: StructType, simplify: simplifyMakeStruct},
+	MakeList:   {check: checkMakeList, private: true, ret: ListType, simplify: simplifyMakeList},
 
 	InSubquery:        {check: checkInSubquery, private: true, ret: LogicalType},
 	HashLookup:        {check: checkHashLookup, private: true, ret: AnyType},
@@ -961,6 +1053,7 @@ var builtinInfo = [maxBuiltin]binfo{
 
 	TableGlob:    {check: checkTableGlob, ret: AnyType, isTable: true},
 	TablePattern: {check: checkTablePattern, ret: AnyType, isTable: true},
+	TableAt:      {check: checkTableAt, ret: AnyType, isTable: true},
 }
 
 func (b *Builtin) isTable() bool {
@@ -103,6 +103,26 @@ func TestCheck(t *testing.T) {
 			&TypeError{},
 			"SIZE is undefined for values of type integer",
 		},
+		{
+			CallOp(MakeStruct, String("a"), path("x"), String("b")),
+			&SyntaxError{},
+			"MAKE_STRUCT expects an even number of arguments, but found 3",
+		},
+		{
+			CallOp(MakeStruct, String("a"), path("x"), String("a"), path("y")),
+			&SyntaxError{},
+			"duplicate struct field \"a\"",
+		},
+		{
+			CallOp(MakeStruct, path("a"), path("x")),
+			&SyntaxError{},
+			"struct field name a is not a string",
+		},
+		{
+			CallOp(MakeList, path("x"), Star{}),
+			&SyntaxError{},
+			"cannot use * in a list constructor",
+		},
 		{
 			CallOp(ObjectSize, Float(1.5)),
 			&TypeError{},
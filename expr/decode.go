@@ -142,6 +142,8 @@ func getEmpty(name string) composite {
 		return &Cast{}
 	case "member":
 		return &Member{}
+	case "semijoin":
+		return &SemiJoin{}
 	case "struct":
 		return &Struct{}
 	case "list":
@@ -0,0 +1,266 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// Function is a user-defined function,
+// i.e. CREATE FUNCTION name(params...) AS body
+//
+// Functions are macros: each call to a Function
+// is replaced with a copy of Body in which each
+// of the parameters is replaced with the
+// corresponding argument (see Inline).
+type Function struct {
+	// Name is the name of the function.
+	Name string
+	// Params are the names of the parameters
+	// of the function.
+	Params []string
+	// Body is the expression that replaces
+	// calls to the function.
+	Body Node
+}
+
+// Text returns the CREATE FUNCTION
+// statement that defines f.
+func (f *Function) Text() string {
+	var out strings.Builder
+	out.WriteString("CREATE FUNCTION ")
+	out.WriteString(QuoteID(f.Name))
+	out.WriteByte('(')
+	for i := range f.Params {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(QuoteID(f.Params[i]))
+	}
+	out.WriteString(") AS ")
+	f.Body.text(&out, false)
+	return out.String()
+}
+
+func (f *Function) param(name string) int {
+	for i := range f.Params {
+		if f.Params[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+type fncheck struct {
+	fn  *Function
+	err error
+}
+
+func (c *fncheck) Visit(e Node) Visitor {
+	if c.err != nil || e == nil {
+		return nil
+	}
+	switch n := e.(type) {
+	case *Select:
+		c.err = errsyntaxf("function %s: sub-queries are not supported in function bodies", c.fn.Name)
+		return nil
+	case *Path:
+		if c.fn.param(n.First) < 0 {
+			c.err = errsyntaxf("function %s: unknown parameter %q", c.fn.Name, n.First)
+		}
+	case Star:
+		c.err = errsyntaxf("function %s: cannot use * in a function body", c.fn.Name)
+	}
+	return c
+}
+
+// Check checks that f is a valid definition.
+// Each of the paths referenced in f.Body must
+// begin with one of the parameters of f, and
+// the name of f must not be the name of a builtin.
+func (f *Function) Check() error {
+	if f.Name == "" {
+		return errsyntax("function has no name")
+	}
+	if Call(f.Name).Func != Unspecified {
+		return errsyntaxf("cannot redefine builtin %s", strings.ToUpper(f.Name))
+	}
+	for i := range f.Params {
+		if f.param(f.Params[i]) != i {
+			return errsyntaxf("function %s: duplicate parameter %q", f.Name, f.Params[i])
+		}
+	}
+	if f.Body == nil {
+		return errsyntaxf("function %s has no body", f.Name)
+	}
+	c := &fncheck{fn: f}
+	Walk(c, f.Body)
+	return c.err
+}
+
+// appendPath returns a copy of p
+// with rest appended to the end
+func appendPath(p *Path, rest PathComponent) *Path {
+	out := p.Clone()
+	if out.Rest == nil {
+		out.Rest = rest.clone()
+		return out
+	}
+	last := out.Rest
+	for last.Next() != nil {
+		last = last.Next()
+	}
+	switch l := last.(type) {
+	case *Dot:
+		l.Rest = rest.clone()
+	case *LiteralIndex:
+		l.Rest = rest.clone()
+	default:
+		return nil
+	}
+	return out
+}
+
+type substitute struct {
+	fn   *Function
+	args []Node
+	err  error
+}
+
+func (s *substitute) Walk(e Node) Rewriter {
+	if s.err != nil {
+		return nil
+	}
+	return s
+}
+
+func (s *substitute) Rewrite(e Node) Node {
+	p, ok := e.(*Path)
+	if !ok || s.err != nil {
+		return e
+	}
+	i := s.fn.param(p.First)
+	if i < 0 {
+		return e
+	}
+	arg := Copy(s.args[i])
+	if p.Rest == nil {
+		return arg
+	}
+	ap, ok := arg.(*Path)
+	if ok {
+		ap = appendPath(ap, p.Rest)
+	}
+	if ap == nil {
+		s.err = errsyntaxf("function %s: cannot reference a field of parameter %q with argument %s",
+			s.fn.Name, p.First, ToString(s.args[i]))
+		return e
+	}
+	return ap
+}
+
+// Call returns a copy of the body of f
+// with each parameter replaced by the
+// corresponding argument in args.
+func (f *Function) Call(args []Node) (Node, error) {
+	if len(args) != len(f.Params) {
+		return nil, errsyntaxf("function %s expects %d arguments but found %d", f.Name, len(f.Params), len(args))
+	}
+	s := &substitute{fn: f, args: args}
+	body := Rewrite(s, Copy(f.Body))
+	if s.err != nil {
+		return nil, s.err
+	}
+	return body, nil
+}
+
+// Copy returns a deep copy of e.
+func Copy(e Node) Node {
+	var dst ion.Buffer
+	var st ion.Symtab
+	e.Encode(&dst, &st)
+	ret, _, err := Decode(&st, dst.Bytes())
+	if err != nil {
+		panic(fmt.Sprintf("expr.Copy: cannot decode %s: %s", ToString(e), err))
+	}
+	return ret
+}
+
+// maxInlineDepth is the maximum depth of
+// nested function calls that are expanded
+// by Inline; deeper nesting is assumed to
+// be the result of a recursive definition
+const maxInlineDepth = 32
+
+type inliner struct {
+	lookup func(name string) (*Function, error)
+	depth  int
+	err    error
+}
+
+func (i *inliner) Walk(e Node) Rewriter {
+	if i.err != nil {
+		return nil
+	}
+	return i
+}
+
+func (i *inliner) Rewrite(e Node) Node {
+	b, ok := e.(*Builtin)
+	if !ok || b.Func != Unspecified || i.err != nil {
+		return e
+	}
+	fn, err := i.lookup(b.Text)
+	if err != nil {
+		i.err = err
+		return e
+	}
+	if fn == nil {
+		return e
+	}
+	if i.depth >= maxInlineDepth {
+		i.err = errsyntaxf("function %s: too many levels of nested calls (recursive definition?)", fn.Name)
+		return e
+	}
+	body, err := fn.Call(b.Args)
+	if err != nil {
+		i.err = err
+		return e
+	}
+	// the arguments have already been inlined,
+	// but the body may call other functions
+	inner := &inliner{lookup: i.lookup, depth: i.depth + 1}
+	body = Rewrite(inner, body)
+	if inner.err != nil {
+		i.err = inner.err
+		return e
+	}
+	return body
+}
+
+// Inline replaces each call to a user-defined
+// function in e with the body of the function.
+// The lookup function is called with the name
+// of each function that is not a builtin,
+// and it should return (nil, nil) if there
+// is no function with the given name.
+func Inline(e Node, lookup func(name string) (*Function, error)) (Node, error) {
+	i := &inliner{lookup: lookup}
+	e = Rewrite(i, e)
+	return e, i.err
+}
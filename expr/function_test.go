@@ -0,0 +1,145 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInline(t *testing.T) {
+	fns := []*Function{{
+		// CREATE FUNCTION status_class(code) AS CASE WHEN code >= 500 THEN 'server' ELSE 'ok' END
+		Name:   "status_class",
+		Params: []string{"code"},
+		Body:   casen(Compare(GreaterEquals, Identifier("code"), Integer(500)), String("server"), String("ok")),
+	}, {
+		// CREATE FUNCTION add_field(r, n) AS r.x + n
+		Name:   "add_field",
+		Params: []string{"r", "n"},
+		Body:   Add(&Path{First: "r", Rest: &Dot{Field: "x"}}, Identifier("n")),
+	}, {
+		// CREATE FUNCTION twice(n) AS add_field(n, n)
+		Name:   "twice",
+		Params: []string{"n"},
+		Body:   Call("add_field", Identifier("n"), Identifier("n")),
+	}, {
+		// CREATE FUNCTION loop(n) AS loop(n)
+		Name:   "loop",
+		Params: []string{"n"},
+		Body:   Call("loop", Identifier("n")),
+	}}
+	lookup := func(name string) (*Function, error) {
+		for i := range fns {
+			if strings.EqualFold(fns[i].Name, name) {
+				return fns[i], nil
+			}
+		}
+		return nil, nil
+	}
+	for i := range fns {
+		if err := fns[i].Check(); err != nil {
+			t.Fatalf("%s: %s", fns[i].Text(), err)
+		}
+	}
+
+	tcs := []struct {
+		in   Node
+		want string // or error text
+	}{
+		{
+			in:   Call("STATUS_CLASS", Identifier("status")),
+			want: "CASE WHEN status >= 500 THEN 'server' ELSE 'ok' END",
+		},
+		{
+			in:   Call("add_field", &Path{First: "t", Rest: &Dot{Field: "y"}}, Integer(1)),
+			want: "t.y.x + 1",
+		},
+		{
+			// arguments are inlined first
+			in:   Call("add_field", Identifier("t"), Call("status_class", Identifier("s"))),
+			want: "t.x + CASE WHEN s >= 500 THEN 'server' ELSE 'ok' END",
+		},
+		{
+			in:   Call("twice", Identifier("t")),
+			want: "t.x + t",
+		},
+		{
+			// unknown functions are left alone
+			in:   Call("unknown_fn", Identifier("t")),
+			want: "UNKNOWN_FN(t)",
+		},
+		{
+			in:   Call("add_field", Integer(1), Integer(2)),
+			want: "cannot reference a field of parameter",
+		},
+		{
+			in:   Call("status_class"),
+			want: "expects 1 arguments but found 0",
+		},
+		{
+			in:   Call("loop", Integer(1)),
+			want: "too many levels of nested calls",
+		},
+	}
+	for i := range tcs {
+		out, err := Inline(tcs[i].in, lookup)
+		if err != nil {
+			if !strings.Contains(err.Error(), tcs[i].want) {
+				t.Errorf("case %d: unexpected error %s", i, err)
+			}
+			continue
+		}
+		if got := ToString(out); got != tcs[i].want {
+			t.Errorf("case %d: got %s, want %s", i, got, tcs[i].want)
+		}
+	}
+	// the function bodies must not be modified
+	if got := ToString(fns[1].Body); got != "r.x + n" {
+		t.Errorf("body modified: %s", got)
+	}
+}
+
+func TestFunctionCheck(t *testing.T) {
+	tcs := []struct {
+		fn   Function
+		want string
+	}{
+		{
+			fn:   Function{Name: "f", Params: []string{"a"}, Body: Identifier("b")},
+			want: `unknown parameter "b"`,
+		},
+		{
+			fn:   Function{Name: "f", Params: []string{"a", "a"}, Body: Identifier("a")},
+			want: `duplicate parameter "a"`,
+		},
+		{
+			fn:   Function{Name: "lower", Params: []string{"a"}, Body: Identifier("a")},
+			want: "cannot redefine builtin LOWER",
+		},
+		{
+			fn: Function{Name: "f", Params: []string{"a"}, Body: &Select{
+				Columns: []Binding{Bind(Identifier("a"), "")},
+			}},
+			want: "sub-queries are not supported",
+		},
+	}
+	for i := range tcs {
+		err := tcs[i].fn.Check()
+		if err == nil || !strings.Contains(err.Error(), tcs[i].want) {
+			t.Errorf("case %d: got error %v, want %q", i, err, tcs[i].want)
+		}
+	}
+}
@@ -1711,6 +1711,36 @@ func (b *Builtin) setfield(name string, st *ion.Symtab, body []byte) error {
 }
 
 func (b *Builtin) text(dst *strings.Builder, redact bool) {
+	switch b.Func {
+	case MakeStruct:
+		// print constructors as literals
+		// so that they can be parsed again
+		dst.WriteByte('{')
+		for i := 0; i+1 < len(b.Args); i += 2 {
+			if i != 0 {
+				dst.WriteString(", ")
+			}
+			if key, ok := b.Args[i].(String); ok {
+				sqlQuote(dst, string(key))
+			} else {
+				b.Args[i].text(dst, redact)
+			}
+			dst.WriteString(": ")
+			b.Args[i+1].text(dst, redact)
+		}
+		dst.WriteByte('}')
+		return
+	case MakeList:
+		dst.WriteByte('[')
+		for i := range b.Args {
+			if i != 0 {
+				dst.WriteString(", ")
+			}
+			b.Args[i].text(dst, redact)
+		}
+		dst.WriteByte(']')
+		return
+	}
 	dst.WriteString(b.Name())
 	dst.WriteByte('(')
 	for i := range b.Args {
@@ -3002,6 +3032,8 @@ func AsConstant(d ion.Datum) (Constant, bool) {
 		return &Timestamp{Value: date.Time(d)}, true
 	case ion.Bool:
 		return Bool(d), true
+	case ion.UntypedNull:
+		return Null{}, true
 	default:
 		// TODO: add blob, clob, bags, etc.
 		return nil, false
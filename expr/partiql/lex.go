@@ -44,6 +44,15 @@ type scanner struct {
 	// notkw is set when
 	// we are not in keyword context
 	notkw bool
+	// create is set after CREATE
+	// until the following AS
+	create bool
+	// function is the result of
+	// parsing CREATE FUNCTION
+	function *expr.Function
+	// view is the result of
+	// parsing CREATE VIEW
+	view *expr.View
 
 	// value of UTCNOW(); populated lazily
 	// (we need every instance of UTCNOW()
@@ -228,6 +237,13 @@ func (s *scanner) lexIdent(l *yySymType) int {
 		s.pos++
 	}
 	wordend := s.pos == len(s.from) || issep(s.from[s.pos])
+	if !s.notkw && wordend && s.tableAt(s.from[startpos:s.pos]) {
+		return AT
+	}
+	if !s.notkw && wordend && s.createAt(startpos) {
+		s.create = true
+		return CREATE
+	}
 	if !s.notkw && wordend {
 		// don't perform string allocation if we have a keyword
 		term := kwterms.get(s.from[startpos:s.pos])
@@ -236,7 +252,11 @@ func (s *scanner) lexIdent(l *yySymType) int {
 			// next word as a case-sensitive identifier
 			if term == AS {
 				s.chompws()
-				s.notkw = true
+				// the first AS following CREATE
+				// introduces a definition rather
+				// than a binding
+				s.notkw = !s.create
+				s.create = false
 			}
 			return term
 		}
@@ -246,6 +266,51 @@ func (s *scanner) lexIdent(l *yySymType) int {
 	return ID
 }
 
+// tableAt returns whether word is the AT in
+// <table> AT TIMESTAMP '...'; AT is not a keyword
+// anywhere else, so it may be used as an identifier
+func (s *scanner) tableAt(word []byte) bool {
+	if !bytes.EqualFold(word, []byte("AT")) {
+		return false
+	}
+	i := s.pos
+	for i < len(s.from) && isspace(s.from[i]) {
+		i++
+	}
+	j := i
+	for j < len(s.from) && isident(s.from[j]) {
+		j++
+	}
+	if i == s.pos || !bytes.EqualFold(s.from[i:j], []byte("TIMESTAMP")) {
+		return false
+	}
+	for j < len(s.from) && isspace(s.from[j]) {
+		j++
+	}
+	return j < len(s.from) && s.from[j] == '\''
+}
+
+// createAt returns whether the word at start
+// is the CREATE that begins a definition;
+// CREATE is only a keyword as the first word
+// of the input, so it may be used as an
+// identifier anywhere else
+func (s *scanner) createAt(start int) bool {
+	if !bytes.EqualFold(s.from[start:s.pos], []byte("CREATE")) {
+		return false
+	}
+	for i := 0; i < start; i++ {
+		if s.from[i] == '#' {
+			for i < start && s.from[i] != '\n' {
+				i++
+			}
+		} else if !isspace(s.from[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 // lexNumber lexes a number-like thing
 // (NOTE: this is too permissive; we do the actual
 // checking for valid numbers at parse time)
@@ -20,6 +20,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/SnellerInc/sneller/date"
 	"github.com/SnellerInc/sneller/expr"
 )
 
@@ -55,6 +56,12 @@ func Parse(in []byte) (*expr.Query, error) {
 	if ret != 0 {
 		return nil, fmt.Errorf("parse error %d", ret)
 	}
+	if s.function != nil {
+		return nil, fmt.Errorf("unexpected CREATE FUNCTION (see ParseFunction)")
+	}
+	if s.view != nil {
+		return nil, fmt.Errorf("unexpected CREATE VIEW (see ParseView)")
+	}
 	return &expr.Query{
 		With: s.with,
 		Into: s.into,
@@ -62,6 +69,94 @@ func Parse(in []byte) (*expr.Query, error) {
 	}, nil
 }
 
+// ParseFunction parses a user-defined
+// function definition of the form
+//
+//	CREATE FUNCTION name(params...) AS expr
+//
+// and returns the result, or an error if one
+// is encountered. The returned function has
+// been checked with expr.Function.Check.
+func ParseFunction(in []byte) (*expr.Function, error) {
+	s := &scanner{from: in}
+	p := newParser()
+	ret := p.Parse(s)
+	dropParser(p)
+	if s.err != nil && s.err != io.EOF {
+		return nil, s.err
+	}
+	if ret != 0 {
+		return nil, fmt.Errorf("parse error %d", ret)
+	}
+	if s.function == nil {
+		return nil, fmt.Errorf("expected CREATE FUNCTION")
+	}
+	if err := s.function.Check(); err != nil {
+		return nil, err
+	}
+	return s.function, nil
+}
+
+// define handles CREATE kind name(params...) AS body
+func (s *scanner) define(kind, name string, params []string, body expr.Node) {
+	if !strings.EqualFold(kind, "FUNCTION") {
+		s.Error(fmt.Sprintf("unexpected CREATE %s", kind))
+		return
+	}
+	s.function = &expr.Function{Name: name, Params: params, Body: body}
+}
+
+// ParseView parses a view definition
+// of the form
+//
+//	CREATE VIEW [db.]name AS SELECT ...
+//
+// and returns the result, or an error if one
+// is encountered. The returned view has
+// been checked with expr.View.Check.
+func ParseView(in []byte) (*expr.View, error) {
+	s := &scanner{from: in}
+	p := newParser()
+	ret := p.Parse(s)
+	dropParser(p)
+	if s.err != nil && s.err != io.EOF {
+		return nil, s.err
+	}
+	if ret != 0 {
+		return nil, fmt.Errorf("parse error %d", ret)
+	}
+	if s.view == nil {
+		return nil, fmt.Errorf("expected CREATE VIEW")
+	}
+	if err := s.view.Check(); err != nil {
+		return nil, err
+	}
+	return s.view, nil
+}
+
+// defineView handles CREATE kind [db.]name AS query
+func (s *scanner) defineView(kind string, name expr.Node, query *expr.Select) {
+	if !strings.EqualFold(kind, "VIEW") {
+		s.Error(fmt.Sprintf("unexpected CREATE %s", kind))
+		return
+	}
+	p, ok := name.(*expr.Path)
+	if !ok {
+		s.Error(fmt.Sprintf("invalid view name %s", expr.ToString(name)))
+		return
+	}
+	v := &expr.View{Name: p.First, Query: query}
+	if p.Rest != nil {
+		d, ok := p.Rest.(*expr.Dot)
+		if !ok || d.Rest != nil {
+			s.Error(fmt.Sprintf("invalid view name %s", expr.ToString(name)))
+			return
+		}
+		v.Database, v.Name = p.First, d.Field
+	}
+	s.view = v
+}
+
 // we parse CAST() using identifiers
 // rather than keywords so that we can
 // preserve the invariant that the token
@@ -125,6 +220,26 @@ func timePart(id string) (expr.Timepart, bool) {
 	return part, true
 }
 
+// tableAt produces the table expression
+// for '<tbl> AT TIMESTAMP <str>'
+//
+// (like the type name in a CAST, TIMESTAMP
+// is parsed as an identifier so that it
+// can still be used as a field name)
+func tableAt(tbl expr.Node, id, str string) (expr.Node, bool) {
+	if strings.ToUpper(id) != "TIMESTAMP" {
+		return nil, false
+	}
+	if _, ok := tbl.(*expr.Path); !ok {
+		return nil, false
+	}
+	ts, ok := date.Parse([]byte(str))
+	if !ok {
+		return nil, false
+	}
+	return expr.CallOp(expr.TableAt, tbl, &expr.Timestamp{Value: ts}), true
+}
+
 func exists(s *expr.Select) expr.Node {
 	if s.Limit != nil && int(*s.Limit) == 0 {
 		return expr.Bool(false)
@@ -70,6 +70,8 @@ var sameq = []string{
 	"WITH foo AS (SELECT x, y FROM table), bar AS (SELECT z, a FROM table) SELECT x FROM foo CROSS JOIN bar",
 	"SELECT * FROM (t1 ++ t2 ++ t3)",
 	"SELECT x, y INTO db.xyz FROM db.foo WHERE x = 'foo' AND y = 'bar'",
+	"SELECT {'a': x, 'b': [y, z + 1], 'c': {'d': 'foo'}} AS obj FROM table",
+	"SELECT x, [x, y] AS pair, [] AS empty, {} AS nothing FROM table",
 }
 
 func TestParseSFW(t *testing.T) {
@@ -229,6 +231,28 @@ func TestParseNormalization(t *testing.T) {
 			"SELECT EXISTS(SELECT x, y FROM foo WHERE x = 3) AS exist",
 			"SELECT (SELECT x, y FROM foo WHERE x = 3 LIMIT 1) IS NOT MISSING AS exist",
 		},
+		{
+			"SELECT COUNT(*) FROM db.foo AT TIMESTAMP '2022-10-01T00:00:00Z'",
+			"SELECT COUNT(*) FROM TABLE_AT(db.foo, `2022-10-01T00:00:00Z`)",
+		},
+		{
+			"select f.x from db.foo at timestamp '2022-10-01T00:00:00Z' as f where f.timestamp > `2022-09-01T00:00:00Z`",
+			"SELECT f.x FROM TABLE_AT(db.foo, `2022-10-01T00:00:00Z`) AS f WHERE BEFORE(`2022-09-01T00:00:00Z`, f.timestamp)",
+		},
+		{
+			"SELECT * FROM a AT TIMESTAMP '2022-10-01T00:00:00Z' x JOIN b ON x.id = b.id",
+			"SELECT * FROM TABLE_AT(a, `2022-10-01T00:00:00Z`) AS x JOIN b ON x.id = b.id",
+		},
+		{
+			// AT is only a keyword before TIMESTAMP '...'
+			"SELECT at, x.at FROM foo AS at WHERE at LIKE 'x%'",
+			"SELECT at, x.at FROM foo AS at WHERE at LIKE 'x%'",
+		},
+		{
+			// CREATE is only a keyword at the start of the input
+			"SELECT create, x.create FROM foo AS x WHERE create > 1 ORDER BY create",
+			"SELECT create, x.create FROM foo AS x WHERE create > 1 ORDER BY create ASC NULLS FIRST",
+		},
 	}
 
 	tm, ok := date.Parse([]byte("2006-01-02T15:04:05.999Z"))
@@ -269,6 +293,8 @@ func TestParseGarbage(t *testing.T) {
 		"select * from t where",
 		"select * limit 3 where foo = bar from x",
 		"select CAST(x AS notatype) from y",
+		"select * from y at timestamp 'yesterday'",
+		"select * from y at time '2022-10-01T00:00:00Z'",
 		"select a[1E100] from y",
 		"seleCt CoAlesC%(CoAlesC%(A[10000000000000000000]))",
 	}
@@ -280,6 +306,126 @@ func TestParseGarbage(t *testing.T) {
 	}
 }
 
+func TestParseFunction(t *testing.T) {
+	defs := []struct {
+		text, want string
+	}{
+		{
+			text: "CREATE FUNCTION status_class(code) AS CASE WHEN code >= 500 THEN 'server' WHEN code >= 400 THEN 'client' ELSE 'ok' END",
+			want: "CREATE FUNCTION status_class(code) AS CASE WHEN code >= 500 THEN 'server' WHEN code >= 400 THEN 'client' ELSE 'ok' END",
+		},
+		{
+			text: "create function no_args() as 1",
+			want: "CREATE FUNCTION no_args() AS 1",
+		},
+		{
+			text: "# comment\n  CREATE FUNCTION create_at(create) AS create + 1",
+			want: "CREATE FUNCTION create_at(create) AS create + 1",
+		},
+		{
+			text: "CREATE FUNCTION fld(a, b) AS a.x + b",
+			want: "CREATE FUNCTION fld(a, b) AS a.x + b",
+		},
+	}
+	for i := range defs {
+		fn, err := ParseFunction([]byte(defs[i].text))
+		if err != nil {
+			t.Errorf("%q: %s", defs[i].text, err)
+			continue
+		}
+		if got := fn.Text(); got != defs[i].want {
+			t.Errorf("got %q, want %q", got, defs[i].want)
+		}
+		// the canonical text must
+		// produce the same function
+		fn2, err := ParseFunction([]byte(fn.Text()))
+		if err != nil {
+			t.Errorf("%q: %s", fn.Text(), err)
+		} else if !fn2.Body.Equals(fn.Body) {
+			t.Errorf("%q: body not equal after re-parsing", fn.Text())
+		}
+	}
+
+	bad := []string{
+		"SELECT * FROM t",
+		"CREATE FUNCTION f(a) AS b",
+		"CREATE FUNCTION upper(a) AS a",
+		"CREATE FUNCTION f(a, a) AS a",
+		"CREATE THING f(a) AS a",
+		"CREATE FUNCTION f(a) AS (SELECT a FROM t)",
+	}
+	for i := range bad {
+		_, err := ParseFunction([]byte(bad[i]))
+		if err == nil {
+			t.Errorf("case %q: err == nil?", bad[i])
+		}
+	}
+	// Parse only accepts queries
+	_, err := Parse([]byte("CREATE FUNCTION f(a) AS a"))
+	if err == nil {
+		t.Error("Parse accepted CREATE FUNCTION")
+	}
+}
+
+func TestParseView(t *testing.T) {
+	defs := []struct {
+		text, want string
+	}{
+		{
+			text: "CREATE VIEW prod AS SELECT * FROM events WHERE env = 'production'",
+			want: "CREATE VIEW prod AS SELECT * FROM events WHERE env = 'production'",
+		},
+		{
+			text: "create view db0.prod as (select ts, env as environment from events where env = 'production')",
+			want: "CREATE VIEW db0.prod AS SELECT ts, env AS environment FROM events WHERE env = 'production'",
+		},
+		{
+			text: "CREATE VIEW counts AS SELECT COUNT(*) FROM prod",
+			want: "CREATE VIEW counts AS SELECT COUNT(*) FROM prod",
+		},
+	}
+	for i := range defs {
+		v, err := ParseView([]byte(defs[i].text))
+		if err != nil {
+			t.Errorf("%q: %s", defs[i].text, err)
+			continue
+		}
+		if got := v.Text(); got != defs[i].want {
+			t.Errorf("got %q, want %q", got, defs[i].want)
+		}
+		// the canonical text must
+		// produce the same view
+		v2, err := ParseView([]byte(v.Text()))
+		if err != nil {
+			t.Errorf("%q: %s", v.Text(), err)
+		} else if !v2.Query.Equals(v.Query) {
+			t.Errorf("%q: query not equal after re-parsing", v.Text())
+		}
+	}
+
+	bad := []string{
+		"SELECT * FROM t",
+		"CREATE FUNCTION f(a) AS a",
+		"CREATE TABLE t AS SELECT * FROM u",
+		"CREATE VIEW a.b.c AS SELECT * FROM t",
+		"CREATE VIEW v AS SELECT 1",
+	}
+	for i := range bad {
+		_, err := ParseView([]byte(bad[i]))
+		if err == nil {
+			t.Errorf("case %q: err == nil?", bad[i])
+		}
+	}
+	_, err := Parse([]byte("CREATE VIEW v AS SELECT * FROM t"))
+	if err == nil {
+		t.Error("Parse accepted CREATE VIEW")
+	}
+	_, err = ParseFunction([]byte("CREATE VIEW v AS SELECT * FROM t"))
+	if err == nil {
+		t.Error("ParseFunction accepted CREATE VIEW")
+	}
+}
+
 func testEquivalence(t *testing.T, e expr.Node) {
 	var obuf ion.Buffer
 	var st ion.Symtab
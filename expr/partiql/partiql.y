@@ -51,12 +51,13 @@ import (
     limbs    []expr.CaseLimb
     values   []expr.Node
     orders   []expr.Order
+    strs     []string
 }
 
 %token ERROR EOF
 %left UNION
-%token SELECT FROM WHERE GROUP ORDER BY HAVING LIMIT OFFSET WITH INTO
-%token DISTINCT ALL AS EXISTS NULLS FIRST LAST ASC DESC
+%token SELECT FROM WHERE GROUP ORDER BY HAVING LIMIT OFFSET WITH INTO CREATE
+%token DISTINCT ALL AS EXISTS NULLS FIRST LAST ASC DESC AT
 %token VALUE
 %right COUNT MIN MAX SUM AVG COALESCE NULLIF EXTRACT DATE_TRUNC
 %right ABS SIGN CAST UTCNOW
@@ -82,18 +83,20 @@ import (
 %token <expr> NUMBER ION
 %token <str> STRING
 
+%type <expr> table_at
 %type <expr> query expr datum datum_or_parens path_expression maybe_into
 %type <expr> where_expr having_expr case_optional_else parenthesized_expr
 %type <with> maybe_cte_bindings cte_bindings
 %type <pc> path_component
 %type <yesno> ascdesc nullslast maybe_distinct
 %type <str> identifier
+%type <strs> param_list
 %type <integer> literal_int
 %type <sel> select_stmt
 %type <bindings> group_expr binding_list
-%type <bind> value_binding
+%type <bind> value_binding table_binding
 %type <from> from_expr lhs_from_expr
-%type <values> value_list
+%type <values> value_list struct_fields
 %type <order> order_one_col
 %type <orders> order_expr order_cols
 %type <jk> join_kind
@@ -112,6 +115,26 @@ maybe_cte_bindings SELECT maybe_distinct binding_list maybe_into from_expr where
   yylex.(*scanner).into = $5
   yylex.(*scanner).result = &expr.Select{Distinct: $3, Columns: $4, From: $6, Where: $7, GroupBy: $8, Having: $9, OrderBy: $10, Limit: $11, Offset: $12};
 }
+| CREATE identifier identifier '(' ')' AS expr
+{
+  yylex.(*scanner).define($2, $3, nil, $7)
+}
+| CREATE identifier identifier '(' param_list ')' AS expr
+{
+  yylex.(*scanner).define($2, $3, $5, $8)
+}
+| CREATE identifier path_expression AS select_stmt
+{
+  yylex.(*scanner).defineView($2, $3, $5)
+}
+| CREATE identifier path_expression AS '(' select_stmt ')'
+{
+  yylex.(*scanner).defineView($2, $3, $6)
+}
+
+param_list:
+identifier { $$ = []string{$1} } |
+param_list ',' identifier { $$ = append($1, $3) }
 
 select_stmt:
 SELECT maybe_distinct binding_list from_expr where_expr group_expr having_expr order_expr limit_expr offset_expr
@@ -138,6 +161,25 @@ expr identifier { $$ = expr.Bind($1, $2) } |
 expr { $$ = expr.Bind($1, "") } |
 '*' { $$ = expr.Bind(expr.Star{}, "") }
 
+// match a value_binding or
+// <table> AT TIMESTAMP '...' [[AS] alias]
+table_binding:
+value_binding { $$ = $1 } |
+table_at { $$ = expr.Bind($1, "") } |
+table_at AS identifier { $$ = expr.Bind($1, $3) } |
+table_at identifier { $$ = expr.Bind($1, $2) }
+
+table_at:
+expr AT identifier STRING
+{
+  nod, ok := tableAt($1, $3, $4)
+  if !ok {
+    yylex.Error(__yyfmt__.Sprintf("bad AT %s %q", $3, $4))
+    return 1
+  }
+  $$ = nod
+}
+
 path_expression:
 identifier path_component { $$ = &expr.Path{First: $1, Rest: $2} }
 
@@ -222,6 +264,22 @@ datum_or_parens
 {
   $$ = expr.Sign($3)
 }
+| '{' '}'
+{
+  $$ = &expr.Struct{}
+}
+| '{' struct_fields '}'
+{
+  $$ = expr.CallOp(expr.MakeStruct, $2...)
+}
+| '[' ']'
+{
+  $$ = &expr.List{}
+}
+| '[' value_list ']'
+{
+  $$ = expr.CallOp(expr.MakeList, $2...)
+}
 | CASE case_limbs case_optional_else END
 {
   $$ = &expr.Case{Limbs: $2, Else: $3}
@@ -435,6 +493,12 @@ expr { $$ = []expr.Node{$1} } |
 '*' { $$ = []expr.Node{expr.Star{}} } |
 value_list ',' expr { $$ = append($1, $3) }
 
+// struct_fields is a list of
+// alternating field names and values
+struct_fields:
+STRING ':' expr { $$ = []expr.Node{expr.String($1), $3} } |
+struct_fields ',' STRING ':' expr { $$ = append($1, expr.String($3), $5) }
+
 join_kind:
 JOIN { $$ = expr.InnerJoin } |
 INNER JOIN { $$ = expr.InnerJoin } |
@@ -455,9 +519,9 @@ lhs_from_expr { $$ = $1 } |
 //   - add support for CROSS JOIN with ON and JOIN without ON
 //   (right now the grammar prohibits both of those)
 lhs_from_expr:
-FROM value_binding { $$ = &expr.Table{Binding: $2} } |
-lhs_from_expr cross_symbol value_binding { $$ = &expr.Join{Kind: expr.CrossJoin, Left: $1, Right: $3} } |
-lhs_from_expr join_kind value_binding ON expr EQ expr
+FROM table_binding { $$ = &expr.Table{Binding: $2} } |
+lhs_from_expr cross_symbol table_binding { $$ = &expr.Join{Kind: expr.CrossJoin, Left: $1, Right: $3} } |
+lhs_from_expr join_kind table_binding ON expr EQ expr
 { $$ = &expr.Join{Kind: $2, Left: $1, Right: $3, On: &expr.OnEquals{Left: $5, Right: $7} } }
 
 literal_int:
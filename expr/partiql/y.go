@@ -43,6 +43,7 @@ type yySymType struct {
 	limbs    []expr.CaseLimb
 	values   []expr.Node
 	orders   []expr.Order
+	strs     []string
 }
 
 const ERROR = 57346
@@ -59,71 +60,73 @@ const LIMIT = 57356
 const OFFSET = 57357
 const WITH = 57358
 const INTO = 57359
-const DISTINCT = 57360
-const ALL = 57361
-const AS = 57362
-const EXISTS = 57363
-const NULLS = 57364
-const FIRST = 57365
-const LAST = 57366
-const ASC = 57367
-const DESC = 57368
-const VALUE = 57369
-const COUNT = 57370
-const MIN = 57371
-const MAX = 57372
-const SUM = 57373
-const AVG = 57374
-const COALESCE = 57375
-const NULLIF = 57376
-const EXTRACT = 57377
-const DATE_TRUNC = 57378
-const ABS = 57379
-const SIGN = 57380
-const CAST = 57381
-const UTCNOW = 57382
-const DATE_ADD = 57383
-const DATE_DIFF = 57384
-const EARLIEST = 57385
-const LATEST = 57386
-const JOIN = 57387
-const LEFT = 57388
-const RIGHT = 57389
-const CROSS = 57390
-const INNER = 57391
-const OUTER = 57392
-const FULL = 57393
-const ON = 57394
-const ID = 57395
-const NULL = 57396
-const TRUE = 57397
-const FALSE = 57398
-const MISSING = 57399
-const OR = 57400
-const AND = 57401
-const NOT = 57402
-const BETWEEN = 57403
-const CASE = 57404
-const WHEN = 57405
-const THEN = 57406
-const ELSE = 57407
-const END = 57408
-const EQ = 57409
-const NE = 57410
-const LT = 57411
-const LE = 57412
-const GT = 57413
-const GE = 57414
-const ILIKE = 57415
-const LIKE = 57416
-const IN = 57417
-const IS = 57418
-const CONCAT = 57419
-const APPEND = 57420
-const NEGATION_PRECEDENCE = 57421
-const NUMBER = 57422
-const ION = 57423
-const STRING = 57424
+const CREATE = 57360
+const DISTINCT = 57361
+const ALL = 57362
+const AS = 57363
+const EXISTS = 57364
+const NULLS = 57365
+const FIRST = 57366
+const LAST = 57367
+const ASC = 57368
+const DESC = 57369
+const AT = 57370
+const VALUE = 57371
+const COUNT = 57372
+const MIN = 57373
+const MAX = 57374
+const SUM = 57375
+const AVG = 57376
+const COALESCE = 57377
+const NULLIF = 57378
+const EXTRACT = 57379
+const DATE_TRUNC = 57380
+const ABS = 57381
+const SIGN = 57382
+const CAST = 57383
+const UTCNOW = 57384
+const DATE_ADD = 57385
+const DATE_DIFF = 57386
+const EARLIEST = 57387
+const LATEST = 57388
+const JOIN = 57389
+const LEFT = 57390
+const RIGHT = 57391
+const CROSS = 57392
+const INNER = 57393
+const OUTER = 57394
+const FULL = 57395
+const ON = 57396
+const ID = 57397
+const NULL = 57398
+const TRUE = 57399
+const FALSE = 57400
+const MISSING = 57401
+const OR = 57402
+const AND = 57403
+const NOT = 57404
+const BETWEEN = 57405
+const CASE = 57406
+const WHEN = 57407
+const THEN = 57408
+const ELSE = 57409
+const END = 57410
+const EQ = 57411
+const NE = 57412
+const LT = 57413
+const LE = 57414
+const GT = 57415
+const GE = 57416
+const ILIKE = 57417
+const LIKE = 57418
+const IN = 57419
+const IS = 57420
+const CONCAT = 57421
+const APPEND = 57422
+const NEGATION_PRECEDENCE = 57423
+const NUMBER = 57424
+const ION = 57425
+const STRING = 57426
 
 var yyToknames = [...]string{
 	"$end",
@@ -143,6 +146,7 @@ var yyToknames = [...]string{
 	"OFFSET",
 	"WITH",
 	"INTO",
+	"CREATE",
 	"DISTINCT",
 	"ALL",
 	"AS",
@@ -152,6 +156,7 @@ var yyToknames = [...]string{
 	"LAST",
 	"ASC",
 	"DESC",
+	"AT",
 	"VALUE",
 	"COUNT",
 	"MIN",
@@ -222,6 +227,7 @@ var yyToknames = [...]string{
 	"NUMBER",
 	"ION",
 	"STRING",
+	"':'",
 }
 
 var yyStatenames = [...]string{}
@@ -231,365 +237,399 @@ const yyErrCode = 2
 const yyInitialStackSize = 16
 
 //line yacctab:1
-var yyExca = [...]int{
+var yyExca = [...]int16{
 	-1, 1,
 	1, -1,
 	-2, 0,
-	-1, 291,
-	65, 64,
-	66, 64,
-	68, 64,
-	69, 64,
-	75, 64,
-	76, 64,
-	77, 64,
-	78, 64,
-	79, 64,
-	80, 64,
-	-2, 101,
+	-1, 335,
+	67, 79,
+	68, 79,
+	70, 79,
+	71, 79,
+	77, 79,
+	78, 79,
+	79, 79,
+	80, 79,
+	81, 79,
+	82, 79,
+	-2, 118,
 }
 
 const yyPrivate = 57344
 
-const yyLast = 1618
-
-var yyAct = [...]int{
-	15, 285, 289, 278, 170, 270, 228, 256, 178, 13,
-	110, 97, 14, 9, 17, 193, 126, 125, 172, 36,
-	134, 157, 100, 209, 7, 88, 11, 60, 61, 62,
-	63, 64, 63, 64, 192, 56, 224, 171, 223, 102,
-	103, 264, 106, 105, 65, 66, 57, 77, 58, 59,
-	60, 61, 62, 63, 64, 214, 241, 240, 99, 118,
-	119, 120, 121, 122, 123, 124, 113, 227, 127, 128,
-	129, 130, 131, 132, 115, 116, 135, 136, 172, 144,
-	145, 146, 147, 148, 149, 150, 151, 152, 133, 156,
-	158, 160, 161, 115, 37, 109, 154, 225, 155, 158,
-	198, 18, 20, 21, 19, 22, 28, 29, 34, 33,
-	25, 26, 30, 35, 31, 32, 23, 24, 158, 169,
-	168, 175, 8, 41, 177, 189, 8, 41, 112, 167,
-	45, 43, 44, 46, 45, 43, 44, 46, 174, 191,
-	166, 39, 294, 27, 199, 173, 54, 98, 49, 53,
-	100, 219, 176, 214, 221, 210, 211, 214, 213, 38,
-	159, 190, 218, 42, 48, 47, 217, 42, 48, 47,
-	137, 140, 141, 139, 6, 53, 117, 138, 108, 101,
-	230, 222, 96, 248, 53, 226, 99, 194, 196, 197,
-	195, 95, 94, 231, 232, 184, 186, 187, 183, 185,
-	93, 188, 92, 114, 8, 182, 91, 242, 90, 89,
-	86, 85, 115, 245, 84, 246, 247, 83, 249, 250,
-	251, 252, 82, 81, 80, 79, 78, 51, 165, 164,
-	163, 162, 259, 237, 235, 253, 254, 255, 238, 236,
-	261, 260, 239, 234, 233, 262, 303, 304, 301, 50,
-	12, 10, 4, 286, 279, 257, 280, 258, 272, 271,
-	274, 229, 179, 220, 269, 112, 275, 276, 273, 107,
-	5, 180, 87, 181, 288, 111, 277, 300, 295, 3,
-	107, 290, 291, 2, 287, 284, 104, 153, 52, 40,
-	292, 293, 1, 0, 37, 290, 298, 299, 0, 0,
-	302, 18, 20, 21, 19, 22, 28, 29, 34, 33,
-	25, 26, 30, 35, 31, 32, 23, 24, 0, 0,
-	0, 0, 0, 0, 0, 0, 8, 41, 0, 0,
-	0, 0, 0, 0, 45, 43, 44, 46, 0, 0,
-	0, 39, 0, 27, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 38,
-	159, 143, 0, 0, 37, 0, 0, 42, 48, 47,
-	0, 18, 20, 21, 19, 22, 28, 29, 34, 33,
-	25, 26, 30, 35, 31, 32, 23, 24, 0, 0,
-	0, 0, 0, 0, 0, 0, 8, 41, 0, 0,
-	0, 0, 0, 0, 45, 43, 44, 46, 0, 0,
-	0, 39, 0, 27, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 38,
-	142, 0, 0, 37, 0, 0, 0, 42, 48, 47,
-	18, 20, 21, 19, 22, 28, 29, 34, 33, 25,
-	26, 30, 35, 31, 32, 23, 24, 0, 0, 0,
-	0, 0, 0, 0, 0, 8, 41, 0, 0, 0,
-	0, 0, 0, 45, 43, 44, 46, 0, 0, 0,
-	39, 0, 27, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 38, 16,
-	0, 0, 37, 0, 0, 0, 42, 48, 47, 18,
-	20, 21, 19, 22, 28, 29, 34, 33, 25, 26,
-	30, 35, 31, 32, 23, 24, 0, 0, 0, 0,
-	0, 0, 0, 0, 8, 41, 0, 0, 0, 0,
-	0, 0, 45, 43, 44, 46, 0, 0, 0, 39,
-	0, 27, 0, 0, 0, 0, 0, 107, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 38, 159, 0,
-	0, 37, 0, 0, 0, 42, 48, 47, 18, 20,
-	21, 19, 22, 28, 29, 34, 33, 25, 26, 30,
-	35, 31, 32, 23, 24, 0, 0, 0, 0, 0,
-	0, 0, 0, 8, 41, 0, 0, 0, 0, 0,
-	0, 45, 43, 44, 46, 0, 0, 0, 39, 0,
-	27, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 38, 0, 0, 0,
-	37, 0, 0, 0, 42, 48, 47, 18, 20, 21,
-	19, 22, 28, 29, 34, 33, 25, 26, 30, 35,
-	31, 32, 23, 24, 0, 0, 296, 297, 0, 0,
-	0, 0, 8, 41, 0, 0, 0, 0, 0, 0,
-	45, 43, 44, 46, 0, 0, 0, 39, 0, 27,
+const yyLast = 1820
+
+var yyAct = [...]int16{
+	19, 329, 333, 322, 127, 313, 266, 296, 17, 208,
+	134, 57, 18, 102, 21, 219, 11, 285, 179, 318,
+	242, 226, 150, 149, 129, 158, 8, 47, 74, 75,
+	245, 327, 106, 103, 51, 49, 50, 52, 225, 120,
+	87, 86, 98, 85, 84, 117, 118, 204, 121, 59,
+	78, 79, 80, 81, 82, 83, 76, 77, 68, 88,
+	69, 70, 71, 72, 73, 74, 75, 48, 54, 53,
+	142, 143, 144, 145, 146, 147, 148, 137, 100, 151,
+	152, 153, 154, 155, 156, 58, 128, 159, 160, 203,
+	168, 169, 170, 171, 172, 173, 174, 175, 176, 157,
+	130, 183, 133, 184, 181, 282, 307, 185, 103, 187,
+	188, 71, 72, 73, 74, 75, 103, 178, 181, 256,
+	42, 186, 177, 281, 7, 265, 10, 129, 13, 195,
+	15, 115, 141, 55, 59, 181, 249, 222, 202, 198,
+	67, 14, 103, 181, 56, 8, 180, 59, 123, 220,
+	227, 229, 230, 228, 136, 224, 196, 76, 77, 68,
+	88, 69, 70, 71, 72, 73, 74, 75, 232, 264,
+	58, 205, 206, 201, 200, 257, 231, 125, 207, 126,
+	243, 223, 244, 58, 246, 247, 139, 140, 161, 164,
+	165, 163, 197, 193, 338, 162, 65, 64, 254, 138,
+	259, 253, 252, 64, 9, 139, 132, 258, 122, 278,
+	268, 222, 222, 116, 114, 262, 263, 113, 112, 111,
+	110, 109, 108, 220, 220, 107, 269, 270, 214, 216,
+	217, 213, 215, 97, 218, 8, 64, 289, 212, 96,
+	283, 95, 94, 8, 301, 93, 92, 91, 90, 287,
+	89, 288, 62, 290, 291, 292, 293, 131, 192, 191,
+	190, 295, 189, 299, 300, 275, 273, 277, 272, 294,
+	276, 274, 271, 347, 348, 345, 260, 199, 61, 60,
+	16, 12, 5, 330, 3, 323, 304, 305, 297, 324,
+	298, 314, 267, 209, 255, 136, 122, 6, 315, 210,
+	317, 105, 211, 332, 312, 99, 135, 316, 124, 319,
+	320, 344, 339, 4, 2, 119, 182, 63, 46, 321,
+	1, 221, 261, 0, 0, 334, 335, 0, 331, 328,
+	122, 0, 0, 0, 336, 337, 0, 0, 0, 334,
+	342, 343, 279, 67, 346, 43, 139, 0, 0, 0,
+	0, 0, 0, 22, 24, 25, 23, 26, 34, 35,
+	40, 39, 29, 30, 36, 41, 37, 38, 27, 28,
+	0, 0, 0, 0, 0, 0, 0, 0, 8, 47,
+	0, 0, 32, 0, 31, 0, 51, 49, 50, 52,
+	0, 0, 0, 45, 0, 33, 0, 0, 0, 302,
+	0, 303, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 44, 104, 0, 43, 0, 0, 0, 0, 48,
+	54, 53, 22, 24, 25, 23, 26, 34, 35, 40,
+	39, 29, 30, 36, 41, 37, 38, 27, 28, 0,
+	0, 0, 0, 0, 0, 0, 0, 8, 47, 0,
+	194, 32, 0, 31, 0, 51, 49, 50, 52, 0,
+	0, 0, 45, 0, 33, 0, 0, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	55, 0, 0, 0, 0, 38, 76, 75, 0, 74,
-	73, 0, 0, 42, 48, 47, 67, 68, 69, 70,
-	71, 72, 65, 66, 57, 77, 58, 59, 60, 61,
-	62, 63, 64, 8, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 76, 75, 0, 74, 73,
-	0, 0, 0, 0, 0, 67, 68, 69, 70, 71,
-	72, 65, 66, 57, 77, 58, 59, 60, 61, 62,
-	63, 64, 283, 0, 0, 0, 0, 0, 0, 0,
-	0, 76, 75, 0, 74, 73, 0, 0, 0, 0,
-	0, 67, 68, 69, 70, 71, 72, 65, 66, 57,
-	77, 58, 59, 60, 61, 62, 63, 64, 282, 0,
-	0, 0, 0, 0, 0, 0, 0, 76, 75, 0,
-	74, 73, 0, 0, 0, 0, 0, 67, 68, 69,
-	70, 71, 72, 65, 66, 57, 77, 58, 59, 60,
-	61, 62, 63, 64, 268, 0, 0, 0, 0, 0,
-	0, 0, 0, 76, 75, 0, 74, 73, 0, 0,
-	0, 0, 0, 67, 68, 69, 70, 71, 72, 65,
-	66, 57, 77, 58, 59, 60, 61, 62, 63, 64,
-	267, 0, 0, 0, 0, 0, 0, 0, 0, 76,
-	75, 0, 74, 73, 0, 0, 0, 0, 0, 67,
-	68, 69, 70, 71, 72, 65, 66, 57, 77, 58,
-	59, 60, 61, 62, 63, 64, 266, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 76, 75, 0, 74,
-	73, 0, 0, 0, 0, 0, 67, 68, 69, 70,
-	71, 72, 65, 66, 57, 77, 58, 59, 60, 61,
-	62, 63, 64, 265, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 76, 75, 0, 74, 73, 0, 0,
-	0, 0, 0, 67, 68, 69, 70, 71, 72, 65,
-	66, 57, 77, 58, 59, 60, 61, 62, 63, 64,
-	263, 0, 0, 0, 0, 0, 0, 0, 0, 76,
-	75, 0, 74, 73, 0, 0, 0, 0, 0, 67,
-	68, 69, 70, 71, 72, 65, 66, 57, 77, 58,
-	59, 60, 61, 62, 63, 64, 76, 75, 0, 74,
-	73, 0, 0, 244, 0, 0, 67, 68, 69, 70,
-	71, 72, 65, 66, 57, 77, 58, 59, 60, 61,
-	62, 63, 64, 243, 216, 0, 0, 0, 0, 0,
-	0, 0, 76, 75, 0, 74, 73, 0, 0, 0,
-	0, 0, 67, 68, 69, 70, 71, 72, 65, 66,
-	57, 77, 58, 59, 60, 61, 62, 63, 64, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 76,
-	75, 0, 74, 73, 0, 0, 0, 0, 0, 67,
-	68, 69, 70, 71, 72, 65, 66, 57, 77, 58,
-	59, 60, 61, 62, 63, 64, 215, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 76, 75, 0, 74,
-	73, 0, 0, 0, 0, 0, 67, 68, 69, 70,
-	71, 72, 65, 66, 57, 77, 58, 59, 60, 61,
-	62, 63, 64, 76, 75, 0, 74, 73, 0, 0,
-	212, 0, 0, 67, 68, 69, 70, 71, 72, 65,
-	66, 57, 77, 58, 59, 60, 61, 62, 63, 64,
-	208, 0, 0, 0, 0, 0, 0, 0, 0, 76,
-	75, 0, 74, 73, 0, 0, 0, 0, 0, 67,
-	68, 69, 70, 71, 72, 65, 66, 57, 77, 58,
-	59, 60, 61, 62, 63, 64, 207, 0, 0, 0,
-	0, 0, 0, 0, 0, 76, 75, 0, 74, 73,
-	0, 0, 0, 0, 0, 67, 68, 69, 70, 71,
-	72, 65, 66, 57, 77, 58, 59, 60, 61, 62,
-	63, 64, 206, 0, 0, 0, 0, 0, 0, 0,
-	0, 76, 75, 0, 74, 73, 0, 0, 0, 0,
-	0, 67, 68, 69, 70, 71, 72, 65, 66, 57,
-	77, 58, 59, 60, 61, 62, 63, 64, 205, 0,
-	0, 0, 0, 0, 0, 0, 0, 76, 75, 0,
-	74, 73, 0, 0, 0, 0, 0, 67, 68, 69,
-	70, 71, 72, 65, 66, 57, 77, 58, 59, 60,
-	61, 62, 63, 64, 204, 0, 0, 0, 0, 0,
-	0, 0, 0, 76, 75, 0, 74, 73, 0, 0,
-	0, 0, 0, 67, 68, 69, 70, 71, 72, 65,
-	66, 57, 77, 58, 59, 60, 61, 62, 63, 64,
-	203, 0, 0, 0, 0, 0, 0, 0, 0, 76,
-	75, 0, 74, 73, 0, 0, 0, 0, 0, 67,
-	68, 69, 70, 71, 72, 65, 66, 57, 77, 58,
-	59, 60, 61, 62, 63, 64, 202, 0, 0, 0,
-	0, 0, 0, 0, 0, 76, 75, 0, 74, 73,
-	0, 0, 0, 0, 0, 67, 68, 69, 70, 71,
-	72, 65, 66, 57, 77, 58, 59, 60, 61, 62,
-	63, 64, 201, 0, 0, 0, 0, 0, 0, 0,
-	0, 76, 75, 0, 74, 73, 0, 0, 0, 0,
-	0, 67, 68, 69, 70, 71, 72, 65, 66, 57,
-	77, 58, 59, 60, 61, 62, 63, 64, 200, 0,
-	0, 0, 0, 0, 0, 0, 0, 76, 75, 0,
-	74, 73, 0, 0, 0, 0, 0, 67, 68, 69,
-	70, 71, 72, 65, 66, 57, 77, 58, 59, 60,
-	61, 62, 63, 64, 76, 75, 0, 74, 73, 0,
-	0, 0, 0, 0, 281, 68, 69, 70, 71, 72,
-	65, 66, 57, 77, 58, 59, 60, 61, 62, 63,
-	64, 76, 75, 0, 74, 73, 0, 0, 0, 0,
-	0, 67, 68, 69, 70, 71, 72, 65, 66, 57,
-	77, 58, 59, 60, 61, 62, 63, 64, 75, 0,
-	74, 73, 0, 0, 0, 0, 0, 67, 68, 69,
-	70, 71, 72, 65, 66, 57, 77, 58, 59, 60,
-	61, 62, 63, 64, 74, 73, 0, 0, 0, 0,
-	0, 67, 68, 69, 70, 71, 72, 65, 66, 57,
-	77, 58, 59, 60, 61, 62, 63, 64,
+	44, 104, 167, 0, 0, 43, 0, 0, 48, 54,
+	53, 0, 0, 22, 24, 25, 23, 26, 34, 35,
+	40, 39, 29, 30, 36, 41, 37, 38, 27, 28,
+	0, 0, 0, 0, 0, 0, 0, 0, 8, 47,
+	0, 0, 32, 0, 31, 0, 51, 49, 50, 52,
+	0, 0, 0, 45, 0, 33, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 44, 166, 0, 43, 0, 0, 0, 0, 48,
+	54, 53, 22, 24, 25, 23, 26, 34, 35, 40,
+	39, 29, 30, 36, 41, 37, 38, 27, 28, 0,
+	0, 0, 0, 0, 0, 0, 0, 8, 47, 0,
+	0, 32, 101, 31, 0, 51, 49, 50, 52, 0,
+	0, 0, 45, 0, 33, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	44, 104, 0, 43, 0, 0, 0, 0, 48, 54,
+	53, 22, 24, 25, 23, 26, 34, 35, 40, 39,
+	29, 30, 36, 41, 37, 38, 27, 28, 0, 0,
+	0, 0, 0, 0, 0, 0, 8, 47, 0, 0,
+	32, 0, 31, 0, 51, 49, 50, 52, 0, 0,
+	0, 45, 0, 33, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 44,
+	20, 0, 43, 0, 0, 0, 0, 48, 54, 53,
+	22, 24, 25, 23, 26, 34, 35, 40, 39, 29,
+	30, 36, 41, 37, 38, 27, 28, 0, 0, 0,
+	0, 0, 0, 0, 0, 8, 47, 0, 0, 32,
+	0, 31, 0, 51, 49, 50, 52, 0, 0, 0,
+	45, 0, 33, 0, 0, 0, 122, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 44, 104,
+	0, 43, 0, 0, 0, 0, 48, 54, 53, 22,
+	24, 25, 23, 26, 34, 35, 40, 39, 29, 30,
+	36, 41, 37, 38, 27, 28, 0, 0, 0, 0,
+	0, 0, 0, 0, 8, 47, 0, 0, 32, 0,
+	31, 0, 51, 49, 50, 52, 0, 0, 0, 45,
+	0, 33, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 44, 0, 0,
+	43, 0, 0, 0, 0, 48, 54, 53, 22, 24,
+	25, 23, 26, 34, 35, 40, 39, 29, 30, 36,
+	41, 37, 38, 27, 28, 0, 0, 0, 0, 0,
+	0, 66, 0, 8, 47, 0, 0, 32, 280, 31,
+	0, 51, 49, 50, 52, 0, 0, 0, 45, 0,
+	33, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 340, 341, 8, 44, 0, 0, 0,
+	0, 0, 0, 0, 48, 54, 53, 87, 86, 0,
+	85, 84, 0, 0, 0, 0, 0, 78, 79, 80,
+	81, 82, 83, 76, 77, 68, 88, 69, 70, 71,
+	72, 73, 74, 75, 87, 86, 66, 85, 84, 0,
+	0, 0, 0, 0, 78, 79, 80, 81, 82, 83,
+	76, 77, 68, 88, 69, 70, 71, 72, 73, 74,
+	75, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	8, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 87, 86, 0, 85, 84, 0, 0, 0,
+	0, 0, 78, 79, 80, 81, 82, 83, 76, 77,
+	68, 88, 69, 70, 71, 72, 73, 74, 75, 326,
+	0, 0, 0, 0, 0, 0, 0, 0, 87, 86,
+	0, 85, 84, 0, 0, 0, 0, 0, 78, 79,
+	80, 81, 82, 83, 76, 77, 68, 88, 69, 70,
+	71, 72, 73, 74, 75, 311, 0, 0, 0, 0,
+	0, 0, 0, 0, 87, 86, 0, 85, 84, 0,
+	0, 0, 0, 0, 78, 79, 80, 81, 82, 83,
+	76, 77, 68, 88, 69, 70, 71, 72, 73, 74,
+	75, 310, 0, 0, 0, 0, 0, 0, 0, 0,
+	87, 86, 0, 85, 84, 0, 0, 0, 0, 0,
+	78, 79, 80, 81, 82, 83, 76, 77, 68, 88,
+	69, 70, 71, 72, 73, 74, 75, 309, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 87, 86, 0,
+	85, 84, 0, 0, 0, 0, 0, 78, 79, 80,
+	81, 82, 83, 76, 77, 68, 88, 69, 70, 71,
+	72, 73, 74, 75, 308, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 87, 86, 0, 85, 84, 0,
+	0, 0, 0, 0, 78, 79, 80, 81, 82, 83,
+	76, 77, 68, 88, 69, 70, 71, 72, 73, 74,
+	75, 306, 0, 0, 0, 0, 0, 0, 0, 0,
+	87, 86, 0, 85, 84, 0, 0, 0, 0, 0,
+	78, 79, 80, 81, 82, 83, 76, 77, 68, 88,
+	69, 70, 71, 72, 73, 74, 75, 87, 86, 0,
+	85, 84, 0, 0, 286, 0, 0, 78, 79, 80,
+	81, 82, 83, 76, 77, 68, 88, 69, 70, 71,
+	72, 73, 74, 75, 284, 251, 0, 0, 0, 0,
+	0, 0, 0, 87, 86, 0, 85, 84, 0, 0,
+	0, 0, 0, 78, 79, 80, 81, 82, 83, 76,
+	77, 68, 88, 69, 70, 71, 72, 73, 74, 75,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 87, 86, 0, 85, 84, 0, 0, 0, 0,
+	0, 78, 79, 80, 81, 82, 83, 76, 77, 68,
+	88, 69, 70, 71, 72, 73, 74, 75, 250, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 87, 86,
+	0, 85, 84, 0, 0, 0, 0, 0, 78, 79,
+	80, 81, 82, 83, 76, 77, 68, 88, 69, 70,
+	71, 72, 73, 74, 75, 87, 86, 0, 85, 84,
+	0, 0, 248, 0, 0, 78, 79, 80, 81, 82,
+	83, 76, 77, 68, 88, 69, 70, 71, 72, 73,
+	74, 75, 241, 0, 0, 0, 0, 0, 0, 0,
+	0, 87, 86, 0, 85, 84, 0, 0, 0, 0,
+	0, 78, 79, 80, 81, 82, 83, 76, 77, 68,
+	88, 69, 70, 71, 72, 73, 74, 75, 240, 0,
+	0, 0, 0, 0, 0, 0, 0, 87, 86, 0,
+	85, 84, 0, 0, 0, 0, 0, 78, 79, 80,
+	81, 82, 83, 76, 77, 68, 88, 69, 70, 71,
+	72, 73, 74, 75, 239, 0, 0, 0, 0, 0,
+	0, 0, 0, 87, 86, 0, 85, 84, 0, 0,
+	0, 0, 0, 78, 79, 80, 81, 82, 83, 76,
+	77, 68, 88, 69, 70, 71, 72, 73, 74, 75,
+	238, 0, 0, 0, 0, 0, 0, 0, 0, 87,
+	86, 0, 85, 84, 0, 0, 0, 0, 0, 78,
+	79, 80, 81, 82, 83, 76, 77, 68, 88, 69,
+	70, 71, 72, 73, 74, 75, 237, 0, 0, 0,
+	0, 0, 0, 0, 0, 87, 86, 0, 85, 84,
+	0, 0, 0, 0, 0, 78, 79, 80, 81, 82,
+	83, 76, 77, 68, 88, 69, 70, 71, 72, 73,
+	74, 75, 236, 0, 0, 0, 0, 0, 0, 0,
+	0, 87, 86, 0, 85, 84, 0, 0, 0, 0,
+	0, 78, 79, 80, 81, 82, 83, 76, 77, 68,
+	88, 69, 70, 71, 72, 73, 74, 75, 235, 0,
+	0, 0, 0, 0, 0, 0, 0, 87, 86, 0,
+	85, 84, 0, 0, 0, 0, 0, 78, 79, 80,
+	81, 82, 83, 76, 77, 68, 88, 69, 70, 71,
+	72, 73, 74, 75, 234, 0, 0, 0, 0, 0,
+	0, 0, 0, 87, 86, 0, 85, 84, 0, 0,
+	0, 0, 0, 78, 79, 80, 81, 82, 83, 76,
+	77, 68, 88, 69, 70, 71, 72, 73, 74, 75,
+	233, 0, 0, 0, 0, 0, 0, 0, 0, 87,
+	86, 0, 85, 84, 0, 0, 0, 0, 0, 78,
+	79, 80, 81, 82, 83, 76, 77, 68, 88, 69,
+	70, 71, 72, 73, 74, 75, 87, 86, 0, 85,
+	84, 0, 0, 0, 0, 0, 325, 79, 80, 81,
+	82, 83, 76, 77, 68, 88, 69, 70, 71, 72,
+	73, 74, 75, 87, 86, 0, 85, 84, 0, 0,
+	0, 0, 0, 78, 79, 80, 81, 82, 83, 76,
+	77, 68, 88, 69, 70, 71, 72, 73, 74, 75,
+	86, 0, 85, 84, 0, 0, 0, 0, 0, 78,
+	79, 80, 81, 82, 83, 76, 77, 68, 88, 69,
+	70, 71, 72, 73, 74, 75, 85, 84, 0, 0,
+	0, 0, 0, 78, 79, 80, 81, 82, 83, 76,
+	77, 68, 88, 69, 70, 71, 72, 73, 74, 75,
 }
 
-var yyPact = [...]int{
-	236, -1000, 263, 119, 151, 233, 151, 230, -1000, 412,
-	-1000, 229, 173, 129, -1000, 680, -1000, -1000, 172, 171,
-	170, 169, 168, 163, 160, 157, 156, -46, 155, 154,
-	152, 148, 146, 138, 137, 128, 93, 125, 619, 619,
-	-1000, 550, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
-	124, 262, 257, 412, 151, 151, -1000, 122, 619, 619,
-	619, 619, 619, 619, 619, -79, -80, 619, 619, 619,
-	619, 619, 619, 69, -62, 619, 619, 109, 343, 619,
-	619, 619, 619, 619, 619, 619, 619, 25, 619, 481,
-	619, 619, 178, 177, 176, 175, 84, -1000, 73, 151,
-	-16, 262, -1000, 1526, 82, -1000, 1476, 233, 262, 68,
-	253, 150, 412, -1000, -1000, -35, -1000, 273, -60, -60,
-	-58, -58, -58, -1000, -1000, -1000, -1000, -37, -37, -37,
-	-37, -37, -37, -32, -81, 1526, 1502, -1000, 126, -1000,
-	-1000, -1000, 44, 619, 1422, 1386, 1350, 1314, 1278, 1242,
-	1206, 1170, 1134, -51, 619, 619, 1098, 102, 1476, -1000,
-	1071, 1034, 111, 107, 96, 255, -1000, -1000, 98, -35,
-	-20, -22, -1000, 41, -1000, 412, 11, -1000, 251, 619,
-	412, 412, -1000, 199, -1000, 198, 189, 188, 197, -1000,
-	1, 0, 69, -1000, -1000, -1000, -1000, -1000, -1000, 997,
-	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
-	961, 1476, 619, -1000, 619, 619, 130, 619, 619, 619,
-	619, -1000, -1000, -35, -35, -1000, 120, -1000, 242, 245,
-	1476, -1000, 180, -1000, -1000, -1000, 196, -1000, 195, -1000,
-	-1000, -1000, -1000, -1000, 619, 1476, 1476, 934, -15, 898,
-	861, 824, 788, -1000, -1000, 253, 248, 619, 412, 619,
-	-1000, -1000, 1476, -1000, -1000, 619, 619, -1000, -1000, 251,
-	240, 244, 1476, 94, 1449, 752, 716, 242, 238, -76,
-	619, 619, -1000, -1000, 248, -1000, -76, -1000, 87, -1000,
-	641, -37, 240, -1000, 619, 226, -1000, -1000, 238, -1000,
-	-1000, 223, -1000, -1000, -1000,
+var yyPact = [...]int16{
+	266, -1000, 290, 180, 147, 180, 262, 180, -1000, 180,
+	259, 601, -1000, 88, 258, 257, 196, 179, -1000, 915,
+	-1000, -1000, 194, 192, 191, 190, 189, 186, 185, 183,
+	177, -20, 532, -41, 169, 166, 165, 164, 163, 162,
+	161, 158, 75, 157, 808, 808, -1000, 739, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, 90, -1000, 180, 31,
+	201, 150, 289, 287, 601, 180, 180, -1000, 76, 808,
+	808, 808, 808, 808, 808, 808, -75, -76, 808, 808,
+	808, 808, 808, 808, -29, -59, 808, 808, 125, 463,
+	808, 808, 808, 808, 808, 808, 808, 808, -1000, 60,
+	-81, -1000, 86, 1676, -1000, 28, 808, 670, 808, 808,
+	207, 205, 204, 203, 135, 392, 289, -1000, 1726, 134,
+	-1000, 1676, 262, 256, 116, -1000, -10, 29, -13, -1000,
+	-1000, 289, 289, 120, 284, 181, 601, -1000, -1000, -10,
+	-1000, 323, 22, 22, -64, -64, -64, -1000, -1000, -1000,
+	-1000, 74, 74, 74, 74, 74, 74, -30, -77, 1726,
+	1702, -1000, 87, -1000, -1000, -1000, 118, 808, 1622, 1586,
+	1550, 1514, 1478, 1442, 1406, 1370, 1334, -1000, -78, 808,
+	-1000, 808, -46, 808, 808, 1298, 78, 1271, 1234, 145,
+	144, 141, 286, -1000, -1000, 61, 117, -1000, 601, 808,
+	255, 180, -1000, -10, -10, 111, 67, -1000, 282, 808,
+	601, 601, -1000, 225, -1000, 221, 219, 218, 220, -1000,
+	-1000, 188, 840, 65, 47, -29, -1000, -1000, -1000, -1000,
+	-1000, -1000, 1196, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -82, 1676, 1676, -1000, 1160, 1676, 808, -1000,
+	808, 182, 808, 808, 808, 808, -1000, -1000, 146, 1676,
+	808, -1000, -1000, -1000, -1000, -1000, 275, 278, 1676, -1000,
+	209, -1000, -1000, -1000, 217, -1000, 197, -1000, 180, -1000,
+	180, -1000, -1000, -1000, -1000, 808, 808, 1676, 1133, 48,
+	1097, 1060, 1023, 987, 284, 1676, 280, 808, 601, 808,
+	-1000, -1000, -1000, -79, 1676, 1676, -1000, -1000, 808, 808,
+	-1000, -1000, 282, 271, 277, 1676, 140, 1649, -1000, 951,
+	-27, 275, 268, -72, 808, 808, -1000, -1000, 280, -1000,
+	-72, -1000, 137, -1000, 867, 74, 271, -1000, 808, 252,
+	-1000, -1000, 268, -1000, -1000, 249, -1000, -1000, -1000,
 }
 
-var yyPgo = [...]int{
-	0, 292, 0, 289, 14, 148, 288, 8, 7, 287,
-	286, 283, 279, 11, 278, 277, 13, 19, 4, 43,
-	6, 9, 12, 10, 275, 21, 2, 5, 274, 273,
-	3, 1, 272, 271,
+var yyPgo = [...]int16{
+	0, 321, 320, 0, 318, 14, 133, 317, 9, 7,
+	316, 315, 314, 313, 11, 312, 311, 16, 120, 308,
+	4, 39, 6, 8, 12, 15, 10, 306, 13, 305,
+	2, 5, 303, 302, 3, 1, 301, 299,
 }
 
-var yyR1 = [...]int{
-	0, 1, 19, 6, 6, 11, 11, 12, 12, 22,
-	22, 22, 22, 5, 3, 3, 3, 3, 3, 3,
-	3, 3, 4, 4, 10, 10, 16, 16, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 21, 21, 25, 25, 25, 29, 29,
-	29, 29, 29, 29, 29, 33, 33, 23, 23, 24,
-	24, 24, 18, 13, 13, 13, 13, 17, 9, 9,
-	32, 32, 7, 7, 8, 8, 20, 20, 15, 15,
-	15, 14, 14, 14, 26, 28, 28, 27, 27, 30,
-	30, 31, 31,
+var yyR1 = [...]int8{
+	0, 2, 2, 2, 2, 2, 19, 19, 21, 7,
+	7, 12, 12, 13, 13, 24, 24, 24, 24, 25,
+	25, 25, 25, 1, 6, 4, 4, 4, 4, 4,
+	4, 4, 4, 5, 5, 11, 11, 17, 17, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 23, 23,
+	28, 28, 28, 29, 29, 33, 33, 33, 33, 33,
+	33, 33, 37, 37, 26, 26, 27, 27, 27, 20,
+	14, 14, 14, 14, 18, 10, 10, 36, 36, 8,
+	8, 9, 9, 22, 22, 16, 16, 16, 15, 15,
+	15, 30, 32, 32, 31, 31, 34, 34, 35, 35,
 }
 
-var yyR2 = [...]int{
-	0, 12, 10, 2, 0, 1, 0, 6, 7, 3,
-	2, 1, 1, 2, 1, 1, 1, 1, 1, 1,
-	1, 1, 1, 3, 1, 1, 1, 0, 1, 4,
-	5, 4, 4, 4, 4, 4, 4, 4, 4, 4,
-	4, 4, 6, 6, 8, 8, 6, 6, 3, 3,
-	4, 5, 5, 4, 3, 3, 3, 3, 3, 3,
-	3, 2, 3, 3, 3, 3, 3, 3, 3, 3,
-	5, 4, 2, 3, 3, 3, 4, 3, 4, 3,
-	4, 3, 4, 1, 3, 1, 1, 3, 1, 2,
-	2, 3, 2, 3, 2, 1, 2, 1, 0, 2,
-	3, 7, 1, 0, 3, 4, 4, 1, 0, 2,
-	4, 5, 0, 2, 0, 2, 0, 3, 0, 2,
-	2, 0, 1, 1, 3, 3, 1, 0, 3, 0,
-	2, 0, 2,
+var yyR2 = [...]int8{
+	0, 12, 7, 8, 5, 7, 1, 3, 10, 2,
+	0, 1, 0, 6, 7, 3, 2, 1, 1, 1,
+	1, 3, 2, 4, 2, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 3, 1, 1, 1, 0, 1,
+	4, 5, 4, 4, 4, 4, 4, 4, 4, 4,
+	4, 2, 3, 2, 3, 4, 4, 6, 6, 8,
+	8, 6, 6, 3, 3, 4, 5, 5, 4, 3,
+	3, 3, 3, 3, 3, 3, 2, 3, 3, 3,
+	3, 3, 3, 3, 3, 5, 4, 2, 3, 3,
+	3, 4, 3, 4, 3, 4, 3, 4, 1, 3,
+	1, 1, 3, 3, 5, 1, 2, 2, 3, 2,
+	3, 2, 1, 2, 1, 0, 2, 3, 7, 1,
+	0, 3, 4, 4, 1, 0, 2, 4, 5, 0,
+	2, 0, 2, 0, 3, 0, 2, 2, 0, 1,
+	1, 3, 3, 1, 0, 3, 0, 2, 0, 2,
 }
 
-var yyChk = [...]int{
-	-1000, -1, -11, -12, 16, 7, 55, -17, 53, -16,
-	18, -17, 20, -21, -22, -2, 87, -4, 28, 31,
-	29, 30, 32, 43, 44, 37, 38, 70, 33, 34,
-	39, 41, 42, 36, 35, 40, -17, 21, 86, 68,
-	-3, 54, 94, 62, 63, 61, 64, 96, 95, -5,
-	20, 54, -6, 55, 17, 20, -17, 83, 85, 86,
-	87, 88, 89, 90, 91, 81, 82, 75, 76, 77,
-	78, 79, 80, 69, 68, 66, 65, 84, 54, 54,
-	54, 54, 54, 54, 54, 54, 54, -32, 71, 54,
-	54, 54, 54, 54, 54, 54, 54, -13, 54, 93,
-	57, 54, -2, -2, -10, -19, -2, 7, 54, -19,
-	-23, -24, 8, -22, -5, -17, -17, 54, -2, -2,
-	-2, -2, -2, -2, -2, 96, 96, -2, -2, -2,
-	-2, -2, -2, -4, 82, -2, -2, 61, 68, 64,
-	62, 63, 87, 18, -2, -2, -2, -2, -2, -2,
-	-2, -2, -2, -9, 71, 73, -2, -25, -2, 87,
-	-2, -2, 53, 53, 53, 53, 56, 56, -25, -17,
-	-18, 53, 94, -19, 56, -16, -19, 56, -7, 9,
-	-33, -29, 55, 48, 45, 49, 46, 47, 51, -22,
-	-19, -25, 66, 96, 61, 64, 62, 63, 56, -2,
-	56, 56, 56, 56, 56, 56, 56, 56, 56, 74,
-	-2, -2, 72, 56, 55, 55, 20, 55, 55, 55,
-	8, 56, -13, 58, 58, 56, -21, 56, -20, 10,
-	-2, -22, -22, 45, 45, 45, 50, 45, 50, 45,
-	56, 56, -4, 56, 72, -2, -2, -2, 53, -2,
-	-2, -2, -2, -13, -13, -23, -8, 13, 12, 52,
-	45, 45, -2, 56, 56, 55, 55, 56, 56, -7,
-	-27, 11, -2, -21, -2, -2, -2, -20, -30, 14,
-	12, 75, 56, 56, -8, -31, 15, -18, -28, -26,
-	-2, -2, -27, -18, 55, -14, 25, 26, -30, -26,
-	-15, 22, -31, 23, 24,
+var yyChk = [...]int16{
+	-1000, -2, -12, 18, -13, 16, 7, -18, 55, 57,
+	-18, -17, 19, -18, -6, -18, 21, -23, -24, -3,
+	89, -5, 30, 33, 31, 32, 34, 45, 46, 39,
+	40, 61, 59, 72, 35, 36, 41, 43, 44, 38,
+	37, 42, -18, 22, 88, 70, -4, 56, 96, 64,
+	65, 63, 66, 98, 97, -6, 56, -14, 95, 59,
+	21, 21, 56, -7, 57, 17, 21, -18, 85, 87,
+	88, 89, 90, 91, 92, 93, 83, 84, 77, 78,
+	79, 80, 81, 82, 71, 70, 68, 67, 86, 56,
+	56, 56, 56, 56, 56, 56, 56, 56, 62, -29,
+	98, 60, -28, -3, 89, -36, 73, 56, 56, 56,
+	56, 56, 56, 56, 56, 56, 56, -3, -3, -11,
+	-21, -3, 7, 58, -19, -18, -18, -20, 55, 96,
+	-21, 56, 56, -21, -26, -27, 8, -24, -6, -18,
+	-18, 56, -3, -3, -3, -3, -3, -3, -3, 98,
+	98, -3, -3, -3, -3, -3, -3, -5, 84, -3,
+	-3, 63, 70, 66, 64, 65, 89, 19, -3, -3,
+	-3, -3, -3, -3, -3, -3, -3, 62, 57, 99,
+	60, 57, -10, 73, 75, -3, -28, -3, -3, 55,
+	55, 55, 55, 58, 58, -28, -21, 58, -17, 21,
+	58, 57, -14, 60, 60, -21, -21, 58, -8, 9,
+	-37, -33, 57, 50, 47, 51, 48, 49, 53, -25,
+	-24, -1, -3, -21, -28, 68, 98, 63, 66, 64,
+	65, 58, -3, 58, 58, 58, 58, 58, 58, 58,
+	58, 58, 98, -3, -3, 76, -3, -3, 74, 58,
+	57, 21, 57, 57, 57, 8, 58, 58, -23, -3,
+	21, -18, -14, -14, 58, 58, -22, 10, -3, -25,
+	-25, 47, 47, 47, 52, 47, 52, 47, 21, -18,
+	28, 58, 58, -5, 58, 99, 74, -3, -3, 55,
+	-3, -3, -3, -3, -26, -3, -9, 13, 12, 54,
+	47, 47, -18, -18, -3, -3, 58, 58, 57, 57,
+	58, 58, -8, -31, 11, -3, -23, -3, 98, -3,
+	-3, -22, -34, 14, 12, 77, 58, 58, -9, -35,
+	15, -20, -32, -30, -3, -3, -31, -20, 57, -15,
+	26, 27, -34, -30, -16, 23, -35, 24, 25,
 }
 
-var yyDef = [...]int{
-	6, -2, 0, 5, 0, 27, 0, 0, 107, 0,
-	26, 0, 0, 4, 83, 11, 12, 28, 0, 0,
+var yyDef = [...]int16{
+	12, -2, 0, 0, 11, 0, 38, 0, 124, 0,
+	0, 0, 37, 120, 0, 0, 0, 10, 98, 17,
+	18, 39, 0, 0, 0, 0, 0, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 103, 0, 0, 0,
-	22, 0, 14, 15, 16, 17, 18, 19, 20, 21,
-	0, 0, 98, 0, 0, 0, 10, 0, 0, 0,
+	0, 0, 120, 0, 0, 0, 33, 0, 25, 26,
+	27, 28, 29, 30, 31, 32, 0, 24, 0, 0,
+	0, 0, 0, 115, 0, 0, 0, 16, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 108, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 13, 0, 0,
-	0, 0, 61, 72, 0, 24, 25, 27, 0, 0,
-	112, 97, 0, 84, 3, 103, 9, 0, 54, 55,
-	56, 57, 58, 59, 60, 62, 63, 64, 65, 66,
-	67, 68, 69, 0, 0, 73, 74, 75, 0, 77,
-	79, 81, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 85, 86,
-	0, 0, 0, 0, 0, 0, 48, 49, 0, 103,
-	0, 0, 102, 0, 23, 0, 0, 7, 116, 0,
-	0, 0, 95, 0, 88, 0, 0, 0, 0, 99,
-	0, 0, 0, 71, 76, 78, 80, 82, 29, 0,
-	31, 32, 33, 34, 35, 36, 37, 38, 39, 40,
-	0, 109, 0, 41, 0, 0, 0, 0, 0, 0,
-	0, 50, 104, 103, 103, 53, 98, 8, 114, 0,
-	113, 100, 0, 96, 89, 90, 0, 92, 0, 94,
-	51, 52, 70, 30, 0, 110, 87, 0, 0, 0,
-	0, 0, 0, 105, 106, 112, 127, 0, 0, 0,
-	91, 93, 111, 42, 43, 0, 0, 46, 47, 116,
-	129, 0, 115, 117, 0, 0, 0, 114, 131, 0,
-	0, 0, 44, 45, 127, 1, 0, 130, 128, 126,
-	121, -2, 129, 132, 0, 118, 122, 123, 131, 125,
-	124, 0, 2, 119, 120,
+	0, 0, 0, 0, 0, 0, 0, 0, 51, 0,
+	0, 53, 0, 100, 101, 125, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 76, 87, 0,
+	35, 36, 38, 0, 0, 6, 120, 0, 0, 119,
+	4, 0, 0, 0, 129, 114, 0, 99, 9, 120,
+	15, 0, 69, 70, 71, 72, 73, 74, 75, 77,
+	78, 79, 80, 81, 82, 83, 84, 0, 0, 88,
+	89, 90, 0, 92, 94, 96, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 52, 0, 0,
+	54, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 63, 64, 0, 0, 34, 0, 0,
+	0, 0, 121, 120, 120, 0, 0, 13, 133, 0,
+	0, 0, 112, 0, 105, 0, 0, 0, 0, 116,
+	19, 20, 17, 0, 0, 0, 86, 91, 93, 95,
+	97, 40, 0, 42, 43, 44, 45, 46, 47, 48,
+	49, 50, 0, 103, 102, 55, 0, 126, 0, 56,
+	0, 0, 0, 0, 0, 0, 65, 68, 115, 2,
+	0, 7, 122, 123, 5, 14, 131, 0, 130, 117,
+	0, 113, 106, 107, 0, 109, 0, 111, 0, 22,
+	0, 66, 67, 85, 41, 0, 0, 127, 0, 0,
+	0, 0, 0, 0, 129, 3, 144, 0, 0, 0,
+	108, 110, 21, 0, 104, 128, 57, 58, 0, 0,
+	61, 62, 133, 146, 0, 132, 134, 0, 23, 0,
+	0, 131, 148, 0, 0, 0, 59, 60, 144, 1,
+	0, 147, 145, 143, 138, -2, 146, 149, 0, 135,
+	139, 140, 148, 142, 141, 0, 8, 136, 137,
 }
 
-var yyTok1 = [...]int{
+var yyTok1 = [...]int8{
 	1, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 67, 3, 3, 3, 89, 3, 3,
-	54, 56, 87, 85, 55, 86, 93, 88, 3, 3,
-	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 69, 3, 3, 3, 91, 3, 3,
+	56, 58, 89, 87, 57, 88, 95, 90, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 99, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 57, 3, 58, 3, 3, 3, 3, 3, 3,
+	3, 59, 3, 60, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 59, 3, 60,
+	3, 3, 3, 61, 3, 62,
 }
 
-var yyTok2 = [...]int{
+var yyTok2 = [...]int8{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
 	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
 	22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
 	32, 33, 34, 35, 36, 37, 38, 39, 40, 41,
 	42, 43, 44, 45, 46, 47, 48, 49, 50, 51,
-	52, 53, 61, 62, 63, 64, 65, 66, 68, 69,
+	52, 53, 54, 55, 63, 64, 65, 66, 67, 68,
 	70, 71, 72, 73, 74, 75, 76, 77, 78, 79,
-	80, 81, 82, 83, 84, 90, 91, 92, 94, 95,
-	96,
+	80, 81, 82, 83, 84, 85, 86, 92, 93, 94,
+	96, 97, 98,
 }
 
-var yyTok3 = [...]int{
+var yyTok3 = [...]int8{
 	0,
 }
 
@@ -671,9 +711,9 @@ func yyErrorMessage(state, lookAhead int) string {
 	expected := make([]int, 0, 4)
 
 	// Look for shiftable tokens.
-	base := yyPact[state]
+	base := int(yyPact[state])
 	for tok := TOKSTART; tok-1 < len(yyToknames); tok++ {
-		if n := base + tok; n >= 0 && n < yyLast && yyChk[yyAct[n]] == tok {
+		if n := base + tok; n >= 0 && n < yyLast && int(yyChk[int(yyAct[n])]) == tok {
 			if len(expected) == cap(expected) {
 				return res
 			}
@@ -683,13 +723,13 @@ func yyErrorMessage(state, lookAhead int) string {
 
 	if yyDef[state] == -2 {
 		i := 0
-		for yyExca[i] != -1 || yyExca[i+1] != state {
+		for yyExca[i] != -1 || int(yyExca[i+1]) != state {
 			i += 2
 		}
 
 		// Look for tokens that we accept or reduce.
 		for i += 2; yyExca[i] >= 0; i += 2 {
-			tok := yyExca[i]
+			tok := int(yyExca[i])
 			if tok < TOKSTART || yyExca[i+1] == 0 {
 				continue
 			}
@@ -720,30 +760,30 @@ func yylex1(lex yyLexer, lval *yySymType) (char, token int) {
 	token = 0
 	char = lex.Lex(lval)
 	if char <= 0 {
-		token = yyTok1[0]
+		token = int(yyTok1[0])
 		goto out
 	}
 	if char < len(yyTok1) {
-		token = yyTok1[char]
+		token = int(yyTok1[char])
 		goto out
 	}
 	if char >= yyPrivate {
 		if char < yyPrivate+len(yyTok2) {
-			token = yyTok2[char-yyPrivate]
+			token = int(yyTok2[char-yyPrivate])
 			goto out
 		}
 	}
 	for i := 0; i < len(yyTok3); i += 2 {
-		token = yyTok3[i+0]
+		token = int(yyTok3[i+0])
 		if token == char {
-			token = yyTok3[i+1]
+			token = int(yyTok3[i+1])
 			goto out
 		}
 	}
 
 out:
 	if token == 0 {
-		token = yyTok2[1] /* unknown char */
+		token = int(yyTok2[1]) /* unknown char */
 	}
 	if yyDebug >= 3 {
 		__yyfmt__.Printf("lex %s(%d)\n", yyTokname(token), uint(char))
@@ -798,7 +838,7 @@ yystack:
 	yyS[yyp].yys = yystate
 
 yynewstate:
-	yyn = yyPact[yystate]
+	yyn = int(yyPact[yystate])
 	if yyn <= yyFlag {
 		goto yydefault /* simple state */
 	}
@@ -809,8 +849,8 @@ yynewstate:
 	if yyn < 0 || yyn >= yyLast {
 		goto yydefault
 	}
-	yyn = yyAct[yyn]
-	if yyChk[yyn] == yytoken { /* valid shift */
+	yyn = int(yyAct[yyn])
+	if int(yyChk[yyn]) == yytoken { /* valid shift */
 		yyrcvr.char = -1
 		yytoken = -1
 		yyVAL = yyrcvr.lval
@@ -823,7 +863,7 @@ yynewstate:
 
 yydefault:
 	/* default state action */
-	yyn = yyDef[yystate]
+	yyn = int(yyDef[yystate])
 	if yyn == -2 {
 		if yyrcvr.char < 0 {
 			yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
@@ -832,18 +872,18 @@ yydefault:
 		/* look through exception table */
 		xi := 0
 		for {
-			if yyExca[xi+0] == -1 && yyExca[xi+1] == yystate {
+			if yyExca[xi+0] == -1 && int(yyExca[xi+1]) == yystate {
 				break
 			}
 			xi += 2
 		}
 		for xi += 2; ; xi += 2 {
-			yyn = yyExca[xi+0]
+			yyn = int(yyExca[xi+0])
 			if yyn < 0 || yyn == yytoken {
 				break
 			}
 		}
-		yyn = yyExca[xi+1]
+		yyn = int(yyExca[xi+1])
 		if yyn < 0 {
 			goto ret0
 		}
@@ -865,10 +905,10 @@ yydefault:
 
 			/* find a state where "error" is a legal shift action */
 			for yyp >= 0 {
-				yyn = yyPact[yyS[yyp].yys] + yyErrCode
+				yyn = int(yyPact[yyS[yyp].yys]) + yyErrCode
 				if yyn >= 0 && yyn < yyLast {
-					yystate = yyAct[yyn] /* simulate a shift of "error" */
-					if yyChk[yystate] == yyErrCode {
+					yystate = int(yyAct[yyn]) /* simulate a shift of "error" */
+					if int(yyChk[yystate]) == yyErrCode {
 						goto yystack
 					}
 				}
@@ -904,7 +944,7 @@ yydefault:
 	yypt := yyp
 	_ = yypt // guard against "declared and not used"
 
-	yyp -= yyR2[yyn]
+	yyp -= int(yyR2[yyn])
 	// yyp is now the index of $0. Perform the default action. Iff the
 	// reduced production is ε, $1 is possibly out of range.
 	if yyp+1 >= len(yyS) {
@@ -915,16 +955,16 @@ yydefault:
 	yyVAL = yyS[yyp+1]
 
 	/* consult goto table to find next state */
-	yyn = yyR1[yyn]
-	yyg := yyPgo[yyn]
+	yyn = int(yyR1[yyn])
+	yyg := int(yyPgo[yyn])
 	yyj := yyg + yyS[yyp].yys + 1
 
 	if yyj >= yyLast {
-		yystate = yyAct[yyg]
+		yystate = int(yyAct[yyg])
 	} else {
-		yystate = yyAct[yyj]
-		if yyChk[yystate] != -yyn {
-			yystate = yyAct[yyg]
+		yystate = int(yyAct[yyj])
+		if int(yyChk[yystate]) != -yyn {
+			yystate = int(yyAct[yyg])
 		}
 	}
 	// dummy call; replaced with literal code
@@ -932,261 +972,356 @@ yydefault:
 
 	case 1:
 		yyDollar = yyS[yypt-12 : yypt+1]
-//line partiql.y:110
+//line partiql.y:113
 		{
 			yylex.(*scanner).with = yyDollar[1].with
 			yylex.(*scanner).into = yyDollar[5].expr
 			yylex.(*scanner).result = &expr.Select{Distinct: yyDollar[3].yesno, Columns: yyDollar[4].bindings, From: yyDollar[6].from, Where: yyDollar[7].expr, GroupBy: yyDollar[8].bindings, Having: yyDollar[9].expr, OrderBy: yyDollar[10].orders, Limit: yyDollar[11].exprint, Offset: yyDollar[12].exprint}
 		}
 	case 2:
+		yyDollar = yyS[yypt-7 : yypt+1]
+//line partiql.y:119
+		{
+			yylex.(*scanner).define(yyDollar[2].str, yyDollar[3].str, nil, yyDollar[7].expr)
+		}
+	case 3:
+		yyDollar = yyS[yypt-8 : yypt+1]
+//line partiql.y:123
+		{
+			yylex.(*scanner).define(yyDollar[2].str, yyDollar[3].str, yyDollar[5].strs, yyDollar[8].expr)
+		}
+	case 4:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line partiql.y:127
+		{
+			yylex.(*scanner).defineView(yyDollar[2].str, yyDollar[3].expr, yyDollar[5].sel)
+		}
+	case 5:
+		yyDollar = yyS[yypt-7 : yypt+1]
+//line partiql.y:131
+		{
+			yylex.(*scanner).defineView(yyDollar[2].str, yyDollar[3].expr, yyDollar[6].sel)
+		}
+	case 6:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:136
+		{
+			yyVAL.strs = []string{yyDollar[1].str}
+		}
+	case 7:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line partiql.y:137
+		{
+			yyVAL.strs = append(yyDollar[1].strs, yyDollar[3].str)
+		}
+	case 8:
 		yyDollar = yyS[yypt-10 : yypt+1]
-//line partiql.y:118
+//line partiql.y:141
 		{
 			yyVAL.sel = &expr.Select{Distinct: yyDollar[2].yesno, Columns: yyDollar[3].bindings, From: yyDollar[4].from, Where: yyDollar[5].expr, GroupBy: yyDollar[6].bindings, Having: yyDollar[7].expr, OrderBy: yyDollar[8].orders, Limit: yyDollar[9].exprint, Offset: yyDollar[10].exprint}
 		}
-	case 3:
+	case 9:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:123
+//line partiql.y:146
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 4:
+	case 10:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:123
+//line partiql.y:146
 		{
 			yyVAL.expr = nil
 		}
-	case 5:
+	case 11:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:126
+//line partiql.y:149
 		{
 			yyVAL.with = yyDollar[1].with
 		}
-	case 6:
+	case 12:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:126
+//line partiql.y:149
 		{
 			yyVAL.with = nil
 		}
-	case 7:
+	case 13:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:129
+//line partiql.y:152
 		{
 			yyVAL.with = []expr.CTE{{yyDollar[2].str, yyDollar[5].sel}}
 		}
-	case 8:
+	case 14:
 		yyDollar = yyS[yypt-7 : yypt+1]
-//line partiql.y:130
+//line partiql.y:153
 		{
 			yyVAL.with = append(yyDollar[1].with, expr.CTE{yyDollar[3].str, yyDollar[6].sel})
 		}
-	case 9:
+	case 15:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:136
+//line partiql.y:159
 		{
 			yyVAL.bind = expr.Bind(yyDollar[1].expr, yyDollar[3].str)
 		}
-	case 10:
+	case 16:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:137
+//line partiql.y:160
 		{
 			yyVAL.bind = expr.Bind(yyDollar[1].expr, yyDollar[2].str)
 		}
-	case 11:
+	case 17:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:138
+//line partiql.y:161
 		{
 			yyVAL.bind = expr.Bind(yyDollar[1].expr, "")
 		}
-	case 12:
+	case 18:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:139
+//line partiql.y:162
 		{
 			yyVAL.bind = expr.Bind(expr.Star{}, "")
 		}
-	case 13:
+	case 19:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:167
+		{
+			yyVAL.bind = yyDollar[1].bind
+		}
+	case 20:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line partiql.y:168
+		{
+			yyVAL.bind = expr.Bind(yyDollar[1].expr, "")
+		}
+	case 21:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line partiql.y:169
+		{
+			yyVAL.bind = expr.Bind(yyDollar[1].expr, yyDollar[3].str)
+		}
+	case 22:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line partiql.y:170
+		{
+			yyVAL.bind = expr.Bind(yyDollar[1].expr, yyDollar[2].str)
+		}
+	case 23:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line partiql.y:174
+		{
+			nod, ok := tableAt(yyDollar[1].expr, yyDollar[3].str, yyDollar[4].str)
+			if !ok {
+				yylex.Error(__yyfmt__.Sprintf("bad AT %s %q", yyDollar[3].str, yyDollar[4].str))
+				return 1
+			}
+			yyVAL.expr = nod
+		}
+	case 24:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:142
+//line partiql.y:184
 		{
 			yyVAL.expr = &expr.Path{First: yyDollar[1].str, Rest: yyDollar[2].pc}
 		}
-	case 14:
+	case 25:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:146
+//line partiql.y:188
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 15:
+	case 26:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:147
+//line partiql.y:189
 		{
 			yyVAL.expr = expr.Bool(true)
 		}
-	case 16:
+	case 27:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:148
+//line partiql.y:190
 		{
 			yyVAL.expr = expr.Bool(false)
 		}
-	case 17:
+	case 28:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:149
+//line partiql.y:191
 		{
 			yyVAL.expr = expr.Null{}
 		}
-	case 18:
+	case 29:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:150
+//line partiql.y:192
 		{
 			yyVAL.expr = expr.Missing{}
 		}
-	case 19:
+	case 30:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:151
+//line partiql.y:193
 		{
 			yyVAL.expr = expr.String(yyDollar[1].str)
 		}
-	case 20:
+	case 31:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:152
+//line partiql.y:194
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 21:
+	case 32:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:153
+//line partiql.y:195
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 22:
+	case 33:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:165
+//line partiql.y:207
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 23:
+	case 34:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:166
+//line partiql.y:208
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 24:
+	case 35:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:169
+//line partiql.y:211
 		{
 			yyVAL.expr = yyDollar[1].sel
 		}
-	case 25:
+	case 36:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:170
+//line partiql.y:212
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 26:
+	case 37:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:173
+//line partiql.y:215
 		{
 			yyVAL.yesno = true
 		}
-	case 27:
+	case 38:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:173
+//line partiql.y:215
 		{
 			yyVAL.yesno = false
 		}
-	case 28:
+	case 39:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:178
+//line partiql.y:220
 		{
 			yyVAL.expr = yyDollar[1].expr
 		}
-	case 29:
+	case 40:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:182
+//line partiql.y:224
 		{
 			yyVAL.expr = expr.Count(expr.Star{})
 		}
-	case 30:
+	case 41:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:186
+//line partiql.y:228
 		{
 			yyVAL.expr = expr.CountDistinct(yyDollar[4].expr)
 		}
-	case 31:
+	case 42:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:190
+//line partiql.y:232
 		{
 			yyVAL.expr = expr.Count(yyDollar[3].expr)
 		}
-	case 32:
+	case 43:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:194
+//line partiql.y:236
 		{
 			yyVAL.expr = expr.Sum(yyDollar[3].expr)
 		}
-	case 33:
+	case 44:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:198
+//line partiql.y:240
 		{
 			yyVAL.expr = expr.Min(yyDollar[3].expr)
 		}
-	case 34:
+	case 45:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:202
+//line partiql.y:244
 		{
 			yyVAL.expr = expr.Max(yyDollar[3].expr)
 		}
-	case 35:
+	case 46:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:206
+//line partiql.y:248
 		{
 			yyVAL.expr = expr.Avg(yyDollar[3].expr)
 		}
-	case 36:
+	case 47:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:210
+//line partiql.y:252
 		{
 			yyVAL.expr = expr.Earliest(yyDollar[3].expr)
 		}
-	case 37:
+	case 48:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:214
+//line partiql.y:256
 		{
 			yyVAL.expr = expr.Latest(yyDollar[3].expr)
 		}
-	case 38:
+	case 49:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:218
+//line partiql.y:260
 		{
 			yyVAL.expr = expr.Abs(yyDollar[3].expr)
 		}
-	case 39:
+	case 50:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:222
+//line partiql.y:264
 		{
 			yyVAL.expr = expr.Sign(yyDollar[3].expr)
 		}
-	case 40:
+	case 51:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line partiql.y:268
+		{
+			yyVAL.expr = &expr.Struct{}
+		}
+	case 52:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line partiql.y:272
+		{
+			yyVAL.expr = expr.CallOp(expr.MakeStruct, yyDollar[2].values...)
+		}
+	case 53:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line partiql.y:276
+		{
+			yyVAL.expr = &expr.List{}
+		}
+	case 54:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line partiql.y:280
+		{
+			yyVAL.expr = expr.CallOp(expr.MakeList, yyDollar[2].values...)
+		}
+	case 55:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:226
+//line partiql.y:284
 		{
 			yyVAL.expr = &expr.Case{Limbs: yyDollar[2].limbs, Else: yyDollar[3].expr}
 		}
-	case 41:
+	case 56:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:230
+//line partiql.y:288
 		{
 			yyVAL.expr = expr.Coalesce(yyDollar[3].values)
 		}
-	case 42:
+	case 57:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:234
+//line partiql.y:292
 		{
 			yyVAL.expr = expr.NullIf(yyDollar[3].expr, yyDollar[5].expr)
 		}
-	case 43:
+	case 58:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:238
+//line partiql.y:296
 		{
 			nod, ok := buildCast(yyDollar[3].expr, yyDollar[5].str)
 			if !ok {
@@ -1195,9 +1330,9 @@ yydefault:
 			}
 			yyVAL.expr = nod
 		}
-	case 44:
+	case 59:
 		yyDollar = yyS[yypt-8 : yypt+1]
-//line partiql.y:247
+//line partiql.y:305
 		{
 			part, ok := timePart(yyDollar[3].str)
 			if !ok {
@@ -1205,9 +1340,9 @@ yydefault:
 			}
 			yyVAL.expr = expr.DateAdd(part, yyDollar[5].expr, yyDollar[7].expr)
 		}
-	case 45:
+	case 60:
 		yyDollar = yyS[yypt-8 : yypt+1]
-//line partiql.y:255
+//line partiql.y:313
 		{
 			part, ok := timePart(yyDollar[3].str)
 			if !ok {
@@ -1215,9 +1350,9 @@ yydefault:
 			}
 			yyVAL.expr = expr.DateDiff(part, yyDollar[5].expr, yyDollar[7].expr)
 		}
-	case 46:
+	case 61:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:263
+//line partiql.y:321
 		{
 			part, ok := timePart(yyDollar[3].str)
 			if !ok {
@@ -1225,9 +1360,9 @@ yydefault:
 			}
 			yyVAL.expr = expr.DateTrunc(part, yyDollar[5].expr)
 		}
-	case 47:
+	case 62:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line partiql.y:271
+//line partiql.y:329
 		{
 			part, ok := timePart(yyDollar[3].str)
 			if !ok {
@@ -1235,15 +1370,15 @@ yydefault:
 			}
 			yyVAL.expr = expr.DateExtract(part, yyDollar[5].expr)
 		}
-	case 48:
+	case 63:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:279
+//line partiql.y:337
 		{
 			yyVAL.expr = yylex.(*scanner).utcnow()
 		}
-	case 49:
+	case 64:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:283
+//line partiql.y:341
 		{
 			op := expr.Call(yyDollar[1].str)
 			if op.Private() {
@@ -1251,9 +1386,9 @@ yydefault:
 			}
 			yyVAL.expr = op
 		}
-	case 50:
+	case 65:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:291
+//line partiql.y:349
 		{
 			op := expr.Call(yyDollar[1].str, yyDollar[3].values...)
 			if op.Private() {
@@ -1261,303 +1396,315 @@ yydefault:
 			}
 			yyVAL.expr = op
 		}
-	case 51:
+	case 66:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:299
+//line partiql.y:357
 		{
 			yyVAL.expr = expr.CallOp(expr.InSubquery, yyDollar[1].expr, yyDollar[4].sel)
 		}
-	case 52:
+	case 67:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:303
+//line partiql.y:361
 		{
 			yyVAL.expr = expr.In(yyDollar[1].expr, yyDollar[4].values...)
 		}
-	case 53:
+	case 68:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:307
+//line partiql.y:365
 		{
 			yyVAL.expr = exists(yyDollar[3].sel)
 		}
-	case 54:
+	case 69:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:311
+//line partiql.y:369
 		{
 			yyVAL.expr = expr.Add(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 55:
+	case 70:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:315
+//line partiql.y:373
 		{
 			yyVAL.expr = expr.Sub(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 56:
+	case 71:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:319
+//line partiql.y:377
 		{
 			yyVAL.expr = expr.Mul(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 57:
+	case 72:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:323
+//line partiql.y:381
 		{
 			yyVAL.expr = expr.Div(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 58:
+	case 73:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:327
+//line partiql.y:385
 		{
 			yyVAL.expr = expr.Mod(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 59:
+	case 74:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:331
+//line partiql.y:389
 		{
 			yyVAL.expr = expr.Call("CONCAT", yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 60:
+	case 75:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:335
+//line partiql.y:393
 		{
 			yyVAL.expr = expr.Append(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 61:
+	case 76:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:339
+//line partiql.y:397
 		{
 			yyVAL.expr = expr.Neg(yyDollar[2].expr)
 		}
-	case 62:
+	case 77:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:343
+//line partiql.y:401
 		{
 			yyVAL.expr = expr.Compare(expr.Ilike, yyDollar[1].expr, expr.String(yyDollar[3].str))
 		}
-	case 63:
+	case 78:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:347
+//line partiql.y:405
 		{
 			yyVAL.expr = expr.Compare(expr.Like, yyDollar[1].expr, expr.String(yyDollar[3].str))
 		}
-	case 64:
+	case 79:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:351
+//line partiql.y:409
 		{
 			yyVAL.expr = expr.Compare(expr.Equals, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 65:
+	case 80:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:355
+//line partiql.y:413
 		{
 			yyVAL.expr = expr.Compare(expr.NotEquals, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 66:
+	case 81:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:359
+//line partiql.y:417
 		{
 			yyVAL.expr = expr.Compare(expr.Less, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 67:
+	case 82:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:363
+//line partiql.y:421
 		{
 			yyVAL.expr = expr.Compare(expr.LessEquals, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 68:
+	case 83:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:367
+//line partiql.y:425
 		{
 			yyVAL.expr = expr.Compare(expr.Greater, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 69:
+	case 84:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:371
+//line partiql.y:429
 		{
 			yyVAL.expr = expr.Compare(expr.GreaterEquals, yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 70:
+	case 85:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:375
+//line partiql.y:433
 		{
 			yyVAL.expr = expr.Between(yyDollar[1].expr, yyDollar[3].expr, yyDollar[5].expr)
 		}
-	case 71:
+	case 86:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:379
+//line partiql.y:437
 		{
 			yyVAL.expr = &expr.Not{Expr: expr.Compare(expr.Like, yyDollar[1].expr, expr.String(yyDollar[4].str))}
 		}
-	case 72:
+	case 87:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:383
+//line partiql.y:441
 		{
 			yyVAL.expr = &expr.Not{Expr: yyDollar[2].expr}
 		}
-	case 73:
+	case 88:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:387
+//line partiql.y:445
 		{
 			yyVAL.expr = expr.And(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 74:
+	case 89:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:391
+//line partiql.y:449
 		{
 			yyVAL.expr = expr.Or(yyDollar[1].expr, yyDollar[3].expr)
 		}
-	case 75:
+	case 90:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:395
+//line partiql.y:453
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsNull, Expr: yyDollar[1].expr}
 		}
-	case 76:
+	case 91:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:399
+//line partiql.y:457
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsNotNull, Expr: yyDollar[1].expr}
 		}
-	case 77:
+	case 92:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:403
+//line partiql.y:461
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsMissing, Expr: yyDollar[1].expr}
 		}
-	case 78:
+	case 93:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:407
+//line partiql.y:465
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsNotMissing, Expr: yyDollar[1].expr}
 		}
-	case 79:
+	case 94:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:411
+//line partiql.y:469
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsTrue, Expr: yyDollar[1].expr}
 		}
-	case 80:
+	case 95:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:415
+//line partiql.y:473
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsNotTrue, Expr: yyDollar[1].expr}
 		}
-	case 81:
+	case 96:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:419
+//line partiql.y:477
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsFalse, Expr: yyDollar[1].expr}
 		}
-	case 82:
+	case 97:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:423
+//line partiql.y:481
 		{
 			yyVAL.expr = &expr.IsKey{Key: expr.IsNotFalse, Expr: yyDollar[1].expr}
 		}
-	case 83:
+	case 98:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:429
+//line partiql.y:487
 		{
 			yyVAL.bindings = []expr.Binding{yyDollar[1].bind}
 		}
-	case 84:
+	case 99:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:430
+//line partiql.y:488
 		{
 			yyVAL.bindings = append(yyDollar[1].bindings, yyDollar[3].bind)
 		}
-	case 85:
+	case 100:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:434
+//line partiql.y:492
 		{
 			yyVAL.values = []expr.Node{yyDollar[1].expr}
 		}
-	case 86:
+	case 101:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:435
+//line partiql.y:493
 		{
 			yyVAL.values = []expr.Node{expr.Star{}}
 		}
-	case 87:
+	case 102:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:436
+//line partiql.y:494
 		{
 			yyVAL.values = append(yyDollar[1].values, yyDollar[3].expr)
 		}
-	case 88:
+	case 103:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line partiql.y:499
+		{
+			yyVAL.values = []expr.Node{expr.String(yyDollar[1].str), yyDollar[3].expr}
+		}
+	case 104:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line partiql.y:500
+		{
+			yyVAL.values = append(yyDollar[1].values, expr.String(yyDollar[3].str), yyDollar[5].expr)
+		}
+	case 105:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:439
+//line partiql.y:503
 		{
 			yyVAL.jk = expr.InnerJoin
 		}
-	case 89:
+	case 106:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:440
+//line partiql.y:504
 		{
 			yyVAL.jk = expr.InnerJoin
 		}
-	case 90:
+	case 107:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:441
+//line partiql.y:505
 		{
 			yyVAL.jk = expr.LeftJoin
 		}
-	case 91:
+	case 108:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:442
+//line partiql.y:506
 		{
 			yyVAL.jk = expr.LeftJoin
 		}
-	case 92:
+	case 109:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:443
+//line partiql.y:507
 		{
 			yyVAL.jk = expr.RightJoin
 		}
-	case 93:
+	case 110:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:444
+//line partiql.y:508
 		{
 			yyVAL.jk = expr.RightJoin
 		}
-	case 94:
+	case 111:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:445
+//line partiql.y:509
 		{
 			yyVAL.jk = expr.FullJoin
 		}
-	case 97:
+	case 114:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:450
+//line partiql.y:514
 		{
 			yyVAL.from = yyDollar[1].from
 		}
-	case 98:
+	case 115:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:451
+//line partiql.y:515
 		{
 			yyVAL.from = nil
 		}
-	case 99:
+	case 116:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:458
+//line partiql.y:522
 		{
 			yyVAL.from = &expr.Table{Binding: yyDollar[2].bind}
 		}
-	case 100:
+	case 117:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:459
+//line partiql.y:523
 		{
 			yyVAL.from = &expr.Join{Kind: expr.CrossJoin, Left: yyDollar[1].from, Right: yyDollar[3].bind}
 		}
-	case 101:
+	case 118:
 		yyDollar = yyS[yypt-7 : yypt+1]
-//line partiql.y:461
+//line partiql.y:525
 		{
 			yyVAL.from = &expr.Join{Kind: yyDollar[2].jk, Left: yyDollar[1].from, Right: yyDollar[3].bind, On: &expr.OnEquals{Left: yyDollar[5].expr, Right: yyDollar[7].expr}}
 		}
-	case 102:
+	case 119:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:464
+//line partiql.y:528
 		{
 			var idxerr error
 			yyVAL.integer, idxerr = toint(yyDollar[1].expr)
@@ -1565,184 +1712,184 @@ yydefault:
 				yylex.Error(idxerr.Error())
 			}
 		}
-	case 103:
+	case 120:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:467
+//line partiql.y:531
 		{
 			yyVAL.pc = nil
 		}
-	case 104:
+	case 121:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:468
+//line partiql.y:532
 		{
 			yyVAL.pc = &expr.Dot{Field: yyDollar[2].str, Rest: yyDollar[3].pc}
 		}
-	case 105:
+	case 122:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:469
+//line partiql.y:533
 		{
 			yyVAL.pc = &expr.LiteralIndex{Field: yyDollar[2].integer, Rest: yyDollar[4].pc}
 		}
-	case 106:
+	case 123:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:470
+//line partiql.y:534
 		{
 			yyVAL.pc = &expr.Dot{Field: yyDollar[2].str, Rest: yyDollar[4].pc}
 		}
-	case 107:
+	case 124:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:479
+//line partiql.y:543
 		{
 			yyVAL.str = yyDollar[1].str
 		}
-	case 108:
+	case 125:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:482
+//line partiql.y:546
 		{
 			yyVAL.expr = nil
 		}
-	case 109:
+	case 126:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:483
+//line partiql.y:547
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 110:
+	case 127:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line partiql.y:486
+//line partiql.y:550
 		{
 			yyVAL.limbs = []expr.CaseLimb{{When: yyDollar[2].expr, Then: yyDollar[4].expr}}
 		}
-	case 111:
+	case 128:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line partiql.y:487
+//line partiql.y:551
 		{
 			yyVAL.limbs = append(yyDollar[1].limbs, expr.CaseLimb{When: yyDollar[3].expr, Then: yyDollar[5].expr})
 		}
-	case 112:
+	case 129:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:490
+//line partiql.y:554
 		{
 			yyVAL.expr = nil
 		}
-	case 113:
+	case 130:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:491
+//line partiql.y:555
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 114:
+	case 131:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:494
+//line partiql.y:558
 		{
 			yyVAL.expr = nil
 		}
-	case 115:
+	case 132:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:495
+//line partiql.y:559
 		{
 			yyVAL.expr = yyDollar[2].expr
 		}
-	case 116:
+	case 133:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:498
+//line partiql.y:562
 		{
 			yyVAL.bindings = nil
 		}
-	case 117:
+	case 134:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:499
+//line partiql.y:563
 		{
 			yyVAL.bindings = yyDollar[3].bindings
 		}
-	case 118:
+	case 135:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:503
+//line partiql.y:567
 		{
 			yyVAL.yesno = false
 		}
-	case 119:
+	case 136:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:504
+//line partiql.y:568
 		{
 			yyVAL.yesno = false
 		}
-	case 120:
+	case 137:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:505
+//line partiql.y:569
 		{
 			yyVAL.yesno = true
 		}
-	case 121:
+	case 138:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:509
+//line partiql.y:573
 		{
 			yyVAL.yesno = false
 		}
-	case 122:
+	case 139:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:510
+//line partiql.y:574
 		{
 			yyVAL.yesno = false
 		}
-	case 123:
+	case 140:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:511
+//line partiql.y:575
 		{
 			yyVAL.yesno = true
 		}
-	case 124:
+	case 141:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:515
+//line partiql.y:579
 		{
 			yyVAL.order = expr.Order{Column: yyDollar[1].expr, Desc: yyDollar[2].yesno, NullsLast: yyDollar[3].yesno}
 		}
-	case 125:
+	case 142:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:518
+//line partiql.y:582
 		{
 			yyVAL.orders = append(yyDollar[1].orders, yyDollar[3].order)
 		}
-	case 126:
+	case 143:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line partiql.y:519
+//line partiql.y:583
 		{
 			yyVAL.orders = []expr.Order{yyDollar[1].order}
 		}
-	case 127:
+	case 144:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:522
+//line partiql.y:586
 		{
 			yyVAL.orders = nil
 		}
-	case 128:
+	case 145:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line partiql.y:523
+//line partiql.y:587
 		{
 			yyVAL.orders = yyDollar[3].orders
 		}
-	case 129:
+	case 146:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:526
+//line partiql.y:590
 		{
 			yyVAL.exprint = nil
 		}
-	case 130:
+	case 147:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:527
+//line partiql.y:591
 		{
 			n := expr.Integer(yyDollar[2].integer)
 			yyVAL.exprint = &n
 		}
-	case 131:
+	case 148:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line partiql.y:530
+//line partiql.y:594
 		{
 			yyVAL.exprint = nil
 		}
-	case 132:
+	case 149:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line partiql.y:531
+//line partiql.y:595
 		{
 			n := expr.Integer(yyDollar[2].integer)
 			yyVAL.exprint = &n
@@ -0,0 +1,121 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// HashSet is a set of 64-bit hashes
+// of ion-encoded values.
+//
+// A HashSet is produced from the result of
+// a sub-query that is too large to be
+// interpolated into a query as a list of constants.
+type HashSet struct {
+	// Hashes is the list of hashes
+	// in the set, in ascending order
+	// and without duplicates.
+	Hashes []uint64
+
+	once sync.Once
+	memo interface{}
+}
+
+// Memo returns the result of fn(h.Hashes).
+// The function fn is called at most once
+// per HashSet, so Memo can be used by
+// the query engine to cache a lookup
+// structure built from the set.
+func (h *HashSet) Memo(fn func([]uint64) interface{}) interface{} {
+	h.once.Do(func() {
+		h.memo = fn(h.Hashes)
+	})
+	return h.memo
+}
+
+// SemiJoin is an implementation of IN
+// that compares against the result of a
+// sub-query that has been reduced to a HashSet,
+// i.e. x IN HASH_SET(0)
+//
+// Only Arg and ID are serialized as part of
+// the expression; the HashSet is expected
+// to be provided out-of-band and attached
+// to the node after decoding.
+type SemiJoin struct {
+	Arg Node
+	// ID is the identifier of
+	// the sub-query that produced Set.
+	ID  int
+	Set *HashSet
+}
+
+func (s *SemiJoin) walk(v Visitor) {
+	Walk(v, s.Arg)
+}
+
+func (s *SemiJoin) rewrite(r Rewriter) Node {
+	s.Arg = Rewrite(r, s.Arg)
+	return s
+}
+
+func (s *SemiJoin) text(out *strings.Builder, redact bool) {
+	s.Arg.text(out, redact)
+	out.WriteString(" IN HASH_SET(")
+	out.WriteString(strconv.Itoa(s.ID))
+	out.WriteString(")")
+}
+
+func (s *SemiJoin) Encode(dst *ion.Buffer, st *ion.Symtab) {
+	dst.BeginStruct(-1)
+	settype(dst, st, "semijoin")
+	dst.BeginField(st.Intern("arg"))
+	s.Arg.Encode(dst, st)
+	dst.BeginField(st.Intern("id"))
+	dst.WriteInt(int64(s.ID))
+	dst.EndStruct()
+}
+
+func (s *SemiJoin) setfield(name string, st *ion.Symtab, body []byte) error {
+	var err error
+	switch name {
+	case "arg":
+		s.Arg, _, err = Decode(st, body)
+	case "id":
+		var i int64
+		i, _, err = ion.ReadInt(body)
+		if err == nil && i < 0 {
+			err = fmt.Errorf("invalid semijoin id %d", i)
+		}
+		s.ID = int(i)
+	}
+	return err
+}
+
+// Equals implements Node.Equals.
+//
+// Two SemiJoin nodes are equal if they
+// test the same argument against the same
+// sub-query; the contents of Set are not compared.
+func (s *SemiJoin) Equals(e Node) bool {
+	se, ok := e.(*SemiJoin)
+	return ok && se.ID == s.ID && s.Arg.Equals(se.Arg)
+}
@@ -395,17 +395,23 @@ func isRegularString(str string) bool {
 // so that the result-set of the case can be determined
 // statically to be a boolean result (these are cheaper
 // for the back-end to handle)
+//
+// the limbs are rewritten into a copy of c,
+// since c may also be referenced elsewhere
+// (see Coalesce)
 func cmpCase(c *Case, rewrite func(when Node) Node) *Case {
+	out := &Case{Limbs: make([]CaseLimb, len(c.Limbs))}
 	for i := range c.Limbs {
-		c.Limbs[i].Then = rewrite(c.Limbs[i].Then)
+		out.Limbs[i].When = c.Limbs[i].When
+		out.Limbs[i].Then = rewrite(c.Limbs[i].Then)
 	}
 	if c.Else == nil {
-		c.Else = Missing{}
+		out.Else = Missing{}
 	} else {
-		c.Else = rewrite(c.Else)
+		out.Else = rewrite(c.Else)
 	}
-	c.Valence = "logical"
-	return c
+	out.Valence = "logical"
+	return out
 }
 
 func (c *Comparison) simplify(h Hint) Node {
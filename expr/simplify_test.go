@@ -590,6 +590,20 @@ func TestSimplify(t *testing.T) {
 			}}),
 			Integer(3),
 		},
+		{
+			// {'a': 1, 'b': MISSING, 'c': [2, MISSING]} => constant
+			CallOp(MakeStruct, String("a"), Integer(1), String("b"), Missing{},
+				String("c"), CallOp(MakeList, Integer(2), Missing{})),
+			&Struct{Fields: []Field{
+				{"a", Integer(1)},
+				{"c", &List{Values: []Constant{Integer(2), Null{}}}},
+			}},
+		},
+		{
+			// {'a': x, 'b': [1]} is unchanged except for the constant list
+			CallOp(MakeStruct, String("a"), path("x"), String("b"), CallOp(MakeList, Integer(1))),
+			CallOp(MakeStruct, String("a"), path("x"), String("b"), &List{Values: []Constant{Integer(1)}}),
+		},
 		{
 			// SIZE(["a", "b", "c", "d") => 4
 			CallOp(ObjectSize, &List{Values: []Constant{
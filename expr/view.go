@@ -0,0 +1,174 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package expr
+
+import (
+	"strings"
+)
+
+// View is a saved query that can be
+// referenced in place of a table,
+// i.e. CREATE VIEW [db.]name AS SELECT ...
+//
+// References to a View are replaced with
+// a copy of Query as a sub-query (see ExpandViews).
+type View struct {
+	// Database is the database to which
+	// the view belongs, or the empty string
+	// if the name of the view was not qualified.
+	Database string
+	// Name is the name of the view.
+	Name string
+	// Query is the query that replaces
+	// references to the view.
+	Query *Select
+}
+
+// Text returns the CREATE VIEW
+// statement that defines v.
+func (v *View) Text() string {
+	var out strings.Builder
+	out.WriteString("CREATE VIEW ")
+	if v.Database != "" {
+		out.WriteString(QuoteID(v.Database))
+		out.WriteByte('.')
+	}
+	out.WriteString(QuoteID(v.Name))
+	out.WriteString(" AS ")
+	v.Query.write(&out, false, nil)
+	return out.String()
+}
+
+// Check checks that v is a valid definition.
+func (v *View) Check() error {
+	if v.Name == "" {
+		return errsyntax("view has no name")
+	}
+	if v.Query == nil {
+		return errsyntaxf("view %s has no query", v.Name)
+	}
+	if v.Query.From == nil {
+		return errsyntaxf("view %s: query has no FROM clause", v.Name)
+	}
+	return nil
+}
+
+// maxViewDepth is the maximum depth of
+// nested view references that are expanded
+// by ExpandViews; deeper nesting is assumed to
+// be the result of a recursive definition
+const maxViewDepth = 32
+
+type viewExpander struct {
+	lookup func(table Node) (*View, error)
+	depth  int
+	err    error
+	// bound holds, for each enclosing SELECT,
+	// the name bound to an expanded view
+	// in its FROM clause (or "" if none)
+	bound []string
+}
+
+func (v *viewExpander) Walk(e Node) Rewriter {
+	if _, ok := e.(*Select); ok {
+		v.bound = append(v.bound, "")
+	}
+	if v.err != nil {
+		return nil
+	}
+	return v
+}
+
+func (v *viewExpander) Rewrite(e Node) Node {
+	switch e := e.(type) {
+	case *Select:
+		v.bound = v.bound[:len(v.bound)-1]
+		return e
+	case *Path:
+		return v.unqualify(e)
+	case *Table:
+		return v.expand(e)
+	}
+	return e
+}
+
+// unqualify strips the name bound to an
+// expanded view from the front of p, since
+// the columns of the view are not qualified
+// within the sub-query that replaced it
+func (v *viewExpander) unqualify(p *Path) Node {
+	if len(v.bound) == 0 {
+		return p
+	}
+	name := v.bound[len(v.bound)-1]
+	if name == "" || p.First != name {
+		return p
+	}
+	if rest := p.Strip(); rest != nil {
+		return rest
+	}
+	return p
+}
+
+func (v *viewExpander) expand(tbl *Table) Node {
+	if v.err != nil {
+		return tbl
+	}
+	view, err := v.lookup(tbl.Expr)
+	if err != nil {
+		v.err = err
+		return tbl
+	}
+	if view == nil {
+		return tbl
+	}
+	if v.depth >= maxViewDepth {
+		v.err = errsyntaxf("view %s: too many levels of nested views (recursive definition?)", view.Name)
+		return tbl
+	}
+	// the view may itself reference other views
+	inner := &viewExpander{lookup: v.lookup, depth: v.depth + 1}
+	query := Rewrite(inner, Copy(view.Query))
+	if inner.err != nil {
+		v.err = inner.err
+		return tbl
+	}
+	// references to the view may be qualified
+	// with its alias or else with its name
+	name := view.Name
+	if tbl.Explicit() {
+		name = tbl.Result()
+	} else if p, ok := tbl.Expr.(*Path); ok {
+		name = p.Binding()
+	}
+	if len(v.bound) > 0 {
+		v.bound[len(v.bound)-1] = name
+	}
+	tbl.Expr = query
+	tbl.As("")
+	return tbl
+}
+
+// ExpandViews replaces each table reference in e
+// that refers to a View with a copy of the query
+// that defines the view. The lookup function is
+// called with the table expression of each
+// FROM clause, and it should return (nil, nil)
+// if the expression does not refer to a view.
+func ExpandViews(e Node, lookup func(table Node) (*View, error)) (Node, error) {
+	v := &viewExpander{lookup: lookup}
+	e = Rewrite(v, e)
+	return e, v.err
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandViews(t *testing.T) {
+	from := func(name string) From {
+		return &Table{Binding: Bind(Identifier(name), "")}
+	}
+	views := []*View{{
+		// CREATE VIEW prod AS SELECT * FROM events WHERE env = 'production'
+		Name: "prod",
+		Query: &Select{
+			Columns: []Binding{Bind(Star{}, "")},
+			From:    from("events"),
+			Where:   Compare(Equals, Identifier("env"), String("production")),
+		},
+	}, {
+		// CREATE VIEW prod_ids AS SELECT id FROM prod
+		Name: "prod_ids",
+		Query: &Select{
+			Columns: []Binding{Bind(Identifier("id"), "")},
+			From:    from("prod"),
+		},
+	}, {
+		// CREATE VIEW loop AS SELECT * FROM loop
+		Name: "loop",
+		Query: &Select{
+			Columns: []Binding{Bind(Star{}, "")},
+			From:    from("loop"),
+		},
+	}}
+	lookup := func(tbl Node) (*View, error) {
+		for i := range views {
+			if IsIdentifier(tbl, views[i].Name) {
+				return views[i], nil
+			}
+		}
+		return nil, nil
+	}
+	for i := range views {
+		if err := views[i].Check(); err != nil {
+			t.Fatalf("%s: %s", views[i].Text(), err)
+		}
+	}
+
+	tcs := []struct {
+		in   *Select
+		want string // or error text
+	}{
+		{
+			in:   &Select{Columns: []Binding{Bind(Count(Star{}), "")}, From: from("prod")},
+			want: "SELECT COUNT(*) FROM (SELECT * FROM events WHERE env = 'production')",
+		},
+		{
+			in:   &Select{Columns: []Binding{Bind(Identifier("id"), "")}, From: from("prod_ids")},
+			want: "SELECT id FROM (SELECT id AS id FROM (SELECT * FROM events WHERE env = 'production'))",
+		},
+		{
+			// other tables are left alone
+			in:   &Select{Columns: []Binding{Bind(Star{}, "")}, From: from("events")},
+			want: "SELECT * FROM events",
+		},
+		{
+			in:   &Select{Columns: []Binding{Bind(Star{}, "")}, From: from("loop")},
+			want: "too many levels of nested views",
+		},
+	}
+	for i := range tcs {
+		out, err := ExpandViews(tcs[i].in, lookup)
+		if err != nil {
+			if !strings.Contains(err.Error(), tcs[i].want) {
+				t.Errorf("case %d: unexpected error %s", i, err)
+			}
+			continue
+		}
+		if got := out.(*Select).Text(); got != tcs[i].want {
+			t.Errorf("case %d: got %s, want %s", i, got, tcs[i].want)
+		}
+	}
+	// the view queries must not be modified
+	if got := views[1].Query.Text(); got != "SELECT id FROM prod" {
+		t.Errorf("query modified: %s", got)
+	}
+}
@@ -51,3 +51,23 @@ func TestHeap(t *testing.T) {
 		t.Fatal("not sorted after FixSlice")
 	}
 }
+
+func TestOrderSlice(t *testing.T) {
+	less := func(x, y int) bool {
+		return x < y
+	}
+	for _, size := range []int{0, 1, 2, 3, 15, 16, 100, 1000} {
+		x := make([]int, size)
+		for i := range x {
+			x[i] = rand.Intn(size + 1)
+		}
+		OrderSlice(x, less)
+		sorted := make([]int, 0, len(x))
+		for len(x) > 0 {
+			sorted = append(sorted, PopSlice(&x, less))
+		}
+		if !slices.IsSorted(sorted) {
+			t.Fatalf("size %d: not sorted after OrderSlice", size)
+		}
+	}
+}
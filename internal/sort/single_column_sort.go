@@ -43,6 +43,12 @@ import (
 
 // ByColumn sorts collection of records by values from a single column.
 func ByColumn(rawrecords map[uint32][][]byte, column *MixedTypeColumn, direction Direction, nullsOrder NullsOrder, limit *Limit, rowsWriter *RowsWriter, rp *RuntimeParameters) error {
+	// the consumer only finishes once it
+	// has consumed at least one row
+	if column.Len() == 0 {
+		return nil
+	}
+
 	// 1. setup
 	var lim indicesRange
 	if limit != nil {
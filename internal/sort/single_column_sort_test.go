@@ -20,6 +20,7 @@ import (
 	"math/rand"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/SnellerInc/sneller/date"
 	"github.com/SnellerInc/sneller/ion"
@@ -478,6 +479,29 @@ func TestSingleColumnSortSortingData(t *testing.T) {
 	}
 }
 
+func TestSingleColumnSortEmpty(t *testing.T) {
+	var col MixedTypeColumn
+	var dst bytes.Buffer
+	var st ion.Symtab
+	writer, err := NewRowsWriter(&dst, &st, 32*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := NewRuntimeParameters(1)
+	done := make(chan error, 1)
+	go func() {
+		done <- ByColumn(map[uint32][][]byte{}, &col, Ascending, NullsFirst, nil, writer, &rp)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("sorting zero rows did not finish")
+	}
+}
+
 func testSingleColumnSortSortingData(t *testing.T, spec mtcSpec, direction Direction, nullsOrder NullsOrder) {
 	// given
 	records := makeTestMixedTypeIonRecords(spec)
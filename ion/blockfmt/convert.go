@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"runtime"
+	"slices"
 
 	"github.com/SnellerInc/sneller/compr"
 	"github.com/SnellerInc/sneller/ion"
@@ -210,6 +211,18 @@ func CloudtrailJSON(compression string) RowFormat {
 	}
 }
 
+// RowSorter is implemented by types that
+// reorder the rows produced by a Converter.
+type RowSorter interface {
+	// Write accepts chunks of rows
+	// as they are produced by ion.Chunker.
+	io.Writer
+	// Emit writes all of the rows that have
+	// been written to the RowSorter to dst.
+	// Emit does not flush dst.
+	Emit(dst *ion.Chunker) error
+}
+
 // Converter performs single- or
 // multi-stream conversion of a list of inputs
 // in parallel.
@@ -253,10 +266,19 @@ type Converter struct {
 	// DisablePrefetch, if true, disables
 	// prefetching of inputs.
 	DisablePrefetch bool
+	// Sort, if non-nil, receives all of the rows
+	// (including the rows from Prepend) before they
+	// are written to the Output so that it can
+	// write them out in a different order.
+	// Conversion is always single-stream
+	// when Sort is set.
+	Sort RowSorter
 
 	// trailer built by the writer. This is only
 	// set if the object was written successfully.
 	trailer *Trailer
+	// schema of the rows that were written
+	schema *Schema
 }
 
 // static errors known to be fatal to decoding
@@ -293,7 +315,7 @@ func IsFatal(err error) bool {
 // MultiStream returns whether the configuration of Converter
 // would lead to a multi-stream upload.
 func (c *Converter) MultiStream() bool {
-	return len(c.Inputs) > 1 && (c.Parallel <= 0 || c.Parallel > 1)
+	return c.Sort == nil && len(c.Inputs) > 1 && (c.Parallel <= 0 || c.Parallel > 1)
 }
 
 // Run runs the conversion operation
@@ -333,12 +355,30 @@ func (c *Converter) runSingle() error {
 		// half the target size
 		MinChunksPerBlock: c.FlushMeta / (c.Align * 2),
 	}
+	sw := &SchemaWriter{W: w}
 	cn := ion.Chunker{
-		W:          w,
+		W:          sw,
 		Align:      w.InputAlign,
 		RangeAlign: c.FlushMeta,
 	}
-	err := c.runPrepend(&cn)
+	// rows are converted into dst, which
+	// is cn unless they have to be sorted
+	dst := &cn
+	var sorted *sortInput
+	if c.Sort != nil {
+		sorted = &sortInput{RowSorter: c.Sort}
+		if c.Prepend.R != nil {
+			// the prepended rows are interleaved
+			// with the new rows, so their time ranges
+			// have to be tracked for the whole output
+			sorted.paths = collectRanges(c.Prepend.Trailer)
+		}
+		dst = &ion.Chunker{
+			W:     sorted,
+			Align: c.Align,
+		}
+	}
+	err := c.runPrepend(dst)
 	if err != nil {
 		return err
 	}
@@ -375,7 +415,7 @@ func (c *Converter) runSingle() error {
 			next++
 		}
 
-		err := c.Inputs[i].F.Convert(c.Inputs[i].R, &cn)
+		err := c.Inputs[i].F.Convert(c.Inputs[i].R, dst)
 		err2 := c.Inputs[i].R.Close()
 		if err == nil {
 			err = err2
@@ -396,15 +436,49 @@ func (c *Converter) runSingle() error {
 			return err
 		}
 	}
+	if c.Sort != nil {
+		err = dst.Flush()
+		if err == nil {
+			// the ranges recorded while the rows were
+			// converted are lost once they are sorted,
+			// so they are collected again from the
+			// sorted rows
+			cn.WalkTimeRanges = sorted.paths
+			err = c.Sort.Emit(&cn)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	err = cn.Flush()
 	if err != nil {
 		return err
 	}
 	err = w.Close()
 	c.trailer = &w.Trailer
+	c.schema = sw.Schema()
 	return err
 }
 
+// sortInput forwards rows to a RowSorter
+// and records the paths of the time ranges
+// that the Chunker collects from them
+type sortInput struct {
+	RowSorter
+	paths [][]string
+}
+
+// SetMinMax is called by ion.Chunker
+// when it flushes the collected ranges
+func (s *sortInput) SetMinMax(path []string, min, max ion.Datum) {
+	for i := range s.paths {
+		if slices.Equal(s.paths[i], path) {
+			return
+		}
+	}
+	s.paths = append(s.paths, slices.Clone(path))
+}
+
 func (c *Converter) runPrepend(cn *ion.Chunker) error {
 	if c.Prepend.R == nil {
 		return nil
@@ -448,6 +522,7 @@ func (c *Converter) runMulti() error {
 		readyc = doPrefetch(startc, max, wantInflight)
 	}
 	errs := make(chan error, p)
+	schemas := make([]*SchemaWriter, p)
 	consume := func(in chan *Input) {
 		for in := range in {
 			in.R.Close()
@@ -459,12 +534,13 @@ func (c *Converter) runMulti() error {
 			close(readyc)
 			return err
 		}
+		schemas[i] = &SchemaWriter{W: wc}
 		go func(i int) {
 			// if we encounter an error,
 			// drain the queue and close each item
 			defer consume(startc)
 			cn := ion.Chunker{
-				W:          wc,
+				W:          schemas[i],
 				Align:      w.InputAlign,
 				RangeAlign: c.FlushMeta,
 			}
@@ -523,9 +599,20 @@ func (c *Converter) runMulti() error {
 		return err
 	}
 	c.trailer = &w.Trailer
+	c.schema = new(Schema)
+	for i := range schemas {
+		c.schema.Add(schemas[i].Schema())
+	}
 	return nil
 }
 
 func (c *Converter) Trailer() *Trailer {
 	return c.trailer
 }
+
+// Schema returns the schema of the rows
+// in the output object. This is only valid
+// after Run has returned successfully.
+func (c *Converter) Schema() *Schema {
+	return c.schema
+}
@@ -12,7 +12,7 @@
 // You should have received a copy of the GNU Affero General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-package main
+package blockfmt
 
 import (
 	"fmt"
@@ -23,21 +23,20 @@ import (
 	"github.com/SnellerInc/sneller/expr"
 	"github.com/SnellerInc/sneller/expr/partiql"
 	"github.com/SnellerInc/sneller/ion"
-	"github.com/SnellerInc/sneller/ion/blockfmt"
 )
 
 func TestCompileFilter(t *testing.T) {
 	// Test basic expressions.
 	for _, c := range []struct {
 		node   expr.Node
-		expect ternary
+		expect Ternary
 	}{
-		{node: expr.Bool(false), expect: never},
-		{node: parsePath("foo"), expect: maybe},
-		{node: expr.Bool(true), expect: always},
+		{node: expr.Bool(false), expect: Never},
+		{node: parsePath("foo"), expect: Maybe},
+		{node: expr.Bool(true), expect: Always},
 	} {
-		var sparse blockfmt.SparseIndex
-		f, ok := compileFilter(c.node)
+		var sparse SparseIndex
+		f, ok := CompileFilter(c.node)
 		got := toMaybe(f, ok)(&sparse, 0)
 		if got != c.expect {
 			t.Errorf("%v: want %d, got %d", c.node, c.expect, got)
@@ -52,8 +51,8 @@ func TestCompileFilter(t *testing.T) {
 	//   U 3 4 5
 	//   T 6 7 8
 	//
-	type truthTable [9]ternary
-	const F, U, T = never, maybe, always
+	type truthTable [9]Ternary
+	const F, U, T = Never, Maybe, Always
 	for _, c := range []struct {
 		op expr.LogicalOp
 		tt truthTable
@@ -92,11 +91,11 @@ func TestCompileFilter(t *testing.T) {
 			expr.Bool(true),  // T
 		}
 		t.Run(fmt.Sprint(c.op), func(t *testing.T) {
-			var sparse blockfmt.SparseIndex
+			var sparse SparseIndex
 			le := &expr.Logical{Op: c.op}
 			for i := range c.tt {
 				le.Left, le.Right = exprs[i/3], exprs[i%3]
-				got, want := toMaybe(compileFilter(le))(&sparse, 0), c.tt[i]
+				got, want := toMaybe(CompileFilter(le))(&sparse, 0), c.tt[i]
 				if got != want {
 					t.Errorf("%v: want %d, got %d", le, want, got)
 				}
@@ -107,8 +106,8 @@ func TestCompileFilter(t *testing.T) {
 	// Test some path expressions against ranges.
 	now := date.Now().Truncate(time.Second)
 	type check struct {
-		ranges []blockfmt.Range
-		expect ternary
+		ranges []Range
+		expect Ternary
 	}
 	cases := []struct {
 		expr   expr.Node
@@ -118,124 +117,124 @@ func TestCompileFilter(t *testing.T) {
 		checks: []check{{
 			// No ranges
 			ranges: nil,
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Within the range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(-time.Hour)),
 				ion.Timestamp(now.Add(time.Hour)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Right at the min
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now),
 				ion.Timestamp(now.Add(time.Hour)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Right at the max; before(now, now) is false,
 			// but we use inclusive ranges, so "maybe"
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(-time.Hour)),
 				ion.Timestamp(now),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Before the range -> always
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(time.Hour)),
 				ion.Timestamp(now.Add(2*time.Hour)),
 			)},
-			expect: always,
+			expect: Always,
 		}, {
 			// After the range -> never
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(-2*time.Hour)),
 				ion.Timestamp(now.Add(-time.Hour)),
 			)},
-			expect: never,
+			expect: Never,
 		}, {
 			// Extra ranges, before the range -> maybe
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"baz"},
 				ion.Int(100),
 				ion.Int(200),
-			), blockfmt.NewRange(
+			), NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(time.Hour)),
 				ion.Timestamp(now.Add(2*time.Hour)),
 			)},
-			expect: always,
+			expect: Always,
 		}, {
 			// Different path
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"bar", "foo"},
 				ion.Timestamp(now.Add(time.Hour)),
 				ion.Timestamp(now.Add(2*time.Hour)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Non-applicable types
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Int(100),
 				ion.Int(200),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}},
 	}, {
 		expr: parseExpr("BEFORE(foo.bar, %s)", now),
 		checks: []check{{
 			// Within the range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(-time.Hour)),
 				ion.Timestamp(now.Add(time.Hour)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Right at the min; before(min, now) is false
 			// and before(max, now) is false, but we use
 			// inclusive ranges, so maybe
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now),
 				ion.Timestamp(now.Add(time.Hour)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Right at the max; before(max, now) is false
 			// but before(min, now) is true
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(-time.Hour)),
 				ion.Timestamp(now),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Before the range;
 			// BEFORE(foo.bar, now) is always false
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(time.Hour)),
 				ion.Timestamp(now.Add(2*time.Hour)),
 			)},
-			expect: never,
+			expect: Never,
 		}, {
 			// After the range;
 			// BEFORE(foo.bar, now) is always true
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(-2*time.Hour)),
 				ion.Timestamp(now.Add(-time.Hour)),
 			)},
-			expect: always,
+			expect: Always,
 		}},
 	}, {
 		expr: parseExpr("BEFORE(%s, foo.bar, %s)",
@@ -243,158 +242,158 @@ func TestCompileFilter(t *testing.T) {
 		checks: []check{{
 			// Smaller range; result always
 			// fits within bounds
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(-time.Minute)),
 				ion.Timestamp(now.Add(time.Minute)),
 			)},
-			expect: always,
+			expect: Always,
 		}, {
 			// Exact range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(-time.Hour)),
 				ion.Timestamp(now.Add(time.Hour)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Disjoint range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo", "bar"},
 				ion.Timestamp(now.Add(-2*time.Hour)),
 				ion.Timestamp(now.Add(-time.Hour-1)),
 			)},
-			expect: never,
+			expect: Never,
 		}},
 	}, {
 		expr: parseExpr("BEFORE(%s, foo, %s, bar, %s)",
 			now.Add(-time.Hour), now, now.Add(time.Hour)),
 		checks: []check{{
 			// Both smaller ranges
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo"},
 				ion.Timestamp(now.Add(-2*time.Minute)),
 				ion.Timestamp(now.Add(-1*time.Minute)),
-			), blockfmt.NewRange(
+			), NewRange(
 				[]string{"bar"},
 				ion.Timestamp(now.Add(1*time.Minute)),
 				ion.Timestamp(now.Add(2*time.Minute)),
 			)},
-			expect: always,
+			expect: Always,
 		}, {
 			// foo always matches
 			// bar never matches
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo"},
 				ion.Timestamp(now.Add(-2*time.Minute)),
 				ion.Timestamp(now.Add(-1*time.Minute)),
-			), blockfmt.NewRange(
+			), NewRange(
 				[]string{"bar"},
 				ion.Timestamp(now.Add(1*time.Hour+1)),
 				ion.Timestamp(now.Add(2*time.Hour)),
 			)},
-			expect: never,
+			expect: Never,
 		}, {
 			// Missing range for bar
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo"},
 				ion.Timestamp(now.Add(-2*time.Minute)),
 				ion.Timestamp(now.Add(-1*time.Minute)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}},
 	}, {
 		expr: parseExpr("TO_UNIX_EPOCH(foo) >= %d", now.Unix()),
 		checks: []check{{
 			// Within range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo"},
 				ion.Timestamp(now.Add(-1*time.Minute)),
 				ion.Timestamp(now.Add(+1*time.Minute)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// After the range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo"},
 				ion.Timestamp(now.Add(-2*time.Minute)),
 				ion.Timestamp(now.Add(-1*time.Minute)),
 			)},
-			expect: always,
+			expect: Always,
 		}, {
 			// Before the range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo"},
 				ion.Timestamp(now.Add(1*time.Minute)),
 				ion.Timestamp(now.Add(2*time.Minute)),
 			)},
-			expect: never,
+			expect: Never,
 		}, {
 			// Right at min
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo"},
 				ion.Timestamp(now),
 				ion.Timestamp(now.Add(time.Minute)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}},
 	}, {
 		expr: parseExpr("%d < TO_UNIX_EPOCH(bar)", now.Unix()),
 		checks: []check{{
 			// Within range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"bar"},
 				ion.Timestamp(now.Add(-1*time.Minute)),
 				ion.Timestamp(now.Add(+1*time.Minute)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Right below min
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"bar"},
 				ion.Timestamp(now.Add(1)),
 				ion.Timestamp(now.Add(time.Minute)),
 			)},
-			expect: never,
+			expect: Never,
 		}, {
 			// After the range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"bar"},
 				ion.Timestamp(now.Add(-2*time.Minute)),
 				ion.Timestamp(now.Add(-1*time.Minute)),
 			)},
-			expect: always,
+			expect: Always,
 		}},
 	}, {
 		expr: parseExpr("TO_UNIX_MICRO(foo) = %d", now.UnixMicro()),
 		checks: []check{{
 			// Within range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo"},
 				ion.Timestamp(now.Add(-1*time.Minute)),
 				ion.Timestamp(now.Add(+1*time.Minute)),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// min = max
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo"},
 				ion.Timestamp(now),
 				ion.Timestamp(now),
 			)},
-			expect: maybe,
+			expect: Maybe,
 		}, {
 			// Outside range
-			ranges: []blockfmt.Range{blockfmt.NewRange(
+			ranges: []Range{NewRange(
 				[]string{"foo"},
 				ion.Timestamp(now.Add(-2*time.Minute)),
 				ion.Timestamp(now.Add(-1*time.Minute)),
 			)},
-			expect: never,
+			expect: Never,
 		}},
 	}, {
 		expr:   expr.Bool(false),
-		checks: []check{{expect: never}},
+		checks: []check{{expect: Never}},
 	}, {
 		// include an un-indexed expression
 		expr: parseExpr("foo = 'bar' AND timestamp BETWEEN %s AND %s",
@@ -402,69 +401,69 @@ func TestCompileFilter(t *testing.T) {
 		checks: []check{
 			{
 				// strictly before
-				ranges: []blockfmt.Range{
-					blockfmt.NewRange(
+				ranges: []Range{
+					NewRange(
 						[]string{"userIdentity", "sessionContext", "creationDate"},
 						ion.Timestamp(now.Add(11*time.Minute)),
 						ion.Timestamp(now.Add(12*time.Minute)),
 					),
-					blockfmt.NewRange(
+					NewRange(
 						[]string{"timestamp"},
 						ion.Timestamp(now.Add(-12*time.Minute)),
 						ion.Timestamp(now.Add(-11*time.Minute)),
 					),
 				},
-				expect: never,
+				expect: Never,
 			},
 			{
 				// within
-				ranges: []blockfmt.Range{
-					blockfmt.NewRange(
+				ranges: []Range{
+					NewRange(
 						[]string{"timestamp"},
 						ion.Timestamp(now.Add(-time.Minute)),
 						ion.Timestamp(now.Add(time.Minute)),
 					),
-					blockfmt.NewRange(
+					NewRange(
 						[]string{"userIdentity", "sessionContext", "creationDate"},
 						ion.Timestamp(now.Add(-time.Hour)),
 						ion.Timestamp(now.Add(time.Hour)),
 					),
 				},
-				expect: maybe,
+				expect: Maybe,
 			},
 			{
 				// during
-				ranges: []blockfmt.Range{blockfmt.NewRange(
+				ranges: []Range{NewRange(
 					[]string{"timestamp"},
 					ion.Timestamp(now.Add(-2*time.Minute)),
 					ion.Timestamp(now.Add(2*time.Minute)),
 				)},
-				expect: maybe,
+				expect: Maybe,
 			},
 			{
 				// after
-				ranges: []blockfmt.Range{
-					blockfmt.NewRange(
+				ranges: []Range{
+					NewRange(
 						[]string{"timestamp"},
 						ion.Timestamp(now.Add(11*time.Minute)),
 						ion.Timestamp(now.Add(12*time.Minute)),
 					),
-					blockfmt.NewRange(
+					NewRange(
 						[]string{"userIdentity", "sessionContext", "creationDate"},
 						ion.Timestamp(now.Add(-time.Hour)),
 						ion.Timestamp(now.Add(time.Hour)),
 					),
 				},
-				expect: never,
+				expect: Never,
 			},
 		},
 	}}
 	for i := range cases {
 		c := cases[i]
 		t.Run(fmt.Sprint(expr.ToString(c.expr)), func(t *testing.T) {
-			f := toMaybe(compileFilter(c.expr))
+			f := toMaybe(CompileFilter(c.expr))
 			for i, c := range c.checks {
-				var sparse blockfmt.SparseIndex
+				var sparse SparseIndex
 				if len(c.ranges) > 0 {
 					sparse.Push(c.ranges)
 				}
@@ -505,13 +504,13 @@ func BenchmarkExecuteFilter(b *testing.B) {
 	now := date.Now().Truncate(time.Microsecond)
 	cases := []struct {
 		Expr   expr.Node
-		Ranges []blockfmt.Range
+		Ranges []Range
 	}{
 		{
 			Expr: parseExpr(`(foo = 'baz' OR foo = 'bar') AND BEFORE(x, %s)`, now),
-			Ranges: []blockfmt.Range{
-				blockfmt.NewRange([]string{"quux"}, ion.Int(0), ion.Int(100)),
-				blockfmt.NewRange([]string{"x"}, ion.Timestamp(now.Add(-time.Minute)), ion.Timestamp(now.Add(time.Minute))),
+			Ranges: []Range{
+				NewRange([]string{"quux"}, ion.Int(0), ion.Int(100)),
+				NewRange([]string{"x"}, ion.Timestamp(now.Add(-time.Minute)), ion.Timestamp(now.Add(time.Minute))),
 			},
 		},
 	}
@@ -519,8 +518,8 @@ func BenchmarkExecuteFilter(b *testing.B) {
 		e := cases[i].Expr
 		rng := cases[i].Ranges
 		b.Run(fmt.Sprintf("case-%d", i), func(b *testing.B) {
-			f := toMaybe(compileFilter(e))
-			var sparse blockfmt.SparseIndex
+			f := toMaybe(CompileFilter(e))
+			var sparse SparseIndex
 			sparse.Push(rng)
 			b.ResetTimer()
 			b.ReportAllocs()
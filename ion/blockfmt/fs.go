@@ -26,6 +26,7 @@ import (
 	"strings"
 
 	"github.com/SnellerInc/sneller/aws/s3"
+	"github.com/SnellerInc/sneller/azure"
 	"github.com/SnellerInc/sneller/fsutil"
 
 	"golang.org/x/crypto/blake2b"
@@ -110,6 +111,45 @@ func (s *S3FS) WriteFile(path string, contents []byte) (string, error) {
 	return s.Put(path, contents)
 }
 
+// AzureFS implements UploadFS and InputFS
+// for the blobs in an Azure storage container.
+type AzureFS struct {
+	azure.ContainerFS
+}
+
+// Prefix implements InputFS.Prefix
+func (a *AzureFS) Prefix() string {
+	return "az://" + a.Container + "/"
+}
+
+// ETag implements InputFS.ETag
+func (a *AzureFS) ETag(fullpath string, f fs.FileInfo) (string, error) {
+	if rd, ok := f.(*azure.File); ok {
+		return rd.ETag, nil
+	}
+	return "", fmt.Errorf("cannot produce ETag for %T", f)
+}
+
+// Create implements UploadFS.Create
+func (a *AzureFS) Create(path string) (Uploader, error) {
+	up := &azure.Uploader{
+		Key:       a.Key,
+		Client:    a.Client,
+		Container: a.Container,
+		Blob:      path,
+	}
+	err := up.Start()
+	if err != nil {
+		return nil, err
+	}
+	return up, nil
+}
+
+// WriteFile implements UploadFS.WriteFile
+func (a *AzureFS) WriteFile(path string, contents []byte) (string, error) {
+	return a.Put(path, contents)
+}
+
 // NewDirFS creates a new DirFS in dir.
 func NewDirFS(dir string) *DirFS {
 	return &DirFS{
@@ -266,6 +306,8 @@ var (
 	_ UploadFS = &DirFS{}
 	_ InputFS  = &S3FS{}
 	_ UploadFS = &S3FS{}
+	_ InputFS  = &AzureFS{}
+	_ UploadFS = &AzureFS{}
 )
 
 func inferFormat(name string, fallback func(name string) RowFormat) RowFormat {
@@ -307,13 +307,28 @@ func (idx *Index) append(i *IndirectTree, ofs UploadFS, basedir string, lst []De
 		pushSummary(&i.Sparse, lst)
 	}
 	all := append(prepend, lst...)
+	err = writeRef(ofs, basedir, r, all)
+	if err != nil {
+		return err
+	}
+	if prev != "" {
+		idx.ToDelete = append(idx.ToDelete, Quarantined{
+			Path:   prev,
+			Expiry: date.Now().Add(expiry),
+		})
+	}
+	return nil
+}
 
+// writeRef writes the list of descriptors lst
+// to a new object in basedir and points r at it
+func writeRef(ofs UploadFS, basedir string, r *IndirectRef, lst []Descriptor) error {
 	// encode the list of objects:
 	var buf ion.Buffer
 	var st ion.Symtab
 	buf.BeginStruct(-1)
 	buf.BeginField(st.Intern("contents"))
-	writeContents(&buf, &st, all)
+	writeContents(&buf, &st, lst)
 	buf.EndStruct()
 
 	split := buf.Size()
@@ -331,7 +346,7 @@ func (idx *Index) append(i *IndirectTree, ofs UploadFS, basedir string, lst []De
 	r.Path = p
 	r.ETag = etag
 	r.Size = int64(len(compressed))
-	r.Objects = len(all)
+	r.Objects = len(lst)
 
 	info, err := fs.Stat(ofs, p)
 	if err != nil {
@@ -345,7 +360,46 @@ func (idx *Index) append(i *IndirectTree, ofs UploadFS, basedir string, lst []De
 		return fmt.Errorf("stored etag is %s instead of %s?", storedEtag, etag)
 	}
 	r.LastModified = date.FromTime(info.ModTime())
-	if prev != "" {
+	return nil
+}
+
+// RewriteIndirect rewrites the lists of descriptors
+// in idx.Indirect. The function fn is called with the list
+// of descriptors in each indirect object for which
+// overlap(&idx.Indirect.Sparse, i) returns true
+// (or every object if overlap is nil).
+// If fn returns true, then the returned list of descriptors
+// is written to a new object in basedir, and the old object
+// is queued in idx.ToDelete with the provided expiry
+// relative to the current time.
+//
+// The summary of the time ranges covered by each
+// object is not narrowed, so fn must not return
+// descriptors that cover time ranges outside of
+// those in the original list.
+func (idx *Index) RewriteIndirect(ofs UploadFS, basedir string, overlap func(*SparseIndex, int) bool, fn func([]Descriptor) ([]Descriptor, bool, error), expiry time.Duration) error {
+	i := &idx.Indirect
+	for j := range i.Refs {
+		if overlap != nil && !overlap(&i.Sparse, j) {
+			continue
+		}
+		r := &i.Refs[j]
+		lst, err := i.decode(ofs, r, nil, nil)
+		if err != nil {
+			return err
+		}
+		lst, changed, err := fn(lst)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			continue
+		}
+		prev := r.Path
+		err = writeRef(ofs, basedir, r, lst)
+		if err != nil {
+			return err
+		}
 		idx.ToDelete = append(idx.ToDelete, Quarantined{
 			Path:   prev,
 			Expiry: date.Now().Add(expiry),
@@ -0,0 +1,152 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/SnellerInc/sneller/compr"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// Rewriter produces a new object from
+// an existing blockfmt-formatted object by
+// passing each of its decompressed chunks
+// through a function. The time ranges indexed
+// in the trailer of the original object are
+// also indexed in the new object.
+type Rewriter struct {
+	// Output is the Uploader to which
+	// the new object is written.
+	Output Uploader
+	// Comp is the name of the compression
+	// algorithm used for uploaded data blocks.
+	Comp string
+	// Align is the pre-compression alignment
+	// of chunks written to the uploader.
+	Align int
+	// FlushMeta is the maximum interval
+	// at which metadata is flushed.
+	FlushMeta int
+
+	trailer *Trailer
+	schema  *Schema
+}
+
+// Run reads the object described by t from src
+// (which must read the data preceding t.Offset)
+// and calls fn for each decompressed chunk along
+// with the index of the block that contains it.
+// Each chunk passed to fn begins with a BVM
+// and a complete symbol table, even if the chunk
+// in the original object only appended symbols
+// to the symbol table of the preceding chunk.
+// The function fn should write the ion data that
+// should be present in the new object to dst.
+func (r *Rewriter) Run(src io.Reader, t *Trailer, fn func(dst io.Writer, block int, chunk []byte) error) error {
+	comp := compr.Compression(r.Comp)
+	if comp == nil {
+		return fmt.Errorf("compression %q unavailable", r.Comp)
+	}
+	w := &CompressionWriter{
+		Output:            r.Output,
+		Comp:              comp,
+		InputAlign:        r.Align,
+		MinChunksPerBlock: r.FlushMeta / (r.Align * 2),
+	}
+	sw := &SchemaWriter{W: w}
+	cn := ion.Chunker{
+		W:              sw,
+		Align:          w.InputAlign,
+		RangeAlign:     r.FlushMeta,
+		WalkTimeRanges: collectRanges(t),
+	}
+	err := Chunks(src, t, func(block int, chunk []byte) error {
+		return fn(&cn, block, chunk)
+	})
+	if err != nil {
+		return err
+	}
+	err = cn.Flush()
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	r.trailer = &w.Trailer
+	r.schema = sw.Schema()
+	return nil
+}
+
+// Trailer returns the trailer of the new object.
+// It is only valid after Run has returned successfully.
+func (r *Rewriter) Trailer() *Trailer {
+	return r.trailer
+}
+
+// Schema returns the schema of the rows in the new object.
+// It is only valid after Run has returned successfully.
+func (r *Rewriter) Schema() *Schema {
+	return r.schema
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (w writerFunc) Write(p []byte) (int, error) { return w(p) }
+
+// Chunks reads the object described by t from src
+// (which must read the data preceding t.Offset)
+// and calls fn for each decompressed chunk along
+// with the index of the block that contains it.
+// As with Rewriter.Run, each chunk passed to fn
+// begins with a BVM and a complete symbol table.
+// If fn returns an error, Chunks stops reading
+// and returns that error.
+func Chunks(src io.Reader, t *Trailer, fn func(block int, chunk []byte) error) error {
+	var d Decoder
+	var st ion.Symtab
+	var hdr ion.Buffer
+	var tmp []byte
+	d.Set(t, len(t.Blocks))
+	for i := range t.Blocks {
+		end := t.Offset
+		if i+1 < len(t.Blocks) {
+			end = t.Blocks[i+1].Offset
+		}
+		block := i
+		st.Reset()
+		_, err := d.Copy(writerFunc(func(p []byte) (int, error) {
+			rest := p
+			if ion.IsBVM(p) || ion.TypeOf(p) == ion.AnnotationType {
+				var err error
+				rest, err = st.Unmarshal(p)
+				if err != nil {
+					return 0, err
+				}
+			}
+			hdr.Reset()
+			st.Marshal(&hdr, true)
+			tmp = append(append(tmp[:0], hdr.Bytes()...), rest...)
+			return len(p), fn(block, tmp)
+		}), io.LimitReader(src, end-t.Blocks[i].Offset))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,411 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+const (
+	// MaxSchemaFields is the maximum number
+	// of distinct field paths recorded in a Schema.
+	// Additional paths are not recorded,
+	// and Schema.Truncated is set instead.
+	MaxSchemaFields = 1000
+	// MaxSchemaDepth is the maximum depth
+	// of the field paths recorded in a Schema.
+	// Structures below this depth are counted
+	// as structures, but their fields are not recorded.
+	MaxSchemaDepth = 8
+)
+
+// TypeCounts is the number of
+// values of each ion.Type.
+type TypeCounts [16]int64
+
+// Total returns the total number of values.
+func (t *TypeCounts) Total() int64 {
+	n := int64(0)
+	for i := range t {
+		n += t[i]
+	}
+	return n
+}
+
+// FieldSchema describes the values
+// that occur at a particular field path.
+type FieldSchema struct {
+	// Path is the path of the field
+	// from the top-level row.
+	Path []string
+	// Types is the number of values
+	// of each type that occur at Path.
+	Types TypeCounts
+}
+
+// Schema is a summary of the field paths
+// and the types of the values at those
+// paths in a collection of rows.
+//
+// Fields within lists are not recorded.
+type Schema struct {
+	// Rows is the number of rows.
+	Rows int64
+	// Fields is the list of field paths,
+	// ordered lexicographically by path.
+	Fields []FieldSchema
+	// Truncated is set if some paths were
+	// not recorded because there were more
+	// than MaxSchemaFields distinct paths.
+	// When a schema is truncated, a path
+	// that is not present in Fields may still
+	// occur in the rows.
+	Truncated bool
+}
+
+func comparePath(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// Field returns the schema for
+// the given path, or nil if the
+// path does not occur.
+func (s *Schema) Field(path []string) *FieldSchema {
+	i := sort.Search(len(s.Fields), func(i int) bool {
+		return comparePath(s.Fields[i].Path, path) >= 0
+	})
+	if i < len(s.Fields) && comparePath(s.Fields[i].Path, path) == 0 {
+		return &s.Fields[i]
+	}
+	return nil
+}
+
+// Empty returns true if s describes no rows.
+func (s *Schema) Empty() bool {
+	return s.Rows == 0 && len(s.Fields) == 0 && !s.Truncated
+}
+
+// Add adds the rows described by o to s.
+func (s *Schema) Add(o *Schema) {
+	if o == nil {
+		return
+	}
+	s.Rows += o.Rows
+	s.Truncated = s.Truncated || o.Truncated
+	out := make([]FieldSchema, 0, len(s.Fields)+len(o.Fields))
+	i, j := 0, 0
+	for i < len(s.Fields) || j < len(o.Fields) {
+		var c int
+		switch {
+		case i == len(s.Fields):
+			c = 1
+		case j == len(o.Fields):
+			c = -1
+		default:
+			c = comparePath(s.Fields[i].Path, o.Fields[j].Path)
+		}
+		switch {
+		case c < 0:
+			out = append(out, s.Fields[i])
+			i++
+		case c > 0:
+			out = append(out, o.Fields[j])
+			j++
+		default:
+			f := s.Fields[i]
+			for k := range f.Types {
+				f.Types[k] += o.Fields[j].Types[k]
+			}
+			out = append(out, f)
+			i++
+			j++
+		}
+	}
+	if len(out) > MaxSchemaFields {
+		out = out[:MaxSchemaFields]
+		s.Truncated = true
+	}
+	s.Fields = out
+}
+
+// Sub removes the rows described by o from s.
+// The rows described by o must have been
+// previously added to s with Add.
+// Paths that no longer occur are removed from s.
+func (s *Schema) Sub(o *Schema) {
+	if o == nil {
+		return
+	}
+	s.Rows -= o.Rows
+	if s.Rows < 0 {
+		s.Rows = 0
+	}
+	out := s.Fields[:0]
+	j := 0
+	for i := range s.Fields {
+		f := s.Fields[i]
+		for j < len(o.Fields) && comparePath(o.Fields[j].Path, f.Path) < 0 {
+			j++
+		}
+		if j < len(o.Fields) && comparePath(o.Fields[j].Path, f.Path) == 0 {
+			for k := range f.Types {
+				f.Types[k] -= o.Fields[j].Types[k]
+				if f.Types[k] < 0 {
+					// possible if o was truncated
+					// differently than s
+					f.Types[k] = 0
+				}
+			}
+		}
+		if f.Types.Total() > 0 {
+			out = append(out, f)
+		}
+	}
+	s.Fields = out
+}
+
+// Encode encodes s to dst using the symbol table st.
+func (s *Schema) Encode(dst *ion.Buffer, st *ion.Symtab) {
+	dst.BeginStruct(-1)
+	dst.BeginField(st.Intern("rows"))
+	dst.WriteInt(s.Rows)
+	if s.Truncated {
+		dst.BeginField(st.Intern("truncated"))
+		dst.WriteBool(true)
+	}
+	dst.BeginField(st.Intern("fields"))
+	dst.BeginList(-1)
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		dst.BeginStruct(-1)
+		dst.BeginField(st.Intern("path"))
+		dst.BeginList(-1)
+		for j := range f.Path {
+			dst.WriteString(f.Path[j])
+		}
+		dst.EndList()
+		dst.BeginField(st.Intern("types"))
+		dst.BeginStruct(-1)
+		for t := range f.Types {
+			if f.Types[t] != 0 {
+				dst.BeginField(st.Intern(ion.Type(t).String()))
+				dst.WriteInt(f.Types[t])
+			}
+		}
+		dst.EndStruct()
+		dst.EndStruct()
+	}
+	dst.EndList()
+	dst.EndStruct()
+}
+
+func typeByName(name string) (ion.Type, bool) {
+	for t := ion.NullType; t <= ion.ReservedType; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// Decode decodes a schema encoded using Encode.
+func (s *Schema) Decode(st *ion.Symtab, body []byte) error {
+	return unpackStruct(st, body, func(name string, field []byte) error {
+		var err error
+		switch name {
+		case "rows":
+			s.Rows, _, err = ion.ReadInt(field)
+		case "truncated":
+			s.Truncated, _, err = ion.ReadBool(field)
+		case "fields":
+			err = unpackList(field, func(field []byte) error {
+				var f FieldSchema
+				err := f.decode(st, field)
+				if err != nil {
+					return err
+				}
+				s.Fields = append(s.Fields, f)
+				return nil
+			})
+		default:
+			err = fmt.Errorf("unexpected field %q", name)
+		}
+		return err
+	})
+}
+
+func (f *FieldSchema) decode(st *ion.Symtab, body []byte) error {
+	return unpackStruct(st, body, func(name string, field []byte) error {
+		switch name {
+		case "path":
+			return unpackList(field, func(field []byte) error {
+				str, _, err := ion.ReadString(field)
+				if err != nil {
+					return err
+				}
+				f.Path = append(f.Path, str)
+				return nil
+			})
+		case "types":
+			return unpackStruct(st, field, func(name string, field []byte) error {
+				t, ok := typeByName(name)
+				if !ok {
+					return fmt.Errorf("unknown type %q", name)
+				}
+				n, _, err := ion.ReadInt(field)
+				f.Types[t] = n
+				return err
+			})
+		default:
+			return fmt.Errorf("unexpected field %q", name)
+		}
+	})
+}
+
+// SchemaWriter is an io.Writer that collects
+// a Schema from the ion chunks written to it
+// before passing them to W.
+//
+// SchemaWriter can be used as the ion.Chunker.W
+// in place of W; it implements ion.Flusher and
+// forwards the time ranges produced by the Chunker
+// if W accepts them.
+type SchemaWriter struct {
+	// W is the writer to which
+	// the chunks are forwarded.
+	W io.Writer
+
+	st     ion.Symtab
+	rows   int64
+	trunc  bool
+	fields map[string]*FieldSchema
+	path   []string
+	key    []byte
+}
+
+// Write implements io.Writer.Write
+func (w *SchemaWriter) Write(p []byte) (int, error) {
+	rest := p
+	if ion.IsBVM(p) || ion.TypeOf(p) == ion.AnnotationType {
+		var err error
+		rest, err = w.st.Unmarshal(p)
+		if err != nil {
+			return 0, fmt.Errorf("SchemaWriter: %w", err)
+		}
+	}
+	for len(rest) > 0 {
+		size := ion.SizeOf(rest)
+		if size <= 0 || size > len(rest) {
+			return 0, fmt.Errorf("SchemaWriter: invalid ion object")
+		}
+		if ion.TypeOf(rest) == ion.StructType {
+			w.rows++
+			body, _ := ion.Contents(rest[:size])
+			w.record(body)
+		}
+		rest = rest[size:]
+	}
+	return w.W.Write(p)
+}
+
+// Flush implements ion.Flusher
+func (w *SchemaWriter) Flush() error {
+	if f, ok := w.W.(ion.Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// SetMinMax forwards the time range
+// of the next chunk to W, if W accepts it
+func (w *SchemaWriter) SetMinMax(path []string, min, max ion.Datum) {
+	if mm, ok := w.W.(minMaxer); ok {
+		mm.SetMinMax(path, min, max)
+	}
+}
+
+func (w *SchemaWriter) record(body []byte) {
+	for len(body) > 0 {
+		sym, rest, err := ion.ReadLabel(body)
+		if err != nil {
+			return
+		}
+		size := ion.SizeOf(rest)
+		if size <= 0 || size > len(rest) {
+			return
+		}
+		val := rest[:size]
+		body = rest[size:]
+		t := ion.TypeOf(val)
+		if t == ion.NullType && val[0] != 0x0f {
+			continue // nop pad
+		}
+		w.path = append(w.path, w.st.Get(sym))
+		w.count(t)
+		if t == ion.StructType && len(w.path) < MaxSchemaDepth {
+			inner, _ := ion.Contents(val)
+			w.record(inner)
+		}
+		w.path = w.path[:len(w.path)-1]
+	}
+}
+
+func (w *SchemaWriter) count(t ion.Type) {
+	w.key = w.key[:0]
+	for i := range w.path {
+		w.key = append(w.key, w.path[i]...)
+		w.key = append(w.key, 0)
+	}
+	f := w.fields[string(w.key)]
+	if f == nil {
+		if len(w.fields) >= MaxSchemaFields {
+			w.trunc = true
+			return
+		}
+		if w.fields == nil {
+			w.fields = make(map[string]*FieldSchema)
+		}
+		f = &FieldSchema{Path: append([]string(nil), w.path...)}
+		w.fields[string(w.key)] = f
+	}
+	f.Types[t]++
+}
+
+// Schema returns the schema of the rows
+// written to w so far.
+func (w *SchemaWriter) Schema() *Schema {
+	s := &Schema{
+		Rows:      w.rows,
+		Truncated: w.trunc,
+		Fields:    make([]FieldSchema, 0, len(w.fields)),
+	}
+	for _, f := range w.fields {
+		s.Fields = append(s.Fields, *f)
+	}
+	sort.Slice(s.Fields, func(i, j int) bool {
+		return comparePath(s.Fields[i].Path, s.Fields[j].Path) < 0
+	})
+	return s
+}
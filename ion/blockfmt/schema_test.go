@@ -0,0 +1,136 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockfmt
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func convertSchema(t *testing.T, parallel int, inputs ...string) *Schema {
+	var lst []Input
+	for i := range inputs {
+		lst = append(lst, Input{
+			R: io.NopCloser(strings.NewReader(inputs[i])),
+			F: SuffixToFormat[".json"](),
+		})
+	}
+	var out BufferUploader
+	out.PartSize = 4096
+	c := Converter{
+		Output:    &out,
+		Comp:      "zstd",
+		Inputs:    lst,
+		Align:     1024,
+		FlushMeta: 4096,
+		Parallel:  parallel,
+	}
+	err := c.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.Schema()
+}
+
+func TestSchemaCollect(t *testing.T) {
+	a := `{"id": 1, "name": "foo", "tags": ["x"]}
+{"id": -2, "name": null, "inner": {"x": 1.5, "y": {"z": true}}}
+`
+	b := `{"id": 3, "inner": {"x": "str"}}
+`
+	counts := func(pairs ...any) TypeCounts {
+		var tc TypeCounts
+		for i := 0; i < len(pairs); i += 2 {
+			tc[pairs[i].(ion.Type)] = int64(pairs[i+1].(int))
+		}
+		return tc
+	}
+	want := &Schema{
+		Rows: 3,
+		Fields: []FieldSchema{
+			{Path: []string{"id"}, Types: counts(ion.UintType, 2, ion.IntType, 1)},
+			{Path: []string{"inner"}, Types: counts(ion.StructType, 2)},
+			{Path: []string{"inner", "x"}, Types: counts(ion.FloatType, 1, ion.StringType, 1)},
+			{Path: []string{"inner", "y"}, Types: counts(ion.StructType, 1)},
+			{Path: []string{"inner", "y", "z"}, Types: counts(ion.BoolType, 1)},
+			{Path: []string{"name"}, Types: counts(ion.StringType, 1, ion.NullType, 1)},
+			{Path: []string{"tags"}, Types: counts(ion.ListType, 1)},
+		},
+	}
+	for _, parallel := range []int{1, 2} {
+		t.Run(fmt.Sprintf("parallel=%d", parallel), func(t *testing.T) {
+			got := convertSchema(t, parallel, a, b)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got  %+v", got)
+				t.Errorf("want %+v", want)
+			}
+		})
+	}
+
+	// Add and Sub are inverses
+	sa := convertSchema(t, 1, a)
+	sb := convertSchema(t, 1, b)
+	sum := new(Schema)
+	sum.Add(sa)
+	sum.Add(sb)
+	if !reflect.DeepEqual(sum, want) {
+		t.Errorf("Add: got %+v", sum)
+	}
+	sum.Sub(sa)
+	if !reflect.DeepEqual(sum, sb) {
+		t.Errorf("Sub: got %+v, want %+v", sum, sb)
+	}
+	if f := sum.Field([]string{"inner", "x"}); f == nil || f.Types[ion.StringType] != 1 || f.Types.Total() != 1 {
+		t.Errorf("Field(inner.x) = %+v", f)
+	}
+	if f := sum.Field([]string{"name"}); f != nil {
+		t.Errorf("Field(name) = %+v after Sub", f)
+	}
+
+	// schemas survive a round-trip
+	var st ion.Symtab
+	var buf ion.Buffer
+	want.Encode(&buf, &st)
+	ret := new(Schema)
+	err := ret.Decode(&st, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ret, want) {
+		t.Errorf("decoded schema %+v", ret)
+	}
+}
+
+func TestSchemaTruncated(t *testing.T) {
+	var text strings.Builder
+	for i := 0; i < MaxSchemaFields+10; i++ {
+		fmt.Fprintf(&text, "{\"f%d\": %d}\n", i, i)
+	}
+	s := convertSchema(t, 1, text.String())
+	if !s.Truncated {
+		t.Error("schema not truncated")
+	}
+	if len(s.Fields) != MaxSchemaFields {
+		t.Errorf("%d fields", len(s.Fields))
+	}
+	if s.Rows != MaxSchemaFields+10 {
+		t.Errorf("%d rows", s.Rows)
+	}
+}
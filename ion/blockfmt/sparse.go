@@ -52,6 +52,16 @@ func (s *SparseIndex) FieldNames() []string {
 	return o
 }
 
+// FieldPaths returns the list of path components
+// of each indexed field, in the same order as FieldNames.
+func (s *SparseIndex) FieldPaths() [][]string {
+	o := make([][]string, len(s.indices))
+	for i := range s.indices {
+		o[i] = s.indices[i].path
+	}
+	return o
+}
+
 func (s *SparseIndex) Encode(dst *ion.Buffer, st *ion.Symtab) {
 	dst.BeginStruct(-1)
 	dst.BeginField(st.Intern("blocks"))
@@ -392,6 +392,19 @@ func (c *Chunker) Commit() error {
 	return nil
 }
 
+// CommitStable is identical to Commit, except
+// that it never resymbolizes the buffered objects,
+// so c.Symbols does not change and objects that were
+// encoded using c.Symbols may be appended to c.Buffer
+// directly after CommitStable returns.
+func (c *Chunker) CommitStable() error {
+	c.noResymbolize = true
+	defer func() {
+		c.noResymbolize = false
+	}()
+	return c.Commit()
+}
+
 // Flush flushes the output of the chunker,
 // regardless of whether or not the current
 // buffer is approaching the target alignment.
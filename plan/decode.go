@@ -76,6 +76,7 @@ func Decode(d Decoder, st *ion.Symtab, buf []byte) (*Tree, error) {
 	out := &Tree{}
 	var sym ion.Symbol
 	var err error
+	var inputs []Input
 	for len(inner) > 0 {
 		sym, inner, err = ion.ReadLabel(inner)
 		if err != nil {
@@ -94,6 +95,19 @@ func Decode(d Decoder, st *ion.Symtab, buf []byte) (*Tree, error) {
 			if err != nil {
 				return nil, err
 			}
+		case "inputs":
+			err = unpackList(inner, func(field []byte) error {
+				in, err := decodeInput(field)
+				if err != nil {
+					return err
+				}
+				inputs = append(inputs, in)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("plan.Decode: decoding inputs: %w", err)
+			}
+			inner = inner[ion.SizeOf(inner):]
 		case "children":
 			err = unpackList(inner, func(field []byte) error {
 				tt, err := Decode(d, st, field)
@@ -118,6 +132,11 @@ func Decode(d Decoder, st *ion.Symtab, buf []byte) (*Tree, error) {
 	if out.Op == nil {
 		return nil, fmt.Errorf("plan.Decode: no Op field present")
 	}
+	if inputs != nil {
+		if err := out.setInputs(inputs); err != nil {
+			return nil, fmt.Errorf("plan.Decode: %w", err)
+		}
+	}
 	return out, nil
 }
 
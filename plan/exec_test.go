@@ -1071,6 +1071,18 @@ where Make in (
 				`{"Ticket": 1104820732, "nil": null}`,
 			},
 		},
+		{
+			// test ORDER BY clause without LIMIT;
+			// the split plan merges the sorted outputs
+			query: `select Ticket from 'parking.10n' order by Ticket`,
+			rows:  1023,
+			expectedRows: []string{
+				`{"Ticket": 1103341116}`,
+				`{"Ticket": 1103700150}`,
+				`{"Ticket": 1104803000}`,
+				`{"Ticket": 1104820732}`,
+			},
+		},
 		{
 			// test ORDER BY clause with LIMIT and OFFSET
 			query: `select Ticket from 'parking.10n' order by Ticket limit 2 offset 2`,
@@ -1470,6 +1482,10 @@ func testRemoteEquivalent(t *testing.T, tree *Tree,
 	if remoteerr != nil {
 		t.Errorf("remote error: %s", remoteerr)
 	}
+	// peak memory use and compilation
+	// time depend on scheduling
+	stats.PeakMemory = wantstat.PeakMemory
+	stats.CompileTime = wantstat.CompileTime
 	if stats != *wantstat {
 		t.Errorf("got stats %#v", &stats)
 		t.Errorf("wanted stats %#v", wantstat)
@@ -1540,6 +1556,8 @@ func testSplitEquivalent(t *testing.T, text string, e *testenv, expected []strin
 			t.Errorf("want: %#v", want)
 		}
 	}
+	stat.PeakMemory = wantstat.PeakMemory
+	stat.CompileTime = wantstat.CompileTime
 	if stat != *wantstat {
 		t.Errorf("got stats %#v", &stat)
 		t.Errorf("wanted stats %#v", wantstat)
@@ -1632,6 +1650,66 @@ func TestServerError(t *testing.T) {
 	}
 }
 
+func TestMemoryLimit(t *testing.T) {
+	env := &testenv{t: t}
+	defer env.clean()
+
+	queries := []string{
+		`select Make, count(*) from 'parking.10n' group by Make`,
+		`select Ticket from 'parking.10n' order by Ticket desc`,
+		`select distinct Color from 'parking.10n'`,
+		`select count(*) from 'parking.10n' where Make in (select Make from 'parking.10n' where Color = 'BK')`,
+	}
+	defer func(n int64) {
+		MemoryLimit = n
+	}(MemoryLimit)
+	for _, q := range queries {
+		t.Run(q, func(t *testing.T) {
+			s, err := partiql.Parse([]byte(q))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree, err := New(s, env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			MemoryLimit = 0
+			var stat ExecStats
+			err = Exec(tree, io.Discard, &stat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stat.PeakMemory <= 0 {
+				t.Fatalf("peak memory %d", stat.PeakMemory)
+			}
+			if stat.CompileTime <= 0 {
+				t.Fatalf("compile time %s", stat.CompileTime)
+			}
+			var buf ion.Buffer
+			stat.Marshal(&buf)
+			var dec ExecStats
+			err = dec.UnmarshalBinary(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dec != stat {
+				t.Fatalf("decoded %#v, want %#v", dec, stat)
+			}
+
+			MemoryLimit = stat.PeakMemory / 2
+			stat = ExecStats{}
+			err = Exec(tree, io.Discard, &stat)
+			var merr *vm.MemoryLimitError
+			if !errors.As(err, &merr) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if stat.PeakMemory > MemoryLimit {
+				t.Errorf("peak memory %d exceeds limit %d", stat.PeakMemory, MemoryLimit)
+			}
+		})
+	}
+}
+
 // nopSplitter is a splitter that
 // "splits" the sub-query into a single
 // sub-query that is executed locally
@@ -39,7 +39,12 @@ func (f *Filter) rewrite(rw expr.Rewriter) {
 
 func (f *Filter) exec(dst vm.QuerySink, parallel int, stats *ExecStats, rw TableRewrite) error {
 	push(f.Expr, f.From)
-	return f.From.exec(vm.NewFilter(f.Expr, dst), parallel, stats, rw)
+	filt, err := vm.NewFilter(f.Expr, dst)
+	if err != nil {
+		return err
+	}
+	filt.SetCompileTimer(stats.compile)
+	return f.From.exec(filt, parallel, stats, rw)
 }
 
 func (f *Filter) encode(dst *ion.Buffer, st *ion.Symtab) error {
@@ -0,0 +1,113 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package plan
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/expr/partiql"
+)
+
+// fnenv is a testenv with user-defined functions
+type fnenv struct {
+	*testenv
+	fns []*expr.Function
+}
+
+var _ FunctionResolver = (*fnenv)(nil)
+
+func (f *fnenv) Function(name string) (*expr.Function, error) {
+	for i := range f.fns {
+		if strings.EqualFold(f.fns[i].Name, name) {
+			return f.fns[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func TestUserFunctions(t *testing.T) {
+	env := &fnenv{testenv: &testenv{t: t}}
+	for _, def := range []string{
+		"CREATE FUNCTION is_black(c) AS c = 'BK'",
+		"CREATE FUNCTION fine_class(f) AS CASE WHEN f >= 50 THEN 'high' ELSE 'low' END",
+	} {
+		fn, err := partiql.ParseFunction([]byte(def))
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.fns = append(env.fns, fn)
+	}
+
+	tcs := []struct {
+		query, expanded string
+	}{
+		{
+			query:    `select count(*) from 'parking.10n' where is_black(Color)`,
+			expanded: `select count(*) from 'parking.10n' where Color = 'BK'`,
+		},
+		{
+			query:    `select FINE_CLASS(Fine) as class, count(*) from 'parking.10n' group by fine_class(Fine) order by class`,
+			expanded: `select CASE WHEN Fine >= 50 THEN 'high' ELSE 'low' END as class, count(*) from 'parking.10n' group by CASE WHEN Fine >= 50 THEN 'high' ELSE 'low' END order by class`,
+		},
+		{
+			query:    `with black as (select Make, Fine from 'parking.10n' where is_black(Color)) select count(*) from black where fine_class(Fine) = 'high'`,
+			expanded: `with black as (select Make, Fine from 'parking.10n' where Color = 'BK') select count(*) from black where CASE WHEN Fine >= 50 THEN 'high' ELSE 'low' END = 'high'`,
+		},
+	}
+	run := func(t *testing.T, q string, env Env) (*Tree, []byte) {
+		s, err := partiql.Parse([]byte(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := New(s, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		var stat ExecStats
+		err = Exec(tree, &out, &stat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tree, out.Bytes()
+	}
+	for i := range tcs {
+		t.Run(tcs[i].query, func(t *testing.T) {
+			gottree, got := run(t, tcs[i].query, env)
+			wanttree, want := run(t, tcs[i].expanded, env.testenv)
+			if gottree.String() != wanttree.String() {
+				t.Errorf("got plan\n%s", gottree.String())
+				t.Errorf("want plan\n%s", wanttree.String())
+			}
+			if !bytes.Equal(got, want) {
+				t.Error("output not equivalent to the expanded query")
+			}
+		})
+	}
+
+	// without a FunctionResolver, user-defined
+	// functions are not recognized
+	s, err := partiql.Parse([]byte(tcs[0].query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(s, env.testenv)
+	if err == nil {
+		t.Fatal("expected an error without functions")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package plan
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// Input is a side input to a Tree.
+// Sub-query results that are too large
+// to be interpolated into the query as
+// constants are shipped alongside the
+// query as Inputs, and they are referenced
+// from the query by ID using expr.SemiJoin.
+type Input struct {
+	ID  int
+	Set *expr.HashSet
+}
+
+type inputCollector struct {
+	inputs []Input
+}
+
+func (c *inputCollector) Walk(e expr.Node) expr.Rewriter {
+	return c
+}
+
+func (c *inputCollector) Rewrite(e expr.Node) expr.Node {
+	sj, ok := e.(*expr.SemiJoin)
+	if !ok || sj.Set == nil {
+		return e
+	}
+	for i := range c.inputs {
+		if c.inputs[i].ID == sj.ID {
+			return e
+		}
+	}
+	c.inputs = append(c.inputs, Input{ID: sj.ID, Set: sj.Set})
+	return e
+}
+
+// collectInputs returns the list of
+// Inputs referenced by op
+func collectInputs(op Op) []Input {
+	c := &inputCollector{}
+	op.rewrite(c)
+	return c.inputs
+}
+
+type inputBinder struct {
+	inputs []Input
+	err    error
+}
+
+func (b *inputBinder) Walk(e expr.Node) expr.Rewriter {
+	if b.err != nil {
+		return nil
+	}
+	return b
+}
+
+func (b *inputBinder) Rewrite(e expr.Node) expr.Node {
+	sj, ok := e.(*expr.SemiJoin)
+	if !ok || b.err != nil {
+		return e
+	}
+	for i := range b.inputs {
+		if b.inputs[i].ID == sj.ID {
+			sj.Set = b.inputs[i].Set
+			return e
+		}
+	}
+	b.err = fmt.Errorf("no input for %s", expr.ToString(sj))
+	return e
+}
+
+// setInputs sets t.Inputs and binds each
+// expr.SemiJoin in t.Op to its hash set
+func (t *Tree) setInputs(in []Input) error {
+	t.Inputs = in
+	b := &inputBinder{inputs: in}
+	t.Op.rewrite(b)
+	return b.err
+}
+
+// encode as [id, hashes]
+// where hashes is a blob of
+// little-endian 64-bit words
+func (i *Input) encode(dst *ion.Buffer) {
+	dst.BeginList(-1)
+	dst.WriteInt(int64(i.ID))
+	dst.WriteBlob(hashbytes(i.Set.Hashes))
+	dst.EndList()
+}
+
+func hashbytes(h []uint64) []byte {
+	buf := make([]byte, 8*len(h))
+	for i := range h {
+		binary.LittleEndian.PutUint64(buf[8*i:], h[i])
+	}
+	return buf
+}
+
+// appendHashes appends the little-endian
+// 64-bit words in buf to dst
+func appendHashes(dst []uint64, buf []byte) ([]uint64, error) {
+	if len(buf)%8 != 0 {
+		return dst, fmt.Errorf("hash set length %d not a multiple of 8", len(buf))
+	}
+	for len(buf) > 0 {
+		dst = append(dst, binary.LittleEndian.Uint64(buf))
+		buf = buf[8:]
+	}
+	return dst, nil
+}
+
+func decodeInput(body []byte) (Input, error) {
+	var in Input
+	lst, err := nonemptyList(body)
+	if err != nil {
+		return in, err
+	}
+	id, lst, err := ion.ReadInt(lst)
+	if err != nil {
+		return in, err
+	}
+	blob, _, err := ion.ReadBytesShared(lst)
+	if err != nil {
+		return in, err
+	}
+	in.ID = int(id)
+	in.Set = &expr.HashSet{}
+	in.Set.Hashes, err = appendHashes(nil, blob)
+	return in, err
+}
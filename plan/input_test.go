@@ -0,0 +1,165 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package plan
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/expr/partiql"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func TestSemiJoin(t *testing.T) {
+	env := &testenv{t: t}
+	defer env.clean()
+
+	queries := []string{
+		`select count(*) from 'parking.10n' where Make in (select Make from 'parking.10n' where Color = 'BK')`,
+		`select Ticket from 'parking.10n' where Ticket in (select Ticket from 'parking.10n' where Color = 'BK')`,
+		`select count(*) from 'nyc-taxi.block' where tpep_pickup_datetime in (select tpep_dropoff_datetime from 'nyc-taxi.block')`,
+	}
+	run := func(t *testing.T, q string) (*Tree, []byte) {
+		s, err := partiql.Parse([]byte(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := New(s, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		var stat ExecStats
+		err = Exec(tree, &out, &stat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tree, out.Bytes()
+	}
+	defer func(n int) {
+		maxLiteralRows = n
+	}(maxLiteralRows)
+	for _, q := range queries {
+		t.Run(q, func(t *testing.T) {
+			maxLiteralRows = 10000
+			tree, want := run(t, q)
+			if strings.Contains(tree.String(), "HASH_SET") {
+				t.Fatal("unexpected semi-join in plan")
+			}
+			maxLiteralRows = 10
+			tree, got := run(t, q)
+			if !strings.Contains(tree.String(), "HASH_SET") {
+				t.Fatalf("expected semi-join in plan:\n%s", tree.String())
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatal("semi-join output not equivalent to literal output")
+			}
+
+			// the rewritten part of the plan should
+			// be executable on its own with the hash
+			// set encoded inline or sent out-of-band
+			stub := &Tree{Op: tree.Op, Inputs: collectInputs(tree.Op)}
+			if len(stub.Inputs) != 1 {
+				t.Fatalf("got %d inputs", len(stub.Inputs))
+			}
+			var ib ion.Buffer
+			var st ion.Symtab
+			err := stub.Encode(&ib, &st)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec, err := Decode(env, &st, ib.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dec.Inputs[0].Set.Hashes, stub.Inputs[0].Set.Hashes) {
+				t.Fatal("decoded hashes not equivalent")
+			}
+			var out bytes.Buffer
+			var stat ExecStats
+			err = Exec(dec, &out, &stat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Fatal("decoded plan output not equivalent")
+			}
+			testRemoteEquivalent(t, stub, env, want, &stat)
+		})
+	}
+}
+
+func TestInputFrames(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	ins := []Input{
+		{ID: 1, Set: &expr.HashSet{Hashes: []uint64{1, 2, 3, 4, 5, 6, 7}}},
+		{ID: 3, Set: &expr.HashSet{}},
+	}
+	errc := make(chan error, 1)
+	go func() {
+		c := Client{Pipe: local}
+		for i := range ins {
+			err := c.sendInput(&ins[i], 3)
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+		errc <- nil
+	}()
+	s := &server{rd: bufio.NewReader(remote)}
+	// 7 hashes in chunks of 3, plus an
+	// empty frame for the empty set
+	for i := 0; i < 4; i++ {
+		f, err := s.frame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.kind() != frameinput {
+			t.Fatalf("frame %d: unexpected kind %d", i, f.kind())
+		}
+		buf, err := s.readn(f.length())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.addInput(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(s.inputs) != 2 {
+		t.Fatalf("got %d inputs", len(s.inputs))
+	}
+	for i := range ins {
+		if s.inputs[i].ID != ins[i].ID {
+			t.Errorf("input %d: got ID %d, want %d", i, s.inputs[i].ID, ins[i].ID)
+		}
+		if len(s.inputs[i].Set.Hashes) != len(ins[i].Set.Hashes) {
+			t.Errorf("input %d: got %v, want %v", i, s.inputs[i].Set.Hashes, ins[i].Set.Hashes)
+		} else if len(ins[i].Set.Hashes) > 0 && !reflect.DeepEqual(s.inputs[i].Set.Hashes, ins[i].Set.Hashes) {
+			t.Errorf("input %d: got %v, want %v", i, s.inputs[i].Set.Hashes, ins[i].Set.Hashes)
+		}
+	}
+}
@@ -197,9 +197,32 @@ func lowerOrder(in *pir.Order, from Op) (Op, error) {
 	return &OrderBy{
 		Nonterminal: Nonterminal{From: from},
 		Columns:     columns,
+		Merge:       mergesSorted(from, columns),
 	}, nil
 }
 
+// mergesSorted returns true if from is a UnionMap
+// whose sub-queries already produce their output
+// in the order given by columns
+func mergesSorted(from Op, columns []OrderByColumn) bool {
+	u, ok := from.(*UnionMap)
+	if !ok {
+		return false
+	}
+	o, ok := u.From.(*OrderBy)
+	if !ok || len(o.Columns) != len(columns) {
+		return false
+	}
+	for i := range columns {
+		if !expr.Equivalent(o.Columns[i].Node, columns[i].Node) ||
+			o.Columns[i].Desc != columns[i].Desc ||
+			o.Columns[i].NullsLast != columns[i].NullsLast {
+			return false
+		}
+	}
+	return true
+}
+
 func lowerBind(in *pir.Bind, from Op) (Op, error) {
 	return (&Project{
 		Nonterminal: Nonterminal{From: from},
@@ -455,6 +478,22 @@ func (e pirenv) Index(tbl expr.Node) (pir.Index, error) {
 	return index(idx, tbl)
 }
 
+func (e pirenv) Function(name string) (*expr.Function, error) {
+	fr, ok := e.env.(FunctionResolver)
+	if !ok {
+		return nil, nil
+	}
+	return fr.Function(name)
+}
+
+func (e pirenv) View(tbl expr.Node) (*expr.View, error) {
+	vr, ok := e.env.(ViewResolver)
+	if !ok {
+		return nil, nil
+	}
+	return vr.View(tbl)
+}
+
 // New creates a new Tree from raw query AST.
 func New(q *expr.Query, env Env) (*Tree, error) {
 	return NewSplit(q, env, nil)
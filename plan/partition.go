@@ -206,8 +206,18 @@ const (
 	framedata // output query data
 	frameerr  // query encountered an error
 	framefin  // no more query data
+
+	// client-to-server frames
+	// that precede framestart
+	frameinput // part of a side input
 )
 
+// inputchunk is the maximum number of
+// hashes in a single frameinput frame;
+// each frame holds a 4-byte input ID
+// followed by the hashes
+const inputchunk = (maxframe - 4) / 8
+
 func (f frame) kind() framekind {
 	return framekind(f >> 24)
 }
@@ -232,6 +242,9 @@ type server struct {
 	st  ion.Symtab
 	tmp []byte
 
+	// inputs received for the next query
+	inputs []Input
+
 	outlock   sync.Mutex
 	writeFail bool
 }
@@ -259,6 +272,7 @@ func Serve(rw io.ReadWriteCloser, dec Decoder) error {
 	}
 	s.dec = dec
 	s.st.Reset()
+	s.inputs = nil
 	err := s.serve()
 	serverPool.Put(s)
 	return err
@@ -323,6 +337,17 @@ func (s *server) serve() error {
 			}
 			return err
 		}
+		if f.kind() == frameinput {
+			buf, err := s.readn(f.length())
+			if err == nil {
+				err = s.addInput(buf)
+			}
+			if err != nil {
+				s.senderr(err.Error())
+				return fmt.Errorf("reading input frame: %w", err)
+			}
+			continue
+		}
 		if f.kind() != framestart {
 			s.senderr("unexpected frame")
 			return fmt.Errorf("received unexpected frame %x", f)
@@ -333,6 +358,7 @@ func (s *server) serve() error {
 			return fmt.Errorf("reading start frame: %w", err)
 		}
 		err = s.runQuery(buf)
+		s.inputs = nil
 		if err != nil {
 			s.senderr(err.Error())
 			// note: we don't close the connection here;
@@ -404,6 +430,29 @@ func (s *server) fin(stat *ExecStats) error {
 	return err
 }
 
+// addInput adds the contents of
+// a frameinput frame to s.inputs
+func (s *server) addInput(buf []byte) error {
+	if len(buf) < 4 {
+		return fmt.Errorf("input frame too short (%d bytes)", len(buf))
+	}
+	id := int(binary.LittleEndian.Uint32(buf))
+	var set *expr.HashSet
+	for i := range s.inputs {
+		if s.inputs[i].ID == id {
+			set = s.inputs[i].Set
+			break
+		}
+	}
+	if set == nil {
+		set = &expr.HashSet{}
+		s.inputs = append(s.inputs, Input{ID: id, Set: set})
+	}
+	var err error
+	set.Hashes, err = appendHashes(set.Hashes, buf[4:])
+	return err
+}
+
 func (s *server) runQuery(buf []byte) error {
 	s.st.Reset()
 	var err error
@@ -417,6 +466,12 @@ func (s *server) runQuery(buf []byte) error {
 	if err != nil {
 		return err
 	}
+	if len(s.inputs) > 0 {
+		err = t.setInputs(s.inputs)
+		if err != nil {
+			return err
+		}
+	}
 	var stat ExecStats
 	err = Exec(t, s, &stat)
 	if err != nil {
@@ -481,6 +536,19 @@ func (c *Client) Close() error {
 }
 
 func (c *Client) send(t *Tree, rw TableRewrite) error {
+	if len(t.Inputs) > 0 {
+		// send inputs as separate frames
+		// rather than as part of the plan
+		for i := range t.Inputs {
+			err := c.sendInput(&t.Inputs[i], inputchunk)
+			if err != nil {
+				return err
+			}
+		}
+		cp := *t
+		cp.Inputs = nil
+		t = &cp
+	}
 	err := t.EncodePart(&c.iob, &c.st, rw)
 	if err != nil {
 		return fmt.Errorf("plan.Client.Exec: encoding plan: %w", err)
@@ -505,6 +573,34 @@ func (c *Client) send(t *Tree, rw TableRewrite) error {
 	return err
 }
 
+// sendInput writes in to c.Pipe as a sequence
+// of frameinput frames containing up to chunk hashes
+func (c *Client) sendInput(in *Input, chunk int) error {
+	var hdr [framesize + 4]byte
+	h := in.Set.Hashes
+	for first := true; first || len(h) > 0; first = false {
+		n := len(h)
+		if n > chunk {
+			n = chunk
+		}
+		mkframe(frameinput, 4+8*n).put(hdr[:])
+		binary.LittleEndian.PutUint32(hdr[framesize:], uint32(in.ID))
+		_, err := c.Pipe.Write(hdr[:])
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		_, err = c.Pipe.Write(hashbytes(h[:n]))
+		if err != nil {
+			return err
+		}
+		h = h[n:]
+	}
+	return nil
+}
+
 func (c *Client) next() (frame, error) {
 	if c.valid < framesize {
 		n, err := io.ReadAtLeast(c.Pipe, c.tmp[c.valid:], framesize-c.valid)
@@ -140,8 +140,32 @@ type Index interface {
 	TimeRange(path *expr.Path) (min, max date.Time, ok bool)
 }
 
+// FunctionEnv is an interface that may
+// optionally be implemented by an Env
+// to provide user-defined functions.
+type FunctionEnv interface {
+	// Function returns the user-defined function
+	// with the given name, or (nil, nil) if there
+	// is no function with that name.
+	Function(name string) (*expr.Function, error)
+}
+
+// ViewEnv is an interface that may
+// optionally be implemented by an Env
+// to provide views.
+type ViewEnv interface {
+	// View returns the view referenced by the
+	// table expression tbl, or (nil, nil) if
+	// tbl does not refer to a view.
+	View(tbl expr.Node) (*expr.View, error)
+}
+
 // Build walks the provided Query
 // and lowers it into the optimized query IR.
+// If e implements ViewEnv, references to views
+// are replaced with the queries that define them,
+// and if e implements FunctionEnv, calls to
+// user-defined functions are expanded.
 // If the provided SchemaHint is non-nil,
 // then it will be used to provide additional
 // type information that can be used to type-check
@@ -155,6 +179,21 @@ func Build(q *expr.Query, e Env) (*Trace, error) {
 			return nil, err
 		}
 	}
+	// views are expanded after CTE replacement
+	// so that CTE bindings do not apply to
+	// the tables referenced within views
+	if ve, ok := e.(ViewEnv); ok {
+		body, err = expr.ExpandViews(body, ve.View)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if fe, ok := e.(FunctionEnv); ok {
+		body, err = expr.Inline(body, fe.Function)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if sel, ok := body.(*expr.Select); ok {
 		t, err := build(nil, sel, e)
 		if err != nil {
@@ -501,12 +540,13 @@ func (h *hoistwalk) rewriteInSubquery(b *expr.Builtin) expr.Node {
 		h.in = append(h.in, t)
 		repl := expr.Call("SCALAR_REPLACEMENT", expr.Integer(index))
 		return expr.Compare(expr.Equals, b.Args[0], repl)
-	case SizeExactSmall, SizeColumnCardinality:
+	default:
+		// results larger than LargeSize are
+		// turned into a hash set (rather than a list
+		// of constants) during execution, so there
+		// is no upper bound on the sub-query size
 		h.in = append(h.in, t)
 		return expr.Call("IN_REPLACEMENT", b.Args[0], expr.Integer(index))
-	default:
-		h.err = errorf(b.Args[1].(*expr.Select), "sub-query cardinality too large: %s", b.Args[1])
-		return b
 	}
 }
 
@@ -100,10 +100,6 @@ func TestBuildError(t *testing.T) {
 			input:  `select xthree+ythree from (select xtwo as xthree, ytwo as ythree from (select x as xtwo, y as ytwo from table))`,
 			rx:     `ill-typed`,
 		},
-		{
-			input: `select x, y, z from foo order by x`,
-			rx:    "unlimited cardinality",
-		},
 		{
 			input: `select * from tbl limit -1`,
 			rx:    "negative limit -1 not supported",
@@ -116,6 +112,14 @@ func TestBuildError(t *testing.T) {
 			input: `select * from tbl offset 15`,
 			rx:    "OFFSET without LIMIT is not supported",
 		},
+		{
+			input: `select * from tbl where [x, y] = [1, 2]`,
+			rx:    "only supported as projected values",
+		},
+		{
+			input: `select count(*) from tbl group by {'x': x}`,
+			rx:    "only supported as projected values",
+		},
 	}
 	for i := range tests {
 		in := tests[i].input
@@ -298,6 +302,19 @@ func TestBuild(t *testing.T) {
 				"AGGREGATE SUM($_0_0) AS \"sum\"",
 			},
 		},
+		{
+			// no LIMIT in the sub-query: large results
+			// are evaluated as a semi-join at execution time
+			input: `select sum(x) from foo where y in (select y from bar)`,
+			expect: []string{
+				"WITH (",
+				"	ITERATE bar",
+				"	PROJECT y AS y",
+				") AS REPLACEMENT(0)",
+				"ITERATE foo WHERE IN_REPLACEMENT(y, 0)",
+				"AGGREGATE SUM(x) AS \"sum\"",
+			},
+		},
 		{
 			input:  "select x, count(x) from foo group by x",
 			schema: mkschema("x", stringType),
@@ -676,6 +693,24 @@ where out.Make = 'CHRY' and entry.BodyStyle = 'PA'`,
 				"LIMIT 10 OFFSET 64",
 			},
 		},
+		{
+			// unlimited ORDER BY: each of the mapping
+			// steps sorts its own output and the
+			// reduction step merges the results
+			input: `select x, y from foo order by x, y desc`,
+			expect: []string{
+				"ITERATE foo",
+				"PROJECT x AS x, y AS y",
+				"ORDER BY x ASC NULLS FIRST, y DESC NULLS FIRST",
+			},
+			split: []string{
+				"UNION MAP foo (",
+				"	ITERATE PART foo",
+				"	PROJECT x AS x, y AS y",
+				"	ORDER BY x ASC NULLS FIRST, y DESC NULLS FIRST)",
+				"ORDER BY x ASC NULLS FIRST, y DESC NULLS FIRST",
+			},
+		},
 		{
 			input: `select x, count(*) from foo group by x limit 10 offset 64`,
 			expect: []string{
@@ -101,7 +101,10 @@ func splitOne(s Step, mapping, reduce *Trace) (bool, error) {
 		// from each of the mapping steps
 		if lim, ok := s.(*Limit); ok && fusesLimit(reduce.top) {
 			if _, ok := reduce.top.parent().(*UnionMap); ok {
-				if ord, ok := reduce.top.(*Order); ok {
+				// (the mapping step may already end with
+				// the same ORDER BY; see the *Order case below)
+				_, sorted := mapping.top.(*Order)
+				if ord, ok := reduce.top.(*Order); ok && !sorted {
 					nord := ord.clone()
 					nord.setparent(mapping.top)
 					mapping.top = nord
@@ -149,9 +152,13 @@ func splitOne(s Step, mapping, reduce *Trace) (bool, error) {
 		// no longer in mapping step
 		return false, nil
 	case *Order:
-		mapping.top = par
-		n.setparent(reduce.top)
-		reduce.top = n
+		// sort in the mapping step as well
+		// so that the reduction step only
+		// has to merge the sorted outputs
+		mapping.top = n
+		o2 := n.clone()
+		o2.setparent(reduce.top)
+		reduce.top = o2
 		return false, nil
 	case *Aggregate:
 		return false, reduceAggregate(n, mapping, reduce)
@@ -14,17 +14,67 @@
 
 package pir
 
+import (
+	"github.com/SnellerInc/sneller/expr"
+)
+
+// rules are checks that are applied
+// to a Trace once it has been built
 var rules = []func(t *Trace) error{
-	checkSortSize,
+	checkConstructors,
+}
+
+func isConstructor(e expr.Node) bool {
+	b, ok := e.(*expr.Builtin)
+	return ok && (b.Func == expr.MakeStruct || b.Func == expr.MakeList)
+}
+
+// checkConstructors checks that struct and
+// list constructors only appear as the
+// (possibly nested) value of a projected binding;
+// paths into constructed values are not
+// resolved against the constructor yet
+func checkConstructors(t *Trace) error {
+	for i := range t.Inputs {
+		if err := checkConstructors(t.Inputs[i]); err != nil {
+			return err
+		}
+	}
+	var err error
+	for s := t.top; s != nil && err == nil; s = s.parent() {
+		_, project := s.(*Bind)
+		s.rewrite(func(e expr.Node, _ bool) expr.Node {
+			if err == nil {
+				err = checkConstructor(e, project)
+			}
+			return e
+		})
+	}
+	return err
 }
 
-func checkSortSize(t *Trace) error {
-	f, ok := t.Final().(*Order)
-	if !ok {
+func checkConstructor(e expr.Node, projected bool) error {
+	if projected && isConstructor(e) {
+		for _, arg := range e.(*expr.Builtin).Args {
+			if err := checkConstructor(arg, true); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
-	if c := t.Class(); c > SizeColumnCardinality {
-		return errorf(f.Columns[0].Column, "cannot perform ORDER BY with unlimited cardinality")
+	var bad expr.Node
+	expr.Walk(visitfn(func(e expr.Node) bool {
+		if bad != nil {
+			return false
+		}
+		if isConstructor(e) {
+			bad = e
+			return false
+		}
+		return true
+	}), e)
+	if bad != nil {
+		return errorf(bad, "%s: struct and list constructors are only supported as projected values", expr.ToString(bad))
 	}
 	return nil
 }
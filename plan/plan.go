@@ -170,6 +170,34 @@ type Indexer interface {
 	Index(expr.Node) (Index, error)
 }
 
+// FunctionResolver is an interface
+// optionally implemented by an Env
+// to provide user-defined functions.
+// Calls to user-defined functions are
+// replaced with the function bodies
+// before the query is planned.
+type FunctionResolver interface {
+	// Function returns the user-defined function
+	// with the given name, or (nil, nil) if there
+	// is no function with that name.
+	// Function names are case-insensitive.
+	Function(name string) (*expr.Function, error)
+}
+
+// ViewResolver is an interface
+// optionally implemented by an Env
+// to provide views. References to views
+// are replaced with the queries that
+// define them before the query is planned,
+// so the tables referenced within a view
+// are resolved by the same Env.
+type ViewResolver interface {
+	// View returns the view referenced by
+	// the table expression tbl, or (nil, nil)
+	// if tbl does not refer to a view.
+	View(tbl expr.Node) (*expr.View, error)
+}
+
 // An Index may be returned by Indexer.Index to provide
 // additional table metadata that may be used during
 // optimization.
@@ -270,6 +298,7 @@ func (s *SimpleAggregate) exec(dst vm.QuerySink, parallel int, stats *ExecStats,
 	if err != nil {
 		return err
 	}
+	a.SetCompileTimer(stats.compile)
 	return s.From.exec(a, parallel, stats, rw)
 }
 
@@ -726,6 +755,8 @@ func (h *HashAggregate) exec(dst vm.QuerySink, parallel int, stats *ExecStats, r
 	if err != nil {
 		return err
 	}
+	ha.SetBudget(stats.budget)
+	ha.SetCompileTimer(stats.compile)
 	if h.Limit > 0 {
 		ha.Limit(h.Limit)
 	}
@@ -754,6 +785,10 @@ type OrderBy struct {
 	Columns []OrderByColumn
 	Limit   int
 	Offset  int
+	// Merge is set when each of the streams
+	// produced by From is already sorted,
+	// so they only have to be merged
+	Merge bool
 }
 
 func (o *OrderBy) rewrite(rw expr.Rewriter) {
@@ -765,6 +800,9 @@ func (o *OrderBy) rewrite(rw expr.Rewriter) {
 
 func (o *OrderBy) String() string {
 	s := "ORDER BY "
+	if o.Merge {
+		s = "MERGE ORDER BY "
+	}
 	for i, column := range o.Columns {
 		if i > 0 {
 			s += ", "
@@ -832,6 +870,11 @@ func (o *OrderBy) exec(dst vm.QuerySink, parallel int, stats *ExecStats, rw Tabl
 	}
 
 	sorter := vm.NewOrder(writer, orderBy, limit, parallel)
+	sorter.SetBudget(stats.budget)
+	sorter.SetCompileTimer(stats.compile)
+	if o.Merge {
+		sorter.MergeSorted()
+	}
 
 	return o.From.exec(sorter, parallel, stats, rw)
 }
@@ -859,6 +902,10 @@ func (o *OrderBy) encode(dst *ion.Buffer, st *ion.Symtab) error {
 		dst.BeginField(st.Intern("offset"))
 		dst.WriteInt(int64(o.Offset))
 	}
+	if o.Merge {
+		dst.BeginField(st.Intern("merge"))
+		dst.WriteBool(true)
+	}
 
 	dst.EndStruct()
 	return nil
@@ -901,6 +948,12 @@ func (o *OrderBy) setfield(d Decoder, name string, st *ion.Symtab, buf []byte) e
 			return err
 		}
 		o.Offset = int(i)
+	case "merge":
+		b, _, err := ion.ReadBool(buf)
+		if err != nil {
+			return err
+		}
+		o.Merge = b
 	}
 	return nil
 }
@@ -923,6 +976,8 @@ func (d *Distinct) exec(dst vm.QuerySink, parallel int, stats *ExecStats, rw Tab
 	if err != nil {
 		return err
 	}
+	df.SetBudget(stats.budget)
+	df.SetCompileTimer(stats.compile)
 	if d.Limit > 0 {
 		df.Limit(d.Limit)
 	}
@@ -1031,6 +1086,14 @@ func (t *Tree) EncodePart(dst *ion.Buffer, st *ion.Symtab, rw TableRewrite) erro
 		}
 		dst.EndList()
 	}
+	if len(t.Inputs) > 0 {
+		dst.BeginField(st.Intern("inputs"))
+		dst.BeginList(-1)
+		for i := range t.Inputs {
+			t.Inputs[i].encode(dst)
+		}
+		dst.EndList()
+	}
 	dst.BeginField(st.Intern("op"))
 	dst.BeginList(-1)
 	err := encoderec(t.Op, dst, st, rw)
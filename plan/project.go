@@ -37,7 +37,9 @@ func (p *Project) rewrite(rw expr.Rewriter) {
 }
 
 func (p *Project) exec(dst vm.QuerySink, parallel int, stats *ExecStats, rw TableRewrite) error {
-	return p.From.exec(vm.NewProjection(vm.Selection(p.Using), dst), parallel, stats, rw)
+	proj := vm.NewProjection(vm.Selection(p.Using), dst)
+	proj.SetCompileTimer(stats.compile)
+	return p.From.exec(proj, parallel, stats, rw)
 }
 
 func (p *Project) encode(dst *ion.Buffer, st *ion.Symtab) error {
@@ -17,11 +17,22 @@ package plan
 import (
 	"io"
 	"runtime"
+	"sync/atomic"
 
 	"github.com/SnellerInc/sneller/ion"
 	"github.com/SnellerInc/sneller/vm"
 )
 
+// MemoryLimit is the maximum number of bytes
+// that a query executed locally may reserve
+// for hash tables, sort buffers, and sub-query
+// results (see vm.Budget). Queries that exceed
+// the limit fail with a *vm.MemoryLimitError,
+// unless the operator that exceeded the limit
+// is able to spill its state to disk (see vm.SpillDir).
+// A MemoryLimit <= 0 means there is no limit.
+var MemoryLimit int64
+
 // Exec executes a plan and writes the
 // results of the query execution to dst.
 func Exec(t *Tree, dst io.Writer, stats *ExecStats) error {
@@ -55,6 +66,21 @@ func (l *LocalTransport) Exec(t *Tree, rw TableRewrite, dst io.Writer, stats *Ex
 	if parallel <= 0 {
 		parallel = runtime.GOMAXPROCS(0)
 	}
+	// the outermost local execution owns the
+	// budget; sub-queries executed in the same
+	// process through a UnionMap share it
+	if stats.budget == nil {
+		b := &vm.Budget{Limit: MemoryLimit}
+		c := &vm.CompileTimer{}
+		stats.budget = b
+		stats.compile = c
+		defer func() {
+			stats.budget = nil
+			stats.compile = nil
+			atomic.AddInt64(&stats.PeakMemory, b.Peak())
+			atomic.AddInt64((*int64)(&stats.CompileTime), int64(c.Elapsed()))
+		}()
+	}
 	return t.exec(s, parallel, stats, rw)
 }
 
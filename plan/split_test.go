@@ -72,6 +72,16 @@ func TestSplit(t *testing.T) {
 				"AGGREGATE SUM_COUNT($_0_0) AS \"count\"",
 			},
 		},
+		{
+			query: `SELECT x FROM foo ORDER BY x`,
+			lines: []string{
+				"foo",
+				"PROJECT x AS x",
+				"ORDER BY x ASC NULLS FIRST",
+				"UNION MAP foo [foo-part1 foo-part2]",
+				"MERGE ORDER BY x ASC NULLS FIRST",
+			},
+		},
 	}
 
 	for i := range tcs {
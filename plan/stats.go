@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"sync/atomic"
+	"time"
 
 	"github.com/SnellerInc/sneller/ion"
 	"github.com/SnellerInc/sneller/vm"
@@ -39,6 +40,24 @@ type ExecStats struct {
 	// BytesScanned is the number
 	// of bytes scanned.
 	BytesScanned int64
+	// PeakMemory is the peak number of bytes
+	// reserved by the query (see vm.Budget).
+	// When a query is split across multiple
+	// machines, PeakMemory is the sum of the
+	// peak memory used on each machine.
+	PeakMemory int64
+	// CompileTime is the time spent compiling
+	// bytecode for the query (see vm.CompileTimer).
+	// When a query is split across multiple machines,
+	// CompileTime is the sum of the time spent on each.
+	CompileTime time.Duration
+
+	// budget is the memory budget for
+	// the query that is being executed locally
+	budget *vm.Budget
+	// compile records the compilation time
+	// of the query that is being executed locally
+	compile *vm.CompileTimer
 }
 
 // CachedTable is an interface optionally
@@ -57,6 +76,8 @@ func (e *ExecStats) atomicAdd(tmp *ExecStats) {
 	atomic.AddInt64(&e.CacheHits, tmp.CacheHits)
 	atomic.AddInt64(&e.CacheMisses, tmp.CacheMisses)
 	atomic.AddInt64(&e.BytesScanned, tmp.BytesScanned)
+	atomic.AddInt64(&e.PeakMemory, tmp.PeakMemory)
+	atomic.AddInt64((*int64)(&e.CompileTime), int64(tmp.CompileTime))
 }
 
 func (e *ExecStats) observe(table vm.Table) {
@@ -136,6 +157,14 @@ func (e *ExecStats) Encode(dst *ion.Buffer, st *ion.Symtab) {
 		dst.BeginField(st.Intern("scanned"))
 		dst.WriteInt(e.BytesScanned)
 	}
+	if e.PeakMemory != 0 {
+		dst.BeginField(st.Intern("memory"))
+		dst.WriteInt(e.PeakMemory)
+	}
+	if e.CompileTime != 0 {
+		dst.BeginField(st.Intern("compile"))
+		dst.WriteInt(int64(e.CompileTime))
+	}
 	dst.EndStruct()
 }
 
@@ -164,6 +193,12 @@ func (e *ExecStats) Decode(buf []byte, st *ion.Symtab) error {
 			e.CacheMisses, inner, err = ion.ReadInt(inner)
 		case "scanned":
 			e.BytesScanned, inner, err = ion.ReadInt(inner)
+		case "memory":
+			e.PeakMemory, inner, err = ion.ReadInt(inner)
+		case "compile":
+			var ns int64
+			ns, inner, err = ion.ReadInt(inner)
+			e.CompileTime = time.Duration(ns)
 		default:
 			inner = inner[ion.SizeOf(inner):]
 		}
@@ -188,6 +223,8 @@ func init() {
 		"hits",
 		"misses",
 		"scanned",
+		"memory",
+		"compile",
 	} {
 		statsSymtab.Intern(s)
 	}
@@ -17,17 +17,114 @@ package plan
 import (
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 
 	"github.com/SnellerInc/sneller/expr"
 	"github.com/SnellerInc/sneller/ion"
 	"github.com/SnellerInc/sneller/plan/pir"
+	"github.com/SnellerInc/sneller/vm"
 )
 
+// maxLiteralRows is the maximum number of
+// sub-query rows that are interpolated into
+// the outer query as constants; larger results
+// are only usable as the right-hand-side of IN
+// and are converted to an expr.HashSet
+var maxLiteralRows = pir.LargeSize
+
 type replacement struct {
 	lock sync.Mutex
 
 	rows []ion.Struct
+
+	// once len(rows) exceeds maxLiteralRows,
+	// hashed is set and rows are accumulated
+	// into hashes instead
+	hashed bool
+	hashes []uint64
+	count  int
+	set    *expr.HashSet
+	tmp    ion.Buffer
+	st     ion.Symtab
+
+	// size is the number of bytes
+	// reserved from budget for the
+	// buffered rows or hashes
+	budget *vm.Budget
+	size   int64
+}
+
+// reserve updates the number of bytes
+// reserved for r to size
+func (r *replacement) reserve(size int64) error {
+	if size < r.size {
+		r.budget.Shrink(r.size - size)
+	} else if err := r.budget.Grow(size - r.size); err != nil {
+		return err
+	}
+	r.size = size
+	return nil
+}
+
+func (r *replacement) release() {
+	r.budget.Shrink(r.size)
+	r.size = 0
+}
+
+// addHash adds the hash of the only
+// field in row to r.hashes and returns
+// false if the row cannot be hashed
+func (r *replacement) addHash(row *ion.Struct) bool {
+	if len(row.Fields) == 0 {
+		return true // see toScalarList
+	}
+	if len(row.Fields) != 1 {
+		return false
+	}
+	v, ok := expr.AsConstant(row.Fields[0].Value)
+	if !ok {
+		return false
+	}
+	switch v.(type) {
+	case *expr.Struct, *expr.List:
+		// the encoding of these depends on
+		// the symbol table that is in use
+		return false
+	}
+	r.tmp.Reset()
+	v.Datum().Encode(&r.tmp, &r.st)
+	r.hashes = append(r.hashes, vm.MemberHash(r.tmp.Bytes()))
+	return true
+}
+
+// toHashes converts r.rows into r.hashes
+func (r *replacement) toHashes() bool {
+	r.hashed = true
+	for i := range r.rows {
+		if !r.addHash(&r.rows[i]) {
+			return false
+		}
+	}
+	r.rows = nil
+	return true
+}
+
+func (r *replacement) toSemiJoin(x expr.Node, id int) *expr.SemiJoin {
+	if r.set == nil {
+		h := r.hashes
+		sort.Slice(h, func(i, j int) bool {
+			return h[i] < h[j]
+		})
+		out := h[:0]
+		for i := range h {
+			if i == 0 || h[i] != h[i-1] {
+				out = append(out, h[i])
+			}
+		}
+		r.set = &expr.HashSet{Hashes: out}
+	}
+	return &expr.SemiJoin{Arg: x, ID: id, Set: r.set}
 }
 
 func (r *replacement) toScalar() (expr.Constant, bool) {
@@ -251,12 +348,28 @@ func (s *subreplacement) Write(buf []byte) (int, error) {
 	}
 	s.parent.lock.Lock()
 	defer s.parent.lock.Unlock()
-	s.parent.rows = append(s.parent.rows, s.tmp...)
+	p := s.parent
+	p.count += len(s.tmp)
+	if p.hashed {
+		for i := range s.tmp {
+			if !p.addHash(&s.tmp[i]) {
+				return orig, fmt.Errorf("%d items in subreplacement exceeds limit", p.count)
+			}
+		}
+		s.tmp = s.tmp[:0]
+		return orig, p.reserve(8 * int64(len(p.hashes)))
+	}
+	p.rows = append(p.rows, s.tmp...)
 	s.tmp = s.tmp[:0]
-	if len(s.parent.rows) > pir.LargeSize {
-		return orig, fmt.Errorf("%d items in supreplacement exceeds limit", len(s.parent.rows))
+	if len(p.rows) > maxLiteralRows {
+		if !p.toHashes() {
+			return orig, fmt.Errorf("%d items in subreplacement exceeds limit", p.count)
+		}
+		return orig, p.reserve(8 * int64(len(p.hashes)))
 	}
-	return orig, nil
+	// the decoded rows take up roughly
+	// as much space as their encoding
+	return orig, p.reserve(p.size + int64(orig))
 }
 
 func (s *subreplacement) Close() error {
@@ -305,6 +418,17 @@ func (r *replacer) Walk(e expr.Node) expr.Rewriter {
 	return r
 }
 
+// literal returns the replacement with the given id
+// if it can be interpolated as a constant
+func (r *replacer) literal(id int) (*replacement, bool) {
+	in := &r.inputs[id]
+	if in.hashed {
+		r.err = fmt.Errorf("%d items in subreplacement exceeds limit", in.count)
+		return nil, false
+	}
+	return in, true
+}
+
 func (r *replacer) Rewrite(e expr.Node) expr.Node {
 	b, ok := e.(*expr.Builtin)
 	if !ok {
@@ -315,15 +439,21 @@ func (r *replacer) Rewrite(e expr.Node) expr.Node {
 		return r.simplify(e)
 	case expr.ListReplacement:
 		r.rewrote = true
-		id := int(b.Args[0].(expr.Integer))
-		value, ok := r.inputs[id].toList()
+		in, ok := r.literal(int(b.Args[0].(expr.Integer)))
 		if !ok {
-			r.err = fmt.Errorf("cannot interpolate value %v as constant", r.inputs[id].rows)
+			return e
+		}
+		value, ok := in.toList()
+		if !ok {
+			r.err = fmt.Errorf("cannot interpolate value %v as constant", in.rows)
 		}
 		return value
 	case expr.InReplacement:
 		r.rewrote = true
 		id := int(b.Args[1].(expr.Integer))
+		if r.inputs[id].hashed {
+			return r.inputs[id].toSemiJoin(b.Args[0], id)
+		}
 		lst, ok := r.inputs[id].toScalarList()
 		if !ok {
 			r.err = fmt.Errorf("cannot interpolate %v as const list", r.inputs[id].rows)
@@ -335,30 +465,39 @@ func (r *replacer) Rewrite(e expr.Node) expr.Node {
 		}
 	case expr.HashReplacement:
 		r.rewrote = true
-		id := int(b.Args[0].(expr.Integer))
+		in, ok := r.literal(int(b.Args[0].(expr.Integer)))
+		if !ok {
+			return e
+		}
 		kind := string(b.Args[1].(expr.String))
 		label := string(b.Args[2].(expr.String))
-		fn, ok := r.inputs[id].toHashLookup(kind, label, b.Args[3])
+		fn, ok := in.toHashLookup(kind, label, b.Args[3])
 		if !ok {
-			r.err = fmt.Errorf("cannot interpolate %v as case", r.inputs[id].rows)
+			r.err = fmt.Errorf("cannot interpolate %v as case", in.rows)
 			return e
 		}
 		return fn
 	case expr.StructReplacement:
 		r.rewrote = true
-		id := int(b.Args[0].(expr.Integer))
-		value, ok := r.inputs[id].toStruct()
+		in, ok := r.literal(int(b.Args[0].(expr.Integer)))
 		if !ok {
-			r.err = fmt.Errorf("cannot interpolate %v as a structure", r.inputs[id].rows)
+			return e
+		}
+		value, ok := in.toStruct()
+		if !ok {
+			r.err = fmt.Errorf("cannot interpolate %v as a structure", in.rows)
 			return e
 		}
 		return value
 	case expr.ScalarReplacement:
 		r.rewrote = true
-		id := int(b.Args[0].(expr.Integer))
-		value, ok := r.inputs[id].toScalar()
+		in, ok := r.literal(int(b.Args[0].(expr.Integer)))
+		if !ok {
+			return e
+		}
+		value, ok := in.toScalar()
 		if !ok {
-			r.err = fmt.Errorf("cannot interpolate value %v as a constant", r.inputs[id].rows)
+			r.err = fmt.Errorf("cannot interpolate value %v as a constant", in.rows)
 			return e
 		}
 		return value
@@ -63,6 +63,14 @@ type Tree struct {
 	// and the terminal element of the list
 	// is the first in execution order.
 	Op Op
+
+	// Inputs is the list of hash sets
+	// referenced by semi-joins in Op.
+	// Inputs are only present in Trees
+	// that are constructed from part of a
+	// query that has already had its
+	// sub-queries evaluated.
+	Inputs []Input
 }
 
 func tabify(n int, dst *strings.Builder) {
@@ -113,6 +121,14 @@ func (t *Tree) exec(dst vm.QuerySink, parallel int, stats *ExecStats, rw TableRe
 	var wg sync.WaitGroup
 	wg.Add(len(t.Children))
 	rp := make([]replacement, len(t.Children))
+	for i := range rp {
+		rp[i].budget = stats.budget
+	}
+	defer func() {
+		for i := range rp {
+			rp[i].release()
+		}
+	}()
 	errors := make([]error, len(t.Children))
 	subp := (parallel + len(t.Children) - 1) / len(t.Children)
 	if subp <= 0 {
@@ -16,6 +16,7 @@ package plan
 
 import (
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/SnellerInc/sneller/expr"
@@ -146,7 +147,17 @@ func decodeLocal(st *ion.Symtab, body []byte) (Transport, error) {
 	return t, nil
 }
 
+// mergingSink is implemented by QuerySinks
+// that expect each stream returned by Open
+// to be sorted already (see vm.Order.MergeSorted)
+type mergingSink interface {
+	Merging() bool
+}
+
 func (u *UnionMap) exec(dst vm.QuerySink, parallel int, stats *ExecStats, rw TableRewrite) error {
+	if m, ok := dst.(mergingSink); ok && m.Merging() {
+		return u.execMerge(dst, stats)
+	}
 	w, err := dst.Open()
 	if err != nil {
 		return err
@@ -158,7 +169,56 @@ func (u *UnionMap) exec(dst vm.QuerySink, parallel int, stats *ExecStats, rw Tab
 	// does not benefit substantially from having
 	// parallelism, so we union all the output bytes
 	// into a single thread here
+	err = u.execSubs(stats, func(int) io.Writer { return s })
+	if err != nil {
+		w.Close()
+		dst.Close()
+		return err
+	}
+	err = w.Close()
+	err2 := dst.Close()
+	if err == nil {
+		err = err2
+	}
+	return err
+}
+
+// execMerge is like exec, but the output of each
+// of the sub-queries is written to its own stream
+// so that the sorted outputs can be merged
+func (u *UnionMap) execMerge(dst vm.QuerySink, stats *ExecStats) error {
+	var err error
+	ws := make([]io.WriteCloser, u.Sub.Len())
+	for i := range ws {
+		ws[i], err = dst.Open()
+		if err != nil {
+			for j := 0; j < i; j++ {
+				ws[j].Close()
+			}
+			dst.Close()
+			return err
+		}
+	}
+	err = u.execSubs(stats, func(i int) io.Writer { return vm.Locked(ws[i]) })
+	for i := range ws {
+		err2 := ws[i].Close()
+		if err == nil {
+			err = err2
+		}
+	}
+	err2 := dst.Close()
+	if err == nil {
+		err = err2
+	}
+	return err
+}
+
+// execSubs executes each of the sub-queries
+// in parallel, writing the output of sub-query i
+// to out(i), and returns the first error encountered
+func (u *UnionMap) execSubs(stats *ExecStats, out func(i int) io.Writer) error {
 	errors := make([]error, u.Sub.Len())
+	inputs := collectInputs(u.From)
 	var wg sync.WaitGroup
 	wg.Add(u.Sub.Len())
 	for i := 0; i < u.Sub.Len(); i++ {
@@ -176,8 +236,8 @@ func (u *UnionMap) exec(dst vm.QuerySink, parallel int, stats *ExecStats, rw Tab
 			// this makes it look to the Transport
 			// like we are executing a sub-query, which
 			// is approximately true
-			stub := &Tree{Op: u.From}
-			errors[i] = sub.Exec(stub, rw, s, stats)
+			stub := &Tree{Op: u.From, Inputs: inputs}
+			errors[i] = sub.Exec(stub, rw, out(i), stats)
 		}(i)
 	}
 	wg.Wait()
@@ -187,21 +247,10 @@ func (u *UnionMap) exec(dst vm.QuerySink, parallel int, stats *ExecStats, rw Tab
 	// if we had a whole bunch of them turn up
 	for i := range errors {
 		if errors[i] != nil {
-			err = errors[i]
-			break
+			return errors[i]
 		}
 	}
-	if err != nil {
-		w.Close()
-		dst.Close()
-		return err
-	}
-	err = w.Close()
-	err2 := dst.Close()
-	if err == nil {
-		err = err2
-	}
-	return err
+	return nil
 }
 
 func (u *UnionMap) encode(dst *ion.Buffer, st *ion.Symtab) error {
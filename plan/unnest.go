@@ -132,11 +132,13 @@ func (u *Unnest) String() string {
 }
 
 func (u *Unnest) exec(dst vm.QuerySink, parallel int, stats *ExecStats, rw TableRewrite) error {
-	return u.From.exec(vm.NewUnnest(
+	un := vm.NewUnnest(
 		dst,
 		u.PivotField,
 		u.OuterProject,
 		u.InnerProject,
 		u.InnerMatch,
-	), parallel, stats, rw)
+	)
+	un.SetCompileTimer(stats.compile)
+	return u.From.exec(un, parallel, stats, rw)
 }
@@ -0,0 +1,128 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package plan
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/expr/partiql"
+)
+
+// viewenv is a testenv with views
+type viewenv struct {
+	*testenv
+	views []*expr.View
+}
+
+var _ ViewResolver = (*viewenv)(nil)
+
+func (v *viewenv) View(tbl expr.Node) (*expr.View, error) {
+	for i := range v.views {
+		if expr.IsIdentifier(tbl, v.views[i].Name) {
+			return v.views[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func TestViews(t *testing.T) {
+	env := &viewenv{testenv: &testenv{t: t}}
+	for _, def := range []string{
+		"CREATE VIEW black AS SELECT Make, Fine FROM 'parking.10n' WHERE Color = 'BK'",
+		"CREATE VIEW black_fines AS SELECT Fine FROM black WHERE Fine > 50",
+	} {
+		v, err := partiql.ParseView([]byte(def))
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.views = append(env.views, v)
+	}
+
+	tcs := []struct {
+		query, expanded string
+	}{
+		{
+			query:    `select count(*) from black`,
+			expanded: `select count(*) from (select Make, Fine from 'parking.10n' where Color = 'BK')`,
+		},
+		{
+			query:    `select Make, sum(Fine) as total from black group by Make order by total desc limit 3`,
+			expanded: `select Make, sum(Fine) as total from (select Make, Fine from 'parking.10n' where Color = 'BK') group by Make order by total desc limit 3`,
+		},
+		{
+			// the columns of a view may be
+			// qualified with the name of the view...
+			query:    `select black.Make, black.Fine from black where black.Fine > 50`,
+			expanded: `select Make, Fine from (select Make, Fine from 'parking.10n' where Color = 'BK') where Fine > 50`,
+		},
+		{
+			// ... or with its alias
+			query:    `select b.Make, sum(b.Fine) as total from black b group by b.Make order by total desc limit 3`,
+			expanded: `select Make, sum(Fine) as total from (select Make, Fine from 'parking.10n' where Color = 'BK') group by Make order by total desc limit 3`,
+		},
+		{
+			query:    `select b.Make from black as b where b.Fine > 50`,
+			expanded: `select Make from (select Make, Fine from 'parking.10n' where Color = 'BK') where Fine > 50`,
+		},
+		{
+			// views may reference other views
+			query:    `select count(*) from black_fines`,
+			expanded: `select count(*) from (select Fine from (select Make, Fine from 'parking.10n' where Color = 'BK') where Fine > 50)`,
+		},
+		{
+			// a CTE binding shadows a view
+			query:    `with black as (select Fine from 'parking.10n' where Color = 'WH') select count(*) from black`,
+			expanded: `select count(*) from (select Fine from 'parking.10n' where Color = 'WH')`,
+		},
+		{
+			// ... but CTE bindings do not
+			// apply within a view
+			query:    `with black as (select Fine from 'parking.10n' where Color = 'WH') select count(*) from black_fines`,
+			expanded: `select count(*) from (select Fine from (select Make, Fine from 'parking.10n' where Color = 'BK') where Fine > 50)`,
+		},
+	}
+	run := func(t *testing.T, q string, env Env) (*Tree, []byte) {
+		s, err := partiql.Parse([]byte(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := New(s, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		var stat ExecStats
+		err = Exec(tree, &out, &stat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tree, out.Bytes()
+	}
+	for i := range tcs {
+		t.Run(tcs[i].query, func(t *testing.T) {
+			gottree, got := run(t, tcs[i].query, env)
+			wanttree, want := run(t, tcs[i].expanded, env.testenv)
+			if gottree.String() != wanttree.String() {
+				t.Errorf("got plan\n%s", gottree.String())
+				t.Errorf("want plan\n%s", wanttree.String())
+			}
+			if !bytes.Equal(got, want) {
+				t.Error("output not equivalent to the expanded query")
+			}
+		})
+	}
+}
@@ -77,6 +77,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -162,6 +163,12 @@ type Manager struct {
 	// If logger is nil, no output is logged.
 	logger *log.Logger
 
+	// number of queries executed
+	// locally via Do and on behalf of
+	// peers via Manager.Remote;
+	// accessed atomically
+	directExecs, remoteExecs int64
+
 	done chan struct{}
 	lock sync.Mutex // guards live
 	live map[tnproto.ID]*child
@@ -636,7 +643,70 @@ func (m *Manager) Do(id tnproto.ID, t *plan.Tree, ofmt tnproto.OutputFormat, int
 	if err != nil {
 		return nil, err
 	}
-	return c.directExec(t, ofmt, into)
+	rc, err := c.directExec(t, ofmt, into)
+	if err == nil {
+		atomic.AddInt64(&m.directExecs, 1)
+	}
+	return rc, err
+}
+
+// Live returns the number of
+// tenant processes that are currently running.
+func (m *Manager) Live() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.live)
+}
+
+// Stats are statistics about
+// the queries executed by a Manager
+// and the caches of its tenant processes.
+type Stats struct {
+	// Live is the number of tenant
+	// processes that are currently running.
+	Live int
+	// DirectExecs is the number of queries
+	// started successfully with Manager.Do.
+	DirectExecs int64
+	// RemoteExecs is the number of
+	// connections from peers that were
+	// handed to a tenant process for
+	// executing part of a query.
+	RemoteExecs int64
+	// Cache is the sum of the cache statistics
+	// of the tenant processes that are currently
+	// running. (The statistics of a tenant process
+	// are lost when it exits.)
+	Cache tnproto.CacheStats
+}
+
+// Stats returns the current Manager statistics.
+// Stats asks each running tenant process for
+// its cache statistics; tenant processes that
+// do not respond are logged and skipped.
+func (m *Manager) Stats() Stats {
+	m.lock.Lock()
+	ids := make([]tnproto.ID, 0, len(m.live))
+	children := make([]*child, 0, len(m.live))
+	for id, c := range m.live {
+		ids = append(ids, id)
+		children = append(children, c)
+	}
+	m.lock.Unlock()
+	out := Stats{
+		Live:        len(children),
+		DirectExecs: atomic.LoadInt64(&m.directExecs),
+		RemoteExecs: atomic.LoadInt64(&m.remoteExecs),
+	}
+	for i, c := range children {
+		cs, err := tnproto.ReadStats(c.ctl)
+		if err != nil {
+			m.errorf("id %s: reading stats: %s", ids[i], err)
+			continue
+		}
+		out.Cache.Add(cs)
+	}
+	return out
 }
 
 // Quit sends a SIGQUIT to the tenant process
@@ -710,7 +780,9 @@ func (m *Manager) handleRemote(conn net.Conn) {
 	err = c.proxyExec(conn)
 	if err != nil {
 		m.errorf("id %s: proxy-exec: %s", id, err)
+		return
 	}
+	atomic.AddInt64(&m.remoteExecs, 1)
 }
 
 // Stop performs a graceful cleanup
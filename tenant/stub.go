@@ -51,6 +51,15 @@ func (e *Env) post() {
 	e.eventfd.Write(e.evbuf[:])
 }
 
+func (e *Env) CacheStats() tnproto.CacheStats {
+	return tnproto.CacheStats{
+		Hits:     e.cache.Hits(),
+		Misses:   e.cache.Misses(),
+		Failures: e.cache.Failures(),
+		LiveHits: int64(e.cache.LiveHits()),
+	}
+}
+
 var _ plan.UploaderDecoder = (*Env)(nil)
 
 func (e *Env) DecodeUploader(st *ion.Symtab, buf []byte) (plan.UploadFS, error) {
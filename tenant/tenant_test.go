@@ -390,6 +390,23 @@ func TestExec(t *testing.T) {
 		t.Logf("expected 6 cache fills; found %d (%d - %d)", f, atomic.LoadInt32(&evictcount), cachefills)
 	}
 
+	// the first query plus one for each
+	// sub-query; three quarters of each sub-query
+	// loop back through the remote listener
+	st := m.Stats()
+	if st.Live != 1 {
+		t.Errorf("Stats: %d live processes", st.Live)
+	}
+	if st.DirectExecs != int64(1+len(subqueries)) {
+		t.Errorf("Stats: %d direct executions", st.DirectExecs)
+	}
+	if st.RemoteExecs != int64(3*len(subqueries)) {
+		t.Errorf("Stats: %d remote executions", st.RemoteExecs)
+	}
+	if st.Cache.Hits+st.Cache.Misses == 0 {
+		t.Errorf("Stats: no cache accesses: %+v", st.Cache)
+	}
+
 	t.Logf("before stop: %d fds", nfds())
 	m.Stop()
 
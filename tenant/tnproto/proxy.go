@@ -283,8 +283,8 @@ func (b *Buffer) DirectExec(ctl *net.UnixConn, conn net.Conn) (io.ReadCloser, er
 	return nil, fmt.Errorf("unexpected tenant response %q", b.pre[:])
 }
 
-// Serve responds to ProxyExec and DirectExec requests
-// over the given control socket.
+// Serve responds to ProxyExec, DirectExec,
+// and ReadStats requests over the given control socket.
 func Serve(ctl *net.UnixConn, dec plan.Decoder) error {
 	var msgbuf [8]byte
 	var st ion.Symtab
@@ -312,6 +312,8 @@ func Serve(ctl *net.UnixConn, dec plan.Decoder) error {
 		if bytes.Equal(msgbuf[:], proxymsg) {
 			// proxy request
 			go serveProxy(dec, conn)
+		} else if bytes.Equal(msgbuf[:], statsmsg) {
+			go serveStats(dec, conn)
 		} else if bytes.Equal(msgbuf[:3], directmsg[:3]) {
 			// need to read the plan
 			// and then execute it directly
@@ -0,0 +1,111 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tnproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/SnellerInc/sneller/plan"
+	"github.com/SnellerInc/sneller/usock"
+)
+
+// prologue to requesting cache statistics;
+// the tenant writes the statistics into
+// the provided connection and closes it
+var statsmsg = []byte("stats!!\n")
+
+// CacheStats are the cache statistics
+// reported by a tenant process.
+//
+// See also: dcache.Cache
+type CacheStats struct {
+	// Hits, Misses, and Failures are
+	// the cumulative number of cache hits,
+	// misses, and failed cache fills.
+	Hits, Misses, Failures int64
+	// LiveHits is the number of cache
+	// entries that are mapped for reading
+	// at the moment the statistics are taken.
+	LiveHits int64
+}
+
+// Add adds the statistics in other to s.
+func (s *CacheStats) Add(other *CacheStats) {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Failures += other.Failures
+	s.LiveHits += other.LiveHits
+}
+
+// CacheStatter is the interface optionally
+// implemented by the plan.Decoder passed to Serve.
+// If the decoder does not implement CacheStatter,
+// Serve responds to ReadStats with zeroed statistics.
+type CacheStatter interface {
+	CacheStats() CacheStats
+}
+
+const statsSize = 4 * 8
+
+// ReadStats requests the cache statistics
+// of the tenant listening on the control socket ctl.
+//
+// ReadStats performs exactly one Write call
+// on ctl, so it is safe to call ReadStats
+// on the same control socket from multiple
+// goroutines simultaneously.
+func ReadStats(ctl *net.UnixConn) (*CacheStats, error) {
+	here, there, err := usock.SocketPair()
+	if err != nil {
+		return nil, err
+	}
+	defer here.Close()
+	_, err = usock.WriteWithConn(ctl, statsmsg, there)
+	there.Close()
+	if err != nil {
+		return nil, fmt.Errorf("in ReadStats: usock.WriteWithConn: %w", err)
+	}
+	var buf [statsSize]byte
+	here.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadFull(here, buf[:])
+	if err != nil {
+		return nil, fmt.Errorf("in ReadStats: reading response: %w", err)
+	}
+	return &CacheStats{
+		Hits:     int64(binary.LittleEndian.Uint64(buf[0:])),
+		Misses:   int64(binary.LittleEndian.Uint64(buf[8:])),
+		Failures: int64(binary.LittleEndian.Uint64(buf[16:])),
+		LiveHits: int64(binary.LittleEndian.Uint64(buf[24:])),
+	}, nil
+}
+
+func serveStats(dec plan.Decoder, conn net.Conn) {
+	defer conn.Close()
+	var stats CacheStats
+	if cs, ok := dec.(CacheStatter); ok {
+		stats = cs.CacheStats()
+	}
+	var buf [statsSize]byte
+	binary.LittleEndian.PutUint64(buf[0:], uint64(stats.Hits))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(stats.Misses))
+	binary.LittleEndian.PutUint64(buf[16:], uint64(stats.Failures))
+	binary.LittleEndian.PutUint64(buf[24:], uint64(stats.LiveHits))
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.Write(buf[:])
+}
@@ -30,7 +30,14 @@ import (
 )
 
 //go:noescape
-func evalaggregatebc(w *bytecode, delims []vmref, aggregateDataBuffer []byte) int
+func evalaggregatebcAVX512(w *bytecode, delims []vmref, aggregateDataBuffer []byte) int
+
+func evalaggregatebc(w *bytecode, delims []vmref, aggregateDataBuffer []byte) int {
+	if portable {
+		return evalaggregatebcGo(w, delims, aggregateDataBuffer)
+	}
+	return evalaggregatebcAVX512(w, delims, aggregateDataBuffer)
+}
 
 // AggregateKind Specifies the aggregate operation and its type.
 type AggregateKind uint8
@@ -306,6 +313,8 @@ type Aggregate struct {
 
 	// Aggregated values (results from executing queries, even in parallel)
 	AggregatedData []byte
+
+	timer *CompileTimer
 }
 
 type aggregateLocal struct {
@@ -343,6 +352,13 @@ func (a Aggregation) String() string {
 	return out.String()
 }
 
+// SetCompileTimer sets the CompileTimer that
+// records the time spent compiling the aggregate.
+// SetCompileTimer must be called before Open.
+func (q *Aggregate) SetCompileTimer(t *CompileTimer) {
+	q.timer = t
+}
+
 func (q *Aggregate) Open() (io.WriteCloser, error) {
 	r, err := q.rest.Open()
 	if err != nil {
@@ -355,6 +371,7 @@ func (q *Aggregate) Open() (io.WriteCloser, error) {
 
 	return splitter(&aggregateLocal{
 		parent:      q,
+		bc:          bytecode{timer: q.timer},
 		dst:         asRowConsumer(r),
 		rowCount:    0,
 		partialData: partialData,
@@ -416,6 +433,9 @@ func (p *aggregateLocal) writeRows(delims []vmref) error {
 		panic("bytecode WriteRows() before Symbolize()")
 	}
 	rowsCount := evalaggregatebc(&p.bc, delims, p.partialData)
+	if p.bc.err != 0 {
+		return fmt.Errorf("aggregate: bytecode error: %w", p.bc.err)
+	}
 	p.rowCount += uint64(rowsCount)
 	return nil
 }
@@ -34,6 +34,8 @@ type bctestContext struct {
 	current uint16 // K1 = current mask bits
 	valid   uint16 // K7 = valid mask bits
 
+	tree *radixTree64 // R10 = hash aggregate tree
+
 	// 'current row'
 	structBase [16]uint32 // Z0 = struct base
 	structLen  [16]uint32 // Z1 = struct len
@@ -64,7 +66,11 @@ func (c *bctestContext) Execute(op bcop) error {
 		dict: c.dict,
 	}
 
-	bctest_run_aux(&bc, c)
+	if portable {
+		c.executeGo(&bc)
+	} else {
+		bctest_run_aux(&bc, c)
+	}
 	if bc.err != 0 {
 		return fmt.Errorf("bytecode error: %s (%d)", bc.err.Error(), bc.err)
 	}
@@ -72,6 +78,26 @@ func (c *bctestContext) Execute(op bcop) error {
 	return nil
 }
 
+// executeGo is the equivalent of bctest_run_aux
+// using the portable interpreter
+func (c *bctestContext) executeGo(bc *bytecode) {
+	p := newInterp(bc, c.data[:cap(c.data)])
+	p.k1 = c.current
+	p.tree = c.tree
+	p.b = [2][16]uint32{c.structBase, c.structLen}
+	p.v = [2][16]uint32{c.valueBase, c.valueLen}
+	copy(p.s[:8], c.scalar[0][:])
+	copy(p.s[8:], c.scalar[1][:])
+
+	p.enter()
+
+	c.current, c.valid = p.k1, p.k7
+	c.structBase, c.structLen = p.b[0], p.b[1]
+	c.valueBase, c.valueLen = p.v[0], p.v[1]
+	copy(c.scalar[0][:], p.s[:8])
+	copy(c.scalar[1][:], p.s[8:])
+}
+
 //lint:ignore U1000 kept for symmetry
 func (c *bctestContext) setScalarUint64(values []uint64) {
 	if len(values) > 16 {
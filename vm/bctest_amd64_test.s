@@ -30,6 +30,7 @@ TEXT ·bctest_run_aux(SB), NOSPLIT, $16
     VMOVDQU64   bctestContext_scalar+64(CX), Z3
     VMOVDQU64   bctestContext_valueBase(CX), Z30
     VMOVDQU64   bctestContext_valueLen(CX), Z31
+    MOVQ        bctestContext_tree(CX), R10
 
     MOVW        bctestContext_current(CX), AX
     KMOVW       AX, K1
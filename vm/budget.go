@@ -0,0 +1,152 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Budget tracks the memory used by the
+// operators that execute a single query
+// and optionally limits it.
+//
+// Operators that buffer an amount of state
+// that depends on their input (HashAggregate,
+// Order, and DistinctFilter) reserve memory from
+// the Budget passed to SetBudget, including the
+// vm pages that they allocate for their programs.
+// Streaming operators use a small, fixed number
+// of pages per thread and are not tracked.
+//
+// A nil *Budget tracks nothing and
+// never reports that the limit was exceeded.
+type Budget struct {
+	// Limit is the maximum number of bytes
+	// that may be reserved at once.
+	// A Limit <= 0 means there is no limit.
+	Limit int64
+
+	inuse, peak int64
+}
+
+// MemoryLimitError is the error returned
+// when a query attempts to use more memory
+// than its Budget allows.
+type MemoryLimitError struct {
+	Limit int64
+}
+
+func (m *MemoryLimitError) Error() string {
+	return fmt.Sprintf("query exceeded memory limit %d", m.Limit)
+}
+
+// Grow reserves n additional bytes.
+// If reserving n bytes would exceed b.Limit,
+// nothing is reserved and a *MemoryLimitError
+// is returned.
+func (b *Budget) Grow(n int64) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+	inuse := atomic.AddInt64(&b.inuse, n)
+	if b.Limit > 0 && inuse > b.Limit {
+		atomic.AddInt64(&b.inuse, -n)
+		return &MemoryLimitError{Limit: b.Limit}
+	}
+	for {
+		peak := atomic.LoadInt64(&b.peak)
+		if inuse <= peak || atomic.CompareAndSwapInt64(&b.peak, peak, inuse) {
+			return nil
+		}
+	}
+}
+
+// Shrink releases n bytes that
+// were reserved with Grow.
+func (b *Budget) Shrink(n int64) {
+	if b == nil || n <= 0 {
+		return
+	}
+	atomic.AddInt64(&b.inuse, -n)
+}
+
+// InUse returns the number of bytes
+// that are currently reserved.
+func (b *Budget) InUse() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.inuse)
+}
+
+// Peak returns the largest number of bytes
+// that have been reserved at once.
+func (b *Budget) Peak() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.peak)
+}
+
+// Malloc is equivalent to Malloc,
+// except that it reserves PageSize bytes
+// from the budget first.
+func (b *Budget) Malloc() ([]byte, error) {
+	if err := b.Grow(PageSize); err != nil {
+		return nil, err
+	}
+	return Malloc(), nil
+}
+
+// Free is equivalent to Free,
+// except that it releases the memory
+// that was reserved by b.Malloc.
+func (b *Budget) Free(buf []byte) {
+	Free(buf)
+	b.Shrink(PageSize)
+}
+
+// memtrack tracks the size of a single
+// data structure against a Budget
+type memtrack struct {
+	budget *Budget
+	size   int64
+}
+
+// set updates the tracked size to size
+func (m *memtrack) set(size int) error {
+	delta := int64(size) - m.size
+	if delta < 0 {
+		m.budget.Shrink(-delta)
+	} else if err := m.budget.Grow(delta); err != nil {
+		return err
+	}
+	m.size = int64(size)
+	return nil
+}
+
+// release releases all of the tracked memory
+func (m *memtrack) release() {
+	m.budget.Shrink(m.size)
+	m.size = 0
+}
+
+// moveTo transfers the tracked memory
+// to dst, which must use the same budget
+func (m *memtrack) moveTo(dst *memtrack) {
+	dst.size += m.size
+	m.size = 0
+}
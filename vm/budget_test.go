@@ -0,0 +1,160 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/internal/sort"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func TestBudget(t *testing.T) {
+	b := &Budget{Limit: 100}
+	if err := b.Grow(60); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Grow(30); err != nil {
+		t.Fatal(err)
+	}
+	err := b.Grow(20)
+	var merr *MemoryLimitError
+	if !errors.As(err, &merr) || merr.Limit != 100 {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if b.InUse() != 90 {
+		t.Fatalf("in use %d after failed Grow", b.InUse())
+	}
+	b.Shrink(90)
+	if err := b.Grow(50); err != nil {
+		t.Fatal(err)
+	}
+	if b.InUse() != 50 || b.Peak() != 90 {
+		t.Fatalf("in use %d, peak %d", b.InUse(), b.Peak())
+	}
+
+	// a nil budget tracks nothing
+	var nb *Budget
+	if err := nb.Grow(1 << 40); err != nil {
+		t.Fatal(err)
+	}
+	if nb.Peak() != 0 {
+		t.Fatal("nil budget has non-zero peak")
+	}
+}
+
+func TestBudgetLimit(t *testing.T) {
+	const rows = 20000
+	input, err := limitTestIon(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregate := func(b *Budget) ([]*ion.Struct, error) {
+		var qb QueryBuffer
+		agg := Aggregation{mkagg(expr.OpCount, "id", "count")}
+		ha, err := NewHashAggregate(agg, Selection{{Expr: path(nil, "key")}}, &qb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ha.OrderByGroup(0, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ha.SetBudget(b)
+		err = CopyRows(ha, buftbl(input), 4)
+		if err != nil {
+			ha.Close()
+			return nil, err
+		}
+		err = ha.Close()
+		if err != nil {
+			return nil, err
+		}
+		return readStructs(t, qb.Bytes()), nil
+	}
+	order := func(b *Budget) ([]*ion.Struct, error) {
+		var out bytes.Buffer
+		sorter := NewOrder(&out, []SortColumn{{
+			Node:      parsePath("key"),
+			Direction: sort.Descending,
+			Nulls:     sort.NullsFirst,
+		}}, nil, 4)
+		sorter.SetBudget(b)
+		err := CopyRows(sorter, buftbl(input), 4)
+		if err != nil {
+			sorter.Close()
+			return nil, err
+		}
+		err = sorter.Close()
+		if err != nil {
+			return nil, err
+		}
+		return readStructs(t, out.Bytes()), nil
+	}
+	run := map[string]func(*Budget) ([]*ion.Struct, error){
+		"aggregate": aggregate,
+		"order":     order,
+	}
+	for name, fn := range run {
+		fn := fn
+		t.Run(name, func(t *testing.T) {
+			b := &Budget{}
+			want, err := fn(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(want) != rows {
+				t.Fatalf("got %d rows", len(want))
+			}
+			if b.Peak() == 0 {
+				t.Error("no memory use tracked")
+			}
+			if b.InUse() != 0 {
+				t.Errorf("%d bytes still in use", b.InUse())
+			}
+			limit := b.Peak() / 4
+
+			b = &Budget{Limit: limit}
+			_, err = fn(b)
+			var merr *MemoryLimitError
+			if !errors.As(err, &merr) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if b.Peak() > limit {
+				t.Errorf("peak %d exceeds limit %d", b.Peak(), limit)
+			}
+
+			// with spilling enabled, exceeding the
+			// budget causes the operator to spill
+			// rather than fail
+			enableSpill(t, 1<<30)
+			b = &Budget{Limit: limit}
+			got, err := fn(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Error("output with spilling not equivalent")
+			}
+			if b.InUse() != 0 {
+				t.Errorf("%d bytes still in use", b.InUse())
+			}
+		})
+	}
+}
@@ -383,6 +383,12 @@ var opinfo = [_maxbcop]bcopinfo{
 	opboxfloat:  {text: "boxfloat", flags: bcReadK | bcReadS},
 	opboxstring: {text: "boxstring", flags: bcReadK | bcReadS},
 
+	// Constructor instructions
+	opappendv:     {text: "append.v", imms: bcImmsS16, flags: bcReadK | bcReadWriteV},
+	opappendfield: {text: "append.field", imms: bcImmsS16H32, flags: bcReadK | bcReadWriteV},
+	opboxlist:     {text: "boxlist", flags: bcReadK | bcReadWriteV},
+	opboxstruct:   {text: "boxstruct", flags: bcReadK | bcReadWriteV},
+
 	// Hash instructions
 	ophashvalue:     {text: "hashvalue", imms: bcImmsS16, flags: bcReadK | bcReadV | bcWriteH},
 	ophashvalueplus: {text: "hashvalue+", imms: bcImmsS16S16, flags: bcReadK | bcReadV | bcReadWriteH},
@@ -508,6 +514,12 @@ const (
 	// bytecode fails a bounds check
 	// in a radix tree lookup
 	bcerrTreeCorrupt
+	// NotSupported is returned by the portable
+	// interpreter when the program uses an opcode
+	// (or entry point) that it does not implement
+	//
+	// the errinfo field will be set to the opcode
+	bcerrNotSupported
 )
 
 func (b bcerr) Error() string {
@@ -520,6 +532,8 @@ func (b bcerr) Error() string {
 		return "internal assertion failed"
 	case bcerrTreeCorrupt:
 		return "radix tree bounds-check failed"
+	case bcerrNotSupported:
+		return "operation not supported by the portable interpreter"
 	default:
 		return "unknown bytecode error"
 	}
@@ -568,6 +582,13 @@ type bytecode struct {
 	// additional error information;
 	// error-specific
 	errinfo int
+
+	// budget, if non-nil, is charged
+	// for the scratch page
+	budget *Budget
+	// timer, if non-nil, records the time
+	// spent compiling programs into this bytecode
+	timer *CompileTimer
 }
 
 func formatBytecode(compiled []byte) string {
@@ -655,9 +676,14 @@ func (b *bytecode) String() string {
 
 // finalize append the final 'return' instruction
 // to the bytecode buffer and checks that the stack
-// depth is sane
+// depth is sane; when bytecode is executed by the
+// portable interpreter, it also checks that the
+// interpreter implements every opcode
 func (b *bytecode) finalize() error {
 	b.compiled = append(b.compiled, byte(opret), byte(opret>>8))
+	if portable {
+		return checkPortable(b.compiled)
+	}
 	return nil
 }
 
@@ -696,12 +722,16 @@ func (b *bytecode) allocStacks() {
 // called from ssa compilation;
 // this sets up the initial space
 // for literals that need to be projected
-func (b *bytecode) setlit(buf []byte) bool {
+func (b *bytecode) setlit(buf []byte) error {
 	if len(buf) > defaultAlign {
-		return false
+		return fmt.Errorf("literal buffer (len=%d) too large", len(buf))
 	}
 	if b.scratch == nil {
-		b.scratch = Malloc()
+		mem, err := b.budget.Malloc()
+		if err != nil {
+			return err
+		}
+		b.scratch = mem
 	}
 	b.scratchreserve = copy(b.scratch, buf)
 	b.scratch = b.scratch[:b.scratchreserve]
@@ -710,12 +740,12 @@ func (b *bytecode) setlit(buf []byte) bool {
 	if !ok {
 		panic("buffer from malloc has bad displacement?")
 	}
-	return true
+	return nil
 }
 
 func (b *bytecode) reset() {
 	if b.scratch != nil {
-		Free(b.scratch)
+		b.budget.Free(b.scratch)
 	}
 	*b = bytecode{}
 }
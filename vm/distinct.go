@@ -47,6 +47,12 @@ type DistinctFilter struct {
 	dedup     *radixTree64
 	limit     int64
 	remaining int64
+
+	// budget and mem track the size
+	// of dedup and of the local trees
+	budget *Budget
+	mem    memtrack
+	timer  *CompileTimer
 }
 
 // NewDistinct creates a new DistinctFilter
@@ -95,6 +101,22 @@ func (d *DistinctFilter) Limit(n int64) {
 	d.remaining = n
 }
 
+// SetBudget sets the Budget against which
+// the memory used to track distinct rows is
+// reserved. SetBudget must be called before Open.
+func (d *DistinctFilter) SetBudget(b *Budget) {
+	d.budget = b
+	d.mem.budget = b
+}
+
+// SetCompileTimer sets the CompileTimer
+// that records the time spent compiling
+// the programs of d. SetCompileTimer must
+// be called before Open.
+func (d *DistinctFilter) SetCompileTimer(t *CompileTimer) {
+	d.timer = t
+}
+
 func (d *DistinctFilter) Open() (io.WriteCloser, error) {
 	dst, err := d.out.Open()
 	if err != nil {
@@ -103,10 +125,13 @@ func (d *DistinctFilter) Open() (io.WriteCloser, error) {
 	return splitter(&deduper{
 		parent: d,
 		dst:    asRowConsumer(dst),
+		bc:     bytecode{budget: d.budget, timer: d.timer},
+		mem:    memtrack{budget: d.budget},
 	}), nil
 }
 
 func (d *DistinctFilter) Close() error {
+	d.mem.release()
 	return d.out.Close()
 }
 
@@ -116,6 +141,7 @@ type deduper struct {
 	local  *radixTree64
 	dst    rowConsumer
 	bc     bytecode
+	mem    memtrack
 
 	// temporary buffer for
 	// storing computed hashes
@@ -152,7 +178,14 @@ func (d *deduper) symbolize(st *ion.Symtab) error {
 }
 
 //go:noescape
-func evaldedup(bc *bytecode, delims []vmref, hashes []uint64, tree *radixTree64, slot int) int
+func evaldedupAVX512(bc *bytecode, delims []vmref, hashes []uint64, tree *radixTree64, slot int) int
+
+func evaldedup(bc *bytecode, delims []vmref, hashes []uint64, tree *radixTree64, slot int) int {
+	if portable {
+		return evaldedupGo(bc, delims, hashes, tree, slot)
+	}
+	return evaldedupAVX512(bc, delims, hashes, tree, slot)
+}
 
 func (d *deduper) writeRows(delims []vmref) error {
 	if d.closed {
@@ -200,6 +233,9 @@ func (d *deduper) writeRows(delims []vmref) error {
 	}
 	delims = delims[:outpos]
 	hashes = hashes[:outpos]
+	if err := d.mem.set(d.local.memsize()); err != nil {
+		return err
+	}
 
 	// we may not insert len(delims) entries
 	// (due to duplicates), but we should have
@@ -224,6 +260,10 @@ func (d *deduper) writeRows(delims []vmref) error {
 			outpos++
 		}
 	}
+	if err := d.parent.mem.set(all.memsize()); err != nil {
+		d.parent.lock.Unlock()
+		return err
+	}
 	if d.parent.limit > 0 {
 		c := int64(outpos)
 		if c >= d.parent.remaining {
@@ -246,5 +286,6 @@ func (d *deduper) writeRows(delims []vmref) error {
 
 func (d *deduper) Close() error {
 	d.bc.reset()
+	d.mem.release()
 	return d.dst.Close()
 }
@@ -18,7 +18,7 @@
 #include "bc_amd64.h"
 #include "bc_imm_amd64.h"
 
-TEXT ·evalaggregatebc(SB), NOSPLIT, $8
+TEXT ·evalaggregatebcAVX512(SB), NOSPLIT, $8
   NO_LOCAL_POINTERS
   MOVQ w+0(FP), DI                     // RDI = &w
   XORQ R9, R9                          // R9  = rows consumed
@@ -9778,6 +9778,310 @@ abort:
   MOVL $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
   RET_ABORT()
 
+// Constructor Instructions
+// ------------------------
+//
+// Structs and lists are constructed one element
+// at a time: appendfield and appendv append the
+// encoded fields or items to the contents that
+// have been constructed so far, and boxstruct
+// and boxlist prepend the header to the contents.
+
+// append the value in the stack slot to the
+// value in Z30:Z31 in the lanes in K1;
+// the other lanes of Z30:Z31 are left untouched
+TEXT bcappendv(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX 0(VIRT_PCREG), R8
+  ADDQ    $2, VIRT_PCREG
+  KXORQ   K3, K3, K3                          // K3 = nothing to write in between
+  JMP     append_tail(SB)
+
+// like appendv, but write the encoded symbol
+// in the immediate in between the two values
+TEXT bcappendfield(SB), NOSPLIT|NOFRAME, $0
+  MOVWQZX 0(VIRT_PCREG), R8
+  MOVL    2(VIRT_PCREG), R14
+  ADDQ    $6, VIRT_PCREG
+  VMOVD   R14, X11                            // X11 = encoded symbol
+  // the last byte of the symbol has the
+  // high bit set; K3 = (1 << size) - 1
+  ANDL    $0x80808080, R14
+  TZCNTL  R14, R14
+  ADDL    $1, R14
+  SHRL    $3, R14
+  MOVQ    $-1, CX
+  SHLXQ   R14, CX, CX
+  NOTQ    CX
+  KMOVQ   CX, K3
+  JMP     append_tail(SB)
+
+// Appends the values in a stack slot to the values in Z30:Z31
+//
+// Inputs:
+//   - K1 - 16-bit lane mask
+//   - Z30:Z31 - the values to append to
+//   - R8 - offset of the stack slot holding the values to append
+//   - X11, K3 - the bytes to write in between the two values
+//
+// Outputs:
+//   - Z30:Z31 - the concatenated values (in the lanes in K1)
+TEXT append_tail(SB), NOSPLIT|NOFRAME, $0
+  KTESTW        K1, K1
+  JZ            next
+  ADDQ          VIRT_VALUES, R8                      // R8 = address of the stack slot
+  VMOVDQU32     64(R8), Z4                           // Z4 = lengths of the appended values
+  KMOVQ         K3, R14
+  POPCNTQ       R14, R14
+  VPBROADCASTD  R14, Z5                              // Z5 = number of bytes in between
+  VPADDD.Z      Z31, Z4, K1, Z6
+  VPADDD.Z      Z5, Z6, K1, Z6                       // Z6 = output length of each lane
+
+  // R15 = sum of the output lengths
+  VEXTRACTI32X8 $1, Z6, Y7
+  VPADDD        Y6, Y7, Y7
+  VEXTRACTI128  $1, Y7, X8
+  VPADDD        X7, X8, X8
+  VPSHUFD       $SHUFFLE_IMM_4x2b(1, 0, 3, 2), X8, X7
+  VPADDD        X7, X8, X8
+  VPSHUFD       $SHUFFLE_IMM_4x2b(2, 3, 0, 1), X8, X7
+  VPADDD        X7, X8, X8
+  VMOVD         X8, R15
+
+  VM_CHECK_SCRATCH_CAPACITY(R15, CX, abort)
+  VM_GET_SCRATCH_BASE_GP(R13)
+  ADDQ          R15, bytecode_scratch+8(VIRT_BCPTR)  // reserve the output
+  ADDQ          VIRT_BASE, R13                       // R13 = output pointer
+  KMOVW         K1, BX
+
+lane_loop:
+  TZCNTL        BX, CX                               // CX = lane index
+  BLSRL         BX, BX
+  VPBROADCASTD  CX, Z7
+  MOVL          $1, DX
+  SHLXL         CX, DX, DX
+  KMOVW         DX, K4
+  MOVQ          R13, DX
+  SUBQ          VIRT_BASE, DX
+  VPBROADCASTD  DX, K4, Z9                           // Z9 = output offset of this lane
+
+  // copy the first value
+  VPERMD        Z30, Z7, Z8
+  VMOVD         X8, DX
+  ADDQ          VIRT_BASE, DX
+  VPERMD        Z31, Z7, Z8
+  VMOVD         X8, CX
+first_loop:
+  CMPQ          CX, $64
+  JB            first_tail
+  VMOVDQU8      0(DX), Z10
+  VMOVDQU8      Z10, 0(R13)
+  ADDQ          $64, DX
+  ADDQ          $64, R13
+  SUBQ          $64, CX
+  JMP           first_loop
+first_tail:
+  MOVQ          $-1, R14
+  SHLXQ         CX, R14, R14
+  NOTQ          R14
+  KMOVQ         R14, K2
+  VMOVDQU8.Z    0(DX), K2, Z10
+  VMOVDQU8      Z10, K2, 0(R13)
+  ADDQ          CX, R13
+
+  // write the bytes in between
+  VMOVDQU8      X11, K3, 0(R13)
+  KMOVQ         K3, R14
+  POPCNTQ       R14, R14
+  ADDQ          R14, R13
+
+  // copy the second value
+  VPERMD        0(R8), Z7, Z8
+  VMOVD         X8, DX
+  ADDQ          VIRT_BASE, DX
+  VPERMD        Z4, Z7, Z8
+  VMOVD         X8, CX
+second_loop:
+  CMPQ          CX, $64
+  JB            second_tail
+  VMOVDQU8      0(DX), Z10
+  VMOVDQU8      Z10, 0(R13)
+  ADDQ          $64, DX
+  ADDQ          $64, R13
+  SUBQ          $64, CX
+  JMP           second_loop
+second_tail:
+  MOVQ          $-1, R14
+  SHLXQ         CX, R14, R14
+  NOTQ          R14
+  KMOVQ         R14, K2
+  VMOVDQU8.Z    0(DX), K2, Z10
+  VMOVDQU8      Z10, K2, 0(R13)
+  ADDQ          CX, R13
+
+  TESTL         BX, BX
+  JNZ           lane_loop
+
+  VMOVDQA32     Z9, K1, Z30
+  VMOVDQA32     Z6, K1, Z31
+next:
+  NEXT()
+abort:
+  MOVL $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
+// box the list contents in Z30:Z31
+TEXT bcboxlist(SB), NOSPLIT|NOFRAME, $0
+  MOVL $0xb0, R8
+  JMP  boxcontainer_tail(SB)
+
+// box the struct fields in Z30:Z31
+TEXT bcboxstruct(SB), NOSPLIT|NOFRAME, $0
+  MOVL $0xd0, R8
+  JMP  boxcontainer_tail(SB)
+
+// Writes the contents in Z30:Z31 with an ion header
+//
+// Inputs:
+//   - K1 - 16-bit lane mask
+//   - Z30:Z31 - the contents of each list or struct
+//   - R8 - the type byte of the header (with a zero length)
+//
+// Outputs:
+//   - Z30:Z31 - the boxed values (zero in the lanes not in K1)
+TEXT boxcontainer_tail(SB), NOSPLIT|NOFRAME, $0
+  VPXORD        Z9, Z9, Z9
+  VPXORD        Z6, Z6, Z6
+  KTESTW        K1, K1
+  JZ            done
+
+  // the header is one byte followed by
+  // the length as a varuint if it is 14 or more
+  VPBROADCASTD  CONSTD_1(), Z5
+  VMOVDQA32     Z5, K1, Z6                           // Z6 = header length
+  VPCMPD.BCST   $VPCMP_IMM_GE, CONSTD_14(), Z31, K1, K2
+  VPADDD        Z5, Z6, K2, Z6
+  VPCMPD.BCST   $VPCMP_IMM_GE, CONSTD_128(), Z31, K1, K2
+  VPADDD        Z5, Z6, K2, Z6
+  MOVL          $(1 << 14), R14
+  VPBROADCASTD  R14, Z7
+  VPCMPD        $VPCMP_IMM_GE, Z7, Z31, K1, K2
+  VPADDD        Z5, Z6, K2, Z6
+  MOVL          $(1 << 21), R14
+  VPBROADCASTD  R14, Z7
+  VPCMPD        $VPCMP_IMM_GE, Z7, Z31, K1, K2
+  VPADDD        Z5, Z6, K2, Z6
+  MOVL          $(1 << 28), R14
+  VPBROADCASTD  R14, Z7
+  VPCMPD        $VPCMP_IMM_GE, Z7, Z31, K1, K2
+  VPADDD        Z5, Z6, K2, Z6
+  VPADDD        Z31, Z6, K1, Z6                      // Z6 = output length of each lane
+
+  // R15 = sum of the output lengths
+  VEXTRACTI32X8 $1, Z6, Y7
+  VPADDD        Y6, Y7, Y7
+  VEXTRACTI128  $1, Y7, X8
+  VPADDD        X7, X8, X8
+  VPSHUFD       $SHUFFLE_IMM_4x2b(1, 0, 3, 2), X8, X7
+  VPADDD        X7, X8, X8
+  VPSHUFD       $SHUFFLE_IMM_4x2b(2, 3, 0, 1), X8, X7
+  VPADDD        X7, X8, X8
+  VMOVD         X8, R15
+
+  VM_CHECK_SCRATCH_CAPACITY(R15, CX, abort)
+  VM_GET_SCRATCH_BASE_GP(R13)
+  ADDQ          R15, bytecode_scratch+8(VIRT_BCPTR)  // reserve the output
+  ADDQ          VIRT_BASE, R13                       // R13 = output pointer
+  KMOVW         K1, BX
+
+lane_loop:
+  TZCNTL        BX, CX                               // CX = lane index
+  BLSRL         BX, BX
+  VPBROADCASTD  CX, Z7
+  MOVL          $1, DX
+  SHLXL         CX, DX, DX
+  KMOVW         DX, K4
+  MOVQ          R13, DX
+  SUBQ          VIRT_BASE, DX
+  VPBROADCASTD  DX, K4, Z9                           // Z9 = output offset of this lane
+
+  // write the header
+  VPERMD        Z31, Z7, Z8
+  VMOVD         X8, CX                               // CX = length of the contents
+  CMPL          CX, $14
+  JAE           long_header
+  MOVL          R8, DX
+  ORL           CX, DX
+  MOVB          DX, 0(R13)
+  ADDQ          $1, R13
+  JMP           copy
+long_header:
+  MOVL          R8, DX
+  ORL           $0x0e, DX
+  MOVB          DX, 0(R13)
+  ADDQ          $1, R13
+  // R14 = number of varuint bytes
+  MOVL          CX, DX
+  XORL          R14, R14
+count_loop:
+  ADDL          $1, R14
+  SHRL          $7, DX
+  JNZ           count_loop
+  LEAQ          0(R13)(R14*1), R15                   // R15 = end of the varuint
+  MOVQ          R15, R14
+  // the last byte has the high bit set
+  MOVL          CX, DX
+  ANDL          $0x7f, DX
+  ORL           $0x80, DX
+  MOVB          DX, -1(R14)
+  SUBQ          $1, R14
+  SHRL          $7, CX
+varuint_loop:
+  CMPQ          R14, R13
+  JE            varuint_done
+  MOVL          CX, DX
+  ANDL          $0x7f, DX
+  MOVB          DX, -1(R14)
+  SUBQ          $1, R14
+  SHRL          $7, CX
+  JMP           varuint_loop
+varuint_done:
+  MOVQ          R15, R13
+  VPERMD        Z31, Z7, Z8
+  VMOVD         X8, CX
+
+  // copy the contents
+copy:
+  VPERMD        Z30, Z7, Z8
+  VMOVD         X8, DX
+  ADDQ          VIRT_BASE, DX
+copy_loop:
+  CMPQ          CX, $64
+  JB            copy_tail
+  VMOVDQU8      0(DX), Z10
+  VMOVDQU8      Z10, 0(R13)
+  ADDQ          $64, DX
+  ADDQ          $64, R13
+  SUBQ          $64, CX
+  JMP           copy_loop
+copy_tail:
+  MOVQ          $-1, R14
+  SHLXQ         CX, R14, R14
+  NOTQ          R14
+  KMOVQ         R14, K2
+  VMOVDQU8.Z    0(DX), K2, Z10
+  VMOVDQU8      Z10, K2, 0(R13)
+  ADDQ          CX, R13
+
+  TESTL         BX, BX
+  JNZ           lane_loop
+done:
+  VMOVDQA32     Z9, Z30
+  VMOVDQA32     Z6, Z31
+  NEXT()
+abort:
+  MOVL $const_bcerrMoreScratch, bytecode_err(VIRT_BCPTR)
+  RET_ABORT()
+
 // Hash Instructions
 // -----------------
 
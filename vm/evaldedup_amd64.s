@@ -17,7 +17,7 @@
 #include "go_asm.h"
 #include "bc_amd64.h"
 
-TEXT ·evaldedup(SB), NOSPLIT, $8
+TEXT ·evaldedupAVX512(SB), NOSPLIT, $8
   NO_LOCAL_POINTERS
   XORQ R9, R9         // R9 = rows consumed
   MOVQ R9, ret+72(FP) // # rows output (set to zero for now)
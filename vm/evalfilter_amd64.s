@@ -17,7 +17,7 @@
 #include "go_asm.h"
 #include "bc_amd64.h"
 
-TEXT ·evalfilterbc(SB), NOSPLIT, $8
+TEXT ·evalfilterbcAVX512(SB), NOSPLIT, $8
   NO_LOCAL_POINTERS
   MOVQ w+0(FP), DI    // DI = &w
   XORQ R9, R9         // R9 = rows consumed
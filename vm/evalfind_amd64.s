@@ -17,7 +17,7 @@
 #include "go_asm.h"
 #include "bc_amd64.h"
 
-TEXT ·evalfindbc(SB), NOSPLIT, $16
+TEXT ·evalfindbcAVX512(SB), NOSPLIT, $16
   NO_LOCAL_POINTERS
   MOVQ w+0(FP), DI        // DI = &w
   XORL R9, R9             // R9 = rows consumed
@@ -84,7 +84,7 @@ trap:
 // project fields into an output buffer
 // using the stack slots produced by a bytecode
 // program invocation
-TEXT ·evalproject(SB), NOSPLIT, $16
+TEXT ·evalprojectAVX512(SB), NOSPLIT, $16
   NO_LOCAL_POINTERS
   XORL R9, R9             // R9 = rows consumed
   MOVQ dst+32(FP), DI
@@ -18,7 +18,7 @@
 #include "bc_amd64.h"
 #include "bc_imm_amd64.h"
 
-TEXT ·evalhashagg(SB), NOSPLIT, $8
+TEXT ·evalhashaggAVX512(SB), NOSPLIT, $8
   NO_LOCAL_POINTERS
 
   MOVQ bc+0(FP), DI     // DI = &w
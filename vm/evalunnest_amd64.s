@@ -35,7 +35,7 @@ DATA indexb<>+14(SB)/1, $14
 DATA indexb<>+15(SB)/1, $15
 GLOBL indexb<>(SB), RODATA|NOPTR, $16
 
-TEXT ·compress(SB), NOSPLIT, $0
+TEXT ·compressAVX512(SB), NOSPLIT, $0
   NO_LOCAL_POINTERS
   MOVQ  delims+0(FP), SI
   MOVQ  SI, DI
@@ -73,7 +73,7 @@ ret:
   MOVQ  AX, ret+24(FP)
   RET
 
-TEXT ·evalsplat(SB), NOSPLIT, $16
+TEXT ·evalsplatAVX512(SB), NOSPLIT, $16
   NO_LOCAL_POINTERS
   MOVQ         indelims_len+16(FP), CX
   CMPQ         CX, $16
@@ -181,7 +181,7 @@ genmask:
 // project fields into an output buffer
 // using the stack slots produced by a bytecode
 // program invocation
-TEXT ·evalunnest(SB), NOSPLIT, $16
+TEXT ·evalunnestAVX512(SB), NOSPLIT, $16
   NO_LOCAL_POINTERS
   XORL R9, R9             // R9 = rows consumed
   MOVQ dst+56(FP), DI
@@ -263,7 +263,7 @@ empty_cell:
   MOVL    CX, DX
   ANDL    $0x7f, DX
   ORL     $0x80, DX          // final byte |= 0x80
-  SHRL    $8, CX
+  SHRL    $7, CX
   JZ      writeheader
 moreheader:
   INCL    BX
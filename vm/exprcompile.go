@@ -245,6 +245,8 @@ func compile(p *prog, e expr.Node) (*value, error) {
 		return p.Constant(n.Value), nil
 	case *expr.Member:
 		return p.member(n.Arg, n.Values)
+	case *expr.SemiJoin:
+		return p.semijoin(n)
 	case expr.Missing:
 		return p.ssa0(skfalse), nil
 	default:
@@ -274,6 +276,7 @@ func (p *prog) compileAsBool(e expr.Node) (*value, error) {
 	case *expr.Builtin:
 	case *expr.Not:
 	case *expr.Member:
+	case *expr.SemiJoin:
 	case *expr.Path:
 		coerce = true
 	default:
@@ -905,11 +908,56 @@ func compilefunc(p *prog, b *expr.Builtin, args []expr.Node) (*value, error) {
 		return p.ssa2(sobjectsize, arg, p.mask(arg)), nil
 	case expr.HashLookup:
 		return p.compileHashLookup(b.Args)
+	case expr.MakeStruct:
+		return p.compileMakeStruct(args)
+	case expr.MakeList:
+		return p.compileMakeList(args)
 	default:
 		return nil, fmt.Errorf("unhandled builtin function name %q", fn)
 	}
 }
 
+// compileMakeStruct compiles a struct constructor;
+// the arguments are alternating field names and values,
+// and fields with MISSING values are omitted
+func (p *prog) compileMakeStruct(args []expr.Node) (*value, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("MAKE_STRUCT with %d arguments?", len(args))
+	}
+	// the contents start out empty for every struct;
+	// each constructor gets its own chain of fields
+	// so that the fields can be re-ordered by symbol ID
+	// when the program is symbolized (see prog.sortfields)
+	acc := p.Constant(rawDatum(nil))
+	for i := 0; i < len(args); i += 2 {
+		name, ok := args[i].(expr.String)
+		if !ok {
+			return nil, fmt.Errorf("MAKE_STRUCT: field name %s is not a string", expr.ToString(args[i]))
+		}
+		val, err := p.serialized(args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		acc = p.ssa3imm(sappendfield, acc, val, p.mask(val), string(name))
+	}
+	return p.ssa2(sboxstruct, acc, p.ValidLanes()), nil
+}
+
+// compileMakeList compiles a list constructor;
+// items with MISSING values become NULL
+func (p *prog) compileMakeList(args []expr.Node) (*value, error) {
+	acc := p.Constant(rawDatum(nil))
+	for i := range args {
+		val, err := p.serialized(args[i])
+		if err != nil {
+			return nil, err
+		}
+		item := p.ssa3(sblendv, p.Constant(nil), val, p.mask(val))
+		acc = p.ssa3(sappendv, acc, item, p.ValidLanes())
+	}
+	return p.ssa2(sboxlist, acc, p.ValidLanes()), nil
+}
+
 func (p *prog) compileHashLookup(lst []expr.Node) (*value, error) {
 	var datums []ion.Datum
 	if len(lst) <= 1 {
@@ -1030,6 +1078,30 @@ func (p *prog) member(e expr.Node, values []expr.Constant) (*value, error) {
 	return p.ssaimm(shashmember, values, h, p.mask(h)), nil
 }
 
+// semijoin compiles a membership test of s.Arg
+// against the pre-computed hashes in s.Set
+func (p *prog) semijoin(s *expr.SemiJoin) (*value, error) {
+	if s.Set == nil {
+		return nil, fmt.Errorf("no hash set provided for %q", expr.ToString(s))
+	}
+	v, err := p.serialized(s.Arg)
+	if err != nil {
+		return nil, err
+	}
+	h := p.hash(v)
+	// the tree does not depend on the symbol table,
+	// so it can be built once and shared by every
+	// program that references this set
+	tree := s.Set.Memo(func(hashes []uint64) interface{} {
+		tree := newRadixTree(0)
+		for i := range hashes {
+			tree.insertSlow(hashes[i])
+		}
+		return tree
+	})
+	return p.ssaimm(shashmember, tree, h, p.mask(h)), nil
+}
+
 func (p *prog) recordDatum(d ion.Datum, st *ion.Symtab) {
 	switch d := d.(type) {
 	case *ion.Struct:
@@ -1087,18 +1159,27 @@ func (p *prog) mktree(st *ion.Symtab, imm interface{}) *radixTree64 {
 
 	var tmp ion.Buffer
 	tree := newRadixTree(0)
-	var hmem [16]byte
 	for i := range values {
 		tmp.Reset()
 		dat := values[i].Datum()
 		dat.Encode(&tmp, st)
 		p.recordDatum(dat, st)
-		chacha8Hash(tmp.Bytes(), hmem[:])
-		tree.insertSlow(binary.LittleEndian.Uint64(hmem[:]))
+		tree.insertSlow(MemberHash(tmp.Bytes()))
 	}
 	return tree
 }
 
+// MemberHash returns the hash of the ion-encoded
+// datum in buf that is used for IN comparisons
+// against a list of values.
+//
+// See also: expr.HashSet
+func MemberHash(buf []byte) uint64 {
+	var hmem [16]byte
+	chacha8Hash(buf, hmem[:])
+	return binary.LittleEndian.Uint64(hmem[:])
+}
+
 // serialized turns an arbitrary expression
 // into a boxed value (stValue) so that it
 // can be passed to generic operations (store, hash, etc.)
@@ -1154,6 +1235,9 @@ func compilecase(p *prog, c *expr.Case) (*value, error) {
 	if c.Valence == "logical" {
 		return p.compileLogicalCase(c)
 	}
+	if hasStringArm(c) {
+		return p.compileDatumCase(c)
+	}
 	return p.compileGenericCase(c)
 }
 
@@ -1366,8 +1450,219 @@ func (p *prog) compileGenericCase(c *expr.Case) (*value, error) {
 	return p.vk(v, k), nil
 }
 
+// isStringArm returns true if the
+// arm e of a CASE only produces
+// strings (or MISSING)
+func isStringArm(e expr.Node) bool {
+	t := expr.TypeOf(e, nil)
+	return t.AnyOf(expr.StringType) && t.Only(expr.StringType|expr.MissingType)
+}
+
+// isStringCase returns true if every arm
+// of c produces a string, NULL, or MISSING
+// and at least one of them produces a string
+func isStringCase(c *expr.Case) bool {
+	str := false
+	arm := func(e expr.Node) bool {
+		switch e.(type) {
+		case nil, expr.Null, expr.Missing:
+			return true
+		}
+		if !isStringArm(e) {
+			return false
+		}
+		str = true
+		return true
+	}
+	for i := range c.Limbs {
+		if !arm(c.Limbs[i].Then) {
+			return false
+		}
+	}
+	return arm(c.Else) && str
+}
+
+// hasStringArm returns true if
+// any arm of c produces only strings
+func hasStringArm(c *expr.Case) bool {
+	for i := range c.Limbs {
+		if isStringArm(c.Limbs[i].Then) {
+			return true
+		}
+	}
+	return c.Else != nil && isStringArm(c.Else)
+}
+
+// compileStringCase compiles a CASE expression
+// in a context where a string is expected
+//
+// if the arms of the CASE are all strings, then
+// the string slices of each arm are blended together
+// directly; otherwise the result of the generic CASE
+// is unboxed as a string
 func (p *prog) compileStringCase(c *expr.Case) (*value, error) {
-	return nil, fmt.Errorf("string-case unimplemented")
+	if !isStringCase(c) {
+		v, err := p.compileGenericCase(c)
+		if err != nil {
+			return nil, err
+		}
+		if v.op == skfalse {
+			return v, nil
+		}
+		return p.ssa2(stostr, v, p.mask(v)), nil
+	}
+	// lanes that evaluate to NULL are simply
+	// not strings, so they are dropped here
+	arms, err := p.caseArms(c)
+	if err != nil {
+		return nil, err
+	}
+	if arms.s == nil {
+		return p.ssa0(skfalse), nil
+	}
+	return p.strk(arms.s, arms.sk), nil
+}
+
+// compileDatumCase compiles a CASE expression
+// that has at least one string arm (see hasStringArm)
+// as a boxed value
+//
+// the string arms are blended as string slices and
+// boxed once, rather than boxing each arm; the other
+// arms (paths to nested values, constants, and so forth)
+// are blended as values, which does not re-box them
+func (p *prog) compileDatumCase(c *expr.Case) (*value, error) {
+	arms, err := p.caseArms(c)
+	if err != nil {
+		return nil, err
+	}
+	if arms.v == nil && arms.nullk.op == skfalse {
+		if arms.s == nil {
+			return p.ssa0(skfalse), nil
+		}
+		return p.strk(arms.s, arms.sk), nil
+	}
+	v, k := arms.v, arms.vk
+	// merge the lanes in mask
+	// holding src into the output
+	merge := func(src, mask *value) {
+		if v == nil {
+			v, k = src, mask
+			return
+		}
+		v = p.ssa3(sblendv, v, src, mask)
+		k = p.Or(k, mask)
+	}
+	if arms.s != nil {
+		merge(p.ssa2(sboxstring, arms.s, arms.sk), arms.sk)
+	}
+	if arms.nullk.op != skfalse {
+		merge(p.Constant(nil), arms.nullk)
+	}
+	return p.vk(v, k), nil
+}
+
+// caseArms is the result of compiling the arms
+// of a CASE expression by the type they produce
+type caseArms struct {
+	// s holds the blended string slices of
+	// the lanes in sk that produce a string
+	s, sk *value
+	// v holds the blended values of
+	// the lanes in vk that produce another value
+	v, vk *value
+	// nullk is the set of lanes that produce NULL
+	nullk *value
+}
+
+// caseArms compiles each arm of c for the lanes
+// for which it is the first matching WHEN (or ELSE)
+// and merges the result of each arm into the
+// output for the type it produces
+//
+// arms.s is nil if none of the string arms can be
+// selected, and arms.v is nil if none of the other
+// arms can be selected
+func (p *prog) caseArms(c *expr.Case) (arms caseArms, err error) {
+	arms.sk = p.ssa0(skfalse)
+	arms.vk = p.ssa0(skfalse)
+	arms.nullk = p.ssa0(skfalse)
+	merged := p.ssa0(skfalse) // has a previous WHEN already matched?
+
+	// merge the result of e into
+	// the output for the lanes in live
+	arm := func(e expr.Node, live *value) error {
+		switch e.(type) {
+		case expr.Null:
+			arms.nullk = p.Or(arms.nullk, live)
+			return nil
+		case expr.Missing:
+			return nil
+		}
+		if !isStringArm(e) {
+			v, err := p.serialized(e)
+			if err != nil {
+				return err
+			}
+			if v.op == skfalse {
+				return nil
+			}
+			vk := p.And(p.mask(v), live)
+			if arms.v == nil {
+				arms.v = v
+			} else {
+				arms.v = p.ssa3(sblendv, arms.v, v, vk)
+			}
+			arms.vk = p.Or(arms.vk, vk)
+			return nil
+		}
+		str, err := p.compileAsString(e)
+		if err != nil {
+			return err
+		}
+		if str.op == skfalse {
+			return nil
+		}
+		if str.op == sliteral {
+			str = p.ssa2(stostr, str, p.mask(str))
+		}
+		strk := p.And(p.mask(str), live)
+		if arms.s == nil {
+			arms.s = str
+		} else {
+			arms.s = p.ssa3(sblendstr, arms.s, str, strk)
+		}
+		arms.sk = p.Or(arms.sk, strk)
+		return nil
+	}
+	for i := range c.Limbs {
+		when, err := p.compileAsBool(c.Limbs[i].When)
+		if err != nil {
+			return arms, err
+		}
+		if when.op == skfalse {
+			continue
+		}
+		// only lanes for which this is the
+		// first matching WHEN take this arm
+		live := p.nand(merged, when)
+		merged = p.Or(merged, when)
+		if live.op == skfalse {
+			continue
+		}
+		if err := arm(c.Limbs[i].Then, live); err != nil {
+			return arms, err
+		}
+	}
+	if c.Else != nil {
+		live := p.nand(merged, p.ValidLanes())
+		if live.op != skfalse {
+			if err := arm(c.Else, live); err != nil {
+				return arms, err
+			}
+		}
+	}
+	return arms, nil
 }
 
 func (p *prog) compileCast(c *expr.Cast) (*value, error) {
@@ -27,26 +27,36 @@ import (
 // of QuerySink that applies a filter to
 // incoming rows.
 type Filter struct {
-	prog *prog
-	rest QuerySink // rest of sub-query
+	prog  *prog
+	rest  QuerySink // rest of sub-query
+	timer *CompileTimer
 }
 
 // NewFilter constructs a Filter from a boolean expression.
 // The returned Filter will write rows for which e evaluates
-// to TRUE to rest.
-func NewFilter(e expr.Node, rest QuerySink) *Filter {
+// to TRUE to rest. NewFilter returns an error if e
+// cannot be compiled.
+func NewFilter(e expr.Node, rest QuerySink) (*Filter, error) {
 	prog, err := compileLogical(e)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	prog.Renumber()
-	return where(prog, rest)
+	return where(prog, rest), nil
 }
 
 func where(p *prog, rest QuerySink) *Filter {
 	return &Filter{prog: p, rest: rest}
 }
 
+// SetCompileTimer sets the CompileTimer
+// that records the time spent compiling
+// the filter. SetCompileTimer must be
+// called before Open.
+func (r *Filter) SetCompileTimer(t *CompileTimer) {
+	r.timer = t
+}
+
 // Open implements QuerySink.Open
 func (r *Filter) Open() (io.WriteCloser, error) {
 	q, err := r.rest.Open()
@@ -56,7 +66,7 @@ func (r *Filter) Open() (io.WriteCloser, error) {
 	// we know we'd like to write to a RowConsumer,
 	// so determine if we have one already or if we
 	// need to create one with a rematerializer
-	return splitter(&wherebc{parent: r, dst: asRowConsumer(q)}), nil
+	return splitter(&wherebc{parent: r, bc: bytecode{timer: r.timer}, dst: asRowConsumer(q)}), nil
 }
 
 // Close implements io.Closer
@@ -98,7 +108,14 @@ type wherebc struct {
 }
 
 //go:noescape
-func evalfilterbc(w *bytecode, delims []vmref) int
+func evalfilterbcAVX512(w *bytecode, delims []vmref) int
+
+func evalfilterbc(w *bytecode, delims []vmref) int {
+	if portable {
+		return evalfilterbcGo(w, delims)
+	}
+	return evalfilterbcAVX512(w, delims)
+}
 
 func (w *wherebc) symbolize(st *ion.Symtab) error {
 	err := recompile(st, w.parent.prog, &w.ssa, &w.bc)
@@ -47,10 +47,39 @@ type HashAggregate struct {
 	final *aggtable
 	limit int
 
+	budget *Budget
+	timer  *CompileTimer
+
+	// spill holds the partitions of
+	// aggregate state that have been
+	// written to disk (see SpillDir);
+	// it is nil if nothing has been spilled
+	spillLock sync.Mutex
+	spill     []*spillFile
+
 	// ordering functions;
 	// applied in order to determine
 	// the total ordering
-	order []func(*aggtable, hpair, hpair) int
+	order []func(left, right *aggrow) int
+}
+
+// aggrow is a single aggregated group:
+// the concatenated grouping values
+// and the associated aggregate data
+type aggrow struct {
+	hash  uint64
+	repr  []byte
+	value []byte
+}
+
+// column returns the idx'th grouping value
+func (r *aggrow) column(idx int) []byte {
+	mem := r.repr
+	for idx > 0 {
+		mem = mem[ion.SizeOf(mem):]
+		idx--
+	}
+	return mem[:ion.SizeOf(mem)]
 }
 
 // Limit sets the maximum number of output rows.
@@ -59,6 +88,19 @@ func (h *HashAggregate) Limit(n int) {
 	h.limit = n
 }
 
+// SetBudget sets the Budget from which
+// the aggregate reserves memory for its
+// hash tables and programs.
+func (h *HashAggregate) SetBudget(b *Budget) {
+	h.budget = b
+}
+
+// SetCompileTimer sets the CompileTimer that
+// records the time the aggregate spends compiling.
+func (h *HashAggregate) SetCompileTimer(t *CompileTimer) {
+	h.timer = t
+}
+
 func (h *HashAggregate) OrderByGroup(n int, desc bool, nullslast bool) error {
 	if n < 0 || n >= len(h.by) {
 		return fmt.Errorf("group %d doesn't exist", n)
@@ -72,10 +114,8 @@ func (h *HashAggregate) OrderByGroup(n int, desc bool, nullslast bool) error {
 	if nullslast {
 		o.Nulls = ionsort.NullsLast
 	}
-	h.order = append(h.order, func(agt *aggtable, left, right hpair) int {
-		leftmem := agt.repridx(&left, n)
-		rightmem := agt.repridx(&right, n)
-		return o.Compare(leftmem, rightmem)
+	h.order = append(h.order, func(left, right *aggrow) int {
+		return o.Compare(left.column(n), right.column(n))
 	})
 	return nil
 }
@@ -85,10 +125,8 @@ func (h *HashAggregate) OrderByAggregate(n int, desc bool) error {
 		return fmt.Errorf("aggregate %d doesn't exist", n)
 	}
 	aggregateKind := h.aggregateKinds[n]
-	h.order = append(h.order, func(agt *aggtable, left, right hpair) int {
-		lmem := agt.valueof(&left)
-		rmem := agt.valueof(&right)
-		dir := aggcmp(aggregateKind, lmem, rmem)
+	h.order = append(h.order, func(left, right *aggrow) int {
+		dir := aggcmp(aggregateKind, left.value, right.value)
 		if desc {
 			return -dir
 		}
@@ -267,34 +305,125 @@ func NewHashAggregate(agg Aggregation, by Selection, dst QuerySink) (*HashAggreg
 	return h, nil
 }
 
-func (h *HashAggregate) Open() (io.WriteCloser, error) {
-	at := &aggtable{
-		parent: h,
-		tree:   newRadixTree(len(h.initialData)),
+func (h *HashAggregate) newtable() *aggtable {
+	return &aggtable{
+		parent:         h,
+		tree:           newRadixTree(len(h.initialData)),
+		aggregateKinds: h.aggregateKinds,
+		bc:             bytecode{budget: h.budget, timer: h.timer},
+		mem:            memtrack{budget: h.budget},
 	}
-	at.aggregateKinds = h.aggregateKinds
+}
 
+func (h *HashAggregate) Open() (io.WriteCloser, error) {
+	at := h.newtable()
 	atomic.AddInt64(&h.children, 1)
 	return splitter(at), nil
 }
 
-func (h *HashAggregate) sort(pairs []hpair) {
+func (h *HashAggregate) compare(left, right *aggrow) int {
+	for k := range h.order {
+		dir := h.order[k](left, right)
+		if dir != 0 {
+			return dir
+		}
+	}
+	return 0
+}
+
+func (h *HashAggregate) sort(t *aggtable) {
 	if h.order == nil {
 		return
 	}
-	slices.SortFunc(pairs, func(left, right hpair) bool {
-		for k := range h.order {
-			dir := h.order[k](h.final, left, right)
-			if dir < 0 {
-				return true
+	slices.SortFunc(t.pairs, func(left, right hpair) bool {
+		lrow := t.row(&left)
+		rrow := t.row(&right)
+		return h.compare(&lrow, &rrow) < 0
+	})
+}
+
+func (h *HashAggregate) spilled() bool {
+	h.spillLock.Lock()
+	defer h.spillLock.Unlock()
+	return h.spill != nil
+}
+
+// aggoutput serializes the output rows of a HashAggregate
+type aggoutput struct {
+	parent  *HashAggregate
+	dst     io.Writer
+	st      ion.Symtab
+	buf     ion.Buffer
+	bysyms  []ion.Symbol
+	aggsyms []ion.Symbol
+	offsets []int // offset of each aggregate within aggrow.value
+}
+
+// aggOutputFlushSize is the size of the output
+// buffer at which aggoutput writes to the destination
+const aggOutputFlushSize = 1 << 20
+
+func (h *HashAggregate) newOutput(dst io.Writer) *aggoutput {
+	o := &aggoutput{parent: h, dst: dst}
+	for i := range h.by {
+		o.bysyms = append(o.bysyms, o.st.Intern(h.by[i].Result()))
+	}
+	off := 0
+	for i := range h.agg {
+		o.aggsyms = append(o.aggsyms, o.st.Intern(h.agg[i].Result))
+		o.offsets = append(o.offsets, off)
+		off += int(aggregateKindInfoTable[h.aggregateKinds[i]].dataSize)
+	}
+	o.st.Marshal(&o.buf, true)
+	return o
+}
+
+// write emits one output record;
+// we take special care to emit the
+// fields in an order that guarantees
+// that the symbol IDs are sorted
+func (o *aggoutput) write(row *aggrow) error {
+	h := o.parent
+	o.buf.BeginStruct(-1)
+	prevsym := ion.Symbol(0)
+	for _, pos := range h.pos2id {
+		if pos < len(h.by) {
+			sym := o.bysyms[pos]
+			if sym < prevsym {
+				panic("symbols out-of-order")
 			}
-			if dir > 0 {
-				return false
+			prevsym = sym
+			o.buf.BeginField(sym)
+			o.buf.UnsafeAppend(row.column(pos))
+		} else {
+			pos -= len(o.bysyms)
+			sym := o.aggsyms[pos]
+			if sym < prevsym {
+				panic("symbols out-of-order")
 			}
-			// dir == 0 -> equal, continue
+			prevsym = sym
+			o.buf.BeginField(sym)
+			writeAggregatedValue(&o.buf, row.value[o.offsets[pos]:], h.aggregateKinds[pos])
 		}
-		return false
-	})
+	}
+	o.buf.EndStruct()
+	if o.buf.Size() >= aggOutputFlushSize {
+		return o.flush()
+	}
+	return nil
+}
+
+func (o *aggoutput) flush() error {
+	// NOTE: we are triggering a vm copy here;
+	// we're doing this deliberately because
+	// typically the result is small (so, cheap)
+	// or the result is large in which case
+	// the RowSplitter will take care to split
+	// it up into small pieces before copying
+	_, err := o.dst.Write(o.buf.Bytes())
+	o.buf.Reset()
+	o.st.Marshal(&o.buf, true)
+	return err
 }
 
 func (h *HashAggregate) Close() error {
@@ -306,97 +435,168 @@ func (h *HashAggregate) Close() error {
 		return fmt.Errorf("HashAggregate.final == nil, didn't compute any aggregates?")
 	}
 
-	var outst ion.Symtab
-	var outbuf ion.Buffer
-
-	var aggsyms []ion.Symbol
-	var bysyms []ion.Symbol
-
-	for i := range h.by {
-		bysyms = append(bysyms, outst.Intern(h.by[i].Result()))
+	// finally, write the output...
+	dst, err := h.dst.Open()
+	if err != nil {
+		return err
 	}
-	for i := range h.agg {
-		aggsyms = append(aggsyms, outst.Intern(h.agg[i].Result))
+	out := h.newOutput(dst)
+	if h.spill != nil {
+		err = h.writeSpilled(out)
+	} else {
+		err = h.writeFinal(out)
+	}
+	if err == nil {
+		err = out.flush()
+	}
+	h.final.mem.release()
+	h.final = nil
+	if err != nil {
+		dst.Close()
+		return err
 	}
 
-	outst.Marshal(&outbuf, true)
+	// close the threading context
+	// *and* the destination query sink
+	err = dst.Close()
+	err2 := h.dst.Close()
+	if err == nil {
+		err = err2
+	}
+	return err
+}
 
+// writeFinal writes the output when
+// all of the state is held in memory
+func (h *HashAggregate) writeFinal(out *aggoutput) error {
 	// perform ORDER BY and LIMIT steps
+	h.sort(h.final)
 	pairs := h.final.pairs
-	h.sort(pairs)
 	if h.limit > 0 && len(pairs) > h.limit {
 		pairs = pairs[:h.limit]
 	}
+	for i := range pairs {
+		row := h.final.row(&pairs[i])
+		if err := out.write(&row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// for each of the pairs,
-	// emit the records;
-	// we take special care to
-	// emit the fields in an order that
-	// guarantees that the symbol IDs are sorted
-	aggregateKinds := h.aggregateKinds
+// writeSpilled writes the output when some of
+// the state has been spilled to disk;
+// each partition is merged in memory separately,
+// and when there is an ORDER BY the sorted partitions
+// are written to runs that are merged at the end
+func (h *HashAggregate) writeSpilled(out *aggoutput) error {
+	var runs []*spillFile
+	defer func() {
+		closeSpillFiles(runs)
+		closeSpillFiles(h.spill)
+		h.spill = nil
+	}()
+	// spill whatever is left in memory so that
+	// each group lives in exactly one partition
+	if err := h.final.spill(); err != nil {
+		return err
+	}
 
-	// turn the i'th 'agg' output
-	// into an offset
-	offset := func(i int) int {
-		off := 0
-		for _, k := range h.aggregateKinds[:i] {
-			off += int(aggregateKindInfoTable[k].dataSize)
+	remaining := h.limit
+	for i := range h.spill {
+		t, err := h.loadPartition(h.spill[i])
+		if err != nil {
+			return err
+		}
+		done, err := h.writePartition(t, out, &remaining, &runs)
+		t.mem.release()
+		if done || err != nil {
+			return err
 		}
-		return off
 	}
-
-	for i := range pairs {
-		outbuf.BeginStruct(-1)
-		valmem := h.final.valueof(&pairs[i])
-		prevsym := ion.Symbol(0)
-		for _, pos := range h.pos2id {
-			if pos < len(h.by) {
-				sym := bysyms[pos]
-				if sym < prevsym {
-					panic("symbols out-of-order")
-				}
-				prevsym = sym
-				outbuf.BeginField(sym)
-				outval := h.final.repridx(&pairs[i], pos)
-				outbuf.UnsafeAppend(outval)
-			} else {
-				pos -= len(bysyms)
-				sym := aggsyms[pos]
-				if sym < prevsym {
-					panic("symbols out-of-order")
-				}
-				prevsym = sym
-				outbuf.BeginField(aggsyms[pos])
-				writeAggregatedValue(&outbuf, valmem[offset(pos):], aggregateKinds[pos])
+	if h.order == nil {
+		return nil
+	}
+	less := func(left, right *aggrow) bool {
+		return h.compare(left, right) < 0
+	}
+	emit := func(row *aggrow) (bool, error) {
+		if h.limit > 0 {
+			if remaining == 0 {
+				return false, nil
 			}
+			remaining--
 		}
-		outbuf.EndStruct()
+		return true, out.write(row)
 	}
-
-	h.final = nil
-	// finally, write the output...
-	dst, err := h.dst.Open()
+	iters, err := fileIters(runs, h.decodeRow)
 	if err != nil {
 		return err
 	}
-	// NOTE: we are triggering a vm copy here;
-	// we're doing this deliberately because
-	// typically the result is small (so, cheap)
-	// or the result is large in which case
-	// the RowSplitter will take care to split
-	// it up into small pieces before copying
-	_, err = dst.Write(outbuf.Bytes())
+	return mergeRuns(iters, less, emit)
+}
+
+// writePartition writes the groups in t directly
+// to out when there is no ORDER BY, or otherwise
+// sorts them and writes them to a new run in runs;
+// it returns true if the LIMIT has been reached
+func (h *HashAggregate) writePartition(t *aggtable, out *aggoutput, remaining *int, runs *[]*spillFile) (bool, error) {
+	if h.order == nil {
+		for j := range t.pairs {
+			if h.limit > 0 {
+				if *remaining == 0 {
+					return true, nil
+				}
+				*remaining--
+			}
+			row := t.row(&t.pairs[j])
+			if err := out.write(&row); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+	h.sort(t)
+	run, err := newSpillFile()
 	if err != nil {
-		dst.Close()
-		return err
+		return false, err
 	}
+	*runs = append(*runs, run)
+	return false, t.writeTo(run, t.pairs)
+}
 
-	// close the threading context
-	// *and* the destination query sink
-	err = dst.Close()
-	err2 := h.dst.Close()
-	if err == nil {
-		err = err2
+// loadPartition merges all of the
+// spilled entries in f into a new table
+func (h *HashAggregate) loadPartition(f *spillFile) (*aggtable, error) {
+	t := h.newtable()
+	var r spillReader
+	if err := f.rewind(&r); err != nil {
+		return nil, err
 	}
-	return err
+	var row aggrow
+	for {
+		frame, err := r.next()
+		if err == io.EOF {
+			return t, t.mem.set(t.memsize())
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := h.decodeRow(frame, &row); err != nil {
+			return nil, err
+		}
+		t.mergeRow(row.hash, row.repr, row.value)
+	}
+}
+
+// decodeRow decodes a frame written by aggtable.writeTo
+func (h *HashAggregate) decodeRow(frame []byte, row *aggrow) error {
+	vsize := len(h.initialData)
+	if len(frame) < aggregateTagSize+vsize {
+		return fmt.Errorf("corrupt spilled aggregate entry")
+	}
+	row.hash = le64(frame)
+	row.repr = frame[aggregateTagSize : len(frame)-vsize]
+	row.value = frame[len(frame)-vsize:]
+	return nil
 }
@@ -0,0 +1,682 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"reflect"
+	"unsafe"
+
+	"golang.org/x/sys/cpu"
+)
+
+// portable is set when bytecode is executed
+// by the portable interpreter in this file
+// rather than by the AVX-512 implementation
+// in evalbc_amd64.s
+//
+// The portable interpreter implements the
+// subset of opcodes that have an entry in
+// interpops; programs that use any other
+// opcode are rejected when they are compiled
+// (see checkPortable).
+var portable = !hasAVX512()
+
+func hasAVX512() bool {
+	return cpu.X86.HasAVX512 &&
+		cpu.X86.HasAVX512BW &&
+		cpu.X86.HasAVX512DQ &&
+		cpu.X86.HasAVX512VL &&
+		cpu.X86.HasAVX512CD
+}
+
+// Portable returns true if the CPU does not
+// support AVX-512, in which case queries are
+// executed by a (much slower) portable interpreter
+// that supports only a subset of the bytecode.
+func Portable() bool { return portable }
+
+// errNotPortable is the error returned for
+// queries that the portable interpreter
+// cannot execute
+func errNotPortable(what string) error {
+	return fmt.Errorf("%s is not supported by the portable interpreter", what)
+}
+
+// checkPortable returns an error if the
+// compiled program uses an opcode that
+// the portable interpreter does not implement
+func checkPortable(code []byte) error {
+	for pc := 0; pc+2 <= len(code); {
+		op := bcop(binary.LittleEndian.Uint16(code[pc:]))
+		if int(op) >= len(interpops) {
+			return fmt.Errorf("invalid opcode %d", op)
+		}
+		info := &opinfo[op]
+		if op != opret && interpops[op] == nil {
+			return errNotPortable("opcode " + info.text)
+		}
+		pc += 2
+		for _, imm := range info.imms {
+			pc += int(bcImmWidth[imm])
+		}
+	}
+	return nil
+}
+
+// interp is the state of the portable interpreter
+//
+// The fields correspond to the registers
+// described in bc_amd64.h, and each opcode
+// produces exactly the same register state
+// as its assembly implementation.
+type interp struct {
+	bc  *bytecode
+	mem []byte // SI = base of all offsets
+	pc  int    // offset of the next byte in bc.compiled
+
+	k1 uint16 // K1 = current mask
+	k7 uint16 // K7 = valid lanes
+
+	b [2][16]uint32 // Z0:Z1 = struct base and length
+	s [16]uint64    // Z2:Z3 = scalar
+	v [2][16]uint32 // Z30:Z31 = value base and length
+
+	vstack []byte       // VIRT_VALUES
+	agg    []byte       // R10 = aggregate buffer
+	tree   *radixTree64 // R10 = hash aggregate tree
+
+	// lanes that aggbucket could not locate
+	// in tree when it aborted the program
+	abort uint16
+
+	// displacement used by eqv4mask+ and eqv8+
+	disp uint32
+}
+
+// vstackBytes returns the memory of vstack as bytes
+func vstackBytes(vstack []uint64) (out []byte) {
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&vstack))
+	outhdr := (*reflect.SliceHeader)(unsafe.Pointer(&out))
+	outhdr.Data = hdr.Data
+	outhdr.Len = hdr.Len * 8
+	outhdr.Cap = hdr.Cap * 8
+	return
+}
+
+func newInterp(bc *bytecode, mem []byte) *interp {
+	return &interp{
+		bc:     bc,
+		mem:    mem,
+		vstack: vstackBytes(bc.vstack),
+	}
+}
+
+// enter corresponds to VMENTER: it executes
+// the program with K7 set to the current mask
+// after resetting the scratch buffer
+func (p *interp) enter() bool {
+	p.k7 = p.k1
+	p.bc.scratch = p.bc.scratch[:p.bc.scratchreserve]
+	return p.exec()
+}
+
+// exec executes the program from the beginning
+// and returns false if it aborted
+func (p *interp) exec() bool {
+	code := p.bc.compiled
+	p.pc = 0
+	for {
+		op := bcop(binary.LittleEndian.Uint16(code[p.pc:]))
+		p.pc += 2
+		if op == opret {
+			return true
+		}
+		var fn func(*interp) bool
+		if int(op) < len(interpops) {
+			fn = interpops[op]
+		}
+		if fn == nil {
+			p.bc.err = bcerrNotSupported
+			p.bc.errpc = int32(p.pc - 2)
+			p.bc.errinfo = int(op)
+			return false
+		}
+		if !fn(p) {
+			return false
+		}
+	}
+}
+
+// fail aborts the program with the given error
+func (p *interp) fail(err bcerr) bool {
+	p.bc.err = err
+	p.bc.errpc = int32(p.pc)
+	return false
+}
+
+func (p *interp) imm8() uint8 {
+	x := p.bc.compiled[p.pc]
+	p.pc++
+	return x
+}
+
+func (p *interp) imm16() uint16 {
+	x := binary.LittleEndian.Uint16(p.bc.compiled[p.pc:])
+	p.pc += 2
+	return x
+}
+
+func (p *interp) imm32() uint32 {
+	x := binary.LittleEndian.Uint32(p.bc.compiled[p.pc:])
+	p.pc += 4
+	return x
+}
+
+func (p *interp) imm64() uint64 {
+	x := binary.LittleEndian.Uint64(p.bc.compiled[p.pc:])
+	p.pc += 8
+	return x
+}
+
+// load32 and load64 perform the equivalent
+// of a gather from SI; bytes past the end
+// of p.mem read as zero
+func (p *interp) load32(off uint32) uint32 {
+	if uint64(off)+4 <= uint64(len(p.mem)) {
+		return binary.LittleEndian.Uint32(p.mem[off:])
+	}
+	var buf [4]byte
+	if uint64(off) < uint64(len(p.mem)) {
+		copy(buf[:], p.mem[off:])
+	}
+	return binary.LittleEndian.Uint32(buf[:])
+}
+
+func (p *interp) load64(off uint32) uint64 {
+	if uint64(off)+8 <= uint64(len(p.mem)) {
+		return binary.LittleEndian.Uint64(p.mem[off:])
+	}
+	return uint64(p.load32(off)) | uint64(p.load32(off+4))<<32
+}
+
+// memeq returns whether the n bytes at off equal str
+func (p *interp) memeq(off uint32, str string) bool {
+	if uint64(off)+uint64(len(str)) <= uint64(len(p.mem)) {
+		return string(p.mem[off:off+uint32(len(str))]) == str
+	}
+	for i := 0; i < len(str); i++ {
+		if byte(p.load32(off+uint32(i))) != str[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// stack slot accessors; 32-bit slots hold
+// the offsets in words 0-15 and the lengths
+// in words 16-31
+func (p *interp) stk16(slot uint16) uint16 {
+	return binary.LittleEndian.Uint16(p.vstack[slot:])
+}
+
+func (p *interp) setstk16(slot, x uint16) {
+	binary.LittleEndian.PutUint16(p.vstack[slot:], x)
+}
+
+func (p *interp) stk32(slot uint16, i int) uint32 {
+	return binary.LittleEndian.Uint32(p.vstack[int(slot)+4*i:])
+}
+
+func (p *interp) setstk32(slot uint16, i int, x uint32) {
+	binary.LittleEndian.PutUint32(p.vstack[int(slot)+4*i:], x)
+}
+
+func (p *interp) stk64(slot uint16, i int) uint64 {
+	return binary.LittleEndian.Uint64(p.vstack[int(slot)+8*i:])
+}
+
+func (p *interp) setstk64(slot uint16, i int, x uint64) {
+	binary.LittleEndian.PutUint64(p.vstack[int(slot)+8*i:], x)
+}
+
+// sd returns the i'th 32-bit word of Z2:Z3;
+// when the scalar holds slices, words 0-15
+// are the offsets and words 16-31 are the lengths
+func (p *interp) sd(i int) uint32 {
+	return uint32(p.s[i>>1] >> (32 * (i & 1)))
+}
+
+func (p *interp) setsd(i int, x uint32) {
+	shift := 32 * (i & 1)
+	p.s[i>>1] = p.s[i>>1]&^(0xffffffff<<shift) | uint64(x)<<shift
+}
+
+func lane(k uint16, i int) bool { return k&(1<<i) != 0 }
+
+// loadDelims loads up to 16 delimiters into Z0:Z1,
+// zeroing the unused lanes, and sets K1 accordingly
+func (p *interp) loadDelims(delims []vmref) {
+	n := len(delims)
+	if n > 16 {
+		n = 16
+	}
+	p.k1 = uint16(1<<n - 1)
+	for i := 0; i < 16; i++ {
+		if i < n {
+			p.b[0][i], p.b[1][i] = delims[i][0], delims[i][1]
+		} else {
+			p.b[0][i], p.b[1][i] = 0, 0
+		}
+	}
+}
+
+// portable implementations of the
+// assembly entry points; see the
+// callers of each for documentation
+
+func evalfilterbcGo(bc *bytecode, delims []vmref) int {
+	p := newInterp(bc, vmm[:])
+	out := 0
+	for i := 0; i < len(delims); i += 16 {
+		p.loadDelims(delims[i:])
+		p.v = [2][16]uint32{}
+		// like the assembly, keep going
+		// after an abort; the caller checks bc.err
+		p.enter()
+		for j := 0; j < 16; j++ {
+			if lane(p.k1, j) {
+				delims[out] = vmref{p.b[0][j], p.b[1][j]}
+				out++
+			}
+		}
+	}
+	return out
+}
+
+func evalaggregatebcGo(bc *bytecode, delims []vmref, buf []byte) int {
+	p := newInterp(bc, vmm[:])
+	p.agg = buf
+	for i := 0; i < len(delims); i += 16 {
+		p.loadDelims(delims[i:])
+		p.v = [2][16]uint32{}
+		p.enter()
+	}
+	return 0
+}
+
+func evalfindbcGo(bc *bytecode, delims []vmref, stride int) {
+	bc.scratch = bc.scratch[:bc.scratchreserve]
+	bc.err = 0
+	p := newInterp(bc, vmm[:])
+	stack := p.vstack
+	for i, base := 0, 0; i < len(delims); i, base = i+16, base+stride {
+		p.loadDelims(delims[i:])
+		// no VMENTER here: the scratch buffer
+		// and Z30:Z31 are preserved across groups
+		p.k7 = p.k1
+		p.vstack = stack[base:]
+		if !p.exec() {
+			return
+		}
+	}
+}
+
+func evalprojectGo(bc *bytecode, delims []vmref, dst []byte, symbols []syminfo) (int, int) {
+	p := newInterp(bc, vmm[:])
+	dstoff := vmoff(dst)
+	rows, out := 0, 0
+	for rows < len(delims) {
+		p.loadDelims(delims[rows:])
+		start := out
+		if !p.enter() {
+			return 0, 0
+		}
+		for j := 0; j < 16; j++ {
+			if !lane(p.k1, j) {
+				continue
+			}
+			size := 0
+			for f := range symbols {
+				if n := p.stk32(uint16(f*vRegSize), 16+j); n != 0 {
+					size += int(n) + int(symbols[f].size)
+				}
+			}
+			// leave 13 bytes of slack, just like
+			// the assembly version; note that an early
+			// return reports the rows written in this
+			// group but not the bytes
+			if out > len(dst)-13-size {
+				return start, rows
+			}
+			delims[rows][1] = uint32(size)
+			dst[out] = 0xde
+			out++
+			out += putuvarint(dst[out:], uint(size))
+			delims[rows][0] = dstoff + uint32(out)
+			rows++
+			for f := range symbols {
+				slot := uint16(f * vRegSize)
+				n := p.stk32(slot, 16+j)
+				if n == 0 {
+					continue
+				}
+				sym := symbols[f]
+				var enc [4]byte
+				binary.LittleEndian.PutUint32(enc[:], sym.encoded)
+				out += copy(dst[out:], enc[:sym.size])
+				off := p.stk32(slot, j)
+				out += copy(dst[out:], vmm[off:off+n])
+			}
+		}
+	}
+	return out, rows
+}
+
+func evalhashaggGo(bc *bytecode, delims []vmref, tree *radixTree64, abort *uint16) int {
+	p := newInterp(bc, vmm[:])
+	p.tree = tree
+	*abort = 0
+	rows := 0
+	for rows < len(delims) {
+		p.loadDelims(delims[rows:])
+		p.v = [2][16]uint32{}
+		if !p.enter() {
+			// the rows in this group have not
+			// been aggregated; the caller inserts
+			// the missing lanes and starts over
+			*abort = p.abort
+			return rows
+		}
+		rows += bits.OnesCount16(p.k7)
+	}
+	return rows
+}
+
+func evaldedupGo(bc *bytecode, delims []vmref, hashes []uint64, tree *radixTree64, slot int) int {
+	p := newInterp(bc, vmm[:])
+	out := 0
+	for i := 0; i < len(delims); i += 16 {
+		p.loadDelims(delims[i:])
+		p.enter()
+		h := bc.hashmem[slot*32:]
+		k := p.k1
+		for j := 0; j < 16; j++ {
+			if !lane(k, j) {
+				continue
+			}
+			// like VPCONFLICTQ, drop lanes with the
+			// same hash as an earlier lane (active or not)
+			// in the same group of eight
+			for m := j &^ 7; m < j; m++ {
+				if h[2*m] == h[2*j] {
+					k &^= 1 << j
+					break
+				}
+			}
+			if lane(k, j) && tree.Offset(h[2*j]) >= 0 {
+				k &^= 1 << j
+			}
+		}
+		for j := 0; j < 16; j++ {
+			if lane(k, j) {
+				hashes[out] = h[2*j]
+				delims[out] = vmref{p.b[0][j], p.b[1][j]}
+				out++
+			}
+		}
+	}
+	return out
+}
+
+func evalsplatGo(bc *bytecode, indelims, outdelims []vmref, perm []int32) (int, int) {
+	p := newInterp(bc, vmm[:])
+	p.loadDelims(indelims)
+	p.enter()
+	k := p.k1
+	if k == 0 {
+		return 0, 0
+	}
+	lanes, out := 0, 0
+	for {
+		if out >= len(outdelims) {
+			break
+		}
+		if lane(k, 0) {
+			off := uint64(p.sd(lanes))
+			end := off + uint64(p.sd(16+lanes))
+			for off < end {
+				w := p.load64(uint32(off))
+				size := uint64(1)
+				switch w & 0x0f {
+				case 0x0f:
+				case 0x0e:
+					// the assembly decodes the varint
+					// from the eight bytes that it loaded
+					length, hdr := uint64(0), uint64(1)
+					for i := 0; i < 7; i++ {
+						hdr++
+						w >>= 8
+						length = length<<7 + w&0x7f
+						if w&0x80 != 0 {
+							break
+						}
+					}
+					size = length + hdr
+				default:
+					size = w&0x0f + 1
+				}
+				outdelims[out] = vmref{uint32(off), uint32(size)}
+				perm[out] = int32(lanes)
+				out++
+				off += size
+				if out >= len(outdelims) {
+					return lanes, out
+				}
+			}
+		}
+		lanes++
+		if k >>= 1; k == 0 {
+			break
+		}
+	}
+	return lanes, out
+}
+
+func evalunnestGo(bc *bytecode, delims []vmref, perm []int32, dst []byte, symbols []syminfo) (int, int) {
+	p := newInterp(bc, vmm[:])
+	dstoff := vmoff(dst)
+	rows, out := 0, 0
+	for rows < len(delims) {
+		// the rows are loaded into Z30:Z31
+		// (the inner program parses them) and
+		// the permutation into the outer bindings
+		// is loaded alongside them
+		n := len(delims) - rows
+		if n > 16 {
+			n = 16
+		}
+		p.k1 = uint16(1<<n - 1)
+		p.b = [2][16]uint32{}
+		p.v = [2][16]uint32{}
+		bc.perm = [16]int32{}
+		for i := 0; i < n; i++ {
+			p.v[0][i], p.v[1][i] = delims[rows+i][0], delims[rows+i][1]
+			bc.perm[i] = perm[rows+i]
+		}
+		p.enter()
+		start := out
+		// like the assembly, the rows following
+		// the last active lane are not consumed
+		k := p.k1
+		for j := 0; ; j++ {
+			if !lane(k, j) {
+				delims[rows] = vmref{}
+			} else {
+				size := 0
+				for f := range symbols {
+					if n := p.stk32(uint16(f*vRegSize), 16+j); n != 0 {
+						size += int(n) + int(symbols[f].size)
+					}
+				}
+				// see evalprojectGo
+				if out > len(dst)-13-size {
+					return start, rows
+				}
+				delims[rows][1] = uint32(size)
+				dst[out] = 0xde
+				out++
+				out += putuvarint(dst[out:], uint(size))
+				delims[rows][0] = dstoff + uint32(out)
+				for f := range symbols {
+					slot := uint16(f * vRegSize)
+					n := p.stk32(slot, 16+j)
+					if n == 0 {
+						continue
+					}
+					sym := symbols[f]
+					var enc [4]byte
+					binary.LittleEndian.PutUint32(enc[:], sym.encoded)
+					out += copy(dst[out:], enc[:sym.size])
+					off := p.stk32(slot, j)
+					out += copy(dst[out:], vmm[off:off+n])
+				}
+			}
+			rows++
+			if k>>(j+1) == 0 {
+				break
+			}
+		}
+	}
+	return out, rows
+}
+
+// vmoff returns the offset of buf relative to vmm
+func vmoff(buf []byte) uint32 {
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&buf))
+	return uint32(hdr.Data - vmbase())
+}
+
+// putuvarint writes x as an ion uvarint
+// and returns the number of bytes written
+func putuvarint(dst []byte, x uint) int {
+	n := 1
+	for y := x >> 7; y != 0; y >>= 7 {
+		n++
+	}
+	for i := n - 1; i >= 0; i-- {
+		dst[i] = byte(x & 0x7f)
+		x >>= 7
+	}
+	dst[n-1] |= 0x80
+	return n
+}
+
+// scanbody is the portable equivalent of
+// scanbody in scan_amd64.s; it scans mem
+// from start to end and returns the number
+// of delimiters stored and the next offset
+func scanbody(mem []byte, start, end uint32, dst []vmref) (int, uint32) {
+	p := interp{mem: mem}
+	count := 0
+	off := start
+	if int32(off) >= int32(end) || count >= len(dst) {
+		return count, off
+	}
+	// uvarint decodes the length following
+	// a descriptor with a varint length;
+	// it returns false if there is no stop bit
+	// in the next three bytes
+	uvarint := func(w uint64) (uint32, bool) {
+		if w&0x80808000 == 0 {
+			return 0, false
+		}
+		length := uint32(0)
+		for {
+			length <<= 7
+			w >>= 8
+			off++
+			length += uint32(w & 0x7f)
+			if w&0x80 != 0 {
+				return length, true
+			}
+		}
+	}
+	for {
+		prev := off
+		w := p.load64(off)
+		off++
+		var length uint32
+		if w&0xf0 != 0xd0 {
+			// skip a non-structure value
+			// (or the binary version marker)
+			if w&0xff == 0xe0 {
+				length = 3
+			} else if length = uint32(w & 0x0f); length == 0x0e {
+				var ok bool
+				length, ok = uvarint(w)
+				if !ok {
+					return count, prev
+				}
+			}
+			off += length
+			if int32(off) < int32(end) {
+				continue
+			}
+			return count, off
+		}
+		if length = uint32(w & 0x0f); length == 0x0e {
+			var ok bool
+			length, ok = uvarint(w)
+			if !ok {
+				return count, prev
+			}
+		}
+		next := off + length
+		if next > end {
+			return count, prev
+		}
+		dst[count] = vmref{off, length}
+		count++
+		off = next
+		if next == end || count >= len(dst) {
+			return count, off
+		}
+	}
+}
+
+func scanGo(buf []byte, start int32, dst [][2]uint32) (int, int32) {
+	count, next := scanbody(buf[:cap(buf)], uint32(start), uint32(len(buf)), *(*[]vmref)(unsafe.Pointer(&dst)))
+	return count, int32(next)
+}
+
+func scanvmmGo(buf []byte, dst []vmref) (int, int32) {
+	start := vmoff(buf)
+	count, next := scanbody(vmm[:], start, start+uint32(len(buf)), dst)
+	return count, int32(next - start)
+}
+
+func compressGo(delims []vmref) int {
+	n := 0
+	for i := range delims {
+		if delims[i] != (vmref{}) {
+			delims[n] = delims[i]
+			n++
+		}
+	}
+	return n
+}
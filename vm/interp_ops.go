@@ -0,0 +1,1583 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// interpops is the opcode table of the portable
+// interpreter; each function returns false
+// if the program should abort
+//
+// See the corresponding bc* routines in
+// evalbc_amd64.s for the documentation of
+// each opcode.
+var interpops = [_maxbcop]func(p *interp) bool{
+	opjz: (*interp).jz,
+
+	oploadk:         (*interp).loadk,
+	opsavek:         (*interp).savek,
+	opxchgk:         (*interp).xchgk,
+	oploadb:         (*interp).loadb,
+	opsaveb:         (*interp).saveb,
+	oploadv:         (*interp).loadv,
+	opsavev:         (*interp).savev,
+	oploadzerov:     (*interp).loadzerov,
+	opsavezerov:     (*interp).savezerov,
+	opsaveblendv:    (*interp).saveblendv,
+	oploadpermzerov: (*interp).loadpermzerov,
+	oploads:         (*interp).loads,
+	opsaves:         (*interp).saves,
+	oploadzeros:     (*interp).loadzeros,
+	opsavezeros:     (*interp).savezeros,
+
+	opfalse:   (*interp).falsek,
+	opandk:    func(p *interp) bool { return p.maskop(func(k1, k2 uint16) uint16 { return k1 & k2 }) },
+	opork:     func(p *interp) bool { return p.maskop(func(k1, k2 uint16) uint16 { return k1 | k2 }) },
+	opandnotk: func(p *interp) bool { return p.maskop(func(k1, k2 uint16) uint16 { return k2 &^ k1 }) },
+	opnandk:   func(p *interp) bool { return p.maskop(func(k1, k2 uint16) uint16 { return k1 &^ k2 }) },
+	opxork:    func(p *interp) bool { return p.maskop(func(k1, k2 uint16) uint16 { return k1 ^ k2 }) },
+	opxnork:   func(p *interp) bool { return p.maskop(func(k1, k2 uint16) uint16 { return ^(k1 ^ k2) & p.k7 }) },
+	opnotk:    (*interp).notk,
+
+	opbroadcastimmf: (*interp).broadcastimm,
+	opbroadcastimmi: (*interp).broadcastimm,
+	opabsf:          func(p *interp) bool { return p.unaryf(math.Abs) },
+	opabsi:          func(p *interp) bool { return p.unaryi(absi) },
+	opnegf:          func(p *interp) bool { return p.unaryf(func(x float64) float64 { return 0 - x }) },
+	opnegi:          func(p *interp) bool { return p.unaryi(func(x int64) int64 { return 0 - x }) },
+
+	opaddf:    func(p *interp) bool { return p.arithf(p.slotf(), addf) },
+	opaddimmf: func(p *interp) bool { return p.arithf(p.immf(), addf) },
+	opaddi:    func(p *interp) bool { return p.arithi(p.sloti(), addi) },
+	opaddimmi: func(p *interp) bool { return p.arithi(p.immi(), addi) },
+	opsubf:    func(p *interp) bool { return p.arithf(p.slotf(), subf) },
+	opsubimmf: func(p *interp) bool { return p.arithf(p.immf(), subf) },
+	opsubi:    func(p *interp) bool { return p.arithi(p.sloti(), subi) },
+	opsubimmi: func(p *interp) bool { return p.arithi(p.immi(), subi) },
+	opmulf:    func(p *interp) bool { return p.arithf(p.slotf(), mulf) },
+	opmulimmf: func(p *interp) bool { return p.arithf(p.immf(), mulf) },
+	opmuli:    func(p *interp) bool { return p.arithi(p.sloti(), muli) },
+	opmulimmi: func(p *interp) bool { return p.arithi(p.immi(), muli) },
+	opdivf:    func(p *interp) bool { return p.arithf(p.slotf(), divf) },
+	opdivimmf: func(p *interp) bool { return p.arithf(p.immf(), divf) },
+
+	opcvtktof64:   func(p *interp) bool { return p.cvtk(math.Float64bits(1)) },
+	opcvtktoi64:   func(p *interp) bool { return p.cvtk(1) },
+	opcvti64tof64: (*interp).cvti64tof64,
+	opcvtf64toi64: func(p *interp) bool { return p.cvtf64toi64(math.RoundToEven) },
+	opfproundu:    func(p *interp) bool { return p.cvtf64toi64(math.Ceil) },
+	opfproundd:    func(p *interp) bool { return p.cvtf64toi64(math.Floor) },
+
+	opcmpeqf:    func(p *interp) bool { return p.cmpf(p.slotf(), func(a, b float64) bool { return a == b }) },
+	opcmpeqimmf: func(p *interp) bool { return p.cmpf(p.immf(), func(a, b float64) bool { return a == b }) },
+	opcmpeqi:    func(p *interp) bool { return p.cmpi(p.sloti(), func(a, b int64) bool { return a == b }) },
+	opcmpeqimmi: func(p *interp) bool { return p.cmpi(p.immi(), func(a, b int64) bool { return a == b }) },
+	opcmpltf:    func(p *interp) bool { return p.cmpf(p.slotf(), func(a, b float64) bool { return a < b }) },
+	opcmpltimmf: func(p *interp) bool { return p.cmpf(p.immf(), func(a, b float64) bool { return a < b }) },
+	opcmplti:    func(p *interp) bool { return p.cmpi(p.sloti(), func(a, b int64) bool { return a < b }) },
+	opcmpltimmi: func(p *interp) bool { return p.cmpi(p.immi(), func(a, b int64) bool { return a < b }) },
+	opcmplef:    func(p *interp) bool { return p.cmpf(p.slotf(), func(a, b float64) bool { return a <= b }) },
+	opcmpleimmf: func(p *interp) bool { return p.cmpf(p.immf(), func(a, b float64) bool { return a <= b }) },
+	opcmplei:    func(p *interp) bool { return p.cmpi(p.sloti(), func(a, b int64) bool { return a <= b }) },
+	opcmpleimmi: func(p *interp) bool { return p.cmpi(p.immi(), func(a, b int64) bool { return a <= b }) },
+	opcmpgtf:    func(p *interp) bool { return p.cmpf(p.slotf(), func(a, b float64) bool { return a > b }) },
+	opcmpgtimmf: func(p *interp) bool { return p.cmpf(p.immf(), func(a, b float64) bool { return a > b }) },
+	opcmpgti:    func(p *interp) bool { return p.cmpi(p.sloti(), func(a, b int64) bool { return a > b }) },
+	opcmpgtimmi: func(p *interp) bool { return p.cmpi(p.immi(), func(a, b int64) bool { return a > b }) },
+	opcmpgef:    func(p *interp) bool { return p.cmpf(p.slotf(), func(a, b float64) bool { return a >= b }) },
+	opcmpgeimmf: func(p *interp) bool { return p.cmpf(p.immf(), func(a, b float64) bool { return a >= b }) },
+	opcmpgei:    func(p *interp) bool { return p.cmpi(p.sloti(), func(a, b int64) bool { return a >= b }) },
+	opcmpgeimmi: func(p *interp) bool { return p.cmpi(p.immi(), func(a, b int64) bool { return a >= b }) },
+
+	opchecktag:     (*interp).checktag,
+	opisnull:       func(p *interp) bool { return p.testv(func(w uint32) bool { return w&0xf == 0xf }) },
+	opisnotnull:    func(p *interp) bool { return p.testv(func(w uint32) bool { return w&0xf != 0xf }) },
+	opistrue:       func(p *interp) bool { return p.testv(func(w uint32) bool { return w&0xff == 0x11 }) },
+	opisfalse:      func(p *interp) bool { return p.testv(func(w uint32) bool { return w&0xff == 0x10 }) },
+	opeqslice:      (*interp).eqslice,
+	opequalv:       (*interp).equalv,
+	opeqv4mask:     func(p *interp) bool { p.disp = 0; return p.eqv4mask() },
+	opeqv4maskplus: (*interp).eqv4mask,
+	opeqv8:         func(p *interp) bool { p.disp = 0; return p.eqv8() },
+	opeqv8plus:     (*interp).eqv8,
+	opleneq:        (*interp).leneq,
+
+	opfindsym:     (*interp).findsym,
+	opfindsym2:    (*interp).findsym2,
+	opfindsym2rev: (*interp).findsym2rev,
+	opfindsym3:    (*interp).findsym3,
+
+	opblendv:        func(p *interp) bool { return p.blend32(&p.v, p.k1) },
+	opblendrevv:     func(p *interp) bool { return p.blend32(&p.v, p.k1^p.k7) },
+	opblendnum:      func(p *interp) bool { return p.blend64(p.k1) },
+	opblendnumrev:   func(p *interp) bool { return p.blend64(p.k1 ^ p.k7) },
+	opblendslice:    func(p *interp) bool { return p.blendslice(p.k1) },
+	opblendslicerev: func(p *interp) bool { return p.blendslice(p.k1 ^ p.k7) },
+
+	opunpack: (*interp).unpack,
+	optoint:  (*interp).toint,
+	optof64:  (*interp).tof64,
+	optuple:  (*interp).tuple,
+
+	opboxint:   (*interp).boxint,
+	opboxfloat: (*interp).boxfloat,
+	opboxmask:  func(p *interp) bool { return p.boxmask(p.stk16(p.imm16())) },
+	opboxmask2: func(p *interp) bool { k2 := p.k1; p.k1 = p.stk16(p.imm16()); return p.boxmask(k2) },
+	opboxmask3: func(p *interp) bool { return p.boxmask(p.k1) },
+
+	opappendv:     func(p *interp) bool { return p.appendv(p.imm16(), nil) },
+	opappendfield: (*interp).appendfield,
+	opboxlist:     func(p *interp) bool { return p.boxcontainer(0xb0) },
+	opboxstruct:   func(p *interp) bool { return p.boxcontainer(0xd0) },
+
+	opaggsumf:  (*interp).aggsumf,
+	opaggsumi:  func(p *interp) bool { return p.aggi(0, func(a, b int64) int64 { return a + b }) },
+	opaggminf:  func(p *interp) bool { return p.aggf(math.Inf(1), func(a, b float64) bool { return a < b }) },
+	opaggmini:  func(p *interp) bool { return p.aggi(math.MaxInt64, mini) },
+	opaggmaxf:  func(p *interp) bool { return p.aggf(math.Inf(-1), func(a, b float64) bool { return a > b }) },
+	opaggmaxi:  func(p *interp) bool { return p.aggi(math.MinInt64, maxi) },
+	opaggcount: (*interp).aggcount,
+
+	ophashvalue:     func(p *interp) bool { return p.hashvalue(nil, p.imm16()) },
+	ophashvalueplus: func(p *interp) bool { src := p.imm16(); return p.hashvalue(p.hashslot(src), p.imm16()) },
+	ophashmember:    (*interp).hashmember,
+	ophashlookup:    (*interp).hashlookup,
+
+	opaggbucket:    (*interp).aggbucket,
+	opaggslotaddf:  func(p *interp) bool { return p.aggslot(false, addf64) },
+	opaggslotaddi:  func(p *interp) bool { return p.aggslot(false, addi64) },
+	opaggslotavgf:  func(p *interp) bool { return p.aggslot(true, addf64) },
+	opaggslotavgi:  func(p *interp) bool { return p.aggslot(true, addi64) },
+	opaggslotminf:  func(p *interp) bool { return p.aggslot(false, minf64) },
+	opaggslotmini:  func(p *interp) bool { return p.aggslot(false, mini64) },
+	opaggslotmaxf:  func(p *interp) bool { return p.aggslot(false, maxf64) },
+	opaggslotmaxi:  func(p *interp) bool { return p.aggslot(false, maxi64) },
+	opaggslotcount: (*interp).aggslotcount,
+
+	oplitref: (*interp).litref,
+	opsplit:  (*interp).split,
+	opdupv:   (*interp).dupv,
+	opzerov:  (*interp).zerov,
+
+	opCmpStrEqCs:       (*interp).cmpstreqcs,
+	opContainsPrefixCs: (*interp).containsprefixcs,
+	opContainsSuffixCs: (*interp).containssuffixcs,
+}
+
+// Control flow, load, and save instructions
+
+func (p *interp) jz() bool {
+	rel := p.imm64()
+	if p.k1 == 0 {
+		p.pc += int(rel)
+	}
+	return true
+}
+
+func (p *interp) loadk() bool {
+	p.k1 = p.stk16(p.imm16())
+	return true
+}
+
+func (p *interp) savek() bool {
+	p.setstk16(p.imm16(), p.k1)
+	return true
+}
+
+func (p *interp) xchgk() bool {
+	slot := p.imm16()
+	k := p.stk16(slot)
+	p.setstk16(slot, p.k1)
+	p.k1 = k
+	return true
+}
+
+func (p *interp) loadslot(dst *[2][16]uint32, slot uint16) {
+	for i := 0; i < 16; i++ {
+		dst[0][i] = p.stk32(slot, i)
+		dst[1][i] = p.stk32(slot, 16+i)
+	}
+}
+
+// saveslot stores the lanes of src in k
+// and zeroes the other lanes if zero is set
+func (p *interp) saveslot(src *[2][16]uint32, slot uint16, k uint16, zero bool) {
+	for i := 0; i < 16; i++ {
+		if lane(k, i) {
+			p.setstk32(slot, i, src[0][i])
+			p.setstk32(slot, 16+i, src[1][i])
+		} else if zero {
+			p.setstk32(slot, i, 0)
+			p.setstk32(slot, 16+i, 0)
+		}
+	}
+}
+
+// nonzero returns the mask of the non-zero lanes of x
+func nonzero(x *[16]uint32) uint16 {
+	k := uint16(0)
+	for i := range x {
+		if x[i] != 0 {
+			k |= 1 << i
+		}
+	}
+	return k
+}
+
+func (p *interp) loadb() bool {
+	p.loadslot(&p.b, p.imm16())
+	return true
+}
+
+func (p *interp) saveb() bool {
+	p.saveslot(&p.b, p.imm16(), 0xffff, false)
+	return true
+}
+
+func (p *interp) loadv() bool {
+	p.loadslot(&p.v, p.imm16())
+	return true
+}
+
+func (p *interp) savev() bool {
+	p.saveslot(&p.v, p.imm16(), 0xffff, false)
+	return true
+}
+
+func (p *interp) loadzerov() bool {
+	p.loadslot(&p.v, p.imm16())
+	p.k1 = nonzero(&p.v[1])
+	return true
+}
+
+func (p *interp) savezerov() bool {
+	p.saveslot(&p.v, p.imm16(), p.k1, true)
+	return true
+}
+
+func (p *interp) saveblendv() bool {
+	p.saveslot(&p.v, p.imm16(), p.k1, false)
+	return true
+}
+
+// loadpermzerov loads the lanes of the given
+// slot of the outer program that correspond to
+// each lane of this program (see evalunnest)
+func (p *interp) loadpermzerov() bool {
+	slot := int(p.imm16())
+	stack := vstackBytes(p.bc.outer.vstack)
+	p.k1 = 0
+	for i := 0; i < 16; i++ {
+		j := 4 * int(p.bc.perm[i]&15)
+		p.v[0][i] = binary.LittleEndian.Uint32(stack[slot+j:])
+		p.v[1][i] = binary.LittleEndian.Uint32(stack[slot+64+j:])
+		if p.v[1][i] != 0 {
+			p.k1 |= 1 << i
+		}
+	}
+	return true
+}
+
+func (p *interp) loads() bool {
+	slot := p.imm16()
+	for i := range p.s {
+		p.s[i] = p.stk64(slot, i)
+	}
+	return true
+}
+
+func (p *interp) saves() bool {
+	slot := p.imm16()
+	for i := range p.s {
+		p.setstk64(slot, i, p.s[i])
+	}
+	return true
+}
+
+func (p *interp) loadzeros() bool {
+	p.loads()
+	p.k1 = 0
+	for i := 0; i < 16; i++ {
+		if p.sd(16+i) != 0 {
+			p.k1 |= 1 << i
+		}
+	}
+	return true
+}
+
+func (p *interp) savezeros() bool {
+	slot := p.imm16()
+	for i := 0; i < 16; i++ {
+		off, length := p.sd(i), p.sd(16+i)
+		if !lane(p.k1, i) {
+			off = 0
+		}
+		// the assembly masks the lengths
+		// with K1>>8, so only the first 8
+		// lengths are preserved (using the
+		// upper 8 bits of the mask)
+		if i >= 8 || !lane(p.k1, i+8) {
+			length = 0
+		}
+		p.setstk32(slot, i, off)
+		p.setstk32(slot, 16+i, length)
+	}
+	return true
+}
+
+// Mask instructions
+
+func (p *interp) falsek() bool {
+	p.k1 = 0
+	p.v = [2][16]uint32{}
+	return true
+}
+
+func (p *interp) maskop(fn func(k1, k2 uint16) uint16) bool {
+	p.k1 = fn(p.k1, p.stk16(p.imm16()))
+	return true
+}
+
+func (p *interp) notk() bool {
+	p.k1 ^= p.k7
+	return true
+}
+
+// Arithmetic instructions
+
+func addf(a, b float64) float64 { return a + b }
+func subf(a, b float64) float64 { return a - b }
+func mulf(a, b float64) float64 { return a * b }
+func divf(a, b float64) float64 { return a / b }
+func addi(a, b int64) int64     { return a + b }
+func subi(a, b int64) int64     { return a - b }
+func muli(a, b int64) int64     { return a * b }
+
+func absi(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func mini(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxi(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// slotf, sloti, immf and immi return the
+// second argument of a binary operation
+func (p *interp) slotf() (out [16]float64) {
+	slot := p.imm16()
+	for i := range out {
+		out[i] = math.Float64frombits(p.stk64(slot, i))
+	}
+	return out
+}
+
+func (p *interp) sloti() (out [16]int64) {
+	slot := p.imm16()
+	for i := range out {
+		out[i] = int64(p.stk64(slot, i))
+	}
+	return out
+}
+
+func (p *interp) immf() (out [16]float64) {
+	x := math.Float64frombits(p.imm64())
+	for i := range out {
+		out[i] = x
+	}
+	return out
+}
+
+func (p *interp) immi() (out [16]int64) {
+	x := int64(p.imm64())
+	for i := range out {
+		out[i] = x
+	}
+	return out
+}
+
+func (p *interp) broadcastimm() bool {
+	x := p.imm64()
+	for i := range p.s {
+		p.s[i] = x
+	}
+	return true
+}
+
+func (p *interp) f(i int) float64 { return math.Float64frombits(p.s[i]) }
+
+func (p *interp) setf(i int, x float64) { p.s[i] = math.Float64bits(x) }
+
+func (p *interp) unaryf(fn func(float64) float64) bool {
+	for i := range p.s {
+		if lane(p.k1, i) {
+			p.setf(i, fn(p.f(i)))
+		}
+	}
+	return true
+}
+
+func (p *interp) unaryi(fn func(int64) int64) bool {
+	for i := range p.s {
+		if lane(p.k1, i) {
+			p.s[i] = uint64(fn(int64(p.s[i])))
+		}
+	}
+	return true
+}
+
+func (p *interp) arithf(arg [16]float64, fn func(a, b float64) float64) bool {
+	for i := range p.s {
+		if lane(p.k1, i) {
+			p.setf(i, fn(p.f(i), arg[i]))
+		}
+	}
+	return true
+}
+
+func (p *interp) arithi(arg [16]int64, fn func(a, b int64) int64) bool {
+	for i := range p.s {
+		if lane(p.k1, i) {
+			p.s[i] = uint64(fn(int64(p.s[i]), arg[i]))
+		}
+	}
+	return true
+}
+
+// Conversion instructions
+
+func (p *interp) cvtk(one uint64) bool {
+	for i := range p.s {
+		p.s[i] = 0
+		if lane(p.k1, i) {
+			p.s[i] = one
+		}
+	}
+	return true
+}
+
+func (p *interp) cvti64tof64() bool {
+	for i := range p.s {
+		if lane(p.k1, i) {
+			p.setf(i, float64(int64(p.s[i])))
+		}
+	}
+	return true
+}
+
+// cvtf64 converts an integral float64 to int64,
+// producing 0x8000000000000000 for NaN and
+// out-of-range values like VCVTPD2QQ
+func cvtf64(x float64) uint64 {
+	if !(x >= -(1<<63) && x < 1<<63) {
+		return 1 << 63
+	}
+	return uint64(int64(x))
+}
+
+func (p *interp) cvtf64toi64(round func(float64) float64) bool {
+	for i := range p.s {
+		if lane(p.k1, i) {
+			p.s[i] = cvtf64(round(p.f(i)))
+		}
+	}
+	return true
+}
+
+// Comparison instructions
+
+func (p *interp) cmpf(arg [16]float64, fn func(a, b float64) bool) bool {
+	for i := range p.s {
+		if lane(p.k1, i) && !fn(p.f(i), arg[i]) {
+			p.k1 &^= 1 << i
+		}
+	}
+	return true
+}
+
+func (p *interp) cmpi(arg [16]int64, fn func(a, b int64) bool) bool {
+	for i := range p.s {
+		if lane(p.k1, i) && !fn(int64(p.s[i]), arg[i]) {
+			p.k1 &^= 1 << i
+		}
+	}
+	return true
+}
+
+// testv keeps the lanes for which fn returns
+// true given the first 4 bytes of the value
+func (p *interp) testv(fn func(w uint32) bool) bool {
+	for i := 0; i < 16; i++ {
+		if lane(p.k1, i) && !fn(p.load32(p.v[0][i])) {
+			p.k1 &^= 1 << i
+		}
+	}
+	return true
+}
+
+func (p *interp) checktag() bool {
+	tags := uint32(p.imm16())
+	return p.testv(func(w uint32) bool {
+		return (1<<((w>>4)&0xf))&tags != 0
+	})
+}
+
+// eqmem keeps the lanes of K1 where the
+// memory referenced by the two slices is equal
+func (p *interp) eqmem(off0, len0, off1, len1 *[16]uint32) {
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		if len0[i] != len1[i] || !p.memeq(off0[i], string(p.slice(off1[i], len1[i]))) {
+			p.k1 &^= 1 << i
+		}
+	}
+}
+
+// slice returns the memory at off, padded
+// with zeros if it extends past p.mem
+func (p *interp) slice(off, n uint32) []byte {
+	if uint64(off)+uint64(n) <= uint64(len(p.mem)) {
+		return p.mem[off : off+n]
+	}
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = byte(p.load32(off + uint32(i)))
+	}
+	return buf
+}
+
+func (p *interp) eqslice() bool {
+	var arg [2][16]uint32
+	p.loadslot(&arg, p.imm16())
+	var off, length [16]uint32
+	for i := 0; i < 16; i++ {
+		off[i], length[i] = p.sd(i), p.sd(16+i)
+	}
+	p.eqmem(&arg[0], &arg[1], &off, &length)
+	return true
+}
+
+func (p *interp) equalv() bool {
+	var arg [2][16]uint32
+	p.loadslot(&arg, p.imm16())
+	p.eqmem(&arg[0], &arg[1], &p.v[0], &p.v[1])
+	return true
+}
+
+func (p *interp) eqv4mask() bool {
+	imm := p.imm32()
+	mask := p.imm32()
+	for i := 0; i < 16; i++ {
+		if lane(p.k1, i) && p.load32(p.v[0][i]+p.disp)&mask != imm {
+			p.k1 &^= 1 << i
+		}
+	}
+	p.disp += 4
+	return true
+}
+
+func (p *interp) eqv8() bool {
+	imm := p.imm64()
+	for i := 0; i < 16; i++ {
+		if lane(p.k1, i) && p.load64(p.v[0][i]+p.disp) != imm {
+			p.k1 &^= 1 << i
+		}
+	}
+	p.disp += 8
+	return true
+}
+
+func (p *interp) leneq() bool {
+	n := p.imm32()
+	for i := 0; i < 16; i++ {
+		if p.v[1][i] != n {
+			p.k1 &^= 1 << i
+		}
+	}
+	return true
+}
+
+// Symbol lookup instructions
+
+func (p *interp) findsym() bool {
+	sym := p.imm32()
+	p.v[0] = p.b[0]
+	return p.findsymtail(sym)
+}
+
+func (p *interp) findsym2() bool {
+	k2 := p.stk16(p.imm16())
+	sym := p.imm32()
+	p.advance(k2)
+	return p.findsymtail(sym)
+}
+
+func (p *interp) findsym2rev() bool {
+	slot := p.imm16()
+	sym := p.imm32()
+	p.advance(p.k1)
+	p.k1 = p.stk16(slot)
+	return p.findsymtail(sym)
+}
+
+func (p *interp) findsym3() bool {
+	sym := p.imm32()
+	p.advance(p.k1)
+	return p.findsymtail(sym)
+}
+
+// advance moves the value base
+// past the current value in lanes k
+func (p *interp) advance(k uint16) {
+	for i := 0; i < 16; i++ {
+		if lane(k, i) {
+			p.v[0][i] += p.v[1][i]
+		}
+	}
+}
+
+// findsymtail searches each active structure
+// for the field with the given symbol, starting
+// at Z30 and ending at Z0+Z1; K1 is set for the lanes
+// where the field was found, in which case Z30:Z31
+// is the field value
+//
+// In the lanes where the symbol was not found,
+// Z31 is the length of the last field examined.
+func (p *interp) findsymtail(sym uint32) bool {
+	active := p.k1
+	p.k1 = 0
+	p.v[1] = [16]uint32{}
+	for i := 0; i < 16; i++ {
+		if !lane(active, i) {
+			continue
+		}
+		end := p.b[0][i] + p.b[1][i]
+		off, length := p.v[0][i], uint32(0)
+		for off < end {
+			w := p.load32(off)
+			id, size := w&0x7f, uint32(1)
+			for w&0x80 == 0 {
+				if size == 3 {
+					return p.fail(bcerrCorrupt)
+				}
+				w >>= 8
+				id = id<<7 | w&0x7f
+				size++
+			}
+			if int32(sym) < int32(id) {
+				break
+			}
+			desc := p.load32(off+size) & 0xff
+			off += size
+			switch nib := desc & 0xf; {
+			case nib == 0xe:
+				w = p.load32(off + 1)
+				n, hdr := w&0x7f, uint32(2)
+				for w&0x80 == 0 {
+					if hdr == 5 {
+						return p.fail(bcerrCorrupt)
+					}
+					w >>= 8
+					n = n<<7 | w&0x7f
+					hdr++
+				}
+				length = hdr + n
+			case nib == 0xf, desc == 0x11:
+				length = 1
+			default:
+				length = 1 + nib
+			}
+			if id == sym {
+				p.k1 |= 1 << i
+				break
+			}
+			off += length
+		}
+		p.v[0][i], p.v[1][i] = off, length
+	}
+	return true
+}
+
+// Blend instructions
+
+func (p *interp) blend32(dst *[2][16]uint32, k uint16) bool {
+	slot := p.imm16()
+	for i := 0; i < 16; i++ {
+		if lane(k, i) {
+			dst[0][i] = p.stk32(slot, i)
+			dst[1][i] = p.stk32(slot, 16+i)
+		}
+	}
+	return true
+}
+
+func (p *interp) blend64(k uint16) bool {
+	slot := p.imm16()
+	for i := range p.s {
+		if lane(k, i) {
+			p.s[i] = p.stk64(slot, i)
+		}
+	}
+	return true
+}
+
+func (p *interp) blendslice(k uint16) bool {
+	slot := p.imm16()
+	for i := 0; i < 16; i++ {
+		if lane(k, i) {
+			p.setsd(i, p.stk32(slot, i))
+			p.setsd(16+i, p.stk32(slot, 16+i))
+		}
+	}
+	return true
+}
+
+// Unboxing instructions
+
+// header decodes the descriptor of the value
+// at off, returning the tag, the number of
+// bytes in the header, and the length of the
+// value following the header; ok is false
+// if the varint length has more than maxlen bytes
+func (p *interp) header(off uint32, maxlen int) (tag, hdr, length uint32, ok bool) {
+	w := p.load32(off)
+	tag = (w >> 4) & 0xf
+	length = w & 0xf
+	hdr = 1
+	if length != 0xe {
+		return tag, hdr, length, true
+	}
+	w >>= 8
+	length = 0
+	for i := 0; i < maxlen; i++ {
+		length = length<<7 | w&0x7f
+		hdr++
+		if w&0x80 != 0 {
+			return tag, hdr, length, true
+		}
+		w >>= 8
+	}
+	return tag, hdr, length, false
+}
+
+func (p *interp) unpack() bool {
+	want := uint32(p.imm8())
+	if p.k1 == 0 {
+		return true
+	}
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		w := p.load32(p.v[0][i])
+		if w&0xf == 0xf || (w>>4)&0xf != want {
+			p.k1 &^= 1 << i
+		}
+	}
+	if p.k1 == 0 {
+		return true
+	}
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			p.setsd(i, 0)
+			continue
+		}
+		_, hdr, length, ok := p.header(p.v[0][i], 3)
+		if !ok {
+			return p.fail(bcerrCorrupt)
+		}
+		p.setsd(i, p.v[0][i]+hdr)
+		p.setsd(16+i, length)
+	}
+	return true
+}
+
+func (p *interp) toint() bool {
+	if p.k1 == 0 {
+		return true
+	}
+	var neg uint16
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		w := p.load32(p.v[0][i])
+		switch tag := (w >> 4) & 0xf; {
+		case w&0xf == 0xf || (tag != 2 && tag != 3):
+			p.k1 &^= 1 << i
+		case w&0xf > 8:
+			return p.fail(bcerrCorrupt)
+		case tag == 3:
+			neg |= 1 << i
+		}
+	}
+	for i := range p.s {
+		if !lane(p.k1, i) {
+			continue
+		}
+		shift := (8 - p.load32(p.v[0][i])&0xf) * 8
+		x := p.load64(p.v[0][i] + 1)
+		x = bits.ReverseBytes64((x & (math.MaxUint64 >> shift)) << shift)
+		if lane(neg, i) {
+			x = -x
+		}
+		p.s[i] = x
+	}
+	return true
+}
+
+func (p *interp) tof64() bool {
+	if p.k1 == 0 {
+		return true
+	}
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		w := p.load32(p.v[0][i])
+		if w&0xf == 0xf || (w>>4)&0xf != 4 {
+			p.k1 &^= 1 << i
+			continue
+		}
+		// other sizes (i.e. 0e0) leave the lane
+		// set without writing the scalar
+		switch w & 0xf {
+		case 8:
+			p.s[i] = bits.ReverseBytes64(p.load64(p.v[0][i] + 1))
+		case 4:
+			f := math.Float32frombits(bits.ReverseBytes32(p.load32(p.v[0][i] + 1)))
+			p.setf(i, float64(f))
+		}
+	}
+	return true
+}
+
+func (p *interp) tuple() bool {
+	if p.k1 == 0 {
+		return true
+	}
+	var base, length [16]uint32
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		w := p.load32(p.v[0][i])
+		if w&0xf == 0xf || (w>>4)&0xf != 0xd {
+			p.k1 &^= 1 << i
+			continue
+		}
+		_, hdr, n, ok := p.header(p.v[0][i], 3)
+		if !ok {
+			return p.fail(bcerrCorrupt)
+		}
+		base[i], length[i] = p.v[0][i]+hdr, n
+	}
+	for i := 0; i < 16; i++ {
+		if lane(p.k1, i) {
+			p.b[0][i], p.b[1][i] = base[i], length[i]
+		}
+	}
+	return true
+}
+
+// Boxing instructions
+
+// reserve returns the offset of the scratch buffer
+// space that is not in use, or false if there are
+// fewer than n bytes available
+func (p *interp) reserve(n int) (uint32, bool) {
+	scratch := p.bc.scratch
+	if cap(scratch)-len(scratch) < n {
+		return 0, false
+	}
+	return p.bc.scratchoff + uint32(len(scratch)), true
+}
+
+// grow extends the scratch buffer by n bytes
+// and returns the new space
+func (p *interp) grow(n int) []byte {
+	pos := len(p.bc.scratch)
+	p.bc.scratch = p.bc.scratch[:pos+n]
+	return p.bc.scratch[pos:]
+}
+
+func (p *interp) boxint() bool {
+	p.v = [2][16]uint32{}
+	return p.boxints(p.k1, &p.s)
+}
+
+// boxints writes the lanes k of ints as ion
+// integers and points Z30:Z31 at them, leaving
+// the other lanes of Z30:Z31 untouched
+func (p *interp) boxints(k uint16, ints *[16]uint64) bool {
+	base, ok := p.reserve(9 * 16)
+	if !ok {
+		return p.fail(bcerrMoreScratch)
+	}
+	var mag [16]uint64
+	var size [16]int
+	stride := 8
+	for i := range ints {
+		mag[i] = ints[i]
+		if int64(mag[i]) < 0 {
+			mag[i] = -mag[i]
+		}
+		size[i] = (bits.Len64(mag[i]) + 7) / 8
+		if lane(k, i) && size[i] == 8 {
+			stride = 9
+		}
+	}
+	mem := p.grow(stride * 16)
+	for i := range ints {
+		if !lane(k, i) {
+			continue
+		}
+		desc := byte(0x20)
+		if int64(ints[i]) < 0 {
+			desc = 0x30
+		}
+		out := mem[i*stride:]
+		out[0] = desc | byte(size[i])
+		for j := 0; j < size[i]; j++ {
+			out[1+j] = byte(mag[i] >> (8 * (size[i] - 1 - j)))
+		}
+		p.v[0][i] = base + uint32(i*stride)
+		p.v[1][i] = uint32(1 + size[i])
+	}
+	return true
+}
+
+// cvttf64 converts x to int64 with truncation,
+// producing 0x8000000000000000 for NaN and
+// out-of-range values like VCVTTPD2QQ
+func cvttf64(x float64) uint64 {
+	return cvtf64(math.Trunc(x))
+}
+
+func (p *interp) boxfloat() bool {
+	base, ok := p.reserve(9 * 16)
+	if !ok {
+		return p.fail(bcerrMoreScratch)
+	}
+	p.v = [2][16]uint32{}
+	var ints [16]uint64
+	var isint, isfloat uint16
+	for i := range p.s {
+		if !lane(p.k1, i) {
+			continue
+		}
+		ints[i] = cvttf64(p.f(i))
+		if float64(int64(ints[i])) == p.f(i) {
+			isint |= 1 << i
+		} else {
+			isfloat |= 1 << i
+		}
+	}
+	if isfloat != 0 {
+		mem := p.grow(9 * 16)
+		for i := range p.s {
+			if !lane(isfloat, i) {
+				continue
+			}
+			mem[i*9] = 0x48
+			binary.BigEndian.PutUint64(mem[i*9+1:], p.s[i])
+			p.v[0][i] = base + uint32(i*9)
+			p.v[1][i] = 9
+		}
+	}
+	if isint != 0 {
+		return p.boxints(isint, &ints)
+	}
+	return true
+}
+
+// boxmask writes k2 as 16 ion booleans
+// and points Z30:Z31 at them in the lanes of K1
+func (p *interp) boxmask(k2 uint16) bool {
+	base, ok := p.reserve(16)
+	if !ok {
+		return p.fail(bcerrMoreScratch)
+	}
+	mem := p.grow(16)
+	for i := 0; i < 16; i++ {
+		mem[i] = 0x10
+		if lane(k2, i) {
+			mem[i] = 0x11
+		}
+		p.v[0][i], p.v[1][i] = 0, 0
+		if lane(p.k1, i) {
+			p.v[0][i], p.v[1][i] = base+uint32(i), 1
+		}
+	}
+	return true
+}
+
+// Constructor instructions
+
+func (p *interp) appendfield() bool {
+	slot := p.imm16()
+	sym := p.imm32()
+	var enc []byte
+	for {
+		enc = append(enc, byte(sym))
+		if byte(sym)&0x80 != 0 || len(enc) == 4 {
+			break
+		}
+		sym >>= 8
+	}
+	return p.appendv(slot, enc)
+}
+
+// appendv writes the values in Z30:Z31 followed
+// by between and the values in slot for the lanes
+// in K1 and points Z30:Z31 at the results
+func (p *interp) appendv(slot uint16, between []byte) bool {
+	var arg [2][16]uint32
+	p.loadslot(&arg, slot)
+	total := 0
+	for i := 0; i < 16; i++ {
+		if lane(p.k1, i) {
+			total += int(p.v[1][i]) + len(between) + int(arg[1][i])
+		}
+	}
+	if p.k1 == 0 {
+		return true
+	}
+	base, ok := p.reserve(total)
+	if !ok {
+		return p.fail(bcerrMoreScratch)
+	}
+	mem := p.grow(total)[:0]
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		start := len(mem)
+		mem = append(mem, p.slice(p.v[0][i], p.v[1][i])...)
+		mem = append(mem, between...)
+		mem = append(mem, p.slice(arg[0][i], arg[1][i])...)
+		p.v[0][i] = base + uint32(start)
+		p.v[1][i] = uint32(len(mem) - start)
+	}
+	return true
+}
+
+// boxcontainer writes the contents in Z30:Z31
+// with a header with the given type byte for
+// the lanes in K1 and points Z30:Z31 at them
+func (p *interp) boxcontainer(tag byte) bool {
+	var hdr [16][6]byte
+	var size [16]int
+	total := 0
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		n := p.v[1][i]
+		if n < 14 {
+			hdr[i][0] = tag | byte(n)
+			size[i] = 1
+		} else {
+			hdr[i][0] = tag | 0xe
+			size[i] = 1 + putuvarint(hdr[i][1:], uint(n))
+		}
+		total += size[i] + int(n)
+	}
+	var out [2][16]uint32
+	if p.k1 != 0 {
+		base, ok := p.reserve(total)
+		if !ok {
+			return p.fail(bcerrMoreScratch)
+		}
+		mem := p.grow(total)[:0]
+		for i := 0; i < 16; i++ {
+			if !lane(p.k1, i) {
+				continue
+			}
+			start := len(mem)
+			mem = append(mem, hdr[i][:size[i]]...)
+			mem = append(mem, p.slice(p.v[0][i], p.v[1][i])...)
+			out[0][i] = base + uint32(start)
+			out[1][i] = uint32(len(mem) - start)
+		}
+	}
+	p.v = out
+	return true
+}
+
+// Aggregation instructions
+
+func (p *interp) aggcount() bool {
+	slot := int(p.imm16())
+	buf := p.agg[slot:]
+	n := binary.LittleEndian.Uint64(buf)
+	binary.LittleEndian.PutUint64(buf, n+uint64(bits.OnesCount16(p.k1)))
+	return true
+}
+
+// addcount adds the number of active lanes
+// to the count following the aggregate value
+func (p *interp) addcount(buf []byte) {
+	n := binary.LittleEndian.Uint64(buf[8:])
+	binary.LittleEndian.PutUint64(buf[8:], n+uint64(bits.OnesCount16(p.k1)))
+}
+
+// aggsumf adds up the lanes in the
+// same order as the assembly version
+// so that the result is bit-identical
+func (p *interp) aggsumf() bool {
+	buf := p.agg[p.imm16():]
+	var x [8]float64
+	for i := range x {
+		var lo, hi float64
+		if lane(p.k1, i) {
+			lo = p.f(i)
+		}
+		if lane(p.k1, i+8) {
+			hi = p.f(i + 8)
+		}
+		x[i] = lo + hi
+	}
+	for i := 0; i < 4; i++ {
+		x[i] += x[i+4]
+	}
+	x[0], x[1] = x[0]+x[2], x[1]+x[3]
+	sum := x[0] + x[1]
+	sum += math.Float64frombits(binary.LittleEndian.Uint64(buf))
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(sum))
+	p.addcount(buf)
+	return true
+}
+
+// aggf implements aggmin.f and aggmax.f;
+// less(a, b) determines whether VMINPD/VMAXPD
+// would choose a over b (the second operand
+// is chosen for NaNs and signed zeros)
+func (p *interp) aggf(init float64, less func(a, b float64) bool) bool {
+	buf := p.agg[p.imm16():]
+	pick := func(a, b float64) float64 {
+		if less(a, b) {
+			return a
+		}
+		return b
+	}
+	var x [8]float64
+	for i := range x {
+		x[i] = init
+		if lane(p.k1, i) {
+			x[i] = pick(p.f(i), x[i])
+		}
+		if lane(p.k1, i+8) {
+			x[i] = pick(p.f(i+8), x[i])
+		}
+	}
+	for i := 0; i < 4; i++ {
+		x[i] = pick(x[i], x[i+4])
+	}
+	x[0], x[1] = pick(x[0], x[2]), pick(x[1], x[3])
+	r := pick(x[0], x[1])
+	r = pick(r, math.Float64frombits(binary.LittleEndian.Uint64(buf)))
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(r))
+	p.addcount(buf)
+	return true
+}
+
+func (p *interp) aggi(init int64, fn func(a, b int64) int64) bool {
+	buf := p.agg[p.imm16():]
+	r := int64(binary.LittleEndian.Uint64(buf))
+	if init != 0 {
+		// min and max include the initial
+		// value in every lane
+		r = fn(r, init)
+	}
+	for i := range p.s {
+		if lane(p.k1, i) {
+			r = fn(r, int64(p.s[i]))
+		}
+	}
+	binary.LittleEndian.PutUint64(buf, uint64(r))
+	p.addcount(buf)
+	return true
+}
+
+// Hash instructions
+
+// hashslot returns the hash slot at the
+// given offset; each lane holds two words
+func (p *interp) hashslot(slot uint16) []uint64 {
+	return p.bc.hashmem[slot/8 : slot/8+32]
+}
+
+// hashvalue hashes the values in Z30:Z31 into
+// the hash slot dst using the hashes in seed
+// (or zeros) as the seed; inactive lanes
+// hash an empty value
+func (p *interp) hashvalue(seed []uint64, dst uint16) bool {
+	var out [32]uint64
+	for i := 0; i < 16; i++ {
+		var iv, h [16]byte
+		if seed != nil {
+			binary.LittleEndian.PutUint64(iv[:], seed[2*i])
+			binary.LittleEndian.PutUint64(iv[8:], seed[2*i+1])
+		}
+		var buf []byte
+		if lane(p.k1, i) {
+			buf = p.slice(p.v[0][i], p.v[1][i])
+		}
+		chacha8HashSeed(buf, h[:], iv[:])
+		out[2*i] = binary.LittleEndian.Uint64(h[:])
+		out[2*i+1] = binary.LittleEndian.Uint64(h[8:])
+	}
+	copy(p.hashslot(dst), out[:])
+	return true
+}
+
+func (p *interp) hashmember() bool {
+	h := p.hashslot(p.imm16())
+	tree := p.bc.trees[p.imm16()]
+	for i := 0; i < 16; i++ {
+		if lane(p.k1, i) && tree.Offset(h[2*i]) < 0 {
+			p.k1 &^= 1 << i
+		}
+	}
+	return true
+}
+
+// hashlookup loads the value associated with each
+// hash into Z30:Z31; the values are offsets into
+// the scratch buffer followed by lengths
+func (p *interp) hashlookup() bool {
+	h := p.hashslot(p.imm16())
+	tree := p.bc.trees[p.imm16()]
+	p.v = [2][16]uint32{}
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		off := tree.Offset(h[2*i])
+		if off < 0 {
+			p.k1 &^= 1 << i
+			continue
+		}
+		val := tree.values[int(off)+aggregateTagSize:]
+		p.v[0][i] = binary.LittleEndian.Uint32(val) + p.bc.scratchoff
+		p.v[1][i] = binary.LittleEndian.Uint32(val[4:])
+	}
+	return true
+}
+
+// Slot aggregation instructions
+
+// aggbucket locates the entry of each hash in
+// the hash aggregate tree and stores its offset
+// in the bucket register; if any entry is missing,
+// the program aborts with bcerrNeedRadix so that
+// the caller can insert the missing lanes
+func (p *interp) aggbucket() bool {
+	slot := p.imm16()
+	if p.k1 == 0 {
+		return true
+	}
+	h := p.hashslot(slot)
+	var bucket [16]int32
+	missing := uint16(0)
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		if off := p.tree.Offset(h[2*i]); off >= 0 {
+			bucket[i] = off
+		} else {
+			missing |= 1 << i
+		}
+	}
+	if missing != 0 {
+		p.bc.err = bcerrNeedRadix
+		p.bc.errinfo = int(slot)
+		p.abort = missing
+		return false
+	}
+	for i := 0; i < 16; i++ {
+		if lane(p.k1, i) && int(bucket[i]) > len(p.tree.values) {
+			p.bc.err = bcerrTreeCorrupt
+			p.bc.errpc = int32(p.pc - 2)
+			return false
+		}
+	}
+	p.bc.bucket = bucket
+	return true
+}
+
+// the operators of the slot aggregation
+// instructions work on the raw bits of
+// the aggregated values; the first argument
+// is the first source operand of the instruction
+func addf64(a, b uint64) uint64 {
+	return math.Float64bits(math.Float64frombits(a) + math.Float64frombits(b))
+}
+
+func minf64(a, b uint64) uint64 {
+	if math.Float64frombits(a) < math.Float64frombits(b) {
+		return a
+	}
+	return b
+}
+
+func maxf64(a, b uint64) uint64 {
+	if math.Float64frombits(a) > math.Float64frombits(b) {
+		return a
+	}
+	return b
+}
+
+func addi64(a, b uint64) uint64 { return a + b }
+func mini64(a, b uint64) uint64 { return uint64(mini(int64(a), int64(b))) }
+func maxi64(a, b uint64) uint64 { return uint64(maxi(int64(a), int64(b))) }
+
+// aggslot aggregates the values in Z2:Z3 into
+// the buckets of the hash aggregate tree
+//
+// Like the assembly, each group of eight lanes
+// first combines the values of lanes that share
+// a bucket in lane order and then updates the
+// bucket once, so the results are bit-identical.
+// The value is followed by a count when avg is
+// set, and otherwise by a mark that is set once
+// a bucket has been updated.
+func (p *interp) aggslot(avg bool, fn func(a, b uint64) uint64) bool {
+	off := int(p.imm16()) + aggregateTagSize
+	values := p.tree.values
+	bucket := &p.bc.bucket
+	if !avg {
+		for i := 0; i < 16; i++ {
+			if lane(p.k1, i) {
+				binary.LittleEndian.PutUint32(values[int(bucket[i])+off+8:], 1)
+			}
+		}
+	}
+	for half := 0; half < 16; half += 8 {
+		var acc, count, val, n [16]uint64
+		for i := half; i < half+8; i++ {
+			if !lane(p.k1, i) {
+				continue
+			}
+			acc[i], count[i] = p.s[i], 1
+			for j := i - 1; j >= half; j-- {
+				if lane(p.k1, j) && bucket[j] == bucket[i] {
+					acc[i], count[i] = fn(p.s[i], acc[j]), count[j]+1
+					break
+				}
+			}
+			buf := values[int(bucket[i])+off:]
+			val[i] = fn(binary.LittleEndian.Uint64(buf), acc[i])
+			n[i] = binary.LittleEndian.Uint64(buf[8:]) + count[i]
+		}
+		// the last lane that refers to
+		// a bucket determines its value
+		for i := half; i < half+8; i++ {
+			if !lane(p.k1, i) {
+				continue
+			}
+			buf := values[int(bucket[i])+off:]
+			binary.LittleEndian.PutUint64(buf, val[i])
+			if avg {
+				binary.LittleEndian.PutUint64(buf[8:], n[i])
+			}
+		}
+	}
+	return true
+}
+
+func (p *interp) aggslotcount() bool {
+	off := int(p.imm16()) + aggregateTagSize
+	for i := 0; i < 16; i++ {
+		if lane(p.k1, i) {
+			buf := p.tree.values[int(p.bc.bucket[i])+off:]
+			binary.LittleEndian.PutUint64(buf, binary.LittleEndian.Uint64(buf)+1)
+		}
+	}
+	return true
+}
+
+// Value stack instructions
+
+func (p *interp) litref() bool {
+	off := p.imm32() + p.bc.scratchoff
+	n := p.imm32()
+	for i := 0; i < 16; i++ {
+		p.v[0][i], p.v[1][i] = off, n
+	}
+	return true
+}
+
+func (p *interp) dupv() bool {
+	src := p.imm16()
+	dst := p.imm16()
+	var tmp [2][16]uint32
+	p.loadslot(&tmp, src)
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			tmp[0][i], tmp[1][i] = 0, 0
+		}
+	}
+	p.saveslot(&tmp, dst, 0xffff, false)
+	return true
+}
+
+func (p *interp) zerov() bool {
+	var zero [2][16]uint32
+	p.saveslot(&zero, p.imm16(), 0xffff, false)
+	return true
+}
+
+// List instructions
+
+// split moves the first element of each
+// list slice in Z2:Z3 into Z30:Z31 and
+// leaves the remaining elements in Z2:Z3
+func (p *interp) split() bool {
+	for i := 0; i < 16; i++ {
+		if p.sd(16+i) == 0 {
+			p.k1 &^= 1 << i
+		}
+	}
+	if p.k1 == 0 {
+		return true
+	}
+	var size [16]uint32
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		w := p.load32(p.sd(i))
+		l, tag := w&0xf, (w>>4)&0xf
+		// booleans are one byte regardless
+		// of their size bits (except 0xe)
+		n := uint32(1)
+		if tag != 1 && l != 0xe && l != 0xf {
+			n += l
+		}
+		if l == 0xe {
+			// the assembly decodes at
+			// most three varint bytes
+			length := uint32(0)
+			for j := 1; ; j++ {
+				if j > 3 {
+					return p.fail(bcerrCorrupt)
+				}
+				b := (w >> (8 * j)) & 0xff
+				length = length<<7 | b&0x7f
+				n++
+				if b&0x80 != 0 {
+					break
+				}
+			}
+			n += length
+		}
+		if n > p.sd(16+i) {
+			return p.fail(bcerrCorrupt)
+		}
+		size[i] = n
+	}
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			p.v[0][i], p.v[1][i] = 0, 0
+			p.setsd(16+i, 0)
+			continue
+		}
+		p.v[0][i], p.v[1][i] = p.sd(i), size[i]
+		p.setsd(i, p.sd(i)+size[i])
+		p.setsd(16+i, p.sd(16+i)-size[i])
+	}
+	return true
+}
+
+// String instructions (on slices in Z2:Z3)
+
+func (p *interp) cmpstreqcs() bool {
+	needle := p.bc.dict[p.imm16()]
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		if int32(p.sd(16+i)) != int32(len(needle)) || !p.memeq(p.sd(i), needle) {
+			p.k1 &^= 1 << i
+		}
+	}
+	return true
+}
+
+func (p *interp) containsprefixcs() bool {
+	needle := p.bc.dict[p.imm16()]
+	n := uint32(len(needle))
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		if int32(p.sd(16+i)) < int32(n) || !p.memeq(p.sd(i), needle) {
+			p.k1 &^= 1 << i
+		}
+	}
+	// the assembly only trims the prefix when
+	// the needle length is not a multiple of 4
+	if n%4 != 0 {
+		for i := 0; i < 16; i++ {
+			if lane(p.k1, i) {
+				p.setsd(i, p.sd(i)+n)
+				p.setsd(16+i, p.sd(16+i)-n)
+			}
+		}
+	}
+	return true
+}
+
+func (p *interp) containssuffixcs() bool {
+	needle := p.bc.dict[p.imm16()]
+	n := uint32(len(needle))
+	for i := 0; i < 16; i++ {
+		if !lane(p.k1, i) {
+			continue
+		}
+		length := p.sd(16 + i)
+		if int32(length) < int32(n) || !p.memeq(p.sd(i)+length-n, needle) {
+			p.k1 &^= 1 << i
+		}
+	}
+	for i := 0; i < 16; i++ {
+		if lane(p.k1, i) {
+			p.setsd(16+i, p.sd(16+i)-n)
+		}
+	}
+	return true
+}
@@ -0,0 +1,950 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// SetPortable forces the use of the portable
+// interpreter (or the assembly implementation)
+// and returns a function that restores the
+// previous setting
+func SetPortable(enable bool) func() {
+	old := portable
+	portable = enable
+	return func() { portable = old }
+}
+
+// immediate kinds for randomly-generated programs
+type interpImm int
+
+const (
+	immKSlot   interpImm = iota // mask slot
+	immVSlot                    // slot holding value/slice references
+	immSSlot                    // slot holding 64-bit numbers
+	immF64                      // float64
+	immI64                      // int64
+	immMask                     // uint32 comparison value and mask
+	immU64                      // uint64
+	immLen                      // small uint32 length
+	immTag                      // ion type (imm8)
+	immTags                     // ion type bitmask (imm16)
+	immSym                      // symbol ID (imm32)
+	immEncSym                   // encoded symbol ID (imm32)
+	immDict                     // dictionary index (imm16)
+	immLit                      // scratch literal (imm32, imm32)
+	immHSlot                    // hash slot
+	immTree                     // hash tree index (imm16)
+	immAggSlot                  // offset into an aggregate bucket (imm16)
+)
+
+const (
+	interpDataSize    = 64 << 10
+	interpScratchOff  = 32 << 10
+	interpScratchSize = 4 << 10
+	interpKSlots      = 5 * vRegSize
+	interpHSlots      = 2
+	interpTreeData    = 64
+)
+
+type interpCase struct {
+	name  string
+	ops   []bcop
+	imms  [][]interpImm
+	slice bool // Z2:Z3 holds slices rather than numbers
+}
+
+func unary(name string, op bcop) interpCase {
+	return interpCase{name: name, ops: []bcop{op}, imms: [][]interpImm{nil}}
+}
+
+func withimm(name string, op bcop, imms ...interpImm) interpCase {
+	return interpCase{name: name, ops: []bcop{op}, imms: [][]interpImm{imms}}
+}
+
+func slicecase(name string, op bcop, imms ...interpImm) interpCase {
+	c := withimm(name, op, imms...)
+	c.slice = true
+	return c
+}
+
+var interpCases = []interpCase{
+	withimm("loadk", oploadk, immKSlot),
+	withimm("savek", opsavek, immKSlot),
+	withimm("xchgk", opxchgk, immKSlot),
+	withimm("loadb", oploadb, immVSlot),
+	withimm("saveb", opsaveb, immVSlot),
+	withimm("loadv", oploadv, immVSlot),
+	withimm("savev", opsavev, immVSlot),
+	withimm("loadzerov", oploadzerov, immVSlot),
+	withimm("savezerov", opsavezerov, immVSlot),
+	withimm("saveblendv", opsaveblendv, immVSlot),
+	withimm("loads", oploads, immSSlot),
+	withimm("saves", opsaves, immSSlot),
+	slicecase("loadzeros", oploadzeros, immVSlot),
+	slicecase("savezeros", opsavezeros, immVSlot),
+
+	unary("false", opfalse),
+	withimm("andk", opandk, immKSlot),
+	withimm("ork", opork, immKSlot),
+	withimm("andnotk", opandnotk, immKSlot),
+	withimm("nandk", opnandk, immKSlot),
+	withimm("xork", opxork, immKSlot),
+	withimm("notk", opnotk),
+	withimm("xnork", opxnork, immKSlot),
+
+	withimm("broadcastimmf", opbroadcastimmf, immF64),
+	withimm("broadcastimmi", opbroadcastimmi, immI64),
+	unary("absf", opabsf),
+	unary("absi", opabsi),
+	unary("negf", opnegf),
+	unary("negi", opnegi),
+	withimm("addf", opaddf, immSSlot),
+	withimm("addimmf", opaddimmf, immF64),
+	withimm("addi", opaddi, immSSlot),
+	withimm("addimmi", opaddimmi, immI64),
+	withimm("subf", opsubf, immSSlot),
+	withimm("subimmf", opsubimmf, immF64),
+	withimm("subi", opsubi, immSSlot),
+	withimm("subimmi", opsubimmi, immI64),
+	withimm("mulf", opmulf, immSSlot),
+	withimm("mulimmf", opmulimmf, immF64),
+	withimm("muli", opmuli, immSSlot),
+	withimm("mulimmi", opmulimmi, immI64),
+	withimm("divf", opdivf, immSSlot),
+	withimm("divimmf", opdivimmf, immF64),
+
+	unary("cvtktof64", opcvtktof64),
+	unary("cvtktoi64", opcvtktoi64),
+	unary("cvti64tof64", opcvti64tof64),
+	unary("cvtf64toi64", opcvtf64toi64),
+	unary("fproundu", opfproundu),
+	unary("fproundd", opfproundd),
+
+	withimm("cmpeqf", opcmpeqf, immSSlot),
+	withimm("cmpeqimmf", opcmpeqimmf, immF64),
+	withimm("cmpeqi", opcmpeqi, immSSlot),
+	withimm("cmpeqimmi", opcmpeqimmi, immI64),
+	withimm("cmpltf", opcmpltf, immSSlot),
+	withimm("cmpltimmf", opcmpltimmf, immF64),
+	withimm("cmplti", opcmplti, immSSlot),
+	withimm("cmpltimmi", opcmpltimmi, immI64),
+	withimm("cmplef", opcmplef, immSSlot),
+	withimm("cmpleimmf", opcmpleimmf, immF64),
+	withimm("cmplei", opcmplei, immSSlot),
+	withimm("cmpleimmi", opcmpleimmi, immI64),
+	withimm("cmpgtf", opcmpgtf, immSSlot),
+	withimm("cmpgtimmf", opcmpgtimmf, immF64),
+	withimm("cmpgti", opcmpgti, immSSlot),
+	withimm("cmpgtimmi", opcmpgtimmi, immI64),
+	withimm("cmpgef", opcmpgef, immSSlot),
+	withimm("cmpgeimmf", opcmpgeimmf, immF64),
+	withimm("cmpgei", opcmpgei, immSSlot),
+	withimm("cmpgeimmi", opcmpgeimmi, immI64),
+
+	withimm("checktag", opchecktag, immTags),
+	unary("isnull", opisnull),
+	unary("isnotnull", opisnotnull),
+	unary("istrue", opistrue),
+	unary("isfalse", opisfalse),
+	slicecase("eqslice", opeqslice, immVSlot),
+	withimm("equalv", opequalv, immVSlot),
+	withimm("eqv4mask", opeqv4mask, immMask),
+	{
+		name: "eqv4mask+",
+		ops:  []bcop{opeqv4mask, opeqv4maskplus, opeqv4maskplus},
+		imms: [][]interpImm{{immMask}, {immMask}, {immMask}},
+	},
+	withimm("eqv8", opeqv8, immU64),
+	{
+		name: "eqv8+",
+		ops:  []bcop{opeqv8, opeqv8plus},
+		imms: [][]interpImm{{immU64}, {immU64}},
+	},
+	withimm("leneq", opleneq, immLen),
+
+	withimm("findsym", opfindsym, immSym),
+	{
+		name: "findsym2",
+		ops:  []bcop{opfindsym, opsavek, opfindsym2},
+		imms: [][]interpImm{{immSym}, {immKSlot}, {immKSlot, immSym}},
+	},
+	{
+		name: "findsym2rev",
+		ops:  []bcop{opfindsym, opsavek, opfindsym2rev},
+		imms: [][]interpImm{{immSym}, {immKSlot}, {immKSlot, immSym}},
+	},
+	{
+		name: "findsym3",
+		ops:  []bcop{opfindsym, opfindsym3, opfindsym3},
+		imms: [][]interpImm{{immSym}, {immSym}, {immSym}},
+	},
+
+	withimm("blendv", opblendv, immVSlot),
+	withimm("blendrevv", opblendrevv, immVSlot),
+	withimm("blendnum", opblendnum, immSSlot),
+	withimm("blendnumrev", opblendnumrev, immSSlot),
+	slicecase("blendslice", opblendslice, immVSlot),
+	slicecase("blendslicerev", opblendslicerev, immVSlot),
+
+	withimm("unpack", opunpack, immTag),
+	unary("toint", optoint),
+	unary("tof64", optof64),
+	unary("tuple", optuple),
+
+	unary("boxint", opboxint),
+	unary("boxfloat", opboxfloat),
+	withimm("boxmask", opboxmask, immKSlot),
+	withimm("boxmask2", opboxmask2, immKSlot),
+	unary("boxmask3", opboxmask3),
+
+	withimm("appendv", opappendv, immVSlot),
+	withimm("appendfield", opappendfield, immVSlot, immEncSym),
+	unary("boxlist", opboxlist),
+	unary("boxstruct", opboxstruct),
+
+	withimm("litref", oplitref, immLit),
+	{
+		name: "dupv",
+		ops:  []bcop{opdupv},
+		imms: [][]interpImm{{immVSlot, immVSlot}},
+	},
+	withimm("zerov", opzerov, immVSlot),
+
+	withimm("loadpermzerov", oploadpermzerov, immVSlot),
+	withimm("hashvalue", ophashvalue, immHSlot),
+	withimm("hashvalue+", ophashvalueplus, immHSlot, immHSlot),
+	withimm("hashmember", ophashmember, immHSlot, immTree),
+	withimm("hashlookup", ophashlookup, immHSlot, immTree),
+	withimm("aggbucket", opaggbucket, immHSlot),
+	withimm("aggslotadd.f", opaggslotaddf, immAggSlot),
+	withimm("aggslotadd.i", opaggslotaddi, immAggSlot),
+	withimm("aggslotavg.f", opaggslotavgf, immAggSlot),
+	withimm("aggslotavg.i", opaggslotavgi, immAggSlot),
+	withimm("aggslotmin.f", opaggslotminf, immAggSlot),
+	withimm("aggslotmin.i", opaggslotmini, immAggSlot),
+	withimm("aggslotmax.f", opaggslotmaxf, immAggSlot),
+	withimm("aggslotmax.i", opaggslotmaxi, immAggSlot),
+	withimm("aggslotcount", opaggslotcount, immAggSlot),
+	slicecase("split", opsplit),
+
+	slicecase("cmp_str_eq_cs", opCmpStrEqCs, immDict),
+	slicecase("contains_prefix_cs", opContainsPrefixCs, immDict),
+	slicecase("contains_suffix_cs", opContainsSuffixCs, immDict),
+}
+
+// interpData is randomly-generated ion data
+// and references into that data
+type interpData struct {
+	mem     []byte
+	values  []vmref // complete values
+	structs []vmref // struct bodies
+	strs    []vmref // string contents
+	lists   []vmref // list contents
+
+	tree   *radixTree64 // hash tree with interpTreeData bytes per value
+	hashes []uint64     // hashes present in tree
+}
+
+func randInterpFloat(r *rand.Rand) float64 {
+	switch r.Intn(10) {
+	case 0:
+		specials := []float64{
+			0, math.Copysign(0, -1), math.Inf(1), math.Inf(-1), math.NaN(),
+			math.MaxInt64, math.MinInt64, 1 << 63, 0.5, -0.5, 1.5, 2.5, -2.5,
+		}
+		return specials[r.Intn(len(specials))]
+	case 1, 2, 3:
+		return float64(r.Intn(200) - 100)
+	case 4:
+		return math.Float64frombits(r.Uint64())
+	default:
+		return (r.Float64() - 0.5) * math.Pow(10, float64(r.Intn(40)-10))
+	}
+}
+
+func randInterpInt(r *rand.Rand) int64 {
+	switch r.Intn(6) {
+	case 0:
+		specials := []int64{0, -1, 1, math.MaxInt64, math.MinInt64, math.MinInt64 + 1}
+		return specials[r.Intn(len(specials))]
+	case 1, 2:
+		return int64(r.Intn(200) - 100)
+	default:
+		return int64(r.Uint64()) >> r.Intn(64)
+	}
+}
+
+func randInterpValue(r *rand.Rand, buf *ion.Buffer, depth int) {
+	switch r.Intn(10) {
+	case 0:
+		buf.WriteNull()
+	case 1:
+		buf.WriteBool(r.Intn(2) == 0)
+	case 2:
+		buf.WriteInt(randInterpInt(r))
+	case 3:
+		buf.WriteFloat64(randInterpFloat(r))
+	case 4:
+		buf.WriteFloat32(float32(randInterpFloat(r)))
+	case 5:
+		buf.WriteString(string(bytes.Repeat([]byte{'a' + byte(r.Intn(3))}, r.Intn(20))))
+	case 6:
+		// typed null
+		buf.UnsafeAppend([]byte{byte(r.Intn(14)<<4) | 0xf})
+	case 7:
+		if depth < 2 {
+			randInterpStruct(r, buf, depth+1)
+			return
+		}
+		buf.WriteInt(int64(r.Intn(10)))
+	default:
+		buf.WriteString(string(bytes.Repeat([]byte{'x'}, r.Intn(300))))
+	}
+}
+
+func randInterpStruct(r *rand.Rand, buf *ion.Buffer, depth int) {
+	buf.BeginStruct(-1)
+	sym := ion.Symbol(0)
+	for n := r.Intn(8); n > 0; n-- {
+		sym += ion.Symbol(1 + r.Intn(3))
+		if r.Intn(20) == 0 {
+			sym += 200 // multi-byte symbol ID
+		}
+		buf.BeginField(sym)
+		randInterpValue(r, buf, depth)
+	}
+	buf.EndStruct()
+}
+
+// ionbody returns the position and size
+// of the body of the ion value at the start of mem
+func ionbody(mem []byte) (int, int) {
+	n := int(mem[0] & 0xf)
+	if n != 0xe {
+		return 1, n
+	}
+	n = 0
+	for i := 1; ; i++ {
+		n = n<<7 | int(mem[i]&0x7f)
+		if mem[i]&0x80 != 0 {
+			return i + 1, n
+		}
+	}
+}
+
+func newInterpData(r *rand.Rand) *interpData {
+	var buf ion.Buffer
+	d := &interpData{}
+	for buf.Size() < 16<<10 {
+		start := buf.Size()
+		switch r.Intn(5) {
+		case 0, 1:
+			randInterpStruct(r, &buf, 0)
+		case 2:
+			buf.BeginList(-1)
+			for n := r.Intn(6); n > 0; n-- {
+				randInterpValue(r, &buf, 0)
+			}
+			buf.EndList()
+		default:
+			randInterpValue(r, &buf, 0)
+		}
+		mem := buf.Bytes()[start:]
+		d.values = append(d.values, vmref{uint32(start), uint32(len(mem))})
+		hdr, size := ionbody(mem)
+		switch mem[0] >> 4 {
+		case 0xd:
+			d.structs = append(d.structs, vmref{uint32(start + hdr), uint32(size)})
+		case 0x8:
+			d.strs = append(d.strs, vmref{uint32(start + hdr), uint32(size)})
+		case 0xb:
+			d.lists = append(d.lists, vmref{uint32(start + hdr), uint32(size)})
+		}
+	}
+	d.tree = newRadixTree(interpTreeData)
+	for len(d.hashes) < 200 {
+		h := r.Uint64()
+		val, ok := d.tree.Insert(h)
+		if !ok {
+			continue
+		}
+		r.Read(val)
+		// hashlookup reads a scratch reference
+		binary.LittleEndian.PutUint32(val, uint32(r.Intn(64)))
+		binary.LittleEndian.PutUint32(val[4:], uint32(r.Intn(64)))
+		d.hashes = append(d.hashes, h)
+	}
+	d.mem = make([]byte, interpDataSize)
+	copy(d.mem, buf.Bytes())
+	// garbage after the valid data
+	r.Read(d.mem[buf.Size() : buf.Size()+1024])
+	return d
+}
+
+func (d *interpData) value(r *rand.Rand) vmref {
+	if r.Intn(10) == 0 {
+		return vmref{uint32(r.Intn(16 << 10)), uint32(r.Intn(32))}
+	}
+	return d.values[r.Intn(len(d.values))]
+}
+
+func (d *interpData) slice(r *rand.Rand) vmref {
+	if r.Intn(4) == 0 || len(d.strs) == 0 {
+		return vmref{uint32(r.Intn(16 << 10)), uint32(r.Intn(32))}
+	}
+	if r.Intn(3) == 0 && len(d.lists) > 0 {
+		return d.lists[r.Intn(len(d.lists))]
+	}
+	return d.strs[r.Intn(len(d.strs))]
+}
+
+func (d *interpData) str(ref vmref) string {
+	return string(d.mem[ref[0] : ref[0]+ref[1]])
+}
+
+// interpState is the complete input of a test program
+type interpState struct {
+	ctx     bctestContext
+	vstack  []uint64
+	dict    []string
+	hashmem []uint64
+	tree    *radixTree64 // trees[0] and R10
+	bucket  [16]int32
+	perm    [16]int32
+	outer   []uint64 // outer vstack for loadpermzero.v
+}
+
+func newInterpState(r *rand.Rand, d *interpData, slice bool) *interpState {
+	s := &interpState{}
+	c := &s.ctx
+	c.data = append([]byte(nil), d.mem...)
+	c.current = uint16(r.Uint32())
+	if r.Intn(8) == 0 {
+		c.current = 0xffff
+	}
+	c.valid = uint16(r.Uint32())
+	var slices [16]vmref
+	for i := 0; i < 16; i++ {
+		b := d.structs[r.Intn(len(d.structs))]
+		c.structBase[i], c.structLen[i] = b[0], b[1]
+		v := d.value(r)
+		c.valueBase[i], c.valueLen[i] = v[0], v[1]
+		slices[i] = d.slice(r)
+	}
+	if slice {
+		for i := 0; i < 16; i++ {
+			c.scalar[i/8][i%8] = uint64(slices[i][0])
+		}
+		// dwords 0-15 are offsets, 16-31 are lengths
+		var words [32]uint32
+		for i := 0; i < 16; i++ {
+			words[i], words[16+i] = slices[i][0], slices[i][1]
+		}
+		for i := 0; i < 16; i++ {
+			c.scalar[i/8][i%8] = uint64(words[2*i]) | uint64(words[2*i+1])<<32
+		}
+	} else {
+		for i := 0; i < 16; i++ {
+			if r.Intn(2) == 0 {
+				c.scalar[i/8][i%8] = math.Float64bits(randInterpFloat(r))
+			} else {
+				c.scalar[i/8][i%8] = uint64(randInterpInt(r))
+			}
+		}
+	}
+
+	s.vstack = newInterpStack(r, d, c)
+	s.outer = newInterpStack(r, d, c)
+
+	// most hashes are present in the tree, and the
+	// buckets are drawn from a few values so that
+	// lanes conflict
+	s.tree = d.tree
+	s.hashmem = make([]uint64, interpHSlots*32)
+	for i := range s.hashmem {
+		s.hashmem[i] = r.Uint64()
+		if i%2 == 0 && r.Intn(16) != 0 {
+			s.hashmem[i] = d.hashes[r.Intn(len(d.hashes))]
+		}
+	}
+	for i := range s.bucket {
+		s.bucket[i] = d.tree.Offset(d.hashes[r.Intn(4)])
+		s.perm[i] = int32(r.Intn(16))
+		if r.Intn(8) == 0 {
+			s.perm[i] = r.Int31()
+		}
+	}
+
+	// pick a needle that is likely to match
+	ref := slices[r.Intn(16)]
+	needle := d.str(ref)
+	if len(needle) > 0 {
+		switch r.Intn(3) {
+		case 1:
+			needle = needle[:r.Intn(len(needle))]
+		case 2:
+			needle = needle[r.Intn(len(needle)):]
+		}
+	}
+	s.dict = []string{needle}
+	return s
+}
+
+// newInterpStack returns a vstack where slots 0 and 1
+// hold references, 2 and 3 hold numbers, and the
+// mask slots follow
+func newInterpStack(r *rand.Rand, d *interpData, c *bctestContext) []uint64 {
+	stack := make([]byte, interpKSlots+64)
+	for slot := 0; slot < 2; slot++ {
+		for i := 0; i < 16; i++ {
+			v := d.value(r)
+			if slot == 1 && r.Intn(2) == 0 {
+				v = d.slice(r)
+			}
+			if r.Intn(8) == 0 {
+				v = vmref{}
+			}
+			binary.LittleEndian.PutUint32(stack[slot*vRegSize+4*i:], v[0])
+			binary.LittleEndian.PutUint32(stack[slot*vRegSize+64+4*i:], v[1])
+		}
+	}
+	for slot := 2; slot < 4; slot++ {
+		for i := 0; i < 16; i++ {
+			x := uint64(randInterpInt(r))
+			if slot == 2 || r.Intn(2) == 0 {
+				x = math.Float64bits(randInterpFloat(r))
+			}
+			if r.Intn(4) == 0 {
+				// same as the scalar register
+				x = c.scalar[i/8][i%8]
+			}
+			binary.LittleEndian.PutUint64(stack[slot*vRegSize+8*i:], x)
+		}
+	}
+	r.Read(stack[4*vRegSize:])
+	vstack := make([]uint64, len(stack)/8)
+	for i := range vstack {
+		vstack[i] = binary.LittleEndian.Uint64(stack[8*i:])
+	}
+	return vstack
+}
+
+func appendImm(r *rand.Rand, d *interpData, code []byte, imm interpImm) []byte {
+	switch imm {
+	case immKSlot:
+		return binary.LittleEndian.AppendUint16(code, uint16(interpKSlots+2*r.Intn(32)))
+	case immVSlot:
+		return binary.LittleEndian.AppendUint16(code, uint16(r.Intn(2)*vRegSize))
+	case immSSlot:
+		return binary.LittleEndian.AppendUint16(code, uint16((2+r.Intn(2))*vRegSize))
+	case immF64:
+		return binary.LittleEndian.AppendUint64(code, math.Float64bits(randInterpFloat(r)))
+	case immI64:
+		return binary.LittleEndian.AppendUint64(code, uint64(randInterpInt(r)))
+	case immMask:
+		v := d.value(r)
+		x := binary.LittleEndian.Uint32(d.mem[v[0]:])
+		mask := uint32(0xffffffff) >> (8 * r.Intn(4))
+		if r.Intn(4) == 0 {
+			x = r.Uint32()
+		}
+		code = binary.LittleEndian.AppendUint32(code, x&mask)
+		return binary.LittleEndian.AppendUint32(code, mask)
+	case immU64:
+		v := d.value(r)
+		x := binary.LittleEndian.Uint64(d.mem[v[0]:])
+		if r.Intn(4) == 0 {
+			x = r.Uint64()
+		}
+		return binary.LittleEndian.AppendUint64(code, x)
+	case immLen:
+		return binary.LittleEndian.AppendUint32(code, uint32(r.Intn(10)))
+	case immTag:
+		return append(code, byte(r.Intn(16)))
+	case immTags:
+		return binary.LittleEndian.AppendUint16(code, uint16(r.Uint32()))
+	case immSym:
+		return binary.LittleEndian.AppendUint32(code, uint32(1+r.Intn(24)))
+	case immEncSym:
+		enc, _, _ := encoded(ion.Symbol(1 + r.Intn(1<<(7*(1+r.Intn(3))))))
+		return binary.LittleEndian.AppendUint32(code, enc)
+	case immDict:
+		return binary.LittleEndian.AppendUint16(code, 0)
+	case immLit:
+		code = binary.LittleEndian.AppendUint32(code, uint32(r.Intn(64)))
+		return binary.LittleEndian.AppendUint32(code, uint32(r.Intn(64)))
+	case immHSlot:
+		return binary.LittleEndian.AppendUint16(code, uint16(r.Intn(interpHSlots)*256))
+	case immTree:
+		return binary.LittleEndian.AppendUint16(code, 0)
+	case immAggSlot:
+		// leave room for the mark or the AVG count
+		return binary.LittleEndian.AppendUint16(code, uint16(8*r.Intn(interpTreeData/8-1)))
+	}
+	panic("unknown immediate")
+}
+
+// cloneTree returns a deep copy of t
+func cloneTree(t *radixTree64) *radixTree64 {
+	c := *t
+	c.index = append([][tabsize]int32(nil), t.index...)
+	c.values = append([]byte(nil), t.values...)
+	return &c
+}
+
+func (s *interpState) run(code []byte, asm bool) (*bctestContext, *bytecode) {
+	c := s.ctx
+	c.data = append([]byte(nil), s.ctx.data...)
+	c.tree = cloneTree(s.tree)
+	bc := &bytecode{
+		compiled:   code,
+		dict:       s.dict,
+		vstack:     append([]uint64(nil), s.vstack...),
+		scratch:    c.data[interpScratchOff:interpScratchOff:(interpScratchOff + interpScratchSize)],
+		scratchoff: interpScratchOff,
+		hashmem:    append([]uint64(nil), s.hashmem...),
+		trees:      []*radixTree64{c.tree},
+		bucket:     s.bucket,
+		perm:       s.perm,
+		outer:      &bytecode{vstack: s.outer},
+	}
+	if asm {
+		bctest_run_aux(bc, &c)
+	} else {
+		c.executeGo(bc)
+	}
+	// the bucket is only meaningful in active lanes
+	for i := range bc.bucket {
+		if c.current&(1<<i) == 0 {
+			bc.bucket[i] = 0
+		}
+	}
+	return &c, bc
+}
+
+// TestInterpOps compares the portable interpreter
+// with the assembly implementation of each opcode
+// using randomly-generated inputs
+func TestInterpOps(t *testing.T) {
+	if !hasAVX512() {
+		t.Skip("cannot compare with the AVX-512 implementation on this CPU")
+	}
+	r := rand.New(rand.NewSource(0))
+	d := newInterpData(r)
+	for i := range interpCases {
+		tc := &interpCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			for iter := 0; iter < 500; iter++ {
+				var code []byte
+				for j, op := range tc.ops {
+					code = binary.LittleEndian.AppendUint16(code, uint16(op))
+					for _, imm := range tc.imms[j] {
+						code = appendImm(r, d, code, imm)
+					}
+				}
+				code = binary.LittleEndian.AppendUint16(code, uint16(opret))
+
+				s := newInterpState(r, d, tc.slice)
+				want, wantbc := s.run(code, true)
+				got, gotbc := s.run(code, false)
+				if wantbc.err != gotbc.err {
+					t.Fatalf("iter %d: got error %v, want %v", iter, gotbc.err, wantbc.err)
+				}
+				if wantbc.err != 0 {
+					if wantbc.errinfo != gotbc.errinfo {
+						t.Fatalf("iter %d: got errinfo %d, want %d", iter, gotbc.errinfo, wantbc.errinfo)
+					}
+					continue
+				}
+				if !reflect.DeepEqual(want, got) ||
+					!reflect.DeepEqual(wantbc.vstack, gotbc.vstack) ||
+					!reflect.DeepEqual(wantbc.hashmem, gotbc.hashmem) ||
+					wantbc.bucket != gotbc.bucket {
+					t.Logf("input: %+v", s.ctx)
+					interpDiff(t, want, got, wantbc, gotbc)
+					t.Fatalf("iter %d: mismatch", iter)
+				}
+			}
+		})
+	}
+}
+
+func interpDiff(t *testing.T, want, got *bctestContext, wantbc, gotbc *bytecode) {
+	if want.current != got.current {
+		t.Logf("K1: got %016b want %016b", got.current, want.current)
+	}
+	if want.valid != got.valid {
+		t.Logf("K7: got %016b want %016b", got.valid, want.valid)
+	}
+	if want.structBase != got.structBase || want.structLen != got.structLen {
+		t.Logf("Z0:Z1: got %v %v", got.structBase, got.structLen)
+		t.Logf("Z0:Z1: want %v %v", want.structBase, want.structLen)
+	}
+	if want.scalar != got.scalar {
+		t.Logf("Z2:Z3: got  %x", got.scalar)
+		t.Logf("Z2:Z3: want %x", want.scalar)
+	}
+	if want.valueBase != got.valueBase || want.valueLen != got.valueLen {
+		t.Logf("Z30:Z31: got %v %v", got.valueBase, got.valueLen)
+		t.Logf("Z30:Z31: want %v %v", want.valueBase, want.valueLen)
+	}
+	if !bytes.Equal(want.data, got.data) {
+		for i := range want.data {
+			if want.data[i] != got.data[i] {
+				t.Logf("memory differs at %d: got %x want %x", i, got.data[i:i+16], want.data[i:i+16])
+				break
+			}
+		}
+	}
+	for i := range wantbc.vstack {
+		if wantbc.vstack[i] != gotbc.vstack[i] {
+			t.Logf("vstack word %d: got %x want %x", i, gotbc.vstack[i], wantbc.vstack[i])
+		}
+	}
+	for i := range wantbc.hashmem {
+		if wantbc.hashmem[i] != gotbc.hashmem[i] {
+			t.Logf("hash word %d: got %x want %x", i, gotbc.hashmem[i], wantbc.hashmem[i])
+		}
+	}
+	if wantbc.bucket != gotbc.bucket {
+		t.Logf("L: got %v want %v", gotbc.bucket, wantbc.bucket)
+	}
+	if !bytes.Equal(want.tree.values, got.tree.values) {
+		for i := range want.tree.values {
+			if want.tree.values[i] != got.tree.values[i] {
+				t.Logf("tree values differ at %d: got %x want %x", i, got.tree.values[i:i+8], want.tree.values[i:i+8])
+				break
+			}
+		}
+	}
+}
+
+// TestInterpScan compares the portable
+// implementations of scan, scanvmm and compress
+// with the assembly implementations
+func TestInterpScan(t *testing.T) {
+	if !hasAVX512() {
+		t.Skip("cannot compare with the AVX-512 implementation on this CPU")
+	}
+	r := rand.New(rand.NewSource(0))
+	var st ion.Symtab
+	var buf ion.Buffer
+	st.Intern("foo")
+	st.Marshal(&buf, true)
+	for buf.Size() < PageSize/2 {
+		randInterpStruct(r, &buf, 0)
+		if r.Intn(20) == 0 {
+			// non-struct values are skipped
+			buf.WriteInt(randInterpInt(r))
+		}
+		if r.Intn(50) == 0 {
+			st.Marshal(&buf, true)
+		}
+	}
+	mem := Malloc()
+	defer Free(mem)
+	all := buf.Bytes()
+	copy(mem, all)
+
+	for iter := 0; iter < 1000; iter++ {
+		end := len(all) - r.Intn(64)
+		if iter%2 == 0 {
+			end = r.Intn(len(all))
+		}
+		start := int32(r.Intn(64))
+		if int(start) > end {
+			start = 0
+		}
+		size := 1 + r.Intn(40)
+		want := make([][2]uint32, size)
+		got := make([][2]uint32, size)
+		wantn, wantnext := scanAVX512(all[:end], start, want)
+		gotn, gotnext := scanGo(all[:end], start, got)
+		if wantn != gotn || wantnext != gotnext || !reflect.DeepEqual(want, got) {
+			t.Fatalf("scan(%d, %d, %d): got %d %d %v, want %d %d %v",
+				end, start, size, gotn, gotnext, got[:gotn], wantn, wantnext, want[:wantn])
+		}
+
+		wantv := make([]vmref, size)
+		gotv := make([]vmref, size)
+		wantn, wantnext = scanvmmAVX512(mem[start:end], wantv)
+		gotn, gotnext = scanvmmGo(mem[start:end], gotv)
+		if wantn != gotn || wantnext != gotnext || !reflect.DeepEqual(wantv, gotv) {
+			t.Fatalf("scanvmm(%d, %d, %d): got %d %d %v, want %d %d %v",
+				end, start, size, gotn, gotnext, gotv[:gotn], wantn, wantnext, wantv[:wantn])
+		}
+
+		for i := range wantv {
+			if r.Intn(3) == 0 {
+				wantv[i][1] = 0
+			}
+		}
+		copy(gotv, wantv)
+		wantn = compressAVX512(wantv)
+		gotn = compressGo(gotv)
+		if wantn != gotn || !reflect.DeepEqual(wantv[:wantn], gotv[:gotn]) {
+			t.Fatalf("compress: got %v, want %v", gotv[:gotn], wantv[:wantn])
+		}
+	}
+}
+
+// TestInterpRejects checks that programs that
+// the portable interpreter cannot execute are
+// rejected up front
+func TestInterpRejects(t *testing.T) {
+	defer SetPortable(true)()
+	var supported, unsupported []bcop
+	for op := bcop(0); op < _maxbcop; op++ {
+		if op == opret || opinfo[op].text == "" {
+			continue
+		}
+		if interpops[op] != nil {
+			supported = append(supported, op)
+		} else {
+			unsupported = append(unsupported, op)
+		}
+	}
+	program := func(op bcop) *bytecode {
+		code := binary.LittleEndian.AppendUint16(nil, uint16(op))
+		for _, imm := range opinfo[op].imms {
+			code = append(code, make([]byte, bcImmWidth[imm])...)
+		}
+		return &bytecode{compiled: code}
+	}
+	for _, op := range supported {
+		if err := program(op).finalize(); err != nil {
+			t.Errorf("%s: %s", opinfo[op].text, err)
+		}
+	}
+	for _, op := range unsupported {
+		err := program(op).finalize()
+		if err == nil || !strings.Contains(err.Error(), "not supported by the portable interpreter") {
+			t.Errorf("%s: unexpected error %v", opinfo[op].text, err)
+		}
+	}
+	t.Logf("%d of %d opcodes supported", len(supported), len(supported)+len(unsupported))
+}
+
+// TestInterpOperators runs the tests of the
+// operators with their own entry points
+// using the portable interpreter
+func TestInterpOperators(t *testing.T) {
+	defer SetPortable(true)()
+	t.Run("Distinct", TestDistinct)
+	t.Run("HashAggregate", TestHashAggregate)
+	t.Run("Splat", TestSplat)
+	t.Run("Unnest", TestUnnest)
+	t.Run("UnnestWhere", TestUnnestWhere)
+}
+
+// TestInterpUnnest compares the output of the
+// portable and assembly implementations of UNNEST
+// on rows with long projected fields
+func TestInterpUnnest(t *testing.T) {
+	if !hasAVX512() {
+		t.Skip("cannot compare with the AVX-512 implementation on this CPU")
+	}
+	r := rand.New(rand.NewSource(0))
+	var st ion.Symtab
+	var buf ion.Buffer
+	outer, items := st.Intern("outer"), st.Intern("items")
+	a, b, id := st.Intern("a"), st.Intern("b"), st.Intern("id")
+	st.Marshal(&buf, true)
+	counts := make([]int, 500)
+	for i := range counts {
+		counts[i] = r.Intn(5)
+		buf.BeginStruct(-1)
+		buf.BeginField(outer)
+		buf.WriteString(string(bytes.Repeat([]byte{'o'}, r.Intn(100))))
+		buf.BeginField(items)
+		buf.BeginList(-1)
+		for n := counts[i]; n > 0; n-- {
+			buf.BeginStruct(-1)
+			buf.BeginField(a)
+			buf.WriteString(string(bytes.Repeat([]byte{'a'}, r.Intn(300))))
+			buf.BeginField(b)
+			buf.WriteInt(randInterpInt(r))
+			buf.EndStruct()
+		}
+		buf.EndList()
+		buf.BeginField(id)
+		buf.WriteInt(int64(i))
+		buf.EndStruct()
+	}
+	unnest := func() []byte {
+		var out QueryBuffer
+		q := NewUnnest(&out, path(t, "items"),
+			selection("outer as o, id as id"), selection("a as a, b as b"), nil)
+		err := CopyRows(q, buftbl(buf.Bytes()), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		skipok(out.Bytes(), t)
+		return out.Bytes()
+	}
+	want := unnest()
+	restore := SetPortable(true)
+	got := unnest()
+	restore()
+	if !bytes.Equal(want, got) {
+		t.Fatalf("got %d bytes of output, want %d", len(got), len(want))
+	}
+
+	// each outer row is bound to as many
+	// output rows as it has list items
+	var outst ion.Symtab
+	rest, err := outst.Unmarshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(rest) > 0 {
+		var d ion.Datum
+		d, rest, err = ion.ReadDatum(&outst, rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, ok := d.(*ion.Struct)
+		if !ok {
+			break
+		}
+		f := s.FieldByName("id")
+		if f == nil {
+			t.Fatalf("row %v has no id", s)
+		}
+		switch id := f.Value.(type) {
+		case ion.Uint:
+			counts[id]--
+		case ion.Int:
+			counts[id]--
+		default:
+			t.Fatalf("unexpected id %v", f.Value)
+		}
+	}
+	for i := range counts {
+		if counts[i] != 0 {
+			t.Fatalf("row %d: %d unexpected output rows", i, -counts[i])
+		}
+	}
+}
@@ -199,63 +199,67 @@ const (
 	opboxmask2               bcop = 193
 	opboxmask3               bcop = 194
 	opboxstring              bcop = 195
-	ophashvalue              bcop = 196
-	ophashvalueplus          bcop = 197
-	ophashmember             bcop = 198
-	ophashlookup             bcop = 199
-	opaggsumf                bcop = 200
-	opaggsumi                bcop = 201
-	opaggminf                bcop = 202
-	opaggmini                bcop = 203
-	opaggmaxf                bcop = 204
-	opaggmaxi                bcop = 205
-	opaggcount               bcop = 206
-	opaggbucket              bcop = 207
-	opaggslotaddf            bcop = 208
-	opaggslotaddi            bcop = 209
-	opaggslotavgf            bcop = 210
-	opaggslotavgi            bcop = 211
-	opaggslotminf            bcop = 212
-	opaggslotmini            bcop = 213
-	opaggslotmaxf            bcop = 214
-	opaggslotmaxi            bcop = 215
-	opaggslotcount           bcop = 216
-	oplitref                 bcop = 217
-	opsplit                  bcop = 218
-	optuple                  bcop = 219
-	opdupv                   bcop = 220
-	opzerov                  bcop = 221
-	opobjectsize             bcop = 222
-	opCmpStrEqCs             bcop = 223
-	opCmpStrEqCi             bcop = 224
-	opCmpStrEqUTF8Ci         bcop = 225
-	opSkip1charLeft          bcop = 226
-	opSkip1charRight         bcop = 227
-	opSkipNcharLeft          bcop = 228
-	opSkipNcharRight         bcop = 229
-	opTrimWsLeft             bcop = 230
-	opTrimWsRight            bcop = 231
-	opTrim4charLeft          bcop = 232
-	opTrim4charRight         bcop = 233
-	opTrimPrefixCs           bcop = 234
-	opTrimPrefixCi           bcop = 235
-	opTrimSuffixCs           bcop = 236
-	opTrimSuffixCi           bcop = 237
-	opContainsSubstrCs       bcop = 238
-	opContainsSubstrCi       bcop = 239
-	opContainsSuffixCs       bcop = 240
-	opContainsSuffixCi       bcop = 241
-	opContainsSuffixUTF8Ci   bcop = 242
-	opContainsPrefixCs       bcop = 243
-	opContainsPrefixCi       bcop = 244
-	opContainsPrefixUTF8Ci   bcop = 245
-	opLengthStr              bcop = 246
-	opSubstr                 bcop = 247
-	opSplitPart              bcop = 248
-	opMatchpatCs             bcop = 249
-	opMatchpatCi             bcop = 250
-	opMatchpatUTF8Ci         bcop = 251
-	opIsSubnetOfIP4          bcop = 252
-	optrap                   bcop = 253
-	_maxbcop                      = 254
+	opappendv                bcop = 196
+	opappendfield            bcop = 197
+	opboxlist                bcop = 198
+	opboxstruct              bcop = 199
+	ophashvalue              bcop = 200
+	ophashvalueplus          bcop = 201
+	ophashmember             bcop = 202
+	ophashlookup             bcop = 203
+	opaggsumf                bcop = 204
+	opaggsumi                bcop = 205
+	opaggminf                bcop = 206
+	opaggmini                bcop = 207
+	opaggmaxf                bcop = 208
+	opaggmaxi                bcop = 209
+	opaggcount               bcop = 210
+	opaggbucket              bcop = 211
+	opaggslotaddf            bcop = 212
+	opaggslotaddi            bcop = 213
+	opaggslotavgf            bcop = 214
+	opaggslotavgi            bcop = 215
+	opaggslotminf            bcop = 216
+	opaggslotmini            bcop = 217
+	opaggslotmaxf            bcop = 218
+	opaggslotmaxi            bcop = 219
+	opaggslotcount           bcop = 220
+	oplitref                 bcop = 221
+	opsplit                  bcop = 222
+	optuple                  bcop = 223
+	opdupv                   bcop = 224
+	opzerov                  bcop = 225
+	opobjectsize             bcop = 226
+	opCmpStrEqCs             bcop = 227
+	opCmpStrEqCi             bcop = 228
+	opCmpStrEqUTF8Ci         bcop = 229
+	opSkip1charLeft          bcop = 230
+	opSkip1charRight         bcop = 231
+	opSkipNcharLeft          bcop = 232
+	opSkipNcharRight         bcop = 233
+	opTrimWsLeft             bcop = 234
+	opTrimWsRight            bcop = 235
+	opTrim4charLeft          bcop = 236
+	opTrim4charRight         bcop = 237
+	opTrimPrefixCs           bcop = 238
+	opTrimPrefixCi           bcop = 239
+	opTrimSuffixCs           bcop = 240
+	opTrimSuffixCi           bcop = 241
+	opContainsSubstrCs       bcop = 242
+	opContainsSubstrCi       bcop = 243
+	opContainsSuffixCs       bcop = 244
+	opContainsSuffixCi       bcop = 245
+	opContainsSuffixUTF8Ci   bcop = 246
+	opContainsPrefixCs       bcop = 247
+	opContainsPrefixCi       bcop = 248
+	opContainsPrefixUTF8Ci   bcop = 249
+	opLengthStr              bcop = 250
+	opSubstr                 bcop = 251
+	opSplitPart              bcop = 252
+	opMatchpatCs             bcop = 253
+	opMatchpatCi             bcop = 254
+	opMatchpatUTF8Ci         bcop = 255
+	opIsSubnetOfIP4          bcop = 256
+	optrap                   bcop = 257
+	_maxbcop                      = 258
 )
@@ -199,64 +199,320 @@ DATA opaddrs+0x600(SB)/8, $bcboxmask(SB)
 DATA opaddrs+0x608(SB)/8, $bcboxmask2(SB)
 DATA opaddrs+0x610(SB)/8, $bcboxmask3(SB)
 DATA opaddrs+0x618(SB)/8, $bcboxstring(SB)
-DATA opaddrs+0x620(SB)/8, $bchashvalue(SB)
-DATA opaddrs+0x628(SB)/8, $bchashvalueplus(SB)
-DATA opaddrs+0x630(SB)/8, $bchashmember(SB)
-DATA opaddrs+0x638(SB)/8, $bchashlookup(SB)
-DATA opaddrs+0x640(SB)/8, $bcaggsumf(SB)
-DATA opaddrs+0x648(SB)/8, $bcaggsumi(SB)
-DATA opaddrs+0x650(SB)/8, $bcaggminf(SB)
-DATA opaddrs+0x658(SB)/8, $bcaggmini(SB)
-DATA opaddrs+0x660(SB)/8, $bcaggmaxf(SB)
-DATA opaddrs+0x668(SB)/8, $bcaggmaxi(SB)
-DATA opaddrs+0x670(SB)/8, $bcaggcount(SB)
-DATA opaddrs+0x678(SB)/8, $bcaggbucket(SB)
-DATA opaddrs+0x680(SB)/8, $bcaggslotaddf(SB)
-DATA opaddrs+0x688(SB)/8, $bcaggslotaddi(SB)
-DATA opaddrs+0x690(SB)/8, $bcaggslotavgf(SB)
-DATA opaddrs+0x698(SB)/8, $bcaggslotavgi(SB)
-DATA opaddrs+0x6a0(SB)/8, $bcaggslotminf(SB)
-DATA opaddrs+0x6a8(SB)/8, $bcaggslotmini(SB)
-DATA opaddrs+0x6b0(SB)/8, $bcaggslotmaxf(SB)
-DATA opaddrs+0x6b8(SB)/8, $bcaggslotmaxi(SB)
-DATA opaddrs+0x6c0(SB)/8, $bcaggslotcount(SB)
-DATA opaddrs+0x6c8(SB)/8, $bclitref(SB)
-DATA opaddrs+0x6d0(SB)/8, $bcsplit(SB)
-DATA opaddrs+0x6d8(SB)/8, $bctuple(SB)
-DATA opaddrs+0x6e0(SB)/8, $bcdupv(SB)
-DATA opaddrs+0x6e8(SB)/8, $bczerov(SB)
-DATA opaddrs+0x6f0(SB)/8, $bcobjectsize(SB)
-DATA opaddrs+0x6f8(SB)/8, $bcCmpStrEqCs(SB)
-DATA opaddrs+0x700(SB)/8, $bcCmpStrEqCi(SB)
-DATA opaddrs+0x708(SB)/8, $bcCmpStrEqUTF8Ci(SB)
-DATA opaddrs+0x710(SB)/8, $bcSkip1charLeft(SB)
-DATA opaddrs+0x718(SB)/8, $bcSkip1charRight(SB)
-DATA opaddrs+0x720(SB)/8, $bcSkipNcharLeft(SB)
-DATA opaddrs+0x728(SB)/8, $bcSkipNcharRight(SB)
-DATA opaddrs+0x730(SB)/8, $bcTrimWsLeft(SB)
-DATA opaddrs+0x738(SB)/8, $bcTrimWsRight(SB)
-DATA opaddrs+0x740(SB)/8, $bcTrim4charLeft(SB)
-DATA opaddrs+0x748(SB)/8, $bcTrim4charRight(SB)
-DATA opaddrs+0x750(SB)/8, $bcTrimPrefixCs(SB)
-DATA opaddrs+0x758(SB)/8, $bcTrimPrefixCi(SB)
-DATA opaddrs+0x760(SB)/8, $bcTrimSuffixCs(SB)
-DATA opaddrs+0x768(SB)/8, $bcTrimSuffixCi(SB)
-DATA opaddrs+0x770(SB)/8, $bcContainsSubstrCs(SB)
-DATA opaddrs+0x778(SB)/8, $bcContainsSubstrCi(SB)
-DATA opaddrs+0x780(SB)/8, $bcContainsSuffixCs(SB)
-DATA opaddrs+0x788(SB)/8, $bcContainsSuffixCi(SB)
-DATA opaddrs+0x790(SB)/8, $bcContainsSuffixUTF8Ci(SB)
-DATA opaddrs+0x798(SB)/8, $bcContainsPrefixCs(SB)
-DATA opaddrs+0x7a0(SB)/8, $bcContainsPrefixCi(SB)
-DATA opaddrs+0x7a8(SB)/8, $bcContainsPrefixUTF8Ci(SB)
-DATA opaddrs+0x7b0(SB)/8, $bcLengthStr(SB)
-DATA opaddrs+0x7b8(SB)/8, $bcSubstr(SB)
-DATA opaddrs+0x7c0(SB)/8, $bcSplitPart(SB)
-DATA opaddrs+0x7c8(SB)/8, $bcMatchpatCs(SB)
-DATA opaddrs+0x7d0(SB)/8, $bcMatchpatCi(SB)
-DATA opaddrs+0x7d8(SB)/8, $bcMatchpatUTF8Ci(SB)
-DATA opaddrs+0x7e0(SB)/8, $bcIsSubnetOfIP4(SB)
-DATA opaddrs+0x7e8(SB)/8, $bctrap(SB)
-DATA opaddrs+0x7f0(SB)/8, $bctrap(SB)
-DATA opaddrs+0x7f8(SB)/8, $bctrap(SB)
-GLOBL opaddrs(SB), RODATA|NOPTR, $0x800
+DATA opaddrs+0x620(SB)/8, $bcappendv(SB)
+DATA opaddrs+0x628(SB)/8, $bcappendfield(SB)
+DATA opaddrs+0x630(SB)/8, $bcboxlist(SB)
+DATA opaddrs+0x638(SB)/8, $bcboxstruct(SB)
+DATA opaddrs+0x640(SB)/8, $bchashvalue(SB)
+DATA opaddrs+0x648(SB)/8, $bchashvalueplus(SB)
+DATA opaddrs+0x650(SB)/8, $bchashmember(SB)
+DATA opaddrs+0x658(SB)/8, $bchashlookup(SB)
+DATA opaddrs+0x660(SB)/8, $bcaggsumf(SB)
+DATA opaddrs+0x668(SB)/8, $bcaggsumi(SB)
+DATA opaddrs+0x670(SB)/8, $bcaggminf(SB)
+DATA opaddrs+0x678(SB)/8, $bcaggmini(SB)
+DATA opaddrs+0x680(SB)/8, $bcaggmaxf(SB)
+DATA opaddrs+0x688(SB)/8, $bcaggmaxi(SB)
+DATA opaddrs+0x690(SB)/8, $bcaggcount(SB)
+DATA opaddrs+0x698(SB)/8, $bcaggbucket(SB)
+DATA opaddrs+0x6a0(SB)/8, $bcaggslotaddf(SB)
+DATA opaddrs+0x6a8(SB)/8, $bcaggslotaddi(SB)
+DATA opaddrs+0x6b0(SB)/8, $bcaggslotavgf(SB)
+DATA opaddrs+0x6b8(SB)/8, $bcaggslotavgi(SB)
+DATA opaddrs+0x6c0(SB)/8, $bcaggslotminf(SB)
+DATA opaddrs+0x6c8(SB)/8, $bcaggslotmini(SB)
+DATA opaddrs+0x6d0(SB)/8, $bcaggslotmaxf(SB)
+DATA opaddrs+0x6d8(SB)/8, $bcaggslotmaxi(SB)
+DATA opaddrs+0x6e0(SB)/8, $bcaggslotcount(SB)
+DATA opaddrs+0x6e8(SB)/8, $bclitref(SB)
+DATA opaddrs+0x6f0(SB)/8, $bcsplit(SB)
+DATA opaddrs+0x6f8(SB)/8, $bctuple(SB)
+DATA opaddrs+0x700(SB)/8, $bcdupv(SB)
+DATA opaddrs+0x708(SB)/8, $bczerov(SB)
+DATA opaddrs+0x710(SB)/8, $bcobjectsize(SB)
+DATA opaddrs+0x718(SB)/8, $bcCmpStrEqCs(SB)
+DATA opaddrs+0x720(SB)/8, $bcCmpStrEqCi(SB)
+DATA opaddrs+0x728(SB)/8, $bcCmpStrEqUTF8Ci(SB)
+DATA opaddrs+0x730(SB)/8, $bcSkip1charLeft(SB)
+DATA opaddrs+0x738(SB)/8, $bcSkip1charRight(SB)
+DATA opaddrs+0x740(SB)/8, $bcSkipNcharLeft(SB)
+DATA opaddrs+0x748(SB)/8, $bcSkipNcharRight(SB)
+DATA opaddrs+0x750(SB)/8, $bcTrimWsLeft(SB)
+DATA opaddrs+0x758(SB)/8, $bcTrimWsRight(SB)
+DATA opaddrs+0x760(SB)/8, $bcTrim4charLeft(SB)
+DATA opaddrs+0x768(SB)/8, $bcTrim4charRight(SB)
+DATA opaddrs+0x770(SB)/8, $bcTrimPrefixCs(SB)
+DATA opaddrs+0x778(SB)/8, $bcTrimPrefixCi(SB)
+DATA opaddrs+0x780(SB)/8, $bcTrimSuffixCs(SB)
+DATA opaddrs+0x788(SB)/8, $bcTrimSuffixCi(SB)
+DATA opaddrs+0x790(SB)/8, $bcContainsSubstrCs(SB)
+DATA opaddrs+0x798(SB)/8, $bcContainsSubstrCi(SB)
+DATA opaddrs+0x7a0(SB)/8, $bcContainsSuffixCs(SB)
+DATA opaddrs+0x7a8(SB)/8, $bcContainsSuffixCi(SB)
+DATA opaddrs+0x7b0(SB)/8, $bcContainsSuffixUTF8Ci(SB)
+DATA opaddrs+0x7b8(SB)/8, $bcContainsPrefixCs(SB)
+DATA opaddrs+0x7c0(SB)/8, $bcContainsPrefixCi(SB)
+DATA opaddrs+0x7c8(SB)/8, $bcContainsPrefixUTF8Ci(SB)
+DATA opaddrs+0x7d0(SB)/8, $bcLengthStr(SB)
+DATA opaddrs+0x7d8(SB)/8, $bcSubstr(SB)
+DATA opaddrs+0x7e0(SB)/8, $bcSplitPart(SB)
+DATA opaddrs+0x7e8(SB)/8, $bcMatchpatCs(SB)
+DATA opaddrs+0x7f0(SB)/8, $bcMatchpatCi(SB)
+DATA opaddrs+0x7f8(SB)/8, $bcMatchpatUTF8Ci(SB)
+DATA opaddrs+0x800(SB)/8, $bcIsSubnetOfIP4(SB)
+DATA opaddrs+0x808(SB)/8, $bctrap(SB)
+DATA opaddrs+0x810(SB)/8, $bctrap(SB)
+DATA opaddrs+0x818(SB)/8, $bctrap(SB)
+DATA opaddrs+0x820(SB)/8, $bctrap(SB)
+DATA opaddrs+0x828(SB)/8, $bctrap(SB)
+DATA opaddrs+0x830(SB)/8, $bctrap(SB)
+DATA opaddrs+0x838(SB)/8, $bctrap(SB)
+DATA opaddrs+0x840(SB)/8, $bctrap(SB)
+DATA opaddrs+0x848(SB)/8, $bctrap(SB)
+DATA opaddrs+0x850(SB)/8, $bctrap(SB)
+DATA opaddrs+0x858(SB)/8, $bctrap(SB)
+DATA opaddrs+0x860(SB)/8, $bctrap(SB)
+DATA opaddrs+0x868(SB)/8, $bctrap(SB)
+DATA opaddrs+0x870(SB)/8, $bctrap(SB)
+DATA opaddrs+0x878(SB)/8, $bctrap(SB)
+DATA opaddrs+0x880(SB)/8, $bctrap(SB)
+DATA opaddrs+0x888(SB)/8, $bctrap(SB)
+DATA opaddrs+0x890(SB)/8, $bctrap(SB)
+DATA opaddrs+0x898(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8a0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8a8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8b0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8b8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8c0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8c8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8d0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8d8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8e0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8e8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8f0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x8f8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x900(SB)/8, $bctrap(SB)
+DATA opaddrs+0x908(SB)/8, $bctrap(SB)
+DATA opaddrs+0x910(SB)/8, $bctrap(SB)
+DATA opaddrs+0x918(SB)/8, $bctrap(SB)
+DATA opaddrs+0x920(SB)/8, $bctrap(SB)
+DATA opaddrs+0x928(SB)/8, $bctrap(SB)
+DATA opaddrs+0x930(SB)/8, $bctrap(SB)
+DATA opaddrs+0x938(SB)/8, $bctrap(SB)
+DATA opaddrs+0x940(SB)/8, $bctrap(SB)
+DATA opaddrs+0x948(SB)/8, $bctrap(SB)
+DATA opaddrs+0x950(SB)/8, $bctrap(SB)
+DATA opaddrs+0x958(SB)/8, $bctrap(SB)
+DATA opaddrs+0x960(SB)/8, $bctrap(SB)
+DATA opaddrs+0x968(SB)/8, $bctrap(SB)
+DATA opaddrs+0x970(SB)/8, $bctrap(SB)
+DATA opaddrs+0x978(SB)/8, $bctrap(SB)
+DATA opaddrs+0x980(SB)/8, $bctrap(SB)
+DATA opaddrs+0x988(SB)/8, $bctrap(SB)
+DATA opaddrs+0x990(SB)/8, $bctrap(SB)
+DATA opaddrs+0x998(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9a0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9a8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9b0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9b8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9c0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9c8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9d0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9d8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9e0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9e8(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9f0(SB)/8, $bctrap(SB)
+DATA opaddrs+0x9f8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa00(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa08(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa10(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa18(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa20(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa28(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa30(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa38(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa40(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa48(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa50(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa58(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa60(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa68(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa70(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa78(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa80(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa88(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa90(SB)/8, $bctrap(SB)
+DATA opaddrs+0xa98(SB)/8, $bctrap(SB)
+DATA opaddrs+0xaa0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xaa8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xab0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xab8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xac0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xac8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xad0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xad8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xae0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xae8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xaf0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xaf8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb00(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb08(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb10(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb18(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb20(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb28(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb30(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb38(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb40(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb48(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb50(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb58(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb60(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb68(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb70(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb78(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb80(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb88(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb90(SB)/8, $bctrap(SB)
+DATA opaddrs+0xb98(SB)/8, $bctrap(SB)
+DATA opaddrs+0xba0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xba8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xbb0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xbb8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xbc0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xbc8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xbd0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xbd8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xbe0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xbe8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xbf0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xbf8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc00(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc08(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc10(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc18(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc20(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc28(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc30(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc38(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc40(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc48(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc50(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc58(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc60(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc68(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc70(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc78(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc80(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc88(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc90(SB)/8, $bctrap(SB)
+DATA opaddrs+0xc98(SB)/8, $bctrap(SB)
+DATA opaddrs+0xca0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xca8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xcb0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xcb8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xcc0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xcc8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xcd0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xcd8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xce0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xce8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xcf0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xcf8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd00(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd08(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd10(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd18(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd20(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd28(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd30(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd38(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd40(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd48(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd50(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd58(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd60(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd68(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd70(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd78(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd80(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd88(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd90(SB)/8, $bctrap(SB)
+DATA opaddrs+0xd98(SB)/8, $bctrap(SB)
+DATA opaddrs+0xda0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xda8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xdb0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xdb8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xdc0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xdc8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xdd0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xdd8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xde0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xde8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xdf0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xdf8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe00(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe08(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe10(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe18(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe20(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe28(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe30(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe38(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe40(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe48(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe50(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe58(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe60(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe68(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe70(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe78(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe80(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe88(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe90(SB)/8, $bctrap(SB)
+DATA opaddrs+0xe98(SB)/8, $bctrap(SB)
+DATA opaddrs+0xea0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xea8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xeb0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xeb8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xec0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xec8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xed0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xed8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xee0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xee8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xef0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xef8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf00(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf08(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf10(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf18(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf20(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf28(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf30(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf38(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf40(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf48(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf50(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf58(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf60(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf68(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf70(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf78(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf80(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf88(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf90(SB)/8, $bctrap(SB)
+DATA opaddrs+0xf98(SB)/8, $bctrap(SB)
+DATA opaddrs+0xfa0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xfa8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xfb0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xfb8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xfc0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xfc8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xfd0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xfd8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xfe0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xfe8(SB)/8, $bctrap(SB)
+DATA opaddrs+0xff0(SB)/8, $bctrap(SB)
+DATA opaddrs+0xff8(SB)/8, $bctrap(SB)
+GLOBL opaddrs(SB), RODATA|NOPTR, $0x1000
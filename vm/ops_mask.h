@@ -1,2 +1,2 @@
 // Code generated by genops; DO NOT EDIT
-#define OPMASK 0x0ff
+#define OPMASK 0x1ff
@@ -0,0 +1,324 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SnellerInc/sneller/date"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// compiled is the symbol-table-independent
+// output of compiling a symbolized prog
+type compiled struct {
+	trees       []*radixTree64
+	instrs      []byte
+	dict        []string
+	litbuf      []byte
+	needscratch bool
+	vstacksize  int
+	hstacksize  int
+
+	// retimm is the immediate assigned to
+	// the return value during compilation
+	// (e.g. the H register number of a hash),
+	// which callers may inspect after compiling
+	retimm interface{}
+}
+
+// progcache is a process-wide cache of compiled
+// programs; identical queries (or identical
+// operators within a query) that are symbolized
+// against compatible symbol tables produce
+// identical programs, so they can skip
+// register allocation and code generation
+type progcache struct {
+	lock    sync.Mutex
+	entries map[string]*compiled
+	// max is the maximum number of entries
+	max int
+
+	hits, misses int64
+	// elapsed is the total time spent compiling
+	// programs, from the construction of the SSA
+	// through symbolization, cache lookups
+	// and code generation
+	elapsed int64
+}
+
+// compileCacheEntries is the default
+// maximum number of cached programs
+const compileCacheEntries = 4096
+
+var bccache = progcache{max: compileCacheEntries}
+
+func (c *progcache) get(key string) *compiled {
+	c.lock.Lock()
+	e := c.entries[key]
+	c.lock.Unlock()
+	if e != nil {
+		atomic.AddInt64(&c.hits, 1)
+	} else {
+		atomic.AddInt64(&c.misses, 1)
+	}
+	return e
+}
+
+func (c *progcache) put(key string, e *compiled) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.max <= 0 {
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*compiled)
+	}
+	// when the cache is full, evict an
+	// arbitrary entry; the working set of
+	// most deployments is a small number
+	// of distinct queries, so we don't
+	// bother tracking recency
+	for k := range c.entries {
+		if len(c.entries) < c.max {
+			break
+		}
+		delete(c.entries, k)
+	}
+	c.entries[key] = e
+}
+
+// CompileStats is a snapshot of the
+// statistics for bytecode compilation.
+type CompileStats struct {
+	// Hits and Misses are the number of
+	// programs that were and were not
+	// found in the compilation cache.
+	Hits, Misses int64
+	// Elapsed is the total time spent
+	// compiling programs.
+	Elapsed time.Duration
+}
+
+// ReadCompileStats returns the compilation
+// statistics accumulated by this process.
+func ReadCompileStats() CompileStats {
+	return CompileStats{
+		Hits:    atomic.LoadInt64(&bccache.hits),
+		Misses:  atomic.LoadInt64(&bccache.misses),
+		Elapsed: time.Duration(atomic.LoadInt64(&bccache.elapsed)),
+	}
+}
+
+// CompileTimer accumulates the time spent
+// compiling the programs of the operators
+// that execute a single query.
+// Operators record the time spent compiling
+// into the CompileTimer passed to their
+// SetCompileTimer method.
+//
+// The recorded time includes building and
+// symbolizing the SSA of each program, since
+// that happens whether or not the compiled
+// program is found in the cache.
+//
+// A nil *CompileTimer records nothing.
+type CompileTimer struct {
+	elapsed int64
+}
+
+// since records the time elapsed since start
+// in c and in the process-wide statistics
+func (c *CompileTimer) since(start time.Time) {
+	elapsed := int64(time.Since(start))
+	atomic.AddInt64(&bccache.elapsed, elapsed)
+	if c != nil {
+		atomic.AddInt64(&c.elapsed, elapsed)
+	}
+}
+
+// Elapsed returns the total time recorded by c.
+func (c *CompileTimer) Elapsed() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&c.elapsed))
+}
+
+// SetCompileCacheSize sets the maximum number
+// of compiled programs that are cached and
+// discards the current contents of the cache.
+// A size <= 0 disables caching.
+func SetCompileCacheSize(size int) {
+	bccache.lock.Lock()
+	defer bccache.lock.Unlock()
+	bccache.max = size
+	bccache.entries = nil
+}
+
+// cachekey returns a string that uniquely
+// identifies the compiled output of p;
+// the key includes the symbol IDs and
+// encoded literals that were resolved by
+// p.symbolize, so programs symbolized against
+// incompatible symbol tables never share
+// an entry. If p contains immediates that
+// cannot be serialized (e.g. hash trees),
+// then ok is false and p should not be cached.
+func (p *prog) cachekey() (key string, ok bool) {
+	if !p.symbolized || p.ret == nil {
+		return "", false
+	}
+	var k keybuf
+	for _, v := range p.values {
+		k.id(v)
+		k.uint(uint64(v.op))
+		k.uint(uint64(len(v.args)))
+		for _, arg := range v.args {
+			k.id(arg)
+		}
+		k.id(v.notMissing)
+		if !k.imm(v.imm) {
+			return "", false
+		}
+	}
+	k.id(p.ret)
+	for _, slot := range p.reserved {
+		k.uint(uint64(slot))
+	}
+	return string(k.buf), true
+}
+
+type keybuf struct {
+	buf []byte
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func (k *keybuf) uint(u uint64) {
+	n := binary.PutUvarint(k.tmp[:], u)
+	k.buf = append(k.buf, k.tmp[:n]...)
+}
+
+func (k *keybuf) int(i int64) {
+	n := binary.PutVarint(k.tmp[:], i)
+	k.buf = append(k.buf, k.tmp[:n]...)
+}
+
+func (k *keybuf) id(v *value) {
+	if v == nil {
+		k.int(-1)
+	} else {
+		k.int(int64(v.id))
+	}
+}
+
+func (k *keybuf) str(s string) {
+	k.uint(uint64(len(s)))
+	k.buf = append(k.buf, s...)
+}
+
+// imm appends a tagged encoding of imm
+func (k *keybuf) imm(imm interface{}) bool {
+	switch v := imm.(type) {
+	case nil:
+		k.buf = append(k.buf, 0)
+	case bool:
+		k.buf = append(k.buf, 1)
+		if v {
+			k.uint(1)
+		} else {
+			k.uint(0)
+		}
+	case int:
+		k.buf = append(k.buf, 2)
+		k.int(int64(v))
+	case int64:
+		k.buf = append(k.buf, 3)
+		k.int(v)
+	case uint:
+		k.buf = append(k.buf, 4)
+		k.uint(uint64(v))
+	case uint16:
+		k.buf = append(k.buf, 5)
+		k.uint(uint64(v))
+	case uint64:
+		k.buf = append(k.buf, 6)
+		k.uint(v)
+	case ion.Symbol:
+		k.buf = append(k.buf, 7)
+		k.uint(uint64(v))
+	case float64:
+		k.buf = append(k.buf, 8)
+		k.uint(math.Float64bits(v))
+	case float32:
+		k.buf = append(k.buf, 9)
+		k.uint(uint64(math.Float32bits(v)))
+	case string:
+		k.buf = append(k.buf, 10)
+		k.str(v)
+	case rawDatum:
+		k.buf = append(k.buf, 11)
+		k.str(string(v))
+	case date.Time:
+		var tmp ion.Buffer
+		tmp.WriteTime(v)
+		k.buf = append(k.buf, 12)
+		k.str(string(tmp.Bytes()))
+	case stackslot:
+		k.buf = append(k.buf, 13)
+		k.uint(uint64(v))
+	default:
+		return false
+	}
+	return true
+}
+
+// compileCached is equivalent to compileinto,
+// but it returns a cached result when one is
+// available and caches the result otherwise;
+// the returned value must not be modified
+func (p *prog) compileCached() (*compiled, error) {
+	key, ok := p.cachekey()
+	if ok {
+		if e := bccache.get(key); e != nil {
+			p.ret.imm = e.retimm
+			return e, nil
+		}
+	}
+	var c compilestate
+	if err := p.compileinto(&c); err != nil {
+		return nil, err
+	}
+	e := &compiled{
+		trees:       c.trees,
+		instrs:      c.instrs,
+		dict:        c.dict,
+		litbuf:      c.litbuf,
+		needscratch: c.needscratch,
+		vstacksize:  c.regs.stack.stackSize(stackTypeV),
+		hstacksize:  c.regs.stack.stackSize(stackTypeH),
+	}
+	// hash trees are built from literals
+	// for each program, so they are never shared
+	if ok && len(c.trees) == 0 {
+		e.retimm = p.ret.imm
+		bccache.put(key, e)
+	}
+	return e, nil
+}
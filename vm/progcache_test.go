@@ -0,0 +1,125 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/SnellerInc/sneller/ion"
+)
+
+func TestCompileCache(t *testing.T) {
+	defer SetCompileCacheSize(compileCacheEntries)
+	SetCompileCacheSize(compileCacheEntries)
+
+	var timer CompileTimer
+	compile := func(st *ion.Symtab) *bytecode {
+		var p prog
+		bc := bytecode{timer: &timer}
+		p.Begin()
+		x := p.Dot("x", p.ValidLanes())
+		p.Return(p.RowsMasked(p.ValidLanes(), p.Equals(x, p.Constant("foo"))))
+		if err := p.symbolize(st); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.compile(&bc); err != nil {
+			t.Fatal(err)
+		}
+		return &bc
+	}
+
+	var st0, st1 ion.Symtab
+	st0.Intern("x")
+	st1.Intern("y")
+	st1.Intern("x")
+
+	before := ReadCompileStats()
+	first := compile(&st0)
+	second := compile(&st0)
+	after := ReadCompileStats()
+	if hits := after.Hits - before.Hits; hits != 1 {
+		t.Errorf("%d hits; expected 1", hits)
+	}
+	if misses := after.Misses - before.Misses; misses != 1 {
+		t.Errorf("%d misses; expected 1", misses)
+	}
+	if after.Elapsed <= before.Elapsed {
+		t.Error("compilation time not recorded")
+	}
+	// the timer only records the time spent
+	// compiling into bytecode that uses it
+	if elapsed := timer.Elapsed(); elapsed <= 0 || elapsed > after.Elapsed-before.Elapsed {
+		t.Errorf("timer recorded %s of %s", elapsed, after.Elapsed-before.Elapsed)
+	}
+	if !bytes.Equal(first.compiled, second.compiled) {
+		t.Errorf("cached bytecode\n%s\nnot equal to\n%s", second, first)
+	}
+	// the cached copy must be independent
+	// of the bytecode it was copied into
+	first.compiled[0] ^= 0xff
+	if third := compile(&st0); !bytes.Equal(third.compiled, second.compiled) {
+		t.Error("cache entry modified through bytecode")
+	}
+
+	// a different symbol ID for x
+	// must produce a different program
+	before = ReadCompileStats()
+	other := compile(&st1)
+	after = ReadCompileStats()
+	if after.Hits != before.Hits {
+		t.Error("unexpected cache hit with a different symbol table")
+	}
+	if bytes.Equal(other.compiled, second.compiled) {
+		t.Error("expected different bytecode with a different symbol table")
+	}
+
+	// disabling the cache should
+	// always cause a miss
+	SetCompileCacheSize(0)
+	before = ReadCompileStats()
+	compile(&st0)
+	compile(&st0)
+	after = ReadCompileStats()
+	if after.Hits != before.Hits {
+		t.Error("unexpected cache hit with caching disabled")
+	}
+}
+
+func TestCompileTimerIncludesSSA(t *testing.T) {
+	var st ion.Symtab
+	var timer CompileTimer
+	const delay = 10 * time.Millisecond
+	for i := 0; i < 2; i++ {
+		// the second program is found in the cache,
+		// but building it still counts as compiling
+		before := timer.Elapsed()
+		var p prog
+		p.Begin()
+		p.Return(p.RowsMasked(p.ValidLanes(), p.ValidLanes()))
+		time.Sleep(delay)
+		if err := p.symbolize(&st); err != nil {
+			t.Fatal(err)
+		}
+		bc := bytecode{timer: &timer}
+		if err := p.compile(&bc); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := timer.Elapsed() - before; elapsed < delay {
+			t.Errorf("program %d: recorded %s; expected at least %s", i, elapsed, delay)
+		}
+	}
+}
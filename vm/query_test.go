@@ -630,3 +630,61 @@ func TestQueries(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// portableSupported runs the query in fname once
+// and returns false if it uses bytecode that
+// is not supported by the portable interpreter
+func portableSupported(t *testing.T, fname string) bool {
+	query, inputs, _ := readPath(t, fname)
+	if len(inputs) > 1 {
+		// FIXME: re-symbolizing multiple inputs
+		// may hang in testInput regardless of
+		// which interpreter is used
+		t.Skip("multiple inputs")
+	}
+	var st ion.Symtab
+	input := make([]plan.TableHandle, len(inputs))
+	for i := range inputs {
+		rows, err := rows(inputs[i], &st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input[i] = bufhandle(flatten(rows, &st))
+	}
+	q, err := partiql.Parse(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := plan.New(q, &queryenv{in: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	var stats plan.ExecStats
+	err = plan.Exec(tree, &out, &stats)
+	return err == nil || !strings.Contains(err.Error(), "not supported by the portable interpreter")
+}
+
+// TestQueriesPortable runs the query tests that
+// the portable interpreter is able to execute
+func TestQueriesPortable(t *testing.T) {
+	defer vm.SetPortable(true)()
+	err := filepath.WalkDir("./testdata/queries/", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".test") {
+			return nil
+		}
+		t.Run(strings.TrimSuffix(d.Name(), ".test"), func(t *testing.T) {
+			if !portableSupported(t, path) {
+				t.Skip("not supported by the portable interpreter")
+			}
+			testPath(t, path)
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
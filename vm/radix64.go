@@ -49,6 +49,12 @@ type radixTree64 struct {
 	vsize int
 }
 
+// memsize returns the approximate
+// number of bytes used by the tree
+func (r *radixTree64) memsize() int {
+	return len(r.values) + 4*tabsize*len(r.index)
+}
+
 func newRadixTree(datasize int) *radixTree64 {
 	rt := &radixTree64{
 		index:  make([][tabsize]int32, 1),
@@ -328,6 +334,16 @@ type aggtable struct {
 	// has an hpair entry that holds
 	// the representation of each value
 	pairs []hpair
+
+	// mem tracks memsize() against
+	// the parent's budget
+	mem memtrack
+}
+
+// memsize returns the approximate number
+// of bytes of memory used by the table
+func (a *aggtable) memsize() int {
+	return len(a.repr) + 8*len(a.pairs) + a.tree.memsize()
 }
 
 // for an aggtable, get the hash of the value
@@ -342,13 +358,13 @@ func (a *aggtable) reproff(p *hpair, off int) []byte {
 	return mem[:ion.SizeOf(mem)]
 }
 
-func (a *aggtable) repridx(p *hpair, idx int) []byte {
-	mem := a.repr[p.reprloc:]
-	for idx > 0 {
-		mem = mem[ion.SizeOf(mem):]
-		idx--
+// row returns the aggrow corresponding to p
+func (a *aggtable) row(p *hpair) aggrow {
+	return aggrow{
+		hash:  a.hashof(p),
+		repr:  a.repr[p.reprloc:],
+		value: a.valueof(p),
 	}
-	return mem[:ion.SizeOf(mem)]
 }
 
 // fullrepr returns the serialized representation of 'columns'
@@ -491,7 +507,14 @@ func (a *aggtable) initentry(buf []byte) {
 }
 
 //go:noescape
-func evalhashagg(bc *bytecode, delims []vmref, tree *radixTree64, abort *uint16) int
+func evalhashaggAVX512(bc *bytecode, delims []vmref, tree *radixTree64, abort *uint16) int
+
+func evalhashagg(bc *bytecode, delims []vmref, tree *radixTree64, abort *uint16) int {
+	if portable {
+		return evalhashaggGo(bc, delims, tree, abort)
+	}
+	return evalhashaggAVX512(bc, delims, tree, abort)
+}
 
 func (a *aggtable) fasteval(delims []vmref, abort *uint16) int {
 	if a.bc.compiled == nil {
@@ -595,12 +618,88 @@ func (a *aggtable) writeRows(delims []vmref) error {
 			}
 		}
 	}
+	size := a.memsize()
+	if spillEnabled() && size >= SpillThreshold {
+		return a.spill()
+	}
+	if err := a.mem.set(size); err != nil {
+		// when the query is running out of memory,
+		// spill early rather than failing (if we can)
+		if spillEnabled() {
+			return a.spill()
+		}
+		return err
+	}
+	return nil
+}
+
+// spillPartition returns the spill partition for a hash;
+// the tree may hold either a hash or the hash rotated
+// by 32 bits, so the partition must be the same for both
+func spillPartition(h uint64) int {
+	return int((h ^ bits.RotateLeft64(h, 32)) % spillPartitions)
+}
+
+// spill writes the entries in the table to
+// the spill partitions of the parent and
+// then resets the table
+func (a *aggtable) spill() error {
+	h := a.parent
+	h.spillLock.Lock()
+	defer h.spillLock.Unlock()
+	if h.spill == nil {
+		parts := make([]*spillFile, spillPartitions)
+		for i := range parts {
+			f, err := newSpillFile()
+			if err != nil {
+				closeSpillFiles(parts)
+				return err
+			}
+			parts[i] = f
+		}
+		h.spill = parts
+	}
+	for i := range a.pairs {
+		p := &a.pairs[i]
+		err := a.writeTo(h.spill[spillPartition(a.hashof(p))], a.pairs[i:i+1])
+		if err != nil {
+			return err
+		}
+	}
+	a.tree = newRadixTree(len(h.initialData))
+	a.repr = a.repr[:0]
+	a.pairs = a.pairs[:0]
+	a.mem.release()
+	return nil
+}
+
+// writeTo writes the entries for pairs to f
+func (a *aggtable) writeTo(f *spillFile, pairs []hpair) error {
+	columns := len(a.parent.by)
+	vsize := len(a.parent.initialData)
+	for i := range pairs {
+		p := &pairs[i]
+		hash := a.tree.values[p.hloc:][:aggregateTagSize]
+		err := f.writeFrame(hash, a.fullrepr(p, columns), a.valueof(p)[:vsize])
+		if err != nil {
+			return fmt.Errorf("hash aggregate: spilling state: %w", err)
+		}
+	}
 	return nil
 }
 
 func (a *aggtable) Close() error {
 	a.bc.reset()
 	parent := a.parent
+
+	// once anything has been spilled, the
+	// remaining tables are spilled rather
+	// than merged so that the merged state
+	// doesn't grow without bound
+	var err error
+	if parent.spilled() {
+		err = a.spill()
+	}
 	parent.lock.Lock()
 
 	// a little clever:
@@ -621,6 +720,10 @@ func (a *aggtable) Close() error {
 		parent.final = nil
 		parent.lock.Unlock()
 		a.merge(tmp)
+		tmp.mem.release()
+		if err == nil {
+			err = a.mem.set(a.memsize())
+		}
 		parent.lock.Lock()
 	}
 
@@ -629,7 +732,7 @@ func (a *aggtable) Close() error {
 		panic("duplicate aggtable.Close()")
 	}
 	parent.lock.Unlock()
-	return nil
+	return err
 }
 
 // merge the right-hand-side table into
@@ -639,23 +742,24 @@ func (a *aggtable) Close() error {
 func (a *aggtable) merge(r *aggtable) {
 	for i := range r.pairs {
 		p := &r.pairs[i]
-		// get value from rhs
-		hash := r.hashof(p)
-		repr := r.fullrepr(p, len(a.parent.by))
-		value := r.valueof(p)
-
-		// regular insert slow path for lhs
-		off, ok := a.tree.insertSlow(hash)
-		if ok {
-			reprloc := int32(len(a.repr))
-			a.repr = append(a.repr, repr...)
-			a.pairs = append(a.pairs, hpair{
-				reprloc: reprloc,
-				hloc:    off,
-			})
-			a.initentry(a.tree.values[off+8:])
-		}
+		a.mergeRow(r.hashof(p), r.fullrepr(p, len(a.parent.by)), r.valueof(p))
+	}
+}
 
-		mergeAggregatedValues(a.tree.values[off+8:], value, a.aggregateKinds)
+// mergeRow merges a single aggregated group
+// into the table
+func (a *aggtable) mergeRow(hash uint64, repr, value []byte) {
+	// regular insert slow path for lhs
+	off, ok := a.tree.insertSlow(hash)
+	if ok {
+		reprloc := int32(len(a.repr))
+		a.repr = append(a.repr, repr...)
+		a.pairs = append(a.pairs, hpair{
+			reprloc: reprloc,
+			hloc:    off,
+		})
+		a.initentry(a.tree.values[off+8:])
 	}
+
+	mergeAggregatedValues(a.tree.values[off+8:], value, a.aggregateKinds)
 }
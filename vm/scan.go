@@ -18,6 +18,9 @@ import (
 	"github.com/SnellerInc/sneller/ion"
 )
 
+//go:noescape
+func scanAVX512(buf []byte, start int32, dst [][2]uint32) (int, int32)
+
 // scan scans any buffer and produces
 // relative displacments for all of
 // the structures relative to &buf[0]
@@ -25,16 +28,27 @@ import (
 //
 // NOTE: these are *not* vmref slices;
 // those are produced by scanvmm
+func scan(buf []byte, start int32, dst [][2]uint32) (int, int32) {
+	if portable {
+		return scanGo(buf, start, dst)
+	}
+	return scanAVX512(buf, start, dst)
+}
+
 //go:noescape
-func scan(buf []byte, start int32, dst [][2]uint32) (int, int32)
+func scanvmmAVX512(buf []byte, dst []vmref) (int, int32)
 
 // scanvmm scans a vmm-allocated buffer
 // and produces absolute displacements
 // relative to vmm for all of the structures
 // present in buf, up to either len(buf) or
 // the maximum number of records that fit in dst
-//go:noescape
-func scanvmm(buf []byte, dst []vmref) (int, int32)
+func scanvmm(buf []byte, dst []vmref) (int, int32) {
+	if portable {
+		return scanvmmGo(buf, dst)
+	}
+	return scanvmmAVX512(buf, dst)
+}
 
 // encoded returns a Symbol as its UVarInt
 // encoded bytes (up to 4 bytes) and the mask
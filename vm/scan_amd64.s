@@ -19,7 +19,7 @@
 #include "textflag.h"
 
 // func scan(buf []byte, dst [][2]uint32) (int, int)
-TEXT ·scan(SB), NOSPLIT, $8
+TEXT ·scanAVX512(SB), NOSPLIT, $8
   MOVQ   buf+0(FP), SI      // SI: &raw
   MOVQ   buf_len+8(FP), DX  // DX: len(raw)
   MOVL   start+24(FP), AX   // AX: start offset
@@ -31,7 +31,7 @@ TEXT ·scan(SB), NOSPLIT, $8
   RET
 
 // func scan(buf []byte, dst [][2]uint32) (int, int)
-TEXT ·scanvmm(SB), NOSPLIT, $0
+TEXT ·scanvmmAVX512(SB), NOSPLIT, $0
   MOVQ buf+0(FP), AX
   MOVQ buf_len+8(FP), DX   // DX = relative end offset
   MOVQ ·vmm+0(SB), SI      // SI = static base
@@ -69,7 +69,15 @@ type syminfo struct {
 }
 
 //go:noescape
-func evalfindbc(w *bytecode, delims []vmref, stride int)
+func evalfindbcAVX512(w *bytecode, delims []vmref, stride int)
+
+func evalfindbc(w *bytecode, delims []vmref, stride int) {
+	if portable {
+		evalfindbcGo(w, delims, stride)
+		return
+	}
+	evalfindbcAVX512(w, delims, stride)
+}
 
 func evalfind(w *bytecode, delims []vmref, stride int) error {
 	evalfindbc(w, delims, stride*vRegSize)
@@ -81,8 +89,9 @@ func evalfind(w *bytecode, delims []vmref, stride int) error {
 }
 
 type Projection struct {
-	dst QuerySink
-	sel Selection // selection w/ renaming
+	dst   QuerySink
+	sel   Selection // selection w/ renaming
+	timer *CompileTimer
 }
 
 // NewProjection implements simple column projection from
@@ -129,6 +138,13 @@ func (b *byID) Less(i, j int) bool {
 	return b.outsel[i].value < b.outsel[j].value
 }
 
+// SetCompileTimer sets the CompileTimer that
+// records the time spent compiling the projection.
+// SetCompileTimer must be called before Open.
+func (p *Projection) SetCompileTimer(t *CompileTimer) {
+	p.timer = t
+}
+
 func (p *Projection) Open() (io.WriteCloser, error) {
 	dst, err := p.dst.Open()
 	if err != nil {
@@ -136,7 +152,7 @@ func (p *Projection) Open() (io.WriteCloser, error) {
 	}
 
 	rc, _ := dst.(rowConsumer)
-	pj := &projector{parent: p, dst: dst, dstrc: rc}
+	pj := &projector{parent: p, bc: bytecode{timer: p.timer}, dst: dst, dstrc: rc}
 
 	// set alignedWriter.out so that even if the
 	// projection goroutine receives zero rows of
@@ -306,4 +322,11 @@ func (p *projector) writeRows(delims []vmref) error {
 }
 
 //go:noescape
-func evalproject(bc *bytecode, delims []vmref, dst []byte, symbols []syminfo) (int, int)
+func evalprojectAVX512(bc *bytecode, delims []vmref, dst []byte, symbols []syminfo) (int, int)
+
+func evalproject(bc *bytecode, delims []vmref, dst []byte, symbols []syminfo) (int, int) {
+	if portable {
+		return evalprojectGo(bc, delims, dst, symbols)
+	}
+	return evalprojectAVX512(bc, delims, dst, symbols)
+}
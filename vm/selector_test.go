@@ -17,10 +17,12 @@ package vm
 import (
 	"io"
 	"io/ioutil"
+	"reflect"
 	"runtime"
 	"sync/atomic"
 	"testing"
 
+	"github.com/SnellerInc/sneller/expr"
 	"github.com/SnellerInc/sneller/ion"
 )
 
@@ -204,6 +206,73 @@ func TestSelectNested(t *testing.T) {
 	}
 }
 
+func TestSelectConstruct(t *testing.T) {
+	buf := unhex(parkingCitations1KLines)
+	project := func(sel Selection) []*ion.Struct {
+		var out QueryBuffer
+		err := CopyRows(NewProjection(sel, &out), buftbl(buf), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return readStructs(t, out.Bytes())
+	}
+	flat := project(selection("Ticket as t, Make as m, Color as c, Fine as f"))
+	// {'color': Color, 'fine': [Fine, Fine + 1], 'make': {'name': Make}} AS obj
+	sel := Selection{
+		expr.Bind(expr.Identifier("Ticket"), "t"),
+		expr.Bind(expr.CallOp(expr.MakeStruct,
+			expr.String("make"), expr.CallOp(expr.MakeStruct, expr.String("name"), expr.Identifier("Make")),
+			expr.String("fine"), expr.CallOp(expr.MakeList, expr.Identifier("Fine"), expr.Add(expr.Identifier("Fine"), expr.Integer(1))),
+			expr.String("color"), expr.Identifier("Color"),
+		), "obj"),
+	}
+	got := project(sel)
+	if len(got) != 1023 || len(got) != len(flat) {
+		t.Fatalf("got %d rows, %d flat rows", len(got), len(flat))
+	}
+	// value returns the value of the field
+	// or nil if the field is not present
+	value := func(s *ion.Struct, name string) ion.Datum {
+		if f := s.FieldByName(name); f != nil {
+			return f.Value
+		}
+		return nil
+	}
+	for i := range got {
+		if !reflect.DeepEqual(value(got[i], "t"), value(flat[i], "t")) {
+			t.Fatalf("row %d: ticket %v != %v", i, value(got[i], "t"), value(flat[i], "t"))
+		}
+		obj, ok := value(got[i], "obj").(*ion.Struct)
+		if !ok {
+			t.Fatalf("row %d: obj is %#v", i, value(got[i], "obj"))
+		}
+		for j := 1; j < len(obj.Fields); j++ {
+			if obj.Fields[j].Sym <= obj.Fields[j-1].Sym {
+				t.Fatalf("row %d: fields not sorted by symbol", i)
+			}
+		}
+		if !reflect.DeepEqual(value(obj, "color"), value(flat[i], "c")) {
+			t.Fatalf("row %d: color %v != %v", i, value(obj, "color"), value(flat[i], "c"))
+		}
+		mk, ok := value(obj, "make").(*ion.Struct)
+		if !ok || !reflect.DeepEqual(value(mk, "name"), value(flat[i], "m")) {
+			t.Fatalf("row %d: make %#v != %v", i, value(obj, "make"), value(flat[i], "m"))
+		}
+		fine, ok := value(obj, "fine").(ion.List)
+		if !ok || len(fine) != 2 {
+			t.Fatalf("row %d: fine %#v", i, value(obj, "fine"))
+		}
+		want := value(flat[i], "f")
+		if want == nil {
+			// MISSING list items become NULL
+			want = ion.UntypedNull{}
+		}
+		if !reflect.DeepEqual(fine[0], want) {
+			t.Fatalf("row %d: fine %v != %v", i, fine[0], want)
+		}
+	}
+}
+
 // dumb table that yields the same chunk 'count' times
 // (useful for benchmarking)
 type looptable struct {
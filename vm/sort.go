@@ -16,6 +16,7 @@ package vm
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"sync"
@@ -52,6 +53,29 @@ type Order struct {
 
 	// collection of all records received in writeRows (for multicolumn sorting)
 	records []sort.IonRecord
+	// approximate number of bytes held by records
+	memsize int
+	// mem tracks the records held in memory
+	// (including memruns and rawrecords)
+	// against budget
+	budget *Budget
+	mem    memtrack
+	// timer records the time spent compiling
+	timer *CompileTimer
+
+	// sorted runs of records that have been spilled
+	// to disk (see SpillDir) and the total number
+	// of records in those runs
+	runs    []*spillFile
+	runrows int
+
+	// if merge is set, each input stream is
+	// already sorted (see MergeSorted), and
+	// the streams that were not spilled to disk
+	// are kept in memruns
+	merge   bool
+	memruns [][]sort.IonRecord
+	memrows int
 
 	// collection of all records received in writeRows (for single column sorting)
 	column  sort.MixedTypeColumn
@@ -64,7 +88,9 @@ type Order struct {
 	rawrecords map[uint32][][]byte
 
 	// collection of k-top rows
-	ktop    *sort.Ktop
+	ktop *sort.Ktop
+	// symbol tables referenced by IonRecord.SymtabID
+	// (for k-top rows and merged streams)
 	symtabs []ion.Symtab
 
 	// lock for writing to `records`/`rawrecords`/'ktop'
@@ -81,6 +107,8 @@ type Order struct {
 	wg sync.WaitGroup
 
 	rp sort.RuntimeParameters
+
+	orders []sort.Ordering
 }
 
 // NewOrder constructs a new Order QuerySink that
@@ -93,7 +121,13 @@ func NewOrder(dst io.Writer, columns []SortColumn, limit *sort.Limit, parallelis
 		limit:       limit,
 		parallelism: parallelism,
 		dst:         dst,
-		rp:          sort.NewRuntimeParameters(parallelism),
+		rp:          sort.NewRuntimeParameters(parallelism, sort.WithAVX512Sorter(!portable)),
+	}
+
+	s.orders = make([]sort.Ordering, len(columns))
+	for i := range columns {
+		s.orders[i].Direction = columns[i].Direction
+		s.orders[i].Nulls = columns[i].Nulls
 	}
 
 	s.rawrecords = make(map[uint32][][]byte)
@@ -101,12 +135,52 @@ func NewOrder(dst io.Writer, columns []SortColumn, limit *sort.Limit, parallelis
 		s.ktop = s.newKtop()
 	}
 
-	s.bytesAlloc.Init(1024 * 1024)
-	s.indicesAlloc.Init(len(columns) * 1024)
-
+	s.initAllocators()
 	return s
 }
 
+// MergeSorted indicates that the rows written
+// to each of the io.WriteClosers returned by Open
+// will already be in sorted order, so the Order
+// only has to merge the streams. (The k-top
+// algorithm is still preferred when the limit
+// is small enough; see sort.RuntimeParameters.)
+// MergeSorted must be called before Open.
+func (s *Order) MergeSorted() {
+	s.merge = true
+}
+
+// SetBudget sets the Budget against which the
+// rows buffered by the Order are tracked.
+// If spilling is enabled (see SpillDir), exceeding
+// the budget causes the buffered rows to be spilled
+// to disk rather than failing the query.
+// SetBudget must be called before Open.
+func (s *Order) SetBudget(b *Budget) {
+	s.budget = b
+	s.mem.budget = b
+}
+
+// SetCompileTimer sets the CompileTimer that
+// records the time spent compiling the programs
+// that extract (and pre-filter) the sort columns.
+// SetCompileTimer must be called before Open.
+func (s *Order) SetCompileTimer(t *CompileTimer) {
+	s.timer = t
+}
+
+// Merging returns true if MergeSorted has been called
+// and the merge (rather than the k-top algorithm)
+// will be used to produce the output.
+func (s *Order) Merging() bool {
+	return s.merge && !s.useKtop()
+}
+
+func (s *Order) initAllocators() {
+	s.bytesAlloc.Init(1024 * 1024)
+	s.indicesAlloc.Init(len(s.columns) * 1024)
+}
+
 // setSymbolTable sets symbol table for the sorted data.
 //
 // It's expected that all input chunks have exactly the same symtab.
@@ -133,6 +207,11 @@ func (s *Order) useSingleColumnSorter() bool {
 	if s.limit != nil {
 		return false
 	}
+	// the single column sorter cannot
+	// spill its state to disk
+	if spillEnabled() {
+		return false
+	}
 
 	return s.rp.UseSingleColumnSorter && len(s.columns) == 1
 }
@@ -146,12 +225,7 @@ func (s *Order) useKtop() bool {
 }
 
 func (s *Order) newKtop() *sort.Ktop {
-	orders := make([]sort.Ordering, len(s.columns))
-	for i := range s.columns {
-		orders[i].Direction = s.columns[i].Direction
-		orders[i].Nulls = s.columns[i].Nulls
-	}
-	return sort.NewKtop(s.limit.Limit, orders)
+	return sort.NewKtop(s.limit.Limit, s.orders)
 }
 
 // Open implements QuerySink.Open
@@ -159,14 +233,22 @@ func (s *Order) Open() (io.WriteCloser, error) {
 	s.wg.Add(1)
 
 	if s.useKtop() {
-		return splitter(&sortstateKtop{parent: s, ktop: s.newKtop()}), nil
+		return splitter(&sortstateKtop{parent: s, ktop: s.newKtop(), findbc: bytecode{timer: s.timer}}), nil
+	} else if s.merge {
+		return splitter(newSortstateMerge(s)), nil
 	} else if s.useSingleColumnSorter() {
 		chunkID := atomic.AddUint32(&s.chunkID, 1) - 1
 		recordID := uint64(chunkID) << 32 // start with ID().chunk() = chunkID and ID().row() == 0
-		return splitter(&sortstateSingleColumn{parent: s, chunkID: chunkID, recordID: recordID}), nil
+		return splitter(&sortstateSingleColumn{
+			parent:   s,
+			chunkID:  chunkID,
+			recordID: recordID,
+			findbc:   bytecode{budget: s.budget, timer: s.timer},
+			mem:      memtrack{budget: s.budget},
+		}), nil
 	}
 
-	return splitter(&sortstateMulticolumn{parent: s}), nil
+	return splitter(&sortstateMulticolumn{parent: s, findbc: bytecode{budget: s.budget, timer: s.timer}}), nil
 }
 
 // Close implements QuerySink.Close
@@ -176,6 +258,11 @@ func (s *Order) Close() error {
 	// after this returns we can access
 	// s.sub safely
 	s.wg.Wait()
+	defer s.mem.release()
+
+	if s.Merging() {
+		return s.finalizeMerge()
+	}
 
 	if !s.useKtop() && s.symtab == nil {
 		if len(s.records) == 0 {
@@ -196,6 +283,9 @@ func (s *Order) Close() error {
 }
 
 func (s *Order) finalizeMultiColumnSorting() error {
+	if len(s.runs) > 0 {
+		return s.finalizeSpilled()
+	}
 	rowsWriter, err := sort.NewRowsWriter(s.dst, s.symtab, s.rp.ChunkAlignment)
 	if err != nil {
 		return err
@@ -274,6 +364,263 @@ func (s *Order) finalizeKtop() error {
 	return err
 }
 
+func (s *Order) compare(a, b *sort.IonRecord) int {
+	for i := range s.orders {
+		c := s.orders[i].Compare(a.UnsafeField(i), b.UnsafeField(i))
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// spillRun sorts records and writes them
+// to a new run file in SpillDir
+func (s *Order) spillRun(records []sort.IonRecord) error {
+	slices.SortStableFunc(records, func(a, b sort.IonRecord) bool {
+		return s.compare(&a, &b) < 0
+	})
+	f, err := newSpillFile()
+	if err != nil {
+		return err
+	}
+	err = writeRun(f, records)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.recordsLock.Lock()
+	s.runs = append(s.runs, f)
+	s.runrows += len(records)
+	s.recordsLock.Unlock()
+	return nil
+}
+
+// writeRun appends records to the run file f
+func writeRun(f *spillFile, records []sort.IonRecord) error {
+	var hdr []byte
+	for i := range records {
+		hdr = encodeRecordHeader(hdr[:0], &records[i])
+		err := f.writeFrame(hdr, records[i].Raw)
+		if err != nil {
+			return fmt.Errorf("writing sorted run: %w", err)
+		}
+	}
+	return nil
+}
+
+// encodeRecordHeader appends the symbol table ID,
+// the boxed size, and the field delimiters of rec to dst
+func encodeRecordHeader(dst []byte, rec *sort.IonRecord) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	dst = append(dst, tmp[:binary.PutUvarint(tmp[:], uint64(rec.SymtabID))]...)
+	dst = append(dst, tmp[:binary.PutUvarint(tmp[:], uint64(rec.Boxed))]...)
+	for i := range rec.FieldDelims {
+		dst = append(dst, tmp[:binary.PutUvarint(tmp[:], uint64(rec.FieldDelims[i][0]))]...)
+		dst = append(dst, tmp[:binary.PutUvarint(tmp[:], uint64(rec.FieldDelims[i][1]))]...)
+	}
+	return dst
+}
+
+// decodeRecord is the inverse of encodeRecordHeader
+// followed by the raw record data;
+// rec.Raw aliases frame
+func (s *Order) decodeRecord(frame []byte, rec *sort.IonRecord) error {
+	columns := len(s.columns)
+	if cap(rec.FieldDelims) < columns {
+		rec.FieldDelims = make([][2]uint32, columns)
+	}
+	rec.FieldDelims = rec.FieldDelims[:columns]
+	uvarint := func() uint32 {
+		v, n := binary.Uvarint(frame)
+		if n <= 0 {
+			frame = nil
+			return 0
+		}
+		frame = frame[n:]
+		return uint32(v)
+	}
+	rec.SymtabID = int(uvarint())
+	rec.Boxed = uvarint()
+	for i := range rec.FieldDelims {
+		rec.FieldDelims[i][0] = uvarint()
+		rec.FieldDelims[i][1] = uvarint()
+	}
+	if len(frame) < int(rec.Boxed) {
+		return fmt.Errorf("corrupt sorted run record")
+	}
+	rec.Raw = frame
+	return nil
+}
+
+// limitRange returns the number of rows
+// to skip and the number of rows to output
+// given the total number of sorted rows
+func (s *Order) limitRange(total int) (skip, take int) {
+	if s.limit == nil {
+		return 0, total
+	}
+	switch s.limit.Kind {
+	case sort.LimitToTopRows:
+		if total > s.limit.Limit {
+			skip = total - s.limit.Limit
+		}
+	case sort.LimitToRange:
+		skip = s.limit.Offset
+	}
+	return skip, s.limit.Limit
+}
+
+// finalizeSpilled produces the output
+// with a k-way merge of all of the sorted runs
+func (s *Order) finalizeSpilled() error {
+	if len(s.records) > 0 {
+		err := s.spillRun(s.records)
+		if err != nil {
+			closeSpillFiles(s.runs)
+			return err
+		}
+		s.records = nil
+	}
+	return s.writeMerged(s.symtab)
+}
+
+// finalizeMerge produces the output
+// with a k-way merge of the sorted input
+// streams (see MergeSorted)
+func (s *Order) finalizeMerge() error {
+	if len(s.runs) == 0 && len(s.memruns) == 0 {
+		// no data at all
+		return nil
+	}
+	// the streams may have been produced with different
+	// symbol tables; if one of them is a superset of
+	// all of the others, then the records can be output
+	// verbatim, otherwise they have to be re-encoded
+	var st *ion.Symtab
+	for i := range s.symtabs {
+		if st == nil || s.symtabs[i].MaxID() > st.MaxID() {
+			st = &s.symtabs[i]
+		}
+	}
+	for i := range s.symtabs {
+		if !st.Contains(&s.symtabs[i]) {
+			st = nil
+			break
+		}
+	}
+	return s.writeMerged(st)
+}
+
+// writeMerged writes the k-way merge of the
+// sorted runs to s.dst, applying the limit;
+// if st is nil then each record is re-encoded
+// from the symbol table identified by its SymtabID
+func (s *Order) writeMerged(st *ion.Symtab) error {
+	defer closeSpillFiles(s.runs)
+	var write func(rec *sort.IonRecord) error
+	var flush func() error
+	if st != nil {
+		rowsWriter, err := sort.NewRowsWriter(s.dst, st, s.rp.ChunkAlignment)
+		if err != nil {
+			return err
+		}
+		write = func(rec *sort.IonRecord) error {
+			return rowsWriter.WriteRecord(rec.Bytes())
+		}
+		flush = rowsWriter.Close
+	} else {
+		w := &resymWriter{dst: s.dst, symtabs: s.symtabs, flushSize: s.rp.ChunkAlignment}
+		write, flush = w.write, w.flush
+	}
+
+	iters, err := fileIters(s.runs, s.decodeRecord)
+	if err != nil {
+		return err
+	}
+	for i := range s.memruns {
+		iters = append(iters, &sliceIter[sort.IonRecord]{lst: s.memruns[i]})
+	}
+
+	skip, take := s.limitRange(s.runrows + s.memrows)
+	less := func(a, b *sort.IonRecord) bool {
+		return s.compare(a, b) < 0
+	}
+	emit := func(rec *sort.IonRecord) (bool, error) {
+		if skip > 0 {
+			skip--
+			return true, nil
+		}
+		if take <= 0 {
+			return false, nil
+		}
+		take--
+		return true, write(rec)
+	}
+	err = mergeRuns(iters, less, emit)
+	err2 := flush()
+	if err != nil {
+		return err
+	}
+	return err2
+}
+
+// resymWriter writes records that were captured
+// with different symbol tables by re-encoding
+// them with one output symbol table
+type resymWriter struct {
+	dst       io.Writer
+	symtabs   []ion.Symtab
+	flushSize int
+
+	st  ion.Symtab
+	row ion.Struct
+	buf ion.Buffer
+	hdr ion.Buffer
+}
+
+func (w *resymWriter) write(rec *sort.IonRecord) error {
+	var sym ion.Symbol
+	var val ion.Datum
+	var err error
+	st := &w.symtabs[rec.SymtabID]
+	w.row.Fields = w.row.Fields[:0]
+	contents := rec.Bytes()
+	for len(contents) > 0 {
+		sym, contents, err = ion.ReadLabel(contents)
+		if err != nil {
+			return err
+		}
+		val, contents, err = ion.ReadDatum(st, contents)
+		if err != nil {
+			return err
+		}
+		w.row.Fields = append(w.row.Fields, ion.Field{
+			Label: st.Get(sym),
+			Value: val,
+		})
+	}
+	w.row.Encode(&w.buf, &w.st)
+	if w.buf.Size() >= w.flushSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows as one chunk,
+// preceded by the current symbol table
+func (w *resymWriter) flush() error {
+	if w.buf.Size() == 0 {
+		return nil
+	}
+	w.hdr.Reset()
+	w.st.Marshal(&w.hdr, true)
+	w.hdr.UnsafeAppend(w.buf.Bytes())
+	w.buf.Reset()
+	_, err := w.dst.Write(w.hdr.Bytes())
+	return err
+}
+
 // ----------------------------------------------------------------------
 
 func symbolize(sort *Order, findbc *bytecode, st *ion.Symtab, global bool) error {
@@ -362,19 +709,54 @@ func (s *sortstateMulticolumn) writeRows(delims []vmref) error {
 		return err
 	}
 
-	// make room for the incoming records
 	s.parent.recordsLock.Lock()
-	if len(s.parent.records)+len(delims) > cap(s.parent.records) {
-		newCapacity := 2 * cap(s.parent.records)
+	var size int
+	s.parent.records, size = copyRecords(s.parent.records, delims, fieldsView,
+		len(s.parent.columns), &s.parent.bytesAlloc, &s.parent.indicesAlloc)
+	s.parent.memsize += size
+	err = s.parent.mem.set(s.parent.memsize)
+
+	// if we have buffered too much data,
+	// take ownership of the buffered records
+	// and write them out as a sorted run
+	var run []sort.IonRecord
+	if spillEnabled() && (s.parent.memsize >= SpillThreshold || err != nil) {
+		run = s.parent.records
+		s.parent.records = nil
+		s.parent.memsize = 0
+		s.parent.mem.release()
+		s.parent.initAllocators()
+		err = nil
+	}
+
+	s.parent.recordsLock.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if run != nil {
+		return s.parent.spillRun(run)
+	}
+	return nil
+}
+
+// copyRecords appends a copy of each of the rows in delims
+// along with the sorting columns located by bcfind to dst;
+// it returns the new slice and the number of bytes copied
+func copyRecords(dst []sort.IonRecord, delims []vmref, fieldsView []vRegLayout, columnCount int,
+	bytesAlloc *bytesAllocator, indicesAlloc *indicesAllocator) ([]sort.IonRecord, int) {
+	// make room for the incoming records
+	if len(dst)+len(delims) > cap(dst) {
+		newCapacity := 2 * cap(dst)
 		if newCapacity == 0 {
 			newCapacity = 1024
 		}
-		s.parent.records = slices.Grow(s.parent.records, newCapacity)
+		dst = slices.Grow(dst, newCapacity)
 	}
 
 	// split input data into separate records
 	blockID := 0
-	columnCount := len(s.parent.columns)
+	size := 0
 
 	for rowID := 0; rowID < len(delims); rowID++ {
 		laneID := rowID & bcLaneCountMask
@@ -392,11 +774,11 @@ func (s *sortstateMulticolumn) writeRows(delims []vmref) error {
 		}
 
 		// allocate memory and copy original row data
-		record.Raw = s.parent.bytesAlloc.Allocate(len(bytes) + int(record.Boxed))
+		record.Raw = bytesAlloc.Allocate(len(bytes) + int(record.Boxed))
 		copy(record.Raw[record.Boxed:], bytes)
 
 		// copy the field locations and also the boxed values
-		record.FieldDelims = s.parent.indicesAlloc.Allocate(columnCount)
+		record.FieldDelims = indicesAlloc.Allocate(columnCount)
 
 		boxedOffset := uint32(0)
 		for columnID := 0; columnID < columnCount; columnID++ {
@@ -408,24 +790,184 @@ func (s *sortstateMulticolumn) writeRows(delims []vmref) error {
 			boxedOffset += fieldSize
 		}
 
-		s.parent.records = append(s.parent.records, record)
+		dst = append(dst, record)
+		size += len(record.Raw)
 
 		if laneID == bcLaneCountMask {
 			blockID += columnCount
 		}
 	}
+	return dst, size
+}
 
-	s.parent.recordsLock.Unlock()
+func (s *sortstateMulticolumn) Close() error {
+	s.findbc.reset()
+	s.parent.wg.Done()
 
 	return nil
 }
 
-func (s *sortstateMulticolumn) Close() error {
-	s.parent.wg.Done()
+// ----------------------------------------------------------------------
+
+// sortstateMerge collects the rows of one input
+// stream that is already sorted (see Order.MergeSorted)
+type sortstateMerge struct {
+	// the parent context for this sorting operation
+	parent *Order
+
+	// bytecode for locating columns
+	findbc bytecode
+
+	// the current symbol table and its index in parent.symtabs
+	symtab ion.Symtab
+	symid  int
+
+	bytesAlloc   bytesAllocator
+	indicesAlloc indicesAllocator
+
+	// records that have not been spilled yet
+	// and the approximate number of bytes they hold
+	records []sort.IonRecord
+	memsize int
+	mem     memtrack
+
+	// run holds the records that have been
+	// spilled to disk and rows is their count
+	run  *spillFile
+	rows int
+
+	// a copy of the most recent record, used
+	// to check that the stream is actually sorted
+	last     sort.IonRecord
+	havelast bool
+}
 
+func newSortstateMerge(parent *Order) *sortstateMerge {
+	s := &sortstateMerge{
+		parent: parent,
+		symid:  -1,
+		findbc: bytecode{budget: parent.budget, timer: parent.timer},
+		mem:    memtrack{budget: parent.budget},
+	}
+	s.initAllocators()
+	return s
+}
+
+func (s *sortstateMerge) initAllocators() {
+	s.bytesAlloc.Init(1024 * 1024)
+	s.indicesAlloc.Init(len(s.parent.columns) * 1024)
+}
+
+func (s *sortstateMerge) symbolize(st *ion.Symtab) error {
+	if s.symid < 0 || !s.symtab.Contains(st) {
+		// records are tagged with an index
+		// into the list of all symbol tables
+		st.CloneInto(&s.symtab)
+		var saved ion.Symtab
+		st.CloneInto(&saved)
+		s.parent.recordsLock.Lock()
+		s.symid = len(s.parent.symtabs)
+		s.parent.symtabs = append(s.parent.symtabs, saved)
+		s.parent.recordsLock.Unlock()
+	}
+	return symbolize(s.parent, &s.findbc, &s.symtab, false)
+}
+
+func (s *sortstateMerge) bcfind(delims []vmref) ([]vRegLayout, error) {
+	return bcfind(s.parent, &s.findbc, delims)
+}
+
+func (s *sortstateMerge) writeRows(delims []vmref) error {
+	if len(delims) == 0 {
+		return nil
+	}
+
+	fieldsView, err := s.bcfind(delims)
+	if err != nil {
+		return err
+	}
+
+	start := len(s.records)
+	var size int
+	s.records, size = copyRecords(s.records, delims, fieldsView,
+		len(s.parent.columns), &s.bytesAlloc, &s.indicesAlloc)
+	s.memsize += size
+
+	prev := &s.last
+	if !s.havelast {
+		prev = nil
+	}
+	for i := start; i < len(s.records); i++ {
+		rec := &s.records[i]
+		rec.SymtabID = s.symid
+		if prev != nil && s.parent.compare(prev, rec) > 0 {
+			return fmt.Errorf("ORDER BY: merged input stream is not sorted")
+		}
+		prev = rec
+	}
+	s.setLast(prev)
+
+	err = s.mem.set(s.memsize)
+	if spillEnabled() && (s.memsize >= SpillThreshold || err != nil) {
+		return s.spill()
+	}
+	return err
+}
+
+// setLast copies rec into s.last
+func (s *sortstateMerge) setLast(rec *sort.IonRecord) {
+	s.last.Raw = append(s.last.Raw[:0], rec.Raw...)
+	s.last.FieldDelims = append(s.last.FieldDelims[:0], rec.FieldDelims...)
+	s.last.Boxed = rec.Boxed
+	s.havelast = true
+}
+
+// spill appends the buffered records to s.run;
+// the records are already in order, so they
+// do not have to be sorted first
+func (s *sortstateMerge) spill() error {
+	if s.run == nil {
+		f, err := newSpillFile()
+		if err != nil {
+			return err
+		}
+		s.run = f
+	}
+	err := writeRun(s.run, s.records)
+	if err != nil {
+		return err
+	}
+	s.rows += len(s.records)
+	s.records = s.records[:0]
+	s.memsize = 0
+	s.mem.release()
+	s.initAllocators()
 	return nil
 }
 
+func (s *sortstateMerge) Close() error {
+	defer s.parent.wg.Done()
+	s.findbc.reset()
+
+	var err error
+	if s.run != nil && len(s.records) > 0 {
+		err = s.spill()
+	}
+
+	s.parent.recordsLock.Lock()
+	defer s.parent.recordsLock.Unlock()
+	if s.run != nil {
+		// the parent closes the run even on error
+		s.parent.runs = append(s.parent.runs, s.run)
+		s.parent.runrows += s.rows
+	} else if len(s.records) > 0 {
+		s.parent.memruns = append(s.parent.memruns, s.records)
+		s.parent.memrows += len(s.records)
+		s.mem.moveTo(&s.parent.mem)
+	}
+	return err
+}
+
 // ----------------------------------------------------------------------
 
 type sortstateSingleColumn struct {
@@ -439,6 +981,7 @@ type sortstateSingleColumn struct {
 	recordID  uint64
 	records   [][]byte
 	subcolumn sort.MixedTypeColumn
+	mem       memtrack
 }
 
 func (s *sortstateSingleColumn) symbolize(st *ion.Symtab) error {
@@ -474,6 +1017,7 @@ func (s *sortstateSingleColumn) writeRows(delims []vmref) error {
 	// split input data into separate records
 	blockID := 0
 	columnCount := len(s.parent.columns)
+	size := int(s.mem.size)
 
 	for rowID := 0; rowID < len(delims); rowID++ {
 		laneID := rowID & bcLaneCountMask
@@ -486,6 +1030,7 @@ func (s *sortstateSingleColumn) writeRows(delims []vmref) error {
 		//record := s.parent.bytesAlloc.Allocate(len(bytes)) -- slower
 		copy(record, bytes)
 		s.records = append(s.records, record)
+		size += len(record)
 
 		// get the field value
 		item := fieldsView[blockID].item(laneID)
@@ -505,7 +1050,7 @@ func (s *sortstateSingleColumn) writeRows(delims []vmref) error {
 		}
 	}
 
-	return nil
+	return s.mem.set(size)
 }
 
 func (s *sortstateSingleColumn) Close() error {
@@ -516,7 +1061,9 @@ func (s *sortstateSingleColumn) Close() error {
 
 	// and move the collected records
 	s.parent.rawrecords[s.chunkID] = s.records
+	s.mem.moveTo(&s.parent.mem)
 	s.parent.recordsLock.Unlock()
+	s.findbc.reset()
 
 	s.parent.wg.Done()
 	return nil
@@ -670,6 +1217,7 @@ func (s *sortstateKtop) maybePrefilter() error {
 	keep = p.Or(keep, unmatched(v))
 	p.Return(keep)
 	p.symbolize(&s.symtabs[len(s.symtabs)-1])
+	s.filtbc.timer = s.parent.timer
 	err = p.compile(&s.filtbc)
 	if err != nil {
 		return err
@@ -669,3 +669,127 @@ func parsePath(s string) *expr.Path {
 
 	return p
 }
+
+// sortedStream returns chunks of rows with key = first, first+step, ...
+// in ascending order; if pad is non-empty, it is interned first
+// so that the symbol IDs differ from those of other streams
+func sortedStream(first, step, rows int, pad string) [][]byte {
+	var out [][]byte
+	for rows > 0 {
+		var buf ion.Buffer
+		var st ion.Symtab
+		if pad != "" {
+			st.Intern(pad)
+		}
+		keySym := st.Intern("key")
+		buf.StartChunk(&st)
+		for i := 0; i < 500 && rows > 0; i++ {
+			buf.BeginStruct(-1)
+			buf.BeginField(keySym)
+			buf.WriteInt(int64(first))
+			buf.EndStruct()
+			first += step
+			rows--
+		}
+		out = append(out, buf.Bytes())
+	}
+	return out
+}
+
+func TestOrderMerge(t *testing.T) {
+	const streams = 5
+	const rows = 4000
+	run := func(t *testing.T, samesyms bool, limit *sort.Limit) []*ion.Struct {
+		orderBy := []SortColumn{{
+			Node:      parsePath("key"),
+			Direction: sort.Ascending,
+			Nulls:     sort.NullsFirst,
+		}}
+		var output bytes.Buffer
+		sorter := NewOrder(&output, orderBy, limit, 4)
+		sorter.MergeSorted()
+		if !sorter.Merging() {
+			t.Fatal("expected merge mode")
+		}
+		for i := 0; i < streams; i++ {
+			pad := ""
+			if !samesyms {
+				pad = fmt.Sprintf("pad%d", i)
+			}
+			w, err := sorter.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, chunk := range sortedStream(i, streams, rows, pad) {
+				_, err = w.Write(chunk)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = w.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := sorter.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return readStructs(t, output.Bytes())
+	}
+	check := func(t *testing.T, lst []*ion.Struct, start, end int) {
+		if len(lst) != end-start {
+			t.Fatalf("got %d rows; expected %d", len(lst), end-start)
+		}
+		for i := range lst {
+			if got := intField(t, lst[i], "key"); got != int64(start+i) {
+				t.Fatalf("row %d: got key %d, want %d", i, got, start+i)
+			}
+		}
+	}
+	limit := &sort.Limit{Kind: sort.LimitToRange, Offset: 1000, Limit: 50}
+	for _, samesyms := range []bool{true, false} {
+		t.Run(fmt.Sprintf("samesyms=%v", samesyms), func(t *testing.T) {
+			check(t, run(t, samesyms, nil), 0, streams*rows)
+			check(t, run(t, samesyms, limit), 1000, 1050)
+		})
+		t.Run(fmt.Sprintf("spill,samesyms=%v", samesyms), func(t *testing.T) {
+			enableSpill(t, 16*1024)
+			check(t, run(t, samesyms, nil), 0, streams*rows)
+			check(t, run(t, samesyms, limit), 1000, 1050)
+		})
+	}
+}
+
+func TestOrderMergeUnsorted(t *testing.T) {
+	orderBy := []SortColumn{{
+		Node:      parsePath("key"),
+		Direction: sort.Descending,
+		Nulls:     sort.NullsFirst,
+	}}
+	var output bytes.Buffer
+	sorter := NewOrder(&output, orderBy, nil, 1)
+	sorter.MergeSorted()
+	w, err := sorter.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stream is sorted in ascending order
+	for _, chunk := range sortedStream(0, 1, 100, "") {
+		_, err = w.Write(chunk)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Close()
+	} else {
+		w.Close()
+	}
+	if err == nil {
+		err = sorter.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), "not sorted") {
+		t.Fatalf("expected an error about unsorted input; got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/SnellerInc/sneller/heap"
+)
+
+// SpillDir is the directory in which operators
+// that would otherwise have to buffer an unbounded
+// amount of state in memory (Order and HashAggregate)
+// create temporary files.
+// Spilling is disabled when SpillDir is empty.
+var SpillDir string
+
+// SpillThreshold is the approximate number of bytes
+// of state that a single sorting or aggregation
+// operator buffers in memory before it writes
+// that state to a file in SpillDir.
+var SpillThreshold = 256 << 20
+
+// spillPartitions is the number of partitions
+// into which hash aggregate state is spilled
+const spillPartitions = 16
+
+func spillEnabled() bool { return SpillDir != "" }
+
+// spillFile is an anonymous temporary file
+// holding a sequence of length-prefixed frames
+type spillFile struct {
+	f   *os.File
+	w   *bufio.Writer
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func newSpillFile() (*spillFile, error) {
+	f, err := os.CreateTemp(SpillDir, "spill-")
+	if err != nil {
+		return nil, fmt.Errorf("creating spill file: %w", err)
+	}
+	// unlink the file immediately so that
+	// its space is reclaimed as soon as it
+	// is closed (or the process exits)
+	os.Remove(f.Name())
+	return &spillFile{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// writeFrame writes the concatenation of parts as one frame
+func (s *spillFile) writeFrame(parts ...[]byte) error {
+	size := 0
+	for i := range parts {
+		size += len(parts[i])
+	}
+	n := binary.PutUvarint(s.tmp[:], uint64(size))
+	if _, err := s.w.Write(s.tmp[:n]); err != nil {
+		return err
+	}
+	for i := range parts {
+		if _, err := s.w.Write(parts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewind flushes any buffered frames and
+// points r at the first frame in the file
+func (s *spillFile) rewind(r *spillReader) error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r.r = bufio.NewReader(s.f)
+	return nil
+}
+
+func (s *spillFile) Close() error {
+	return s.f.Close()
+}
+
+func closeSpillFiles(lst []*spillFile) {
+	for i := range lst {
+		if lst[i] != nil {
+			lst[i].Close()
+		}
+	}
+}
+
+type spillReader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// next returns the next frame in the file
+// or io.EOF if there are no more frames;
+// the returned slice is only valid until
+// the next call to next
+func (r *spillReader) next() ([]byte, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if cap(r.buf) < int(size) {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+	_, err = io.ReadFull(r.r, r.buf)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return r.buf, err
+}
+
+// runIter iterates over a sorted run of values
+type runIter[T any] interface {
+	// next returns the next value in the run
+	// or nil if the run has been exhausted;
+	// the returned value is only valid until
+	// the next call to next
+	next() (*T, error)
+}
+
+// fileIter is a runIter over the frames in a spillFile
+type fileIter[T any] struct {
+	r      spillReader
+	val    T
+	decode func([]byte, *T) error
+}
+
+func (f *fileIter[T]) next() (*T, error) {
+	frame, err := f.r.next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f.val, f.decode(frame, &f.val)
+}
+
+// sliceIter is a runIter over an in-memory run
+type sliceIter[T any] struct {
+	lst []T
+}
+
+func (s *sliceIter[T]) next() (*T, error) {
+	if len(s.lst) == 0 {
+		return nil, nil
+	}
+	v := &s.lst[0]
+	s.lst = s.lst[1:]
+	return v, nil
+}
+
+// fileIters returns a runIter for each of runs
+func fileIters[T any](runs []*spillFile, decode func([]byte, *T) error) ([]runIter[T], error) {
+	iters := make([]runIter[T], len(runs))
+	for i := range runs {
+		f := &fileIter[T]{decode: decode}
+		if err := runs[i].rewind(&f.r); err != nil {
+			return nil, err
+		}
+		iters[i] = f
+	}
+	return iters, nil
+}
+
+type runCursor[T any] struct {
+	iter runIter[T]
+	val  *T
+}
+
+// mergeRuns performs a k-way merge of sorted runs.
+// Each value is passed to emit in the order determined
+// by less until either all of the runs are exhausted
+// or emit returns false.
+// Values passed to emit are only valid for
+// the duration of the call.
+func mergeRuns[T any](runs []runIter[T], less func(x, y *T) bool, emit func(*T) (bool, error)) error {
+	cursors := make([]*runCursor[T], 0, len(runs))
+	cless := func(x, y *runCursor[T]) bool {
+		return less(x.val, y.val)
+	}
+	for i := range runs {
+		c := &runCursor[T]{iter: runs[i]}
+		val, err := c.iter.next()
+		if err != nil {
+			return err
+		}
+		if val != nil {
+			c.val = val
+			cursors = append(cursors, c)
+		}
+	}
+	heap.OrderSlice(cursors, cless)
+	for len(cursors) > 0 {
+		c := cursors[0]
+		more, err := emit(c.val)
+		if err != nil || !more {
+			return err
+		}
+		c.val, err = c.iter.next()
+		if err != nil {
+			return err
+		}
+		if c.val != nil {
+			heap.FixSlice(cursors, 0, cless)
+		} else {
+			heap.PopSlice(&cursors, cless)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+// Copyright (C) 2022 Sneller, Inc.
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/SnellerInc/sneller/expr"
+	"github.com/SnellerInc/sneller/internal/sort"
+	"github.com/SnellerInc/sneller/ion"
+)
+
+// enableSpill turns on spilling into a temporary
+// directory with the given threshold for the
+// duration of the test
+func enableSpill(t *testing.T, threshold int) {
+	dir, thresh := SpillDir, SpillThreshold
+	SpillDir = t.TempDir()
+	SpillThreshold = threshold
+	t.Cleanup(func() {
+		SpillDir, SpillThreshold = dir, thresh
+	})
+}
+
+// readStructs returns every struct in buf
+func readStructs(t *testing.T, buf []byte) []*ion.Struct {
+	var st ion.Symtab
+	var d ion.Datum
+	var err error
+	var out []*ion.Struct
+	for len(buf) > 0 {
+		if ion.TypeOf(buf) == ion.NullType && ion.SizeOf(buf) > 1 {
+			buf = buf[ion.SizeOf(buf):]
+			continue
+		}
+		d, buf, err = ion.ReadDatum(&st, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d == nil {
+			continue
+		}
+		s, ok := d.(*ion.Struct)
+		if !ok {
+			t.Fatalf("unexpected datum %#v", d)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func intField(t *testing.T, s *ion.Struct, name string) int64 {
+	f := s.FieldByName(name)
+	if f == nil {
+		t.Fatalf("missing field %q", name)
+	}
+	switch v := f.Value.(type) {
+	case ion.Int:
+		return int64(v)
+	case ion.Uint:
+		return int64(v)
+	}
+	t.Fatalf("unexpected value %#v", f.Value)
+	return 0
+}
+
+func TestOrderSpill(t *testing.T) {
+	const rows = 50000
+	input, err := limitTestIon(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		limit      *sort.Limit
+		dir        sort.Direction
+		start, end int // expected range of keys
+	}{
+		{nil, sort.Ascending, 0, rows},
+		{nil, sort.Descending, 0, rows},
+		{&sort.Limit{Kind: sort.LimitToRange, Offset: 2000, Limit: 15}, sort.Ascending, 2000, 2015},
+		{&sort.Limit{Kind: sort.LimitToHeadRows, Limit: 5000}, sort.Ascending, 0, 5000},
+		{&sort.Limit{Kind: sort.LimitToTopRows, Limit: 100}, sort.Ascending, rows - 100, rows},
+	}
+	for i := range testcases {
+		tc := &testcases[i]
+		t.Run(fmt.Sprintf("case-%d", i), func(t *testing.T) {
+			enableSpill(t, 64*1024)
+
+			const parallelism = 4
+			orderBy := []SortColumn{{
+				Node:      parsePath("key"),
+				Direction: tc.dir,
+				Nulls:     sort.NullsFirst,
+			}}
+			var output bytes.Buffer
+			sorter := NewOrder(&output, orderBy, tc.limit, parallelism)
+			err = CopyRows(sorter, buftbl(input), parallelism)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sorter.runs) < 2 {
+				t.Fatalf("expected multiple sorted runs; got %d", len(sorter.runs))
+			}
+			err = sorter.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lst := readStructs(t, output.Bytes())
+			if len(lst) != tc.end-tc.start {
+				t.Fatalf("got %d rows; expected %d", len(lst), tc.end-tc.start)
+			}
+			for j := range lst {
+				want := int64(tc.start + j)
+				if tc.dir == sort.Descending {
+					want = int64(tc.end - j - 1)
+				}
+				if got := intField(t, lst[j], "key"); got != want {
+					t.Fatalf("row %d: got key %d, want %d", j, got, want)
+				}
+			}
+			if fds, _ := os.ReadDir(SpillDir); len(fds) != 0 {
+				t.Errorf("%d files left in spill directory", len(fds))
+			}
+		})
+	}
+}
+
+func TestHashAggregateSpill(t *testing.T) {
+	const rows = 20000
+	input, err := limitTestIon(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(t *testing.T, desc bool, limit int) []*ion.Struct {
+		var qb QueryBuffer
+		agg := Aggregation{mkagg(expr.OpCount, "id", "count"), mkagg(expr.OpSum, "id", "sum")}
+		ha, err := NewHashAggregate(agg, Selection{{Expr: path(nil, "key")}}, &qb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ha.OrderByGroup(0, desc, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ha.Limit(limit)
+		err = CopyRows(ha, buftbl(input), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ha.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return readStructs(t, qb.Bytes())
+	}
+
+	for _, desc := range []bool{false, true} {
+		for _, limit := range []int{0, 100} {
+			t.Run(fmt.Sprintf("desc=%v,limit=%d", desc, limit), func(t *testing.T) {
+				want := run(t, desc, limit)
+				enableSpill(t, 4096)
+				got := run(t, desc, limit)
+				if len(got) != len(want) {
+					t.Fatalf("got %d rows; want %d", len(got), len(want))
+				}
+				expected := rows
+				if limit > 0 {
+					expected = limit
+				}
+				if len(got) != expected {
+					t.Fatalf("got %d rows; expected %d", len(got), expected)
+				}
+				for i := range got {
+					if !reflect.DeepEqual(got[i], want[i]) {
+						t.Fatalf("row %d: got %#v want %#v", i, got[i], want[i])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestHashAggregateSpillUnordered(t *testing.T) {
+	buf, err := os.ReadFile("../testdata/nyc-taxi.block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// spill on every call to writeRows
+	enableSpill(t, 1)
+	for i := range haggTests {
+		tc := &haggTests[i]
+		t.Run(tc.agg.String(), func(t *testing.T) {
+			var qb QueryBuffer
+			ha, err := NewHashAggregate(tc.agg, Selection{{Expr: tc.group}}, &qb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			intable := &looptable{chunk: buf, count: 4}
+			err = intable.WriteChunks(ha, int(intable.count))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ha.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			lst := readStructs(t, qb.Bytes())
+			if len(lst) != len(tc.output[0].values) {
+				t.Fatalf("got %d rows; want %d", len(lst), len(tc.output[0].values))
+			}
+			// rows are unordered; match them up by group
+			for _, row := range lst {
+				group := row.FieldByName(tc.output[0].name)
+				if group == nil {
+					t.Fatalf("missing field %q", tc.output[0].name)
+				}
+				j := 0
+				for j < len(tc.output[0].values) && !reflect.DeepEqual(tc.output[0].values[j], group.Value) {
+					j++
+				}
+				if j == len(tc.output[0].values) {
+					t.Fatalf("unexpected group %#v", group.Value)
+				}
+				for _, col := range tc.output[1:] {
+					f := row.FieldByName(col.name)
+					if f == nil || !reflect.DeepEqual(col.values[j], f.Value) {
+						t.Errorf("group %#v %s: got %#v want %#v", group.Value, col.name, f, col.values[j])
+					}
+				}
+			}
+		})
+	}
+}
@@ -22,8 +22,10 @@ import (
 	"math/bits"
 	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/exp/slices"
 
@@ -342,6 +344,11 @@ const (
 	sboxstring // box a string
 	sboxts     // box a timestamp (unpacked)
 
+	sappendv     // append a value to list contents
+	sappendfield // append a field to struct contents
+	sboxlist     // box list contents
+	sboxstruct   // box struct contents
+
 	schecktag // check encoded tag bits
 	_ssamax
 )
@@ -664,7 +671,7 @@ var _ssainfo = [_ssamax]ssaopinfo{
 	// (generally they do not lead to any code being emitted)
 	sfloatk: {text: "floatk", rettype: stFloat, argtypes: []ssatype{stFloat, stBool}, emit: emittuple2regs},
 	sintk:   {text: "intk", rettype: stInt, argtypes: []ssatype{stInt, stBool}, emit: emittuple2regs},
-	sstrk:   {text: "strk", rettype: stString, argtypes: []ssatype{stString, stBool}, emit: emittuple2regs},
+	sstrk:   {text: "strk", rettype: stStringMasked, argtypes: []ssatype{stString, stBool}, emit: emittuple2regs},
 	svk:     {text: "vk", rettype: stValue, argtypes: []ssatype{stValue, stBool}, emit: emittuple2regs},
 
 	sblendv:     {text: "blendv", rettype: stValue, argtypes: []ssatype{stValue, stValue, stBool}, bc: opblendv, emit: emitblendv, blend: true},
@@ -787,6 +794,16 @@ var _ssainfo = [_ssamax]ssaopinfo{
 	sboxfloat:  {text: "boxfloat", argtypes: []ssatype{stFloat, stBool}, rettype: stValue, bc: opboxfloat, scratch: true},
 	sboxstring: {text: "boxstring", argtypes: []ssatype{stString, stBool}, rettype: stValue, bc: opboxstring, scratch: true},
 
+	// constructors
+	//
+	// the contents of a list or struct are built up
+	// by appending values (or fields) to an empty
+	// value and then boxed with the right header
+	sappendv:     {text: "append.v", argtypes: []ssatype{stValue, stValue, stBool}, rettype: stValue, bc: opappendv, emit: emitappend, scratch: true},
+	sappendfield: {text: "append.field", argtypes: []ssatype{stValue, stValue, stBool}, rettype: stValue, immfmt: fmtother, bc: opappendfield, emit: emitappend, scratch: true},
+	sboxlist:     {text: "boxlist", argtypes: []ssatype{stValue, stBool}, rettype: stValue, bc: opboxlist, scratch: true},
+	sboxstruct:   {text: "boxstruct", argtypes: []ssatype{stValue, stBool}, rettype: stValue, bc: opboxstruct, scratch: true},
+
 	// timestamp operations
 	scmplttm:                {text: "cmplt.tm", rettype: stBool, argtypes: []ssatype{stTimeInt, stTimeInt, stBool}, bc: opcmplti, emit: emitcmp, inverse: scmpgttm},
 	scmpgttm:                {text: "cmpgt.tm", rettype: stBool, argtypes: []ssatype{stTimeInt, stTimeInt, stBool}, bc: opcmpgti, emit: emitcmp, inverse: scmplttm},
@@ -880,6 +897,11 @@ type prog struct {
 	// happens; we use this to determine
 	// staleness
 	resolved []sympair
+	// start is the time at which Begin
+	// or Symbolize was called, so that the
+	// compilation time includes building
+	// and symbolizing the SSA
+	start time.Time
 }
 
 // ReserveSlot reserves a stack slot
@@ -983,6 +1005,7 @@ func (p *prog) Begin() {
 
 	p.symbolized = false
 	p.resolved = p.resolved[:0]
+	p.start = time.Now()
 }
 
 func (p *prog) undef() *value {
@@ -1461,6 +1484,11 @@ func (p *prog) intk(f, k *value) *value {
 	return p.ssa2(sintk, f, k)
 }
 
+// string+K tuple
+func (p *prog) strk(s, k *value) *value {
+	return p.ssa2(sstrk, s, k)
+}
+
 // RowsMasked constructs a (base value, predicate) tuple
 func (p *prog) RowsMasked(base *value, pred *value) *value {
 	return p.ssa2(sbk, base, pred)
@@ -3975,6 +4003,16 @@ func (p *prog) falseprop(pi *proginfo) {
 					v.args[1] = p.values[0]
 					opt = true
 				}
+			case sappendfield:
+				// a field that is never present
+				// leaves the struct contents as-is
+				if v.args[1].op == skfalse || v.args[2].op == skfalse {
+					if rewrite == nil {
+						rewrite = make([]*value, len(p.values))
+					}
+					rewrite[v.id] = v.args[0]
+					opt = true
+				}
 			case snand:
 				if v.args[0] == v.args[1] {
 					v.op = skfalse
@@ -3984,6 +4022,17 @@ func (p *prog) falseprop(pi *proginfo) {
 				}
 				fallthrough
 			default:
+				if ssainfo[v.op].blend && v.args[0].op == skfalse {
+					// blending into an absent value
+					// leaves only the blended lanes valid;
+					// the mask of the result says which
+					if rewrite == nil {
+						rewrite = make([]*value, len(p.values))
+					}
+					rewrite[v.id] = v.args[1]
+					opt = true
+					break
+				}
 				if m := v.maskarg(); m != nil &&
 					m.op == skfalse &&
 					ssainfo[v.op].rettype&stMem == 0 {
@@ -5335,6 +5384,29 @@ func emitblendv(v *value, c *compilestate) {
 	c.ops16(v, bc, c.existingStackRef(stackarg, regV))
 }
 
+// append a value from the stack
+// to the contents in the V register
+func emitappend(v *value, c *compilestate) {
+	acc := v.args[0]
+	arg := v.args[1]
+	k := v.args[2]
+
+	c.needscratch = true
+	if c.regs.cur[regV] == arg.id {
+		c.forceStackRef(arg, regV)
+	}
+	c.loadk(v, k)
+	c.loadv(v, acc)
+	c.clobberv(v)
+	slot := c.existingStackRef(arg, regV)
+	if v.op == sappendfield {
+		enc, _, _ := encoded(v.imm.(ion.Symbol))
+		c.ops16u32(v, opappendfield, slot, enc)
+		return
+	}
+	c.ops16(v, opappendv, slot)
+}
+
 // blend value in S register
 func emitblends(v *value, c *compilestate) {
 	cur := v.args[0]
@@ -5569,20 +5641,35 @@ func (p *prog) reserveslots(c *compilestate) {
 	}
 }
 
+// started returns the time at which construction
+// of the program began, or the current time if
+// the program was not started with Begin or Symbolize
+func (p *prog) started() time.Time {
+	if p.start.IsZero() {
+		return time.Now()
+	}
+	return p.start
+}
+
 func (p *prog) compile(dst *bytecode) error {
-	var c compilestate
+	defer dst.timer.since(p.started())
 
-	if err := p.compileinto(&c); err != nil {
+	c, err := p.compileCached()
+	if err != nil {
 		return err
 	}
 
-	dst.vstacksize = c.regs.stack.stackSize(stackTypeV)
-	dst.hstacksize = c.regs.stack.stackSize(stackTypeH)
+	dst.vstacksize = c.vstacksize
+	dst.hstacksize = c.hstacksize
 
 	dst.allocStacks()
 	dst.trees = c.trees
-	dst.dict = c.dict
-	dst.compiled = c.instrs
+	// c may be shared with other programs,
+	// so dst gets its own copy of anything
+	// that may be appended to or modified
+	dst.dict = slices.Clip(c.dict)
+	dst.compiled = make([]byte, len(c.instrs), len(c.instrs)+2)
+	copy(dst.compiled, c.instrs)
 	dst.scratchreserve = 0
 
 	// try to reserve scratch space destructively:
@@ -5591,12 +5678,16 @@ func (p *prog) compile(dst *bytecode) error {
 	// just force the buffer to be allocated with a reasonable
 	// amount of space
 	if c.litbuf != nil {
-		if !dst.setlit(c.litbuf) {
-			return fmt.Errorf("literal buffer (len=%d) too large", len(c.litbuf))
+		if err := dst.setlit(c.litbuf); err != nil {
+			return err
 		}
 	} else if c.needscratch && dst.scratch == nil {
 		// zero-length, large-capacity buffer
-		dst.scratch = Malloc()[:0]
+		mem, err := dst.budget.Malloc()
+		if err != nil {
+			return err
+		}
+		dst.scratch = mem[:0]
 		dst.scratchoff, _ = vmdispl(dst.scratch[:1])
 	}
 	return dst.finalize()
@@ -5604,6 +5695,7 @@ func (p *prog) compile(dst *bytecode) error {
 
 // append a second program to 'dst'
 func (p *prog) appendcode(dst *bytecode) error {
+	defer dst.timer.since(p.started())
 	var c compilestate
 	c.dict = dst.dict
 
@@ -5705,7 +5797,9 @@ func (p *prog) Renumber() {
 // to the program by copying the old program
 // to 'dst' and applying rewrites to findsym operations.
 func (p *prog) Symbolize(st *ion.Symtab, dst *prog) error {
+	start := time.Now()
 	p.clone(dst)
+	dst.start = start
 	return dst.symbolize(st)
 }
 
@@ -5772,8 +5866,12 @@ func (p *prog) symbolize(st *ion.Symtab) error {
 	for i := range p.values {
 		v := p.values[i]
 		if v.op == shashmember {
-			p.literals = true
-			v.imm = p.mktree(st, v.imm)
+			// semi-join trees are built ahead of time
+			// and are independent of the symbol table
+			if _, ok := v.imm.(*radixTree64); !ok {
+				p.literals = true
+				v.imm = p.mktree(st, v.imm)
+			}
 		} else if v.op == shashlookup {
 			p.literals = true
 			v.imm = p.mkhash(st, v.imm)
@@ -5784,6 +5882,14 @@ func (p *prog) symbolize(st *ion.Symtab) error {
 				d.Encode(&tmp, st)
 				v.imm = rawDatum(tmp.Bytes())
 			}
+		} else if v.op == sappendfield {
+			// constructed fields are always
+			// present in the output symbol table
+			if str, ok := v.imm.(string); ok {
+				sym := st.Intern(str)
+				v.imm = sym
+				p.record(str, sym)
+			}
 		}
 		if v.op != sdot {
 			continue
@@ -5810,6 +5916,45 @@ func (p *prog) symbolize(st *ion.Symtab) error {
 		v.imm = sym
 		p.record(str, sym)
 	}
+	p.sortfields()
 	p.symbolized = true
 	return nil
 }
+
+// sortfields orders the fields appended
+// to each constructed struct by symbol ID,
+// which is the order in which they have
+// to be encoded
+func (p *prog) sortfields() {
+	type field struct {
+		val, k *value
+		sym    ion.Symbol
+	}
+	var chain []*value
+	var fields []field
+	for _, v := range p.values {
+		if v.op != sboxstruct {
+			continue
+		}
+		chain = chain[:0]
+		for acc := v.args[0]; acc.op == sappendfield; acc = acc.args[0] {
+			chain = append(chain, acc)
+		}
+		// the chain is walked from the last field
+		// to the first; none of the field values
+		// depend on the chain, so they can be exchanged
+		// between its links (the program is re-scheduled
+		// when it is optimized)
+		fields = fields[:0]
+		for i := len(chain) - 1; i >= 0; i-- {
+			fields = append(fields, field{chain[i].args[1], chain[i].args[2], chain[i].imm.(ion.Symbol)})
+		}
+		sort.SliceStable(fields, func(i, j int) bool {
+			return fields[i].sym < fields[j].sym
+		})
+		for i := range fields {
+			c := chain[len(chain)-1-i]
+			c.args[1], c.args[2], c.imm = fields[i].val, fields[i].k, fields[i].sym
+		}
+	}
+}
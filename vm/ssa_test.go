@@ -1309,3 +1309,103 @@ func BenchmarkNYCQueries(b *testing.B) {
 		})
 	}
 }
+
+func TestDatumCaseSSA(t *testing.T) {
+	x := expr.Identifier("x")
+	s := expr.Identifier("s")
+	trim := expr.Call("TRIM", s)
+	y := expr.Identifier("y")
+	tcs := []struct {
+		c *expr.Case
+		// number of boxed arms
+		boxed int
+		// number of blended string arms
+		blends int
+		// number of blended values
+		blendv int
+	}{
+		{
+			// CASE WHEN x > 1 THEN 'big' WHEN x > 0 THEN TRIM(s) ELSE 'small' END
+			c: &expr.Case{
+				Limbs: []expr.CaseLimb{
+					{When: expr.Compare(expr.Greater, x, expr.Integer(1)), Then: expr.String("big")},
+					{When: expr.Compare(expr.Greater, x, expr.Integer(0)), Then: trim},
+				},
+				Else: expr.String("small"),
+			},
+			boxed:  1,
+			blends: 2,
+		},
+		{
+			// NULLIF(TRIM(s), 'x')
+			c:      expr.NullIf(trim, expr.String("x")).(*expr.Case),
+			boxed:  1,
+			blends: 0,
+			blendv: 1,
+		},
+		{
+			// CASE WHEN x > 2 THEN y WHEN x > 1 THEN TRIM(s) WHEN x > 0 THEN s ELSE 'small' END
+			//
+			// the string arms are blended and boxed once,
+			// and then blended with the (unboxed) values of y and s
+			c: &expr.Case{
+				Limbs: []expr.CaseLimb{
+					{When: expr.Compare(expr.Greater, x, expr.Integer(2)), Then: y},
+					{When: expr.Compare(expr.Greater, x, expr.Integer(1)), Then: trim},
+					{When: expr.Compare(expr.Greater, x, expr.Integer(0)), Then: s},
+				},
+				Else: expr.String("small"),
+			},
+			boxed:  1,
+			blends: 1,
+			blendv: 2,
+		},
+	}
+	for i := range tcs {
+		c := tcs[i].c
+		t.Run(expr.ToString(c), func(t *testing.T) {
+			if !hasStringArm(c) {
+				t.Fatal("no string arms")
+			}
+			var p prog
+			p.Begin()
+			v, err := p.serialized(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mem, err := p.Store(p.InitMem(), v, stackSlotFromIndex(regV, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Return(mem)
+			var st ion.Symtab
+			st.Intern("x")
+			st.Intern("s")
+			st.Intern("y")
+			var sym prog
+			var bc bytecode
+			err = p.Symbolize(&st, &sym)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = sym.compile(&bc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer bc.reset()
+			text := bc.String()
+			if n := strings.Count(text, "boxstring"); n != tcs[i].boxed {
+				t.Errorf("got %d boxstring ops, want %d", n, tcs[i].boxed)
+			}
+			if n := strings.Count(text, "blendslice"); n != tcs[i].blends {
+				t.Errorf("got %d blendslice ops, want %d", n, tcs[i].blends)
+			}
+			if n := strings.Count(text, "blend.v"); n != tcs[i].blendv {
+				t.Errorf("got %d blend.v ops, want %d", n, tcs[i].blendv)
+			}
+			if t.Failed() {
+				t.Logf("bytecode:\n%s", text)
+			}
+		})
+	}
+}
@@ -32,6 +32,7 @@ type UnnestProjection struct {
 	field        *expr.Path
 	outer, inner Selection
 	filter       expr.Node
+	timer        *CompileTimer
 }
 
 // NewUnnest creates a new UnnestProjection.
@@ -51,13 +52,20 @@ func NewUnnest(dst QuerySink, field *expr.Path, outer, inner Selection, filter e
 	}
 }
 
+// SetCompileTimer sets the CompileTimer that
+// records the time spent compiling the projection.
+// SetCompileTimer must be called before Open.
+func (u *UnnestProjection) SetCompileTimer(t *CompileTimer) {
+	u.timer = t
+}
+
 func (u *UnnestProjection) Open() (io.WriteCloser, error) {
 	dst, err := u.dst.Open()
 	if err != nil {
 		return nil, err
 	}
 	rc, _ := dst.(rowConsumer)
-	unnest := &unnesting{parent: u, out: dst, dstrc: rc}
+	unnest := &unnesting{parent: u, outerbc: bytecode{timer: u.timer}, out: dst, dstrc: rc}
 	unnest.aw.out = dst
 	return splitter(unnest), nil
 }
@@ -120,6 +128,12 @@ func (u *unnesting) symbolize(st *ion.Symtab) error {
 		u.outord[i] = i
 	}
 	sort.Stable((*uByID)(u))
+	// outord[i] is the binding in sorted position i,
+	// so the output slot of binding j is slots[j]
+	slots := make([]int, len(u.outord))
+	for i, j := range u.outord {
+		slots[j] = i
+	}
 	var p prog
 	var err error
 	p.Begin()
@@ -152,8 +166,8 @@ func (u *unnesting) symbolize(st *ion.Symtab) error {
 	// reserve input slots
 	// so that the register allocator
 	// does not clobber them
-	for i := range u.outord[:len(u.parent.outer)] {
-		p.ReserveSlot(stackSlotFromIndex(regV, u.outord[i]))
+	for i := range u.parent.outer {
+		p.ReserveSlot(stackSlotFromIndex(regV, slots[i]))
 	}
 	mem = make([]*value, len(u.parent.inner))
 	for i := range u.parent.inner {
@@ -162,7 +176,7 @@ func (u *unnesting) symbolize(st *ion.Symtab) error {
 		if !ok {
 			return fmt.Errorf("cannot handle non-path expression %q", u.parent.inner[i].Expr)
 		}
-		mem[i], err = p.compileStore(mem0, u.parent.inner[i].Expr, stackSlotFromIndex(regV, u.outord[j]))
+		mem[i], err = p.compileStore(mem0, u.parent.inner[i].Expr, stackSlotFromIndex(regV, slots[j]))
 		if err != nil {
 			panic(err)
 		}
@@ -182,7 +196,10 @@ func (u *unnesting) symbolize(st *ion.Symtab) error {
 	// appear as if they were bound correctly in advance
 	//
 	// FIXME: just do this in the SSA instead
-	maskSlot := stackSlotFromIndex(regV, len(u.parent.outer))
+	//
+	// the mask is saved past the output slots so that
+	// storing the outer bindings does not clobber it
+	maskSlot := stackSlotFromIndex(regV, len(u.outsel))
 	u.innerbc.outer = &u.outerbc
 	u.innerbc.compiled = append(u.innerbc.compiled[:0],
 		// save.k [0]
@@ -191,11 +208,12 @@ func (u *unnesting) symbolize(st *ion.Symtab) error {
 		byte(optuple), byte(optuple>>8),
 	)
 	for i := range u.parent.outer {
-		outSlot := stackSlotFromIndex(regV, u.outord[i])
+		inSlot := stackSlotFromIndex(regV, i)
+		outSlot := stackSlotFromIndex(regV, slots[i])
 		u.innerbc.compiled = append(u.innerbc.compiled,
 			// load upvalue [i]
-			byte(oploadpermzerov), byte(oploadpermzerov>>8), byte(i), byte(i>>8),
-			// store locally in slot outord[i]
+			byte(oploadpermzerov), byte(oploadpermzerov>>8), byte(inSlot), byte(inSlot>>8),
+			// store locally in slot slots[i]
 			byte(opsavezerov), byte(opsavezerov>>8), byte(outSlot), byte(outSlot>>8),
 		)
 	}
@@ -220,13 +238,34 @@ func (u *unnesting) symbolize(st *ion.Symtab) error {
 }
 
 //go:noescape
-func evalsplat(bc *bytecode, indelims, outdelims []vmref, perm []int32) (int, int)
+func evalsplatAVX512(bc *bytecode, indelims, outdelims []vmref, perm []int32) (int, int)
+
+func evalsplat(bc *bytecode, indelims, outdelims []vmref, perm []int32) (int, int) {
+	if portable {
+		return evalsplatGo(bc, indelims, outdelims, perm)
+	}
+	return evalsplatAVX512(bc, indelims, outdelims, perm)
+}
 
 //go:noescape
-func evalunnest(bc *bytecode, delims []vmref, perm []int32, dst []byte, symbols []syminfo) (int, int)
+func evalunnestAVX512(bc *bytecode, delims []vmref, perm []int32, dst []byte, symbols []syminfo) (int, int)
+
+func evalunnest(bc *bytecode, delims []vmref, perm []int32, dst []byte, symbols []syminfo) (int, int) {
+	if portable {
+		return evalunnestGo(bc, delims, perm, dst, symbols)
+	}
+	return evalunnestAVX512(bc, delims, perm, dst, symbols)
+}
 
 //go:noescape
-func compress(delims []vmref) int
+func compressAVX512(delims []vmref) int
+
+func compress(delims []vmref) int {
+	if portable {
+		return compressGo(delims)
+	}
+	return compressAVX512(delims)
+}
 
 func (u *unnesting) writeRows(delims []vmref) error {
 	if len(delims) == 0 {